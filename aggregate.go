@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runAggregateCommand handles "codemap aggregate repoA/ repoB/ -o FILE" and
+// returns the process exit code.
+func runAggregateCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap aggregate", flag.ExitOnError)
+	output := fs.String("o", "PLATFORM_CODEMAP.md", "Output file for the merged codemap")
+	fs.BoolVar(&opts.IncludeTests, "tests", false, "Include test files")
+	fs.BoolVar(&opts.Verbose, "v", false, "Verbose output")
+	repos := parseInterspersed(fs, args)
+	if len(repos) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: codemap aggregate <repo>... [-o FILE]")
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	cm, err := codemap.Aggregate(ctx, repos, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	content, err := codemap.Render(cm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(*output, []byte(content), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "error: write %s: %v\n", *output, err)
+		return 1
+	}
+
+	for _, warning := range cm.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	if opts.Verbose {
+		fmt.Printf("Generated %s: %d repos, %d packages\n", *output, len(cm.Repos), len(cm.Packages))
+	} else {
+		fmt.Printf("Generated %s\n", *output)
+	}
+	return 0
+}
+
+// parseInterspersed parses flags that may appear before, between, or after
+// positional arguments and returns the positional arguments in order.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		_ = fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runCleanOutputsCommand handles "codemap clean-outputs" and returns the
+// process exit code.
+func runCleanOutputsCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap clean-outputs", flag.ExitOnError)
+	applyLimits := bindOptionFlags(fs, &opts)
+	dryRun := fs.Bool("n", false, "List orphaned outputs without removing them")
+	_ = fs.Parse(args)
+	applyLimits()
+
+	var (
+		paths []string
+		err   error
+	)
+	if *dryRun {
+		paths, err = codemap.OrphanedOutputs(opts)
+	} else {
+		paths, err = codemap.CleanOutputs(opts)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if len(paths) == 0 {
+		fmt.Println("No orphaned outputs")
+		return 0
+	}
+	verb := "Removed"
+	if *dryRun {
+		verb = "Would remove"
+	}
+	for _, path := range paths {
+		fmt.Printf("%s %s\n", verb, path)
+	}
+	return 0
+}
+
+// warnOrphanedOutputs reports outputs left behind by earlier runs with other
+// output paths.
+func warnOrphanedOutputs(opts codemap.Options) {
+	orphans, err := codemap.OrphanedOutputs(opts)
+	if err != nil || len(orphans) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "warning: outputs from earlier runs remain: %s (run codemap clean-outputs)\n", strings.Join(orphans, ", "))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runDaemonCommand handles "codemap daemon", "codemap status" and
+// "codemap refresh" and returns the process exit code.
+func runDaemonCommand(name string, args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap "+name, flag.ExitOnError)
+	applyLimits := bindOptionFlags(fs, &opts)
+	force := false
+	pkg := ""
+	if name == "refresh" {
+		fs.BoolVar(&force, "force", false, "Force regeneration even if outputs are up to date")
+		fs.StringVar(&pkg, "package", "", "Re-analyze only this package path (e.g. after saving one of its files)")
+	}
+	if name == "daemon" {
+		fs.DurationVar(&opts.AutoRefreshInterval, "auto-refresh", 0, "Poll for changes this often and regenerate automatically (0 = only on refresh requests)")
+		fs.DurationVar(&opts.RefreshQuiescence, "quiescence", 2*time.Second, "With -auto-refresh, wait until no changes were seen for this long before regenerating")
+		fs.DurationVar(&opts.RefreshMinInterval, "min-refresh-interval", 10*time.Second, "With -auto-refresh, minimum time between automatic regenerations")
+	}
+	timeout := fs.Duration("timeout", 5*time.Minute, "Maximum time to wait for the daemon")
+	_ = fs.Parse(args)
+	applyLimits()
+
+	if name == "daemon" {
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		if opts.Verbose {
+			if socketPath, err := codemap.ResolveSocketPath(opts); err == nil {
+				fmt.Printf("Serving codemap on %s\n", socketPath)
+			}
+		}
+		if err := codemap.NewDaemon(opts).Serve(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	socketPath, err := codemap.ResolveSocketPath(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	req := codemap.DaemonRequest{Command: name, Force: force}
+	if pkg != "" {
+		req = codemap.DaemonRequest{Command: codemap.DaemonCommandRefreshPackage, Package: pkg}
+	}
+	req.Options = codemap.DaemonOptionsSignature(opts)
+	resp, err := codemap.CallDaemon(ctx, socketPath, req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+	for _, warning := range resp.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+
+	if name == "status" {
+		state := "up to date"
+		if resp.Stale {
+			state = "stale"
+		}
+		fmt.Printf("Codemap outputs are %s (%d packages, %d concerns)\n", state, resp.Packages, resp.Concerns)
+		if resp.Stale {
+			return 1
+		}
+		return 0
+	}
+
+	if resp.Generated {
+		fmt.Printf("Generated codemap: %d packages, %d concerns\n", resp.Packages, resp.Concerns)
+	} else {
+		fmt.Println("Codemap outputs are up to date")
+	}
+	return 0
+}
+
+// bindDaemonClientFlag registers -daemon for the commands a running daemon
+// can answer.
+func bindDaemonClientFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("daemon", false, "Ask the daemon listening on -socket, which keeps the index, state and caches in memory; runs locally when no daemon is listening")
+}
+
+// callRunningDaemon sends req to the daemon of opts. It returns a nil
+// response without error when no daemon is listening or the daemon runs with
+// other options, so the caller does the work itself.
+func callRunningDaemon(ctx context.Context, opts codemap.Options, req codemap.DaemonRequest) (*codemap.DaemonResponse, error) {
+	socketPath, err := codemap.ResolveSocketPath(opts)
+	if err != nil {
+		return nil, err
+	}
+	req.Options = codemap.DaemonOptionsSignature(opts)
+	resp, err := codemap.CallDaemon(ctx, socketPath, req)
+	switch {
+	case errors.Is(err, codemap.ErrNoDaemon):
+		return nil, nil
+	case errors.Is(err, codemap.ErrDaemonOptions):
+		if opts.Verbose {
+			fmt.Fprintf(os.Stderr, "%v; running locally\n", err)
+		}
+		return nil, nil
+	}
+	return resp, err
+}
+
+// printDaemonRefresh reports a refresh made by the daemon for "codemap
+// generate -daemon" the way a local run does, and returns the exit code.
+func printDaemonRefresh(opts codemap.Options, gen *generateFlags, resp *codemap.DaemonResponse) int {
+	if resp.Generated {
+		for _, warning := range resp.Warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+		}
+	}
+	switch {
+	case gen.jsonStatus:
+		status := generationStatus{Generated: resp.Generated, Packages: resp.Packages, Hash: resp.ContentHash}
+		if err := json.NewEncoder(os.Stdout).Encode(status); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+	case gen.quiet:
+	case !resp.Generated:
+		printUpToDate(opts)
+	default:
+		printGeneratedSummary(opts, resp.Packages, resp.Concerns)
+	}
+	return 0
+}
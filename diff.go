@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runDiffCommand handles "codemap diff" and returns the process exit code:
+// 0 when nothing changed, 1 when packages differ, 2 on errors.
+func runDiffCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap diff", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: codemap diff [flags]")
+		fmt.Fprintln(fs.Output(), "Compare the current analysis with the cache of the last run or of -base.")
+		fs.PrintDefaults()
+	}
+	applyLimits := bindOptionFlags(fs, &opts)
+	base := fs.String("base", "", "State file or analysis cache of an earlier run to compare against (default: the current state)")
+	asJSON := fs.Bool("json", false, "Print the diff as JSON")
+	_ = fs.Parse(args)
+	applyLimits()
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	diff, err := codemap.DiffAnalysis(ctx, opts, *base)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diff); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+	} else {
+		printAnalysisDiff(diff)
+	}
+	if diff.Empty() {
+		return 0
+	}
+	return 1
+}
+
+func printAnalysisDiff(diff *codemap.AnalysisDiff) {
+	if diff.Empty() {
+		fmt.Println("No package changes.")
+		return
+	}
+	for _, d := range diff.Added {
+		fmt.Printf("+ %s (%s): %d lines", d.Package, d.Language, d.LineCount)
+		if d.EntryPoint != "" {
+			fmt.Printf(", entry %s", d.EntryPoint)
+		}
+		fmt.Println()
+		printTypeChanges(d)
+	}
+	for _, d := range diff.Removed {
+		fmt.Printf("- %s (%s): %d lines\n", d.Package, d.Language, d.LineCount)
+	}
+	for _, d := range diff.Changed {
+		fmt.Printf("~ %s (%s): %+d lines (%d)", d.Package, d.Language, d.LineDelta, d.LineCount)
+		if d.EntryChanged {
+			fmt.Printf(", entry %s -> %s", orNone(d.PrevEntryPoint), orNone(d.EntryPoint))
+		}
+		fmt.Println()
+		printTypeChanges(d)
+	}
+}
+
+func printTypeChanges(d codemap.PackageDelta) {
+	if len(d.NewTypes) > 0 {
+		fmt.Printf("    new: %s\n", strings.Join(d.NewTypes, ", "))
+	}
+	if len(d.RemovedTypes) > 0 {
+		fmt.Printf("    removed: %s\n", strings.Join(d.RemovedTypes, ", "))
+	}
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package codemap
+
+import (
+	"sort"
+	"strings"
+)
+
+// assignAdaptiveDetail spends Options.DetailBudget, in approximate tokens, on
+// file listings. Packages are ranked by fan-in (project packages importing
+// them) and churn (recent commits touching them), each relative to the
+// highest in the project; in that order, a package keeps its full listing
+// while the budget allows. Every other package, and any package nothing
+// imports or changes, is reduced to its table row plus the JSON key files of
+// Options.JSONMinFiles, or all its files for the pages of
+// Options.SplitOutputDir.
+func assignAdaptiveDetail(packages []Package, commits [][]string, opts Options) {
+	pkgPaths := make(map[string]struct{}, len(packages))
+	for _, pkg := range packages {
+		pkgPaths[pkg.RelativePath] = struct{}{}
+	}
+	fanIn := make(map[string]int)
+	for _, pkg := range packages {
+		for _, dep := range pkg.DependsOn {
+			fanIn[dep]++
+		}
+	}
+	churn := make(map[string]int)
+	for _, files := range commits {
+		touched := make(map[string]struct{})
+		for _, file := range files {
+			if pkgPath, ok := owningPackagePath(file, pkgPaths); ok {
+				touched[pkgPath] = struct{}{}
+			}
+		}
+		for pkgPath := range touched {
+			churn[pkgPath]++
+		}
+	}
+	maxFanIn, maxChurn := 0, 0
+	for _, n := range fanIn {
+		maxFanIn = max(maxFanIn, n)
+	}
+	for _, n := range churn {
+		maxChurn = max(maxChurn, n)
+	}
+	score := func(pkg Package) float64 {
+		var s float64
+		if maxFanIn > 0 {
+			s += float64(fanIn[pkg.RelativePath]) / float64(maxFanIn)
+		}
+		if maxChurn > 0 {
+			s += float64(churn[pkg.RelativePath]) / float64(maxChurn)
+		}
+		return s
+	}
+
+	order := make([]int, len(packages))
+	scores := make([]float64, len(packages))
+	for i := range packages {
+		order[i] = i
+		scores[i] = score(packages[i])
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		pa, pb := packages[order[a]], packages[order[b]]
+		if scores[order[a]] != scores[order[b]] {
+			return scores[order[a]] > scores[order[b]]
+		}
+		if pa.LineCount != pb.LineCount {
+			return pa.LineCount > pb.LineCount
+		}
+		return pa.RelativePath < pb.RelativePath
+	})
+
+	remaining := opts.DetailBudget
+	for _, i := range order {
+		pkg := &packages[i]
+		files := pkg.Files
+		if len(files) == 0 {
+			files = pkg.KeyFiles
+		}
+		if len(files) == 0 {
+			continue
+		}
+		if cost := fileDetailTokens(files); scores[i] > 0 && cost <= remaining {
+			remaining -= cost
+			if len(pkg.Files) == 0 {
+				pkg.Files = append([]File(nil), files...)
+				sort.Slice(pkg.Files, func(a, b int) bool { return pkg.Files[a].Name < pkg.Files[b].Name })
+			}
+			pkg.KeyFiles = nil
+			continue
+		}
+		pkg.Files = nil
+		if opts.SplitOutputDir == "" {
+			pkg.KeyFiles = keyFiles(files, pkg.EntryPoint, opts.JSONMinFiles)
+		} else {
+			pkg.KeyFiles = keyFiles(files, pkg.EntryPoint, len(files))
+		}
+	}
+}
+
+// fileDetailTokens estimates the tokens a file listing adds to the outputs.
+func fileDetailTokens(files []File) int {
+	size := 0
+	for _, file := range files {
+		size += len(file.Name) + len(file.Purpose) + len(strings.Join(file.KeyTypes, ", ")) + len(strings.Join(file.KeyFuncs, ", ")) + 8
+	}
+	return estimateTokens(size)
+}
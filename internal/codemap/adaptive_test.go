@@ -0,0 +1,51 @@
+package codemap
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDetailBudgetListsFilesOfImportantPackages(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example.com/app\n\ngo 1.22\n",
+		"core/core.go":   "package core\n\ntype Engine struct{}\n",
+		"core/store.go":  "package core\n\ntype Store struct{}\n",
+		"api/api.go":     "package api\n\nimport _ \"example.com/app/core\"\n",
+		"cli/cli.go":     "package cli\n\nimport _ \"example.com/app/core\"\n",
+		"leaf/a.go":      "package leaf\n",
+		"leaf/b.go":      "package leaf\n",
+		"leaf/c.go":      "package leaf\n",
+		"leaf/helper.go": "package leaf\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	listed := func(budget int) map[string][]string {
+		opts := DefaultOptions()
+		opts.ProjectRoot = tmpDir
+		opts.LargePackageFiles = 4
+		opts.DetailBudget = budget
+		cm, err := Generate(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Generate returned error: %v", err)
+		}
+		names := make(map[string][]string)
+		for _, pkg := range cm.Packages {
+			for _, file := range pkg.Files {
+				names[pkg.RelativePath] = append(names[pkg.RelativePath], file.Name)
+			}
+		}
+		return names
+	}
+
+	if got, want := listed(0), map[string][]string{"leaf": {"a.go", "b.go", "c.go", "helper.go"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("without a budget expected large packages listed, got %v", got)
+	}
+	if got, want := listed(1000), map[string][]string{"core": {"core.go", "store.go"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected only the imported package listed, got %v", got)
+	}
+	if got := listed(1); len(got) != 0 {
+		t.Fatalf("expected nothing listed within a tiny budget, got %v", got)
+	}
+}
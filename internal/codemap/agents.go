@@ -0,0 +1,210 @@
+package codemap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Markers delimiting the managed agent-instructions block inside files such
+// as AGENTS.md or CLAUDE.md. Text outside the markers is never touched.
+const (
+	agentsBlockStart = "<!-- codemap:agents:start -->"
+	agentsBlockEnd   = "<!-- codemap:agents:end -->"
+)
+
+const (
+	agentsTopPackages   = 10
+	agentsPurposeLength = 80
+)
+
+// AgentsRenderer renders a short "how to navigate this repo" fragment meant
+// for inclusion in agent instruction files.
+type AgentsRenderer struct{}
+
+func (AgentsRenderer) Name() string        { return "agents" }
+func (AgentsRenderer) DefaultPath() string { return "CODEMAP.agents.md" }
+func (AgentsRenderer) Render(cm *Codemap) (string, error) {
+	return renderAgents(cm), nil
+}
+
+func renderAgents(cm *Codemap) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<!-- codemap-hash: %s -->\n", cm.ContentHash)
+	sb.WriteString("## Navigating This Repo\n\n")
+	sb.WriteString("`CODEMAP.paths` lists every package with a suggested entry file, one per line; `CODEMAP.md` has the details. Read those before searching the tree.\n")
+
+	if top := agentsTopPackageList(cm.Packages); len(top) > 0 {
+		sb.WriteString("\n### Key Packages\n\n")
+		for _, pkg := range top {
+			fmt.Fprintf(&sb, "- `%s` (%s", pkg.RelativePath, pkg.Language)
+			if entry := entryPath(pkg); entry != "" {
+				fmt.Fprintf(&sb, "; start at `%s`", entry)
+			}
+			sb.WriteString(")")
+			if purpose := strings.TrimSpace(pkg.Purpose); purpose != "" {
+				sb.WriteString(": ")
+				sb.WriteString(truncate(purpose, agentsPurposeLength))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	sb.WriteString("\n### Commands\n\n")
+	for _, command := range agentCommands(cm) {
+		fmt.Fprintf(&sb, "- %s\n", command)
+	}
+
+	sb.WriteString("\n### Conventions\n\n")
+	sb.WriteString("- Each package names one entry file; read it first, then follow its imports.\n")
+	sb.WriteString("- Do not edit codemap outputs by hand; regenerate them instead.\n")
+	return sb.String()
+}
+
+// agentsTopPackageList picks the largest packages by line count, keeping
+// the deterministic path order for ties.
+func agentsTopPackageList(packages []Package) []Package {
+	top := append([]Package(nil), packages...)
+	sort.SliceStable(top, func(i, j int) bool { return top[i].LineCount > top[j].LineCount })
+	if len(top) > agentsTopPackages {
+		top = top[:agentsTopPackages]
+	}
+	sort.SliceStable(top, func(i, j int) bool { return top[i].RelativePath < top[j].RelativePath })
+	return top
+}
+
+// agentCommands suggests build and test commands for the toolchains whose
+// manifests sit at the project root.
+func agentCommands(cm *Codemap) []string {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(cm.ProjectRoot, name))
+		return err == nil
+	}
+	var commands []string
+	if exists("go.mod") {
+		commands = append(commands, "Build: `go build ./...`", "Test: `go test ./...`")
+	}
+	if exists("Cargo.toml") {
+		commands = append(commands, "Build: `cargo build`", "Test: `cargo test`")
+	}
+	if exists("package.json") {
+		commands = append(commands, "Test: `npm test`")
+	}
+	if exists("pyproject.toml") || exists("setup.py") {
+		commands = append(commands, "Test: `python -m pytest`")
+	}
+	if exists("Makefile") {
+		commands = append(commands, "Make targets: see `Makefile`")
+	}
+	return append(commands, "Refresh codemap outputs: `codemap`")
+}
+
+// writeAgentsOutputs writes the standalone fragment and injects it into the
+// managed block of every file in opts.AgentsInjectPaths.
+func writeAgentsOutputs(root string, opts Options, cm *Codemap) error {
+	if opts.AgentsOutputPath != "" {
+		if err := writeRenderedOutput(outputAbsPath(root, opts.AgentsOutputPath), AgentsRenderer{}, cm); err != nil {
+			return err
+		}
+	}
+	if len(opts.AgentsInjectPaths) == 0 {
+		return nil
+	}
+	fragment := renderAgents(cm)
+	for _, name := range opts.AgentsInjectPaths {
+		if err := injectManagedBlock(outputAbsPath(root, name), fragment); err != nil {
+			return fmt.Errorf("inject agents block into %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// injectManagedBlock replaces the managed block of path with content, or
+// appends a new block when the file has none. Missing files are created.
+// Files with a lone, repeated or misordered marker are left untouched and
+// reported, since guessing where the block ends could delete hand-written
+// text.
+func injectManagedBlock(path, content string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	existing := string(data)
+	block := agentsBlockStart + "\n" + content + agentsBlockEnd + "\n"
+
+	var updated string
+	start := strings.Index(existing, agentsBlockStart)
+	end := strings.Index(existing, agentsBlockEnd)
+	starts := strings.Count(existing, agentsBlockStart)
+	ends := strings.Count(existing, agentsBlockEnd)
+	if starts != ends || starts > 1 || end < start {
+		return fmt.Errorf("unbalanced managed block markers: found %d %s and %d %s", starts, agentsBlockStart, ends, agentsBlockEnd)
+	}
+	switch {
+	case start >= 0:
+		rest := strings.TrimPrefix(existing[end+len(agentsBlockEnd):], "\n")
+		updated = existing[:start] + block + rest
+	case existing == "":
+		updated = block
+	default:
+		updated = strings.TrimRight(existing, "\n") + "\n\n" + block
+	}
+	if updated == existing {
+		return nil
+	}
+	return os.WriteFile(path, []byte(updated), 0644)
+}
+
+// readManagedBlockHash returns the codemap-hash recorded inside the managed
+// block of path, or "" when the file or block is missing.
+func readManagedBlockHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := newHeaderScanner(f)
+	inBlock := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == agentsBlockStart:
+			inBlock = true
+		case line == agentsBlockEnd:
+			return "", nil
+		case inBlock:
+			return parseHashLine(line), nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// agentsOutputsStale reports whether an enabled agents fragment or managed
+// block is missing or was written for a different content hash.
+func agentsOutputsStale(root string, opts Options, existingHash string) (bool, error) {
+	if opts.AgentsOutputPath != "" {
+		hash, err := readExistingHash(outputAbsPath(root, opts.AgentsOutputPath), opts.HashScanLines)
+		if err != nil {
+			return false, fmt.Errorf("read existing agents hash: %w", err)
+		}
+		if hash == "" || hash != existingHash {
+			return true, nil
+		}
+	}
+	for _, name := range opts.AgentsInjectPaths {
+		hash, err := readManagedBlockHash(outputAbsPath(root, name))
+		if err != nil {
+			return false, fmt.Errorf("read agents block of %s: %w", name, err)
+		}
+		if hash == "" || hash != existingHash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
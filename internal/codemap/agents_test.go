@@ -0,0 +1,95 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInjectManagedBlockPreservesSurroundingText(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "AGENTS.md")
+	original := "# Agents\n\nBefore.\n\n" + agentsBlockStart + "\nold\n" + agentsBlockEnd + "\nAfter.\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := injectManagedBlock(path, "new\n"); err != nil {
+		t.Fatalf("injectManagedBlock returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Agents\n\nBefore.\n\n" + agentsBlockStart + "\nnew\n" + agentsBlockEnd + "\nAfter.\n"
+	if string(data) != want {
+		t.Fatalf("unexpected content:\n%s", data)
+	}
+}
+
+func TestInjectManagedBlockRejectsUnbalancedMarkers(t *testing.T) {
+	for _, original := range []string{
+		"Before.\n" + agentsBlockStart + "\nold\n",
+		"Before.\n" + agentsBlockEnd + "\nAfter.\n",
+		agentsBlockEnd + "\nold\n" + agentsBlockStart + "\n",
+		agentsBlockStart + "\na\n" + agentsBlockEnd + "\n" + agentsBlockStart + "\nb\n" + agentsBlockEnd + "\n",
+	} {
+		path := filepath.Join(t.TempDir(), "AGENTS.md")
+		if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := injectManagedBlock(path, "new\n"); err == nil {
+			t.Fatalf("expected an error for markers in %q", original)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != original {
+			t.Fatalf("expected %q to be left untouched, got %q", original, data)
+		}
+	}
+}
+
+func TestAgentsInjectionTracksStaleness(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("// Command app serves requests.\npackage main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	agentsPath := filepath.Join(tmpDir, "AGENTS.md")
+	if err := os.WriteFile(agentsPath, []byte("# Agents\n\nKeep me.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	if _, _, err := EnsureUpToDate(context.Background(), opts); err != nil {
+		t.Fatalf("EnsureUpToDate returned error: %v", err)
+	}
+
+	opts.AgentsInjectPaths = []string{"AGENTS.md"}
+	if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+		t.Fatalf("expected missing agents block to be stale, got %v (err %v)", stale, err)
+	}
+	cm, generated, err := EnsureUpToDate(context.Background(), opts)
+	if err != nil || !generated {
+		t.Fatalf("expected regeneration, got %v (err %v)", generated, err)
+	}
+
+	data, err := os.ReadFile(agentsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	for _, want := range []string{"Keep me.", agentsBlockStart, "codemap-hash: " + cm.ContentHash, "- `.` (go; start at `main.go`): Command app serves requests.", "`go test ./...`", agentsBlockEnd} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("expected AGENTS.md to contain %q:\n%s", want, content)
+		}
+	}
+	if stale, err := IsStale(context.Background(), opts); err != nil || stale {
+		t.Fatalf("expected fresh outputs, got %v (err %v)", stale, err)
+	}
+}
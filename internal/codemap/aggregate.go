@@ -0,0 +1,246 @@
+package codemap
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// repoManifests holds the module names a repository publishes and the module
+// names it requires, gathered from go.mod and package.json files.
+type repoManifests struct {
+	provides []string
+	requires map[string]struct{}
+}
+
+// Aggregate analyzes several repositories and merges them into a single
+// cross-repo codemap. Package paths are prefixed with the repository name and
+// Repos records dependency hints derived from go.mod/package.json references.
+func Aggregate(ctx context.Context, roots []string, opts Options) (*Codemap, error) {
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("aggregate: no repositories given")
+	}
+
+	merged := &Codemap{GeneratedAt: generationTime()}
+	manifests := make([]repoManifests, 0, len(roots))
+	concernIndex := make(map[string]int)
+	usedNames := make(map[string]int, len(roots))
+	hasher := sha256.New()
+
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("resolve root %s: %w", root, err)
+		}
+		name := filepath.Base(abs)
+		usedNames[name]++
+		if n := usedNames[name]; n > 1 {
+			name = fmt.Sprintf("%s-%d", name, n)
+		}
+
+		repoOpts := opts
+		repoOpts.ProjectRoot = abs
+		cm, err := Analyze(ctx, repoOpts)
+		if err != nil {
+			return nil, fmt.Errorf("analyze %s: %w", name, err)
+		}
+		hash, err := ComputeHash(ctx, abs)
+		if err != nil {
+			return nil, fmt.Errorf("hash %s: %w", name, err)
+		}
+		fmt.Fprintf(hasher, "%s\t%s\n", name, hash)
+
+		for _, pkg := range cm.Packages {
+			pkg.RelativePath = path.Join(name, pkg.RelativePath)
+			pkg.ID = PackageID(pkg.Language, pkg.RelativePath)
+			merged.Packages = append(merged.Packages, pkg)
+		}
+		for _, concern := range cm.Concerns {
+			for i, file := range concern.Files {
+				concern.Files[i] = path.Join(name, file)
+			}
+			if i, ok := concernIndex[concern.Name]; ok {
+				merged.Concerns[i].Files = append(merged.Concerns[i].Files, concern.Files...)
+				merged.Concerns[i].TotalFiles += concern.TotalFiles
+				continue
+			}
+			concernIndex[concern.Name] = len(merged.Concerns)
+			merged.Concerns = append(merged.Concerns, concern)
+		}
+		for _, warning := range cm.Warnings {
+			merged.Warnings = append(merged.Warnings, name+": "+warning)
+		}
+
+		repoManifest, err := collectRepoManifests(ctx, abs)
+		if err != nil {
+			return nil, fmt.Errorf("read manifests for %s: %w", name, err)
+		}
+		manifests = append(manifests, repoManifest)
+		merged.Repos = append(merged.Repos, RepoInfo{
+			Name:         name,
+			ModulePaths:  repoManifest.provides,
+			ContentHash:  hash,
+			PackageCount: len(cm.Packages),
+		})
+	}
+
+	for i := range merged.Repos {
+		merged.Repos[i].DependsOn = crossRepoDependencies(i, merged.Repos, manifests)
+	}
+	sortPackages(merged.Packages)
+	merged.ContentHash = hex.EncodeToString(hasher.Sum(nil))
+	return merged, nil
+}
+
+// crossRepoDependencies returns the names of other repos whose published
+// modules are required by repo i.
+func crossRepoDependencies(i int, repos []RepoInfo, manifests []repoManifests) []string {
+	var deps []string
+	for j, other := range repos {
+		if j == i {
+			continue
+		}
+		if requiresAnyModule(manifests[i].requires, other.ModulePaths) {
+			deps = append(deps, other.Name)
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+func requiresAnyModule(requires map[string]struct{}, modules []string) bool {
+	for req := range requires {
+		for _, mod := range modules {
+			if req == mod || strings.HasPrefix(req, mod+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// collectRepoManifests scans go.mod and package.json files under root.
+func collectRepoManifests(ctx context.Context, root string) (repoManifests, error) {
+	manifests := repoManifests{requires: make(map[string]struct{})}
+	provided := make(map[string]struct{})
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && isExcludedDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		var provides string
+		var requires []string
+		switch d.Name() {
+		case "go.mod":
+			provides, requires = parseGoModManifest(p)
+		case "package.json":
+			provides, requires = parsePackageJSONManifest(p)
+		default:
+			return nil
+		}
+		if provides != "" {
+			provided[provides] = struct{}{}
+		}
+		for _, req := range requires {
+			manifests.requires[req] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return manifests, err
+	}
+
+	for mod := range provided {
+		manifests.provides = append(manifests.provides, mod)
+		delete(manifests.requires, mod)
+	}
+	sort.Strings(manifests.provides)
+	return manifests, nil
+}
+
+func parseGoModManifest(modPath string) (string, []string) {
+	f, err := os.Open(modPath)
+	if err != nil {
+		return "", nil
+	}
+	defer f.Close()
+
+	var module string
+	var requires []string
+	inRequire := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if idx := strings.Index(line, "//"); idx >= 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "module "):
+			module = strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), `"`)
+		case line == "require (":
+			inRequire = true
+		case inRequire && line == ")":
+			inRequire = false
+		case inRequire:
+			if fields := strings.Fields(line); len(fields) > 0 {
+				requires = append(requires, fields[0])
+			}
+		case strings.HasPrefix(line, "require "):
+			if fields := strings.Fields(strings.TrimPrefix(line, "require ")); len(fields) > 0 {
+				requires = append(requires, fields[0])
+			}
+		}
+	}
+	return module, requires
+}
+
+func parsePackageJSONManifest(manifestPath string) (string, []string) {
+	content, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return "", nil
+	}
+	var manifest struct {
+		Name                 string            `json:"name"`
+		Dependencies         map[string]string `json:"dependencies"`
+		DevDependencies      map[string]string `json:"devDependencies"`
+		PeerDependencies     map[string]string `json:"peerDependencies"`
+		OptionalDependencies map[string]string `json:"optionalDependencies"`
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return "", nil
+	}
+
+	var requires []string
+	for _, deps := range []map[string]string{
+		manifest.Dependencies,
+		manifest.DevDependencies,
+		manifest.PeerDependencies,
+		manifest.OptionalDependencies,
+	} {
+		for dep := range deps {
+			requires = append(requires, dep)
+		}
+	}
+	return strings.TrimSpace(manifest.Name), requires
+}
@@ -0,0 +1,67 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAggregateMergesReposWithDependencyHints(t *testing.T) {
+	tmpDir := t.TempDir()
+	libDir := filepath.Join(tmpDir, "lib")
+	appDir := filepath.Join(tmpDir, "app")
+	for _, dir := range []string{filepath.Join(libDir, "store"), appDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+
+	files := map[string]string{
+		filepath.Join(libDir, "go.mod"):            "module example.com/lib\n\ngo 1.22\n",
+		filepath.Join(libDir, "store", "store.go"): "// Package store persists records.\npackage store\n\nfunc Open() {}\n",
+		filepath.Join(appDir, "go.mod"):            "module example.com/app\n\ngo 1.22\n\nrequire (\n\texample.com/lib v0.1.0 // indirect\n\texample.com/other v1.0.0\n)\n",
+		filepath.Join(appDir, "main.go"):           "package main\n\nfunc main() {}\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+
+	cm, err := Aggregate(context.Background(), []string{appDir, libDir}, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Aggregate returned error: %v", err)
+	}
+
+	var paths []string
+	for _, pkg := range cm.Packages {
+		paths = append(paths, pkg.RelativePath)
+	}
+	if want := []string{"app", "lib/store"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("unexpected package paths: got %v want %v", paths, want)
+	}
+
+	if len(cm.Repos) != 2 {
+		t.Fatalf("expected 2 repos, got %+v", cm.Repos)
+	}
+	if cm.Repos[0].Name != "app" || !reflect.DeepEqual(cm.Repos[0].DependsOn, []string{"lib"}) {
+		t.Fatalf("expected app to depend on lib, got %+v", cm.Repos[0])
+	}
+	if len(cm.Repos[1].DependsOn) != 0 || !reflect.DeepEqual(cm.Repos[1].ModulePaths, []string{"example.com/lib"}) {
+		t.Fatalf("unexpected lib repo info: %+v", cm.Repos[1])
+	}
+	if cm.ContentHash == "" {
+		t.Fatal("expected aggregate content hash")
+	}
+
+	content, err := Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if !strings.Contains(content, "## Repositories") || !strings.Contains(content, "| app | 1 | lib |") {
+		t.Fatalf("expected repositories section in output:\n%s", content)
+	}
+}
@@ -0,0 +1,162 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// AnalysisDiff compares the current analysis of a project with an earlier
+// one. Each list is sorted by package path, then language.
+type AnalysisDiff struct {
+	Added   []PackageDelta `json:"added,omitempty"`
+	Removed []PackageDelta `json:"removed,omitempty"`
+	Changed []PackageDelta `json:"changed,omitempty"`
+}
+
+// Empty reports whether the two analyses agree on every package.
+func (d *AnalysisDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// PackageDelta describes how one package differs between two analyses.
+// Removed packages report their earlier entry file and line count.
+type PackageDelta struct {
+	Package        string   `json:"package"`
+	Language       string   `json:"language"`
+	EntryPoint     string   `json:"entryPoint,omitempty"` // Relative to the project root
+	EntryChanged   bool     `json:"entryChanged,omitempty"`
+	PrevEntryPoint string   `json:"prevEntryPoint,omitempty"` // Earlier entry file when EntryChanged
+	LineCount      int      `json:"lineCount"`
+	LineDelta      int      `json:"lineDelta"`
+	NewTypes       []string `json:"newTypes,omitempty"` // Exported types and functions
+	RemovedTypes   []string `json:"removedTypes,omitempty"`
+}
+
+// DiffAnalysis analyzes the project like Search, reusing cached results, and
+// compares it with the analysis cache of an earlier run: basePath names a
+// state file or its analysis cache, and "" selects the cache of the current
+// state, so the diff covers changes made since the last generation. Nothing
+// is written. Exported types are not compared against caches written with
+// Options.LowMemory, which do not keep them.
+func DiffAnalysis(ctx context.Context, opts Options, basePath string) (*AnalysisDiff, error) {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	base, err := readDiffBase(root, opts, basePath)
+	if err != nil {
+		return nil, err
+	}
+	cm, _, err := analyzeCached(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make(map[string]Package, len(base.Packages))
+	for _, cached := range base.Packages {
+		pkg := cached.Package
+		previous[PackageID(pkg.Language, pkg.RelativePath)] = pkg
+	}
+
+	diff := &AnalysisDiff{}
+	for _, pkg := range cm.Packages {
+		if isNestedCodemap(pkg) {
+			continue
+		}
+		id := PackageID(pkg.Language, pkg.RelativePath)
+		prev, ok := previous[id]
+		if !ok {
+			diff.Added = append(diff.Added, PackageDelta{
+				Package:    pkg.RelativePath,
+				Language:   pkg.Language,
+				EntryPoint: entryPath(pkg),
+				LineCount:  pkg.LineCount,
+				LineDelta:  pkg.LineCount,
+				NewTypes:   typeNames(pkg.ExportedTypes, nil),
+			})
+			continue
+		}
+		delete(previous, id)
+
+		delta := PackageDelta{
+			Package:    pkg.RelativePath,
+			Language:   pkg.Language,
+			EntryPoint: entryPath(pkg),
+			LineCount:  pkg.LineCount,
+			LineDelta:  pkg.LineCount - prev.LineCount,
+		}
+		if prevEntry := entryPath(prev); prevEntry != delta.EntryPoint {
+			delta.EntryChanged, delta.PrevEntryPoint = true, prevEntry
+		}
+		if !base.LowMemory {
+			delta.NewTypes = typeNames(pkg.ExportedTypes, prev.ExportedTypes)
+			delta.RemovedTypes = typeNames(prev.ExportedTypes, pkg.ExportedTypes)
+		}
+		if delta.LineDelta != 0 || delta.EntryChanged || len(delta.NewTypes) > 0 || len(delta.RemovedTypes) > 0 {
+			diff.Changed = append(diff.Changed, delta)
+		}
+	}
+	for _, prev := range previous {
+		diff.Removed = append(diff.Removed, PackageDelta{
+			Package:    prev.RelativePath,
+			Language:   prev.Language,
+			EntryPoint: entryPath(prev),
+			LineCount:  prev.LineCount,
+			LineDelta:  -prev.LineCount,
+		})
+	}
+
+	for _, deltas := range [][]PackageDelta{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(deltas, func(i, j int) bool {
+			if deltas[i].Package != deltas[j].Package {
+				return deltas[i].Package < deltas[j].Package
+			}
+			return deltas[i].Language < deltas[j].Language
+		})
+	}
+	return diff, nil
+}
+
+// readDiffBase reads the analysis cache at basePath, or the one belonging to
+// the state file there. An empty basePath selects the current state's cache.
+func readDiffBase(root string, opts Options, basePath string) (*AnalysisCache, error) {
+	cachePath := resolveAnalysisStatePath(root, opts)
+	if basePath != "" {
+		cache, err := readAnalysisCache(basePath)
+		if err != nil {
+			return nil, fmt.Errorf("read base analysis: %w", err)
+		}
+		if cache != nil {
+			return cache, nil
+		}
+		cachePath = analysisStatePathFor(basePath)
+	}
+	cache, err := readAnalysisCache(cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("read base analysis: %w", err)
+	}
+	if cache == nil && basePath != "" {
+		return nil, fmt.Errorf("%w at %s or %s", ErrNoAnalysisCache, basePath, cachePath)
+	}
+	if cache == nil {
+		return nil, fmt.Errorf("%w at %s; run codemap first", ErrNoAnalysisCache, cachePath)
+	}
+	return cache, nil
+}
+
+// typeNames returns the sorted names in types that are missing from except.
+func typeNames(types, except []TypeInfo) []string {
+	skip := make(map[string]struct{}, len(except))
+	for _, typ := range except {
+		skip[typ.Name] = struct{}{}
+	}
+	seen := make(map[string]struct{})
+	for _, typ := range types {
+		if _, ok := skip[typ.Name]; !ok {
+			seen[typ.Name] = struct{}{}
+		}
+	}
+	return sortedImportSet(seen)
+}
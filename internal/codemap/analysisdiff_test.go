@@ -0,0 +1,79 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffAnalysisReportsPackageChanges(t *testing.T) {
+	tmpDir := t.TempDir()
+	write := func(files map[string]string) {
+		writeTestTree(t, tmpDir, files)
+	}
+	write(map[string]string{
+		"go.mod":              "module example.com/app\n\ngo 1.22\n",
+		"store/legacy.go":     "package store\n\ntype Legacy struct{}\n",
+		"old/old.go":          "package old\n\nfunc Old() {}\n",
+		"unchanged/stable.go": "package unchanged\n\nfunc Stable() {}\n",
+	})
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	statePath := filepath.Join(tmpDir, ".codemap.state.json")
+	basePath := filepath.Join(t.TempDir(), "base.json")
+	data, err := os.ReadFile(analysisStatePathFor(statePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(analysisStatePathFor(basePath), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.RemoveAll(filepath.Join(tmpDir, "old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(tmpDir, "store", "legacy.go")); err != nil {
+		t.Fatal(err)
+	}
+	write(map[string]string{
+		"store/store.go": "package store\n\ntype Store struct{}\n\ntype Options struct{}\n",
+		"api/api.go":     "package api\n\ntype Handler struct{}\n",
+	})
+
+	diff, err := DiffAnalysis(context.Background(), opts, "")
+	if err != nil {
+		t.Fatalf("DiffAnalysis returned error: %v", err)
+	}
+	want := &AnalysisDiff{
+		Added:   []PackageDelta{{Package: "api", Language: "go", EntryPoint: "api/api.go", LineCount: 3, LineDelta: 3, NewTypes: []string{"Handler"}}},
+		Removed: []PackageDelta{{Package: "old", Language: "go", EntryPoint: "old/old.go", LineCount: 3, LineDelta: -3}},
+		Changed: []PackageDelta{{
+			Package:        "store",
+			Language:       "go",
+			EntryPoint:     "store/store.go",
+			EntryChanged:   true,
+			PrevEntryPoint: "store/legacy.go",
+			LineCount:      5,
+			LineDelta:      2,
+			NewTypes:       []string{"Options", "Store"},
+			RemovedTypes:   []string{"Legacy"},
+		}},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Fatalf("unexpected diff:\n got %+v\nwant %+v", diff, want)
+	}
+
+	fromState, err := DiffAnalysis(context.Background(), opts, basePath)
+	if err != nil {
+		t.Fatalf("DiffAnalysis with a base state returned error: %v", err)
+	}
+	if !reflect.DeepEqual(fromState, want) {
+		t.Fatalf("unexpected diff against the saved state:\n got %+v\nwant %+v", fromState, want)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"go/ast"
 	"go/parser"
+	"go/printer"
 	"go/token"
 	"os"
 	"path"
@@ -16,11 +17,12 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // Analyze walks the project and extracts package information.
 func Analyze(ctx context.Context, opts Options) (*Codemap, error) {
-	idx, err := BuildFileIndex(ctx, opts.ProjectRoot)
+	idx, err := buildIndex(ctx, opts.ProjectRoot, opts)
 	if err != nil {
 		return nil, fmt.Errorf("build file index: %w", err)
 	}
@@ -29,7 +31,7 @@ func Analyze(ctx context.Context, opts Options) (*Codemap, error) {
 		Root:    idx.Root,
 		Index:   idx,
 		Options: opts,
-	}, DefaultAnalyzerRegistry())
+	}, analyzerRegistryFor(opts))
 }
 
 func analyzeGoWithIndex(ctx context.Context, root string, idx *FileIndex, opts Options, prevState, nextState *CodemapState) (*Codemap, error) {
@@ -45,11 +47,13 @@ func analyzeGoWithIndex(ctx context.Context, root string, idx *FileIndex, opts O
 		if cached, ok := cachedByRel[plan.RelativePath]; ok && plan.Fingerprint != "" && cached.Fingerprint == plan.Fingerprint {
 			pkg := cached.Package
 			packageResults[i] = &pkg
+			recordCacheHit(ctx, opts, languageGo, plan.RelativePath)
 			continue
 		}
 		jobs = append(jobs, analysisJob{
-			index: i,
-			dir:   plan.DirAbsPath,
+			index:   i,
+			dir:     plan.DirAbsPath,
+			relPath: plan.RelativePath,
 		})
 	}
 
@@ -64,17 +68,11 @@ func analyzeGoWithIndex(ctx context.Context, root string, idx *FileIndex, opts O
 		}
 	}
 
-	concerns, err := buildConcerns(idx, opts.Concerns, opts.ConcernExampleLimit)
-	if err != nil {
-		return nil, fmt.Errorf("build concerns: %w", err)
-	}
-
-	updateAnalysisCache(nextState, opts, modulePath, plans, packageResults)
+	updateAnalysisCache(nextState, opts, modulePath, languageGo, plans, packageResults)
 
 	return &Codemap{
 		ProjectRoot: root,
 		Packages:    packages,
-		Concerns:    concerns,
 	}, nil
 }
 
@@ -87,7 +85,7 @@ func findPackageDirsFromIndex(idx *FileIndex, includeTests bool) []string {
 	return dirs
 }
 
-func analyzePackage(fset *token.FileSet, root, dir, modulePath string, opts Options) (*Package, error) {
+func analyzePackage(ctx context.Context, fset *token.FileSet, root, dir, modulePath string, opts Options) (*Package, error) {
 	mode := parser.ParseComments | parser.SkipObjectResolution
 	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
 		name := fi.Name()
@@ -115,6 +113,9 @@ func analyzePackage(fset *token.FileSet, root, dir, modulePath string, opts Opti
 	sort.Strings(pkgNames)
 	pkgName := pkgNames[0]
 	pkgAST := pkgs[pkgName]
+	// External test packages (package foo_test) are only parsed with
+	// IncludeTests; their files are attributed to foo as test-only.
+	xtestAST := pkgs[pkgName+"_test"]
 
 	relPath, err := filepath.Rel(root, dir)
 	if err != nil {
@@ -134,9 +135,14 @@ func analyzePackage(fset *token.FileSet, root, dir, modulePath string, opts Opti
 	files := make([]File, 0, len(pkgAST.Files))
 	var totalLines int
 	allTypes := make([]TypeInfo, 0, len(pkgAST.Files))
+	var funcs []FuncInfo
+	var privateSymbols []TypeInfo
+	receiverMethods := make(map[string][]string)
 	internalImports := make([]string, 0, len(pkgAST.Files))
 	importsSeen := make(map[string]struct{}, len(pkgAST.Files))
+	externalSeen := make(map[string]struct{})
 	var purpose string
+	var tags []string
 	entryPoint := ""
 	entryScore := -1
 
@@ -144,10 +150,18 @@ func analyzePackage(fset *token.FileSet, root, dir, modulePath string, opts Opti
 	for filename := range pkgAST.Files {
 		filenames = append(filenames, filename)
 	}
+	if xtestAST != nil {
+		for filename := range xtestAST.Files {
+			filenames = append(filenames, filename)
+		}
+	}
 	sort.Strings(filenames)
 
 	for _, filename := range filenames {
-		file := pkgAST.Files[filename]
+		file, testOnly := pkgAST.Files[filename], false
+		if file == nil {
+			file, testOnly = xtestAST.Files[filename], true
+		}
 		basename := filepath.Base(filename)
 
 		lineCount := fset.Position(file.End()).Line
@@ -155,17 +169,29 @@ func analyzePackage(fset *token.FileSet, root, dir, modulePath string, opts Opti
 			lineCount = 0
 		}
 		totalLines += lineCount
+		recordFileParsed(ctx, filepath.ToSlash(filepath.Join(relPath, basename)), lineCount)
 
 		fileDoc := ""
 		if file.Doc != nil {
 			fileDoc = strings.TrimSpace(file.Doc.Text())
 		}
 		filePurpose := extractFirstSentence(fileDoc)
-
-		if basename == "doc.go" && file.Doc != nil {
-			purpose = extractFirstSentence(file.Doc.Text())
-		} else if purpose == "" && file.Doc != nil {
-			purpose = extractFirstSentence(file.Doc.Text())
+		hasPurpose := file.Doc != nil
+		if opts.PurposeExtractors[languageGo] != nil {
+			fileRelPath := filepath.ToSlash(filepath.Join(relPath, basename))
+			content, err := os.ReadFile(filename)
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", fileRelPath, err)
+			}
+			if custom, ok := customFilePurpose(opts, languageGo, fileRelPath, content); ok {
+				filePurpose, hasPurpose = custom, true
+			}
+		}
+		if !testOnly {
+			tags = mergeCodemapTags(tags, extractGoDocTags(file.Doc))
+			if hasPurpose && (basename == "doc.go" || purpose == "") {
+				purpose = filePurpose
+			}
 		}
 
 		for _, impSpec := range file.Imports {
@@ -173,7 +199,14 @@ func analyzePackage(fset *token.FileSet, root, dir, modulePath string, opts Opti
 				continue
 			}
 			imp := strings.Trim(impSpec.Path.Value, `"`)
-			if _, seen := importsSeen[imp]; isInternalImport(imp, modulePath) && !seen {
+			if testOnly && imp == importPath {
+				continue
+			}
+			if !isInternalImport(imp, modulePath) {
+				externalSeen[imp] = struct{}{}
+				continue
+			}
+			if _, seen := importsSeen[imp]; !seen {
 				importsSeen[imp] = struct{}{}
 				internalImports = append(internalImports, imp)
 			}
@@ -186,7 +219,7 @@ func analyzePackage(fset *token.FileSet, root, dir, modulePath string, opts Opti
 			case *ast.GenDecl:
 				for _, spec := range d.Specs {
 					t, ok := spec.(*ast.TypeSpec)
-					if !ok || !t.Name.IsExported() {
+					if !ok {
 						continue
 					}
 					kind := "type"
@@ -200,27 +233,80 @@ func analyzePackage(fset *token.FileSet, root, dir, modulePath string, opts Opti
 					if d.Doc != nil {
 						comment = extractFirstSentence(d.Doc.Text())
 					}
+					methods, embeds := goTypeMembers(t)
+					if !t.Name.IsExported() {
+						// Unexported types are recorded regardless of
+						// Options.IncludeUnexported: their methods promote
+						// into exported types for assignImplementations.
+						privateSymbols = append(privateSymbols, TypeInfo{
+							Name:       t.Name.Name,
+							Kind:       kind,
+							Comment:    comment,
+							IsTestOnly: testOnly,
+							IsPrivate:  true,
+							Methods:    methods,
+							Embeds:     embeds,
+						})
+						continue
+					}
 					allTypes = append(allTypes, TypeInfo{
-						Name:    t.Name.Name,
-						Kind:    kind,
-						Comment: comment,
+						Name:       t.Name.Name,
+						Kind:       kind,
+						Comment:    comment,
+						IsTestOnly: testOnly,
+						Methods:    methods,
+						Embeds:     embeds,
 					})
 					keyTypes = append(keyTypes, t.Name.Name)
 				}
 			case *ast.FuncDecl:
-				if d.Name.IsExported() && d.Recv == nil {
+				if d.Recv != nil {
+					if recv := goReceiverName(d.Recv); recv != "" && !testOnly {
+						receiverMethods[recv] = append(receiverMethods[recv], goMethodSignature(d.Name.Name, d.Type))
+					}
+					continue
+				}
+				if d.Name.IsExported() {
 					keyFuncs = append(keyFuncs, d.Name.Name)
+					if !testOnly {
+						comment := ""
+						if d.Doc != nil {
+							comment = extractFirstSentence(d.Doc.Text())
+						}
+						funcs = append(funcs, FuncInfo{
+							Name:      d.Name.Name,
+							Signature: goFuncSignature(d),
+							Comment:   comment,
+							File:      basename,
+						})
+					}
+				} else if opts.IncludeUnexported {
+					comment := ""
+					if d.Doc != nil {
+						comment = extractFirstSentence(d.Doc.Text())
+					}
+					privateSymbols = append(privateSymbols, TypeInfo{
+						Name:       d.Name.Name,
+						Kind:       "func",
+						Comment:    comment,
+						IsTestOnly: testOnly,
+						IsPrivate:  true,
+					})
 				}
 			}
 		}
 
 		files = append(files, File{
-			Name:      basename,
-			LineCount: lineCount,
-			Purpose:   filePurpose,
-			KeyTypes:  keyTypes,
-			KeyFuncs:  keyFuncs,
+			Name:       basename,
+			LineCount:  lineCount,
+			Purpose:    filePurpose,
+			KeyTypes:   keyTypes,
+			KeyFuncs:   keyFuncs,
+			IsTestOnly: testOnly,
 		})
+		if testOnly {
+			continue
+		}
 
 		score := scoreEntryPoint(basename, pkgName, keyTypes, keyFuncs)
 		if score > entryScore {
@@ -229,24 +315,40 @@ func analyzePackage(fset *token.FileSet, root, dir, modulePath string, opts Opti
 		}
 	}
 
-	var detailedFiles []File
-	if len(files) >= opts.LargePackageFiles {
-		detailedFiles = files
-	}
+	attachReceiverMethods(allTypes, receiverMethods)
+	attachReceiverMethods(privateSymbols, receiverMethods)
+	detailedFiles, keyFiles := packageFileDetails(files, entryPoint, opts)
 
 	return &Package{
-		ImportPath:    importPath,
-		RelativePath:  relPath,
-		Purpose:       purpose,
-		FileCount:     len(files),
-		LineCount:     totalLines,
-		Files:         detailedFiles,
-		ExportedTypes: allTypes,
-		Imports:       internalImports,
-		EntryPoint:    entryPoint,
+		ImportPath:      importPath,
+		RelativePath:    relPath,
+		Purpose:         purpose,
+		FileCount:       len(files),
+		LineCount:       totalLines,
+		Files:           detailedFiles,
+		KeyFiles:        keyFiles,
+		ExportedTypes:   allTypes,
+		Symbols:         packageSymbols(files, allTypes),
+		Funcs:           sortedFuncs(funcs),
+		PrivateSymbols:  privateSymbols,
+		Imports:         internalImports,
+		ExternalImports: sortedImportSet(externalSeen),
+		EntryPoint:      entryPoint,
+		Tags:            tags,
 	}, nil
 }
 
+// goFuncSignature prints the declaration of fn without its body on one line.
+func goFuncSignature(fn *ast.FuncDecl) string {
+	var sb strings.Builder
+	// A FileSet without fn's positions keeps the printer from reproducing
+	// the original line breaks.
+	if err := printer.Fprint(&sb, token.NewFileSet(), &ast.FuncDecl{Name: fn.Name, Type: fn.Type}); err != nil {
+		return "func " + fn.Name.Name
+	}
+	return sb.String()
+}
+
 func findModulePath(root string) string {
 	modFile := filepath.Join(root, "go.mod")
 	f, err := os.Open(modFile)
@@ -265,33 +367,79 @@ func findModulePath(root string) string {
 	return ""
 }
 
-func extractFirstSentence(text string) string {
-	text = strings.TrimSpace(text)
-	if text == "" {
-		return ""
+func isInternalImport(imp, pkgImportPath string) bool {
+	if pkgImportPath == "" {
+		return false
 	}
+	return imp == pkgImportPath || strings.HasPrefix(imp, pkgImportPath+"/")
+}
 
-	for i, r := range text {
-		if r == '.' || r == '\n' {
-			s := strings.TrimSpace(text[:i+1])
-			if r == '.' {
-				return s
-			}
-			return strings.TrimSuffix(s, ".")
-		}
+// packageFileDetails returns the files listed for a package: all of them once
+// it reaches Options.LargePackageFiles, otherwise none, with key files kept
+// for the JSON output instead: the entry file plus the Options.JSONMinFiles
+// largest others. With Options.DetailBudget every file is kept as a key file,
+// so assignAdaptiveDetail can list the files of important small packages, and
+// likewise for the package pages of Options.SplitOutputDir.
+func packageFileDetails(files []File, entryPoint string, opts Options) (detailed, key []File) {
+	if len(files) >= opts.LargePackageFiles {
+		return files, nil
+	}
+	if keepsAllKeyFiles(opts) {
+		return nil, keyFiles(files, entryPoint, len(files))
 	}
+	return nil, keyFiles(files, entryPoint, opts.JSONMinFiles)
+}
+
+// keepsAllKeyFiles reports whether opts needs every file of smaller packages.
+func keepsAllKeyFiles(opts Options) bool {
+	return opts.DetailBudget > 0 || opts.SplitOutputDir != ""
+}
+
+// keepsFuncs reports whether packages keep Funcs although opts compacts
+// them, see compactPackage.
+func keepsFuncs(opts Options) bool {
+	return opts.LowMemory && opts.APISurface
+}
+
+// keepsSymbols reports whether packages keep Symbols although opts compacts
+// them, see compactPackage.
+func keepsSymbols(opts Options) bool {
+	return opts.LowMemory && opts.SymbolsOutputPath != ""
+}
 
-	if len(text) > 100 {
-		return text[:100] + "..."
+// keyFiles returns the entry file followed by the n largest other files.
+func keyFiles(files []File, entryPoint string, n int) []File {
+	if n <= 0 || len(files) == 0 {
+		return nil
+	}
+	var key, others []File
+	for _, file := range files {
+		if file.Name == entryPoint {
+			key = append(key, file)
+		} else {
+			others = append(others, file)
+		}
 	}
-	return text
+	sort.SliceStable(others, func(i, j int) bool {
+		if others[i].LineCount != others[j].LineCount {
+			return others[i].LineCount > others[j].LineCount
+		}
+		return others[i].Name < others[j].Name
+	})
+	return append(key, others[:min(n, len(others))]...)
 }
 
-func isInternalImport(imp, pkgImportPath string) bool {
-	if pkgImportPath == "" {
-		return false
+// sortedImportSet flattens an import set into a sorted slice, or nil when empty.
+func sortedImportSet(seen map[string]struct{}) []string {
+	if len(seen) == 0 {
+		return nil
 	}
-	return imp == pkgImportPath || strings.HasPrefix(imp, pkgImportPath+"/")
+	out := make([]string, 0, len(seen))
+	for imp := range seen {
+		out = append(out, imp)
+	}
+	sort.Strings(out)
+	return out
 }
 
 func scoreEntryPoint(filename, pkgName string, types, funcs []string) int {
@@ -318,7 +466,79 @@ func scoreEntryPoint(filename, pkgName string, types, funcs []string) int {
 	return score
 }
 
-func buildConcerns(idx *FileIndex, defs []ConcernDef, exampleLimit int) ([]Concern, error) {
+// cachedConcerns returns concern results, reusing the previous run's results
+// when neither the indexed file set nor the concern definitions changed.
+// Concern matching only looks at paths, so content edits keep the cache warm.
+func cachedConcerns(in AnalysisInput) ([]Concern, error) {
+	pathLimit := concernPathLimit(in.Options)
+	fingerprint := concernFingerprint(in.Index, in.Options.Concerns, in.Options.ConcernExampleLimit, pathLimit)
+	if prev := in.PrevState; prev != nil && prev.Analysis != nil && prev.Analysis.Version == analysisCacheVersion {
+		if cached := prev.Analysis.Concerns; cached != nil && cached.Fingerprint == fingerprint {
+			concerns := cloneConcerns(cached.Concerns)
+			storeConcernCache(in.NextState, fingerprint, concerns)
+			return concerns, nil
+		}
+	}
+
+	concerns, err := buildConcerns(in.Index, in.Options.Concerns, in.Options.ConcernExampleLimit, pathLimit)
+	if err != nil {
+		return nil, err
+	}
+	storeConcernCache(in.NextState, fingerprint, concerns)
+	return concerns, nil
+}
+
+func concernFingerprint(idx *FileIndex, defs []ConcernDef, exampleLimit, pathLimit int) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%d\x00%d\x00", analysisCacheVersion, exampleLimit, pathLimit)
+	for _, def := range defs {
+		fmt.Fprintf(h, "%s\x00%s\x00%d\x00%s\x00", def.Name, strings.Join(def.Patterns, "\x01"), def.ExampleLimit, def.Note)
+	}
+	_, _ = h.Write([]byte{0})
+	for _, rec := range idx.Files {
+		_, _ = h.Write([]byte(rec.RelPath))
+		_, _ = h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func storeConcernCache(nextState *CodemapState, fingerprint string, concerns []Concern) {
+	if nextState == nil {
+		return
+	}
+	if nextState.Analysis == nil {
+		nextState.Analysis = &AnalysisCache{Version: analysisCacheVersion}
+	}
+	nextState.Analysis.Concerns = &ConcernCache{
+		Fingerprint: fingerprint,
+		Concerns:    cloneConcerns(concerns),
+	}
+}
+
+func cloneConcerns(concerns []Concern) []Concern {
+	if concerns == nil {
+		return nil
+	}
+	out := make([]Concern, len(concerns))
+	for i, concern := range concerns {
+		out[i] = concern
+		out[i].Patterns = append([]string(nil), concern.Patterns...)
+		out[i].Files = append([]string(nil), concern.Files...)
+		out[i].Paths = append([]string(nil), concern.Paths...)
+		if concern.Languages != nil {
+			out[i].Languages = make(map[string]int, len(concern.Languages))
+			for language, count := range concern.Languages {
+				out[i].Languages[language] = count
+			}
+		}
+	}
+	return out
+}
+
+// buildConcerns matches the index against defs, keeping up to exampleLimit
+// (or the definition's own limit) example files and up to pathLimit paths
+// per concern.
+func buildConcerns(idx *FileIndex, defs []ConcernDef, exampleLimit, pathLimit int) ([]Concern, error) {
 	var concerns []Concern
 
 	for _, def := range defs {
@@ -335,10 +555,14 @@ func buildConcerns(idx *FileIndex, defs []ConcernDef, exampleLimit int) ([]Conce
 		}
 
 		uniqueFiles := make(map[string]struct{})
+		languages := make(map[string]int)
 		for _, rec := range idx.Files {
 			for _, matcher := range matchers {
 				if matcher.matches(rec.RelPath) {
 					uniqueFiles[rec.RelPath] = struct{}{}
+					if rec.Language != "" {
+						languages[rec.Language]++
+					}
 					break
 				}
 			}
@@ -349,17 +573,24 @@ func buildConcerns(idx *FileIndex, defs []ConcernDef, exampleLimit int) ([]Conce
 			continue
 		}
 
-		var examples []string
-		if exampleLimit > 0 {
-			all := make([]string, 0, totalFiles)
+		limit := exampleLimit
+		if def.ExampleLimit > 0 {
+			limit = def.ExampleLimit
+		}
+		var all []string
+		if limit > 0 || pathLimit > 0 {
+			all = make([]string, 0, totalFiles)
 			for f := range uniqueFiles {
 				all = append(all, f)
 			}
 			sort.Strings(all)
-			if len(all) > exampleLimit {
-				all = all[:exampleLimit]
-			}
-			examples = all
+		}
+		var examples, paths []string
+		if limit > 0 {
+			examples = all[:min(limit, len(all))]
+		}
+		if pathLimit > 0 {
+			paths = all[:min(pathLimit, len(all))]
 		}
 
 		concerns = append(concerns, Concern{
@@ -367,6 +598,9 @@ func buildConcerns(idx *FileIndex, defs []ConcernDef, exampleLimit int) ([]Conce
 			Patterns:   def.Patterns,
 			Files:      examples,
 			TotalFiles: totalFiles,
+			Languages:  languages,
+			Note:       def.Note,
+			Paths:      paths,
 		})
 	}
 
@@ -542,21 +776,24 @@ type packagePlan struct {
 	DirAbsPath   string
 	FileRelPaths []string
 	Fingerprint  string
+	Name         string // Name declared by the package's manifest, for analyzers that read one
 }
 
 type analysisJob struct {
-	index int
-	dir   string
+	index   int
+	dir     string
+	relPath string
 }
 
 type analysisResult struct {
-	index int
-	dir   string
-	pkg   *Package
-	err   error
+	index   int
+	dir     string
+	pkg     *Package
+	err     error
+	skipped bool
 }
 
-type packageAnalyzerFunc func(job analysisJob) (*Package, error)
+type packageAnalyzerFunc func(ctx context.Context, job analysisJob) (*Package, error)
 
 func stateEntryByRelPath(state *CodemapState) map[string]StateEntry {
 	if state == nil || len(state.Entries) == 0 {
@@ -750,32 +987,42 @@ func packageFingerprint(fileRelPaths []string, entriesByRel map[string]StateEntr
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// namedPackageFingerprint extends the fingerprint of a package's files with
+// the name its manifest declares, so renaming the package invalidates its
+// cached analysis.
+func namedPackageFingerprint(fingerprint, name string) string {
+	if fingerprint == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(fingerprint + "\x00" + name))
+	return hex.EncodeToString(sum[:])
+}
+
 func cachedPackagesByPath(prevState *CodemapState, opts Options, modulePath string) map[string]CachedPackage {
 	if prevState == nil || prevState.Analysis == nil {
 		return nil
 	}
 	cache := prevState.Analysis
-	if cache.Version != analysisCacheVersionV2 ||
-		cache.IncludeTests != opts.IncludeTests ||
-		cache.LargePackageFiles != opts.LargePackageFiles ||
-		cache.ModulePath != modulePath {
+	if !analysisCacheCompatible(cache, opts) {
 		return nil
 	}
 
 	byRel := make(map[string]CachedPackage, len(cache.Packages))
 	for _, cachedPkg := range cache.Packages {
-		byRel[cachedPkg.RelativePath] = cachedPkg
+		if cachedPkg.Scope == modulePath {
+			byRel[cachedPkg.RelativePath] = cachedPkg
+		}
 	}
 	return byRel
 }
 
 func analyzePackagesParallel(ctx context.Context, root, modulePath string, opts Options, jobs []analysisJob, out []*Package) error {
-	return analyzePackagePlansParallel(ctx, opts, jobs, out, func(job analysisJob) (*Package, error) {
-		return analyzePackage(token.NewFileSet(), root, job.dir, modulePath, opts)
+	return analyzePackagePlansParallel(ctx, opts, languageGo, jobs, out, func(ctx context.Context, job analysisJob) (*Package, error) {
+		return analyzePackage(ctx, token.NewFileSet(), root, job.dir, modulePath, opts)
 	})
 }
 
-func analyzePackagePlansParallel(ctx context.Context, opts Options, jobs []analysisJob, out []*Package, analyze packageAnalyzerFunc) error {
+func analyzePackagePlansParallel(ctx context.Context, opts Options, languageID string, jobs []analysisJob, out []*Package, analyze packageAnalyzerFunc) error {
 	if len(jobs) == 0 {
 		return nil
 	}
@@ -783,7 +1030,12 @@ func analyzePackagePlansParallel(ctx context.Context, opts Options, jobs []analy
 		return nil
 	}
 
+	guard := resourceGuardFromContext(ctx)
 	workerCount := runtime.GOMAXPROCS(0)
+	if opts.LowMemory {
+		// One package at a time keeps a single parse tree alive.
+		workerCount = 1
+	}
 	if workerCount < 1 {
 		workerCount = 1
 	}
@@ -798,8 +1050,12 @@ func analyzePackagePlansParallel(ctx context.Context, opts Options, jobs []analy
 				return ctx.Err()
 			default:
 			}
-			pkg, err := analyze(job)
+			if guard.exceeded() {
+				return nil
+			}
+			pkg, err := analyzeInstrumented(ctx, opts, languageID, job, analyze)
 			if err != nil {
+				recordSkippedPackage(ctx)
 				if opts.Verbose {
 					fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", job.dir, err)
 				}
@@ -817,7 +1073,15 @@ func analyzePackagePlansParallel(ctx context.Context, opts Options, jobs []analy
 	worker := func() {
 		defer wg.Done()
 		for job := range jobsCh {
-			pkg, err := analyze(job)
+			if guard.exceeded() {
+				select {
+				case resultsCh <- analysisResult{index: job.index, dir: job.dir, skipped: true}:
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+			pkg, err := analyzeInstrumented(ctx, opts, languageID, job, analyze)
 			select {
 			case resultsCh <- analysisResult{
 				index: job.index,
@@ -852,7 +1116,11 @@ func analyzePackagePlansParallel(ctx context.Context, opts Options, jobs []analy
 		case <-ctx.Done():
 			return ctx.Err()
 		case result := <-resultsCh:
+			if result.skipped {
+				continue
+			}
 			if result.err != nil {
+				recordSkippedPackage(ctx)
 				if opts.Verbose {
 					fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", result.dir, result.err)
 				}
@@ -866,29 +1134,107 @@ func analyzePackagePlansParallel(ctx context.Context, opts Options, jobs []analy
 	return nil
 }
 
-func updateAnalysisCache(nextState *CodemapState, opts Options, modulePath string, plans []packagePlan, packageResults []*Package) {
+type skippedPackagesKey struct{}
+
+// withSkippedPackageCounter attaches a counter of packages whose analysis
+// failed to ctx, so the run can say its output is incomplete even when the
+// per-package errors are only printed with Options.Verbose.
+func withSkippedPackageCounter(ctx context.Context) (context.Context, *atomic.Int64) {
+	counter := new(atomic.Int64)
+	return context.WithValue(ctx, skippedPackagesKey{}, counter), counter
+}
+
+func recordSkippedPackage(ctx context.Context) {
+	if counter, ok := ctx.Value(skippedPackagesKey{}).(*atomic.Int64); ok {
+		counter.Add(1)
+	}
+}
+
+// skippedPackagesWarning describes n packages left out after analysis errors.
+func skippedPackagesWarning(n int64) string {
+	if n == 1 {
+		return "1 package skipped due to parse errors; run with -v for details"
+	}
+	return fmt.Sprintf("%d packages skipped due to parse errors; run with -v for details", n)
+}
+
+func analyzeInstrumented(ctx context.Context, opts Options, languageID string, job analysisJob, analyze packageAnalyzerFunc) (*Package, error) {
+	pkgCtx, end := startPackageInstrumentation(ctx, opts, languageID, job.relPath)
+	pkg, err := analyze(pkgCtx, job)
+	end(err)
+	if err == nil && pkg != nil && opts.LowMemory {
+		compactPackage(pkg, opts)
+	}
+	return pkg, err
+}
+
+func analysisCacheCompatible(cache *AnalysisCache, opts Options) bool {
+	return cache != nil &&
+		cache.Version == analysisCacheVersion &&
+		cache.IncludeTests == opts.IncludeTests &&
+		cache.IncludeUnexported == opts.IncludeUnexported &&
+		cache.LowMemory == opts.LowMemory &&
+		cache.Funcs == keepsFuncs(opts) &&
+		cache.Symbols == keepsSymbols(opts) &&
+		strings.Join(cache.PurposeExtractors, ",") == strings.Join(purposeExtractorLanguages(opts), ",") &&
+		cache.LargePackageFiles == opts.LargePackageFiles &&
+		cache.JSONMinFiles == opts.JSONMinFiles &&
+		cache.AllKeyFiles == keepsAllKeyFiles(opts)
+}
+
+// updateAnalysisCache replaces the cached packages for modulePath's scope,
+// keeping entries written by other analyzers in the same run. Packages are
+// cached with their language, which the engine otherwise only assigns after
+// analysis, so readers of the cache need not derive it from the scope.
+func updateAnalysisCache(nextState *CodemapState, opts Options, modulePath, language string, plans []packagePlan, packageResults []*Package) {
 	if nextState == nil {
 		return
 	}
 
 	cachedPkgs := make([]CachedPackage, 0, len(packageResults))
+	var concerns *ConcernCache
+	var scans []FileScan
+	if prev := nextState.Analysis; prev != nil {
+		scans = prev.Scans
+	}
+	if prev := nextState.Analysis; analysisCacheCompatible(prev, opts) {
+		for _, cachedPkg := range prev.Packages {
+			if cachedPkg.Scope != modulePath {
+				cachedPkgs = append(cachedPkgs, cachedPkg)
+			}
+		}
+		concerns = prev.Concerns
+	}
 	for i := range packageResults {
 		if packageResults[i] == nil || plans[i].Fingerprint == "" {
 			continue
 		}
+		pkg := *packageResults[i]
+		if pkg.Language == "" {
+			pkg.Language = language
+		}
 		cachedPkgs = append(cachedPkgs, CachedPackage{
+			Scope:        modulePath,
 			RelativePath: plans[i].RelativePath,
 			Fingerprint:  plans[i].Fingerprint,
 			FileRelPaths: append([]string(nil), plans[i].FileRelPaths...),
-			Package:      *packageResults[i],
+			Package:      pkg,
 		})
 	}
 
 	nextState.Analysis = &AnalysisCache{
-		Version:           analysisCacheVersionV2,
+		Version:           analysisCacheVersion,
 		IncludeTests:      opts.IncludeTests,
+		IncludeUnexported: opts.IncludeUnexported,
+		LowMemory:         opts.LowMemory,
+		PurposeExtractors: purposeExtractorLanguages(opts),
 		LargePackageFiles: opts.LargePackageFiles,
-		ModulePath:        modulePath,
+		JSONMinFiles:      opts.JSONMinFiles,
+		AllKeyFiles:       keepsAllKeyFiles(opts),
+		Funcs:             keepsFuncs(opts),
+		Symbols:           keepsSymbols(opts),
 		Packages:          cachedPkgs,
+		Concerns:          concerns,
+		Scans:             scans,
 	}
 }
@@ -0,0 +1,145 @@
+package codemap
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tocEntry is one line of the CODEMAP.md table of contents.
+type tocEntry struct {
+	Title  string
+	Anchor string
+}
+
+// packageAnchorIDs returns the HTML anchor of each row of the Package Entry
+// Points table: "pkg-" plus the path lowercased, with every run of other
+// characters than letters and digits turned into "-" ("pkg-root" for the
+// project root). Rows repeating a path, as packages of several languages in
+// one directory do, get no anchor; different paths sharing a slug get a
+// numeric suffix.
+func packageAnchorIDs(packages []Package) []string {
+	ids := make([]string, len(packages))
+	byPath := make(map[string]struct{}, len(packages))
+	used := make(map[string]struct{}, len(packages))
+	for i, pkg := range packages {
+		if _, seen := byPath[pkg.RelativePath]; seen {
+			continue
+		}
+		byPath[pkg.RelativePath] = struct{}{}
+
+		slug := anchorSlug(pkg.RelativePath)
+		if slug == "" {
+			slug = "root"
+		}
+		id := "pkg-" + slug
+		for n := 2; ; n++ {
+			if _, taken := used[id]; !taken {
+				break
+			}
+			id = "pkg-" + slug + "-" + strconv.Itoa(n)
+		}
+		used[id] = struct{}{}
+		ids[i] = id
+	}
+	return ids
+}
+
+func anchorSlug(s string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return sb.String()
+}
+
+// headingAnchor returns the anchor GitHub and GitLab generate for a heading:
+// lowercase, punctuation other than "-" dropped, spaces turned into "-".
+func headingAnchor(title string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			sb.WriteRune(r)
+		case r == ' ':
+			sb.WriteByte('-')
+		}
+	}
+	return sb.String()
+}
+
+// markdownSections lists the sections r renders for cm, in order. It mirrors
+// the conditions of codemapTemplate.
+func markdownSections(cm *Codemap, r MarkdownRenderer) []tocEntry {
+	var titles []string
+	if len(cm.Repos) > 0 {
+		titles = append(titles, "Repositories")
+	}
+	if len(cm.Services) > 0 {
+		titles = append(titles, "Services")
+	}
+	if len(cm.Binaries) > 0 {
+		titles = append(titles, "Binaries / Entry Points")
+	}
+	if len(cm.Toolchains) > 0 {
+		titles = append(titles, "Toolchains")
+	}
+	if len(cm.Migrations) > 0 {
+		titles = append(titles, "Data / Migrations")
+	}
+	if len(cm.Endpoints) > 0 {
+		titles = append(titles, "HTTP Endpoints")
+	}
+	titles = append(titles, "Package Entry Points")
+	if len(PackageTagIndex(cm.Packages)) > 0 {
+		titles = append(titles, "Tags")
+	}
+	if len(fileRoleRows(cm.Packages)) > 0 {
+		titles = append(titles, "Large Package Files")
+	}
+	if hasCompanions(cm.Packages) {
+		titles = append(titles, "Entry Companions")
+	}
+	if hasPlatformVariants(cm.Packages) {
+		titles = append(titles, "Platform Variants")
+	}
+	if len(dependencyRows(cm.Packages)) > 0 {
+		titles = append(titles, "Dependency Graph")
+	}
+	if architectureHealth(cm.Packages) != nil {
+		titles = append(titles, "Architecture Health")
+	}
+	if len(coChangeRows(cm.Packages)) > 0 {
+		titles = append(titles, "Frequently Changed Together")
+	}
+	if len(securityRows(cm.Packages)) > 0 {
+		titles = append(titles, "Security-Sensitive Packages")
+	}
+	if len(externalModuleRows(cm.Packages)) > 0 {
+		titles = append(titles, "External Go Modules")
+	}
+	if r.APISurface && len(apiSurfacePackages(cm.Packages)) > 0 {
+		titles = append(titles, "API Surface")
+	}
+	if cm.Statistics != nil {
+		titles = append(titles, "Statistics")
+	}
+	if len(cm.Concerns) > 0 {
+		titles = append(titles, "Concerns (Summary)")
+	}
+
+	entries := make([]tocEntry, len(titles))
+	for i, title := range titles {
+		entries[i] = tocEntry{Title: title, Anchor: headingAnchor(title)}
+	}
+	return entries
+}
@@ -0,0 +1,58 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPackageAnchorIDs(t *testing.T) {
+	packages := []Package{
+		{RelativePath: "."},
+		{RelativePath: "internal/foo"},
+		{RelativePath: "internal/foo"},
+		{RelativePath: "internal_foo"},
+		{RelativePath: "web/My.App"},
+	}
+	got := packageAnchorIDs(packages)
+	want := []string{"pkg-root", "pkg-internal-foo", "", "pkg-internal-foo-2", "pkg-web-my-app"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("packageAnchorIDs = %v, want %v", got, want)
+	}
+}
+
+func TestMarkdownTOCLinksRenderedSections(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":              "module example.com/app\n\ngo 1.22\n",
+		"internal/foo/foo.go": "// Package foo does foo.\npackage foo\n",
+		"cmd/app/main.go":     "package main\n\nimport _ \"example.com/app/internal/foo\"\n\nfunc main() {}\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.MarkdownTOC = true
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	markdown, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(markdown)
+	for _, want := range []string{
+		"## Contents\n\n- [Binaries / Entry Points](#binaries--entry-points)\n- [Toolchains](#toolchains)\n- [Package Entry Points](#package-entry-points)\n- [Dependency Graph](#dependency-graph)\n",
+		"| <a id=\"pkg-internal-foo\"></a>internal/foo | internal/foo/foo.go |",
+	} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("expected %q in CODEMAP.md:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "(#services)") {
+		t.Fatalf("expected no link to a section that is not rendered:\n%s", content)
+	}
+}
@@ -0,0 +1,39 @@
+package codemap
+
+import "sort"
+
+// sortedFuncs orders funcs by name, then file, for stable output.
+func sortedFuncs(funcs []FuncInfo) []FuncInfo {
+	sort.SliceStable(funcs, func(i, j int) bool {
+		if funcs[i].Name != funcs[j].Name {
+			return funcs[i].Name < funcs[j].Name
+		}
+		return funcs[i].File < funcs[j].File
+	})
+	return funcs
+}
+
+// apiSurfacePackages returns the packages listed in the API Surface section:
+// those with exported function signatures.
+func apiSurfacePackages(packages []Package) []Package {
+	var listed []Package
+	for _, pkg := range packages {
+		if len(pkg.Funcs) > 0 {
+			listed = append(listed, pkg)
+		}
+	}
+	return listed
+}
+
+// codeFence returns the Markdown code block language of an analyzer
+// language.
+func codeFence(language string) string {
+	switch language {
+	case languageTypeScript:
+		return "ts"
+	case languageJavaScript:
+		return "js"
+	default:
+		return language
+	}
+}
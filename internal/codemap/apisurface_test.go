@@ -0,0 +1,128 @@
+package codemap
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGoFuncSignatures(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.22\n",
+		"store/store.go": `// Package store saves things.
+package store
+
+import "context"
+
+// Save stores v under key.
+func Save[T any](
+	ctx context.Context,
+	key string, // the key
+	v T,
+) (int, error) {
+	return 0, nil
+}
+
+func load() {}
+
+// Close is a method and not listed.
+func (s *Store) Close() error { return nil }
+
+type Store struct{}
+`,
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	var funcs []FuncInfo
+	for _, pkg := range cm.Packages {
+		if pkg.RelativePath == "store" {
+			funcs = pkg.Funcs
+		}
+	}
+	want := []FuncInfo{{
+		Name:      "Save",
+		Signature: "func Save[T any](ctx context.Context, key string, v T) (int, error)",
+		Comment:   "Save stores v under key.",
+		File:      "store.go",
+	}}
+	if !reflect.DeepEqual(funcs, want) {
+		t.Fatalf("Funcs =\n%+v\nwant\n%+v", funcs, want)
+	}
+
+	content, err := MarkdownRenderer{APISurface: true, TOC: true}.Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	section := "## API Surface\n\n### store\n\n```go\n// Save stores v under key.\nfunc Save[T any](ctx context.Context, key string, v T) (int, error)\n```\n"
+	if !strings.Contains(content, section) || !strings.Contains(content, "- [API Surface](#api-surface)") {
+		t.Fatalf("expected API Surface section and TOC entry in:\n%s", content)
+	}
+	content, err = Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if strings.Contains(content, "## API Surface") {
+		t.Fatalf("expected no API Surface section by default:\n%s", content)
+	}
+}
+
+func TestTypeScriptFuncSignatures(t *testing.T) {
+	content := []byte(`/** Formats a name. */
+export function format<T>(name: string,
+  opts?: T): string {
+  return name;
+}
+
+export const parse = async (input: string): Promise<number> => Number(input);
+
+export const VERSION = "1.0";
+
+function hidden(): void {}
+`)
+	parser, err := newTypeScriptParser(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	_, _, _, _, _, funcs := parseTypeScriptFileSymbolsWithParser(content, parser)
+	want := []FuncInfo{
+		{Name: "format", Signature: "function format<T>(name: string, opts?: T): string", Comment: "Formats a name."},
+		{Name: "parse", Signature: "const parse = async (input: string): Promise<number> =>"},
+	}
+	if !reflect.DeepEqual(funcs, want) {
+		t.Fatalf("funcs =\n%+v\nwant\n%+v", funcs, want)
+	}
+}
+
+func TestRustFuncSignatures(t *testing.T) {
+	content := []byte(`/// Parses input.
+pub fn parse<'a>(input: &'a str) -> Result<Ast<'a>, Error>
+where
+    Error: Debug,
+{
+    todo!()
+}
+
+fn private() {}
+`)
+	parser, err := newRustParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	_, _, _, _, funcs := parseRustFileSymbolsWithParser(content, parser)
+	want := []FuncInfo{
+		{Name: "parse", Signature: "pub fn parse<'a>(input: &'a str) -> Result<Ast<'a>, Error> where Error: Debug,", Comment: "Parses input."},
+	}
+	if !reflect.DeepEqual(funcs, want) {
+		t.Fatalf("funcs =\n%+v\nwant\n%+v", funcs, want)
+	}
+}
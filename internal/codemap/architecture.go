@@ -0,0 +1,171 @@
+package codemap
+
+import (
+	"context"
+	"sort"
+)
+
+// maxArchitectureHubs caps the most depended-on packages listed in the
+// Architecture Health section.
+const maxArchitectureHubs = 10
+
+// dependencyGraph is the package-path adjacency built from DependsOn, with
+// packages of different languages sharing a directory merged into one node.
+type dependencyGraph struct {
+	nodes []string
+	edges map[string][]string
+}
+
+func buildDependencyGraph(packages []Package) dependencyGraph {
+	graph := dependencyGraph{nodes: graphNodes(packages), edges: make(map[string][]string)}
+	for _, row := range dependencyRows(packages) {
+		graph.edges[row.Name] = row.Packages
+	}
+	return graph
+}
+
+// components returns the strongly connected components of the graph in
+// reverse topological order: every component comes after the components it
+// depends on. Members of each component are sorted.
+func (g dependencyGraph) components() [][]string {
+	index := make(map[string]int, len(g.nodes))
+	lowlink := make(map[string]int, len(g.nodes))
+	onStack := make(map[string]bool, len(g.nodes))
+	var stack []string
+	var components [][]string
+
+	var visit func(node string)
+	visit = func(node string) {
+		index[node] = len(index)
+		lowlink[node] = index[node]
+		stack = append(stack, node)
+		onStack[node] = true
+		for _, dep := range g.edges[node] {
+			if _, seen := index[dep]; !seen {
+				visit(dep)
+				lowlink[node] = min(lowlink[node], lowlink[dep])
+			} else if onStack[dep] {
+				lowlink[node] = min(lowlink[node], index[dep])
+			}
+		}
+		if lowlink[node] != index[node] {
+			return
+		}
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == node {
+				break
+			}
+		}
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	for _, node := range g.nodes {
+		if _, seen := index[node]; !seen {
+			visit(node)
+		}
+	}
+	return components
+}
+
+// ProjectImportCycles analyzes opts.ProjectRoot and returns its ImportCycles.
+// Packages whose fingerprint still matches are served from the analysis
+// cache, and nothing is written.
+func ProjectImportCycles(ctx context.Context, opts Options) ([][]string, error) {
+	cm, _, err := analyzeCached(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return ImportCycles(cm.Packages), nil
+}
+
+// ImportCycles returns the groups of packages that import each other
+// directly or transitively, each sorted by path, ordered by their first path.
+func ImportCycles(packages []Package) [][]string {
+	var cycles [][]string
+	for _, component := range buildDependencyGraph(packages).components() {
+		if len(component) > 1 {
+			cycles = append(cycles, component)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// dependencyLayers assigns every package path its layer: 0 for packages that
+// import no project package, otherwise one more than the highest layer they
+// import. Packages in a cycle share a layer.
+func dependencyLayers(packages []Package) map[string]int {
+	graph := buildDependencyGraph(packages)
+	layers := make(map[string]int, len(graph.nodes))
+	for _, component := range graph.components() {
+		members := make(map[string]struct{}, len(component))
+		for _, node := range component {
+			members[node] = struct{}{}
+		}
+		layer := 0
+		for _, node := range component {
+			for _, dep := range graph.edges[node] {
+				if _, inside := members[dep]; !inside {
+					layer = max(layer, layers[dep]+1)
+				}
+			}
+		}
+		for _, node := range component {
+			layers[node] = layer
+		}
+	}
+	return layers
+}
+
+type architectureHub struct {
+	Name       string
+	Dependents int
+	Layer      int
+}
+
+type architectureReport struct {
+	Layers int // Number of dependency layers
+	Cycles [][]string
+	Hubs   []architectureHub
+}
+
+// architectureHealth summarizes the internal dependency graph for CODEMAP.md:
+// import cycles, layering depth, and the packages most others depend on. It
+// returns nil when no package imports another.
+func architectureHealth(packages []Package) *architectureReport {
+	rows := dependencyRows(packages)
+	if len(rows) == 0 {
+		return nil
+	}
+	dependents := make(map[string]int)
+	for _, row := range rows {
+		for _, dep := range row.Packages {
+			dependents[dep]++
+		}
+	}
+	layers := dependencyLayers(packages)
+	report := &architectureReport{Cycles: ImportCycles(packages)}
+	for _, layer := range layers {
+		report.Layers = max(report.Layers, layer+1)
+	}
+	for name, count := range dependents {
+		if count >= 2 {
+			report.Hubs = append(report.Hubs, architectureHub{Name: name, Dependents: count, Layer: layers[name]})
+		}
+	}
+	sort.Slice(report.Hubs, func(i, j int) bool {
+		if report.Hubs[i].Dependents != report.Hubs[j].Dependents {
+			return report.Hubs[i].Dependents > report.Hubs[j].Dependents
+		}
+		return report.Hubs[i].Name < report.Hubs[j].Name
+	})
+	if len(report.Hubs) > maxArchitectureHubs {
+		report.Hubs = report.Hubs[:maxArchitectureHubs]
+	}
+	return report
+}
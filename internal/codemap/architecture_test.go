@@ -0,0 +1,67 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportCyclesAndLayers(t *testing.T) {
+	packages := []Package{
+		{RelativePath: "api", DependsOn: []string{"store", "util"}},
+		{RelativePath: "cli", DependsOn: []string{"api", "util"}},
+		{RelativePath: "store", DependsOn: []string{"cache", "util"}},
+		{RelativePath: "cache", DependsOn: []string{"store"}},
+		{RelativePath: "util"},
+	}
+	if cycles := ImportCycles(packages); !reflect.DeepEqual(cycles, [][]string{{"cache", "store"}}) {
+		t.Fatalf("unexpected cycles: %v", cycles)
+	}
+	want := map[string]int{"util": 0, "cache": 1, "store": 1, "api": 2, "cli": 3}
+	if layers := dependencyLayers(packages); !reflect.DeepEqual(layers, want) {
+		t.Fatalf("unexpected layers: %v", layers)
+	}
+
+	report := architectureHealth(packages)
+	wantHubs := []architectureHub{
+		{Name: "util", Dependents: 3, Layer: 0},
+		{Name: "store", Dependents: 2, Layer: 1},
+	}
+	if report == nil || report.Layers != 4 || !reflect.DeepEqual(report.Hubs, wantHubs) {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if architectureHealth([]Package{{RelativePath: "util"}}) != nil {
+		t.Fatal("expected no report without dependencies")
+	}
+}
+
+func TestArchitectureHealthSection(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":   "module example.com/app\n\ngo 1.22\n",
+		"main.go":  "package main\n\nimport \"example.com/app/a\"\n\nfunc main() { a.Run() }\n",
+		"a/a.go":   "package a\n\nimport \"example.com/app/b\"\n\nfunc Run() { b.Run() }\n",
+		"b/b.go":   "package b\n\nimport \"example.com/app/a\"\n\nfunc Run() { a.Run() }\n",
+		"c/c.go":   "package c\n\nimport \"example.com/app/b\"\n\nfunc Run() { b.Run() }\n",
+		"d/doc.go": "package d\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	markdown, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"## Architecture Health", "Dependency layers: 2", "| a, b |", "| b | 2 | 0 |"} {
+		if !strings.Contains(string(markdown), want) {
+			t.Fatalf("expected %q in:\n%s", want, markdown)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// assetManifestNames lists JSON/YAML files that belong to another language's
+// package row (manifests, lockfiles) and would only duplicate it.
+var assetManifestNames = map[string]struct{}{
+	"package.json":        {},
+	"package-lock.json":   {},
+	"npm-shrinkwrap.json": {},
+	"composer.json":       {},
+	"tsconfig.json":       {},
+	"jsconfig.json":       {},
+	"pnpm-lock.yaml":      {},
+}
+
+// StyleAnalyzer counts CSS/SCSS stylesheets per directory without extracting symbols.
+type StyleAnalyzer struct{}
+
+func (StyleAnalyzer) LanguageID() string { return languageCSS }
+
+func (StyleAnalyzer) Analyze(ctx context.Context, in AnalysisInput) (*Codemap, error) {
+	if in.Index == nil {
+		return nil, fmt.Errorf("missing file index")
+	}
+	return analyzeAssetsWithIndex(ctx, in.Root, in.Index, in.Options, languageCSS, "Stylesheets")
+}
+
+// TemplateAnalyzer counts HTML and template files per directory without extracting symbols.
+type TemplateAnalyzer struct{}
+
+func (TemplateAnalyzer) LanguageID() string { return languageHTML }
+
+func (TemplateAnalyzer) Analyze(ctx context.Context, in AnalysisInput) (*Codemap, error) {
+	if in.Index == nil {
+		return nil, fmt.Errorf("missing file index")
+	}
+	return analyzeAssetsWithIndex(ctx, in.Root, in.Index, in.Options, languageHTML, "Templates")
+}
+
+// ConfigAnalyzer counts YAML/JSON configuration files per directory without extracting symbols.
+type ConfigAnalyzer struct{}
+
+func (ConfigAnalyzer) LanguageID() string { return languageConfig }
+
+func (ConfigAnalyzer) Analyze(ctx context.Context, in AnalysisInput) (*Codemap, error) {
+	if in.Index == nil {
+		return nil, fmt.Errorf("missing file index")
+	}
+	return analyzeAssetsWithIndex(ctx, in.Root, in.Index, in.Options, languageConfig, "Config files")
+}
+
+// analyzeAssetsWithIndex groups files of one asset language by directory and
+// records file/line counts only. Counting is cheap enough that results are not
+// persisted in the analysis cache.
+func analyzeAssetsWithIndex(ctx context.Context, root string, idx *FileIndex, opts Options, languageID, label string) (*Codemap, error) {
+	plans := buildDirectoryPackagePlans(root, idx, languageID)
+
+	packageResults := make([]*Package, len(plans))
+	jobs := make([]analysisJob, 0, len(plans))
+	for i := range plans {
+		jobs = append(jobs, analysisJob{
+			index:   i,
+			dir:     plans[i].DirAbsPath,
+			relPath: plans[i].RelativePath,
+		})
+	}
+
+	if err := analyzePackagePlansParallel(ctx, opts, languageID, jobs, packageResults, func(ctx context.Context, job analysisJob) (*Package, error) {
+		plan := plans[job.index]
+		pkg, err := analyzeAssetPackage(ctx, root, plan, label, opts)
+		if err != nil {
+			return nil, fmt.Errorf("analyze %s package %s: %w", languageID, plan.RelativePath, err)
+		}
+		return pkg, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0, len(packageResults))
+	for i := range packageResults {
+		if packageResults[i] != nil {
+			packages = append(packages, *packageResults[i])
+		}
+	}
+
+	return &Codemap{
+		ProjectRoot: root,
+		Packages:    packages,
+	}, nil
+}
+
+func buildDirectoryPackagePlans(root string, idx *FileIndex, languageID string) []packagePlan {
+	plansByRel := make(map[string]*packagePlan)
+	for _, rec := range idx.Files {
+		if rec.Language != languageID {
+			continue
+		}
+
+		relDir := filepath.ToSlash(filepath.Dir(rec.RelPath))
+		plan, ok := plansByRel[relDir]
+		if !ok {
+			plan = &packagePlan{
+				RelativePath: relDir,
+				DirAbsPath:   filepath.Join(root, filepath.FromSlash(relDir)),
+				FileRelPaths: make([]string, 0, 4),
+			}
+			plansByRel[relDir] = plan
+		}
+		plan.FileRelPaths = append(plan.FileRelPaths, rec.RelPath)
+	}
+
+	relPaths := make([]string, 0, len(plansByRel))
+	for rel := range plansByRel {
+		relPaths = append(relPaths, rel)
+	}
+	sort.Strings(relPaths)
+
+	plans := make([]packagePlan, 0, len(relPaths))
+	for _, rel := range relPaths {
+		plan := plansByRel[rel]
+		sort.Strings(plan.FileRelPaths)
+		plans = append(plans, *plan)
+	}
+	return plans
+}
+
+func analyzeAssetPackage(ctx context.Context, root string, plan packagePlan, label string, opts Options) (*Package, error) {
+	if len(plan.FileRelPaths) == 0 {
+		return nil, nil
+	}
+
+	files := make([]File, 0, len(plan.FileRelPaths))
+	totalLines := 0
+	entryPoint := ""
+	entryScore := -1
+	var tags []string
+
+	for _, relPath := range plan.FileRelPaths {
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(relPath)))
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", relPath, err)
+		}
+		lineCount := lineCountBytes(content)
+		recordFileParsed(ctx, relPath, lineCount)
+		totalLines += lineCount
+		tags = mergeCodemapTags(tags, extractCodemapTags(content))
+
+		name := filepath.Base(relPath)
+		files = append(files, File{
+			Name:      name,
+			LineCount: lineCount,
+		})
+
+		score := scoreAssetEntryPoint(name)
+		if score > entryScore || (score == entryScore && name < entryPoint) {
+			entryScore = score
+			entryPoint = name
+		}
+	}
+
+	detailedFiles, keyFiles := packageFileDetails(files, entryPoint, opts)
+	dirName := filepath.Base(plan.DirAbsPath)
+	return &Package{
+		ImportPath:   plan.RelativePath,
+		RelativePath: plan.RelativePath,
+		Purpose:      label + " in " + dirName,
+		FileCount:    len(plan.FileRelPaths),
+		LineCount:    totalLines,
+		Files:        detailedFiles,
+		KeyFiles:     keyFiles,
+		EntryPoint:   entryPoint,
+		Tags:         tags,
+	}, nil
+}
+
+func scoreAssetEntryPoint(name string) int {
+	stem := strings.ToLower(name)
+	if dot := strings.Index(stem, "."); dot > 0 {
+		stem = stem[:dot]
+	}
+	switch stem {
+	case "index", "main", "app":
+		return 100
+	case "base", "layout", "styles", "style", "global", "config", "settings", "values":
+		return 80
+	case "default", "defaults", "site":
+		return 60
+	}
+	if strings.HasPrefix(stem, "_") {
+		return 0
+	}
+	return 10
+}
+
+// isSkippedAssetFile reports whether an asset-language file should stay out of
+// the index: hidden files (including codemap's own state), minified bundles,
+// and manifests that already surface through their language package.
+func isSkippedAssetFile(relPath string) bool {
+	base := strings.ToLower(filepath.Base(relPath))
+	if strings.HasPrefix(base, ".") {
+		return true
+	}
+	if strings.HasSuffix(base, ".min.css") {
+		return true
+	}
+	_, manifest := assetManifestNames[base]
+	return manifest
+}
@@ -0,0 +1,63 @@
+package codemap
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAnalyzeAssetLanguagesCountsFilesPerDirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"web/styles/main.scss":       "body {\n  margin: 0;\n}\n",
+		"web/styles/_vars.scss":      "$x: 1;\n",
+		"web/styles/vendor.min.css":  "a{}\n",
+		"web/templates/index.html":   "<html>\n</html>\n",
+		"deploy/values.yaml":         "replicas: 2\n",
+		"deploy/service.json":        "{}\n",
+		"deploy/package.json":        "{\"name\":\"ignored\"}\n",
+		"deploy/.hidden-config.yaml": "x: 1\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+
+	byRel := make(map[string]Package, len(cm.Packages))
+	for _, pkg := range cm.Packages {
+		byRel[pkg.RelativePath] = pkg
+	}
+
+	styles, ok := byRel["web/styles"]
+	if !ok {
+		t.Fatalf("expected web/styles package, got %+v", cm.Packages)
+	}
+	if styles.FileCount != 2 || styles.LineCount != 6 {
+		t.Fatalf("expected 2 stylesheet files / 6 lines, got %d / %d", styles.FileCount, styles.LineCount)
+	}
+	if styles.EntryPoint != "main.scss" {
+		t.Fatalf("expected main.scss entry point, got %q", styles.EntryPoint)
+	}
+	if len(styles.ExportedTypes) != 0 {
+		t.Fatalf("expected no symbols for stylesheets, got %+v", styles.ExportedTypes)
+	}
+
+	if tmpl, ok := byRel["web/templates"]; !ok || tmpl.EntryPoint != "index.html" {
+		t.Fatalf("expected web/templates package with index.html entry, got %+v", tmpl)
+	}
+
+	deploy, ok := byRel["deploy"]
+	if !ok {
+		t.Fatalf("expected deploy config package, got %+v", cm.Packages)
+	}
+	if deploy.FileCount != 2 {
+		t.Fatalf("expected manifests and hidden files skipped, got %d files", deploy.FileCount)
+	}
+	if deploy.EntryPoint != "values.yaml" {
+		t.Fatalf("expected values.yaml entry point, got %q", deploy.EntryPoint)
+	}
+}
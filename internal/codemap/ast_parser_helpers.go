@@ -1,8 +1,10 @@
 package codemap
 
 import (
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 
 	sitter "github.com/tree-sitter/go-tree-sitter"
 	tree_sitter_rust "github.com/tree-sitter/tree-sitter-rust/bindings/go"
@@ -35,6 +37,52 @@ func newParserForLanguage(language *sitter.Language) (*sitter.Parser, error) {
 	return parser, nil
 }
 
+// parserPool hands out tree-sitter parsers of one grammar, reused across
+// files and packages so regeneration does not build a parser per file.
+// Parsers dropped by the pool are closed by a finalizer, so pooled parsers
+// must not be closed by callers.
+type parserPool struct {
+	language *sitter.Language
+	pool     sync.Pool
+}
+
+var (
+	rustParsers          = &parserPool{language: rustSyntaxLanguage}
+	typeScriptParsers    = &parserPool{language: typeScriptSyntaxLanguage}
+	typeScriptTSXParsers = &parserPool{language: typeScriptTSXLanguage}
+)
+
+// typeScriptParserPool returns the pool of the TSX grammar or the plain
+// TypeScript one.
+func typeScriptParserPool(isTSX bool) *parserPool {
+	if isTSX {
+		return typeScriptTSXParsers
+	}
+	return typeScriptParsers
+}
+
+// get returns a pooled parser, or nil when the grammar cannot be loaded.
+func (p *parserPool) get() *sitter.Parser {
+	if parser, ok := p.pool.Get().(*sitter.Parser); ok {
+		return parser
+	}
+	parser, err := newParserForLanguage(p.language)
+	if err != nil {
+		return nil
+	}
+	runtime.SetFinalizer(parser, (*sitter.Parser).Close)
+	return parser
+}
+
+// put returns a parser taken from p with get.
+func (p *parserPool) put(parser *sitter.Parser) {
+	if parser == nil {
+		return
+	}
+	parser.Reset()
+	p.pool.Put(parser)
+}
+
 func isTypeScriptTSXPath(path string) bool {
 	return strings.HasSuffix(strings.ToLower(path), ".tsx")
 }
@@ -46,12 +94,28 @@ func nodeText(node *sitter.Node, source []byte) string {
 	return node.Utf8Text(source)
 }
 
+// declarationSignature returns the source of a function declaration up to its
+// body on one line, or the whole declaration when it has no body.
+func declarationSignature(node *sitter.Node, content []byte) string {
+	end := node.EndByte()
+	if body := node.ChildByFieldName("body"); body != nil {
+		end = body.StartByte()
+	}
+	text := strings.Join(strings.Fields(string(content[node.StartByte():end])), " ")
+	return strings.TrimSuffix(text, ";")
+}
+
 func unquoteStringLiteral(raw string) string {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
 		return ""
 	}
-	unquoted, err := strconv.Unquote(raw)
+	quoted := raw
+	// JavaScript and TypeScript also quote strings with single quotes.
+	if len(raw) >= 2 && raw[0] == '\'' && raw[len(raw)-1] == '\'' {
+		quoted = `"` + strings.ReplaceAll(raw[1:len(raw)-1], `"`, `\"`) + `"`
+	}
+	unquoted, err := strconv.Unquote(quoted)
 	if err != nil {
 		return raw
 	}
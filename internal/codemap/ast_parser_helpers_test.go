@@ -0,0 +1,50 @@
+package codemap
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestParserPoolsParseConcurrently(t *testing.T) {
+	tsContent := []byte("export interface Store {}\nexport function open(): Store { return {}; }\n")
+	rustContent := []byte("pub struct Store;\npub fn open() -> Store { Store }\n")
+	wantTS, _, wantTSFuncs, _ := parseTypeScriptFileSymbols(tsContent, "src/store.ts")
+	wantRust, _, wantRustFuncs, _ := parseRustFileSymbols(rustContent)
+	if len(wantTS) == 0 || len(wantRust) == 0 {
+		t.Fatalf("expected symbols, got %v and %v", wantTS, wantRust)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 64)
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 8 {
+				types, _, funcs, _ := parseTypeScriptFileSymbols(tsContent, "src/store.ts")
+				if !reflect.DeepEqual(types, wantTS) || !reflect.DeepEqual(funcs, wantTSFuncs) {
+					errs <- "typescript"
+					return
+				}
+				types, _, funcs, _ = parseRustFileSymbols(rustContent)
+				if !reflect.DeepEqual(types, wantRust) || !reflect.DeepEqual(funcs, wantRustFuncs) {
+					errs <- "rust"
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for lang := range errs {
+		t.Fatalf("pooled %s parser returned different symbols", lang)
+	}
+}
+
+func BenchmarkParseTypeScriptFileSymbols(b *testing.B) {
+	content := []byte("export interface Store {}\nexport function open(): Store { return {}; }\n")
+	for b.Loop() {
+		parseTypeScriptFileSymbols(content, "src/store.ts")
+	}
+}
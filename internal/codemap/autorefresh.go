@@ -0,0 +1,126 @@
+package codemap
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"time"
+)
+
+// defaultRefreshQuiescence is the quiet time automatic regeneration waits
+// for when Options.RefreshQuiescence is unset.
+const defaultRefreshQuiescence = 2 * time.Second
+
+// regenLimiter decides when observed changes should trigger a regeneration,
+// so mass operations such as branch switches or rebases produce a single
+// regeneration once the tree settles instead of one per burst of writes.
+type regenLimiter struct {
+	quiescence  time.Duration // Required quiet time after the last change
+	minInterval time.Duration // Minimum time between regenerations
+
+	pending    bool
+	lastChange time.Time
+	lastRegen  time.Time
+}
+
+func newRegenLimiter(opts Options) *regenLimiter {
+	l := &regenLimiter{quiescence: opts.RefreshQuiescence, minInterval: opts.RefreshMinInterval}
+	if l.quiescence <= 0 {
+		l.quiescence = defaultRefreshQuiescence
+	}
+	if l.minInterval < 0 {
+		l.minInterval = 0
+	}
+	return l
+}
+
+// observe records a filesystem change seen at now.
+func (l *regenLimiter) observe(now time.Time) {
+	l.pending = true
+	l.lastChange = now
+}
+
+// ready reports whether a pending change may be regenerated at now.
+func (l *regenLimiter) ready(now time.Time) bool {
+	if !l.pending || now.Sub(l.lastChange) < l.quiescence {
+		return false
+	}
+	return l.lastRegen.IsZero() || now.Sub(l.lastRegen) >= l.minInterval
+}
+
+// done records a regeneration started at now.
+func (l *regenLimiter) done(now time.Time) {
+	l.pending = false
+	l.lastRegen = now
+}
+
+// treeSignature fingerprints the indexed files by path, size and mtime without
+// reading contents, so polling can cheaply notice that something changed.
+// Directory mtimes are left out because writing outputs touches the root.
+func treeSignature(ctx context.Context, root string, opts Options) (uint64, error) {
+	idx, err := buildIndex(ctx, root, opts)
+	if err != nil {
+		return 0, fmt.Errorf("build file index: %w", err)
+	}
+	h := fnv.New64a()
+	var buf [16]byte
+	for _, rec := range idx.Files {
+		_, _ = h.Write([]byte(rec.RelPath))
+		binary.LittleEndian.PutUint64(buf[:8], uint64(rec.Size))
+		binary.LittleEndian.PutUint64(buf[8:], uint64(rec.ModTimeUnixNano))
+		_, _ = h.Write(buf[:])
+	}
+	// Auxiliary files the last analysis read, such as go.mod, count too.
+	if state, err := readState(resolveStatePath(root, opts)); err == nil && state != nil {
+		statter := newAuxStatter(root)
+		for _, recorded := range state.AuxFiles {
+			current := statter.current(recorded)
+			_, _ = h.Write([]byte(current.RelPath + "\x00" + current.DirFilter + "\x00" + current.ContentHash))
+		}
+	}
+	return h.Sum64(), nil
+}
+
+// autoRefresh polls the tree every opts.AutoRefreshInterval and regenerates
+// through the service once changes have settled, until ctx is done.
+func (d *Daemon) autoRefresh(ctx context.Context) {
+	pollChanges(ctx, d.opts, d.opts.AutoRefreshInterval, func(ctx context.Context) {
+		if _, err := d.refresh(ctx, false); err != nil && ctx.Err() == nil && d.opts.Verbose {
+			fmt.Fprintf(os.Stderr, "warning: automatic refresh: %v\n", err)
+		}
+	})
+}
+
+// pollChanges compares tree signatures every interval and calls regenerate
+// once a change has settled according to the regeneration limits in opts. It
+// returns when ctx is done.
+func pollChanges(ctx context.Context, opts Options, interval time.Duration, regenerate func(context.Context)) {
+	limiter := newRegenLimiter(opts)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, err := treeSignature(ctx, opts.ProjectRoot, opts)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sig, sigErr := treeSignature(ctx, opts.ProjectRoot, opts)
+			if sigErr != nil {
+				err = sigErr
+				continue
+			}
+			if err != nil || sig != last {
+				limiter.observe(now)
+				last, err = sig, nil
+			}
+			if !limiter.ready(now) {
+				continue
+			}
+			limiter.done(now)
+			regenerate(ctx)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegenLimiterWaitsForQuiescenceAndMinInterval(t *testing.T) {
+	opts := DefaultOptions()
+	opts.RefreshQuiescence = 2 * time.Second
+	opts.RefreshMinInterval = 10 * time.Second
+	l := newRegenLimiter(opts)
+	start := time.Unix(1000, 0)
+
+	if l.ready(start) {
+		t.Fatal("expected no regeneration without changes")
+	}
+	// A burst of changes keeps pushing the quiet window out.
+	for i := 0; i < 5; i++ {
+		l.observe(start.Add(time.Duration(i) * time.Second))
+		if l.ready(start.Add(time.Duration(i)*time.Second + time.Second)) {
+			t.Fatalf("expected burst change %d to defer regeneration", i)
+		}
+	}
+	settled := start.Add(6 * time.Second)
+	if !l.ready(settled) {
+		t.Fatal("expected regeneration once the tree settled")
+	}
+	l.done(settled)
+	if l.ready(settled.Add(time.Hour)) {
+		t.Fatal("expected nothing pending after regeneration")
+	}
+
+	l.observe(settled.Add(time.Second))
+	if l.ready(settled.Add(4 * time.Second)) {
+		t.Fatal("expected min interval to hold back the next regeneration")
+	}
+	if !l.ready(settled.Add(10 * time.Second)) {
+		t.Fatal("expected regeneration after the min interval")
+	}
+}
+
+func TestDaemonAutoRefreshRegeneratesAfterChanges(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/test\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatalf("write go.mod: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatalf("write main.go: %v", err)
+	}
+	sockDir, err := os.MkdirTemp("", "cmsock")
+	if err != nil {
+		t.Fatalf("mkdir socket dir: %v", err)
+	}
+	defer os.RemoveAll(sockDir)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.SocketPath = filepath.Join(sockDir, "d.sock")
+	opts.AutoRefreshInterval = 10 * time.Millisecond
+	opts.RefreshQuiescence = 30 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewDaemon(opts).Serve(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("Serve returned error: %v", err)
+		}
+	}()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err = CallDaemon(context.Background(), opts.SocketPath, DaemonRequest{Command: DaemonCommandStatus}); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("daemon did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "util.go"), []byte("package main\n\nfunc Helper() {}\n"), 0644); err != nil {
+		t.Fatalf("write util.go: %v", err)
+	}
+	for {
+		stale, err := IsStale(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("IsStale returned error: %v", err)
+		}
+		if !stale {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the daemon to regenerate outputs on its own")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
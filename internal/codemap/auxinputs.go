@@ -0,0 +1,273 @@
+package codemap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// AuxFileState records a file or directory outside the file index that
+// analysis read, such as go.mod, CODEOWNERS or a directory scanned for
+// Dockerfiles. ContentHash hashes a file's contents, or for a directory the
+// entry names its DirFilter (see auxDirFilters) matched, and is empty when
+// the path did not exist. Size and ModTimeUnixNano are the path's metadata
+// when it was read, and for a missing path DirModTimeUnixNano is that of
+// its parent directory; staleness checks read the path again only when
+// these differ.
+type AuxFileState struct {
+	RelPath            string `json:"relPath"`
+	DirFilter          string `json:"dirFilter,omitempty"`
+	ContentHash        string `json:"contentHash,omitempty"`
+	Size               int64  `json:"size,omitempty"`
+	ModTimeUnixNano    int64  `json:"modTimeUnixNano,omitempty"`
+	DirModTimeUnixNano int64  `json:"dirModTimeUnixNano,omitempty"`
+}
+
+// auxDirFilters select the directory entries analysis looks at, so that
+// unrelated entries, such as outputs written beside them, do not count as a
+// change. A DirFilter is a key of auxDirFilters, optionally followed by ":"
+// and an argument such as the file name stem companions share.
+var auxDirFilters = map[string]func(arg, name string, isDir bool) bool{
+	"services":   func(_, name string, isDir bool) bool { return isServiceDirEntry(name, isDir) },
+	"companions": isCompanionDirEntry,
+	"manifests":  isManifestDirEntry,
+}
+
+// isManifestDirEntry reports whether name is a file matching one of the
+// comma-separated manifest names or path.Match patterns in manifests.
+func isManifestDirEntry(manifests, name string, isDir bool) bool {
+	if isDir {
+		return false
+	}
+	for _, pattern := range strings.Split(manifests, ",") {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestDirFilter returns the DirFilter that records which of manifests a
+// directory holds.
+func manifestDirFilter(manifests []string) string {
+	return "manifests:" + strings.Join(manifests, ",")
+}
+
+// resolveManifestRoot returns the root-relative directory nearest to dir,
+// itself included, holding a file matching one of manifests (names or
+// path.Match patterns), or "." when none up to the root does. The listing of
+// every directory looked at is recorded in aux, so adding or removing a
+// manifest is noticed. Results are memoized in rootsByDir.
+func resolveManifestRoot(aux *auxInputs, dir string, manifests []string, rootsByDir map[string]string) string {
+	filter := manifestDirFilter(manifests)
+	match := auxDirFilter(filter)
+	var visited []string
+	found := "."
+	for {
+		if cached, ok := rootsByDir[dir]; ok {
+			found = cached
+			break
+		}
+		visited = append(visited, dir)
+		entries, _ := aux.readDir(dir, filter)
+		if slices.ContainsFunc(entries, func(entry os.DirEntry) bool { return match(entry.Name(), entry.IsDir()) }) {
+			found = dir
+			break
+		}
+		if dir == "." {
+			break
+		}
+		dir = path.Dir(dir)
+	}
+	for _, visitedDir := range visited {
+		rootsByDir[visitedDir] = found
+	}
+	return found
+}
+
+// auxDirFilter returns the entry filter a DirFilter names, or nil to keep
+// every entry.
+func auxDirFilter(filter string) func(name string, isDir bool) bool {
+	key, arg, _ := strings.Cut(filter, ":")
+	match := auxDirFilters[key]
+	if match == nil {
+		return nil
+	}
+	return func(name string, isDir bool) bool { return match(arg, name, isDir) }
+}
+
+// auxInputs records the auxiliary files and directories one analysis reads,
+// so staleness checks notice when they change although the indexed files did
+// not. Reads of the same path are recorded once.
+type auxInputs struct {
+	root string
+	mu   sync.Mutex
+	seen map[auxKey]AuxFileState
+}
+
+type auxKey struct {
+	relPath   string
+	dirFilter string
+}
+
+func newAuxInputs(root string) *auxInputs {
+	return &auxInputs{root: root, seen: make(map[auxKey]AuxFileState)}
+}
+
+// readFile reads the file at the root-relative relPath and records it,
+// including when it does not exist, so creating it later is noticed too.
+func (a *auxInputs) readFile(relPath string) ([]byte, error) {
+	abs := filepath.Join(a.root, filepath.FromSlash(relPath))
+	state := statAuxPath(abs, AuxFileState{RelPath: relPath})
+	content, err := os.ReadFile(abs)
+	if err == nil {
+		state.ContentHash = auxContentHash(content)
+	}
+	a.record(state)
+	return content, err
+}
+
+// readDir lists the directory at the root-relative relPath and records the
+// names of the entries that the DirFilter filter matches.
+func (a *auxInputs) readDir(relPath, filter string) ([]os.DirEntry, error) {
+	abs := filepath.Join(a.root, filepath.FromSlash(relPath))
+	state := statAuxPath(abs, AuxFileState{RelPath: relPath, DirFilter: filter})
+	entries, err := os.ReadDir(abs)
+	if err == nil {
+		state.ContentHash = auxDirHash(entries, auxDirFilter(filter))
+	}
+	a.record(state)
+	return entries, err
+}
+
+// statAuxPath fills the metadata of state from abs, or from its parent
+// directory when abs does not exist. It is taken before the read, so an
+// edit racing the read leaves newer metadata that is read again on the next
+// check.
+func statAuxPath(abs string, state AuxFileState) AuxFileState {
+	info, err := os.Stat(abs)
+	if err == nil {
+		state.Size = info.Size()
+		state.ModTimeUnixNano = info.ModTime().UnixNano()
+	} else if dir, err := os.Stat(filepath.Dir(abs)); err == nil && dir.IsDir() {
+		state.DirModTimeUnixNano = dir.ModTime().UnixNano()
+	}
+	return state
+}
+
+func (a *auxInputs) record(state AuxFileState) {
+	a.mu.Lock()
+	a.seen[auxKey{state.RelPath, state.DirFilter}] = state
+	a.mu.Unlock()
+}
+
+// states returns the recorded reads sorted by path.
+func (a *auxInputs) states() []AuxFileState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.seen) == 0 {
+		return nil
+	}
+	states := make([]AuxFileState, 0, len(a.seen))
+	for _, state := range a.seen {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].RelPath != states[j].RelPath {
+			return states[i].RelPath < states[j].RelPath
+		}
+		return states[i].DirFilter < states[j].DirFilter
+	})
+	return states
+}
+
+func auxContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func auxDirHash(entries []os.DirEntry, match func(name string, isDir bool) bool) string {
+	var names []string
+	for _, entry := range entries {
+		if match != nil && !match(entry.Name(), entry.IsDir()) {
+			continue
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return auxContentHash([]byte(strings.Join(names, "\x00")))
+}
+
+// auxStatter compares recorded reads with the tree, statting each path at
+// most once, since the reads of one package share its directory.
+type auxStatter struct {
+	root  string
+	infos map[string]os.FileInfo // nil when the path could not be statted
+}
+
+func newAuxStatter(root string) *auxStatter {
+	return &auxStatter{root: root, infos: make(map[string]os.FileInfo)}
+}
+
+func (s *auxStatter) stat(relPath string) os.FileInfo {
+	info, ok := s.infos[relPath]
+	if !ok {
+		info, _ = os.Stat(filepath.Join(s.root, filepath.FromSlash(relPath)))
+		s.infos[relPath] = info
+	}
+	return info
+}
+
+// current returns the state of the file or directory recorded reads. It
+// returns recorded as is while a missing path's parent directory or an
+// existing path's size and modification time are unchanged, and reads the
+// path again otherwise.
+func (s *auxStatter) current(recorded AuxFileState) AuxFileState {
+	if recorded.ContentHash == "" && recorded.DirModTimeUnixNano != 0 {
+		if dir := s.stat(path.Dir(recorded.RelPath)); dir != nil && dir.IsDir() &&
+			dir.ModTime().UnixNano() == recorded.DirModTimeUnixNano {
+			return recorded
+		}
+	}
+	if recorded.ModTimeUnixNano != 0 {
+		if info := s.stat(recorded.RelPath); info != nil && info.IsDir() == (recorded.DirFilter != "") &&
+			info.Size() == recorded.Size && info.ModTime().UnixNano() == recorded.ModTimeUnixNano {
+			return recorded
+		}
+	}
+	aux := newAuxInputs(s.root)
+	if recorded.DirFilter != "" {
+		_, _ = aux.readDir(recorded.RelPath, recorded.DirFilter)
+	} else {
+		_, _ = aux.readFile(recorded.RelPath)
+	}
+	return aux.seen[auxKey{recorded.RelPath, recorded.DirFilter}]
+}
+
+// changed reports whether the file or directory recorded was created,
+// removed or changed since it was read. New metadata alone is no change.
+func (s *auxStatter) changed(recorded AuxFileState) bool {
+	return s.current(recorded).ContentHash != recorded.ContentHash
+}
+
+// auxFilesChanged reports whether any auxiliary file or directory recorded
+// in states was created, removed or changed since it was read.
+func auxFilesChanged(root string, states []AuxFileState) bool {
+	statter := newAuxStatter(root)
+	for _, recorded := range states {
+		if statter.changed(recorded) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package codemap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuxFilesRehashOnlyWhenMetadataChanges(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestTree(t, tmpDir, map[string]string{
+		"CODEOWNERS":     "* @core\n",
+		"deploy/app.yml": "kind: Deployment\n",
+	})
+	aux := newAuxInputs(tmpDir)
+	if _, err := aux.readFile("CODEOWNERS"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := aux.readDir("deploy", "companions:app"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := aux.readFile("go.mod"); !os.IsNotExist(err) {
+		t.Fatalf("expected go.mod to be missing, got %v", err)
+	}
+	states := aux.states()
+	if auxFilesChanged(tmpDir, states) {
+		t.Fatal("expected no change right after reading")
+	}
+	codeowners := states[0]
+
+	// Rewriting the file with the same size and modification time is not
+	// looked at again.
+	path := filepath.Join(tmpDir, "CODEOWNERS")
+	if err := os.WriteFile(path, []byte("* @team\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Unix(0, codeowners.ModTimeUnixNano)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if got := newAuxStatter(tmpDir).current(codeowners); got != codeowners {
+		t.Fatalf("expected the recorded state while metadata is unchanged, got %+v", got)
+	}
+
+	// A new modification time alone is hashed again but is no change.
+	if err := os.WriteFile(path, []byte("* @core\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	touched := mtime.Add(time.Second)
+	if err := os.Chtimes(path, touched, touched); err != nil {
+		t.Fatal(err)
+	}
+	if newAuxStatter(tmpDir).changed(codeowners) {
+		t.Fatal("expected touching CODEOWNERS to be no change")
+	}
+
+	if err := os.WriteFile(path, []byte("* @platform\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !auxFilesChanged(tmpDir, states) {
+		t.Fatal("expected editing CODEOWNERS to be a change")
+	}
+	if err := os.WriteFile(path, []byte("* @core\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestTree(t, tmpDir, map[string]string{"deploy/app.json": "{}\n"})
+	if !auxFilesChanged(tmpDir, states) {
+		t.Fatal("expected a new companion in deploy/ to be a change")
+	}
+	if err := os.Remove(filepath.Join(tmpDir, "deploy", "app.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestTree(t, tmpDir, map[string]string{"go.mod": "module example.com/app\n"})
+	if !auxFilesChanged(tmpDir, states) {
+		t.Fatal("expected creating go.mod to be a change")
+	}
+}
@@ -0,0 +1,340 @@
+package codemap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// detectBinaries finds the executables the project builds or installs:
+// Go main packages, Cargo binary targets, package.json bin entries, Python
+// console scripts and __main__.py modules, and shell scripts starting with a
+// shebang. Manifests are read in the directories of packages and their
+// parents, like detectServices, and recorded in aux; Go package clauses are
+// read through scans. Binaries of languages the index was not built for are
+// left out.
+func detectBinaries(aux *auxInputs, scans *fileScans, idx *FileIndex, packages []Package) []BinaryInfo {
+	var binaries []BinaryInfo
+	indexed := make(map[string]struct{})
+	if idx != nil {
+		for _, rec := range idx.Files {
+			indexed[rec.RelPath] = struct{}{}
+		}
+	}
+	dirs := map[string]struct{}{".": {}}
+	for _, pkg := range packages {
+		if isNestedCodemap(pkg) {
+			continue
+		}
+		for dir := packageFileDir(pkg.RelativePath); dir != "."; dir = path.Dir(dir) {
+			if _, seen := dirs[dir]; seen {
+				break
+			}
+			dirs[dir] = struct{}{}
+		}
+		if pkg.Language == languageGo && isGoMainPackage(scans, entryPath(pkg)) {
+			binaries = append(binaries, BinaryInfo{
+				Name:     binaryDirName(aux, packageFileDir(pkg.RelativePath), "go.mod"),
+				Language: languageGo,
+				Entry:    entryPath(pkg),
+				Source:   "package main",
+			})
+		}
+	}
+	for dir := range dirs {
+		binaries = append(binaries, cargoBinaries(aux, indexed, dir)...)
+		binaries = append(binaries, packageJSONBinaries(aux, dir)...)
+		binaries = append(binaries, pythonScripts(aux, dir)...)
+	}
+	if idx != nil {
+		for _, rec := range idx.Files {
+			if rec.IsTest {
+				continue
+			}
+			switch {
+			case rec.Language == languagePython && path.Base(rec.RelPath) == "__main__.py":
+				binaries = append(binaries, BinaryInfo{
+					Name:     binaryDirName(aux, path.Dir(rec.RelPath), "pyproject.toml"),
+					Language: languagePython,
+					Entry:    rec.RelPath,
+					Source:   "__main__.py",
+				})
+			case rec.Language == languageShell && hasShebang(rec.AbsPath):
+				binaries = append(binaries, BinaryInfo{
+					Name:     path.Base(rec.RelPath),
+					Language: languageShell,
+					Entry:    rec.RelPath,
+					Source:   "shebang",
+				})
+			}
+		}
+	}
+	if idx != nil && len(idx.Languages) > 0 {
+		binaries = slices.DeleteFunc(binaries, func(bin BinaryInfo) bool {
+			return !slices.Contains(idx.Languages, bin.Language)
+		})
+	}
+	sort.Slice(binaries, func(i, j int) bool {
+		a, b := binaries[i], binaries[j]
+		if a.Language != b.Language {
+			return a.Language < b.Language
+		}
+		if a.Entry != b.Entry {
+			return a.Entry < b.Entry
+		}
+		return a.Name < b.Name
+	})
+	return binaries
+}
+
+// binaryDirName names the binary built from dir after the directory, or for
+// the root after the name the root manifest declares, such as the last
+// element of the go.mod module path.
+func binaryDirName(aux *auxInputs, dir, manifest string) string {
+	if dir == "." || dir == "" {
+		return rootManifestName(aux, manifest)
+	}
+	return path.Base(dir)
+}
+
+// isGoMainPackage reports whether the indexed Go file at relPath declares
+// package main.
+func isGoMainPackage(scans *fileScans, relPath string) bool {
+	scan, ok := scans.scan(relPath)
+	return ok && scan.GoPackage == "main"
+}
+
+// hasShebang reports whether the file at absPath starts with "#!".
+func hasShebang(absPath string) bool {
+	f, err := os.Open(absPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	prefix := make([]byte, 2)
+	_, err = io.ReadFull(f, prefix)
+	return err == nil && string(prefix) == "#!"
+}
+
+// cargoBinaries returns the binary targets of the Cargo.toml in dir: its
+// [[bin]] tables plus the src/main.rs and src/bin targets Cargo discovers on
+// its own among the indexed files.
+func cargoBinaries(aux *auxInputs, indexed map[string]struct{}, dir string) []BinaryInfo {
+	source := path.Join(dir, "Cargo.toml")
+	content, err := aux.readFile(source)
+	if err != nil {
+		return nil
+	}
+	crate := ""
+	var declared []BinaryInfo
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+		if strings.HasPrefix(line, "[") {
+			section = line
+			if section == "[[bin]]" {
+				declared = append(declared, BinaryInfo{Language: languageRust, Source: source})
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.Trim(strings.TrimSpace(value), `"'`)
+		switch {
+		case section == "[package]" && key == "name":
+			crate = value
+		case section == "[[bin]]" && key == "name":
+			declared[len(declared)-1].Name = value
+		case section == "[[bin]]" && key == "path":
+			declared[len(declared)-1].Entry = path.Join(dir, value)
+		}
+	}
+
+	exists := func(rel string) bool {
+		_, ok := indexed[path.Join(dir, rel)]
+		return ok
+	}
+	seen := make(map[string]struct{})
+	var binaries []BinaryInfo
+	for _, bin := range declared {
+		if bin.Entry == "" && bin.Name != "" {
+			bin.Entry = path.Join(dir, "src/bin", bin.Name+".rs")
+			if !exists("src/bin/"+bin.Name+".rs") && exists("src/bin/"+bin.Name+"/main.rs") {
+				bin.Entry = path.Join(dir, "src/bin", bin.Name, "main.rs")
+			}
+		}
+		if bin.Name == "" {
+			bin.Name = strings.TrimSuffix(path.Base(bin.Entry), ".rs")
+		}
+		seen[bin.Entry] = struct{}{}
+		binaries = append(binaries, bin)
+	}
+	add := func(name, rel string) {
+		entry := path.Join(dir, rel)
+		if _, ok := seen[entry]; ok || !exists(rel) {
+			return
+		}
+		seen[entry] = struct{}{}
+		binaries = append(binaries, BinaryInfo{Name: name, Language: languageRust, Entry: entry, Source: source})
+	}
+	if crate == "" {
+		crate = binaryDirName(aux, dir, "")
+	}
+	add(crate, "src/main.rs")
+	binDir := path.Join(dir, "src/bin") + "/"
+	var discovered []string
+	for relPath := range indexed {
+		if rest, ok := strings.CutPrefix(relPath, binDir); ok {
+			discovered = append(discovered, rest)
+		}
+	}
+	sort.Strings(discovered)
+	for _, rest := range discovered {
+		if name, ok := strings.CutSuffix(rest, "/main.rs"); ok && !strings.Contains(name, "/") {
+			add(name, "src/bin/"+rest)
+		} else if name, ok := strings.CutSuffix(rest, ".rs"); ok && !strings.Contains(name, "/") {
+			add(name, "src/bin/"+rest)
+		}
+	}
+	return binaries
+}
+
+// packageJSONBinaries returns the bin entries of the package.json in dir,
+// either a single path named after the package or a map of names to paths.
+func packageJSONBinaries(aux *auxInputs, dir string) []BinaryInfo {
+	source := path.Join(dir, "package.json")
+	content, err := aux.readFile(source)
+	if err != nil {
+		return nil
+	}
+	var manifest struct {
+		Name string          `json:"name"`
+		Bin  json.RawMessage `json:"bin"`
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil || len(manifest.Bin) == 0 {
+		return nil
+	}
+	bins := make(map[string]string)
+	var single string
+	if err := json.Unmarshal(manifest.Bin, &single); err == nil {
+		name := manifest.Name
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		if name == "" {
+			name = binaryDirName(aux, dir, "")
+		}
+		bins[name] = single
+	} else if err := json.Unmarshal(manifest.Bin, &bins); err != nil {
+		return nil
+	}
+	var binaries []BinaryInfo
+	for name, entry := range bins {
+		language := languageJavaScript
+		if ext := path.Ext(entry); ext == ".ts" || ext == ".mts" || ext == ".cts" {
+			language = languageTypeScript
+		}
+		binaries = append(binaries, BinaryInfo{
+			Name:     name,
+			Language: language,
+			Entry:    path.Join(dir, entry),
+			Source:   source,
+		})
+	}
+	return binaries
+}
+
+// setupPyConsoleScript matches "name = module:func" entries of setup.py.
+var setupPyConsoleScript = regexp.MustCompile(`["']\s*([\w.-]+)\s*=\s*([\w.]+:[\w.]+)\s*["']`)
+
+// pythonScripts returns the console scripts declared in dir by
+// pyproject.toml ([project.scripts] or [tool.poetry.scripts]), setup.cfg
+// (console_scripts under [options.entry_points]) or setup.py. Their entry is
+// the "module:function" target.
+func pythonScripts(aux *auxInputs, dir string) []BinaryInfo {
+	var binaries []BinaryInfo
+	add := func(source, name, target string) {
+		name, target = strings.Trim(strings.TrimSpace(name), `"'`), strings.Trim(strings.TrimSpace(target), `"'`)
+		if name != "" && target != "" {
+			binaries = append(binaries, BinaryInfo{Name: name, Language: languagePython, Entry: target, Source: source})
+		}
+	}
+	read := func(name string) (string, []byte) {
+		source := path.Join(dir, name)
+		content, err := aux.readFile(source)
+		if err != nil {
+			return "", nil
+		}
+		return source, content
+	}
+
+	if source, content := read("pyproject.toml"); content != nil {
+		section := ""
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "[") {
+				section = line
+				continue
+			}
+			if section != "[project.scripts]" && section != "[tool.poetry.scripts]" {
+				continue
+			}
+			if idx := strings.Index(line, "#"); idx >= 0 {
+				line = line[:idx]
+			}
+			if name, target, ok := strings.Cut(line, "="); ok {
+				add(source, name, target)
+			}
+		}
+	}
+	if source, content := read("setup.cfg"); content != nil {
+		section, inScripts := "", false
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			raw := scanner.Text()
+			line := strings.TrimSpace(raw)
+			if strings.HasPrefix(line, "[") {
+				section, inScripts = line, false
+				continue
+			}
+			if section != "[options.entry_points]" || line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			indented := raw[0] == ' ' || raw[0] == '\t'
+			key, value, ok := strings.Cut(line, "=")
+			switch {
+			case !indented:
+				inScripts = ok && strings.TrimSpace(key) == "console_scripts"
+				if inScripts && strings.TrimSpace(value) != "" {
+					if name, target, ok := strings.Cut(value, "="); ok {
+						add(source, name, target)
+					}
+				}
+			case inScripts && ok:
+				add(source, key, value)
+			}
+		}
+	}
+	if source, content := read("setup.py"); content != nil {
+		if i := bytes.Index(content, []byte("console_scripts")); i >= 0 {
+			for _, match := range setupPyConsoleScript.FindAllSubmatch(content[i:], -1) {
+				add(source, string(match[1]), string(match[2]))
+			}
+		}
+	}
+	return binaries
+}
@@ -0,0 +1,145 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectBinaries(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                    "module example.com/app\n\ngo 1.22\n",
+		"cmd/app/main.go":           "package main\n\nfunc main() {}\n",
+		"internal/foo/foo.go":       "// Package foo does foo.\npackage foo\n",
+		"tools/rs/Cargo.toml":       "[package]\nname = \"rs-tool\"\n\n[[bin]]\nname = \"gen\"\npath = \"src/gen.rs\"\n",
+		"tools/rs/src/main.rs":      "fn main() {}\n",
+		"tools/rs/src/gen.rs":       "fn main() {}\n",
+		"tools/rs/src/bin/check.rs": "fn main() {}\n",
+		"web/package.json":          `{"name": "@acme/web", "bin": "cli.js"}` + "\n",
+		"web/cli.js":                "console.log('hi');\n",
+		"py/pyproject.toml":         "[project]\nname = \"tool\"\n\n[project.scripts]\ntool = \"tool.cli:main\" # entry\n",
+		"py/tool/__init__.py":       "",
+		"py/tool/__main__.py":       "from tool.cli import main\nmain()\n",
+		"py/tool/cli.py":            "def main():\n    pass\n",
+		"scripts/build.sh":          "#!/bin/sh\necho build\n",
+		"scripts/lib.sh":            "echo sourced\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	want := []BinaryInfo{
+		{Name: "app", Language: languageGo, Entry: "cmd/app/main.go", Source: "package main"},
+		{Name: "web", Language: languageJavaScript, Entry: "web/cli.js", Source: "web/package.json"},
+		{Name: "tool", Language: languagePython, Entry: "py/tool/__main__.py", Source: "__main__.py"},
+		{Name: "tool", Language: languagePython, Entry: "tool.cli:main", Source: "py/pyproject.toml"},
+		{Name: "check", Language: languageRust, Entry: "tools/rs/src/bin/check.rs", Source: "tools/rs/Cargo.toml"},
+		{Name: "gen", Language: languageRust, Entry: "tools/rs/src/gen.rs", Source: "tools/rs/Cargo.toml"},
+		{Name: "rs-tool", Language: languageRust, Entry: "tools/rs/src/main.rs", Source: "tools/rs/Cargo.toml"},
+		{Name: "build.sh", Language: languageShell, Entry: "scripts/build.sh", Source: "shebang"},
+	}
+	if !reflect.DeepEqual(cm.Binaries, want) {
+		t.Fatalf("Binaries =\n%+v\nwant\n%+v", cm.Binaries, want)
+	}
+
+	content, err := Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if !strings.Contains(content, "## Binaries / Entry Points\n\n| Binary | Language | Entry | Declared By |\n|--------|----------|-------|-------------|\n| app | go | cmd/app/main.go | package main |\n") {
+		t.Fatalf("expected a Binaries / Entry Points section in:\n%s", content)
+	}
+}
+
+func TestGoMainPackageFollowsEditsThroughCachedScans(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestTree(t, tmpDir, map[string]string{
+		"go.mod":          "module example.com/app\n\ngo 1.22\n",
+		"cmd/app/main.go": "package main\n\nfunc main() {}\n",
+	})
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	binaries := func() []BinaryInfo {
+		t.Helper()
+		cm, err := Generate(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Generate returned error: %v", err)
+		}
+		return cm.Binaries
+	}
+	want := []BinaryInfo{{Name: "app", Language: languageGo, Entry: "cmd/app/main.go", Source: "package main"}}
+	if got := binaries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Binaries = %+v, want %+v", got, want)
+	}
+	if got := binaries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Binaries from cached scans = %+v, want %+v", got, want)
+	}
+	writeTestTree(t, tmpDir, map[string]string{"cmd/app/main.go": "package app\n\nfunc Run() {}\n"})
+	if got := binaries(); len(got) != 0 {
+		t.Fatalf("expected no binary once the package is no longer main, got %+v", got)
+	}
+}
+
+func TestBinaryManifestChangeMakesOutputsStale(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":            "module example.com/relay\n\ngo 1.22\n",
+		"main.go":           "package main\n\nfunc main() {}\n",
+		"py/pyproject.toml": "[project]\nname = \"tool\"\n",
+		"py/tool/cli.py":    "def main():\n    pass\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	// The root binary is named after the module, not the checkout directory.
+	want := []BinaryInfo{{Name: "relay", Language: languageGo, Entry: "main.go", Source: "package main"}}
+	if !reflect.DeepEqual(cm.Binaries, want) {
+		t.Fatalf("Binaries = %+v, want %+v", cm.Binaries, want)
+	}
+
+	pyproject := "[project]\nname = \"tool\"\n\n[project.scripts]\ntool = \"tool.cli:main\"\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "py", "pyproject.toml"), []byte(pyproject), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+		t.Fatalf("IsStale after changing pyproject.toml = %v, %v; want true", stale, err)
+	}
+	cm, generated, err := EnsureUpToDate(context.Background(), opts)
+	if err != nil || !generated {
+		t.Fatalf("EnsureUpToDate = %v, %v; want a regeneration", generated, err)
+	}
+	want = append(want, BinaryInfo{Name: "tool", Language: languagePython, Entry: "tool.cli:main", Source: "py/pyproject.toml"})
+	if !reflect.DeepEqual(cm.Binaries, want) {
+		t.Fatalf("Binaries after regenerating = %+v, want %+v", cm.Binaries, want)
+	}
+}
+
+func TestPythonScriptsFromSetupFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"setup.cfg": "[metadata]\nname = tool\n\n[options.entry_points]\nconsole_scripts =\n    tool = tool.cli:main\n    tool-admin = tool.admin:run\ngui_scripts =\n    tool-gui = tool.gui:main\n",
+		"setup.py":  "from setuptools import setup\n\nsetup(\n    entry_points={\"console_scripts\": [\"legacy=tool.legacy:main\"]},\n    install_requires=[\"requests==2.0\"],\n)\n",
+	}
+	writeTestTree(t, tmpDir, files)
+	var names []string
+	for _, bin := range pythonScripts(newAuxInputs(tmpDir), ".") {
+		names = append(names, bin.Name+"="+bin.Entry+"@"+bin.Source)
+	}
+	want := []string{"tool=tool.cli:main@setup.cfg", "tool-admin=tool.admin:run@setup.cfg", "legacy=tool.legacy:main@setup.py"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("scripts = %v, want %v", names, want)
+	}
+}
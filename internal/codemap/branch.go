@@ -0,0 +1,40 @@
+package codemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// currentGitBranch returns the branch checked out in the work tree containing
+// root, or "" for detached HEADs and directories outside a repository.
+func currentGitBranch(root string) string {
+	_, gitDir := findGitRepository(root)
+	if gitDir == "" {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	branch, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "ref: refs/heads/")
+	if !ok {
+		return ""
+	}
+	return branch
+}
+
+// branchStatePath inserts a filename-safe form of branch before the state
+// file extension, e.g. ".codemap.state.json" -> ".codemap.state.feature-x.json".
+func branchStatePath(statePath, branch string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, branch)
+	ext := filepath.Ext(statePath)
+	return strings.TrimSuffix(statePath, ext) + "." + safe + ext
+}
@@ -0,0 +1,53 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBranchStateKeysCachesByBranch(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, ".git"), 0755); err != nil {
+		t.Fatalf("mkdir .git: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("write main.go: %v", err)
+	}
+	setHead := func(head string) {
+		if err := os.WriteFile(filepath.Join(tmpDir, ".git", "HEAD"), []byte(head+"\n"), 0644); err != nil {
+			t.Fatalf("write HEAD: %v", err)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.BranchState = true
+
+	setHead("ref: refs/heads/feature/x")
+	if got, want := resolveStatePath(tmpDir, opts), filepath.Join(tmpDir, ".codemap.state.feature-x.json"); got != want {
+		t.Fatalf("state path = %q, want %q", got, want)
+	}
+	if got, want := resolveAnalysisStatePath(tmpDir, opts), filepath.Join(tmpDir, ".codemap.state.feature-x.analysis.json"); got != want {
+		t.Fatalf("analysis path = %q, want %q", got, want)
+	}
+	if _, _, err := EnsureUpToDate(context.Background(), opts); err != nil {
+		t.Fatalf("EnsureUpToDate returned error: %v", err)
+	}
+
+	setHead("ref: refs/heads/main")
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	for _, name := range []string{".codemap.state.feature-x.json", ".codemap.state.main.json", ".codemap.state.main.analysis.json"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+	}
+
+	setHead("0123456789abcdef0123456789abcdef01234567")
+	if got, want := resolveStatePath(tmpDir, opts), filepath.Join(tmpDir, ".codemap.state.json"); got != want {
+		t.Fatalf("detached state path = %q, want %q", got, want)
+	}
+}
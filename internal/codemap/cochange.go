@@ -0,0 +1,167 @@
+package codemap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultCoChangeCommits is how much history is scanned when
+	// Options.CoChangeCommits is unset.
+	defaultCoChangeCommits = 500
+	// coChangeMaxPackagesPerCommit skips sweeping commits (renames, formatting)
+	// that would couple unrelated packages.
+	coChangeMaxPackagesPerCommit = 20
+	// coChangeMinCommits is the minimum number of shared commits for a hint.
+	coChangeMinCommits = 2
+	// maxCoChangePartners bounds hints per package.
+	maxCoChangePartners = 3
+)
+
+// recentCommits returns the files changed by each of the
+// Options.CoChangeCommits most recent commits under root.
+func recentCommits(ctx context.Context, root string, opts Options) ([][]string, error) {
+	limit := opts.CoChangeCommits
+	if limit <= 0 {
+		limit = defaultCoChangeCommits
+	}
+	return gitChangedFilesByCommit(ctx, root, limit)
+}
+
+// assignCoChanges records, per package, the packages most frequently changed
+// in the same commits.
+func assignCoChanges(commits [][]string, packages []Package) {
+	pkgPaths := make(map[string]struct{}, len(packages))
+	for _, pkg := range packages {
+		pkgPaths[pkg.RelativePath] = struct{}{}
+	}
+
+	pairs := make(map[[2]string]int)
+	for _, files := range commits {
+		touched := make(map[string]struct{})
+		for _, file := range files {
+			if pkgPath, ok := owningPackagePath(file, pkgPaths); ok {
+				touched[pkgPath] = struct{}{}
+			}
+		}
+		if len(touched) < 2 || len(touched) > coChangeMaxPackagesPerCommit {
+			continue
+		}
+
+		sortedTouched := make([]string, 0, len(touched))
+		for pkgPath := range touched {
+			sortedTouched = append(sortedTouched, pkgPath)
+		}
+		sort.Strings(sortedTouched)
+		for i := range sortedTouched {
+			for j := i + 1; j < len(sortedTouched); j++ {
+				pairs[[2]string{sortedTouched[i], sortedTouched[j]}]++
+			}
+		}
+	}
+
+	type partner struct {
+		path  string
+		count int
+	}
+	partners := make(map[string][]partner)
+	for pair, count := range pairs {
+		if count < coChangeMinCommits {
+			continue
+		}
+		partners[pair[0]] = append(partners[pair[0]], partner{path: pair[1], count: count})
+		partners[pair[1]] = append(partners[pair[1]], partner{path: pair[0], count: count})
+	}
+
+	for i := range packages {
+		list := partners[packages[i].RelativePath]
+		sort.Slice(list, func(a, b int) bool {
+			if list[a].count != list[b].count {
+				return list[a].count > list[b].count
+			}
+			return list[a].path < list[b].path
+		})
+		if len(list) > maxCoChangePartners {
+			list = list[:maxCoChangePartners]
+		}
+		var coChanged []string
+		for _, p := range list {
+			coChanged = append(coChanged, p.path)
+		}
+		packages[i].CoChanged = coChanged
+	}
+}
+
+// gitChangedFilesByCommit lists files changed by each of the last limit
+// non-merge commits, relative to root.
+func gitChangedFilesByCommit(ctx context.Context, root string, limit int) ([][]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "log", "--no-merges", "--relative", "--name-only",
+		"--format=%x1e", "-n", strconv.Itoa(limit))
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git log: %s", msg)
+		}
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+
+	var commits [][]string
+	for _, record := range strings.Split(string(out), "\x1e") {
+		var files []string
+		for _, line := range strings.Split(record, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				files = append(files, line)
+			}
+		}
+		if len(files) > 0 {
+			commits = append(commits, files)
+		}
+	}
+	return commits, nil
+}
+
+// owningPackagePath maps a file to the deepest package directory containing it.
+func owningPackagePath(file string, pkgPaths map[string]struct{}) (string, bool) {
+	dir := path.Dir(file)
+	for {
+		if _, ok := pkgPaths[dir]; ok {
+			return dir, true
+		}
+		if dir == "." {
+			return "", false
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+type coChangeRow struct {
+	Name     string
+	Packages []string
+}
+
+// coChangeRows lists co-change hints once per package path; packages of
+// different languages sharing a directory carry identical hints.
+func coChangeRows(packages []Package) []coChangeRow {
+	var rows []coChangeRow
+	seen := make(map[string]struct{})
+	for _, pkg := range packages {
+		if len(pkg.CoChanged) == 0 {
+			continue
+		}
+		if _, ok := seen[pkg.RelativePath]; ok {
+			continue
+		}
+		seen[pkg.RelativePath] = struct{}{}
+		rows = append(rows, coChangeRow{Name: pkg.RelativePath, Packages: pkg.CoChanged})
+	}
+	return rows
+}
@@ -0,0 +1,72 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeCoChangeFromGitHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	tmpDir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = tmpDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(rel, content string) {
+		t.Helper()
+		abs := filepath.Join(tmpDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+			t.Fatalf("mkdir %s: %v", rel, err)
+		}
+		if err := os.WriteFile(abs, []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+
+	git("init", "-q")
+	write("go.mod", "module example.com/test\n\ngo 1.22\n")
+	write("api/api.go", "package api\n")
+	write("store/store.go", "package store\n")
+	write("util/util.go", "package util\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "init")
+	for i, body := range []string{"// one\n", "// two\n"} {
+		write("api/api.go", "package api\n"+body)
+		write("store/store.go", "package store\n"+body)
+		git("commit", "-q", "-am", "change "+string(rune('a'+i)))
+	}
+	write("util/util.go", "package util\n// solo\n")
+	git("commit", "-q", "-am", "solo")
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.CoChange = true
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+
+	coChanged := make(map[string][]string)
+	for _, pkg := range cm.Packages {
+		coChanged[pkg.RelativePath] = pkg.CoChanged
+	}
+	if !reflect.DeepEqual(coChanged["api"], []string{"store"}) || !reflect.DeepEqual(coChanged["store"], []string{"api"}) {
+		t.Fatalf("expected api and store to be coupled, got %v", coChanged)
+	}
+	if len(coChanged["util"]) != 0 {
+		t.Fatalf("expected no coupling for util, got %v", coChanged["util"])
+	}
+	if len(cm.Warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", cm.Warnings)
+	}
+}
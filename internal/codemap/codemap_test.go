@@ -4,8 +4,10 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestAnalyze(t *testing.T) {
@@ -79,6 +81,45 @@ func NewBar(name string) *Bar {
 	}
 }
 
+func TestAnalyzeAttributesExternalTestPackage(t *testing.T) {
+	tmpDir := t.TempDir()
+	pkgDir := filepath.Join(tmpDir, "foo")
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"go.mod":          "module example.com/test\n\ngo 1.21\n",
+		"foo/foo.go":      "// Package foo does things.\npackage foo\n\n// Bar is real.\ntype Bar struct{}\n",
+		"foo/foo_test.go": "// Package foo_test exercises foo.\npackage foo_test\n\nimport \"example.com/test/foo\"\n\n// Fixture wraps a Bar.\ntype Fixture struct{ B foo.Bar }\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	cm, err := Analyze(context.Background(), Options{ProjectRoot: tmpDir, LargePackageFiles: 1, IncludeTests: true})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if len(cm.Packages) != 1 {
+		t.Fatalf("expected 1 package, got %d", len(cm.Packages))
+	}
+	pkg := cm.Packages[0]
+	if pkg.FileCount != 2 || pkg.EntryPoint != "foo.go" || pkg.Purpose != "Package foo does things." {
+		t.Fatalf("unexpected package: files=%d entry=%q purpose=%q", pkg.FileCount, pkg.EntryPoint, pkg.Purpose)
+	}
+	if len(pkg.Imports) != 0 {
+		t.Fatalf("expected self import from foo_test to be dropped, got %v", pkg.Imports)
+	}
+	want := []TypeInfo{
+		{Name: "Bar", Kind: "struct", Comment: "Bar is real."},
+		{Name: "Fixture", Kind: "struct", Comment: "Fixture wraps a Bar.", IsTestOnly: true},
+	}
+	if !reflect.DeepEqual(pkg.ExportedTypes, want) {
+		t.Fatalf("unexpected exported types: %+v", pkg.ExportedTypes)
+	}
+	if len(pkg.Files) != 2 || pkg.Files[0].IsTestOnly || !pkg.Files[1].IsTestOnly {
+		t.Fatalf("unexpected files: %+v", pkg.Files)
+	}
+}
+
 func TestComputeHash(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -244,6 +285,145 @@ func TestRenderPaths(t *testing.T) {
 	}
 }
 
+func TestRenderersHonorPurposeLength(t *testing.T) {
+	cm := &Codemap{
+		ContentHash: "abc123",
+		Packages: []Package{
+			{
+				RelativePath: "internal/foo",
+				Purpose:      "Verarbeitet Bestellungen für Kunden",
+				EntryPoint:   "foo.go",
+			},
+		},
+	}
+
+	paths, err := PathsRenderer{PurposeLength: 15}.Render(cm)
+	if err != nil {
+		t.Fatalf("paths render failed: %v", err)
+	}
+	if !strings.Contains(paths, "internal/foo/foo.go\tVerarbeitet ...\n") {
+		t.Fatalf("expected truncated paths purpose:\n%s", paths)
+	}
+
+	markdown, err := MarkdownRenderer{PurposeLength: 30}.Render(cm)
+	if err != nil {
+		t.Fatalf("markdown render failed: %v", err)
+	}
+	if !strings.Contains(markdown, "| Verarbeitet Bestellungen fü... |") {
+		t.Fatalf("expected truncated markdown purpose:\n%s", markdown)
+	}
+}
+
+func TestMarkdownRendererTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+	layout := "<!-- codemap-hash: {{.ContentHash}} -->\n{{range .Packages}}- {{.RelativePath}} ({{entryPath .}}): {{truncate .Purpose 10}} [{{.LineCount}} lines]\n{{end}}"
+	if err := os.WriteFile(filepath.Join(tmpDir, "codemap.md.tmpl"), []byte(layout), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "nohash.md.tmpl"), []byte("# Packages\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cm := &Codemap{
+		ContentHash: "abc123",
+		Packages: []Package{
+			{RelativePath: "internal/foo", Purpose: "Foo functionality", EntryPoint: "foo.go", LineCount: 42},
+		},
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.MarkdownTemplate = "codemap.md.tmpl"
+	markdown, err := newMarkdownRenderer(opts).Render(cm)
+	if err != nil {
+		t.Fatalf("markdown render failed: %v", err)
+	}
+	want := "<!-- codemap-hash: abc123 -->\n- internal/foo (internal/foo/foo.go): Foo fun... [42 lines]\n"
+	if markdown != want {
+		t.Fatalf("unexpected templated markdown:\n%q\nwant:\n%q", markdown, want)
+	}
+
+	opts.MarkdownTemplate = "nohash.md.tmpl"
+	if _, err := newMarkdownRenderer(opts).Render(cm); err == nil || !strings.Contains(err.Error(), "codemap-hash") {
+		t.Fatalf("expected missing hash header error, got %v", err)
+	}
+	opts.MarkdownTemplate = "missing.md.tmpl"
+	if _, err := newMarkdownRenderer(opts).Render(cm); err == nil {
+		t.Fatalf("expected error for a missing template")
+	}
+}
+
+func TestMarkdownTemplateChangeMakesOutputsStale(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":               "module example.com/test\n\ngo 1.22\n",
+		"foo/foo.go":           "// Package foo does foo.\npackage foo\n",
+		"docs/codemap.md.tmpl": "<!-- codemap-hash: {{.ContentHash}} -->\n{{range .Packages}}- {{.RelativePath}}\n{{end}}",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	ctx := context.Background()
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.MarkdownTemplate = "docs/codemap.md.tmpl"
+	cm, err := Generate(ctx, opts)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	// The template is an input of codemap, not part of the project map.
+	if len(cm.Packages) != 1 || cm.Packages[0].RelativePath != "foo" {
+		t.Fatalf("expected only the foo package, got %+v", cm.Packages)
+	}
+	if stale, err := IsStale(ctx, opts); err != nil || stale {
+		t.Fatalf("IsStale with the same template = %v, %v; want false", stale, err)
+	}
+
+	updated := "<!-- codemap-hash: {{.ContentHash}} -->\n{{range .Packages}}* {{.RelativePath}}\n{{end}}"
+	if err := os.WriteFile(filepath.Join(tmpDir, "docs", "codemap.md.tmpl"), []byte(updated), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := IsStale(ctx, opts); err != nil || !stale {
+		t.Fatalf("IsStale after editing the template = %v, %v; want true", stale, err)
+	}
+	if _, generated, err := EnsureUpToDate(ctx, opts); err != nil || !generated {
+		t.Fatalf("EnsureUpToDate = %v, %v; want a regeneration", generated, err)
+	}
+	markdown, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(markdown), "* foo\n") {
+		t.Fatalf("expected the edited template to be rendered:\n%s", markdown)
+	}
+
+	opts.MarkdownTemplate = ""
+	if stale, err := IsStale(ctx, opts); err != nil || !stale {
+		t.Fatalf("IsStale after dropping the template = %v, %v; want true", stale, err)
+	}
+}
+
+func TestRenderPathsMini(t *testing.T) {
+	cm := &Codemap{
+		ContentHash: "abc123",
+		Warnings:    []string{"partial"},
+		Packages: []Package{
+			{
+				RelativePath: "internal/foo",
+				Purpose:      "Foo functionality",
+				EntryPoint:   "foo.go",
+			},
+		},
+	}
+
+	content := RenderPathsMini(cm)
+	want := "# codemap-hash: abc123\ninternal/foo\tinternal/foo/foo.go\n"
+	if content != want {
+		t.Fatalf("unexpected mini paths output:\n%q\nwant:\n%q", content, want)
+	}
+	if hash := parseHashLine(strings.SplitN(content, "\n", 2)[0]); hash != "abc123" {
+		t.Fatalf("expected mini header to be parseable, got %q", hash)
+	}
+}
+
 func TestExtractFirstSentence(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -254,6 +434,16 @@ func TestExtractFirstSentence(t *testing.T) {
 		{"First\nSecond", "First"},
 		{"", ""},
 		{strings.Repeat("a", 150), strings.Repeat("a", 100) + "..."},
+		{strings.Repeat("é", 150), strings.Repeat("é", 100) + "..."},
+		{"Parses configs, e.g. YAML and TOML. Other text.", "Parses configs, e.g. YAML and TOML."},
+		{"Speaks the v1.2 protocol. Other text.", "Speaks the v1.2 protocol."},
+		{"Targets v1.2. Other text.", "Targets v1.2."},
+		{"Mirrors https://example.com/docs.html for offline use. Other text.", "Mirrors https://example.com/docs.html for offline use."},
+		{"Splits on `. ` boundaries. Other text.", "Splits on `. ` boundaries."},
+		{"Caches users, sessions, etc. for the API. Other text.", "Caches users, sessions, etc. for the API."},
+		{"Caches users, sessions, etc. Other text.", "Caches users, sessions, etc."},
+		{"Why bother? Because.", "Why bother?"},
+		{"Reads config.yaml (i.e. the defaults).", "Reads config.yaml (i.e. the defaults)."},
 	}
 
 	for _, tt := range tests {
@@ -273,6 +463,8 @@ func TestTruncate(t *testing.T) {
 		{"short", 10, "short"},
 		{"this is longer", 10, "this is..."},
 		{"exactly10!", 10, "exactly10!"},
+		{"überprüfung der daten", 10, "überprü..."},
+		{"日本語のコメントです", 8, "日本語のコ..."},
 	}
 
 	for _, tt := range tests {
@@ -324,3 +516,57 @@ func TestMatchDoubleGlob(t *testing.T) {
 		t.Errorf("expected 2 matches, got %d", len(matches))
 	}
 }
+
+func TestGenerateHonorsSourceDateEpoch(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/test\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatalf("write go.mod: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatalf("write main.go: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if want := time.Unix(1700000000, 0).UTC(); !cm.GeneratedAt.Equal(want) {
+		t.Fatalf("expected GeneratedAt %v, got %v", want, cm.GeneratedAt)
+	}
+	first, err := os.ReadFile(filepath.Join(tmpDir, opts.OutputPath))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if !strings.Contains(string(first), "<!-- Generated: 2023-11-14 22:13:20 UTC -->") {
+		t.Fatalf("expected pinned timestamp in output:\n%s", first)
+	}
+
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("second Generate returned error: %v", err)
+	}
+	second, err := os.ReadFile(filepath.Join(tmpDir, opts.OutputPath))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(first) != string(second) {
+		t.Fatalf("expected byte-identical outputs:\n%s\n---\n%s", first, second)
+	}
+}
+
+// writeTestTree writes files, keyed by slash-separated paths relative to
+// root, creating parent directories as needed.
+func writeTestTree(t testing.TB, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package codemap
+
+import (
+	"path"
+	"strings"
+)
+
+// codeOwnersLocations lists where GitHub and GitLab look for CODEOWNERS, in
+// the order they are searched.
+var codeOwnersLocations = []string{
+	".github/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+	".gitlab/CODEOWNERS",
+}
+
+// codeOwnersRule is one pattern line of a CODEOWNERS file. A rule without
+// owners explicitly leaves matching paths unowned.
+type codeOwnersRule struct {
+	pattern string
+	rule    ignoreRule
+	owners  []string
+}
+
+// loadCodeOwners parses the first CODEOWNERS file found under the root of
+// aux. A missing or unreadable file yields no rules. Every location searched
+// is recorded, so adding a file at an earlier one is noticed too.
+func loadCodeOwners(aux *auxInputs) []codeOwnersRule {
+	for _, name := range codeOwnersLocations {
+		content, err := aux.readFile(name)
+		if err == nil {
+			return parseCodeOwners(string(content))
+		}
+	}
+	return nil
+}
+
+// parseCodeOwners reads GitHub and GitLab CODEOWNERS syntax: a gitignore
+// style pattern followed by owners, with comments and GitLab section headers
+// ("[Section]" or "^[Section]") skipped.
+func parseCodeOwners(content string) []codeOwnersRule {
+	var rules []codeOwnersRule
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[") || strings.HasPrefix(fields[0], "^[") {
+			continue
+		}
+		rule, ok := parseIgnoreRule(fields[0], "")
+		if !ok || rule.negate {
+			continue
+		}
+		rules = append(rules, codeOwnersRule{pattern: fields[0], rule: rule, owners: fields[1:]})
+	}
+	return rules
+}
+
+// dirOwners returns the owners of directory relDir: those of the last rule
+// matching the directory or one of its parents, as a pattern for a directory
+// covers everything inside it. The project root is owned by catch-all rules
+// such as "*".
+func dirOwners(rules []codeOwnersRule, relDir string) []string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if relDir == "." {
+			switch rules[i].pattern {
+			case "*", "/*", "**", "/**":
+				return rules[i].owners
+			}
+			continue
+		}
+		for dir := relDir; dir != "."; dir = path.Dir(dir) {
+			if rules[i].rule.re.MatchString(dir) {
+				return rules[i].owners
+			}
+		}
+	}
+	return nil
+}
+
+// assignPackageOwners sets the CODEOWNERS owners of each package's directory.
+func assignPackageOwners(packages []Package, rules []codeOwnersRule) {
+	if len(rules) == 0 {
+		return
+	}
+	for i := range packages {
+		packages[i].Owners = dirOwners(rules, packageFileDir(packages[i].RelativePath))
+	}
+}
+
+// hasPackageOwners reports whether any package has CODEOWNERS owners, so the
+// Owners column is only rendered for projects that use CODEOWNERS.
+func hasPackageOwners(packages []Package) bool {
+	for _, pkg := range packages {
+		if len(pkg.Owners) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package codemap
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// maxCompanions bounds how many sibling files are listed per entry point.
+const maxCompanions = 5
+
+// assignCompanions records, for each package entry point, sibling files that
+// share its naming stem (foo.go, foo_test.go, foo.md; index.ts, index.test.ts,
+// index.css). These usually change together with the entry file. Siblings are
+// listed through aux, so adding or removing one makes the outputs stale.
+func assignCompanions(aux *auxInputs, packages []Package) {
+	for i := range packages {
+		pkg := &packages[i]
+		if pkg.EntryPoint == "" || isNestedCodemap(*pkg) {
+			continue
+		}
+		entryRel := entryPath(*pkg)
+		entryName := path.Base(entryRel)
+		stem := companionStem(entryName)
+		if stem == "" {
+			continue
+		}
+		// Companion paths are relative to the package, like EntryPoint.
+		prefix := strings.TrimSuffix(pkg.EntryPoint, entryName)
+		var companions []string
+		for _, name := range listCompanionCandidates(aux, path.Dir(entryRel), stem) {
+			if name == entryName {
+				continue
+			}
+			companions = append(companions, prefix+name)
+			if len(companions) == maxCompanions {
+				break
+			}
+		}
+		pkg.Companions = companions
+	}
+}
+
+// listCompanionCandidates returns the sorted names of the files in dir whose
+// stem is stem.
+func listCompanionCandidates(aux *auxInputs, dir, stem string) []string {
+	entries, err := aux.readDir(dir, "companions:"+stem)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if isCompanionDirEntry(stem, entry.Name(), entry.IsDir()) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isCompanionDirEntry reports whether a directory entry is a visible file
+// with the naming stem stem.
+func isCompanionDirEntry(stem, name string, isDir bool) bool {
+	return !isDir && !strings.HasPrefix(name, ".") && companionStem(name) == stem
+}
+
+// companionStem reduces a file name to the stem shared by related files:
+// the text before the first dot, minus test/spec affixes.
+func companionStem(name string) string {
+	stem := strings.ToLower(name)
+	if dot := strings.Index(stem, "."); dot >= 0 {
+		stem = stem[:dot]
+	}
+	for _, suffix := range []string{"_test", "_spec", "-test", "-spec"} {
+		stem = strings.TrimSuffix(stem, suffix)
+	}
+	stem = strings.TrimPrefix(stem, "test_")
+	return stem
+}
+
+func companionPaths(pkg Package) []string {
+	paths := make([]string, 0, len(pkg.Companions))
+	for _, companion := range pkg.Companions {
+		paths = append(paths, entryPath(Package{RelativePath: pkg.RelativePath, EntryPoint: companion}))
+	}
+	return paths
+}
+
+func hasCompanions(packages []Package) bool {
+	for _, pkg := range packages {
+		if len(pkg.Companions) > 0 {
+			return true
+		}
+	}
+	return false
+}
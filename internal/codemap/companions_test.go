@@ -0,0 +1,112 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompanionStem(t *testing.T) {
+	tests := map[string]string{
+		"foo.go":           "foo",
+		"foo_test.go":      "foo",
+		"index.test.ts":    "index",
+		"index.module.css": "index",
+		"test_utils.py":    "utils",
+		"Button.spec.tsx":  "button",
+	}
+	for name, want := range tests {
+		if got := companionStem(name); got != want {
+			t.Fatalf("companionStem(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestAnalyzeAssignsEntryCompanions(t *testing.T) {
+	tmpDir := t.TempDir()
+	pkgDir := filepath.Join(tmpDir, "store")
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatalf("mkdir store: %v", err)
+	}
+	files := map[string]string{
+		"go.mod":              "module example.com/test\n\ngo 1.22\n",
+		"store/store.go":      "// Package store persists records.\npackage store\n\ntype Store struct{}\n",
+		"store/store_test.go": "package store\n",
+		"store/store.md":      "# Store\n",
+		"store/other.go":      "package store\n",
+	}
+	for rel, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, filepath.FromSlash(rel)), []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	if len(cm.Packages) != 1 || cm.Packages[0].EntryPoint != "store.go" {
+		t.Fatalf("unexpected packages: %+v", cm.Packages)
+	}
+	if want := []string{"store.md", "store_test.go"}; !reflect.DeepEqual(cm.Packages[0].Companions, want) {
+		t.Fatalf("unexpected companions: got %v want %v", cm.Packages[0].Companions, want)
+	}
+
+	content, err := Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if !strings.Contains(content, "| store/store.go | store/store.md, store/store_test.go |") {
+		t.Fatalf("expected companions section in output:\n%s", content)
+	}
+}
+
+func TestCompanionChangeMakesOutputsStale(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "store"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"go.mod":         "module example.com/test\n\ngo 1.22\n",
+		"store/store.go": "// Package store persists records.\npackage store\n",
+	}
+	for rel, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, filepath.FromSlash(rel)), []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+
+	ctx := context.Background()
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	if _, err := Generate(ctx, opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	// store.md is not indexed, so only the companion listing notices it.
+	if err := os.WriteFile(filepath.Join(tmpDir, "store", "store.md"), []byte("# Store\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := IsStale(ctx, opts); err != nil || !stale {
+		t.Fatalf("IsStale after adding a companion = %v, %v; want true", stale, err)
+	}
+	cm, generated, err := EnsureUpToDate(ctx, opts)
+	if err != nil || !generated {
+		t.Fatalf("EnsureUpToDate = %v, %v; want a regeneration", generated, err)
+	}
+	if want := []string{"store.md"}; len(cm.Packages) != 1 || !reflect.DeepEqual(cm.Packages[0].Companions, want) {
+		t.Fatalf("unexpected companions after regenerating: %+v", cm.Packages)
+	}
+
+	// Files with another stem are not companions and change nothing.
+	if err := os.WriteFile(filepath.Join(tmpDir, "store", "notes.txt"), []byte("todo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := IsStale(ctx, opts); err != nil || stale {
+		t.Fatalf("IsStale after adding an unrelated file = %v, %v; want false", stale, err)
+	}
+}
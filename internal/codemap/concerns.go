@@ -0,0 +1,140 @@
+package codemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ParseConcernFlag parses a "name=pattern1,pattern2" concern definition as
+// accepted by the -concern flag.
+func ParseConcernFlag(value string) (ConcernDef, error) {
+	name, patterns, ok := strings.Cut(value, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return ConcernDef{}, fmt.Errorf("invalid concern %q (want name=pattern1,pattern2)", value)
+	}
+	def := ConcernDef{Name: name}
+	for _, pattern := range strings.Split(patterns, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			def.Patterns = append(def.Patterns, pattern)
+		}
+	}
+	if len(def.Patterns) == 0 {
+		return ConcernDef{}, fmt.Errorf("concern %q has no patterns", name)
+	}
+	return def, nil
+}
+
+// LoadConcernFile reads concern definitions from a JSON file holding an array
+// of objects with "name", "patterns" and optional "exampleLimit" and "note".
+func LoadConcernFile(path string) ([]ConcernDef, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var defs []ConcernDef
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, def := range defs {
+		if strings.TrimSpace(def.Name) == "" || len(def.Patterns) == 0 {
+			return nil, fmt.Errorf("parse %s: concern %d needs a name and at least one pattern", path, i+1)
+		}
+	}
+	return defs, nil
+}
+
+// MergeConcernDefs extends base with extra. A definition in extra replaces
+// the base definition of the same name (compared case-insensitively) in
+// place; other definitions are appended in order.
+func MergeConcernDefs(base, extra []ConcernDef) []ConcernDef {
+	merged := append([]ConcernDef(nil), base...)
+	for _, def := range extra {
+		replaced := false
+		for i := range merged {
+			if strings.EqualFold(merged[i].Name, def.Name) {
+				merged[i] = def
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, def)
+		}
+	}
+	return merged
+}
+
+// concernDetails reports whether any concern carries a note or examples
+// worth listing below the summary table.
+func concernDetails(concerns []Concern) bool {
+	for _, concern := range concerns {
+		if concern.Note != "" || len(concern.Files) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultConcernPathLimit caps the files listed per concern in
+// CODEMAP.concerns when Options.ConcernPathLimit is 0.
+const defaultConcernPathLimit = 50
+
+// concernPathLimit returns the files kept per concern for CODEMAP.concerns,
+// or 0 when that output is disabled.
+func concernPathLimit(opts Options) int {
+	if opts.ConcernsOutputPath == "" {
+		return 0
+	}
+	if opts.ConcernPathLimit > 0 {
+		return opts.ConcernPathLimit
+	}
+	return defaultConcernPathLimit
+}
+
+// ConcernsRenderer renders CODEMAP.concerns output.
+type ConcernsRenderer struct{}
+
+func (ConcernsRenderer) Name() string        { return "concerns" }
+func (ConcernsRenderer) DefaultPath() string { return "CODEMAP.concerns" }
+func (ConcernsRenderer) Render(cm *Codemap) (string, error) {
+	return renderConcerns(cm), nil
+}
+
+// renderConcerns writes one tab-separated concern and file row per matched
+// file, in concern order, so agents can pull the files for a concern with a
+// single grep. Concerns listing fewer files than they matched say so in a
+// comment after their rows.
+func renderConcerns(cm *Codemap) string {
+	var sb strings.Builder
+	sb.WriteString("# codemap-hash: ")
+	sb.WriteString(cm.ContentHash)
+	sb.WriteString("\n# Format: concern\\tfile\n")
+	for _, concern := range cm.Concerns {
+		for _, file := range concern.Paths {
+			sb.WriteString(concern.Name)
+			sb.WriteByte('\t')
+			sb.WriteString(file)
+			sb.WriteByte('\n')
+		}
+		if len(concern.Paths) < concern.TotalFiles {
+			fmt.Fprintf(&sb, "# %s: %d of %d files listed\n", concern.Name, len(concern.Paths), concern.TotalFiles)
+		}
+	}
+	return sb.String()
+}
+
+// concernsOutputStale reports whether an enabled concerns output is missing
+// or was written for a different content hash than the markdown output.
+func concernsOutputStale(root string, opts Options, existingHash string) (bool, error) {
+	if opts.ConcernsOutputPath == "" {
+		return false, nil
+	}
+	concernsHash, err := readExistingHash(outputAbsPath(root, opts.ConcernsOutputPath), opts.HashScanLines)
+	if err != nil {
+		return false, fmt.Errorf("read existing concerns hash: %w", err)
+	}
+	return concernsHash == "" || concernsHash != existingHash, nil
+}
@@ -0,0 +1,131 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConcernFlag(t *testing.T) {
+	def, err := ParseConcernFlag("Database = **/db/**, **/*store*.go ,")
+	if err != nil {
+		t.Fatalf("ParseConcernFlag returned error: %v", err)
+	}
+	want := ConcernDef{Name: "Database", Patterns: []string{"**/db/**", "**/*store*.go"}}
+	if !reflect.DeepEqual(def, want) {
+		t.Fatalf("unexpected concern: %+v", def)
+	}
+	for _, bad := range []string{"Database", "=**/*.go", "Database="} {
+		if _, err := ParseConcernFlag(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestMergeConcernDefsReplacesByName(t *testing.T) {
+	base := []ConcernDef{{Name: "Testing", Patterns: []string{"a"}}, {Name: "CLI", Patterns: []string{"b"}}}
+	merged := MergeConcernDefs(base, []ConcernDef{{Name: "cli", Patterns: []string{"c"}}, {Name: "Auth", Patterns: []string{"d"}}})
+	want := []ConcernDef{{Name: "Testing", Patterns: []string{"a"}}, {Name: "cli", Patterns: []string{"c"}}, {Name: "Auth", Patterns: []string{"d"}}}
+	if !reflect.DeepEqual(merged, want) {
+		t.Fatalf("unexpected merge: %+v", merged)
+	}
+	if base[1].Name != "CLI" {
+		t.Fatal("expected base to be left untouched")
+	}
+}
+
+func TestConcernFileLimitsAndNotes(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a_store.go", "b_store.go", "c_store.go"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "concerns.json")
+	config := `[{"name": "Database", "patterns": ["**/*_store.go"], "exampleLimit": 2, "note": "Persistence layer"}]`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defs, err := LoadConcernFile(configPath)
+	if err != nil {
+		t.Fatalf("LoadConcernFile returned error: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.Concerns = defs
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	want := []Concern{{
+		Name:       "Database",
+		Patterns:   []string{"**/*_store.go"},
+		Files:      []string{"a_store.go", "b_store.go"},
+		TotalFiles: 3,
+		Languages:  map[string]int{"go": 3},
+		Note:       "Persistence layer",
+	}}
+	if !reflect.DeepEqual(cm.Concerns, want) {
+		t.Fatalf("unexpected concerns: %+v", cm.Concerns)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "- Database: Persistence layer (e.g. a_store.go, b_store.go)") {
+		t.Fatalf("expected concern details in CODEMAP.md:\n%s", data)
+	}
+
+	if err := os.WriteFile(configPath, []byte(`[{"name": "Empty"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConcernFile(configPath); err == nil {
+		t.Fatal("expected error for concern without patterns")
+	}
+}
+
+func TestConcernsOutputListsCappedPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a_store.go", "b_store.go", "c_store.go", "errors.go"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.Concerns = []ConcernDef{
+		{Name: "Database", Patterns: []string{"**/*_store.go"}},
+		{Name: "Errors", Patterns: []string{"**/error*.go"}},
+	}
+	opts.ConcernsOutputPath = "CODEMAP.concerns"
+	opts.ConcernPathLimit = 2
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if len(cm.Concerns) != 2 || len(cm.Concerns[0].Files) != 0 {
+		t.Fatalf("expected paths without example files, got %+v", cm.Concerns)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.concerns"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# codemap-hash: " + cm.ContentHash + "\n" +
+		"# Format: concern\\tfile\n" +
+		"Database\ta_store.go\n" +
+		"Database\tb_store.go\n" +
+		"# Database: 2 of 3 files listed\n" +
+		"Errors\terrors.go\n"
+	if string(data) != want {
+		t.Fatalf("unexpected concerns output:\n%s", data)
+	}
+	if stale, err := IsStale(context.Background(), opts); err != nil || stale {
+		t.Fatalf("expected fresh outputs, got stale=%v err=%v", stale, err)
+	}
+}
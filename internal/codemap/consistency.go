@@ -0,0 +1,226 @@
+package codemap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrOutputsInconsistent is returned by a run with Options.VerifyOutputs when
+// the outputs it wrote disagree with each other.
+var ErrOutputsInconsistent = errors.New("codemap outputs are inconsistent")
+
+// OutputMismatch is one disagreement between the markdown output and another
+// output, found by VerifyOutputs.
+type OutputMismatch struct {
+	Output  string `json:"output"`
+	Message string `json:"message"`
+}
+
+func (m OutputMismatch) String() string {
+	return m.Output + ": " + m.Message
+}
+
+// VerifyOutputs checks that CODEMAP.paths and the JSON output, when opts
+// enables them, embed the same codemap-hash as the markdown output and list
+// the same package rows. It catches partially written outputs and renderer
+// bugs; a missing markdown output is an error since there is nothing to
+// compare against.
+func VerifyOutputs(opts Options) ([]OutputMismatch, error) {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	if opts.OutputPath == "" {
+		opts.OutputPath = MarkdownRenderer{}.DefaultPath()
+	}
+	if opts.PathsOutputPath == "" {
+		opts.PathsOutputPath = PathsRenderer{}.DefaultPath()
+	}
+
+	data, err := os.ReadFile(outputAbsPath(root, opts.OutputPath))
+	if err != nil {
+		return nil, fmt.Errorf("read markdown output: %w", err)
+	}
+	hash, err := scanHashHeader(bytes.NewReader(data), opts.HashScanLines)
+	if err != nil {
+		return nil, fmt.Errorf("read markdown hash: %w", err)
+	}
+	var mismatches []OutputMismatch
+	if hash == "" {
+		mismatches = append(mismatches, OutputMismatch{Output: opts.OutputPath, Message: "no codemap-hash header"})
+	}
+	rows := markdownPackageRows(data)
+
+	check := func(name, otherHash string, otherRows []string) {
+		if otherHash != hash {
+			mismatches = append(mismatches, OutputMismatch{
+				Output:  name,
+				Message: fmt.Sprintf("codemap-hash %s, want %s", orMissing(otherHash), orMissing(hash)),
+			})
+		}
+		missing, extra := packageRowDifference(rows, otherRows)
+		for _, row := range missing {
+			mismatches = append(mismatches, OutputMismatch{Output: name, Message: "missing package " + row})
+		}
+		for _, row := range extra {
+			mismatches = append(mismatches, OutputMismatch{Output: name, Message: "unexpected package " + row})
+		}
+	}
+
+	if !opts.DisablePaths {
+		data, err := os.ReadFile(outputAbsPath(root, opts.PathsOutputPath))
+		switch {
+		case os.IsNotExist(err):
+			mismatches = append(mismatches, OutputMismatch{Output: opts.PathsOutputPath, Message: "missing"})
+		case err != nil:
+			return nil, fmt.Errorf("read paths output: %w", err)
+		default:
+			pathsHash, err := scanHashHeader(bytes.NewReader(data), opts.HashScanLines)
+			if err != nil {
+				return nil, fmt.Errorf("read paths hash: %w", err)
+			}
+			check(opts.PathsOutputPath, pathsHash, pathsPackageRows(data))
+		}
+	}
+	if opts.JSONOutputPath != "" {
+		data, err := os.ReadFile(outputAbsPath(root, opts.JSONOutputPath))
+		switch {
+		case os.IsNotExist(err):
+			mismatches = append(mismatches, OutputMismatch{Output: opts.JSONOutputPath, Message: "missing"})
+		case err != nil:
+			return nil, fmt.Errorf("read json output: %w", err)
+		default:
+			var parsed struct {
+				ContentHash string
+				Packages    []Package
+			}
+			if err := json.Unmarshal(data, &parsed); err != nil {
+				mismatches = append(mismatches, OutputMismatch{Output: opts.JSONOutputPath, Message: "invalid JSON: " + err.Error()})
+				break
+			}
+			jsonRows := make([]string, len(parsed.Packages))
+			for i, pkg := range parsed.Packages {
+				jsonRows[i] = packageRow(pkg.RelativePath, entryPath(pkg))
+			}
+			check(opts.JSONOutputPath, parsed.ContentHash, jsonRows)
+		}
+	}
+	return mismatches, nil
+}
+
+// verifyWrittenOutputs runs VerifyOutputs after a run with
+// Options.VerifyOutputs has written its outputs.
+func verifyWrittenOutputs(opts Options) error {
+	if !opts.VerifyOutputs {
+		return nil
+	}
+	mismatches, err := VerifyOutputs(opts)
+	if err != nil {
+		return fmt.Errorf("verify outputs: %w", err)
+	}
+	if len(mismatches) == 0 {
+		return nil
+	}
+	messages := make([]string, len(mismatches))
+	for i, mismatch := range mismatches {
+		messages[i] = mismatch.String()
+	}
+	return fmt.Errorf("%w: %s", ErrOutputsInconsistent, strings.Join(messages, "; "))
+}
+
+func packageRow(pkgPath, entry string) string {
+	return pkgPath + " -> " + entry
+}
+
+// markdownPackageRows returns the package and entry of each row of the
+// Package Entry Points table.
+func markdownPackageRows(data []byte) []string {
+	var rows []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 4096), maxHeaderLineBytes)
+	inTable := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !inTable {
+			inTable = line == "## Package Entry Points"
+			continue
+		}
+		if strings.HasPrefix(line, "## ") {
+			break
+		}
+		if !strings.HasPrefix(line, "| ") || strings.HasPrefix(line, "| Package |") {
+			continue
+		}
+		cells := strings.SplitN(strings.TrimPrefix(line, "| "), " | ", 3)
+		if len(cells) < 2 {
+			continue
+		}
+		pkgPath := cells[0]
+		if strings.HasPrefix(pkgPath, "<a id=") {
+			if end := strings.Index(pkgPath, "</a>"); end >= 0 {
+				pkgPath = pkgPath[end+len("</a>"):]
+			}
+		}
+		if strings.HasPrefix(pkgPath, "[") {
+			if end := strings.Index(pkgPath, "]("); end >= 0 {
+				pkgPath = pkgPath[1:end]
+			}
+		}
+		rows = append(rows, packageRow(pkgPath, strings.TrimSuffix(cells[1], " |")))
+	}
+	return rows
+}
+
+// pathsPackageRows returns the package and entry of each CODEMAP.paths row.
+func pathsPackageRows(data []byte) []string {
+	var rows []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cells := strings.SplitN(line, "\t", 3)
+		if len(cells) < 2 {
+			continue
+		}
+		rows = append(rows, packageRow(cells[0], cells[1]))
+	}
+	return rows
+}
+
+// packageRowDifference returns the rows of want absent from got and the rows
+// of got absent from want, counting repeated rows.
+func packageRowDifference(want, got []string) (missing, extra []string) {
+	counts := make(map[string]int, len(want))
+	for _, row := range want {
+		counts[row]++
+	}
+	for _, row := range got {
+		if counts[row] > 0 {
+			counts[row]--
+			continue
+		}
+		extra = append(extra, row)
+	}
+	for row, n := range counts {
+		for ; n > 0; n-- {
+			missing = append(missing, row)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return missing, extra
+}
+
+func orMissing(hash string) string {
+	if hash == "" {
+		return "(none)"
+	}
+	return hash
+}
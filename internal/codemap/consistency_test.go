@@ -0,0 +1,68 @@
+package codemap
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVerifyOutputsReportsDisagreements(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":     "module example.com/app\n\ngo 1.22\n",
+		"api/api.go": "// Package api serves requests.\npackage api\n",
+		"db/db.go":   "package db\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.JSONOutputPath = "CODEMAP.json"
+	opts.VerifyOutputs = true
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	mismatches, err := VerifyOutputs(opts)
+	if err != nil || len(mismatches) != 0 {
+		t.Fatalf("expected consistent outputs, got %v (err %v)", mismatches, err)
+	}
+
+	pathsPath := filepath.Join(tmpDir, "CODEMAP.paths")
+	data, err := os.ReadFile(pathsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := strings.Replace(string(data), "db\tdb/db.go\n", "", 1)
+	if err := os.WriteFile(pathsPath, []byte(truncated), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "CODEMAP.json"), []byte(`{"ContentHash": "stale", "Packages": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mismatches, err = VerifyOutputs(opts)
+	if err != nil {
+		t.Fatalf("VerifyOutputs returned error: %v", err)
+	}
+	hash, err := ReadExistingHash(filepath.Join(tmpDir, "CODEMAP.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []OutputMismatch{
+		{Output: "CODEMAP.paths", Message: "missing package db -> db/db.go"},
+		{Output: "CODEMAP.json", Message: "codemap-hash stale, want " + hash},
+		{Output: "CODEMAP.json", Message: "missing package api -> api/api.go"},
+		{Output: "CODEMAP.json", Message: "missing package db -> db/db.go"},
+	}
+	if !reflect.DeepEqual(mismatches, want) {
+		t.Fatalf("unexpected mismatches:\n got %v\nwant %v", mismatches, want)
+	}
+
+	if err := verifyWrittenOutputs(opts); !errors.Is(err, ErrOutputsInconsistent) {
+		t.Fatalf("expected ErrOutputsInconsistent, got %v", err)
+	}
+}
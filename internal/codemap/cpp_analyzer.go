@@ -0,0 +1,647 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// cppBuildManifests mark the root of a C/C++ package. Sources are grouped by
+// the nearest directory holding one of them; the directories looked at are
+// recorded as auxiliary inputs.
+var cppBuildManifests = []string{"CMakeLists.txt", "Makefile", "GNUmakefile"}
+
+var (
+	cppIncludePattern      = regexp.MustCompile(`(?m)^[ \t]*#[ \t]*include[ \t]*([<"])([^>"\n]+)[>"]`)
+	cppMainPattern         = regexp.MustCompile(`(?m)^[ \t]*(?:int|void)[ \t]+main[ \t]*\(`)
+	cppCMakeProjectPattern = regexp.MustCompile(`(?im)^[ \t]*project[ \t]*\([ \t]*([A-Za-z0-9_.+-]+)`)
+	cppFuncPointerPattern  = regexp.MustCompile(`\(\s*\*\s*([A-Za-z_][A-Za-z0-9_]*)\s*\)`)
+)
+
+// CppAnalyzer is the analyzer implementation for C and C++ projects.
+type CppAnalyzer struct{}
+
+func (CppAnalyzer) LanguageID() string { return languageCpp }
+
+func (CppAnalyzer) Analyze(ctx context.Context, in AnalysisInput) (*Codemap, error) {
+	if in.Index == nil {
+		return nil, fmt.Errorf("missing file index")
+	}
+	return analyzeCppWithIndex(ctx, in.Root, in.Index, in.Options, in.PrevState, in.NextState, in.auxInputs())
+}
+
+func analyzeCppWithIndex(ctx context.Context, root string, idx *FileIndex, opts Options, prevState, nextState *CodemapState, aux *auxInputs) (*Codemap, error) {
+	entryByRel := stateEntryByRelPath(nextState)
+	plans, err := buildCppPackagePlans(aux, idx, opts.IncludeTests, entryByRel)
+	if err != nil {
+		return nil, err
+	}
+
+	const modulePath = languageCpp
+	cachedByRel := cachedPackagesByPath(prevState, opts, modulePath)
+
+	packageResults := make([]*Package, len(plans))
+	jobs := make([]analysisJob, 0, len(plans))
+	for i := range plans {
+		plan := plans[i]
+		if cached, ok := cachedByRel[plan.RelativePath]; ok && plan.Fingerprint != "" && cached.Fingerprint == plan.Fingerprint {
+			pkg := cached.Package
+			packageResults[i] = &pkg
+			recordCacheHit(ctx, opts, languageCpp, plan.RelativePath)
+			continue
+		}
+		jobs = append(jobs, analysisJob{
+			index:   i,
+			dir:     plan.DirAbsPath,
+			relPath: plan.RelativePath,
+		})
+	}
+
+	if err := analyzePackagePlansParallel(ctx, opts, languageCpp, jobs, packageResults, func(ctx context.Context, job analysisJob) (*Package, error) {
+		plan := plans[job.index]
+		pkg, err := analyzeCppPackage(ctx, root, plan, plan.Name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("analyze C/C++ package %s: %w", plan.RelativePath, err)
+		}
+		return pkg, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0, len(packageResults))
+	for i := range packageResults {
+		if packageResults[i] != nil {
+			packages = append(packages, *packageResults[i])
+		}
+	}
+
+	updateAnalysisCache(nextState, opts, modulePath, languageCpp, plans, packageResults)
+
+	return &Codemap{
+		ProjectRoot: root,
+		Packages:    packages,
+	}, nil
+}
+
+func buildCppPackagePlans(aux *auxInputs, idx *FileIndex, includeTests bool, entriesByRel map[string]StateEntry) ([]packagePlan, error) {
+	plansByRel := make(map[string]*packagePlan)
+	rootAbs, err := filepath.Abs(aux.root)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	rootByDir := map[string]string{}
+
+	for _, rec := range idx.Files {
+		if rec.Language != languageCpp {
+			continue
+		}
+		if !includeTests && isCppTestPath(rec.RelPath, rec.IsTest) {
+			continue
+		}
+
+		pkgRel := resolveManifestRoot(aux, path.Dir(rec.RelPath), cppBuildManifests, rootByDir)
+		plan, ok := plansByRel[pkgRel]
+		if !ok {
+			plan = &packagePlan{
+				RelativePath: pkgRel,
+				DirAbsPath:   filepath.Join(rootAbs, filepath.FromSlash(pkgRel)),
+				FileRelPaths: make([]string, 0, 4),
+			}
+			plansByRel[pkgRel] = plan
+		}
+		plan.FileRelPaths = append(plan.FileRelPaths, rec.RelPath)
+	}
+
+	relPaths := make([]string, 0, len(plansByRel))
+	for rel := range plansByRel {
+		relPaths = append(relPaths, rel)
+	}
+	sort.Strings(relPaths)
+
+	plans := make([]packagePlan, 0, len(relPaths))
+	for _, rel := range relPaths {
+		plan := plansByRel[rel]
+		sort.Strings(plan.FileRelPaths)
+		plan.Name = readCppProjectName(aux, rootAbs, *plan)
+		plan.Fingerprint = namedPackageFingerprint(packageFingerprint(plan.FileRelPaths, entriesByRel), plan.Name)
+		plans = append(plans, *plan)
+	}
+
+	return plans, nil
+}
+
+func analyzeCppPackage(ctx context.Context, root string, plan packagePlan, packageName string, opts Options) (*Package, error) {
+	if len(plan.FileRelPaths) == 0 {
+		return nil, nil
+	}
+
+	files := make([]File, 0, len(plan.FileRelPaths))
+	allTypes := make([]TypeInfo, 0, len(plan.FileRelPaths))
+	importsSeen := make(map[string]struct{}, len(plan.FileRelPaths))
+	externalSeen := make(map[string]struct{})
+	totalLines := 0
+	purpose := ""
+	entryPoint := ""
+	entryScore := -1
+	var tags []string
+
+	for _, relPath := range plan.FileRelPaths {
+		absPath := filepath.Join(root, filepath.FromSlash(relPath))
+		content, err := os.ReadFile(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", relPath, err)
+		}
+
+		lineCount := lineCountBytes(content)
+		recordFileParsed(ctx, relPath, lineCount)
+		totalLines += lineCount
+
+		withinPackage := relPath
+		if plan.RelativePath != "." {
+			prefix := plan.RelativePath + "/"
+			if strings.HasPrefix(relPath, prefix) {
+				withinPackage = strings.TrimPrefix(relPath, prefix)
+			}
+		}
+
+		filePurpose := extractFilePurpose(opts, languageCpp, relPath, content, extractCppFilePurpose)
+		if purpose == "" && filePurpose != "" {
+			purpose = filePurpose
+		}
+		tags = mergeCodemapTags(tags, extractCodemapTags(content))
+
+		var keyTypes, keyFuncs []string
+		if isCppHeaderPath(relPath) {
+			for _, decl := range parseCppHeaderDecls(content) {
+				allTypes = append(allTypes, decl)
+				if decl.Kind == "func" {
+					keyFuncs = append(keyFuncs, decl.Name)
+				} else {
+					keyTypes = append(keyTypes, decl.Name)
+				}
+			}
+		}
+		for _, inc := range scanCppIncludes(content) {
+			if !inc.system {
+				if dep := resolveCppInclude(root, plan, relPath, inc.path); dep != "" {
+					importsSeen[dep] = struct{}{}
+				}
+				continue
+			}
+			externalSeen[inc.path] = struct{}{}
+		}
+
+		files = append(files, File{
+			Name:      withinPackage,
+			LineCount: lineCount,
+			Purpose:   filePurpose,
+			KeyTypes:  keyTypes,
+			KeyFuncs:  keyFuncs,
+		})
+
+		score := scoreCppEntryPoint(withinPackage, packageName, keyFuncs, cppMainPattern.Match(content))
+		if score > entryScore || (score == entryScore && (entryPoint == "" || withinPackage < entryPoint)) {
+			entryScore = score
+			entryPoint = withinPackage
+		}
+	}
+
+	if entryPoint == "" && len(files) > 0 {
+		entryPoint = files[0].Name
+	}
+	if purpose == "" && packageName != "" {
+		purpose = "C/C++ project " + packageName
+	}
+
+	sort.SliceStable(allTypes, func(i, j int) bool {
+		return allTypes[i].Name < allTypes[j].Name
+	})
+
+	detailedFiles, keyFiles := packageFileDetails(files, entryPoint, opts)
+
+	return &Package{
+		ImportPath:      packageName,
+		RelativePath:    plan.RelativePath,
+		Purpose:         purpose,
+		FileCount:       len(files),
+		LineCount:       totalLines,
+		Files:           detailedFiles,
+		KeyFiles:        keyFiles,
+		ExportedTypes:   allTypes,
+		Symbols:         packageSymbols(files, allTypes),
+		Imports:         sortedImportSet(importsSeen),
+		ExternalImports: sortedImportSet(externalSeen),
+		EntryPoint:      entryPoint,
+		Tags:            tags,
+	}, nil
+}
+
+// readCppProjectName returns the project() name from the package's
+// CMakeLists.txt, falling back to the directory name.
+func readCppProjectName(aux *auxInputs, root string, plan packagePlan) string {
+	if content, err := aux.readFile(path.Join(plan.RelativePath, "CMakeLists.txt")); err == nil {
+		if match := cppCMakeProjectPattern.FindSubmatch(content); match != nil {
+			return string(match[1])
+		}
+	}
+	if plan.RelativePath == "." || plan.RelativePath == "" {
+		return filepath.Base(root)
+	}
+	return path.Base(plan.RelativePath)
+}
+
+func isCppHeaderPath(relPath string) bool {
+	switch strings.ToLower(path.Ext(relPath)) {
+	case ".h", ".hh", ".hpp", ".hxx":
+		return true
+	default:
+		return false
+	}
+}
+
+func isCppTestPath(relPath string, fileMatchTest bool) bool {
+	if fileMatchTest {
+		return true
+	}
+	lower := strings.ToLower(relPath)
+	return strings.HasPrefix(lower, "tests/") || strings.Contains(lower, "/tests/") ||
+		strings.HasPrefix(lower, "test/") || strings.Contains(lower, "/test/")
+}
+
+type cppInclude struct {
+	path   string
+	system bool // <...> include
+}
+
+func scanCppIncludes(content []byte) []cppInclude {
+	matches := cppIncludePattern.FindAllSubmatch(content, -1)
+	includes := make([]cppInclude, 0, len(matches))
+	for _, match := range matches {
+		includes = append(includes, cppInclude{
+			path:   strings.TrimSpace(string(match[2])),
+			system: string(match[1]) == "<",
+		})
+	}
+	return includes
+}
+
+// resolveCppInclude maps a quoted include to the root-relative header it
+// names, looking next to the including file, then in the package root and its
+// include directory, then in the project root. Headers of the same package
+// are dropped; includes that resolve nowhere are kept verbatim.
+func resolveCppInclude(root string, plan packagePlan, relPath, include string) string {
+	candidates := []string{
+		path.Join(path.Dir(relPath), include),
+		path.Join(plan.RelativePath, include),
+		path.Join(plan.RelativePath, "include", include),
+		path.Clean(include),
+	}
+	for _, candidate := range candidates {
+		if candidate == ".." || strings.HasPrefix(candidate, "../") {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(candidate)))
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if i := sort.SearchStrings(plan.FileRelPaths, candidate); i < len(plan.FileRelPaths) && plan.FileRelPaths[i] == candidate {
+			return ""
+		}
+		return candidate
+	}
+	return include
+}
+
+// parseCppHeaderDecls extracts the public declarations of a header: function
+// prototypes and inline definitions, classes, structs, unions, enums and
+// typedefs at file scope. Bodies of classes and functions are skipped, while
+// namespace and extern "C" blocks are scanned through. Static functions are
+// private to their translation unit and left out.
+func parseCppHeaderDecls(content []byte) []TypeInfo {
+	src := string(content)
+	var decls []TypeInfo
+	var stmt strings.Builder
+	pendingDoc, stmtDoc := "", ""
+	depth := 0 // Brace depth inside a skipped body
+	head := ""
+	lineStart := true
+
+	flush := func(text string) {
+		if decl, ok := classifyCppDecl(text); ok {
+			decl.Comment = extractFirstSentence(stmtDoc)
+			decls = append(decls, decl)
+		}
+		stmt.Reset()
+		stmtDoc = ""
+	}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			text := strings.TrimSpace(strings.TrimLeft(src[i+2:i+end], "/!<"))
+			if depth == 0 && stmt.Len() == 0 && lineStart {
+				pendingDoc = strings.TrimSpace(pendingDoc + " " + text)
+			}
+			i += end - 1
+			continue
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src) - i - 2
+			}
+			if depth == 0 && stmt.Len() == 0 && lineStart {
+				pendingDoc = cleanCppBlockComment(src[i+2 : i+2+end])
+			}
+			i += end + 3
+			continue
+		case c == '#' && lineStart:
+			// Preprocessor directive, including backslash continuations.
+			for i < len(src) && !(src[i] == '\n' && src[i-1] != '\\') {
+				i++
+			}
+			pendingDoc = ""
+			lineStart = true
+			continue
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(src) && src[end] != c && src[end] != '\n' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if depth == 0 {
+				stmt.WriteString(src[i:min(end+1, len(src))])
+			}
+			i = end
+			lineStart = false
+			continue
+		}
+
+		if c == '\n' {
+			lineStart = true
+		} else if c != ' ' && c != '\t' && c != '\r' {
+			lineStart = false
+		}
+
+		if depth > 0 {
+			switch c {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					if isCppFunctionBodyHead(head) {
+						flush(head)
+					} else {
+						stmt.WriteString(" {} ")
+					}
+				}
+			}
+			continue
+		}
+
+		switch c {
+		case '{':
+			text := strings.TrimSpace(stmt.String())
+			if strings.HasPrefix(text, "namespace") || strings.HasPrefix(text, "inline namespace") || strings.HasPrefix(text, `extern "C"`) {
+				stmt.Reset()
+				stmtDoc = ""
+				continue
+			}
+			head = text
+			depth = 1
+		case '}':
+			// Closes a namespace or extern "C" block.
+			stmt.Reset()
+			stmtDoc = ""
+		case ';':
+			flush(stmt.String())
+		default:
+			if stmt.Len() == 0 {
+				if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+					continue
+				}
+				stmtDoc, pendingDoc = pendingDoc, ""
+			}
+			stmt.WriteByte(c)
+		}
+	}
+	return decls
+}
+
+func cleanCppBlockComment(text string) string {
+	lines := strings.Split(text, "\n")
+	parts := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "*!<"))
+		if strings.HasPrefix(line, "@file") || strings.HasPrefix(line, `\file`) {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(line, "@brief"), `\brief`))
+		if line != "" {
+			parts = append(parts, line)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+var cppDeclKeywords = map[string]bool{
+	"if": true, "while": true, "for": true, "switch": true, "return": true,
+	"sizeof": true, "decltype": true, "alignas": true, "static_assert": true,
+	"int": true, "void": true, "char": true, "long": true, "short": true,
+	"unsigned": true, "signed": true, "float": true, "double": true, "bool": true,
+	"const": true, "operator": true,
+}
+
+func classifyCppDecl(stmt string) (TypeInfo, bool) {
+	text := strings.Join(strings.Fields(stmt), " ")
+	if strings.HasPrefix(text, "template") {
+		if end := strings.IndexByte(text, '>'); end >= 0 {
+			text = strings.TrimSpace(text[end+1:])
+		}
+	}
+	if text == "" {
+		return TypeInfo{}, false
+	}
+
+	first, rest, _ := strings.Cut(text, " ")
+	switch first {
+	case "typedef":
+		if match := cppFuncPointerPattern.FindStringSubmatch(text); match != nil {
+			return TypeInfo{Name: match[1], Kind: "alias"}, true
+		}
+		if name := lastCppIdentifier(text); name != "" {
+			return TypeInfo{Name: name, Kind: "alias"}, true
+		}
+		return TypeInfo{}, false
+	case "using":
+		name, _, ok := strings.Cut(rest, "=")
+		if name = strings.TrimSpace(name); ok && isCppIdentifier(name) {
+			return TypeInfo{Name: name, Kind: "alias"}, true
+		}
+		return TypeInfo{}, false
+	case "class", "struct", "union", "enum":
+		// Only definitions; forward declarations carry no body.
+		body := strings.Index(text, "{}")
+		if body < 0 {
+			if strings.Contains(text, "(") {
+				break
+			}
+			return TypeInfo{}, false
+		}
+		declHead := text[:body]
+		if colon := strings.Index(declHead, " :"); colon >= 0 {
+			declHead = declHead[:colon]
+		}
+		name := lastCppIdentifier(strings.TrimPrefix(declHead, first))
+		if name == "" || name == "class" || name == "struct" {
+			return TypeInfo{}, false
+		}
+		return TypeInfo{Name: name, Kind: first}, true
+	}
+
+	if !isCppFunctionHead(text) {
+		return TypeInfo{}, false
+	}
+	name := lastCppIdentifier(text[:strings.IndexByte(text, '(')])
+	return TypeInfo{Name: name, Kind: "func"}, true
+}
+
+// isCppFunctionHead reports whether text declares a non-static function: a
+// return type followed by a name and a parameter list.
+func isCppFunctionHead(text string) bool {
+	open := strings.IndexByte(text, '(')
+	if open <= 0 || !strings.Contains(text[open:], ")") {
+		return false
+	}
+	before := text[:open]
+	if strings.ContainsAny(before, "={}") {
+		return false
+	}
+	fields := strings.FieldsFunc(before, func(r rune) bool {
+		return !(r == '_' || r == ':' || r == '~' || r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z')
+	})
+	if len(fields) < 2 {
+		return false
+	}
+	for _, field := range fields {
+		if field == "static" || field == "typedef" {
+			return false
+		}
+	}
+	name := fields[len(fields)-1]
+	return isCppIdentifier(strings.TrimPrefix(name[strings.LastIndex(name, ":")+1:], "~")) && !cppDeclKeywords[name]
+}
+
+// isCppFunctionBodyHead reports whether a braced block following head is a
+// function body rather than a type definition or an initializer.
+func isCppFunctionBodyHead(head string) bool {
+	if !strings.Contains(head, "(") || strings.Contains(head, "=") {
+		return false
+	}
+	first, _, _ := strings.Cut(head, " ")
+	switch first {
+	case "class", "struct", "union", "enum", "typedef":
+		return false
+	}
+	return true
+}
+
+func lastCppIdentifier(text string) string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !(r == '_' || r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z')
+	})
+	for i := len(fields) - 1; i >= 0; i-- {
+		if isCppIdentifier(fields[i]) {
+			return fields[i]
+		}
+	}
+	return ""
+}
+
+func isCppIdentifier(value string) bool {
+	if value == "" || value[0] >= '0' && value[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if !(c == '_' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// extractCppFilePurpose returns the first sentence of the leading comment,
+// skipping license and copyright headers.
+func extractCppFilePurpose(content []byte) string {
+	src := string(content)
+	for {
+		src = strings.TrimSpace(src)
+		var text string
+		switch {
+		case strings.HasPrefix(src, "//"):
+			var lines []string
+			for strings.HasPrefix(src, "//") {
+				line, rest, _ := strings.Cut(src, "\n")
+				lines = append(lines, strings.TrimSpace(strings.TrimLeft(line, "/!")))
+				src = strings.TrimSpace(rest)
+			}
+			text = strings.Join(lines, " ")
+		case strings.HasPrefix(src, "/*"):
+			end := strings.Index(src, "*/")
+			if end < 0 {
+				return ""
+			}
+			text = cleanCppBlockComment(src[2:end])
+			src = src[end+2:]
+		default:
+			return ""
+		}
+		lower := strings.ToLower(text)
+		if strings.Contains(lower, "copyright") || strings.Contains(lower, "spdx-license-identifier") || strings.Contains(lower, "license") {
+			continue
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			return extractFirstSentence(text)
+		}
+	}
+}
+
+func scoreCppEntryPoint(relPath, packageName string, keyFuncs []string, definesMain bool) int {
+	score := 0
+	lower := strings.ToLower(relPath)
+	base := path.Base(lower)
+	stem := strings.TrimSuffix(base, path.Ext(base))
+
+	if definesMain {
+		score += 100
+	}
+	switch {
+	case stem == "main" && !isCppHeaderPath(lower):
+		score += 40
+	case isCppHeaderPath(lower) && packageName != "" && stem == strings.ToLower(packageName):
+		score += 90
+	}
+	if isCppHeaderPath(lower) {
+		score += 20
+		if strings.HasPrefix(lower, "include/") {
+			score += 10
+		}
+	}
+	if len(keyFuncs) > 0 {
+		score += 5
+	}
+	return score
+}
@@ -0,0 +1,167 @@
+package codemap
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseCppHeaderDecls(t *testing.T) {
+	content := []byte(`/* Copyright 2024 Example. */
+#ifndef STORE_H
+#define STORE_H
+
+#include <stddef.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/** Opens a store at path. */
+struct store *store_open(const char *path);
+
+// Closes the store.
+void store_close(struct store *s);
+
+typedef void (*store_cb)(int status);
+typedef struct store_opts {
+    int flags;
+} store_opts_t;
+
+static inline int store_helper(int x) { return x + 1; }
+int store_count(void); // trailing comment
+extern int store_version;
+struct store;
+
+#ifdef __cplusplus
+}
+#endif
+
+namespace acme {
+class API Cache : public Base {
+public:
+    void get(int key);
+};
+enum class Mode { Fast, Safe };
+inline int twice(int x) { return 2 * x; }
+}
+
+#endif
+`)
+
+	got := parseCppHeaderDecls(content)
+	want := []TypeInfo{
+		{Name: "store_open", Kind: "func", Comment: "Opens a store at path."},
+		{Name: "store_close", Kind: "func", Comment: "Closes the store."},
+		{Name: "store_cb", Kind: "alias"},
+		{Name: "store_opts_t", Kind: "alias"},
+		{Name: "store_count", Kind: "func"},
+		{Name: "Cache", Kind: "class"},
+		{Name: "Mode", Kind: "enum"},
+		{Name: "twice", Kind: "func"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected declarations:\n got %+v\nwant %+v", got, want)
+	}
+	if purpose := extractCppFilePurpose(content); purpose != "" {
+		t.Fatalf("expected license header to be skipped, got %q", purpose)
+	}
+}
+
+func TestCppAnalyzerGroupsByBuildRoot(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"CMakeLists.txt":            "cmake_minimum_required(VERSION 3.20)\nproject(engine CXX)\n",
+		"src/main.cpp":              "#include \"engine.h\"\n#include \"util/strings.h\"\n#include <vector>\n\nint main() { return 0; }\n",
+		"src/engine.h":              "// Core engine API.\n#pragma once\nvoid engine_run(void);\n",
+		"util/Makefile":             "all:\n\tcc -c strings.c\n",
+		"util/strings.h":            "/**\n * @file strings.h\n * @brief String helpers.\n */\n#pragma once\nchar *str_dup(const char *s);\n",
+		"util/strings.c":            "#include \"strings.h\"\n#include <stdlib.h>\nchar *str_dup(const char *s) { return 0; }\n",
+		"util/strings_test.c":       "int main() { return 0; }\n",
+		"tests/engine_smoke.cpp":    "int main() { return 0; }\n",
+		"vendor/lib/ignored_file.c": "int x;\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+
+	byPath := make(map[string]Package)
+	for _, pkg := range cm.Packages {
+		if pkg.Language == languageCpp {
+			byPath[pkg.RelativePath] = pkg
+		}
+	}
+	if len(byPath) != 2 {
+		t.Fatalf("expected 2 C/C++ packages, got %+v", byPath)
+	}
+
+	engine := byPath["."]
+	if engine.ImportPath != "engine" || engine.FileCount != 2 || engine.EntryPoint != "src/main.cpp" {
+		t.Fatalf("unexpected engine package: %+v", engine)
+	}
+	if engine.Purpose != "Core engine API." {
+		t.Fatalf("unexpected engine purpose: %q", engine.Purpose)
+	}
+	if !reflect.DeepEqual(engine.Imports, []string{"util/strings.h"}) || !reflect.DeepEqual(engine.ExternalImports, []string{"vector"}) {
+		t.Fatalf("unexpected engine imports: %v / %v", engine.Imports, engine.ExternalImports)
+	}
+	if !reflect.DeepEqual(engine.ExportedTypes, []TypeInfo{{Name: "engine_run", Kind: "func"}}) {
+		t.Fatalf("unexpected engine declarations: %+v", engine.ExportedTypes)
+	}
+
+	util := byPath["util"]
+	if util.ImportPath != "util" || util.FileCount != 2 || util.EntryPoint != "strings.h" || util.Purpose != "String helpers." {
+		t.Fatalf("unexpected util package: %+v", util)
+	}
+	if len(util.Imports) != 0 || !reflect.DeepEqual(util.ExternalImports, []string{"stdlib.h"}) {
+		t.Fatalf("unexpected util imports: %v / %v", util.Imports, util.ExternalImports)
+	}
+}
+
+func TestCppBuildManifestChangesMakeOutputsStale(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"CMakeLists.txt": "project(engine CXX)\n",
+		"src/main.cpp":   "int main() { return 0; }\n",
+		"lib/lib.c":      "int lib_run(void) { return 0; }\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	packages := func() map[string]string {
+		t.Helper()
+		if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+			t.Fatalf("IsStale = %v, %v; want true", stale, err)
+		}
+		cm, _, err := EnsureUpToDate(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("EnsureUpToDate returned error: %v", err)
+		}
+		byPath := make(map[string]string)
+		for _, pkg := range cm.Packages {
+			if pkg.Language == languageCpp {
+				byPath[pkg.RelativePath] = pkg.ImportPath
+			}
+		}
+		return byPath
+	}
+	if got, want := packages(), map[string]string{".": "engine"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("packages = %v, want %v", got, want)
+	}
+
+	writeTestTree(t, tmpDir, map[string]string{"lib/Makefile": "all:\n\tcc -c lib.c\n"})
+	if got, want := packages(), map[string]string{".": "engine", "lib": "lib"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("packages after adding lib/Makefile = %v, want %v", got, want)
+	}
+
+	writeTestTree(t, tmpDir, map[string]string{"CMakeLists.txt": "project(motor CXX)\n"})
+	if got, want := packages(), map[string]string{".": "motor", "lib": "lib"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("packages after renaming the project = %v, want %v", got, want)
+	}
+}
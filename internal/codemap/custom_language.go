@@ -0,0 +1,290 @@
+package codemap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CustomLanguageDef configures a language codemap lists without parsing, such
+// as Thrift or Terraform: its files are indexed, grouped into one package
+// per directory and described by their leading comment.
+type CustomLanguageDef struct {
+	Language     string   `json:"-"`                      // Language ID reported on packages, e.g. "thrift"
+	Suffixes     []string `json:"suffixes"`               // File suffixes indexed for the language; default "." + Language
+	TestSuffixes []string `json:"testSuffixes,omitempty"` // Suffixes marking test files, e.g. "_test.thrift"
+}
+
+// ParseCustomLanguageDef parses "LANG" or "LANG:SUFFIX[:SUFFIX...]", e.g.
+// "thrift" or "terraform:.tf:.tfvars".
+func ParseCustomLanguageDef(value string) (CustomLanguageDef, error) {
+	parts := strings.Split(value, ":")
+	def := CustomLanguageDef{Language: canonicalLanguageID(parts[0])}
+	for _, suffix := range parts[1:] {
+		if suffix = strings.TrimSpace(suffix); suffix != "" {
+			def.Suffixes = append(def.Suffixes, suffix)
+		}
+	}
+	if err := def.validate(); err != nil {
+		return CustomLanguageDef{}, fmt.Errorf("custom language %q: %w", value, err)
+	}
+	return def, nil
+}
+
+// LoadLanguageFile reads custom languages from a JSON file holding a
+// "languages" object keyed by language ID, e.g.
+//
+//	{"languages": {"thrift": {"suffixes": [".thrift"]}}}
+//
+// Definitions are returned sorted by language.
+func LoadLanguageFile(path string) ([]CustomLanguageDef, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config struct {
+		Languages map[string]CustomLanguageDef `json:"languages"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	defs := make([]CustomLanguageDef, 0, len(config.Languages))
+	for language, def := range config.Languages {
+		def.Language = canonicalLanguageID(language)
+		if err := def.validate(); err != nil {
+			return nil, fmt.Errorf("parse %s: language %q: %w", path, language, err)
+		}
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Language < defs[j].Language })
+	return defs, nil
+}
+
+func (d CustomLanguageDef) validate() error {
+	if d.Language == "" {
+		return fmt.Errorf("missing language")
+	}
+	if _, builtin := builtinLanguageSpecs[d.Language]; builtin {
+		return fmt.Errorf("%s has a built-in analyzer", d.Language)
+	}
+	return nil
+}
+
+func (d CustomLanguageDef) languageSpec() LanguageSpec {
+	suffixes := d.Suffixes
+	if len(suffixes) == 0 {
+		suffixes = []string{"." + d.Language}
+	}
+	return LanguageSpec{ID: d.Language, FileSuffixes: suffixes, TestFileSuffixes: d.TestSuffixes}
+}
+
+// SuffixAnalyzer analyzes a custom language: files are grouped by directory
+// and counted, and purposes come from leading comments. Nothing is parsed,
+// so packages carry no symbols.
+type SuffixAnalyzer struct {
+	Def CustomLanguageDef
+}
+
+func (a SuffixAnalyzer) LanguageID() string { return a.Def.Language }
+
+// LanguageSpec lets a SuffixAnalyzer be passed to RegisterAnalyzer.
+func (a SuffixAnalyzer) LanguageSpec() LanguageSpec { return a.Def.languageSpec() }
+
+func (a SuffixAnalyzer) Analyze(ctx context.Context, in AnalysisInput) (*Codemap, error) {
+	if in.Index == nil {
+		return nil, fmt.Errorf("missing file index")
+	}
+	languageID := a.Def.Language
+	plans := buildDirectoryPackagePlans(in.Root, testFilteredIndex(in.Index, languageID, in.Options.IncludeTests), languageID)
+
+	packageResults := make([]*Package, len(plans))
+	jobs := make([]analysisJob, 0, len(plans))
+	for i := range plans {
+		jobs = append(jobs, analysisJob{
+			index:   i,
+			dir:     plans[i].DirAbsPath,
+			relPath: plans[i].RelativePath,
+		})
+	}
+	if err := analyzePackagePlansParallel(ctx, in.Options, languageID, jobs, packageResults, func(ctx context.Context, job analysisJob) (*Package, error) {
+		plan := plans[job.index]
+		pkg, err := analyzeSuffixPackage(ctx, in.Root, plan, languageID, in.Options)
+		if err != nil {
+			return nil, fmt.Errorf("analyze %s package %s: %w", languageID, plan.RelativePath, err)
+		}
+		return pkg, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0, len(packageResults))
+	for _, pkg := range packageResults {
+		if pkg != nil {
+			packages = append(packages, *pkg)
+		}
+	}
+	return &Codemap{
+		ProjectRoot: in.Root,
+		Packages:    packages,
+	}, nil
+}
+
+// testFilteredIndex drops test files of languageID from idx unless tests are
+// included.
+func testFilteredIndex(idx *FileIndex, languageID string, includeTests bool) *FileIndex {
+	if includeTests {
+		return idx
+	}
+	filtered := *idx
+	filtered.Files = make([]FileRecord, 0, len(idx.Files))
+	for _, rec := range idx.Files {
+		if rec.Language != languageID || !rec.IsTest {
+			filtered.Files = append(filtered.Files, rec)
+		}
+	}
+	return &filtered
+}
+
+func analyzeSuffixPackage(ctx context.Context, root string, plan packagePlan, languageID string, opts Options) (*Package, error) {
+	if len(plan.FileRelPaths) == 0 {
+		return nil, nil
+	}
+
+	files := make([]File, 0, len(plan.FileRelPaths))
+	totalLines := 0
+	purpose := ""
+	entryPoint := ""
+	entryScore := -1
+	var tags []string
+
+	for _, relPath := range plan.FileRelPaths {
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(relPath)))
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", relPath, err)
+		}
+		lineCount := lineCountBytes(content)
+		recordFileParsed(ctx, relPath, lineCount)
+		totalLines += lineCount
+		tags = mergeCodemapTags(tags, extractCodemapTags(content))
+
+		name := filepath.Base(relPath)
+		filePurpose := extractFilePurpose(opts, languageID, relPath, content, extractLeadingCommentPurpose)
+		if purpose == "" && filePurpose != "" {
+			purpose = filePurpose
+		}
+		files = append(files, File{
+			Name:      name,
+			LineCount: lineCount,
+			Purpose:   filePurpose,
+		})
+
+		score := scoreAssetEntryPoint(name)
+		if score > entryScore || (score == entryScore && name < entryPoint) {
+			entryScore = score
+			entryPoint = name
+		}
+	}
+
+	if purpose == "" {
+		purpose = languageID + " files in " + filepath.Base(plan.DirAbsPath)
+	}
+	detailedFiles, keyFiles := packageFileDetails(files, entryPoint, opts)
+	return &Package{
+		ImportPath:   plan.RelativePath,
+		RelativePath: plan.RelativePath,
+		Purpose:      purpose,
+		FileCount:    len(plan.FileRelPaths),
+		LineCount:    totalLines,
+		Files:        detailedFiles,
+		KeyFiles:     keyFiles,
+		EntryPoint:   entryPoint,
+		Tags:         tags,
+	}, nil
+}
+
+// leadingCommentPrefixes are line comment markers of common languages. "#",
+// ";" and "%" only start a comment when followed by a space, so directives
+// such as #include are not taken for one.
+var leadingCommentPrefixes = []struct {
+	marker     string
+	trim       string // Characters stripped from the start of the comment
+	needsSpace bool
+}{
+	{marker: "//", trim: "/!"},
+	{marker: "--", trim: "-"},
+	{marker: "#", trim: "#", needsSpace: true},
+	{marker: ";", trim: ";", needsSpace: true},
+	{marker: "%", trim: "%", needsSpace: true},
+}
+
+// extractLeadingCommentPurpose returns the first sentence of a file's leading
+// comment in any common syntax (//, #, --, ;, %, /* */ or <!-- -->), skipping
+// shebangs and license and copyright headers. Line comments separated by a
+// blank line are separate comments.
+func extractLeadingCommentPurpose(content []byte) string {
+	src := strings.TrimSpace(string(content))
+	if strings.HasPrefix(src, "#!") {
+		_, src, _ = strings.Cut(src, "\n")
+	}
+	for {
+		src = strings.TrimSpace(src)
+		var text string
+		switch {
+		case strings.HasPrefix(src, "/*"):
+			end := strings.Index(src, "*/")
+			if end < 0 {
+				return ""
+			}
+			text = cleanCppBlockComment(src[2:end])
+			src = src[end+2:]
+		case strings.HasPrefix(src, "<!--"):
+			end := strings.Index(src, "-->")
+			if end < 0 {
+				return ""
+			}
+			text = strings.TrimSpace(src[4:end])
+			src = src[end+3:]
+		default:
+			var lines []string
+			for {
+				line, rest, _ := strings.Cut(src, "\n")
+				comment, ok := lineCommentText(strings.TrimSpace(line))
+				if !ok {
+					break
+				}
+				lines = append(lines, comment)
+				src = rest
+			}
+			if len(lines) == 0 {
+				return ""
+			}
+			text = strings.Join(lines, " ")
+		}
+		lower := strings.ToLower(text)
+		if strings.Contains(lower, "copyright") || strings.Contains(lower, "spdx-license-identifier") || strings.Contains(lower, "license") {
+			continue
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			return extractFirstSentence(text)
+		}
+	}
+}
+
+// lineCommentText returns the text of line when it is a line comment.
+func lineCommentText(line string) (string, bool) {
+	for _, prefix := range leadingCommentPrefixes {
+		if !strings.HasPrefix(line, prefix.marker) {
+			continue
+		}
+		rest := strings.TrimLeft(line, prefix.trim)
+		if prefix.needsSpace && rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+			return "", false
+		}
+		return strings.TrimSpace(rest), true
+	}
+	return "", false
+}
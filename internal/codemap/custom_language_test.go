@@ -0,0 +1,114 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadLanguageFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "languages.json")
+	config := `{"languages": {"terraform": {"suffixes": [".tf", ".tfvars"]}, "thrift": {"suffixes": [".thrift"], "testSuffixes": ["_test.thrift"]}}}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defs, err := LoadLanguageFile(path)
+	if err != nil {
+		t.Fatalf("LoadLanguageFile returned error: %v", err)
+	}
+	want := []CustomLanguageDef{
+		{Language: "terraform", Suffixes: []string{".tf", ".tfvars"}},
+		{Language: "thrift", Suffixes: []string{".thrift"}, TestSuffixes: []string{"_test.thrift"}},
+	}
+	if !reflect.DeepEqual(defs, want) {
+		t.Fatalf("defs = %+v, want %+v", defs, want)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"languages": {"ts": {"suffixes": [".ts"]}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLanguageFile(path); err == nil || !strings.Contains(err.Error(), "built-in") {
+		t.Fatalf("expected an error for a built-in language, got %v", err)
+	}
+
+	def, err := ParseCustomLanguageDef("Thrift")
+	if err != nil || !reflect.DeepEqual(def.languageSpec(), LanguageSpec{ID: "thrift", FileSuffixes: []string{".thrift"}}) {
+		t.Fatalf("ParseCustomLanguageDef = %+v, %v", def, err)
+	}
+	if _, err := ParseCustomLanguageDef(":.x"); err == nil {
+		t.Fatal("expected an error for a missing language")
+	}
+}
+
+func TestSuffixAnalyzerListsCustomLanguageFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                     "module example.com/app\n\ngo 1.22\n",
+		"main.go":                    "package main\n\nfunc main() {}\n",
+		"api/v1/service.thrift":      "// Copyright 2024 Example Inc.\n// Licensed under the Apache License.\n\n// Orders API service definitions.\nnamespace go orders\n",
+		"api/v1/types.thrift":        "namespace go orders\n\nstruct Order {}\n",
+		"api/v1/service_test.thrift": "// Fixtures.\nnamespace go orders\n",
+		"infra/main.tf":              "# Terraform root module for staging.\nterraform {}\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.CustomLanguages = []CustomLanguageDef{
+		{Language: "thrift", TestSuffixes: []string{"_test.thrift"}},
+		{Language: "terraform", Suffixes: []string{".tf"}},
+	}
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	byPath := make(map[string]Package)
+	for _, pkg := range cm.Packages {
+		byPath[pkg.Language+":"+pkg.RelativePath] = pkg
+	}
+	thrift, ok := byPath["thrift:api/v1"]
+	if !ok {
+		t.Fatalf("expected a thrift package, got %v", cm.Packages)
+	}
+	if thrift.Purpose != "Orders API service definitions." || thrift.FileCount != 2 || thrift.EntryPoint != "service.thrift" {
+		t.Fatalf("unexpected thrift package: %+v", thrift)
+	}
+	if tf := byPath["terraform:infra"]; tf.Purpose != "Terraform root module for staging." || tf.FileCount != 1 {
+		t.Fatalf("unexpected terraform package: %+v", tf)
+	}
+
+	var sources []string
+	for _, lang := range Languages(opts) {
+		if lang.Source == LanguageSourceCustom {
+			sources = append(sources, lang.ID+"="+strings.Join(lang.FileSuffixes, ","))
+		}
+	}
+	if !reflect.DeepEqual(sources, []string{"terraform=.tf", "thrift=.thrift"}) {
+		t.Fatalf("custom languages listed as %v", sources)
+	}
+}
+
+func TestExtractLeadingCommentPurpose(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"// Orders API. More text.\nnamespace go orders\n", "Orders API."},
+		{"#!/usr/bin/env foo\n# Runs the thing\n", "Runs the thing"},
+		{"-- Lookup tables.\n", "Lookup tables."},
+		{"/*\n * Shared styles.\n */\n", "Shared styles."},
+		{"<!-- Landing page. -->\n<html></html>\n", "Landing page."},
+		{"# SPDX-License-Identifier: MIT\n\n# Build rules.\n", "Build rules."},
+		{"#include <stdio.h>\n", ""},
+		{"resource \"x\" {}\n# trailing comment\n", ""},
+	}
+	for _, tt := range tests {
+		if got := extractLeadingCommentPurpose([]byte(tt.content)); got != tt.want {
+			t.Errorf("extractLeadingCommentPurpose(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
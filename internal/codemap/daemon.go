@@ -0,0 +1,412 @@
+package codemap
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Daemon commands accepted over the control socket.
+const (
+	DaemonCommandStatus         = "status"
+	DaemonCommandRefresh        = "refresh"
+	DaemonCommandRefreshPackage = "refresh-package"
+	DaemonCommandQuery          = "query"
+)
+
+// ErrNoDaemon is returned by CallDaemon when no daemon is listening on the
+// socket, so thin clients can fall back to doing the work themselves.
+var ErrNoDaemon = errors.New("no codemap daemon listening")
+
+// ErrDaemonOptions is returned by CallDaemon when the daemon was started
+// with options that index or render differently from the request's.
+var ErrDaemonOptions = errors.New("codemap daemon runs with different options")
+
+// DaemonRequest is a single newline-delimited JSON request sent to a daemon.
+type DaemonRequest struct {
+	Command string `json:"command"`
+	Force   bool   `json:"force,omitempty"`
+	Package string `json:"package,omitempty"` // Package path for refresh-package
+	Kind    string `json:"kind,omitempty"`    // Query kind for query, e.g. QuerySymbol
+	Arg     string `json:"arg,omitempty"`     // Query argument for query
+	Options string `json:"options,omitempty"` // DaemonOptionsSignature of the client; checked when set
+}
+
+// DaemonResponse is the daemon's reply to a DaemonRequest.
+type DaemonResponse struct {
+	OK          bool         `json:"ok"`
+	Error       string       `json:"error,omitempty"`
+	OptionsDiff bool         `json:"optionsDiff,omitempty"` // Request options differ from the daemon's
+	Stale       bool         `json:"stale"`
+	Generated   bool         `json:"generated,omitempty"`
+	ContentHash string       `json:"contentHash,omitempty"`
+	GeneratedAt time.Time    `json:"generatedAt,omitempty"`
+	Packages    int          `json:"packages"`
+	Concerns    int          `json:"concerns"`
+	Warnings    []string     `json:"warnings,omitempty"`
+	Matches     []QueryMatch `json:"matches,omitempty"`
+}
+
+// Daemon keeps a codemap model warm in memory and serves status, refresh and
+// query requests over a unix socket. Status requests are answered from a
+// signature of the tree and outputs taken when they were last found fresh,
+// so only a changed tree goes through IsStale; queries are answered from the
+// state and analysis cache kept in memory.
+type Daemon struct {
+	opts    Options
+	service *Service
+
+	mu         sync.Mutex
+	freshSig   uint64 // freshnessSignature when outputs were last found fresh
+	freshKnown bool
+	state      *CodemapState
+	cache      *AnalysisCache
+	loadedHash string // Model ContentHash state and cache were read for
+}
+
+// NewDaemon constructs a daemon for opts.
+func NewDaemon(opts Options) *Daemon {
+	return &Daemon{opts: opts, service: NewService(opts)}
+}
+
+// ResolveSocketPath returns the absolute control socket path for opts.
+func ResolveSocketPath(opts Options) (string, error) {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return "", fmt.Errorf("resolve root: %w", err)
+	}
+	return resolveSocketPath(root, opts), nil
+}
+
+func resolveSocketPath(root string, opts Options) string {
+	socketPath := opts.SocketPath
+	if socketPath == "" {
+		socketPath = ".codemap.sock"
+	}
+	if filepath.IsAbs(socketPath) {
+		return socketPath
+	}
+	return filepath.Join(root, socketPath)
+}
+
+// Serve warms the model, then answers requests on the control socket until ctx is done.
+func (d *Daemon) Serve(ctx context.Context) error {
+	socketPath, err := ResolveSocketPath(d.opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err := d.refresh(ctx, false); err != nil {
+		return fmt.Errorf("warm model: %w", err)
+	}
+
+	if err := removeStaleSocket(socketPath); err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", socketPath, err)
+	}
+	defer os.Remove(socketPath)
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	if d.opts.AutoRefreshInterval > 0 {
+		refreshCtx, stopRefresh := context.WithCancel(ctx)
+		defer stopRefresh()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.autoRefresh(refreshCtx)
+		}()
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accept: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.handleConn(ctx, conn)
+		}()
+	}
+}
+
+func (d *Daemon) handleConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	var req DaemonRequest
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err == nil || len(line) > 0 {
+		err = json.Unmarshal(line, &req)
+	}
+
+	var resp *DaemonResponse
+	if err != nil {
+		resp = &DaemonResponse{Error: fmt.Sprintf("decode request: %v", err)}
+	} else {
+		resp = d.Handle(ctx, req)
+	}
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+// Handle executes a single request against the warm model. Requests whose
+// options signature differs from the daemon's are refused, as the model
+// would not be the one the client asked for.
+func (d *Daemon) Handle(ctx context.Context, req DaemonRequest) *DaemonResponse {
+	if req.Options != "" && req.Options != DaemonOptionsSignature(d.opts) {
+		return &DaemonResponse{Error: ErrDaemonOptions.Error(), OptionsDiff: true}
+	}
+	var (
+		resp *DaemonResponse
+		err  error
+	)
+	switch req.Command {
+	case DaemonCommandStatus:
+		resp, err = d.status(ctx)
+	case DaemonCommandRefresh:
+		resp, err = d.refresh(ctx, req.Force)
+	case DaemonCommandRefreshPackage:
+		resp, err = d.refreshPackage(ctx, req.Package)
+	case DaemonCommandQuery:
+		resp, err = d.query(req.Kind, req.Arg)
+	default:
+		err = fmt.Errorf("unknown command: %q", req.Command)
+	}
+	if err != nil {
+		return &DaemonResponse{Error: err.Error()}
+	}
+	return resp
+}
+
+func (d *Daemon) status(ctx context.Context) (*DaemonResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// The signature is taken before checking, so changes made meanwhile
+	// fail the next comparison. Outputs may have been edited or removed
+	// behind the daemon's back, so their cached hashes are re-read.
+	sig, sigErr := freshnessSignature(ctx, d.opts)
+	stale := false
+	if sigErr != nil || !d.freshKnown || sig != d.freshSig {
+		forgetExistingHashes()
+		var err error
+		if stale, err = IsStale(ctx, d.opts); err != nil {
+			return nil, err
+		}
+		d.freshSig, d.freshKnown = sig, sigErr == nil && !stale
+	}
+	resp := modelResponse(d.service.Model())
+	resp.Stale = stale
+	return resp, nil
+}
+
+func (d *Daemon) refresh(ctx context.Context, force bool) (*DaemonResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.freshKnown = false
+	cm, generated, err := d.service.Refresh(ctx, force)
+	if err != nil {
+		return nil, err
+	}
+	resp := modelResponse(cm)
+	resp.Generated = generated
+	return resp, nil
+}
+
+func (d *Daemon) refreshPackage(ctx context.Context, relPath string) (*DaemonResponse, error) {
+	if relPath == "" {
+		return nil, errors.New("refresh-package requires a package path")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.freshKnown = false
+	cm, err := d.service.RefreshPackage(ctx, relPath)
+	if err != nil {
+		return nil, err
+	}
+	resp := modelResponse(cm)
+	resp.Generated = true
+	return resp, nil
+}
+
+func (d *Daemon) query(kind, arg string) (*DaemonResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	root, err := filepath.Abs(d.opts.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	model := d.service.Model()
+	if model == nil || d.loadedHash != model.ContentHash || d.state == nil {
+		if d.state, err = readState(resolveStatePath(root, d.opts)); err != nil {
+			return nil, fmt.Errorf("read state: %w", err)
+		}
+		if d.cache, err = readAnalysisCache(resolveAnalysisStatePath(root, d.opts)); err != nil {
+			return nil, fmt.Errorf("read analysis cache: %w", err)
+		}
+		if model != nil {
+			d.loadedHash = model.ContentHash
+		}
+	}
+	matches, err := queryLoaded(root, d.opts, kind, arg, d.cache, d.state)
+	if err != nil {
+		return nil, err
+	}
+	resp := modelResponse(model)
+	resp.Matches = matches
+	return resp, nil
+}
+
+// freshnessSignature fingerprints the indexed files and the markdown and
+// paths outputs by size and mtime, without reading any of them.
+func freshnessSignature(ctx context.Context, opts Options) (uint64, error) {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return 0, fmt.Errorf("resolve root: %w", err)
+	}
+	sig, err := treeSignature(ctx, root, opts)
+	if err != nil {
+		return 0, err
+	}
+	outputs := []string{opts.OutputPath, opts.PathsOutputPath}
+	if outputs[0] == "" {
+		outputs[0] = MarkdownRenderer{}.DefaultPath()
+	}
+	if outputs[1] == "" {
+		outputs[1] = PathsRenderer{}.DefaultPath()
+	}
+	h := fnv.New64a()
+	var buf [24]byte
+	binary.LittleEndian.PutUint64(buf[:8], sig)
+	_, _ = h.Write(buf[:8])
+	for _, output := range outputs {
+		// A missing output hashes as zeros.
+		clear(buf[:])
+		if info, err := os.Stat(outputAbsPath(root, output)); err == nil {
+			binary.LittleEndian.PutUint64(buf[:8], uint64(info.Size()))
+			binary.LittleEndian.PutUint64(buf[8:16], uint64(info.ModTime().UnixNano()))
+			binary.LittleEndian.PutUint64(buf[16:], 1)
+		}
+		_, _ = h.Write(buf[:])
+	}
+	return h.Sum64(), nil
+}
+
+func modelResponse(model *Codemap) *DaemonResponse {
+	resp := &DaemonResponse{OK: true}
+	if model == nil {
+		return resp
+	}
+	resp.ContentHash = model.ContentHash
+	resp.GeneratedAt = model.GeneratedAt
+	resp.Packages = len(model.Packages)
+	resp.Concerns = len(model.Concerns)
+	resp.Warnings = append([]string(nil), model.Warnings...)
+	return resp
+}
+
+// CallDaemon sends req to the daemon listening on socketPath.
+func CallDaemon(ctx context.Context, socketPath string, req DaemonRequest) (*DaemonResponse, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoDaemon, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	var resp DaemonResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.OptionsDiff {
+		return &resp, ErrDaemonOptions
+	}
+	if !resp.OK {
+		return &resp, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// DaemonOptionsSignature fingerprints the options of opts that decide what
+// a daemon indexes, renders and writes, for DaemonRequest.Options.
+func DaemonOptionsSignature(opts Options) string {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return ""
+	}
+	data, err := json.Marshal(struct {
+		Outputs           string
+		Paths             []string
+		StatePath         string
+		GitIgnore         bool
+		GitTracked        bool
+		NestedCodemaps    bool
+		ExcludePatterns   []string
+		IncludePatterns   []string
+		ExternalLanguages []string
+		Languages         []string
+	}{
+		Outputs:           outputOptionsSignature(opts),
+		Paths:             currentOutputPaths(root, opts),
+		StatePath:         resolveStatePath(root, opts),
+		GitIgnore:         opts.GitIgnore,
+		GitTracked:        opts.GitTracked,
+		NestedCodemaps:    opts.NestedCodemaps,
+		ExcludePatterns:   opts.ExcludePatterns,
+		IncludePatterns:   opts.IncludePatterns,
+		ExternalLanguages: externalLanguageSignatures(opts),
+		Languages:         languageFilterIDs(opts),
+	})
+	if err != nil {
+		return ""
+	}
+	return auxContentHash(data)
+}
+
+// removeStaleSocket deletes a leftover socket file unless another daemon is
+// still accepting connections on it.
+func removeStaleSocket(socketPath string) error {
+	if _, err := os.Lstat(socketPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	conn, err := net.DialTimeout("unix", socketPath, 200*time.Millisecond)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("daemon already running on %s", socketPath)
+	}
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove stale socket: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package codemap
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemonServesStatusAndRefresh(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/test\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatalf("write go.mod: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatalf("write main.go: %v", err)
+	}
+
+	// Unix socket paths are length-limited, so keep the socket in a short directory.
+	sockDir, err := os.MkdirTemp("", "cmsock")
+	if err != nil {
+		t.Fatalf("mkdir socket dir: %v", err)
+	}
+	defer os.RemoveAll(sockDir)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.SocketPath = filepath.Join(sockDir, "d.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewDaemon(opts).Serve(ctx) }()
+
+	var resp *DaemonResponse
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		resp, err = CallDaemon(context.Background(), opts.SocketPath, DaemonRequest{Command: DaemonCommandStatus})
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("status returned error: %v", err)
+	}
+	if resp.Stale || resp.Packages != 1 || resp.ContentHash == "" {
+		t.Fatalf("expected fresh warm model, got %+v", resp)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "util.go"), []byte("package main\n\nfunc Helper() {}\n"), 0644); err != nil {
+		t.Fatalf("write util.go: %v", err)
+	}
+	resp, err = CallDaemon(context.Background(), opts.SocketPath, DaemonRequest{Command: DaemonCommandStatus})
+	if err != nil {
+		t.Fatalf("status returned error: %v", err)
+	}
+	if !resp.Stale {
+		t.Fatalf("expected stale status after edit, got %+v", resp)
+	}
+
+	resp, err = CallDaemon(context.Background(), opts.SocketPath, DaemonRequest{Command: DaemonCommandRefresh})
+	if err != nil {
+		t.Fatalf("refresh returned error: %v", err)
+	}
+	if !resp.Generated {
+		t.Fatalf("expected refresh to regenerate, got %+v", resp)
+	}
+
+	if _, err := CallDaemon(context.Background(), opts.SocketPath, DaemonRequest{Command: "bogus"}); err == nil {
+		t.Fatal("expected error for unknown command")
+	}
+
+	same := DaemonRequest{Command: DaemonCommandStatus, Options: DaemonOptionsSignature(opts)}
+	if _, err := CallDaemon(context.Background(), opts.SocketPath, same); err != nil {
+		t.Fatalf("status with matching options returned error: %v", err)
+	}
+	other := opts
+	other.APISurface = true
+	if _, err := CallDaemon(context.Background(), opts.SocketPath, DaemonRequest{Command: DaemonCommandStatus, Options: DaemonOptionsSignature(other)}); !errors.Is(err, ErrDaemonOptions) {
+		t.Fatalf("status with other options returned %v, want ErrDaemonOptions", err)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Serve returned error: %v", err)
+	}
+	if _, err := os.Stat(opts.SocketPath); !os.IsNotExist(err) {
+		t.Fatalf("expected socket to be removed, stat err: %v", err)
+	}
+}
+
+func TestDaemonAnswersQueriesAndCachedStatus(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example.com/test\n\ngo 1.22\n",
+		"main.go":        "package main\n\nfunc main() {}\n",
+		"store/store.go": "// Package store saves things.\npackage store\n\n// Save saves.\nfunc Save() {}\n",
+	}
+	writeTestTree(t, tmpDir, files)
+	sockDir, err := os.MkdirTemp("", "cmsock")
+	if err != nil {
+		t.Fatalf("mkdir socket dir: %v", err)
+	}
+	defer os.RemoveAll(sockDir)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.SocketPath = filepath.Join(sockDir, "d.sock")
+	if _, err := CallDaemon(context.Background(), opts.SocketPath, DaemonRequest{Command: DaemonCommandStatus}); !errors.Is(err, ErrNoDaemon) {
+		t.Fatalf("expected ErrNoDaemon without a daemon, got %v", err)
+	}
+
+	d := NewDaemon(opts)
+	ctx := context.Background()
+	if _, err := d.refresh(ctx, false); err != nil {
+		t.Fatalf("refresh returned error: %v", err)
+	}
+
+	resp := d.Handle(ctx, DaemonRequest{Command: DaemonCommandQuery, Kind: QuerySymbol, Arg: "Save"})
+	if !resp.OK || len(resp.Matches) != 1 || resp.Matches[0].File != "store/store.go" {
+		t.Fatalf("unexpected symbol query response %+v", resp)
+	}
+	resp = d.Handle(ctx, DaemonRequest{Command: DaemonCommandQuery, Kind: "bogus", Arg: "x"})
+	if resp.OK || !strings.Contains(resp.Error, "unknown query") {
+		t.Fatalf("expected an unknown query error, got %+v", resp)
+	}
+
+	if resp := d.Handle(ctx, DaemonRequest{Command: DaemonCommandStatus}); !resp.OK || resp.Stale {
+		t.Fatalf("expected fresh status, got %+v", resp)
+	}
+	if !d.freshKnown {
+		t.Fatal("expected the fresh signature to be recorded")
+	}
+	if resp := d.Handle(ctx, DaemonRequest{Command: DaemonCommandStatus}); !resp.OK || resp.Stale {
+		t.Fatalf("expected fresh status from the signature, got %+v", resp)
+	}
+
+	if err := os.Remove(filepath.Join(tmpDir, "CODEMAP.paths")); err != nil {
+		t.Fatal(err)
+	}
+	if resp := d.Handle(ctx, DaemonRequest{Command: DaemonCommandStatus}); !resp.Stale {
+		t.Fatalf("expected stale status after removing an output, got %+v", resp)
+	}
+	if _, err := d.refresh(ctx, false); err != nil {
+		t.Fatalf("refresh returned error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "store", "load.go"), []byte("package store\n\n// Load loads.\nfunc Load() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if resp := d.Handle(ctx, DaemonRequest{Command: DaemonCommandStatus}); !resp.Stale {
+		t.Fatalf("expected stale status after an edit, got %+v", resp)
+	}
+	if _, err := d.refresh(ctx, false); err != nil {
+		t.Fatalf("refresh returned error: %v", err)
+	}
+	resp = d.Handle(ctx, DaemonRequest{Command: DaemonCommandQuery, Kind: QuerySymbol, Arg: "Load"})
+	if !resp.OK || len(resp.Matches) != 1 {
+		t.Fatalf("expected the reloaded cache to know Load, got %+v", resp)
+	}
+}
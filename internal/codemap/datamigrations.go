@@ -0,0 +1,229 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// migrationDirNames are directory names holding schema migrations, e.g.
+// migrations/ or Rails' db/migrate/.
+var migrationDirNames = map[string]bool{
+	"migrate":       true,
+	"migration":     true,
+	"migrations":    true,
+	"db_migrations": true,
+}
+
+// migrationFileTools guesses the migration tool from the extension of a
+// numbered migration file.
+var migrationFileTools = map[string]string{
+	".rb": "rails",
+	".py": "django",
+	".go": "goose",
+}
+
+// maxMigrationReferenceBytes bounds the files scanned for schema references.
+const maxMigrationReferenceBytes = 1 << 20
+
+type migrationCandidate struct {
+	tools    map[string]struct{}
+	names    map[string]struct{}
+	tables   map[string]struct{}
+	numbered bool // Found only through numbered .sql files, not a directory name
+}
+
+// detectMigrations finds directories of database migrations among the
+// indexed files: directories named migrations/ or migrate/, Alembic
+// versions/, Prisma and Diesel layouts of one directory per migration, and
+// directories of numbered or .up.sql files. An up and down file of one
+// migration count once. Packages reference a set when their non-test files
+// query a table the set's SQL creates or name the directory.
+func detectMigrations(ctx context.Context, root string, idx *FileIndex, packages []Package) ([]MigrationSet, error) {
+	if idx == nil {
+		return nil, nil
+	}
+	candidates := make(map[string]*migrationCandidate)
+	add := func(dir, tool, name string, numbered bool, rec FileRecord) {
+		c := candidates[dir]
+		if c == nil {
+			c = &migrationCandidate{tools: map[string]struct{}{}, names: map[string]struct{}{}, tables: map[string]struct{}{}, numbered: numbered}
+			candidates[dir] = c
+		}
+		c.numbered = c.numbered && numbered
+		c.tools[tool] = struct{}{}
+		c.names[name] = struct{}{}
+		if rec.Language != languageSQL {
+			return
+		}
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rec.RelPath)))
+		if err != nil {
+			return
+		}
+		types, _, _ := parseSQLFileObjects(content)
+		for _, typ := range types {
+			if typ.Kind == "table" {
+				name := typ.Name[strings.LastIndex(typ.Name, ".")+1:]
+				c.tables[strings.ToLower(name)] = struct{}{}
+			}
+		}
+	}
+
+	for _, rec := range idx.Files {
+		if rec.IsTest {
+			continue
+		}
+		dir := path.Dir(rec.RelPath)
+		name := path.Base(rec.RelPath)
+		ext := path.Ext(name)
+		parent := path.Dir(dir)
+		switch {
+		case dir != "." && migrationDirNames[path.Base(parent)] && (name == "migration.sql" || name == "up.sql" || name == "down.sql"):
+			tool := "diesel"
+			if name == "migration.sql" {
+				tool = "prisma"
+			}
+			add(parent, tool, path.Base(dir), false, rec)
+		case path.Base(dir) == "versions" && (path.Base(parent) == "alembic" || migrationDirNames[path.Base(parent)]):
+			if ext == ".py" && name != "__init__.py" {
+				add(dir, "alembic", strings.TrimSuffix(name, ext), false, rec)
+			}
+		case ext == ".sql":
+			migration := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(name, ext), ".up"), ".down")
+			named := migrationDirNames[path.Base(dir)] || migration != strings.TrimSuffix(name, ext)
+			if named || startsWithDigit(name) {
+				add(dir, "sql", migration, !named, rec)
+			}
+		case migrationDirNames[path.Base(dir)] && startsWithDigit(name):
+			tool, ok := migrationFileTools[ext]
+			if !ok {
+				tool = rec.Language
+			}
+			add(dir, tool, strings.TrimSuffix(name, ext), false, rec)
+		}
+	}
+
+	sets := make([]MigrationSet, 0, len(candidates))
+	for dir, c := range candidates {
+		// A lone numbered .sql file is more likely a script than a history.
+		if c.numbered && len(c.names) < 2 {
+			delete(candidates, dir)
+			continue
+		}
+		set := MigrationSet{Path: dir, Tool: strings.Join(sortedImportSet(c.tools), ", "), Count: len(c.names)}
+		for name := range c.names {
+			if set.Latest == "" || naturalLess(set.Latest, name) {
+				set.Latest = name
+			}
+		}
+		sets = append(sets, set)
+	}
+	if len(sets) == 0 {
+		return nil, nil
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Path < sets[j].Path })
+
+	// Files beside a set may name it relative to their own directory.
+	patterns := make([]*regexp.Regexp, len(sets))
+	relative := make([]*regexp.Regexp, len(sets))
+	for i, set := range sets {
+		relative[i] = regexp.MustCompile(migrationPathPattern(path.Base(set.Path)))
+		alternatives := []string{migrationPathPattern(set.Path)}
+		if tables := sortedImportSet(candidates[set.Path].tables); len(tables) > 0 {
+			for j, table := range tables {
+				tables[j] = regexp.QuoteMeta(table)
+			}
+			alternatives = append(alternatives, "(?i:\\b(?:from|join|into|update|table)\\s+[`\"\\[]?(?:\\w+[`\"\\]]?\\.[`\"\\[]?)?(?:"+strings.Join(tables, "|")+")\\b)")
+		}
+		patterns[i] = regexp.MustCompile(strings.Join(alternatives, "|"))
+	}
+
+	owners := newStatsOwners(packages)
+	referencedBy := make([]map[string]struct{}, len(sets))
+	for _, rec := range idx.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if rec.IsTest || rec.Size > maxMigrationReferenceBytes || inMigrationSet(rec.RelPath, sets) {
+			continue
+		}
+		pkgIndex, ok := owners.find(rec.Language, rec.RelPath)
+		if !ok {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rec.RelPath)))
+		if err != nil {
+			continue
+		}
+		dir := path.Dir(rec.RelPath)
+		for i, pattern := range patterns {
+			if !pattern.Match(content) && (dir != path.Dir(sets[i].Path) || !relative[i].Match(content)) {
+				continue
+			}
+			if referencedBy[i] == nil {
+				referencedBy[i] = make(map[string]struct{})
+			}
+			referencedBy[i][packages[pkgIndex].RelativePath] = struct{}{}
+		}
+	}
+	for i := range sets {
+		sets[i].ReferencedBy = sortedImportSet(referencedBy[i])
+	}
+	return sets, nil
+}
+
+// migrationPathPattern matches dir quoted or as a path, e.g. in
+// //go:embed migrations/*.sql or a "db/migrate" config entry.
+func migrationPathPattern(dir string) string {
+	return "(?:^|[\\s\"'`(=/])" + regexp.QuoteMeta(dir) + "(?:[/\"'`)]|$)"
+}
+
+func inMigrationSet(relPath string, sets []MigrationSet) bool {
+	for _, set := range sets {
+		if strings.HasPrefix(relPath, set.Path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+// naturalLess orders strings with runs of digits compared by value, so
+// "10_add" sorts after "9_init".
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		if startsWithDigit(a) && startsWithDigit(b) {
+			na, ra := splitLeadingDigits(a)
+			nb, rb := splitLeadingDigits(b)
+			na, nb = strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = ra, rb
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func splitLeadingDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i], s[i:]
+}
@@ -0,0 +1,72 @@
+package codemap
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectMigrations(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                             "module example.com/app\n\ngo 1.22\n",
+		"main.go":                            "package main\n\nfunc main() {}\n",
+		"db/db.go":                           "package db\n\nimport \"embed\"\n\n//go:embed migrations/*.sql\nvar Migrations embed.FS\n",
+		"db/migrations/9_users.up.sql":       "CREATE TABLE IF NOT EXISTS public.users (id int);\n",
+		"db/migrations/9_users.down.sql":     "DROP TABLE users;\n",
+		"db/migrations/10_orders.up.sql":     "CREATE TABLE orders (id int);\n",
+		"db/migrations/10_orders.down.sql":   "DROP TABLE orders;\n",
+		"db/migrations/2_index.sql":          "CREATE INDEX users_id ON users (id);\n",
+		"store/orders.go":                    "package store\n\nconst q = `SELECT id FROM \"orders\" WHERE id = $1`\n",
+		"store/orders_test.go":               "package store\n\nconst fixture = `INSERT INTO users VALUES (1)`\n",
+		"api/api.go":                         "package api\n\n// Users are listed from the store.\nfunc Users() {}\n",
+		"web/app/migrations/__init__.py":     "",
+		"web/app/migrations/0001_initial.py": "from django.db import migrations\n",
+		"web/app/migrations/0002_auto.py":    "from django.db import migrations\n",
+		"web/app/models.py":                  "class Order:\n    pass\n",
+		"scripts/1_cleanup.sql":              "DELETE FROM users;\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	want := []MigrationSet{
+		{Path: "db/migrations", Tool: "sql", Count: 3, Latest: "10_orders", ReferencedBy: []string{"db", "scripts", "store"}},
+		{Path: "web/app/migrations", Tool: "django", Count: 2, Latest: "0002_auto"},
+	}
+	if !reflect.DeepEqual(cm.Migrations, want) {
+		t.Fatalf("Migrations =\n%+v\nwant\n%+v", cm.Migrations, want)
+	}
+
+	content, err := Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if row := "| db/migrations | sql | 3 | 10_orders | db, scripts, store |"; !strings.Contains(content, "## Data / Migrations") || !strings.Contains(content, row) {
+		t.Fatalf("expected Data / Migrations section with %q:\n%s", row, content)
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"9_init", "10_add", true},
+		{"0002_auto", "0010_auto", true},
+		{"20240101_a", "20231231_b", false},
+		{"001_a", "1_b", true},
+		{"init", "init_more", true},
+		{"b", "a", false},
+	}
+	for _, tt := range tests {
+		if got := naturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package codemap
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// assignGoModules maps each Go package's external imports to the owning
+// module required by the root go.mod, so third-party usage can be summarized
+// per module instead of per import path. Standard library imports are
+// skipped.
+func assignGoModules(root string, packages []Package) {
+	_, requires := parseGoModManifest(filepath.Join(root, "go.mod"))
+	for i := range packages {
+		pkg := &packages[i]
+		if pkg.Language != languageGo || len(pkg.ExternalImports) == 0 {
+			continue
+		}
+		seen := make(map[string]struct{})
+		for _, imp := range pkg.ExternalImports {
+			if mod := owningGoModule(imp, requires); mod != "" {
+				seen[mod] = struct{}{}
+			}
+		}
+		pkg.ExternalModules = sortedImportSet(seen)
+	}
+}
+
+// owningGoModule returns the longest required module path containing imp.
+// Imports outside every required module fall back to the import path itself,
+// and standard library imports (no dot in the first element) return "".
+func owningGoModule(imp string, requires []string) string {
+	best := ""
+	for _, mod := range requires {
+		if (imp == mod || strings.HasPrefix(imp, mod+"/")) && len(mod) > len(best) {
+			best = mod
+		}
+	}
+	if best != "" {
+		return best
+	}
+	first, _, _ := strings.Cut(imp, "/")
+	if !strings.Contains(first, ".") {
+		return ""
+	}
+	return imp
+}
+
+type externalModuleRow struct {
+	Module      string
+	Packages    int
+	ImportPaths int
+}
+
+// externalModuleRows summarizes Go third-party dependencies per module with
+// the number of packages using it and the distinct import paths involved.
+func externalModuleRows(packages []Package) []externalModuleRow {
+	users := make(map[string]int)
+	imports := make(map[string]map[string]struct{})
+	for _, pkg := range packages {
+		if len(pkg.ExternalModules) == 0 {
+			continue
+		}
+		for _, mod := range pkg.ExternalModules {
+			users[mod]++
+			if imports[mod] == nil {
+				imports[mod] = make(map[string]struct{})
+			}
+		}
+		for _, imp := range pkg.ExternalImports {
+			for _, mod := range pkg.ExternalModules {
+				if imp == mod || strings.HasPrefix(imp, mod+"/") {
+					imports[mod][imp] = struct{}{}
+				}
+			}
+		}
+	}
+
+	rows := make([]externalModuleRow, 0, len(users))
+	for mod, count := range users {
+		rows = append(rows, externalModuleRow{Module: mod, Packages: count, ImportPaths: len(imports[mod])})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Packages != rows[j].Packages {
+			return rows[i].Packages > rows[j].Packages
+		}
+		return rows[i].Module < rows[j].Module
+	})
+	return rows
+}
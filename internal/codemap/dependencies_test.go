@@ -0,0 +1,69 @@
+package codemap
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOwningGoModule(t *testing.T) {
+	requires := []string{"golang.org/x/tools", "golang.org/x/tools/gopls", "github.com/pkg/errors"}
+	tests := map[string]string{
+		"golang.org/x/tools/go/ast/astutil": "golang.org/x/tools",
+		"golang.org/x/tools/gopls/internal": "golang.org/x/tools/gopls",
+		"github.com/pkg/errors":             "github.com/pkg/errors",
+		"github.com/other/lib/sub":          "github.com/other/lib/sub",
+		"net/http":                          "",
+	}
+	for imp, want := range tests {
+		if got := owningGoModule(imp, requires); got != want {
+			t.Fatalf("owningGoModule(%q) = %q, want %q", imp, got, want)
+		}
+	}
+}
+
+func TestAnalyzeGroupsGoImportsByModule(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/test\n\ngo 1.22\n\nrequire (\n\tgithub.com/acme/kit v1.2.0\n\tgolang.org/x/sync v0.7.0\n)\n",
+		"a/a.go": "package a\n\nimport (\n\t\"fmt\"\n\t\"github.com/acme/kit/log\"\n\t\"github.com/acme/kit/metrics\"\n\t\"golang.org/x/sync/errgroup\"\n)\n",
+		"b/b.go": "package b\n\nimport (\n\t\"github.com/acme/kit/log\"\n\t\"example.com/test/a\"\n)\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	modules := make(map[string][]string)
+	for _, pkg := range cm.Packages {
+		modules[pkg.RelativePath] = pkg.ExternalModules
+	}
+	want := map[string][]string{
+		"a": {"github.com/acme/kit", "golang.org/x/sync"},
+		"b": {"github.com/acme/kit"},
+	}
+	if !reflect.DeepEqual(modules, want) {
+		t.Fatalf("unexpected modules: got %v want %v", modules, want)
+	}
+
+	rows := externalModuleRows(cm.Packages)
+	wantRows := []externalModuleRow{
+		{Module: "github.com/acme/kit", Packages: 2, ImportPaths: 2},
+		{Module: "golang.org/x/sync", Packages: 1, ImportPaths: 1},
+	}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Fatalf("unexpected rows: got %+v want %+v", rows, wantRows)
+	}
+
+	content, err := Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if !strings.Contains(content, "| github.com/acme/kit | 2 | 2 |") {
+		t.Fatalf("expected module summary in output:\n%s", content)
+	}
+}
@@ -0,0 +1,98 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestGenerateIsDeterministic runs Generate cold and warm under varied
+// GOMAXPROCS and requires byte-identical outputs, so parallel analysis and
+// cache reuse can never leak scheduling order into what gets written.
+func TestGenerateIsDeterministic(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	tmpDir := t.TempDir()
+	writeDeterminismFixture(t, tmpDir)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.LargePackageFiles = 3
+	opts.ConcernExampleLimit = 3
+	opts.HashesOutputPath = "CODEMAP.hashes"
+	opts.AgentsOutputPath = "CODEMAP.agents.md"
+	outputs := []string{opts.OutputPath, opts.PathsOutputPath, opts.HashesOutputPath, opts.AgentsOutputPath}
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	var baseline map[string]string
+	for _, procs := range []int{1, 2, 4, 8, 1} {
+		runtime.GOMAXPROCS(procs)
+		for _, cold := range []bool{true, false} {
+			if cold {
+				for _, name := range []string{".codemap.state.json", ".codemap.state.analysis.json"} {
+					if err := os.Remove(filepath.Join(tmpDir, name)); err != nil && !os.IsNotExist(err) {
+						t.Fatal(err)
+					}
+				}
+			} else {
+				// Touch one file so the warm run mixes cached and fresh packages.
+				touched := filepath.Join(tmpDir, "pkg03", "file1.go")
+				now := time.Now().Add(time.Duration(procs) * time.Second)
+				if err := os.Chtimes(touched, now, now); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := Generate(context.Background(), opts); err != nil {
+				t.Fatalf("Generate (GOMAXPROCS=%d, cold=%v) returned error: %v", procs, cold, err)
+			}
+			got := make(map[string]string, len(outputs))
+			for _, name := range outputs {
+				data, err := os.ReadFile(filepath.Join(tmpDir, name))
+				if err != nil {
+					t.Fatalf("read %s: %v", name, err)
+				}
+				got[name] = string(data)
+			}
+			if baseline == nil {
+				baseline = got
+				continue
+			}
+			for _, name := range outputs {
+				if got[name] != baseline[name] {
+					t.Fatalf("%s differs (GOMAXPROCS=%d, cold=%v):\n%s\n--- baseline ---\n%s", name, procs, cold, got[name], baseline[name])
+				}
+			}
+		}
+	}
+}
+
+// writeDeterminismFixture builds a multi-language tree in which several
+// files per package compete for the package purpose, entry point and tags.
+func writeDeterminismFixture(t *testing.T, root string) {
+	t.Helper()
+	files := map[string]string{
+		"go.mod":                 "module example.com/det\n\ngo 1.22\n",
+		"main.go":                "// Command det exercises determinism.\npackage main\n\nfunc main() {}\n",
+		"tools/py/__init__.py":   "\"\"\"Python helpers.\"\"\"\n",
+		"tools/py/helpers.py":    "\"\"\"Helper functions.\"\"\"\n\ndef run():\n    pass\n",
+		"web/src/index.ts":       "// Web entry.\nexport function start(): void {}\n",
+		"web/src/api.ts":         "// API client.\nexport class Client {}\n",
+		"scripts/build.sh":       "#!/bin/sh\n# Build everything.\necho build\n",
+		"scripts/release.sh":     "#!/bin/sh\n# Cut a release.\necho release\n",
+		"internal/errors.go":     "// Package internal holds shared errors.\npackage internal\n",
+		"internal/error_test.go": "package internal\n",
+	}
+	for i := 0; i < 12; i++ {
+		dir := fmt.Sprintf("pkg%02d", i)
+		for j := 0; j < 4; j++ {
+			files[fmt.Sprintf("%s/file%d.go", dir, j)] = fmt.Sprintf(
+				"// Package %s file %d describes itself.\n//\n// codemap:tag=group%d\npackage %s\n\n// Type%d is exported.\ntype Type%d struct{}\n\nfunc New%d() *Type%d { return nil }\n",
+				dir, j, (i+j)%3, dir, j, j, j, j)
+		}
+	}
+	writeTestTree(t, root, files)
+}
@@ -0,0 +1,101 @@
+package codemap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrDiffBudgetExceeded is returned when regenerating the markdown output
+// would change more lines than Options.DiffBudgetLines allows.
+var ErrDiffBudgetExceeded = errors.New("diff budget exceeded")
+
+// applyDiffBudget compares the markdown that would be written to outputPath
+// with the file already there. Over budget, it either drops file listings
+// (Options.DiffBudgetCoarsen) and checks again, or fails before anything is
+// written. A missing output has no budget to protect.
+func applyDiffBudget(outputPath string, renderer Renderer, cm *Codemap, opts Options) error {
+	if opts.DiffBudgetLines <= 0 {
+		return nil
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("read existing %s output: %w", renderer.Name(), err)
+	}
+	previous := diffBudgetLines(string(data))
+
+	changed, err := renderedLineChanges(renderer, cm, previous, opts.DiffBudgetLines)
+	if err != nil || changed <= opts.DiffBudgetLines {
+		return err
+	}
+	if opts.DiffBudgetCoarsen {
+		coarse := *cm
+		coarse.Packages = make([]Package, len(cm.Packages))
+		for i, pkg := range cm.Packages {
+			pkg.Files = nil
+			coarse.Packages[i] = pkg
+		}
+		coarse.Warnings = append(append([]string(nil), cm.Warnings...),
+			fmt.Sprintf("output changed by more than %d lines; file listings omitted", opts.DiffBudgetLines))
+		changed, err = renderedLineChanges(renderer, &coarse, previous, opts.DiffBudgetLines)
+		if err != nil {
+			return err
+		}
+		if changed <= opts.DiffBudgetLines {
+			*cm = coarse
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s would change by more than %d lines", ErrDiffBudgetExceeded, outputPath, opts.DiffBudgetLines)
+}
+
+func renderedLineChanges(renderer Renderer, cm *Codemap, previous []string, budget int) (int, error) {
+	content, err := renderer.Render(cm)
+	if err != nil {
+		return 0, fmt.Errorf("render %s: %w", renderer.Name(), err)
+	}
+	return lineEditDistance(previous, diffBudgetLines(content), budget), nil
+}
+
+// diffBudgetLines splits content into lines, skipping the leading comment
+// header whose hash and timestamp change on every regeneration.
+func diffBudgetLines(content string) []string {
+	lines := strings.Split(content, "\n")
+	for len(lines) > 0 && strings.HasPrefix(lines[0], "<!--") {
+		lines = lines[1:]
+	}
+	return lines
+}
+
+// lineEditDistance counts added plus removed lines between a and b using
+// Myers' algorithm, giving up once the count exceeds limit (and returning
+// limit+1), so large rewrites cost O(limit) passes rather than O(len(a)*len(b)).
+func lineEditDistance(a, b []string, limit int) int {
+	n, m := len(a), len(b)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return d
+			}
+		}
+	}
+	return limit + 1
+}
@@ -0,0 +1,88 @@
+package codemap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"a b c", "a b c", 5, 0},
+		{"a b c", "a x c", 5, 2},
+		{"a b c", "a b c d", 5, 1},
+		{"a b c d e f", "u v w x y z", 3, 4},
+	}
+	for _, tt := range tests {
+		got := lineEditDistance(strings.Fields(tt.a), strings.Fields(tt.b), tt.limit)
+		if got != tt.want {
+			t.Errorf("lineEditDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestDiffBudgetFailsOrCoarsens(t *testing.T) {
+	tmpDir := t.TempDir()
+	pkgDir := filepath.Join(tmpDir, "big")
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"config.go", "handler.go", "store.go"} {
+		content := fmt.Sprintf("package big\n\n// Type%d is exported.\ntype Type%d struct{}\n", i, i)
+		if err := os.WriteFile(filepath.Join(pkgDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.LargePackageFiles = 100
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	outputPath := filepath.Join(tmpDir, "CODEMAP.md")
+	before, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Lowering the threshold adds a Large Package Files section for "big".
+	opts.LargePackageFiles = 2
+	opts.DiffBudgetLines = 2
+	if _, err := Generate(context.Background(), opts); !errors.Is(err, ErrDiffBudgetExceeded) {
+		t.Fatalf("expected ErrDiffBudgetExceeded, got %v", err)
+	}
+	after, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Fatal("expected output to be left untouched when over budget")
+	}
+
+	opts.DiffBudgetCoarsen = true
+	opts.DiffBudgetLines = 4
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("expected coarsened output to fit the budget, got %v", err)
+	}
+	for _, pkg := range cm.Packages {
+		if len(pkg.Files) != 0 {
+			t.Fatalf("expected file listings to be dropped, got %+v", pkg.Files)
+		}
+	}
+	if len(cm.Warnings) == 0 || !strings.Contains(cm.Warnings[len(cm.Warnings)-1], "file listings omitted") {
+		t.Fatalf("expected coarsening warning, got %v", cm.Warnings)
+	}
+}
@@ -2,9 +2,13 @@ package codemap
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"os"
 	"sort"
+	"strings"
 )
 
 // AnalysisInput provides shared context for analyzer implementations.
@@ -14,6 +18,17 @@ type AnalysisInput struct {
 	Options   Options
 	PrevState *CodemapState
 	NextState *CodemapState
+
+	aux *auxInputs // Records files outside the index that analyzers read; nil outside AnalyzeWithRegistry
+}
+
+// auxInputs returns in's recorder of auxiliary reads, or a fresh one whose
+// reads are not kept when in has none.
+func (in AnalysisInput) auxInputs() *auxInputs {
+	if in.aux != nil {
+		return in.aux
+	}
+	return newAuxInputs(in.Root)
 }
 
 // Analyzer builds a codemap model from a project snapshot.
@@ -69,14 +84,31 @@ func (r *AnalyzerRegistry) LanguageIDs() []string {
 	return ids
 }
 
-// DefaultAnalyzerRegistry returns the current built-in analyzer registry.
+// DefaultAnalyzerRegistry returns the built-in analyzers plus those added with
+// RegisterAnalyzer, which replace built-ins for the same language.
 func DefaultAnalyzerRegistry() *AnalyzerRegistry {
+	registry := builtinAnalyzerRegistry()
+	for _, analyzer := range registeredAnalyzerList() {
+		registry.Register(analyzer)
+	}
+	return registry
+}
+
+func builtinAnalyzerRegistry() *AnalyzerRegistry {
 	registry := NewAnalyzerRegistry()
 	registry.Register(GoAnalyzer{})
 	registry.Register(PythonAnalyzer{})
 	registry.Register(ShellAnalyzer{})
 	registry.Register(TypeScriptAnalyzer{})
+	registry.Register(JavaScriptAnalyzer{})
 	registry.Register(RustAnalyzer{})
+	registry.Register(CppAnalyzer{})
+	registry.Register(RubyAnalyzer{})
+	registry.Register(SQLAnalyzer{})
+	registry.Register(ProtoAnalyzer{})
+	registry.Register(StyleAnalyzer{})
+	registry.Register(TemplateAnalyzer{})
+	registry.Register(ConfigAnalyzer{})
 	return registry
 }
 
@@ -102,6 +134,14 @@ func AnalyzeWithRegistry(ctx context.Context, in AnalysisInput, registry *Analyz
 		ProjectRoot: in.Root,
 		Packages:    make([]Package, 0),
 	}
+	ctx, skipped := withSkippedPackageCounter(ctx)
+	aux := newAuxInputs(in.Root)
+	in.aux = aux
+	for _, id := range languageFilterIDs(in.Options) {
+		if _, ok := registry.AnalyzerFor(id); !ok {
+			merged.Warnings = append(merged.Warnings, fmt.Sprintf("no analyzer for language %q of the language filter", id))
+		}
+	}
 
 	for i, languageID := range selectedIDs {
 		analyzer, ok := registry.AnalyzerFor(languageID)
@@ -110,28 +150,174 @@ func AnalyzeWithRegistry(ctx context.Context, in AnalysisInput, registry *Analyz
 		}
 		cm, err := analyzer.Analyze(ctx, in)
 		if err != nil {
-			return nil, err
+			// One failing language should not sink the others unless strict
+			// mode asks for the whole run to fail.
+			if in.Options.StrictAnalyzers || ctx.Err() != nil {
+				return nil, err
+			}
+			merged.Warnings = append(merged.Warnings, fmt.Sprintf("%s analyzer failed, its packages are omitted: %v", languageID, err))
+			continue
 		}
 		if cm == nil {
 			continue
 		}
+		assignPackageIdentity(cm.Packages, languageID)
 		merged.Packages = append(merged.Packages, cm.Packages...)
+		merged.Warnings = append(merged.Warnings, cm.Warnings...)
 		if i == 0 {
 			merged.Concerns = cm.Concerns
 		}
 	}
+	if n := skipped.Load(); n > 0 {
+		merged.Warnings = append(merged.Warnings, skippedPackagesWarning(n))
+	}
+	nested := nestedCodemapPackages(aux, in.Index)
+	assignPackageIdentity(nested, languageNestedCodemap)
+	merged.Packages = append(merged.Packages, nested...)
 
 	sortPackages(merged.Packages)
+	merged.Warnings = append(merged.Warnings, validateEntryPoints(merged.Packages, in.Index)...)
+	assignCompanions(aux, merged.Packages)
+	assignPlatformVariants(merged.Packages, in.Index, in.Options.IncludeTests)
+	assignSecurityImports(merged.Packages, in.Options.SecurityImportPatterns)
+	assignGoModules(in.Root, merged.Packages)
+	assignPackageDependencies(merged.Packages)
+	assignImplementations(merged.Packages)
+	if !in.Options.IncludeUnexported {
+		dropPrivateSymbols(merged.Packages)
+	}
+	assignGeneratedFrom(in.Root, in.Index, merged.Packages)
+	scans := newFileScans(in)
+	if err := assignTestFiles(ctx, scans, in.Index, merged.Packages); err != nil {
+		return nil, fmt.Errorf("assign test files: %w", err)
+	}
+	owners := loadCodeOwners(aux)
+	assignPackageOwners(merged.Packages, owners)
+	merged.Services = detectServices(aux, merged.Packages, owners)
+	merged.Binaries = detectBinaries(aux, scans, in.Index, merged.Packages)
+	merged.Toolchains = detectToolchains(aux, merged.Packages)
+	migrations, err := detectMigrations(ctx, in.Root, in.Index, merged.Packages)
+	if err != nil {
+		return nil, fmt.Errorf("detect migrations: %w", err)
+	}
+	merged.Migrations = migrations
+	endpoints, err := detectHTTPEndpoints(ctx, scans, in.Index)
+	if err != nil {
+		return nil, fmt.Errorf("detect http endpoints: %w", err)
+	}
+	merged.Endpoints = endpoints
+	if in.Options.Statistics || in.Options.StatisticsPackages {
+		stats, err := computeStatistics(ctx, in.Index, merged.Packages, in.Options.StatisticsPackages)
+		if err != nil {
+			return nil, fmt.Errorf("compute statistics: %w", err)
+		}
+		merged.Statistics = stats
+	}
+	if in.Options.CoChange || in.Options.DetailBudget > 0 {
+		// Adaptive detail ranks without churn outside git repositories.
+		commits, err := recentCommits(ctx, in.Root, in.Options)
+		if in.Options.CoChange {
+			if err != nil {
+				merged.Warnings = append(merged.Warnings, fmt.Sprintf("co-change analysis skipped: %v", err))
+			} else {
+				assignCoChanges(commits, merged.Packages)
+			}
+		}
+		if in.Options.DetailBudget > 0 {
+			assignAdaptiveDetail(merged.Packages, commits, in.Options)
+		}
+	}
+	assignFileRoles(in.Root, merged.Packages, in.Options.FileRoles)
 	if merged.Concerns == nil {
-		concerns, err := buildConcerns(in.Index, in.Options.Concerns, in.Options.ConcernExampleLimit)
+		concerns, err := cachedConcerns(in)
 		if err != nil {
 			return nil, fmt.Errorf("build concerns: %w", err)
 		}
 		merged.Concerns = concerns
 	}
+	if in.NextState != nil {
+		in.NextState.AuxFiles = aux.states()
+	}
+	scans.store(in.NextState)
 	return merged, nil
 }
 
+// PackageID returns a stable package identifier derived from the analyzer
+// language and the package's relative path, so consumers can track a package
+// across runs regardless of output ordering.
+func PackageID(language, relPath string) string {
+	sum := sha256.Sum256([]byte(language + "\x00" + relPath))
+	return hex.EncodeToString(sum[:6])
+}
+
+func assignPackageIdentity(packages []Package, languageID string) {
+	for i := range packages {
+		if packages[i].Language == "" {
+			packages[i].Language = languageID
+		}
+		packages[i].ID = PackageID(packages[i].Language, packages[i].RelativePath)
+	}
+}
+
+// validateEntryPoints checks that every entry path exists in the file index.
+// Cached analysis can reference files deleted since caching; such entries fall
+// back to the shallowest, lexically first file under the package and produce a
+// warning so agents are never routed to a nonexistent file.
+func validateEntryPoints(packages []Package, idx *FileIndex) []string {
+	indexed := make(map[string]struct{}, len(idx.Files))
+	for _, rec := range idx.Files {
+		indexed[rec.RelPath] = struct{}{}
+	}
+
+	var warnings []string
+	for i := range packages {
+		pkg := &packages[i]
+		if pkg.EntryPoint == "" || isNestedCodemap(*pkg) {
+			continue
+		}
+		missing := entryPath(*pkg)
+		if _, ok := indexed[missing]; ok {
+			continue
+		}
+
+		pkg.EntryPoint = fallbackEntryPoint(pkg.RelativePath, idx)
+		if pkg.EntryPoint == "" {
+			warnings = append(warnings, fmt.Sprintf("entry file %s no longer exists", missing))
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("entry file %s no longer exists; using %s", missing, entryPath(*pkg)))
+	}
+	return warnings
+}
+
+func fallbackEntryPoint(pkgRel string, idx *FileIndex) string {
+	pkgRel = packageFileDir(pkgRel)
+	prefix := ""
+	if pkgRel != "" && pkgRel != "." {
+		prefix = pkgRel + "/"
+	}
+
+	best := ""
+	bestDepth := -1
+	bestIsTest := true
+	for _, rec := range idx.Files {
+		if !strings.HasPrefix(rec.RelPath, prefix) {
+			continue
+		}
+		within := strings.TrimPrefix(rec.RelPath, prefix)
+		depth := strings.Count(within, "/")
+		better := best == "" ||
+			(bestIsTest && !rec.IsTest) ||
+			(bestIsTest == rec.IsTest && (depth < bestDepth || (depth == bestDepth && within < best)))
+		if better {
+			best = within
+			bestDepth = depth
+			bestIsTest = rec.IsTest
+		}
+	}
+	return best
+}
+
 func selectedAnalyzerLanguageIDs(idx *FileIndex, registry *AnalyzerRegistry) []string {
 	if idx == nil || registry == nil {
 		return nil
@@ -201,19 +387,50 @@ func (GoAnalyzer) Analyze(ctx context.Context, in AnalysisInput) (*Codemap, erro
 }
 
 // MarkdownRenderer renders CODEMAP.md output.
-type MarkdownRenderer struct{}
+type MarkdownRenderer struct {
+	PurposeLength int  // Max purpose length in runes (0 = 60).
+	TOC           bool // Start with a table of contents linking to each section.
+	APISurface    bool // List the function signatures of each package.
+	// Template is a text/template file used instead of the built-in layout.
+	// It executes on the Codemap with the built-in helper funcs and must
+	// render the codemap-hash header.
+	Template string
+	// PageDir links each package row to its page written by
+	// Options.SplitOutputDir, as a path relative to the markdown output.
+	PageDir string
+}
 
 func (MarkdownRenderer) Name() string        { return "markdown" }
 func (MarkdownRenderer) DefaultPath() string { return "CODEMAP.md" }
-func (MarkdownRenderer) Render(cm *Codemap) (string, error) {
-	return Render(cm)
+func (r MarkdownRenderer) Render(cm *Codemap) (string, error) {
+	if r.Template == "" {
+		return renderMarkdown(cm, r, codemapTemplate)
+	}
+	layout, err := os.ReadFile(r.Template)
+	if err != nil {
+		return "", fmt.Errorf("read markdown template: %w", err)
+	}
+	content, err := renderMarkdown(cm, r, string(layout))
+	if err != nil {
+		return "", err
+	}
+	if !strings.Contains(content, "codemap-hash: "+cm.ContentHash) {
+		return "", fmt.Errorf("markdown template %s does not render the codemap-hash header", r.Template)
+	}
+	return content, nil
 }
 
 // PathsRenderer renders CODEMAP.paths output.
-type PathsRenderer struct{}
+type PathsRenderer struct {
+	Mini          bool // Emit only the hash header and package/entry rows.
+	PurposeLength int  // Max purpose length in runes (0 = 80).
+}
 
 func (PathsRenderer) Name() string        { return "paths" }
 func (PathsRenderer) DefaultPath() string { return "CODEMAP.paths" }
-func (PathsRenderer) Render(cm *Codemap) (string, error) {
-	return RenderPaths(cm), nil
+func (r PathsRenderer) Render(cm *Codemap) (string, error) {
+	if r.Mini {
+		return RenderPathsMini(cm), nil
+	}
+	return renderPaths(cm, r.PurposeLength), nil
 }
@@ -2,8 +2,10 @@ package codemap
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -238,3 +240,153 @@ func TestAnalyzeIncludesPythonAndShellAndIgnoresUnsupportedFiles(t *testing.T) {
 		t.Fatalf("expected Go, Python, and Shell packages, got %+v", cm.Packages)
 	}
 }
+
+func TestAnalyzeWithRegistryFallsBackForMissingEntryFiles(t *testing.T) {
+	goAnalyzer := &stubLanguageAnalyzer{
+		id: languageGo,
+		packages: []Package{
+			{ImportPath: "example.com/app/api", RelativePath: "api", EntryPoint: "deleted.go"},
+			{ImportPath: "example.com/app/gone", RelativePath: "gone", EntryPoint: "gone.go"},
+			{ImportPath: "example.com/app", RelativePath: ".", EntryPoint: "main.go"},
+		},
+	}
+	registry := NewAnalyzerRegistry()
+	registry.Register(goAnalyzer)
+
+	idx := &FileIndex{
+		Files: []FileRecord{
+			{RelPath: "main.go", Language: languageGo},
+			{RelPath: "api/v1/routes.go", Language: languageGo},
+			{RelPath: "api/server.go", Language: languageGo},
+			{RelPath: "api/a_test.go", Language: languageGo, IsTest: true},
+		},
+	}
+
+	cm, err := AnalyzeWithRegistry(context.Background(), AnalysisInput{
+		Root:    "/tmp/repo",
+		Index:   idx,
+		Options: DefaultOptions(),
+	}, registry)
+	if err != nil {
+		t.Fatalf("AnalyzeWithRegistry returned error: %v", err)
+	}
+
+	entries := make(map[string]string, len(cm.Packages))
+	for _, pkg := range cm.Packages {
+		entries[pkg.RelativePath] = pkg.EntryPoint
+	}
+	if entries["."] != "main.go" || entries["api"] != "server.go" || entries["gone"] != "" {
+		t.Fatalf("unexpected entry points after validation: %+v", entries)
+	}
+	if len(cm.Warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", cm.Warnings)
+	}
+}
+
+type failingLanguageAnalyzer struct {
+	id string
+}
+
+func (a failingLanguageAnalyzer) LanguageID() string { return a.id }
+
+func (failingLanguageAnalyzer) Analyze(context.Context, AnalysisInput) (*Codemap, error) {
+	return nil, errors.New("grammar init failed")
+}
+
+func TestAnalyzeWithRegistryIsolatesAnalyzerFailures(t *testing.T) {
+	goAnalyzer := &stubLanguageAnalyzer{
+		id:       languageGo,
+		packages: []Package{{ImportPath: "example.com/app", RelativePath: ".", EntryPoint: "main.go"}},
+	}
+	registry := NewAnalyzerRegistry()
+	registry.Register(goAnalyzer)
+	registry.Register(failingLanguageAnalyzer{id: languageRust})
+
+	idx := &FileIndex{
+		Files: []FileRecord{
+			{RelPath: "main.go", Language: languageGo},
+			{RelPath: "src/lib.rs", Language: languageRust},
+		},
+	}
+	in := AnalysisInput{Root: "/tmp/repo", Index: idx, Options: DefaultOptions()}
+
+	cm, err := AnalyzeWithRegistry(context.Background(), in, registry)
+	if err != nil {
+		t.Fatalf("AnalyzeWithRegistry returned error: %v", err)
+	}
+	if len(cm.Packages) != 1 || cm.Packages[0].ImportPath != "example.com/app" {
+		t.Fatalf("expected Go package to survive Rust failure, got %+v", cm.Packages)
+	}
+	if len(cm.Warnings) != 1 || !strings.Contains(cm.Warnings[0], "rust analyzer failed") {
+		t.Fatalf("expected rust failure warning, got %v", cm.Warnings)
+	}
+
+	in.Options.StrictAnalyzers = true
+	if _, err := AnalyzeWithRegistry(context.Background(), in, registry); err == nil {
+		t.Fatal("expected strict mode to return the analyzer error")
+	}
+}
+
+func TestAnalyzeWarnsAboutSkippedPackages(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":          "module example.com/app\n\ngo 1.22\n",
+		"good/good.go":    "package good\n",
+		"bad/bad.go":      "package bad\n\nfunc {\n",
+		"worse/worse.go":  "package worse\n\ntype\n",
+		"worse/other.go":  "package worse\n",
+		"python/tool.py":  "def run():\n    pass\n",
+		"python/other.py": "X = 1\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	want := "2 packages skipped due to parse errors; run with -v for details"
+	if len(cm.Warnings) != 1 || cm.Warnings[0] != want {
+		t.Fatalf("expected a skipped packages warning, got %v", cm.Warnings)
+	}
+}
+
+func TestAnalyzeWithRegistryAssignsStablePackageIDs(t *testing.T) {
+	goAnalyzer := &stubLanguageAnalyzer{
+		id:       languageGo,
+		packages: []Package{{ImportPath: "example.com/app", RelativePath: ".", EntryPoint: "main.go"}},
+	}
+	shellAnalyzer := &stubLanguageAnalyzer{
+		id:       languageShell,
+		packages: []Package{{ImportPath: "scripts", RelativePath: ".", EntryPoint: "run.sh"}},
+	}
+	registry := NewAnalyzerRegistry()
+	registry.Register(goAnalyzer)
+	registry.Register(shellAnalyzer)
+
+	idx := &FileIndex{
+		Files: []FileRecord{
+			{RelPath: "main.go", Language: languageGo},
+			{RelPath: "run.sh", Language: languageShell},
+		},
+	}
+	cm, err := AnalyzeWithRegistry(context.Background(), AnalysisInput{Root: "/tmp/repo", Index: idx, Options: DefaultOptions()}, registry)
+	if err != nil {
+		t.Fatalf("AnalyzeWithRegistry returned error: %v", err)
+	}
+
+	ids := make(map[string]string, len(cm.Packages))
+	for _, pkg := range cm.Packages {
+		if pkg.ID != PackageID(pkg.Language, pkg.RelativePath) || len(pkg.ID) != 12 {
+			t.Fatalf("unexpected package identity: %+v", pkg)
+		}
+		ids[pkg.Language] = pkg.ID
+	}
+	if len(ids) != 2 || ids[languageGo] == ids[languageShell] {
+		t.Fatalf("expected distinct IDs per language for the same path, got %v", ids)
+	}
+	if PackageID(languageGo, ".") != ids[languageGo] {
+		t.Fatal("expected PackageID to be deterministic")
+	}
+}
@@ -0,0 +1,218 @@
+package codemap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// externalAnalyzerProtocolVersion is sent to external analyzers so they can
+// reject requests they do not understand.
+const externalAnalyzerProtocolVersion = 1
+
+// ExternalAnalyzerDef configures a language analyzed by an external command.
+type ExternalAnalyzerDef struct {
+	Language string   // Language ID reported on packages, e.g. "zig"
+	Suffixes []string // File suffixes indexed for the language; default "." + Language
+	Command  string   // Command line run from the project root, split on spaces
+}
+
+// ParseExternalAnalyzerDef parses "LANG=COMMAND" or
+// "LANG:SUFFIX[:SUFFIX...]=COMMAND", e.g. "zig=./tools/zig-analyzer" or
+// "terraform:.tf:.tfvars=./tools/tf-analyzer".
+func ParseExternalAnalyzerDef(value string) (ExternalAnalyzerDef, error) {
+	spec, command, ok := strings.Cut(value, "=")
+	command = strings.TrimSpace(command)
+	if !ok || command == "" {
+		return ExternalAnalyzerDef{}, fmt.Errorf("external analyzer %q: expected LANG[:SUFFIX...]=COMMAND", value)
+	}
+	parts := strings.Split(spec, ":")
+	def := ExternalAnalyzerDef{
+		Language: canonicalLanguageID(parts[0]),
+		Command:  command,
+	}
+	if def.Language == "" {
+		return ExternalAnalyzerDef{}, fmt.Errorf("external analyzer %q: missing language", value)
+	}
+	if _, builtin := builtinLanguageSpecs[def.Language]; builtin {
+		return ExternalAnalyzerDef{}, fmt.Errorf("external analyzer %q: %s has a built-in analyzer", value, def.Language)
+	}
+	for _, suffix := range parts[1:] {
+		if suffix = strings.TrimSpace(suffix); suffix != "" {
+			def.Suffixes = append(def.Suffixes, suffix)
+		}
+	}
+	return def, nil
+}
+
+func (d ExternalAnalyzerDef) languageSpec() LanguageSpec {
+	suffixes := d.Suffixes
+	if len(suffixes) == 0 {
+		suffixes = []string{"." + d.Language}
+	}
+	return LanguageSpec{ID: d.Language, FileSuffixes: suffixes}
+}
+
+// languageSpecSignature identifies the indexing rules of a non-built-in
+// language in state, so changing them invalidates the cached file list.
+func languageSpecSignature(spec LanguageSpec) string {
+	signature := spec.ID + ":" + strings.Join(spec.FileSuffixes, ":")
+	if len(spec.TestFileSuffixes) > 0 {
+		signature += "|" + strings.Join(spec.TestFileSuffixes, ":")
+	}
+	return signature
+}
+
+// ExternalAnalyzer runs a user-configured command for one language. The
+// command receives an externalAnalyzerRequest as JSON on stdin and writes a
+// Codemap as JSON on stdout; only Packages and Warnings are used.
+type ExternalAnalyzer struct {
+	Def ExternalAnalyzerDef
+}
+
+// externalAnalyzerRequest is the JSON document sent to external analyzers.
+type externalAnalyzerRequest struct {
+	Version           int                    `json:"version"`
+	Language          string                 `json:"language"`
+	Root              string                 `json:"root"`
+	IncludeTests      bool                   `json:"includeTests"`
+	LargePackageFiles int                    `json:"largePackageFiles"`
+	Files             []externalAnalyzerFile `json:"files"`
+}
+
+type externalAnalyzerFile struct {
+	Path string `json:"path"` // Slash-separated, relative to Root
+	Size int64  `json:"size"`
+}
+
+func (a ExternalAnalyzer) LanguageID() string { return a.Def.Language }
+
+// LanguageSpec lets an ExternalAnalyzer be passed to RegisterAnalyzer.
+func (a ExternalAnalyzer) LanguageSpec() LanguageSpec { return a.Def.languageSpec() }
+
+func (a ExternalAnalyzer) Analyze(ctx context.Context, in AnalysisInput) (*Codemap, error) {
+	if in.Index == nil {
+		return nil, fmt.Errorf("missing file index")
+	}
+	args := strings.Fields(a.Def.Command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("external analyzer for %s has no command", a.Def.Language)
+	}
+
+	req := externalAnalyzerRequest{
+		Version:           externalAnalyzerProtocolVersion,
+		Language:          a.Def.Language,
+		Root:              in.Root,
+		IncludeTests:      in.Options.IncludeTests,
+		LargePackageFiles: in.Options.LargePackageFiles,
+		Files:             []externalAnalyzerFile{},
+	}
+	for _, rec := range in.Index.Files {
+		if rec.Language != a.Def.Language || (rec.IsTest && !in.Options.IncludeTests) {
+			continue
+		}
+		req.Files = append(req.Files, externalAnalyzerFile{Path: rec.RelPath, Size: rec.Size})
+	}
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encode external analyzer request: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = in.Root
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("external analyzer %s: %s", a.Def.Command, msg)
+		}
+		return nil, fmt.Errorf("external analyzer %s: %w", a.Def.Command, err)
+	}
+
+	var cm Codemap
+	if err := json.Unmarshal(out, &cm); err != nil {
+		return nil, fmt.Errorf("external analyzer %s: decode output: %w", a.Def.Command, err)
+	}
+	packages := make([]Package, 0, len(cm.Packages))
+	for _, pkg := range cm.Packages {
+		rel := "."
+		if pkg.RelativePath != "" {
+			rel = path.Clean(filepath.ToSlash(pkg.RelativePath))
+		}
+		if rel != "." && !filepath.IsLocal(filepath.FromSlash(rel)) {
+			return nil, fmt.Errorf("external analyzer %s: package path %q is outside the project root", a.Def.Command, pkg.RelativePath)
+		}
+		pkg.RelativePath = rel
+		pkg.Language = a.Def.Language
+		packages = append(packages, pkg)
+	}
+	return &Codemap{
+		ProjectRoot: in.Root,
+		Packages:    packages,
+		Warnings:    cm.Warnings,
+	}, nil
+}
+
+// analyzerRegistryFor returns the built-in analyzers plus the custom
+// languages and external analyzers configured in opts.
+func analyzerRegistryFor(opts Options) *AnalyzerRegistry {
+	registry := DefaultAnalyzerRegistry()
+	for _, def := range opts.CustomLanguages {
+		registry.Register(SuffixAnalyzer{Def: def})
+	}
+	for _, def := range opts.ExternalAnalyzers {
+		registry.Register(ExternalAnalyzer{Def: def})
+	}
+	return registry
+}
+
+// languageSpecsFor returns the language specs indexed for opts: the built-in
+// languages plus custom languages and those of external analyzers,
+// restricted to Options.Languages when set.
+func languageSpecsFor(opts Options) []LanguageSpec {
+	specs := defaultLanguageSpecs()
+	for _, def := range opts.CustomLanguages {
+		specs = append(specs, def.languageSpec())
+	}
+	for _, def := range opts.ExternalAnalyzers {
+		specs = append(specs, def.languageSpec())
+	}
+	if len(opts.Languages) == 0 {
+		return specs
+	}
+	enabled := languageFilterIDs(opts)
+	filtered := specs[:0]
+	for _, spec := range specs {
+		if slices.Contains(enabled, spec.ID) {
+			filtered = append(filtered, spec)
+		}
+	}
+	return filtered
+}
+
+// externalLanguageSignatures returns the sorted indexing signatures of the
+// custom languages and external analyzers in opts and of registered analyzers
+// for new languages.
+func externalLanguageSignatures(opts Options) []string {
+	var signatures []string
+	for _, def := range opts.CustomLanguages {
+		signatures = append(signatures, languageSpecSignature(def.languageSpec()))
+	}
+	for _, def := range opts.ExternalAnalyzers {
+		signatures = append(signatures, languageSpecSignature(def.languageSpec()))
+	}
+	for _, spec := range registeredLanguageSpecs() {
+		signatures = append(signatures, languageSpecSignature(spec))
+	}
+	sort.Strings(signatures)
+	return signatures
+}
@@ -0,0 +1,98 @@
+package codemap
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestParseExternalAnalyzerDef(t *testing.T) {
+	def, err := ParseExternalAnalyzerDef("Terraform:.tf:.tfvars=./tools/tf-analyzer --json")
+	if err != nil {
+		t.Fatalf("ParseExternalAnalyzerDef returned error: %v", err)
+	}
+	want := ExternalAnalyzerDef{Language: "terraform", Suffixes: []string{".tf", ".tfvars"}, Command: "./tools/tf-analyzer --json"}
+	if !reflect.DeepEqual(def, want) {
+		t.Fatalf("unexpected def: %+v", def)
+	}
+	if def, _ := ParseExternalAnalyzerDef("zig=zig-analyzer"); def.languageSpec().FileSuffixes[0] != ".zig" {
+		t.Fatalf("expected default suffix .zig, got %+v", def.languageSpec())
+	}
+	for _, bad := range []string{"zig", "zig=", "=cmd", "go=./my-go-analyzer"} {
+		if _, err := ParseExternalAnalyzerDef(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestExternalAnalyzerExchangesJSON(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script analyzer")
+	}
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"main.go":          "package main\n\nfunc main() {}\n",
+		"src/main.zig":     "pub fn main() void {}\n",
+		"src/util.zig":     "pub fn add() void {}\n",
+		"tools/zig-an.sh":  "#!/bin/sh\ncat > \"$CODEMAP_TEST_REQUEST\"\nprintf '%s' '{\"Packages\":[{\"RelativePath\":\"src/\",\"EntryPoint\":\"main.zig\",\"FileCount\":2,\"Purpose\":\"Zig entry\"}],\"Warnings\":[\"zig: 1 file skipped\"]}'\n",
+		"tools/failing.sh": "#!/bin/sh\necho 'boom' >&2\nexit 3\n",
+	}
+	writeTestTree(t, tmpDir, files)
+	for _, script := range []string{"tools/zig-an.sh", "tools/failing.sh"} {
+		if err := os.Chmod(filepath.Join(tmpDir, filepath.FromSlash(script)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	requestPath := filepath.Join(t.TempDir(), "request.json")
+	t.Setenv("CODEMAP_TEST_REQUEST", requestPath)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	def, err := ParseExternalAnalyzerDef("zig=./tools/zig-an.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.ExternalAnalyzers = []ExternalAnalyzerDef{def}
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+
+	var zig *Package
+	for i := range cm.Packages {
+		if cm.Packages[i].Language == "zig" {
+			zig = &cm.Packages[i]
+		}
+	}
+	if zig == nil || zig.RelativePath != "src" || zig.EntryPoint != "main.zig" || zig.ID != PackageID("zig", "src") {
+		t.Fatalf("unexpected zig package: %+v", zig)
+	}
+	if !reflect.DeepEqual(cm.Warnings, []string{"zig: 1 file skipped"}) {
+		t.Fatalf("unexpected warnings: %v", cm.Warnings)
+	}
+
+	raw, err := os.ReadFile(requestPath)
+	if err != nil {
+		t.Fatalf("read request: %v", err)
+	}
+	var req externalAnalyzerRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		t.Fatalf("decode request: %v", err)
+	}
+	if req.Version != externalAnalyzerProtocolVersion || req.Language != "zig" || len(req.Files) != 2 || req.Files[0].Path != "src/main.zig" {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+
+	opts.ExternalAnalyzers[0].Command = "./tools/failing.sh"
+	cm, err = Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	if len(cm.Warnings) != 1 || cm.Warnings[0] != "zig analyzer failed, its packages are omitted: external analyzer ./tools/failing.sh: boom" {
+		t.Fatalf("expected the failure to surface as a warning, got %v", cm.Warnings)
+	}
+}
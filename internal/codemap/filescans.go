@@ -0,0 +1,153 @@
+package codemap
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// FileScan caches what the project-wide passes read from one indexed file,
+// so unchanged files are not read again: the package clause of Go files,
+// the declared funcs and registered routes of non-test Go files, the
+// operations of OpenAPI and Swagger specs and the line count of test files.
+// It is reused while the file's content hash is unchanged.
+type FileScan struct {
+	RelPath     string         `json:"relPath"`
+	ContentHash string         `json:"contentHash"`
+	GoPackage   string         `json:"goPackage,omitempty"`
+	Decls       []string       `json:"decls,omitempty"` // Lowercase func and method names
+	Endpoints   []HTTPEndpoint `json:"endpoints,omitempty"`
+	Lines       int            `json:"lines,omitempty"` // Test files only
+}
+
+// fileScans scans indexed files for the passes that run after the
+// analyzers, reusing the previous run's scans of files whose content hash
+// is unchanged. Files without a recorded hash are scanned on every run.
+type fileScans struct {
+	records map[string]FileRecord
+	hashes  map[string]StateEntry
+	prev    map[string]FileScan
+	next    map[string]FileScan
+}
+
+func newFileScans(in AnalysisInput) *fileScans {
+	scans := &fileScans{
+		records: make(map[string]FileRecord),
+		hashes:  stateEntryByRelPath(in.NextState),
+		prev:    make(map[string]FileScan),
+		next:    make(map[string]FileScan),
+	}
+	if in.Index != nil {
+		for _, rec := range in.Index.Files {
+			scans.records[rec.RelPath] = rec
+		}
+	}
+	if prev := in.PrevState; prev != nil && prev.Analysis != nil && prev.Analysis.Version == analysisCacheVersion {
+		for _, scan := range prev.Analysis.Scans {
+			scans.prev[scan.RelPath] = scan
+		}
+	}
+	return scans
+}
+
+// scan returns the scan of the indexed file at relPath, and false when the
+// file is not indexed or could not be read.
+func (s *fileScans) scan(relPath string) (FileScan, bool) {
+	if scan, ok := s.next[relPath]; ok {
+		return scan, true
+	}
+	rec, ok := s.records[relPath]
+	if !ok {
+		return FileScan{}, false
+	}
+	hash := s.hashes[relPath].ContentHash
+	if scan, ok := s.prev[relPath]; ok && hash != "" && scan.ContentHash == hash {
+		s.next[relPath] = scan
+		return scan, true
+	}
+	content, err := os.ReadFile(rec.AbsPath)
+	if err != nil {
+		return FileScan{}, false
+	}
+	scan := scanFile(rec, content)
+	if hash != "" {
+		scan.ContentHash = hash
+		s.next[relPath] = scan
+	}
+	return scan, true
+}
+
+// scanFile reads what the passes need from the content of rec.
+func scanFile(rec FileRecord, content []byte) FileScan {
+	scan := FileScan{RelPath: rec.RelPath}
+	if rec.IsTest {
+		scan.Lines = lineCountBytes(content)
+	}
+	if rec.Language == languageGo {
+		if file, err := parser.ParseFile(token.NewFileSet(), rec.RelPath, content, parser.PackageClauseOnly); err == nil {
+			scan.GoPackage = file.Name.Name
+		}
+	}
+	if rec.IsTest || rec.Size > maxEndpointSourceBytes {
+		return scan
+	}
+	if isAPISpec(rec) {
+		scan.Endpoints = parseOpenAPIEndpoints(rec.RelPath, content)
+		return scan
+	}
+	if rec.Language != languageGo {
+		return scan
+	}
+	for _, match := range goFuncDecl.FindAllSubmatch(content, -1) {
+		scan.Decls = append(scan.Decls, strings.ToLower(string(match[1])))
+	}
+	for _, marker := range goRouterImports {
+		if bytes.Contains(content, []byte(marker)) {
+			// Commented-out registrations are blanked, keeping offsets.
+			src := goLineComment.ReplaceAllStringFunc(string(content), func(comment string) string {
+				return strings.Repeat(" ", len(comment))
+			})
+			scan.Endpoints = parseGoRoutes(rec.RelPath, src)
+			break
+		}
+	}
+	return scan
+}
+
+// isAPISpec reports whether rec is an OpenAPI or Swagger spec: an openapi.*,
+// swagger.* or *.openapi.* config file.
+func isAPISpec(rec FileRecord) bool {
+	name := strings.ToLower(path.Base(rec.RelPath))
+	return rec.Language == languageConfig && (strings.HasPrefix(name, "openapi") || strings.HasPrefix(name, "swagger") || strings.Contains(name, ".openapi."))
+}
+
+// store records the scans of this run in nextState's analysis cache.
+func (s *fileScans) store(nextState *CodemapState) {
+	if nextState == nil {
+		return
+	}
+	if nextState.Analysis == nil {
+		nextState.Analysis = &AnalysisCache{Version: analysisCacheVersion}
+	}
+	scans := make([]FileScan, 0, len(s.next))
+	for _, scan := range s.next {
+		scans = append(scans, scan)
+	}
+	sort.Slice(scans, func(i, j int) bool { return scans[i].RelPath < scans[j].RelPath })
+	nextState.Analysis.Scans = scans
+}
+
+// cloneEndpoints copies endpoints so callers can extend their Sources
+// without changing cached scans.
+func cloneEndpoints(endpoints []HTTPEndpoint) []HTTPEndpoint {
+	out := make([]HTTPEndpoint, len(endpoints))
+	for i, endpoint := range endpoints {
+		out[i] = endpoint
+		out[i].Sources = append([]string(nil), endpoint.Sources...)
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package codemap
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	protoGoPackagePattern = regexp.MustCompile(`\boption\s+go_package\s*=\s*"([^"]+)"`)
+	// protoSourcePattern matches the headers protoc plugins write into
+	// generated files: "// source: a/b.proto" (protoc-gen-go, ts-proto,
+	// Python) and "// @generated from file a/b.proto" (protobuf-es).
+	protoSourcePattern = regexp.MustCompile(`(?m)^\s*(?://|#|\*)\s*(?:source:|@generated from file)\s+(\S+\.proto)\b`)
+)
+
+// protoGeneratedNames are base-name globs of code generated from proto files.
+var protoGeneratedNames = []string{
+	"*.pb.go", "*.pb.gw.go", "*_pb2.py", "*_pb2_grpc.py", "*.pb.ts", "*_pb.ts",
+	"*_pb.js", "*_pb.d.ts", "*_connect.ts", "*_connect.js", "*.pb.cc", "*.pb.h",
+}
+
+// assignGeneratedFrom links packages holding code generated from proto files
+// to the proto packages defining them. A Go package is linked when its import
+// path is a go_package option; any package is linked when one of its files
+// named like generated code, or named after a proto file, carries a source
+// header naming a proto file of the project.
+func assignGeneratedFrom(root string, idx *FileIndex, packages []Package) {
+	for i := range packages {
+		packages[i].GeneratedFrom = nil
+	}
+	if idx == nil {
+		return
+	}
+	var protoFiles []string
+	stems := make(map[string]struct{})
+	for _, rec := range idx.Files {
+		if rec.Language == languageProto {
+			protoFiles = append(protoFiles, rec.RelPath)
+			stems[strings.TrimSuffix(path.Base(rec.RelPath), ".proto")] = struct{}{}
+		}
+	}
+	if len(protoFiles) == 0 {
+		return
+	}
+
+	links := make([]map[string]struct{}, len(packages))
+	link := func(pkgIndex int, protoFile string) {
+		source := owningPackage(packages, languageProto, protoFile)
+		if source == nil {
+			return
+		}
+		if links[pkgIndex] == nil {
+			links[pkgIndex] = make(map[string]struct{})
+		}
+		links[pkgIndex][source.RelativePath] = struct{}{}
+	}
+
+	goPackages := make(map[string][]string)
+	for _, protoFile := range protoFiles {
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(protoFile)))
+		if err != nil {
+			continue
+		}
+		if match := protoGoPackagePattern.FindSubmatch(content); match != nil {
+			goPackage, _, _ := strings.Cut(string(match[1]), ";")
+			goPackages[goPackage] = append(goPackages[goPackage], protoFile)
+		}
+	}
+	for i, pkg := range packages {
+		if pkg.Language != languageGo {
+			continue
+		}
+		for _, protoFile := range goPackages[pkg.ImportPath] {
+			link(i, protoFile)
+		}
+	}
+
+	owners := newStatsOwners(packages)
+	for _, rec := range idx.Files {
+		if rec.Language == languageProto || rec.IsTest || !isProtoGeneratedName(path.Base(rec.RelPath), stems) {
+			continue
+		}
+		pkgIndex, ok := owners.find(rec.Language, rec.RelPath)
+		if !ok {
+			continue
+		}
+		header := readFileHeader(filepath.Join(root, filepath.FromSlash(rec.RelPath)), roleHeaderBytes)
+		for _, match := range protoSourcePattern.FindAllSubmatch(header, -1) {
+			if protoFile, ok := resolveProtoImport(string(match[1]), protoFiles); ok {
+				link(pkgIndex, protoFile)
+			}
+		}
+	}
+	for i := range packages {
+		packages[i].GeneratedFrom = sortedImportSet(links[i])
+	}
+}
+
+func isProtoGeneratedName(name string, protoStems map[string]struct{}) bool {
+	lower := strings.ToLower(name)
+	for _, pattern := range protoGeneratedNames {
+		if ok, _ := path.Match(pattern, lower); ok {
+			return true
+		}
+	}
+	_, ok := protoStems[strings.TrimSuffix(name, path.Ext(name))]
+	return ok
+}
@@ -0,0 +1,425 @@
+package codemap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFileState records an ignore, git config or git index file consulted
+// while indexing, so fast staleness checks notice edits that change which
+// files are indexed. Size is -1 when the file did not exist.
+type IgnoreFileState struct {
+	Path            string `json:"path"`
+	Size            int64  `json:"size"`
+	ModTimeUnixNano int64  `json:"modTimeUnixNano"`
+}
+
+type ignoreRule struct {
+	re      *regexp.Regexp
+	base    string // Directory of the defining file relative to the repository top ("" = top)
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreMatcher applies git ignore rules the way git status does: the global
+// excludes file, then .git/info/exclude, then .gitignore files from the
+// repository top downwards, with later (deeper) rules taking precedence.
+type ignoreMatcher struct {
+	rules  []ignoreRule
+	prefix string // Project root relative to the repository top ("" when they coincide)
+	files  []IgnoreFileState
+}
+
+// newIgnoreMatcher loads the ignore rules that apply to absRoot. Outside a git
+// repository only .gitignore files under absRoot are honored.
+func newIgnoreMatcher(ctx context.Context, absRoot string) (*ignoreMatcher, error) {
+	m := &ignoreMatcher{}
+	top, gitDir := findGitRepository(absRoot)
+	if top == "" {
+		if err := m.load(filepath.Join(absRoot, ".gitignore"), ""); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+
+	if rel, err := filepath.Rel(top, absRoot); err == nil && rel != "." {
+		m.prefix = filepath.ToSlash(rel)
+	}
+	commonDir := gitCommonDir(gitDir)
+	for _, path := range gitConfigFiles(commonDir) {
+		m.track(path)
+	}
+	if excludes := globalExcludesFile(ctx, absRoot); excludes != "" {
+		if err := m.load(excludes, ""); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.load(filepath.Join(commonDir, "info", "exclude"), ""); err != nil {
+		return nil, err
+	}
+
+	// .gitignore files between the repository top and the project root.
+	base := ""
+	dir := top
+	for _, part := range strings.Split(m.prefix, "/") {
+		if err := m.load(filepath.Join(dir, ".gitignore"), base); err != nil {
+			return nil, err
+		}
+		if part == "" {
+			break
+		}
+		dir = filepath.Join(dir, part)
+		base = strings.TrimPrefix(base+"/"+part, "/")
+	}
+	if m.prefix != "" {
+		if err := m.load(filepath.Join(absRoot, ".gitignore"), m.prefix); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// enterDir loads the .gitignore of a directory below the project root. Missing
+// files are not tracked; creating one changes the directory mtime instead.
+func (m *ignoreMatcher) enterDir(absDir, relDir string) error {
+	path := filepath.Join(absDir, ".gitignore")
+	if _, err := os.Lstat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return m.load(path, m.repoPath(relDir))
+}
+
+// ignored reports whether relPath (relative to the project root) is ignored.
+func (m *ignoreMatcher) ignored(relPath string, isDir bool) bool {
+	p := m.repoPath(relPath)
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		rule := m.rules[i]
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel := p
+		if rule.base != "" {
+			if !strings.HasPrefix(p, rule.base+"/") {
+				continue
+			}
+			rel = p[len(rule.base)+1:]
+		}
+		if rule.re.MatchString(rel) {
+			return !rule.negate
+		}
+	}
+	return false
+}
+
+func (m *ignoreMatcher) repoPath(relPath string) string {
+	if m.prefix == "" {
+		return relPath
+	}
+	if relPath == "." {
+		return m.prefix
+	}
+	return m.prefix + "/" + relPath
+}
+
+// track records the current metadata of path and reports whether it exists.
+func (m *ignoreMatcher) track(path string) bool {
+	state := statIgnoreFile(path)
+	m.files = append(m.files, state)
+	return state.Size >= 0
+}
+
+func statIgnoreFile(path string) IgnoreFileState {
+	state := IgnoreFileState{Path: path, Size: -1}
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		state.Size = info.Size()
+		state.ModTimeUnixNano = info.ModTime().UnixNano()
+	}
+	return state
+}
+
+func (m *ignoreMatcher) load(path, base string) error {
+	if !m.track(path) {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text(), base); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseIgnoreRule compiles one line of a gitignore file. Comments, blank
+// lines and invalid patterns yield ok=false.
+func parseIgnoreRule(line, base string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	expr := ignoreGlobToRegexp(line)
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// ignoreGlobToRegexp translates gitignore glob syntax (*, ?, [...], ** and
+// backslash escapes) into a regular expression body.
+func ignoreGlobToRegexp(pattern string) string {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**") && (i == 0 || pattern[i-1] == '/') {
+				rest := pattern[i+2:]
+				if rest == "" {
+					sb.WriteString(".*")
+					i++
+					continue
+				}
+				if rest[0] == '/' {
+					// "**/" matches zero or more directories.
+					sb.WriteString("(?:.*/)?")
+					i += 2
+					continue
+				}
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			class, n := ignoreCharClass(pattern[i:])
+			if n == 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			sb.WriteString(class)
+			i += n - 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	return sb.String()
+}
+
+// ignoreCharClass translates a bracket expression at the start of pattern and
+// returns the regexp class with the number of bytes consumed (0 if unclosed).
+func ignoreCharClass(pattern string) (string, int) {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	i := 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		sb.WriteByte('^')
+		i++
+	}
+	for first := true; i < len(pattern); i++ {
+		c := pattern[i]
+		if c == ']' && !first {
+			sb.WriteByte(']')
+			return sb.String(), i + 1
+		}
+		first = false
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case c == '-':
+			sb.WriteByte('-')
+		default:
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	return "", 0
+}
+
+// findGitRepository walks up from dir to the enclosing work tree and returns
+// its top directory and git directory, or empty strings outside a repository.
+func findGitRepository(dir string) (top, gitDir string) {
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		if info, err := os.Lstat(dotGit); err == nil {
+			if info.IsDir() {
+				return dir, dotGit
+			}
+			// Worktrees and submodules use a ".git" file pointing at the git dir.
+			if data, err := os.ReadFile(dotGit); err == nil {
+				if target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:"); ok {
+					target = strings.TrimSpace(target)
+					if !filepath.IsAbs(target) {
+						target = filepath.Join(dir, target)
+					}
+					return dir, target
+				}
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// gitCommonDir resolves the directory shared by all worktrees, which holds
+// info/exclude and the repository config.
+func gitCommonDir(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	common := strings.TrimSpace(string(data))
+	if common == "" {
+		return gitDir
+	}
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitDir, common)
+	}
+	return common
+}
+
+// gitConfigFiles lists the config files that can set core.excludesFile, so a
+// changed setting invalidates the fast staleness path.
+func gitConfigFiles(commonDir string) []string {
+	files := []string{filepath.Join(commonDir, "config")}
+	if global := os.Getenv("GIT_CONFIG_GLOBAL"); global != "" {
+		files = append(files, global)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".gitconfig"))
+	}
+	if dir := xdgGitConfigDir(); dir != "" {
+		files = append(files, filepath.Join(dir, "config"))
+	}
+	return files
+}
+
+// globalExcludesFile returns core.excludesFile as git resolves it, falling
+// back to git's default of $XDG_CONFIG_HOME/git/ignore.
+func globalExcludesFile(ctx context.Context, root string) string {
+	cmd := exec.CommandContext(ctx, "git", "config", "--path", "--get", "core.excludesFile")
+	cmd.Dir = root
+	if out, err := cmd.Output(); err == nil {
+		if path := strings.TrimSpace(string(out)); path != "" {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(root, path)
+			}
+			return path
+		}
+	}
+	if dir := xdgGitConfigDir(); dir != "" {
+		return filepath.Join(dir, "ignore")
+	}
+	return ""
+}
+
+func xdgGitConfigDir() string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "git")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config", "git")
+	}
+	return ""
+}
+
+// indexStateMatches reports whether prev was indexed in the same git modes and
+// with the same index patterns as opts, none of its entries is a file codemap
+// now generates, and every ignore or git index file it consulted is
+// unchanged. Callers must not reuse prev's file list for fast paths otherwise.
+func indexStateMatches(prev *CodemapState, opts Options) bool {
+	if prev == nil {
+		return true
+	}
+	if prev.GitIgnore != opts.GitIgnore || prev.GitTracked != opts.GitTracked || prev.NestedCodemaps != opts.NestedCodemaps {
+		return false
+	}
+	if !indexPatternsEqual(prev.ExcludePatterns, opts.ExcludePatterns) || !indexPatternsEqual(prev.IncludePatterns, opts.IncludePatterns) {
+		return false
+	}
+	if !indexPatternsEqual(prev.ExternalLanguages, externalLanguageSignatures(opts)) || !indexPatternsEqual(prev.Languages, languageFilterIDs(opts)) {
+		return false
+	}
+	for _, file := range prev.IgnoreFiles {
+		if statIgnoreFile(file.Path) != file {
+			return false
+		}
+	}
+	return !stateIndexesGeneratedPaths(prev, opts) && !stateIndexesSkippedPaths(prev)
+}
+
+// stateIndexesSkippedPaths reports whether prev recorded any file the index
+// now skips, as states written before a directory or file kind was excluded
+// can.
+func stateIndexesSkippedPaths(prev *CodemapState) bool {
+	for _, entry := range prev.Entries {
+		if trackedPathExcluded(entry.RelPath) || shouldSkipIndexedFile(entry.Language, entry.RelPath, entry.Size) {
+			return true
+		}
+	}
+	return false
+}
+
+// stateIndexesGeneratedPaths reports whether prev recorded any file codemap
+// generates for opts, as states written before the output paths changed can.
+func stateIndexesGeneratedPaths(prev *CodemapState, opts Options) bool {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil || len(prev.Entries) == 0 {
+		return false
+	}
+	generated := make(map[string]struct{})
+	for _, path := range generatedPaths(root, opts) {
+		if rel, err := filepath.Rel(root, path); err == nil && filepath.IsLocal(rel) {
+			generated[filepath.ToSlash(rel)] = struct{}{}
+		}
+	}
+	for _, entry := range prev.Entries {
+		if _, ok := generated[entry.RelPath]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseIgnoreRuleMatchesGitSemantics(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "a/b/debug.log", false, true},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"out/", "src/out", true, true},
+		{"out/", "src/out", false, false},
+		{"docs/*.md", "docs/a.md", false, true},
+		{"docs/*.md", "docs/sub/a.md", false, false},
+		{"**/gen", "x/y/gen", true, true},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"cache/**", "cache/x/y.go", false, true},
+		{"file?.go", "file1.go", false, true},
+		{"file[!0-9].go", "file1.go", false, false},
+		{"file[!0-9].go", "filex.go", false, true},
+		{`\#notes`, "#notes", false, true},
+		{"trailing.go   ", "trailing.go", false, true},
+	}
+	for _, tt := range tests {
+		rule, ok := parseIgnoreRule(tt.pattern, "")
+		if !ok {
+			t.Fatalf("pattern %q did not compile", tt.pattern)
+		}
+		m := &ignoreMatcher{rules: []ignoreRule{rule}}
+		if got := m.ignored(tt.path, tt.isDir); got != tt.want {
+			t.Fatalf("pattern %q on %q (dir=%v) = %v, want %v", tt.pattern, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{"", "# comment", "   ", "/"} {
+		if _, ok := parseIgnoreRule(line, ""); ok {
+			t.Fatalf("expected %q to be skipped", line)
+		}
+	}
+}
+
+func TestBuildIndexHonorsGitExcludeSources(t *testing.T) {
+	tmpDir := t.TempDir()
+	configHome := filepath.Join(tmpDir, "config")
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(tmpDir, "gitconfig"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	repo := filepath.Join(tmpDir, "repo")
+	files := map[string]string{
+		".git/info/exclude":       "local_only.go\n",
+		".gitignore":              "/gen/\n*.tmp.go\n",
+		"main.go":                 "package main\n",
+		"local_only.go":           "package main\n",
+		"scratch.tmp.go":          "package main\n",
+		"global_skip.go":          "package main\n",
+		"gen/gen.go":              "package gen\n",
+		"lib/lib.go":              "package lib\n",
+		"lib/.gitignore":          "*.go\n!keep.go\n",
+		"lib/keep.go":             "package lib\n",
+		"lib/nested/nested.go":    "package nested\n",
+		"other/global_skip.go":    "package other\n",
+		"other/other.go":          "package other\n",
+		"other/local_only_too.go": "package other\n",
+	}
+	writeTestTree(t, repo, files)
+
+	if err := os.MkdirAll(filepath.Join(configHome, "git"), 0755); err != nil {
+		t.Fatalf("mkdir config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configHome, "git", "ignore"), []byte("global_skip.go\n"), 0644); err != nil {
+		t.Fatalf("write global excludes: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.GitIgnore = true
+	idx, err := buildIndex(context.Background(), repo, opts)
+	if err != nil {
+		t.Fatalf("buildIndex returned error: %v", err)
+	}
+	var got []string
+	for _, rec := range idx.Files {
+		got = append(got, rec.RelPath)
+	}
+	want := []string{"lib/keep.go", "main.go", "other/local_only_too.go", "other/other.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected indexed files: got %v want %v", got, want)
+	}
+
+	state := &CodemapState{GitIgnore: true, IgnoreFiles: idx.IgnoreFiles}
+	if !indexStateMatches(state, opts) {
+		t.Fatal("expected unchanged ignore files to match")
+	}
+	if indexStateMatches(state, DefaultOptions()) {
+		t.Fatal("expected a different ignore mode to invalidate state")
+	}
+	exclude := filepath.Join(repo, ".git", "info", "exclude")
+	if err := os.WriteFile(exclude, []byte("local_only.go\nother/\n"), 0644); err != nil {
+		t.Fatalf("rewrite exclude: %v", err)
+	}
+	if indexStateMatches(state, opts) {
+		t.Fatal("expected edited .git/info/exclude to invalidate state")
+	}
+
+	plain, err := buildIndex(context.Background(), repo, DefaultOptions())
+	if err != nil {
+		t.Fatalf("buildIndex returned error: %v", err)
+	}
+	if len(plain.Files) != 11 {
+		t.Fatalf("expected all 11 Go files without -gitignore, got %d", len(plain.Files))
+	}
+}
+
+func TestBuildIndexReadsCoreExcludesFile(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	tmpDir := t.TempDir()
+	excludes := filepath.Join(tmpDir, "excludes")
+	globalConfig := filepath.Join(tmpDir, "gitconfig")
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "xdg"))
+	t.Setenv("GIT_CONFIG_GLOBAL", globalConfig)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	if err := os.WriteFile(globalConfig, []byte("[core]\n\texcludesFile = "+excludes+"\n"), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := os.WriteFile(excludes, []byte("skipped.go\n"), 0644); err != nil {
+		t.Fatalf("write excludes: %v", err)
+	}
+
+	repo := filepath.Join(tmpDir, "repo")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, name := range []string{"kept.go", "skipped.go"} {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte("package main\n"), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.GitIgnore = true
+	idx, err := buildIndex(context.Background(), repo, opts)
+	if err != nil {
+		t.Fatalf("buildIndex returned error: %v", err)
+	}
+	if len(idx.Files) != 1 || idx.Files[0].RelPath != "kept.go" {
+		t.Fatalf("expected only kept.go, got %+v", idx.Files)
+	}
+}
@@ -0,0 +1,190 @@
+package codemap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// buildTrackedFileIndex indexes the files git tracks under absRoot instead of
+// walking the tree, which skips ignored and untracked files for free. ok is
+// false when git is unavailable or absRoot is outside a work tree, so the
+// caller can fall back to the walker. With nested set, files below a tracked
+// nested codemap marker are left out and the marker directories recorded.
+func buildTrackedFileIndex(ctx context.Context, absRoot string, languageSpecs []LanguageSpec, filter *pathFilter, nested bool) (*FileIndex, bool, error) {
+	_, gitDir := findGitRepository(absRoot)
+	if gitDir == "" {
+		return nil, false, nil
+	}
+	relPaths, err := gitTrackedFiles(ctx, absRoot)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		return nil, false, nil
+	}
+
+	rootEntries, err := os.ReadDir(absRoot)
+	if err != nil {
+		return nil, false, fmt.Errorf("read root: %w", err)
+	}
+	idx := &FileIndex{Root: absRoot, GitTracked: true}
+	for _, entry := range rootEntries {
+		idx.RootEntries = append(idx.RootEntries, entry.Name())
+	}
+
+	var nestedRoots map[string]struct{}
+	if nested {
+		nestedRoots = trackedNestedRoots(absRoot, relPaths, filter)
+		for dir := range nestedRoots {
+			idx.NestedRoots = append(idx.NestedRoots, dir)
+		}
+	}
+
+	dirs := map[string]struct{}{".": {}}
+	for dir := range nestedRoots {
+		for ; dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = struct{}{}
+		}
+	}
+	for _, relPath := range relPaths {
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		default:
+		}
+		if trackedPathExcluded(relPath) || filter.skipFile(relPath) || nestedRootOf(relPath, nestedRoots) != "" {
+			continue
+		}
+
+		absPath := filepath.Join(absRoot, filepath.FromSlash(relPath))
+		if filter.skipGenerated(absPath) {
+			continue
+		}
+		info, err := os.Lstat(absPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // Deleted in the work tree but still in the git index
+			}
+			return nil, false, err
+		}
+		if info.IsDir() {
+			continue // Submodule
+		}
+		langMatch, ok, err := detectLanguageForFile(absPath, path.Base(relPath), languageSpecs)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok || shouldSkipIndexedFile(langMatch.ID, relPath, info.Size()) {
+			continue
+		}
+
+		idx.Files = append(idx.Files, FileRecord{
+			AbsPath:         absPath,
+			RelPath:         relPath,
+			Size:            info.Size(),
+			ModTimeUnixNano: info.ModTime().UnixNano(),
+			Language:        langMatch.ID,
+			IsGo:            langMatch.ID == languageGo,
+			IsTest:          langMatch.IsTest,
+		})
+		for dir := path.Dir(relPath); dir != "."; dir = path.Dir(dir) {
+			if _, seen := dirs[dir]; seen {
+				break
+			}
+			dirs[dir] = struct{}{}
+		}
+	}
+
+	for dir := range dirs {
+		absDir := absRoot
+		if dir != "." {
+			absDir = filepath.Join(absRoot, filepath.FromSlash(dir))
+		}
+		info, err := os.Stat(absDir)
+		if err != nil {
+			return nil, false, err
+		}
+		idx.Dirs = append(idx.Dirs, DirRecord{RelPath: dir, ModTimeUnixNano: info.ModTime().UnixNano()})
+	}
+
+	// Keep the walker's ordering so hashes match across modes.
+	sort.Slice(idx.Files, func(i, j int) bool { return walkOrderLess(idx.Files[i].RelPath, idx.Files[j].RelPath) })
+	sort.Slice(idx.Dirs, func(i, j int) bool { return walkOrderLess(idx.Dirs[i].RelPath, idx.Dirs[j].RelPath) })
+	sort.Slice(idx.NestedRoots, func(i, j int) bool { return walkOrderLess(idx.NestedRoots[i], idx.NestedRoots[j]) })
+
+	// Staging or removing files only touches the git index file.
+	idx.IgnoreFiles = []IgnoreFileState{statIgnoreFile(filepath.Join(gitDir, "index"))}
+	return idx, true, nil
+}
+
+// gitTrackedFiles lists files in the git index under root, relative to root.
+func gitTrackedFiles(ctx context.Context, root string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "ls-files", "-z", "--cached")
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git ls-files: %s", msg)
+		}
+		return nil, fmt.Errorf("git ls-files: %w", err)
+	}
+
+	var files []string
+	seen := make(map[string]struct{})
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file == "" {
+			continue
+		}
+		// Unmerged paths are listed once per conflict stage.
+		if _, dup := seen[file]; dup {
+			continue
+		}
+		seen[file] = struct{}{}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// trackedPathExcluded applies the walker's directory exclusions to a tracked path.
+func trackedPathExcluded(relPath string) bool {
+	dir := path.Dir(relPath)
+	if dir == "." {
+		return false
+	}
+	for _, part := range strings.Split(dir, "/") {
+		if isExcludedDir(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkOrderLess orders slash paths the way filepath.WalkDir visits them: the
+// root first, then entries sorted per directory.
+func walkOrderLess(a, b string) bool {
+	if a == "." || b == "." {
+		return a == "." && b != "."
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		if a[i] == '/' {
+			return true
+		}
+		if b[i] == '/' {
+			return false
+		}
+		return a[i] < b[i]
+	}
+	return len(a) < len(b)
+}
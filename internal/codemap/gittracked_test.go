@@ -0,0 +1,105 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuildIndexGitTrackedListsOnlyTrackedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":          "module example.com/test\n\ngo 1.22\n",
+		"a.go":            "package main\n",
+		"a/b.go":          "package a\n",
+		"a-b/c.go":        "package ab\n",
+		"vendor/x/x.go":   "package x\n",
+		"untracked/u.go":  "package u\n",
+		"scripts/run.sh":  "#!/bin/sh\necho hi\n",
+		"deleted/gone.go": "package deleted\n",
+	}
+	writeTestTree(t, tmpDir, files)
+	runGit := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tmpDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	runGit("init", "-q")
+	runGit("add", "go.mod", "a.go", "a/b.go", "a-b/c.go", "vendor/x/x.go", "scripts/run.sh", "deleted/gone.go")
+	if err := os.RemoveAll(filepath.Join(tmpDir, "deleted")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.GitTracked = true
+	idx, err := buildIndex(context.Background(), tmpDir, opts)
+	if err != nil {
+		t.Fatalf("buildIndex returned error: %v", err)
+	}
+	if !idx.GitTracked || len(idx.IgnoreFiles) != 1 {
+		t.Fatalf("expected git-tracked index with git index metadata, got %+v", idx)
+	}
+
+	// The walker sees the untracked file; otherwise both modes agree, in the same order.
+	if err := os.RemoveAll(filepath.Join(tmpDir, "untracked")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	walked, err := BuildFileIndex(context.Background(), tmpDir)
+	if err != nil {
+		t.Fatalf("BuildFileIndex returned error: %v", err)
+	}
+	if !reflect.DeepEqual(relPathsOf(idx), relPathsOf(walked)) {
+		t.Fatalf("unexpected tracked files: got %v want %v", relPathsOf(idx), relPathsOf(walked))
+	}
+}
+
+func TestBuildIndexGitTrackedFallsBackToWalker(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.GitTracked = true
+	idx, err := buildIndex(context.Background(), tmpDir, opts)
+	if err != nil {
+		t.Fatalf("buildIndex returned error: %v", err)
+	}
+	if got := relPathsOf(idx); !reflect.DeepEqual(got, []string{"main.go"}) {
+		t.Fatalf("expected walker fallback to find main.go, got %v", got)
+	}
+}
+
+func TestWalkOrderLess(t *testing.T) {
+	paths := []string{"a.go", "a/b.go", ".", "a-b/c.go", "a/a/z.go"}
+	want := []string{".", "a/a/z.go", "a/b.go", "a-b/c.go", "a.go"}
+	for i := range paths {
+		for j := i + 1; j < len(paths); j++ {
+			if walkOrderLess(paths[j], paths[i]) {
+				paths[i], paths[j] = paths[j], paths[i]
+			}
+		}
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("unexpected order: got %v want %v", paths, want)
+	}
+}
+
+func relPathsOf(idx *FileIndex) []string {
+	var paths []string
+	for _, rec := range idx.Files {
+		paths = append(paths, rec.RelPath)
+	}
+	return paths
+}
@@ -0,0 +1,287 @@
+package codemap
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// assignPackageDependencies resolves each package's imports to the other
+// packages of the project and records their relative paths in DependsOn.
+// Imports are matched by the target's import path or published name (Go
+// import paths, Python top-level modules, crate, gem and package.json names)
+// and, for relative imports of path-based languages, by the package owning
+// the imported file. Analyzers record those relative to the package
+// directory with packageRelativeImport. Imports a package makes of itself are dropped.
+func assignPackageDependencies(packages []Package) {
+	for i := range packages {
+		pkg := &packages[i]
+		if isNestedCodemap(*pkg) {
+			continue
+		}
+		seen := make(map[string]struct{})
+		for _, target := range packageDependencyTargets(*pkg, packages) {
+			if target != pkg.RelativePath {
+				seen[target] = struct{}{}
+			}
+		}
+		pkg.DependsOn = sortedImportSet(seen)
+	}
+}
+
+func packageDependencyTargets(pkg Package, packages []Package) []string {
+	family := dependencyFamily(pkg.Language)
+	var targets []string
+	add := func(target *Package) {
+		if target != nil {
+			targets = append(targets, target.RelativePath)
+		}
+	}
+
+	switch family {
+	case languageGo:
+		for _, imp := range pkg.Imports {
+			for j := range packages {
+				if packages[j].Language == languageGo && packages[j].ImportPath == imp {
+					add(&packages[j])
+				}
+			}
+		}
+		return targets
+	case languageCpp, languageProto:
+		// Internal includes and proto imports are already resolved to
+		// root-relative paths.
+		for _, imp := range pkg.Imports {
+			add(owningPackage(packages, family, imp))
+		}
+		return targets
+	}
+
+	for _, imp := range pkg.Imports {
+		if strings.HasPrefix(imp, ".") && family != languagePython {
+			add(owningPackage(packages, family, path.Join(packageFileDir(pkg.RelativePath), imp)))
+			continue
+		}
+		add(namedPackage(packages, family, imp))
+	}
+	for _, imp := range pkg.ExternalImports {
+		add(namedPackage(packages, family, imp))
+	}
+	return targets
+}
+
+// packageRelativeImport rewrites imp, a relative import made by file, to be
+// relative to the package directory, where packageDependencyTargets
+// resolves it. file is the importing file relative to that directory.
+func packageRelativeImport(file, imp string) string {
+	joined := path.Join(path.Dir(file), imp)
+	if joined == "." || joined == ".." || strings.HasPrefix(joined, "../") {
+		return joined
+	}
+	return "./" + joined
+}
+
+// dependencyFamily groups languages whose packages can import each other.
+func dependencyFamily(language string) string {
+	if language == languageJavaScript {
+		return languageTypeScript
+	}
+	return language
+}
+
+// namedPackage returns the package of the language family whose published
+// name is the longest prefix of imp, or nil.
+func namedPackage(packages []Package, family, imp string) *Package {
+	imp = normalizeDependencyName(family, imp)
+	var best *Package
+	bestLen := 0
+	for j := range packages {
+		if dependencyFamily(packages[j].Language) != family {
+			continue
+		}
+		name := normalizeDependencyName(family, packages[j].ImportPath)
+		if name == "" || len(name) <= bestLen {
+			continue
+		}
+		if imp == name || strings.HasPrefix(imp, name+"/") || strings.HasPrefix(imp, name+".") || strings.HasPrefix(imp, name+"::") {
+			best = &packages[j]
+			bestLen = len(name)
+		}
+	}
+	return best
+}
+
+func normalizeDependencyName(family, name string) string {
+	switch family {
+	case languagePython:
+		return normalizePythonImportPrefix(name)
+	case languageRust:
+		return strings.ReplaceAll(name, "-", "_")
+	}
+	return name
+}
+
+// owningPackage returns the package of the language family whose directory
+// most closely contains relPath, or nil.
+func owningPackage(packages []Package, family, relPath string) *Package {
+	if relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return nil
+	}
+	var best *Package
+	bestLen := -1
+	for j := range packages {
+		if dependencyFamily(packages[j].Language) != family {
+			continue
+		}
+		rel := packages[j].RelativePath
+		length := 0
+		if rel != "." {
+			if relPath != rel && !strings.HasPrefix(relPath, rel+"/") {
+				continue
+			}
+			length = len(rel)
+		}
+		if length > bestLen {
+			best = &packages[j]
+			bestLen = length
+		}
+	}
+	return best
+}
+
+type dependencyRow struct {
+	Name     string
+	Packages []string
+}
+
+// dependencyRows lists internal dependencies once per package path, merging
+// packages of different languages that share a directory.
+func dependencyRows(packages []Package) []dependencyRow {
+	var rows []dependencyRow
+	index := make(map[string]int)
+	for _, pkg := range packages {
+		if len(pkg.DependsOn) == 0 {
+			continue
+		}
+		i, ok := index[pkg.RelativePath]
+		if !ok {
+			index[pkg.RelativePath] = len(rows)
+			rows = append(rows, dependencyRow{Name: pkg.RelativePath, Packages: pkg.DependsOn})
+			continue
+		}
+		seen := make(map[string]struct{})
+		for _, dep := range rows[i].Packages {
+			seen[dep] = struct{}{}
+		}
+		for _, dep := range pkg.DependsOn {
+			seen[dep] = struct{}{}
+		}
+		rows[i].Packages = sortedImportSet(seen)
+	}
+	return rows
+}
+
+// graphNodes returns the distinct package paths in output order.
+func graphNodes(packages []Package) []string {
+	var nodes []string
+	seen := make(map[string]struct{})
+	for _, pkg := range packages {
+		if _, ok := seen[pkg.RelativePath]; ok {
+			continue
+		}
+		seen[pkg.RelativePath] = struct{}{}
+		nodes = append(nodes, pkg.RelativePath)
+	}
+	return nodes
+}
+
+// GraphDOTRenderer renders the package dependency graph as Graphviz DOT.
+type GraphDOTRenderer struct{}
+
+func (GraphDOTRenderer) Name() string        { return "graph-dot" }
+func (GraphDOTRenderer) DefaultPath() string { return "CODEMAP.dot" }
+func (GraphDOTRenderer) Render(cm *Codemap) (string, error) {
+	return renderGraphDOT(cm), nil
+}
+
+func renderGraphDOT(cm *Codemap) string {
+	var sb strings.Builder
+	sb.WriteString("// codemap-hash: ")
+	sb.WriteString(cm.ContentHash)
+	sb.WriteString("\ndigraph codemap {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, node := range graphNodes(cm.Packages) {
+		fmt.Fprintf(&sb, "\t%s;\n", dotQuote(node))
+	}
+	for _, row := range dependencyRows(cm.Packages) {
+		for _, dep := range row.Packages {
+			fmt.Fprintf(&sb, "\t%s -> %s;\n", dotQuote(row.Name), dotQuote(dep))
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// GraphMermaidRenderer renders the package dependency graph as a Mermaid
+// flowchart.
+type GraphMermaidRenderer struct{}
+
+func (GraphMermaidRenderer) Name() string        { return "graph-mermaid" }
+func (GraphMermaidRenderer) DefaultPath() string { return "CODEMAP.mmd" }
+func (GraphMermaidRenderer) Render(cm *Codemap) (string, error) {
+	return renderGraphMermaid(cm), nil
+}
+
+func renderGraphMermaid(cm *Codemap) string {
+	var sb strings.Builder
+	sb.WriteString("%% codemap-hash: ")
+	sb.WriteString(cm.ContentHash)
+	sb.WriteString("\ngraph LR\n")
+	ids := make(map[string]string)
+	for i, node := range graphNodes(cm.Packages) {
+		ids[node] = fmt.Sprintf("p%d", i)
+		fmt.Fprintf(&sb, "\t%s[\"%s\"]\n", ids[node], strings.ReplaceAll(node, `"`, "#quot;"))
+	}
+	for _, row := range dependencyRows(cm.Packages) {
+		for _, dep := range row.Packages {
+			fmt.Fprintf(&sb, "\t%s --> %s\n", ids[row.Name], ids[dep])
+		}
+	}
+	return sb.String()
+}
+
+// graphOutputsStale reports whether an enabled graph output is missing or
+// was written for a different content hash than the markdown output.
+func graphOutputsStale(root string, opts Options, existingHash string) (bool, error) {
+	for _, name := range []string{opts.DOTOutputPath, opts.MermaidOutputPath} {
+		if name == "" {
+			continue
+		}
+		graphHash, err := readExistingHash(outputAbsPath(root, name), opts.HashScanLines)
+		if err != nil {
+			return false, fmt.Errorf("read existing graph hash: %w", err)
+		}
+		if graphHash == "" || graphHash != existingHash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// writeGraphOutputs writes the DOT and Mermaid graphs enabled in opts.
+func writeGraphOutputs(root string, opts Options, cm *Codemap) error {
+	if opts.DOTOutputPath != "" {
+		if err := writeRenderedOutput(outputAbsPath(root, opts.DOTOutputPath), GraphDOTRenderer{}, cm); err != nil {
+			return err
+		}
+	}
+	if opts.MermaidOutputPath != "" {
+		if err := writeRenderedOutput(outputAbsPath(root, opts.MermaidOutputPath), GraphMermaidRenderer{}, cm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
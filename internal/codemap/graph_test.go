@@ -0,0 +1,91 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPackageDependencyGraph(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                     "module example.com/app\n\ngo 1.22\n",
+		"main.go":                    "package main\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/app/internal/store\"\n)\n\nfunc main() { fmt.Println(store.Open()) }\n",
+		"internal/store/store.go":    "package store\n\nimport \"example.com/app/internal/util\"\n\nfunc Open() string { return util.Name() }\n",
+		"internal/util/util.go":      "package util\n\nfunc Name() string { return \"util\" }\n",
+		"web/app/package.json":       "{\"name\": \"@acme/app\"}\n",
+		"web/app/index.ts":           "import { button } from \"@acme/ui\";\nimport { api } from \"../api/client\";\nexport const app = button + api;\n",
+		"web/ui/package.json":        "{\"name\": \"@acme/ui\"}\n",
+		"web/ui/index.ts":            "export const button = \"b\";\n",
+		"web/api/package.json":       "{\"name\": \"@acme/api\"}\n",
+		"web/api/client.ts":          "export const api = \"a\";\n",
+		"web/admin/package.json":     "{\"name\": \"@acme/admin\"}\n",
+		"web/admin/src/view/page.ts": "import { api } from \"../../../api/client\";\nexport const page = api;\n",
+		"gems/core/core.gemspec":     "Gem::Specification.new do |s|\n  s.name = \"core\"\nend\n",
+		"gems/core/lib/core.rb":      "module Core\nend\n",
+		"gems/app/app.gemspec":       "Gem::Specification.new do |s|\n  s.name = \"app\"\nend\n",
+		"gems/app/lib/app.rb":        "require_relative \"../../core/lib/core\"\n\nmodule App\nend\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	got := make(map[string][]string)
+	for _, pkg := range cm.Packages {
+		if len(pkg.DependsOn) > 0 {
+			got[pkg.RelativePath] = pkg.DependsOn
+		}
+	}
+	want := map[string][]string{
+		".":              {"internal/store"},
+		"internal/store": {"internal/util"},
+		"web/app":        {"web/api", "web/ui"},
+		"web/admin":      {"web/api"},
+		"gems/app":       {"gems/core"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected dependencies:\n got %v\nwant %v", got, want)
+	}
+
+	markdown, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(markdown), "## Dependency Graph") || !strings.Contains(string(markdown), "| web/app | web/api, web/ui |") {
+		t.Fatalf("expected a dependency graph section:\n%s", markdown)
+	}
+
+	opts.DOTOutputPath = "CODEMAP.dot"
+	opts.MermaidOutputPath = "CODEMAP.mmd"
+	if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+		t.Fatalf("expected missing graph outputs to be stale, got stale=%v err=%v", stale, err)
+	}
+	if _, generated, err := EnsureUpToDate(context.Background(), opts); err != nil || !generated {
+		t.Fatalf("expected regeneration, got %v (err %v)", generated, err)
+	}
+	dot, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.dot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(dot), "// codemap-hash: "+cm.ContentHash+"\ndigraph codemap {") || !strings.Contains(string(dot), "\t\"internal/store\" -> \"internal/util\";\n") {
+		t.Fatalf("unexpected DOT output:\n%s", dot)
+	}
+	mermaid, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.mmd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(mermaid), "\ngraph LR\n") || !strings.Contains(string(mermaid), "[\"internal/util\"]") || !strings.Contains(string(mermaid), " --> ") {
+		t.Fatalf("unexpected Mermaid output:\n%s", mermaid)
+	}
+
+	if stale, err := IsStale(context.Background(), opts); err != nil || stale {
+		t.Fatalf("expected fresh outputs, got stale=%v err=%v", stale, err)
+	}
+}
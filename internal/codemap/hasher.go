@@ -20,8 +20,8 @@ import (
 )
 
 const (
-	codemapStateVersion    = 4
-	analysisCacheVersionV2 = 2
+	codemapStateVersion  = 4
+	analysisCacheVersion = 3
 )
 
 type cachedStateFile struct {
@@ -33,7 +33,8 @@ type cachedAnalysisFile struct {
 }
 
 type cachedHashFile struct {
-	hash string
+	hash      string
+	scanLines int // Lines searched when hash is empty
 }
 
 var (
@@ -71,29 +72,73 @@ type DirStateEntry struct {
 
 // CachedPackage stores package-level analysis output for incremental rebuilds.
 type CachedPackage struct {
+	Scope        string   `json:"scope"` // Go module path, or the language ID for other analyzers
 	RelativePath string   `json:"relativePath"`
 	Fingerprint  string   `json:"fingerprint"`
 	FileRelPaths []string `json:"fileRelPaths,omitempty"`
 	Package      Package  `json:"package"`
 }
 
-// AnalysisCache stores cached package analysis metadata.
+// AnalysisCache stores cached package analysis metadata. Packages from every
+// analyzer share the cache, distinguished by CachedPackage.Scope.
 type AnalysisCache struct {
 	Version           int             `json:"version"`
 	IncludeTests      bool            `json:"includeTests"`
+	IncludeUnexported bool            `json:"includeUnexported,omitempty"`
+	LowMemory         bool            `json:"lowMemory,omitempty"`         // Packages are compacted render records
+	PurposeExtractors []string        `json:"purposeExtractors,omitempty"` // Languages with a custom PurposeExtractor
 	LargePackageFiles int             `json:"largePackageFiles"`
-	ModulePath        string          `json:"modulePath"`
+	JSONMinFiles      int             `json:"jsonMinFiles,omitempty"`
+	AllKeyFiles       bool            `json:"allKeyFiles,omitempty"` // Every file of smaller packages is a key file, see keepsAllKeyFiles
+	Funcs             bool            `json:"funcs,omitempty"`       // Compacted packages kept Funcs, see keepsFuncs
+	Symbols           bool            `json:"symbols,omitempty"`     // Compacted packages kept Symbols, see keepsSymbols
 	Packages          []CachedPackage `json:"packages,omitempty"`
+	Concerns          *ConcernCache   `json:"concerns,omitempty"`
+	Scans             []FileScan      `json:"scans,omitempty"`
+}
+
+// ConcernCache stores concern results for the file set and concern
+// definitions identified by Fingerprint.
+type ConcernCache struct {
+	Fingerprint string    `json:"fingerprint"`
+	Concerns    []Concern `json:"concerns,omitempty"`
 }
 
 // CodemapState stores local cache metadata for staleness checks.
 type CodemapState struct {
-	Version       int             `json:"version"`
-	AggregateHash string          `json:"aggregateHash"`
-	RootEntries   []string        `json:"rootEntries,omitempty"`
-	Dirs          []DirStateEntry `json:"dirs,omitempty"`
-	Entries       []StateEntry    `json:"entries"`
-	Analysis      *AnalysisCache  `json:"analysis,omitempty"`
+	Version       int               `json:"version"`
+	AggregateHash string            `json:"aggregateHash"`
+	RootEntries   []string          `json:"rootEntries,omitempty"`
+	Dirs          []DirStateEntry   `json:"dirs,omitempty"`
+	Entries       []StateEntry      `json:"entries"`
+	Analysis      *AnalysisCache    `json:"analysis,omitempty"`
+	GitIgnore     bool              `json:"gitIgnore,omitempty"`
+	GitTracked    bool              `json:"gitTracked,omitempty"`
+	IgnoreFiles   []IgnoreFileState `json:"ignoreFiles,omitempty"`
+	Outputs       []string          `json:"outputs,omitempty"` // Output files written by this and earlier runs
+	// Options.ExcludePatterns and IncludePatterns the entries were indexed with
+	ExcludePatterns []string `json:"excludePatterns,omitempty"`
+	IncludePatterns []string `json:"includePatterns,omitempty"`
+	// Options.NestedCodemaps the entries were indexed with and the nested
+	// codemap directories left out
+	NestedCodemaps bool     `json:"nestedCodemaps,omitempty"`
+	NestedRoots    []string `json:"nestedRoots,omitempty"`
+	// Indexing signatures of the external analyzers the entries were indexed with
+	ExternalLanguages []string `json:"externalLanguages,omitempty"`
+	// Canonical Options.Languages the entries were indexed with
+	Languages []string `json:"languages,omitempty"`
+	// Files and directories outside the index that analysis read
+	AuxFiles []AuxFileState `json:"auxFiles,omitempty"`
+	// outputOptionsSignature of the options the outputs were generated with,
+	// and the number of packages they were rendered from
+	OptionsSignature string `json:"optionsSignature,omitempty"`
+	Packages         int    `json:"packages,omitempty"`
+	// Pages written to Options.SplitOutputDir, relative to the root
+	SplitPages []string `json:"splitPages,omitempty"`
+	// Commit HEAD pointed at when a run with Options.Since wrote the state,
+	// and the files that differed from it
+	GitHead  string   `json:"gitHead,omitempty"`
+	GitDirty []string `json:"gitDirty,omitempty"`
 }
 
 func cloneCodemapState(state *CodemapState) *CodemapState {
@@ -101,8 +146,41 @@ func cloneCodemapState(state *CodemapState) *CodemapState {
 		return nil
 	}
 	out := &CodemapState{
-		Version:       state.Version,
-		AggregateHash: state.AggregateHash,
+		Version:        state.Version,
+		AggregateHash:  state.AggregateHash,
+		GitIgnore:      state.GitIgnore,
+		GitTracked:     state.GitTracked,
+		NestedCodemaps: state.NestedCodemaps,
+
+		OptionsSignature: state.OptionsSignature,
+		Packages:         state.Packages,
+		GitHead:          state.GitHead,
+	}
+	if len(state.IgnoreFiles) > 0 {
+		out.IgnoreFiles = append([]IgnoreFileState(nil), state.IgnoreFiles...)
+	}
+	out.ExcludePatterns = append([]string(nil), state.ExcludePatterns...)
+	out.IncludePatterns = append([]string(nil), state.IncludePatterns...)
+	if len(state.NestedRoots) > 0 {
+		out.NestedRoots = append([]string(nil), state.NestedRoots...)
+	}
+	if len(state.ExternalLanguages) > 0 {
+		out.ExternalLanguages = append([]string(nil), state.ExternalLanguages...)
+	}
+	if len(state.Languages) > 0 {
+		out.Languages = append([]string(nil), state.Languages...)
+	}
+	if len(state.Outputs) > 0 {
+		out.Outputs = append([]string(nil), state.Outputs...)
+	}
+	if len(state.AuxFiles) > 0 {
+		out.AuxFiles = append([]AuxFileState(nil), state.AuxFiles...)
+	}
+	if len(state.SplitPages) > 0 {
+		out.SplitPages = append([]string(nil), state.SplitPages...)
+	}
+	if len(state.GitDirty) > 0 {
+		out.GitDirty = append([]string(nil), state.GitDirty...)
 	}
 	if len(state.RootEntries) > 0 {
 		out.RootEntries = append([]string(nil), state.RootEntries...)
@@ -126,8 +204,14 @@ func cloneAnalysisCache(cache *AnalysisCache) *AnalysisCache {
 	out := &AnalysisCache{
 		Version:           cache.Version,
 		IncludeTests:      cache.IncludeTests,
+		IncludeUnexported: cache.IncludeUnexported,
+		LowMemory:         cache.LowMemory,
+		PurposeExtractors: append([]string(nil), cache.PurposeExtractors...),
 		LargePackageFiles: cache.LargePackageFiles,
-		ModulePath:        cache.ModulePath,
+		JSONMinFiles:      cache.JSONMinFiles,
+		AllKeyFiles:       cache.AllKeyFiles,
+		Funcs:             cache.Funcs,
+		Symbols:           cache.Symbols,
 	}
 	if len(cache.Packages) > 0 {
 		out.Packages = make([]CachedPackage, len(cache.Packages))
@@ -136,6 +220,13 @@ func cloneAnalysisCache(cache *AnalysisCache) *AnalysisCache {
 			out.Packages[i].FileRelPaths = append([]string(nil), cache.Packages[i].FileRelPaths...)
 		}
 	}
+	if cache.Concerns != nil {
+		out.Concerns = &ConcernCache{
+			Fingerprint: cache.Concerns.Fingerprint,
+			Concerns:    append([]Concern(nil), cache.Concerns.Concerns...),
+		}
+	}
+	out.Scans = append([]FileScan(nil), cache.Scans...)
 	return out
 }
 
@@ -155,7 +246,14 @@ func ComputeHash(ctx context.Context, root string) (string, error) {
 
 func computeAggregateHash(ctx context.Context, idx *FileIndex, prev *CodemapState) (string, *CodemapState, error) {
 	if aggregate, ok := aggregateHashFromState(idx, prev); ok {
-		return aggregate, cloneCodemapState(prev), nil
+		next := cloneCodemapState(prev)
+		next.GitIgnore = idx.GitIgnore
+		next.GitTracked = idx.GitTracked
+		next.IgnoreFiles = idx.IgnoreFiles
+		next.ExcludePatterns, next.IncludePatterns = idx.ExcludePatterns, idx.IncludePatterns
+		next.NestedCodemaps, next.NestedRoots = idx.NestedCodemaps, idx.NestedRoots
+		next.ExternalLanguages, next.Languages = idx.ExternalLanguages, idx.Languages
+		return aggregate, next, nil
 	}
 
 	prevEntries := sortedStateEntries(prev)
@@ -206,11 +304,20 @@ func computeAggregateHash(ctx context.Context, idx *FileIndex, prev *CodemapStat
 
 	aggregate := hex.EncodeToString(h.Sum(nil))
 	next := &CodemapState{
-		Version:       codemapStateVersion,
-		AggregateHash: aggregate,
-		RootEntries:   rootEntriesFromIndex(idx),
-		Dirs:          dirStateFromIndex(idx),
-		Entries:       entries,
+		Version:           codemapStateVersion,
+		AggregateHash:     aggregate,
+		RootEntries:       rootEntriesFromIndex(idx),
+		Dirs:              dirStateFromIndex(idx),
+		Entries:           entries,
+		GitIgnore:         idx.GitIgnore,
+		GitTracked:        idx.GitTracked,
+		IgnoreFiles:       idx.IgnoreFiles,
+		ExcludePatterns:   idx.ExcludePatterns,
+		IncludePatterns:   idx.IncludePatterns,
+		NestedCodemaps:    idx.NestedCodemaps,
+		NestedRoots:       idx.NestedRoots,
+		ExternalLanguages: idx.ExternalLanguages,
+		Languages:         idx.Languages,
 	}
 	return aggregate, next, nil
 }
@@ -755,10 +862,19 @@ func buildFileIndexFromState(ctx context.Context, absRoot string, prev *CodemapS
 	}
 
 	return &FileIndex{
-		Root:        absRoot,
-		RootEntries: append([]string(nil), prev.RootEntries...),
-		Dirs:        dirRecordsFromState(prev.Dirs),
-		Files:       fileRecords,
+		Root:              absRoot,
+		RootEntries:       append([]string(nil), prev.RootEntries...),
+		Dirs:              dirRecordsFromState(prev.Dirs),
+		Files:             fileRecords,
+		GitIgnore:         prev.GitIgnore,
+		GitTracked:        prev.GitTracked,
+		IgnoreFiles:       append([]IgnoreFileState(nil), prev.IgnoreFiles...),
+		ExcludePatterns:   prev.ExcludePatterns,
+		IncludePatterns:   prev.IncludePatterns,
+		NestedCodemaps:    prev.NestedCodemaps,
+		NestedRoots:       append([]string(nil), prev.NestedRoots...),
+		ExternalLanguages: prev.ExternalLanguages,
+		Languages:         append([]string(nil), prev.Languages...),
 	}, unchanged.Load(), nil
 }
 
@@ -778,26 +894,11 @@ func filterRootEntries(entries []string, ignored map[string]struct{}) []string {
 
 func ignoredRootEntryNames(root string, opts Options) map[string]struct{} {
 	ignored := make(map[string]struct{}, 4)
-	maybeAdd := func(path string) {
-		if path == "" {
-			return
-		}
-		abs := path
-		if !filepath.IsAbs(abs) {
-			abs = filepath.Join(root, abs)
+	for _, path := range generatedPaths(root, opts) {
+		if filepath.Dir(path) == root {
+			ignored[filepath.Base(path)] = struct{}{}
 		}
-		if filepath.Dir(abs) != root {
-			return
-		}
-		ignored[filepath.Base(abs)] = struct{}{}
 	}
-
-	maybeAdd(opts.OutputPath)
-	if !opts.DisablePaths {
-		maybeAdd(opts.PathsOutputPath)
-	}
-	maybeAdd(resolveStatePath(root, opts))
-	maybeAdd(resolveAnalysisStatePath(root, opts))
 	return ignored
 }
 
@@ -964,7 +1065,7 @@ func readState(path string) (*CodemapState, error) {
 	if err := json.Unmarshal(data, &state); err != nil {
 		return nil, nil
 	}
-	if state.Version != codemapStateVersion {
+	if state.Version != codemapStateVersion && !migrateState(&state) {
 		return nil, nil
 	}
 
@@ -1035,6 +1136,11 @@ func resolveStatePath(root string, opts Options) string {
 	if statePath == "" {
 		statePath = ".codemap.state.json"
 	}
+	if opts.BranchState {
+		if branch := currentGitBranch(root); branch != "" {
+			statePath = branchStatePath(statePath, branch)
+		}
+	}
 	if filepath.IsAbs(statePath) {
 		return statePath
 	}
@@ -1042,7 +1148,12 @@ func resolveStatePath(root string, opts Options) string {
 }
 
 func resolveAnalysisStatePath(root string, opts Options) string {
-	statePath := resolveStatePath(root, opts)
+	return analysisStatePathFor(resolveStatePath(root, opts))
+}
+
+// analysisStatePathFor returns where the analysis cache belonging to the
+// state file at statePath is kept.
+func analysisStatePathFor(statePath string) string {
 	ext := filepath.Ext(statePath)
 	if ext == "" {
 		return statePath + ".analysis"
@@ -1074,7 +1185,7 @@ func readAnalysisCache(path string) (*AnalysisCache, error) {
 	if err := json.Unmarshal(data, &cache); err != nil {
 		return nil, nil
 	}
-	if cache.Version != analysisCacheVersionV2 {
+	if cache.Version != analysisCacheVersion {
 		return nil, nil
 	}
 	sort.Slice(cache.Packages, func(i, j int) bool {
@@ -1135,12 +1246,22 @@ func writeAnalysisCache(path string, cache *AnalysisCache) error {
 	return nil
 }
 
-// ReadExistingHash reads the hash from an existing codemap output file.
+// ReadExistingHash reads the hash from an existing codemap output file,
+// searching the default number of leading lines.
 func ReadExistingHash(path string) (string, error) {
+	return readExistingHash(path, defaultHashScanLines)
+}
+
+// readExistingHash reads the hash from the first scanLines lines of path
+// (0 = default). A cached miss from a shallower scan is retried.
+func readExistingHash(path string, scanLines int) (string, error) {
+	if scanLines <= 0 {
+		scanLines = defaultHashScanLines
+	}
 	hashFileCacheMu.RLock()
 	cached, ok := hashFileCache[path]
 	hashFileCacheMu.RUnlock()
-	if ok {
+	if ok && (cached.hash != "" || cached.scanLines >= scanLines) {
 		return cached.hash, nil
 	}
 
@@ -1156,34 +1277,18 @@ func ReadExistingHash(path string) (string, error) {
 	}
 	defer f.Close()
 
-	scanner := bufio.NewScanner(f)
-	linesChecked := 0
-	for scanner.Scan() {
-		linesChecked++
-		line := scanner.Text()
-		if hash := parseHashLine(line); hash != "" {
-			hashFileCacheMu.Lock()
-			hashFileCache[path] = cachedHashFile{
-				hash: hash,
-			}
-			hashFileCacheMu.Unlock()
-			return hash, nil
-		}
-		if linesChecked >= 20 {
-			break
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
+	hash, err := scanHashHeader(f, scanLines)
+	if err != nil {
 		return "", err
 	}
 
 	hashFileCacheMu.Lock()
 	hashFileCache[path] = cachedHashFile{
-		hash: "",
+		hash:      hash,
+		scanLines: scanLines,
 	}
 	hashFileCacheMu.Unlock()
-	return "", nil
+	return hash, nil
 }
 
 func parseHashLine(line string) string {
@@ -1195,8 +1300,11 @@ func parseHashLine(line string) string {
 		s = strings.TrimSpace(strings.TrimPrefix(s, "<!--"))
 		s = strings.TrimSpace(strings.TrimSuffix(s, "-->"))
 	}
-	if strings.HasPrefix(s, "#") {
-		s = strings.TrimSpace(strings.TrimPrefix(s, "#"))
+	for _, marker := range []string{"#", "//", "%%"} {
+		if strings.HasPrefix(s, marker) {
+			s = strings.TrimSpace(strings.TrimPrefix(s, marker))
+			break
+		}
 	}
 
 	const prefix = "codemap-hash:"
@@ -1221,6 +1329,14 @@ func parseHashLine(line string) string {
 	return hash
 }
 
+// forgetExistingHashes drops the cached output hashes, for long-running
+// processes that noticed outputs change on disk.
+func forgetExistingHashes() {
+	hashFileCacheMu.Lock()
+	clear(hashFileCache)
+	hashFileCacheMu.Unlock()
+}
+
 func cacheExistingHash(path, hash string) {
 	hashFileCacheMu.Lock()
 	hashFileCache[path] = cachedHashFile{
@@ -1229,6 +1345,99 @@ func cacheExistingHash(path, hash string) {
 	hashFileCacheMu.Unlock()
 }
 
+// outputOptions holds the Options that change what the outputs contain
+// without changing the file index, which indexStateMatches covers.
+type outputOptions struct {
+	IncludeTests           bool
+	IncludeUnexported      bool
+	Statistics             bool
+	StatisticsPackages     bool
+	LowMemory              bool
+	PurposeExtractors      []string
+	LargePackageFiles      int
+	JSONMinFiles           int
+	DetailBudget           int
+	MaxOutputTokens        int
+	Concerns               []ConcernDef
+	ConcernExampleLimit    int
+	ConcernPathLimit       int
+	PathsMini              bool
+	MarkdownTemplate       string
+	MarkdownTemplateHash   string // Hash of the template's contents
+	MarkdownPurposeLength  int
+	MarkdownTOC            bool
+	APISurface             bool
+	PathsPurposeLength     int
+	CoChange               bool
+	CoChangeCommits        int
+	SecurityImportPatterns []string
+	FileRoles              []FileRoleDef
+	ShellGrouping          string
+	ShellPrefixes          []string
+}
+
+// outputOptionsSignature hashes the outputOptions of opts, so outputs
+// generated with different options count as stale although the tree did not
+// change.
+func outputOptionsSignature(opts Options) string {
+	data, err := json.Marshal(outputOptions{
+		IncludeTests:           opts.IncludeTests,
+		IncludeUnexported:      opts.IncludeUnexported,
+		Statistics:             opts.Statistics,
+		StatisticsPackages:     opts.StatisticsPackages,
+		LowMemory:              opts.LowMemory,
+		PurposeExtractors:      purposeExtractorLanguages(opts),
+		LargePackageFiles:      opts.LargePackageFiles,
+		JSONMinFiles:           opts.JSONMinFiles,
+		DetailBudget:           opts.DetailBudget,
+		MaxOutputTokens:        opts.MaxOutputTokens,
+		Concerns:               opts.Concerns,
+		ConcernExampleLimit:    opts.ConcernExampleLimit,
+		ConcernPathLimit:       opts.ConcernPathLimit,
+		PathsMini:              opts.PathsMini,
+		MarkdownTemplate:       opts.MarkdownTemplate,
+		MarkdownTemplateHash:   markdownTemplateHash(opts),
+		MarkdownPurposeLength:  opts.MarkdownPurposeLength,
+		MarkdownTOC:            opts.MarkdownTOC,
+		APISurface:             opts.APISurface,
+		PathsPurposeLength:     opts.PathsPurposeLength,
+		CoChange:               opts.CoChange,
+		CoChangeCommits:        opts.CoChangeCommits,
+		SecurityImportPatterns: opts.SecurityImportPatterns,
+		FileRoles:              opts.FileRoles,
+		ShellGrouping:          opts.ShellGrouping,
+		ShellPrefixes:          opts.ShellPrefixes,
+	})
+	if err != nil {
+		return ""
+	}
+	return auxContentHash(data)
+}
+
+// markdownTemplateHash hashes the contents of opts.MarkdownTemplate; it is
+// empty without a template or when the template cannot be read.
+func markdownTemplateHash(opts Options) string {
+	template := newMarkdownRenderer(opts).Template
+	if template == "" {
+		return ""
+	}
+	content, err := os.ReadFile(template)
+	if err != nil {
+		return ""
+	}
+	return auxContentHash(content)
+}
+
+// renderInputsChanged reports whether state describes the outputs hashed
+// existingHash but they were generated with other options, or inputs outside
+// the file index that they were rendered from changed since.
+func renderInputsChanged(root string, opts Options, state *CodemapState, existingHash string) bool {
+	if state == nil || existingHash == "" || state.AggregateHash != existingHash {
+		return false
+	}
+	return state.OptionsSignature != outputOptionsSignature(opts) || auxFilesChanged(root, state.AuxFiles)
+}
+
 // IsStale checks if codemap outputs are stale.
 func IsStale(ctx context.Context, opts Options) (bool, error) {
 	root, err := filepath.Abs(opts.ProjectRoot)
@@ -1244,7 +1453,7 @@ func IsStale(ctx context.Context, opts Options) (bool, error) {
 	}
 
 	outputPath := filepath.Join(root, opts.OutputPath)
-	existingHash, err := ReadExistingHash(outputPath)
+	existingHash, err := readExistingHash(outputPath, opts.HashScanLines)
 	if err != nil {
 		return false, fmt.Errorf("read existing hash: %w", err)
 	}
@@ -1255,7 +1464,7 @@ func IsStale(ctx context.Context, opts Options) (bool, error) {
 	var existingPathsHash string
 	if !opts.DisablePaths {
 		pathsPath := filepath.Join(root, opts.PathsOutputPath)
-		existingPathsHash, err = ReadExistingHash(pathsPath)
+		existingPathsHash, err = readExistingHash(pathsPath, opts.HashScanLines)
 		if err != nil {
 			return false, fmt.Errorf("read existing paths hash: %w", err)
 		}
@@ -1263,13 +1472,23 @@ func IsStale(ctx context.Context, opts Options) (bool, error) {
 			return true, nil
 		}
 	}
+	if stale, err := secondaryOutputsStale(root, opts, existingHash); err != nil || stale {
+		return stale, err
+	}
 
 	state, err := readState(resolveStatePath(root, opts))
 	if err != nil {
 		return false, fmt.Errorf("read state: %w", err)
 	}
+	if renderInputsChanged(root, opts, state, existingHash) {
+		return true, nil
+	}
 	ignoredRootEntries := ignoredRootEntryNames(root, opts)
-	idx, unchangedFromState, err := buildFileIndexFromState(ctx, root, state, ignoredRootEntries)
+	fastState := state
+	if !indexStateMatches(state, opts) {
+		fastState = nil
+	}
+	idx, unchangedFromState, err := indexFromState(ctx, root, opts, fastState, ignoredRootEntries)
 	if err != nil {
 		return false, fmt.Errorf("build file index from state: %w", err)
 	}
@@ -1285,12 +1504,12 @@ func IsStale(ctx context.Context, opts Options) (bool, error) {
 		}
 	} else {
 		var matchedFromState bool
-		currentHash, matchedFromState, err = aggregateHashFromFilesystemState(ctx, root, state, ignoredRootEntries)
+		currentHash, matchedFromState, err = aggregateHashFromFilesystemState(ctx, root, fastState, ignoredRootEntries)
 		if err != nil {
 			return false, fmt.Errorf("verify state: %w", err)
 		}
 		if !matchedFromState {
-			idx, err = BuildFileIndex(ctx, root)
+			idx, err = buildIndex(ctx, root, opts)
 			if err != nil {
 				return false, fmt.Errorf("build file index: %w", err)
 			}
@@ -1305,7 +1524,7 @@ func IsStale(ctx context.Context, opts Options) (bool, error) {
 	}
 
 	if currentHash == "" {
-		idx, err = BuildFileIndex(ctx, root)
+		idx, err = buildIndex(ctx, root, opts)
 		if err != nil {
 			return false, fmt.Errorf("build file index: %w", err)
 		}
@@ -1324,3 +1543,89 @@ func IsStale(ctx context.Context, opts Options) (bool, error) {
 
 	return false, nil
 }
+
+// IsStaleWithGrace checks staleness like IsStale and additionally reports
+// whether stale outputs are still inside opts.StalenessGrace, measured from the
+// GeneratedAt header of the markdown output.
+func IsStaleWithGrace(ctx context.Context, opts Options) (stale bool, withinGrace bool, err error) {
+	stale, err = IsStale(ctx, opts)
+	if err != nil || !stale {
+		return stale, false, err
+	}
+	withinGrace, err = WithinStalenessGrace(opts)
+	if err != nil {
+		return false, false, err
+	}
+	return true, withinGrace, nil
+}
+
+// WithinStalenessGrace reports whether outputs already known to be stale
+// were generated no longer than opts.StalenessGrace ago, according to the
+// GeneratedAt header of the markdown output.
+func WithinStalenessGrace(opts Options) (bool, error) {
+	if opts.StalenessGrace <= 0 {
+		return false, nil
+	}
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return false, fmt.Errorf("resolve root: %w", err)
+	}
+	if opts.OutputPath == "" {
+		opts.OutputPath = "CODEMAP.md"
+	}
+	generatedAt, err := ReadExistingGeneratedAt(filepath.Join(root, opts.OutputPath))
+	if err != nil {
+		return false, fmt.Errorf("read generated time: %w", err)
+	}
+	if generatedAt.IsZero() {
+		return false, nil
+	}
+	return time.Since(generatedAt) <= opts.StalenessGrace, nil
+}
+
+// ReadExistingGeneratedAt reads the generation time from an existing codemap
+// output file. It returns the zero time when the file or header is missing.
+func ReadExistingGeneratedAt(path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	linesChecked := 0
+	for scanner.Scan() {
+		linesChecked++
+		if generatedAt, ok := parseGeneratedLine(scanner.Text()); ok {
+			return generatedAt, nil
+		}
+		if linesChecked >= 20 {
+			break
+		}
+	}
+	return time.Time{}, scanner.Err()
+}
+
+func parseGeneratedLine(line string) (time.Time, bool) {
+	s := strings.TrimSpace(line)
+	if strings.HasPrefix(s, "<!--") {
+		s = strings.TrimSpace(strings.TrimPrefix(s, "<!--"))
+		s = strings.TrimSpace(strings.TrimSuffix(s, "-->"))
+	}
+	if strings.HasPrefix(s, "#") {
+		s = strings.TrimSpace(strings.TrimPrefix(s, "#"))
+	}
+
+	const prefix = "Generated:"
+	if !strings.HasPrefix(s, prefix) {
+		return time.Time{}, false
+	}
+	generatedAt, err := time.Parse("2006-01-02 15:04:05 UTC", strings.TrimSpace(s[len(prefix):]))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return generatedAt, true
+}
@@ -0,0 +1,126 @@
+package codemap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DirHash is the content fingerprint of one directory of hashed files.
+type DirHash struct {
+	Path      string // Directory relative to the project root ("." for the root)
+	FilesHash string // Covers the files directly inside Path
+	TreeHash  string // Covers every file below Path, including subdirectories
+}
+
+// HashesRenderer renders CODEMAP.hashes output.
+type HashesRenderer struct{}
+
+func (HashesRenderer) Name() string        { return "hashes" }
+func (HashesRenderer) DefaultPath() string { return "CODEMAP.hashes" }
+func (HashesRenderer) Render(cm *Codemap) (string, error) {
+	return renderHashes(cm), nil
+}
+
+func renderHashes(cm *Codemap) string {
+	var sb strings.Builder
+	sb.WriteString("# codemap-hash: ")
+	sb.WriteString(cm.ContentHash)
+	sb.WriteString("\n# Format: dir\\tfiles_sha256\\ttree_sha256\n")
+	for _, dir := range cm.DirHashes {
+		sb.WriteString(dir.Path)
+		sb.WriteByte('\t')
+		sb.WriteString(dir.FilesHash)
+		sb.WriteByte('\t')
+		sb.WriteString(dir.TreeHash)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// directoryHashes derives per-directory fingerprints from the content hashes
+// already recorded in state, so no file is read twice. Every ancestor of a
+// hashed file gets a row; a directory's tree hash combines its direct files
+// with the tree hashes of its subdirectories, so it changes exactly when some
+// file below it is added, removed, renamed or edited.
+func directoryHashes(entries []StateEntry) []DirHash {
+	files := make(map[string][]StateEntry)
+	children := make(map[string]map[string]struct{})
+	all := map[string]struct{}{".": {}}
+	for _, entry := range entries {
+		dir := path.Dir(entry.RelPath)
+		files[dir] = append(files[dir], entry)
+		for ; dir != "."; dir = path.Dir(dir) {
+			if _, seen := all[dir]; seen {
+				break
+			}
+			all[dir] = struct{}{}
+			parent := path.Dir(dir)
+			if children[parent] == nil {
+				children[parent] = make(map[string]struct{})
+			}
+			children[parent][dir] = struct{}{}
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	dirs := sortedImportSet(all)
+
+	filesHashes := make(map[string]string, len(dirs))
+	for _, dir := range dirs {
+		dirFiles := files[dir]
+		sort.Slice(dirFiles, func(i, j int) bool { return dirFiles[i].RelPath < dirFiles[j].RelPath })
+		h := sha256.New()
+		for _, entry := range dirFiles {
+			h.Write([]byte(path.Base(entry.RelPath)))
+			h.Write([]byte{0})
+			h.Write([]byte(entry.ContentHash))
+			h.Write([]byte{0})
+		}
+		filesHashes[dir] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	treeHashes := make(map[string]string, len(dirs))
+	var treeHash func(dir string) string
+	treeHash = func(dir string) string {
+		if sum, ok := treeHashes[dir]; ok {
+			return sum
+		}
+		h := sha256.New()
+		h.Write([]byte("files\x00"))
+		h.Write([]byte(filesHashes[dir]))
+		h.Write([]byte{0})
+		for _, sub := range sortedImportSet(children[dir]) {
+			h.Write([]byte(path.Base(sub)))
+			h.Write([]byte("/\x00"))
+			h.Write([]byte(treeHash(sub)))
+			h.Write([]byte{0})
+		}
+		sum := hex.EncodeToString(h.Sum(nil))
+		treeHashes[dir] = sum
+		return sum
+	}
+
+	result := make([]DirHash, 0, len(dirs))
+	for _, dir := range dirs {
+		result = append(result, DirHash{Path: dir, FilesHash: filesHashes[dir], TreeHash: treeHash(dir)})
+	}
+	return result
+}
+
+// hashesOutputStale reports whether an enabled hashes output is missing or
+// was written for a different content hash than the markdown output.
+func hashesOutputStale(root string, opts Options, existingHash string) (bool, error) {
+	if opts.HashesOutputPath == "" {
+		return false, nil
+	}
+	hashesHash, err := readExistingHash(outputAbsPath(root, opts.HashesOutputPath), opts.HashScanLines)
+	if err != nil {
+		return false, fmt.Errorf("read existing hashes hash: %w", err)
+	}
+	return hashesHash == "" || hashesHash != existingHash, nil
+}
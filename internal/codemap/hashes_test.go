@@ -0,0 +1,99 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirectoryHashesTrackSubtreeChanges(t *testing.T) {
+	entries := []StateEntry{
+		{RelPath: "main.go", ContentHash: "a"},
+		{RelPath: "internal/store/store.go", ContentHash: "b"},
+		{RelPath: "internal/web/web.go", ContentHash: "c"},
+	}
+	before := dirHashMap(directoryHashes(entries))
+	for _, dir := range []string{".", "internal", "internal/store", "internal/web"} {
+		if _, ok := before[dir]; !ok {
+			t.Fatalf("missing row for %s in %v", dir, before)
+		}
+	}
+
+	entries[1].ContentHash = "b2"
+	after := dirHashMap(directoryHashes(entries))
+	if after["internal/web"] != before["internal/web"] {
+		t.Fatal("expected sibling directory hash to be unchanged")
+	}
+	if after["."].FilesHash != before["."].FilesHash {
+		t.Fatal("expected root files hash to be unchanged")
+	}
+	for _, dir := range []string{".", "internal", "internal/store"} {
+		if after[dir].TreeHash == before[dir].TreeHash {
+			t.Fatalf("expected tree hash of %s to change", dir)
+		}
+	}
+	if after["internal/store"].FilesHash == before["internal/store"].FilesHash {
+		t.Fatal("expected files hash of internal/store to change")
+	}
+}
+
+func TestHashesOutputWrittenAndCheckedForStaleness(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "pkg"), 0755); err != nil {
+		t.Fatalf("mkdir pkg: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("write main.go: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "pkg", "pkg.go"), []byte("package pkg\n"), 0644); err != nil {
+		t.Fatalf("write pkg.go: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	if _, _, err := EnsureUpToDate(context.Background(), opts); err != nil {
+		t.Fatalf("EnsureUpToDate returned error: %v", err)
+	}
+
+	opts.HashesOutputPath = "CODEMAP.hashes"
+	if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+		t.Fatalf("expected missing hashes output to be stale, got %v (err %v)", stale, err)
+	}
+	cm, generated, err := EnsureUpToDate(context.Background(), opts)
+	if err != nil || !generated {
+		t.Fatalf("expected regeneration, got %v (err %v)", generated, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.hashes"))
+	if err != nil {
+		t.Fatalf("read CODEMAP.hashes: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if lines[0] != "# codemap-hash: "+cm.ContentHash {
+		t.Fatalf("unexpected header: %q", lines[0])
+	}
+	var dirs []string
+	for _, line := range lines[2:] {
+		dirs = append(dirs, strings.Split(line, "\t")[0])
+	}
+	if strings.Join(dirs, ",") != ".,pkg" {
+		t.Fatalf("unexpected directories: %v", dirs)
+	}
+
+	if stale, err := IsStale(context.Background(), opts); err != nil || stale {
+		t.Fatalf("expected fresh outputs, got %v (err %v)", stale, err)
+	}
+	if _, generated, err := EnsureUpToDate(context.Background(), opts); err != nil || generated {
+		t.Fatalf("expected no regeneration, got %v (err %v)", generated, err)
+	}
+}
+
+func dirHashMap(hashes []DirHash) map[string]DirHash {
+	m := make(map[string]DirHash, len(hashes))
+	for _, h := range hashes {
+		m[h.Path] = h
+	}
+	return m
+}
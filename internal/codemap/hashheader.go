@@ -0,0 +1,109 @@
+package codemap
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	// defaultHashScanLines is how many leading lines of an output are searched
+	// for its codemap-hash header when Options.HashScanLines is unset.
+	defaultHashScanLines = 20
+	// maxHeaderLineBytes caps a scanned line so binary or minified content
+	// cannot make header scans buffer the whole file.
+	maxHeaderLineBytes = 64 * 1024
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// newHeaderScanner returns a line scanner over r that strips a UTF-8 byte
+// order mark and transcodes UTF-16 input with a byte order mark, as written
+// by some Windows editors. Trailing carriage returns are left to the caller's
+// whitespace trimming.
+func newHeaderScanner(r io.Reader) *bufio.Scanner {
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	bom, _ := br.Peek(3)
+	switch {
+	case bytes.HasPrefix(bom, utf8BOM):
+		_, _ = br.Discard(len(utf8BOM))
+	case bytes.HasPrefix(bom, utf16LEBOM):
+		_, _ = br.Discard(len(utf16LEBOM))
+		src = &utf16Reader{r: br, littleEndian: true}
+	case bytes.HasPrefix(bom, utf16BEBOM):
+		_, _ = br.Discard(len(utf16BEBOM))
+		src = &utf16Reader{r: br}
+	}
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 0, 4096), maxHeaderLineBytes)
+	return scanner
+}
+
+// scanHashHeader returns the codemap-hash found within the first maxLines
+// lines of r. Overlong lines end the scan without an error, since they only
+// occur in files that are not codemap outputs.
+func scanHashHeader(r io.Reader, maxLines int) (string, error) {
+	if maxLines <= 0 {
+		maxLines = defaultHashScanLines
+	}
+	scanner := newHeaderScanner(r)
+	for lines := 0; lines < maxLines && scanner.Scan(); lines++ {
+		if hash := parseHashLine(scanner.Text()); hash != "" {
+			return hash, nil
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		return "", err
+	}
+	return "", nil
+}
+
+// utf16Reader transcodes UTF-16 code units from r to UTF-8.
+type utf16Reader struct {
+	r            *bufio.Reader
+	littleEndian bool
+	pending      []byte
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.pending) == 0 {
+		unit, err := u.readUnit()
+		if err != nil {
+			return 0, err
+		}
+		r := rune(unit)
+		if utf16.IsSurrogate(r) {
+			low, err := u.readUnit()
+			if err != nil && !errors.Is(err, io.EOF) {
+				return 0, err
+			}
+			r = utf16.DecodeRune(r, rune(low))
+		}
+		u.pending = utf8.AppendRune(u.pending, r)
+	}
+	n := copy(p, u.pending)
+	u.pending = u.pending[n:]
+	return n, nil
+}
+
+func (u *utf16Reader) readUnit() (uint16, error) {
+	var buf [2]byte
+	if _, err := io.ReadFull(u.r, buf[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, io.EOF
+		}
+		return 0, err
+	}
+	if u.littleEndian {
+		return uint16(buf[0]) | uint16(buf[1])<<8, nil
+	}
+	return uint16(buf[1]) | uint16(buf[0])<<8, nil
+}
@@ -0,0 +1,410 @@
+package codemap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// goRouteCall matches route registrations of net/http, chi, gin, echo,
+	// gorilla/mux and fiber up to the comma after the path, e.g.
+	// mux.HandleFunc("GET /users/{id}", ...), r.Get("/users", ...),
+	// g.Handle("GET", "/users", ...) or r.Method("GET", "/users", ...).
+	goRouteCall = regexp.MustCompile(`\b(\w+)(?:\.With\([^)]*\))?\.(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS|Any|All|Get|Post|Put|Patch|Delete|Head|Options|Handle|HandleFunc|Method|MethodFunc)\(\s*(?:(?:http\.Method(\w+)|"([A-Za-z]+)")\s*,\s*)?"((?:[A-Z]+\s+)?/[^"]*)"\s*,`)
+	// goRouteGroup matches prefixed router groups, e.g. v1 := r.Group("/v1").
+	goRouteGroup = regexp.MustCompile(`\b(\w+)\s*:?=\s*(\w+)\.Group\(\s*"([^"]*)"`)
+	// goRouteClosure matches chi sub-routers, e.g.
+	// r.Route("/users", func(r chi.Router) {.
+	goRouteClosure    = regexp.MustCompile(`\b(\w+)\.Route\(\s*"([^"]*)"\s*,\s*func\s*\(\s*(\w+)`)
+	goMethodsCall     = regexp.MustCompile(`^\s*\.Methods\(([^)]*)\)`)
+	goFuncDecl        = regexp.MustCompile(`(?m)^func\s+(?:\([^)]*\)\s*)?(\w+)\s*[\[(]`)
+	goLineComment     = regexp.MustCompile(`(?m)^[ \t]*//.*$`)
+	goSelectorPattern = regexp.MustCompile(`^[\w.]+$`)
+)
+
+// goRouterImports mark Go files whose route registrations are scanned.
+var goRouterImports = []string{
+	`"net/http"`,
+	`"github.com/go-chi/chi`,
+	`"github.com/gin-gonic/gin"`,
+	`"github.com/labstack/echo`,
+	`"github.com/gorilla/mux"`,
+	`"github.com/gofiber/fiber`,
+}
+
+// openAPIMethods are the operation keys of an OpenAPI path item.
+var openAPIMethods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true,
+	"options": true, "head": true, "patch": true, "trace": true,
+}
+
+// maxEndpointSourceBytes bounds the files scanned for routes.
+const maxEndpointSourceBytes = 1 << 20
+
+// detectHTTPEndpoints lists the HTTP routes the project serves: those
+// registered in non-test Go files importing net/http or a common router,
+// and those declared by OpenAPI and Swagger specs (openapi.* and swagger.*
+// files). A spec operation matching a registered route's method and path
+// is merged into it. Handlers are resolved to the Go file declaring a func
+// or method of their name, for specs by operationId. Files are read through
+// scans, so only files changed since the last run are scanned again.
+func detectHTTPEndpoints(ctx context.Context, scans *fileScans, idx *FileIndex) ([]HTTPEndpoint, error) {
+	if idx == nil {
+		return nil, nil
+	}
+	var endpoints []HTTPEndpoint
+	var specs []HTTPEndpoint
+	decls := make(map[string][]string) // Lowercase func name to declaring files
+	for _, rec := range idx.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if rec.IsTest || rec.Size > maxEndpointSourceBytes || (rec.Language != languageGo && !isAPISpec(rec)) {
+			continue
+		}
+		scan, ok := scans.scan(rec.RelPath)
+		if !ok {
+			continue
+		}
+		if rec.Language != languageGo {
+			specs = append(specs, cloneEndpoints(scan.Endpoints)...)
+			continue
+		}
+		for _, key := range scan.Decls {
+			decls[key] = append(decls[key], rec.RelPath)
+		}
+		endpoints = append(endpoints, cloneEndpoints(scan.Endpoints)...)
+	}
+	if len(endpoints) == 0 && len(specs) == 0 {
+		return nil, nil
+	}
+
+	for i := range endpoints {
+		endpoints[i].HandlerFile = resolveHandlerFile(endpoints[i].Handler, endpoints[i].Sources[0], decls)
+	}
+	byRoute := make(map[string]int, len(endpoints))
+	for i, endpoint := range endpoints {
+		byRoute[endpointKey(endpoint.Method, endpoint.Path)] = i
+	}
+	for _, spec := range specs {
+		if i, ok := byRoute[endpointKey(spec.Method, spec.Path)]; ok {
+			if !containsString(endpoints[i].Sources, spec.Sources[0]) {
+				endpoints[i].Sources = append(endpoints[i].Sources, spec.Sources[0])
+			}
+			continue
+		}
+		if spec.Handler != "" {
+			spec.HandlerFile = resolveHandlerFile(spec.Handler, "", decls)
+		}
+		byRoute[endpointKey(spec.Method, spec.Path)] = len(endpoints)
+		endpoints = append(endpoints, spec)
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		if endpoints[i].Path != endpoints[j].Path {
+			return endpoints[i].Path < endpoints[j].Path
+		}
+		return endpoints[i].Method < endpoints[j].Method
+	})
+	return endpoints, nil
+}
+
+// parseGoRoutes lists the routes registered in one Go file. Prefixes of
+// router groups assigned to variables and of chi Route closures are applied
+// to the routes registered on them.
+func parseGoRoutes(relPath, src string) []HTTPEndpoint {
+	type event struct {
+		start, end int
+		loc        []int
+		kind       int
+	}
+	const (
+		eventRoute = iota
+		eventGroup
+		eventClosure
+	)
+	var events []event
+	for _, loc := range goRouteCall.FindAllStringSubmatchIndex(src, -1) {
+		events = append(events, event{start: loc[0], end: loc[1], loc: loc, kind: eventRoute})
+	}
+	for _, loc := range goRouteGroup.FindAllStringSubmatchIndex(src, -1) {
+		events = append(events, event{start: loc[0], end: loc[1], loc: loc, kind: eventGroup})
+	}
+	for _, loc := range goRouteClosure.FindAllStringSubmatchIndex(src, -1) {
+		events = append(events, event{start: loc[0], end: loc[1], loc: loc, kind: eventClosure})
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].start < events[j].start })
+
+	type scope struct {
+		name   string
+		prefix string
+		depth  int
+	}
+	prefixes := make(map[string]string)
+	var scopes []scope
+	var endpoints []HTTPEndpoint
+	depth, last := 0, 0
+	for _, ev := range events {
+		depth += strings.Count(src[last:ev.start], "{") - strings.Count(src[last:ev.start], "}")
+		last = ev.start
+		for len(scopes) > 0 && scopes[len(scopes)-1].depth >= depth {
+			top := scopes[len(scopes)-1]
+			prefixes[top.name] = top.prefix
+			scopes = scopes[:len(scopes)-1]
+		}
+		group := func(i int) string {
+			if ev.loc[2*i] < 0 {
+				return ""
+			}
+			return src[ev.loc[2*i]:ev.loc[2*i+1]]
+		}
+
+		switch ev.kind {
+		case eventGroup:
+			prefixes[group(1)] = joinRoutePath(prefixes[group(2)], group(3))
+		case eventClosure:
+			scopes = append(scopes, scope{name: group(3), prefix: prefixes[group(3)], depth: depth})
+			prefixes[group(3)] = joinRoutePath(prefixes[group(1)], group(2))
+		case eventRoute:
+			method := routeCallMethod(group(2))
+			if explicit := group(3) + group(4); explicit != "" {
+				method = strings.ToUpper(explicit)
+			}
+			pattern := group(5)
+			if verb, rest, ok := strings.Cut(pattern, " "); ok && !strings.HasPrefix(pattern, "/") {
+				method, pattern = verb, strings.TrimSpace(rest)
+			}
+			args, end := goCallArgs(src, ev.end)
+			if len(args) == 0 {
+				continue
+			}
+			handler := args[len(args)-1]
+			if strings.HasPrefix(handler, "func") {
+				handler = "func literal"
+			}
+			methods := []string{method}
+			if match := goMethodsCall.FindStringSubmatch(src[end:]); match != nil && method == "ANY" {
+				methods = methods[:0]
+				for _, m := range strings.Split(match[1], ",") {
+					if m = strings.Trim(strings.TrimSpace(m), `"`); m != "" {
+						methods = append(methods, strings.ToUpper(strings.TrimPrefix(m, "http.Method")))
+					}
+				}
+			}
+			line := strings.Count(src[:ev.start], "\n") + 1
+			for _, m := range methods {
+				endpoints = append(endpoints, HTTPEndpoint{
+					Method:  m,
+					Path:    joinRoutePath(prefixes[group(1)], pattern),
+					Handler: handler,
+					Sources: []string{relPath + ":" + strconv.Itoa(line)},
+				})
+			}
+		}
+	}
+	return endpoints
+}
+
+// routeCallMethod maps a registration method name to an HTTP method, or
+// "ANY" when it accepts every method.
+func routeCallMethod(call string) string {
+	switch call {
+	case "Any", "All", "Handle", "HandleFunc", "Method", "MethodFunc":
+		return "ANY"
+	}
+	return strings.ToUpper(call)
+}
+
+// goCallArgs splits the remaining arguments of a call starting at offset,
+// just past a comma, up to the closing parenthesis. It returns the trimmed
+// top-level arguments and the offset after the parenthesis.
+func goCallArgs(src string, offset int) ([]string, int) {
+	var args []string
+	depth := 0
+	start := offset
+	for i := offset; i < len(src); i++ {
+		switch src[i] {
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			if depth == 0 {
+				if arg := strings.TrimSpace(src[start:i]); arg != "" {
+					args = append(args, arg)
+				}
+				return args, i + 1
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(src[start:i]))
+				start = i + 1
+			}
+		case '"', '`', '\'':
+			end := strings.IndexByte(src[i+1:], src[i])
+			if end < 0 {
+				return nil, len(src)
+			}
+			i += end + 1
+		}
+	}
+	return nil, len(src)
+}
+
+func joinRoutePath(prefix, route string) string {
+	if prefix == "" {
+		return route
+	}
+	if route == "" || route == "/" {
+		return prefix
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(route, "/")
+}
+
+// endpointKey identifies a route independently of its parameter syntax:
+// "/users/{id}", "/users/:id" and "/users/<id>" share a key.
+func endpointKey(method, route string) string {
+	segments := strings.Split(strings.TrimSuffix(route, "/"), "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") || strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "<") {
+			segments[i] = "{}"
+		}
+	}
+	return method + " " + strings.Join(segments, "/")
+}
+
+// resolveHandlerFile returns the Go file declaring the func or method a
+// handler expression calls, e.g. h.ListUsers or handlers.Get(db), or wraps,
+// as in http.HandlerFunc(h.Logout). Among several declarations, one in the
+// registering file's directory wins.
+func resolveHandlerFile(handler, source string, decls map[string][]string) string {
+	file, _, _ := strings.Cut(source, ":")
+	if handler == "func literal" {
+		return file
+	}
+	outer, inner, wraps := strings.Cut(handler, "(")
+	names := []string{outer}
+	if inner = strings.TrimSuffix(inner, ")"); wraps && goSelectorPattern.MatchString(inner) {
+		names = append(names, inner)
+	}
+	for _, name := range names {
+		name = name[strings.LastIndex(name, ".")+1:]
+		files := decls[strings.ToLower(strings.TrimSpace(name))]
+		if len(files) == 1 {
+			return files[0]
+		}
+		for _, candidate := range files {
+			if file != "" && path.Dir(candidate) == path.Dir(file) {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
+
+// parseOpenAPIEndpoints lists the operations of an OpenAPI or Swagger spec
+// in JSON or YAML.
+func parseOpenAPIEndpoints(relPath string, content []byte) []HTTPEndpoint {
+	var endpoints []HTTPEndpoint
+	add := func(route, method, operationID string) {
+		if !strings.HasPrefix(route, "/") || !openAPIMethods[method] {
+			return
+		}
+		endpoints = append(endpoints, HTTPEndpoint{
+			Method:  strings.ToUpper(method),
+			Path:    route,
+			Handler: operationID,
+			Sources: []string{relPath},
+		})
+	}
+
+	if strings.HasSuffix(relPath, ".json") {
+		var spec struct {
+			Paths map[string]map[string]json.RawMessage `json:"paths"`
+		}
+		if err := json.Unmarshal(content, &spec); err != nil {
+			return nil
+		}
+		for route, item := range spec.Paths {
+			for method, raw := range item {
+				var operation struct {
+					OperationID string `json:"operationId"`
+				}
+				_ = json.Unmarshal(raw, &operation)
+				add(route, method, operation.OperationID)
+			}
+		}
+		sortSpecEndpoints(endpoints)
+		return endpoints
+	}
+
+	// YAML specs are read by indentation: path keys one level below the
+	// top-level paths key, methods below them and operationId below those.
+	inPaths := false
+	pathIndent, methodIndent := -1, -1
+	route, method, operationID := "", "", ""
+	flush := func() {
+		if method != "" {
+			add(route, method, operationID)
+		}
+		method, operationID = "", ""
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEndpointSourceBytes)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		key, value, _ := strings.Cut(trimmed, ":")
+		key = strings.Trim(key, `"'`)
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		if indent == 0 {
+			flush()
+			inPaths = key == "paths"
+			pathIndent, methodIndent = -1, -1
+			continue
+		}
+		if !inPaths {
+			continue
+		}
+		switch {
+		case pathIndent < 0 || indent == pathIndent:
+			flush()
+			pathIndent, methodIndent = indent, -1
+			route = key
+		case indent < pathIndent:
+			continue
+		case methodIndent < 0 || indent == methodIndent:
+			flush()
+			methodIndent = indent
+			if openAPIMethods[key] {
+				method = key
+			}
+		case key == "operationId" && method != "" && operationID == "":
+			operationID = value
+		}
+	}
+	flush()
+	sortSpecEndpoints(endpoints)
+	return endpoints
+}
+
+func sortSpecEndpoints(endpoints []HTTPEndpoint) {
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Path != endpoints[j].Path {
+			return endpoints[i].Path < endpoints[j].Path
+		}
+		return endpoints[i].Method < endpoints[j].Method
+	})
+}
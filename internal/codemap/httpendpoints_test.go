@@ -0,0 +1,172 @@
+package codemap
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectHTTPEndpoints(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.22\n",
+		"main.go": `package main
+
+import "net/http"
+
+func main() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {})
+	// mux.HandleFunc("GET /debug", debug)
+	mux.Handle("/static/", http.FileServer(http.Dir("static")))
+	http.ListenAndServe(":8080", mux)
+}
+`,
+		"api/routes.go": `package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func Routes(h *Handler) http.Handler {
+	r := chi.NewRouter()
+	r.Route("/users", func(r chi.Router) {
+		r.Get("/", h.ListUsers)
+		r.With(auth).Post("/", h.CreateUser)
+		r.Route("/{id}", func(r chi.Router) {
+			r.Get("/", h.GetUser)
+		})
+	})
+	r.Method(http.MethodDelete, "/sessions/{id}",
+		http.HandlerFunc(h.Logout))
+	return r
+}
+`,
+		"api/users.go":    "package api\n\ntype Handler struct{}\n\nfunc (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {}\n\nfunc (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {}\n\nfunc (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {}\n",
+		"api/sessions.go": "package api\n\nfunc (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {}\n",
+		"admin/admin.go": `package admin
+
+import "github.com/gin-gonic/gin"
+
+func Register(r *gin.Engine) {
+	v1 := r.Group("/admin")
+	v1.GET("/stats", authorize, stats)
+	v1.Handle("PUT", "/stats/:name", updateStat)
+}
+
+func stats(c *gin.Context)      {}
+func updateStat(c *gin.Context) {}
+`,
+		"api/routes_test.go": "package api\n\nimport \"net/http\"\n\nfunc setup() { http.HandleFunc(\"/test\", nil) }\n",
+		"api/openapi.yaml": `openapi: 3.0.0
+info:
+  title: Users
+paths:
+  /users:
+    get:
+      operationId: listUsers
+    post:
+      summary: Create
+  "/users/{id}":
+    parameters:
+      - name: id
+    get:
+      operationId: getUser
+  /reports:
+    get:
+      operationId: buildReport
+components:
+  schemas: {}
+`,
+		"reports/reports.go": "package reports\n\n// BuildReport renders a report.\nfunc BuildReport() {}\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	want := []HTTPEndpoint{
+		{Method: "GET", Path: "/admin/stats", Handler: "stats", HandlerFile: "admin/admin.go", Sources: []string{"admin/admin.go:7"}},
+		{Method: "PUT", Path: "/admin/stats/:name", Handler: "updateStat", HandlerFile: "admin/admin.go", Sources: []string{"admin/admin.go:8"}},
+		{Method: "GET", Path: "/healthz", Handler: "func literal", HandlerFile: "main.go", Sources: []string{"main.go:7"}},
+		{Method: "GET", Path: "/reports", Handler: "buildReport", HandlerFile: "reports/reports.go", Sources: []string{"api/openapi.yaml"}},
+		{Method: "DELETE", Path: "/sessions/{id}", Handler: "http.HandlerFunc(h.Logout)", HandlerFile: "api/sessions.go", Sources: []string{"api/routes.go:18"}},
+		{Method: "ANY", Path: "/static/", Handler: `http.FileServer(http.Dir("static"))`, Sources: []string{"main.go:9"}},
+		{Method: "GET", Path: "/users", Handler: "h.ListUsers", HandlerFile: "api/users.go", Sources: []string{"api/routes.go:12", "api/openapi.yaml"}},
+		{Method: "POST", Path: "/users", Handler: "h.CreateUser", HandlerFile: "api/users.go", Sources: []string{"api/routes.go:13", "api/openapi.yaml"}},
+		{Method: "GET", Path: "/users/{id}", Handler: "h.GetUser", HandlerFile: "api/users.go", Sources: []string{"api/routes.go:15", "api/openapi.yaml"}},
+	}
+	if !reflect.DeepEqual(cm.Endpoints, want) {
+		t.Fatalf("Endpoints =\n%+v\nwant\n%+v", cm.Endpoints, want)
+	}
+
+	content, err := Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if row := "| GET | /users/{id} | h.GetUser | api/users.go | api/routes.go:15, api/openapi.yaml |"; !strings.Contains(content, "## HTTP Endpoints") || !strings.Contains(content, row) {
+		t.Fatalf("expected HTTP Endpoints section with %q:\n%s", row, content)
+	}
+}
+
+func TestParseOpenAPIEndpointsJSON(t *testing.T) {
+	content := `{"swagger": "2.0", "paths": {"/pets/{petId}": {"get": {"operationId": "showPet"}, "parameters": []}, "/pets": {"post": {}}}}`
+	got := parseOpenAPIEndpoints("swagger.json", []byte(content))
+	want := []HTTPEndpoint{
+		{Method: "POST", Path: "/pets", Sources: []string{"swagger.json"}},
+		{Method: "GET", Path: "/pets/{petId}", Handler: "showPet", Sources: []string{"swagger.json"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseOpenAPIEndpoints =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestDetectHTTPEndpointsReusesUnchangedScans(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestTree(t, tmpDir, map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.22\n",
+		"main.go": "package main\n\nimport \"net/http\"\n\nfunc main() {\n\thttp.HandleFunc(\"GET /healthz\", health)\n}\n\nfunc health(w http.ResponseWriter, r *http.Request) {}\n",
+	})
+	idx, err := BuildFileIndex(context.Background(), tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	detect := func(prev *CodemapState, hash string) ([]string, *CodemapState) {
+		t.Helper()
+		next := &CodemapState{Entries: []StateEntry{{RelPath: "main.go", ContentHash: hash}}}
+		scans := newFileScans(AnalysisInput{Root: tmpDir, Index: idx, PrevState: prev, NextState: next})
+		endpoints, err := detectHTTPEndpoints(context.Background(), scans, idx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scans.store(next)
+		var routes []string
+		for _, endpoint := range endpoints {
+			routes = append(routes, endpoint.Method+" "+endpoint.Path+" "+endpoint.HandlerFile)
+		}
+		return routes, next
+	}
+
+	routes, first := detect(nil, "v1")
+	if want := []string{"GET /healthz main.go"}; !reflect.DeepEqual(routes, want) {
+		t.Fatalf("routes = %v, want %v", routes, want)
+	}
+	if scans := first.Analysis.Scans; len(scans) != 1 || scans[0].RelPath != "main.go" || scans[0].ContentHash != "v1" {
+		t.Fatalf("expected main.go's scan to be cached, got %+v", scans)
+	}
+
+	// An unchanged hash reuses the cached scan without reading the file.
+	first.Analysis.Scans[0].Endpoints[0].Path = "/cached"
+	if routes, _ := detect(first, "v1"); !reflect.DeepEqual(routes, []string{"GET /cached main.go"}) {
+		t.Fatalf("expected the cached scan to be reused, got %v", routes)
+	}
+	if routes, _ := detect(first, "v2"); !reflect.DeepEqual(routes, []string{"GET /healthz main.go"}) {
+		t.Fatalf("expected a changed file to be scanned again, got %v", routes)
+	}
+}
@@ -0,0 +1,279 @@
+package codemap
+
+import (
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// goTypeMembers returns the method signatures and embedded types declared by
+// an interface, or the embedded fields of a struct. Generic interfaces and
+// those with type constraint elements record neither, as no type is matched
+// against them.
+func goTypeMembers(spec *ast.TypeSpec) (methods, embeds []string) {
+	switch t := spec.Type.(type) {
+	case *ast.InterfaceType:
+		if spec.TypeParams != nil {
+			return nil, nil
+		}
+		for _, field := range t.Methods.List {
+			if fn, ok := field.Type.(*ast.FuncType); ok && len(field.Names) > 0 {
+				methods = append(methods, goMethodSignature(field.Names[0].Name, fn))
+				continue
+			}
+			embed := goEmbeddedName(field.Type)
+			if embed == "" {
+				return nil, nil
+			}
+			embeds = append(embeds, embed)
+		}
+	case *ast.StructType:
+		for _, field := range t.Fields.List {
+			if len(field.Names) > 0 {
+				continue
+			}
+			if embed := goEmbeddedName(field.Type); embed != "" {
+				embeds = append(embeds, embed)
+			}
+		}
+	}
+	return methods, embeds
+}
+
+// goEmbeddedName returns the name of an embedded type, e.g. "Base" for *Base
+// or "io.Reader", or "" for type constraint elements.
+func goEmbeddedName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return goEmbeddedName(e.X)
+	case *ast.IndexExpr:
+		return goEmbeddedName(e.X)
+	case *ast.IndexListExpr:
+		return goEmbeddedName(e.X)
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		if pkg, ok := e.X.(*ast.Ident); ok {
+			return pkg.Name + "." + e.Sel.Name
+		}
+	}
+	return ""
+}
+
+// goReceiverName returns the type name of a method receiver, without pointer
+// or type parameters.
+func goReceiverName(recv *ast.FieldList) string {
+	if recv == nil || len(recv.List) == 0 {
+		return ""
+	}
+	name := goEmbeddedName(recv.List[0].Type)
+	if strings.Contains(name, ".") {
+		return ""
+	}
+	return name
+}
+
+// goMethodSignature prints a method without parameter names, e.g.
+// "Save(context.Context, string) (int, error)".
+func goMethodSignature(name string, fn *ast.FuncType) string {
+	params := goFieldTypes(fn.Params)
+	results := goFieldTypes(fn.Results)
+	sig := name + "(" + strings.Join(params, ", ") + ")"
+	switch len(results) {
+	case 0:
+		return sig
+	case 1:
+		return sig + " " + results[0]
+	default:
+		return sig + " (" + strings.Join(results, ", ") + ")"
+	}
+}
+
+// goFieldTypes returns the type of each parameter in fields, repeated for
+// parameters sharing a type.
+func goFieldTypes(fields *ast.FieldList) []string {
+	if fields == nil {
+		return nil
+	}
+	var types []string
+	for _, field := range fields.List {
+		var sb strings.Builder
+		if err := printer.Fprint(&sb, token.NewFileSet(), field.Type); err != nil {
+			continue
+		}
+		for range max(len(field.Names), 1) {
+			types = append(types, sb.String())
+		}
+	}
+	return types
+}
+
+// attachReceiverMethods sets the Methods of each type declared as a method
+// receiver in the package.
+func attachReceiverMethods(types []TypeInfo, methods map[string][]string) {
+	for i := range types {
+		if types[i].Kind == "func" || types[i].Kind == "interface" {
+			continue
+		}
+		if list := methods[types[i].Name]; len(list) > 0 {
+			types[i].Methods = append(types[i].Methods, list...)
+			sort.Strings(types[i].Methods)
+		}
+	}
+}
+
+// packageQualifier matches the package selector of qualified type names.
+var packageQualifier = regexp.MustCompile(`\b[A-Za-z_][A-Za-z0-9_]*\.`)
+
+// assignImplementations records in Package.Implementations which Go types
+// satisfy which exported interfaces of the project. It is a structural
+// heuristic rather than type checking: methods match by name and signature
+// with package qualifiers dropped, pointer and value receivers both count,
+// and only embedded types declared in the same package are followed.
+// Interfaces without methods or embedding types of other packages are
+// skipped.
+func assignImplementations(packages []Package) {
+	type iface struct {
+		name    string
+		pkg     string
+		methods []string
+	}
+	var ifaces []iface
+	sets := make([]map[string]map[string]struct{}, len(packages))
+	for i, pkg := range packages {
+		if pkg.Language != languageGo || isNestedCodemap(pkg) {
+			continue
+		}
+		sets[i] = goMethodSets(pkg)
+		for _, typ := range pkg.ExportedTypes {
+			if typ.Kind != "interface" || typ.IsTestOnly {
+				continue
+			}
+			if set, ok := sets[i][typ.Name]; ok && len(set) > 0 {
+				ifaces = append(ifaces, iface{name: typ.Name, pkg: pkg.RelativePath, methods: sortedImportSet(set)})
+			}
+		}
+	}
+
+	for i := range packages {
+		pkg := &packages[i]
+		if sets[i] == nil {
+			continue
+		}
+		pkg.Implementations = nil
+		for _, typ := range append(append([]TypeInfo(nil), pkg.ExportedTypes...), pkg.PrivateSymbols...) {
+			if typ.Kind == "interface" || typ.Kind == "func" || typ.IsTestOnly {
+				continue
+			}
+			set := sets[i][typ.Name]
+			if len(set) == 0 {
+				continue
+			}
+			var satisfied []string
+			for _, candidate := range ifaces {
+				if !containsAll(set, candidate.methods) {
+					continue
+				}
+				if candidate.pkg == pkg.RelativePath {
+					satisfied = append(satisfied, candidate.name)
+				} else {
+					satisfied = append(satisfied, candidate.pkg+"."+candidate.name)
+				}
+			}
+			if len(satisfied) > 0 {
+				sort.Strings(satisfied)
+				pkg.Implementations = append(pkg.Implementations, Implementation{Type: typ.Name, Interfaces: satisfied})
+			}
+		}
+		sort.Slice(pkg.Implementations, func(a, b int) bool {
+			return pkg.Implementations[a].Type < pkg.Implementations[b].Type
+		})
+	}
+}
+
+// dropPrivateSymbols removes the unexported types the Go analyzer records
+// for method sets, and their implementations, from a model built without
+// Options.IncludeUnexported.
+func dropPrivateSymbols(packages []Package) {
+	for i := range packages {
+		pkg := &packages[i]
+		if len(pkg.PrivateSymbols) == 0 {
+			continue
+		}
+		private := make(map[string]struct{}, len(pkg.PrivateSymbols))
+		for _, typ := range pkg.PrivateSymbols {
+			private[typ.Name] = struct{}{}
+		}
+		pkg.PrivateSymbols = nil
+		pkg.Implementations = slices.DeleteFunc(pkg.Implementations, func(impl Implementation) bool {
+			_, ok := private[impl.Type]
+			return ok
+		})
+		if len(pkg.Implementations) == 0 {
+			pkg.Implementations = nil
+		}
+	}
+}
+
+// goMethodSets returns the normalized method set of each type of pkg,
+// including methods promoted from embedded types of the same package. The
+// set of an interface embedding an unresolvable type is nil.
+func goMethodSets(pkg Package) map[string]map[string]struct{} {
+	types := make(map[string]TypeInfo)
+	for _, list := range [][]TypeInfo{pkg.ExportedTypes, pkg.PrivateSymbols} {
+		for _, typ := range list {
+			if typ.Kind != "func" && !typ.IsTestOnly {
+				types[typ.Name] = typ
+			}
+		}
+	}
+	sets := make(map[string]map[string]struct{}, len(types))
+	var resolve func(name string, visiting map[string]bool) (map[string]struct{}, bool)
+	resolve = func(name string, visiting map[string]bool) (map[string]struct{}, bool) {
+		if set, ok := sets[name]; ok {
+			return set, set != nil
+		}
+		typ, ok := types[name]
+		if !ok || visiting[name] {
+			return nil, false
+		}
+		visiting[name] = true
+		defer delete(visiting, name)
+		set := make(map[string]struct{}, len(typ.Methods))
+		for _, method := range typ.Methods {
+			set[packageQualifier.ReplaceAllString(method, "")] = struct{}{}
+		}
+		for _, embed := range typ.Embeds {
+			promoted, ok := resolve(embed, visiting)
+			if !ok {
+				if typ.Kind == "interface" {
+					sets[name] = nil
+					return nil, false
+				}
+				continue
+			}
+			for method := range promoted {
+				set[method] = struct{}{}
+			}
+		}
+		sets[name] = set
+		return set, true
+	}
+	for name := range types {
+		resolve(name, make(map[string]bool))
+	}
+	return sets
+}
+
+func containsAll(set map[string]struct{}, methods []string) bool {
+	for _, method := range methods {
+		if _, ok := set[method]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAssignImplementations(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.22\n",
+		"store/store.go": `package store
+
+import (
+	"context"
+	"io"
+)
+
+// Item is stored.
+type Item struct{}
+
+type Reader interface {
+	Get(ctx context.Context, key string) (Item, error)
+}
+
+// Store reads and writes items.
+type Store interface {
+	Reader
+	Put(context.Context, string, Item) error
+}
+
+type Closer interface {
+	io.Closer
+}
+
+type Number interface {
+	~int | ~int64
+}
+`,
+		"memory/memory.go": `package memory
+
+import (
+	"context"
+
+	"example.com/app/store"
+)
+
+type base struct{}
+
+func (b *base) Get(ctx context.Context, key string) (store.Item, error) {
+	return store.Item{}, nil
+}
+
+// Memory keeps items in a map.
+type Memory struct {
+	*base
+}
+
+func (m Memory) Put(_ context.Context, key string, item store.Item) error { return nil }
+
+func (m Memory) Close() error { return nil }
+
+// ReadOnly only reads.
+type ReadOnly struct{ base }
+
+// Other has a Get of another signature.
+type Other struct{}
+
+func (Other) Get(key string) (store.Item, error) { return store.Item{}, nil }
+`,
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.IncludeUnexported = true
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	impls := make(map[string][]Implementation)
+	for _, pkg := range cm.Packages {
+		impls[pkg.RelativePath] = pkg.Implementations
+	}
+	want := []Implementation{
+		{Type: "Memory", Interfaces: []string{"store.Reader", "store.Store"}},
+		{Type: "ReadOnly", Interfaces: []string{"store.Reader"}},
+		{Type: "base", Interfaces: []string{"store.Reader"}},
+	}
+	if !reflect.DeepEqual(impls["memory"], want) {
+		t.Fatalf("memory implementations =\n%+v\nwant\n%+v", impls["memory"], want)
+	}
+	if impls["store"] != nil {
+		t.Fatalf("expected no implementations in store, got %+v", impls["store"])
+	}
+
+	// Without -unexported the unexported base still promotes Get, but is
+	// not listed itself.
+	opts.IncludeUnexported = false
+	cm, err = Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	for _, pkg := range cm.Packages {
+		if pkg.PrivateSymbols != nil {
+			t.Fatalf("expected no private symbols in %s, got %+v", pkg.RelativePath, pkg.PrivateSymbols)
+		}
+		impls[pkg.RelativePath] = pkg.Implementations
+	}
+	if !reflect.DeepEqual(impls["memory"], want[:2]) {
+		t.Fatalf("memory implementations without -unexported =\n%+v\nwant\n%+v", impls["memory"], want[:2])
+	}
+
+	splitDir := filepath.Join(tmpDir, "docs")
+	if err := writeSplitOutputs(tmpDir, Options{SplitOutputDir: splitDir}, &CodemapState{}, cm); err != nil {
+		t.Fatalf("writeSplitOutputs returned error: %v", err)
+	}
+	page, err := os.ReadFile(filepath.Join(splitDir, "memory.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "## Implementations\n\n| Type | Implements |\n|------|------------|\n| Memory | store.Reader, store.Store |\n") {
+		t.Fatalf("expected an Implementations section in:\n%s", page)
+	}
+}
@@ -33,6 +33,21 @@ type FileIndex struct {
 	RootEntries []string
 	Dirs        []DirRecord
 	Files       []FileRecord
+	GitIgnore   bool              // Built with git ignore rules applied
+	GitTracked  bool              // Built from git ls-files instead of a directory walk
+	IgnoreFiles []IgnoreFileState // Ignore, git config and git index files consulted
+	// Options.ExcludePatterns and IncludePatterns the index was built with
+	ExcludePatterns []string
+	IncludePatterns []string
+	// NestedCodemaps records Options.NestedCodemaps; NestedRoots lists the
+	// nested codemap directories whose contents were left out.
+	NestedCodemaps bool
+	NestedRoots    []string
+	// ExternalLanguages holds the indexing signatures of external analyzers.
+	ExternalLanguages []string
+	// Languages holds the canonical Options.Languages the index was built
+	// with; empty when every language was indexed.
+	Languages []string
 }
 
 // BuildFileIndex walks root once and captures all files needed by codemap.
@@ -42,14 +57,63 @@ func BuildFileIndex(ctx context.Context, root string) (*FileIndex, error) {
 
 // BuildFileIndexWithLanguages walks root once and captures files matching configured languages.
 func BuildFileIndexWithLanguages(ctx context.Context, root string, languageSpecs []LanguageSpec) (*FileIndex, error) {
+	return buildFileIndex(ctx, root, languageSpecs, Options{ProjectRoot: root})
+}
+
+// buildIndex builds the file index for root honoring index-related options.
+func buildIndex(ctx context.Context, root string, opts Options) (*FileIndex, error) {
+	return buildFileIndex(ctx, root, languageSpecsFor(opts), opts)
+}
+
+// buildFileIndex indexes root using the GitTracked and GitIgnore modes of opts.
+func buildFileIndex(ctx context.Context, root string, languageSpecs []LanguageSpec, opts Options) (*FileIndex, error) {
 	absRoot, err := filepath.Abs(root)
 	if err != nil {
 		return nil, fmt.Errorf("resolve root: %w", err)
 	}
 	rootPrefix := absRoot + string(os.PathSeparator)
 
-	idx := &FileIndex{Root: absRoot}
-	err = filepath.WalkDir(absRoot, func(path string, d fs.DirEntry, err error) error {
+	filter := newPathFilter(opts)
+	if opts.GitTracked {
+		idx, ok, err := buildTrackedFileIndex(ctx, absRoot, languageSpecs, filter, opts.NestedCodemaps)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			idx.GitIgnore = opts.GitIgnore
+			idx.ExcludePatterns, idx.IncludePatterns = opts.ExcludePatterns, opts.IncludePatterns
+			idx.NestedCodemaps = opts.NestedCodemaps
+			idx.ExternalLanguages = externalLanguageSignatures(opts)
+			idx.Languages = languageFilterIDs(opts)
+			return idx, nil
+		}
+		// git is unavailable or root is outside a work tree: walk instead.
+	}
+
+	idx := &FileIndex{
+		Root:              absRoot,
+		GitIgnore:         opts.GitIgnore,
+		GitTracked:        opts.GitTracked,
+		ExcludePatterns:   opts.ExcludePatterns,
+		IncludePatterns:   opts.IncludePatterns,
+		NestedCodemaps:    opts.NestedCodemaps,
+		ExternalLanguages: externalLanguageSignatures(opts),
+		Languages:         languageFilterIDs(opts),
+	}
+	var ignore *ignoreMatcher
+	if opts.GitIgnore {
+		ignore, err = newIgnoreMatcher(ctx, absRoot)
+		if err != nil {
+			return nil, fmt.Errorf("load git ignore rules: %w", err)
+		}
+	}
+	walk := filepath.WalkDir
+	if opts.ParallelWalk > 0 {
+		walk = func(root string, fn fs.WalkDirFunc) error {
+			return walkDirParallel(ctx, root, opts.ParallelWalk, isExcludedDir, fn)
+		}
+	}
+	err = walk(absRoot, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -89,11 +153,28 @@ func BuildFileIndexWithLanguages(ctx context.Context, root string, languageSpecs
 					}
 				}
 			}
+			if path != absRoot && (filter.skipDir(relPath) || filter.skipGenerated(path)) {
+				return filepath.SkipDir
+			}
+			if ignore != nil && path != absRoot {
+				if ignore.ignored(relPath, true) {
+					return filepath.SkipDir
+				}
+				if err := ignore.enterDir(path, relPath); err != nil {
+					return err
+				}
+			}
 
 			idx.Dirs = append(idx.Dirs, DirRecord{
 				RelPath:         relPath,
 				ModTimeUnixNano: info.ModTime().UnixNano(),
 			})
+			// The directory stays in Dirs so adding or removing its marker
+			// invalidates the fast staleness path.
+			if opts.NestedCodemaps && path != absRoot && hasNestedCodemapMarker(path, filter) {
+				idx.NestedRoots = append(idx.NestedRoots, relPath)
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -126,6 +207,12 @@ func BuildFileIndexWithLanguages(ctx context.Context, root string, languageSpecs
 			}
 		}
 
+		if ignore != nil && ignore.ignored(relPath, false) {
+			return nil
+		}
+		if filter.skipFile(relPath) || filter.skipGenerated(path) {
+			return nil
+		}
 		if shouldSkipIndexedFile(langMatch.ID, relPath, info.Size()) {
 			return nil
 		}
@@ -145,17 +232,48 @@ func BuildFileIndexWithLanguages(ctx context.Context, root string, languageSpecs
 		return nil, fmt.Errorf("walk directory: %w", err)
 	}
 	sort.Strings(idx.RootEntries)
+	if ignore != nil {
+		idx.IgnoreFiles = ignore.files
+	}
 
 	return idx, nil
 }
 
+// isExcludedDir reports directories that are never indexed: hidden ones,
+// vendored and test data, and the dist and build output of bundlers and
+// compilers.
 func isExcludedDir(name string) bool {
-	return strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata" || name == "workspace" || name == "node_modules"
+	switch name {
+	case "vendor", "testdata", "workspace", "node_modules", "dist", "build":
+		return true
+	}
+	return strings.HasPrefix(name, ".")
 }
 
 func shouldSkipIndexedFile(languageID, relPath string, size int64) bool {
-	if languageID != languagePython || size != 0 {
+	switch languageID {
+	case languagePython:
+		return size == 0 && filepath.Base(relPath) == "__init__.py"
+	case languageConfig, languageCSS, languageHTML:
+		return isSkippedAssetFile(relPath)
+	case languageJavaScript:
+		return isMinifiedJavaScriptPath(relPath) || isJavaScriptToolConfigPath(relPath)
+	default:
 		return false
 	}
-	return filepath.Base(relPath) == "__init__.py"
+}
+
+// isMinifiedJavaScriptPath reports bundler output such as app.min.js, which
+// has no useful structure to map.
+func isMinifiedJavaScriptPath(relPath string) bool {
+	base := strings.ToLower(filepath.Base(relPath))
+	return strings.Contains(base, ".min.") || strings.Contains(base, ".bundle.")
+}
+
+// isJavaScriptToolConfigPath reports tool configs such as webpack.config.js
+// or jest.config.cjs, which would otherwise turn the directory holding them
+// into a JavaScript package.
+func isJavaScriptToolConfigPath(relPath string) bool {
+	base := strings.ToLower(filepath.Base(relPath))
+	return strings.HasSuffix(strings.TrimSuffix(base, filepath.Ext(base)), ".config")
 }
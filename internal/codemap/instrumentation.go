@@ -0,0 +1,72 @@
+package codemap
+
+import "context"
+
+// Instrumentation receives analysis lifecycle events so embedders can attach
+// tracing or metrics without codemap depending on a telemetry library.
+// Package and file events fire from worker goroutines, so implementations must
+// be safe for concurrent use.
+type Instrumentation interface {
+	// OnPackageStart is called before a package is analyzed. The returned
+	// context is passed to OnFileParsed and OnPackageEnd for that package,
+	// which lets implementations carry a span.
+	OnPackageStart(ctx context.Context, pkg PackageEvent) context.Context
+	OnPackageEnd(ctx context.Context, pkg PackageEvent, err error)
+	OnFileParsed(ctx context.Context, file FileEvent)
+	// OnCacheHit is called when a package is reused from the analysis cache
+	// instead of being analyzed.
+	OnCacheHit(ctx context.Context, pkg PackageEvent)
+}
+
+// PackageEvent identifies a package in instrumentation callbacks.
+type PackageEvent struct {
+	Language     string
+	RelativePath string
+}
+
+// FileEvent describes a source file parsed during package analysis.
+type FileEvent struct {
+	Language string
+	RelPath  string
+	Lines    int
+}
+
+type instrumentationScopeKey struct{}
+
+type instrumentationScope struct {
+	inst     Instrumentation
+	language string
+}
+
+// startPackageInstrumentation reports the start of a package and returns the
+// context for its analysis plus a function reporting its end.
+func startPackageInstrumentation(ctx context.Context, opts Options, language, relPath string) (context.Context, func(error)) {
+	inst := opts.Instrumentation
+	if inst == nil {
+		return ctx, func(error) {}
+	}
+	event := PackageEvent{Language: language, RelativePath: relPath}
+	pkgCtx := inst.OnPackageStart(ctx, event)
+	if pkgCtx == nil {
+		pkgCtx = ctx
+	}
+	pkgCtx = context.WithValue(pkgCtx, instrumentationScopeKey{}, instrumentationScope{inst: inst, language: language})
+	return pkgCtx, func(err error) {
+		inst.OnPackageEnd(pkgCtx, event, err)
+	}
+}
+
+func recordCacheHit(ctx context.Context, opts Options, language, relPath string) {
+	if opts.Instrumentation != nil {
+		opts.Instrumentation.OnCacheHit(ctx, PackageEvent{Language: language, RelativePath: relPath})
+	}
+}
+
+// recordFileParsed reports a parsed file against the package scope in ctx.
+func recordFileParsed(ctx context.Context, relPath string, lines int) {
+	scope, ok := ctx.Value(instrumentationScopeKey{}).(instrumentationScope)
+	if !ok {
+		return
+	}
+	scope.inst.OnFileParsed(ctx, FileEvent{Language: scope.language, RelPath: relPath, Lines: lines})
+}
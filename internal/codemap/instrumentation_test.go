@@ -0,0 +1,100 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+type recordingInstrumentation struct {
+	mu     sync.Mutex
+	starts []string
+	ends   []string
+	files  []string
+	hits   []string
+}
+
+type recordingSpanKey struct{}
+
+func (r *recordingInstrumentation) OnPackageStart(ctx context.Context, pkg PackageEvent) context.Context {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.starts = append(r.starts, pkg.Language+":"+pkg.RelativePath)
+	return context.WithValue(ctx, recordingSpanKey{}, pkg.RelativePath)
+}
+
+func (r *recordingInstrumentation) OnPackageEnd(ctx context.Context, pkg PackageEvent, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ctx.Value(recordingSpanKey{}) == pkg.RelativePath && err == nil {
+		r.ends = append(r.ends, pkg.Language+":"+pkg.RelativePath)
+	}
+}
+
+func (r *recordingInstrumentation) OnFileParsed(ctx context.Context, file FileEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ctx.Value(recordingSpanKey{}) != nil && file.Lines > 0 {
+		r.files = append(r.files, file.Language+":"+file.RelPath)
+	}
+}
+
+func (r *recordingInstrumentation) OnCacheHit(_ context.Context, pkg PackageEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hits = append(r.hits, pkg.Language+":"+pkg.RelativePath)
+}
+
+func TestInstrumentationReceivesPackageFileAndCacheEvents(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "util"), 0755); err != nil {
+		t.Fatalf("mkdir util: %v", err)
+	}
+	files := map[string]string{
+		"go.mod":       "module example.com/test\n\ngo 1.22\n",
+		"main.go":      "package main\n\nfunc main() {}\n",
+		"util/util.go": "package util\n\nfunc Helper() {}\n",
+		"util/run.sh":  "#!/bin/sh\necho ok\n",
+	}
+	for rel, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, filepath.FromSlash(rel)), []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+
+	inst := &recordingInstrumentation{}
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.Instrumentation = inst
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+
+	sort.Strings(inst.starts)
+	sort.Strings(inst.ends)
+	sort.Strings(inst.files)
+	wantPackages := []string{"go:.", "go:util", "shell:util"}
+	if !reflect.DeepEqual(inst.starts, wantPackages) || !reflect.DeepEqual(inst.ends, wantPackages) {
+		t.Fatalf("unexpected package events: starts=%v ends=%v", inst.starts, inst.ends)
+	}
+	wantFiles := []string{"go:main.go", "go:util/util.go", "shell:util/run.sh"}
+	if !reflect.DeepEqual(inst.files, wantFiles) {
+		t.Fatalf("unexpected file events: %v", inst.files)
+	}
+	if len(inst.hits) != 0 {
+		t.Fatalf("expected no cache hits on first run, got %v", inst.hits)
+	}
+
+	inst2 := &recordingInstrumentation{}
+	opts.Instrumentation = inst2
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("second Generate returned error: %v", err)
+	}
+	if len(inst2.hits) == 0 || len(inst2.starts) >= len(wantPackages) {
+		t.Fatalf("expected cache hits on second run, got hits=%v starts=%v", inst2.hits, inst2.starts)
+	}
+}
@@ -0,0 +1,114 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+var javaScriptRequirePattern = regexp.MustCompile(`\brequire\s*\(\s*['"]([^'"\n]+)['"]\s*\)`)
+
+// JavaScriptAnalyzer is the analyzer implementation for JavaScript projects.
+// It shares package discovery and symbol extraction with TypeScriptAnalyzer
+// and also recognizes CommonJS exports and relative require calls.
+type JavaScriptAnalyzer struct{}
+
+func (JavaScriptAnalyzer) LanguageID() string { return languageJavaScript }
+
+func (JavaScriptAnalyzer) Analyze(ctx context.Context, in AnalysisInput) (*Codemap, error) {
+	if in.Index == nil {
+		return nil, fmt.Errorf("missing file index")
+	}
+	return analyzeScriptLanguageWithIndex(ctx, languageJavaScript, in.Root, in.Index, in.Options, in.PrevState, in.NextState)
+}
+
+// scanJavaScriptRelativeRequires returns the relative specifiers passed to
+// require calls.
+func scanJavaScriptRelativeRequires(content []byte) []string {
+	var specs []string
+	for _, match := range javaScriptRequirePattern.FindAllSubmatch(content, -1) {
+		if spec := string(match[1]); strings.HasPrefix(spec, ".") {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// parseCommonJSExports extracts names exported through module.exports or
+// exports assignments in a top-level expression statement.
+func parseCommonJSExports(stmt *sitter.Node, content []byte) ([]TypeInfo, []string, []string) {
+	if stmt.NamedChildCount() == 0 {
+		return nil, nil, nil
+	}
+	assignment := stmt.NamedChild(0)
+	if assignment == nil || assignment.Kind() != "assignment_expression" {
+		return nil, nil, nil
+	}
+	left := assignment.ChildByFieldName("left")
+	right := assignment.ChildByFieldName("right")
+	if left == nil || right == nil || left.Kind() != "member_expression" {
+		return nil, nil, nil
+	}
+
+	target := strings.Join(strings.Fields(nodeText(left, content)), "")
+	if target == "module.exports" {
+		return commonJSValueExports(right, content)
+	}
+	name := ""
+	if strings.HasPrefix(target, "module.exports.") {
+		name = strings.TrimPrefix(target, "module.exports.")
+	} else if strings.HasPrefix(target, "exports.") {
+		name = strings.TrimPrefix(target, "exports.")
+	}
+	if name == "" || strings.ContainsAny(name, ".[") {
+		return nil, nil, nil
+	}
+	if right.Kind() == "class" {
+		return []TypeInfo{{Name: name, Kind: "class"}}, []string{name}, nil
+	}
+	return nil, nil, []string{name}
+}
+
+// commonJSValueExports names the exports of a module.exports assignment: the
+// keys of an object literal, an assigned identifier, or "default".
+func commonJSValueExports(value *sitter.Node, content []byte) ([]TypeInfo, []string, []string) {
+	switch value.Kind() {
+	case "object":
+		var keyFuncs []string
+		for i := uint(0); i < value.NamedChildCount(); i++ {
+			child := value.NamedChild(i)
+			if child == nil {
+				continue
+			}
+			var nameNode *sitter.Node
+			switch child.Kind() {
+			case "shorthand_property_identifier":
+				nameNode = child
+			case "pair":
+				nameNode = child.ChildByFieldName("key")
+			case "method_definition":
+				nameNode = child.ChildByFieldName("name")
+			}
+			if nameNode == nil {
+				continue
+			}
+			if name := unquoteStringLiteral(strings.TrimSpace(nodeText(nameNode, content))); name != "" {
+				keyFuncs = append(keyFuncs, name)
+			}
+		}
+		return nil, nil, keyFuncs
+	case "identifier":
+		return nil, nil, []string{strings.TrimSpace(nodeText(value, content))}
+	case "class":
+		name := typeScriptDeclarationName(value, content)
+		if name == "" {
+			name = "default"
+		}
+		return []TypeInfo{{Name: name, Kind: "class"}}, []string{name}, nil
+	default:
+		return nil, nil, []string{"default"}
+	}
+}
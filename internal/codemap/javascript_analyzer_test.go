@@ -0,0 +1,91 @@
+package codemap
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseCommonJSExports(t *testing.T) {
+	content := []byte(`const helpers = require('./helpers');
+const fs = require('fs');
+
+class Store {}
+
+function load() {}
+
+module.exports = { load, Store, save: function () {}, 'quoted': 1 };
+exports.version = '1.0';
+module.exports.Cache = class Cache {};
+`)
+
+	types, keyTypes, keyFuncs, _ := parseTypeScriptFileSymbols(content, "lib/store.js")
+	if !reflect.DeepEqual(keyFuncs, []string{"load", "Store", "save", "quoted", "version"}) {
+		t.Fatalf("unexpected key funcs: %v", keyFuncs)
+	}
+	if !reflect.DeepEqual(keyTypes, []string{"Cache"}) || len(types) != 1 || types[0].Kind != "class" {
+		t.Fatalf("unexpected key types: %v (%+v)", keyTypes, types)
+	}
+	if got := scanJavaScriptRelativeRequires(content); !reflect.DeepEqual(got, []string{"./helpers"}) {
+		t.Fatalf("unexpected relative requires: %v", got)
+	}
+}
+
+func TestJavaScriptAnalyzerBuildsPackages(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"web/package.json":         "{\"name\": \"@acme/web\"}\n",
+		"web/src/index.jsx":        "// Web client entry.\nimport { App } from './App';\nimport React from 'react';\nexport function render() { return <App />; }\n",
+		"web/src/App.jsx":          "export class App {}\n",
+		"web/src/App.test.jsx":     "test('renders', () => {});\n",
+		"web/dist/bundle.min.js":   "!function(){}();\n",
+		"web/dist/app.js":          "export function render() {}\n",
+		"web/build/app.js":         "export function render() {}\n",
+		"webpack.config.js":        "module.exports = { mode: 'production' };\n",
+		"jest.config.cjs":          "module.exports = {};\n",
+		"server/package.json":      "{\"name\": \"server\"}\n",
+		"server/index.cjs":         "const routes = require('./routes');\nconst express = require('express');\nmodule.exports = { start };\nfunction start() {}\n",
+		"server/routes.mjs":        "export default function routes() {}\n",
+		"tools/tsproject/index.ts": "export const x = 1;\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+
+	byPath := make(map[string]Package)
+	for _, pkg := range cm.Packages {
+		if pkg.Language == languageJavaScript {
+			byPath[pkg.RelativePath] = pkg
+		}
+	}
+	if len(byPath) != 2 {
+		t.Fatalf("expected 2 JavaScript packages, got %+v", byPath)
+	}
+
+	web := byPath["web"]
+	if web.ImportPath != "@acme/web" || web.FileCount != 2 || web.EntryPoint != "src/index.jsx" {
+		t.Fatalf("unexpected web package: %+v", web)
+	}
+	if web.Purpose != "Web client entry." {
+		t.Fatalf("unexpected web purpose: %q", web.Purpose)
+	}
+	if !reflect.DeepEqual(web.Imports, []string{"./src/App"}) || !reflect.DeepEqual(web.ExternalImports, []string{"react"}) {
+		t.Fatalf("unexpected web imports: %v / %v", web.Imports, web.ExternalImports)
+	}
+	if len(web.ExportedTypes) != 1 || web.ExportedTypes[0].Name != "App" {
+		t.Fatalf("unexpected web exported types: %+v", web.ExportedTypes)
+	}
+
+	server := byPath["server"]
+	if server.EntryPoint != "index.cjs" || server.Purpose != "JavaScript package server" {
+		t.Fatalf("unexpected server package: %+v", server)
+	}
+	if !reflect.DeepEqual(server.Imports, []string{"./routes"}) || !reflect.DeepEqual(server.ExternalImports, []string{"express"}) {
+		t.Fatalf("unexpected server imports: %v / %v", server.Imports, server.ExternalImports)
+	}
+}
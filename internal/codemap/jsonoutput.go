@@ -0,0 +1,108 @@
+package codemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// JSONRenderer renders the full model as CODEMAP.json for tools that would
+// otherwise scrape the markdown.
+type JSONRenderer struct {
+	// MinFiles is Options.JSONMinFiles: the files beyond the entry file
+	// listed for smaller packages. Key files the model keeps beyond it, for
+	// the pages of Options.SplitOutputDir, are left out.
+	MinFiles int
+}
+
+func (JSONRenderer) Name() string        { return "json" }
+func (JSONRenderer) DefaultPath() string { return "CODEMAP.json" }
+func (r JSONRenderer) Render(cm *Codemap) (string, error) {
+	data, err := json.MarshalIndent(newJSONCodemap(cm, r.MinFiles), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// jsonCodemap is the serialized form of Codemap. ProjectRoot is left out so
+// the output is identical on every checkout of the same tree.
+type jsonCodemap struct {
+	ContentHash string
+	GeneratedAt time.Time
+	IndexMode   string `json:",omitempty"`
+	HashedFiles int
+	Packages    []Package
+	Concerns    []Concern
+	Warnings    []string        `json:",omitempty"`
+	Repos       []RepoInfo      `json:",omitempty"`
+	Services    []ServiceInfo   `json:",omitempty"`
+	Binaries    []BinaryInfo    `json:",omitempty"`
+	Toolchains  []ToolchainInfo `json:",omitempty"`
+	Migrations  []MigrationSet  `json:",omitempty"`
+	Endpoints   []HTTPEndpoint  `json:",omitempty"`
+	Statistics  *Statistics     `json:",omitempty"`
+}
+
+// newJSONCodemap lists up to minFiles key files of smaller packages, besides
+// the entry file, as their Files, so every package in the JSON output carries
+// file details when Options.JSONMinFiles is set.
+func newJSONCodemap(cm *Codemap, minFiles int) jsonCodemap {
+	packages, copied := cm.Packages, false
+	for i, pkg := range cm.Packages {
+		if len(pkg.KeyFiles) == 0 || len(pkg.Files) > 0 {
+			continue
+		}
+		if !copied {
+			packages, copied = append([]Package(nil), cm.Packages...), true
+		}
+		packages[i].Files, packages[i].KeyFiles = keyFiles(pkg.KeyFiles, pkg.EntryPoint, minFiles), nil
+	}
+	return jsonCodemap{
+		ContentHash: cm.ContentHash,
+		GeneratedAt: cm.GeneratedAt,
+		IndexMode:   cm.IndexMode,
+		HashedFiles: cm.HashedFiles,
+		Packages:    packages,
+		Concerns:    cm.Concerns,
+		Warnings:    cm.Warnings,
+		Repos:       cm.Repos,
+		Services:    cm.Services,
+		Binaries:    cm.Binaries,
+		Toolchains:  cm.Toolchains,
+		Migrations:  cm.Migrations,
+		Endpoints:   cm.Endpoints,
+		Statistics:  cm.Statistics,
+	}
+}
+
+// readJSONOutputHash returns the ContentHash recorded in a JSON output, or ""
+// when the file is missing or unreadable as JSON.
+func readJSONOutputHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	var header struct{ ContentHash string }
+	if err := json.Unmarshal(data, &header); err != nil {
+		return "", nil
+	}
+	return header.ContentHash, nil
+}
+
+// jsonOutputStale reports whether an enabled JSON output is missing or was
+// written for a different content hash than the markdown output.
+func jsonOutputStale(root string, opts Options, existingHash string) (bool, error) {
+	if opts.JSONOutputPath == "" {
+		return false, nil
+	}
+	hash, err := readJSONOutputHash(outputAbsPath(root, opts.JSONOutputPath))
+	if err != nil {
+		return false, fmt.Errorf("read existing json hash: %w", err)
+	}
+	return hash == "" || hash != existingHash, nil
+}
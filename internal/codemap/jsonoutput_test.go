@@ -0,0 +1,197 @@
+package codemap
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONOutputIncludesConcernLanguages(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"main.go":            "package main\n",
+		"errors.go":          "package main\n",
+		"web/error_page.ts":  "export const x = 1;\n",
+		"web/error_codes.ts": "export const y = 2;\n",
+		"web/app.ts":         "export const z = 3;\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.Concerns = []ConcernDef{{Name: "Error Handling", Patterns: []string{"**/error*.go", "**/*error*.ts"}}}
+	opts.JSONOutputPath = "CODEMAP.json"
+	cm, generated, err := EnsureUpToDate(context.Background(), opts)
+	if err != nil || !generated {
+		t.Fatalf("EnsureUpToDate returned %v (err %v)", generated, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.json"))
+	if err != nil {
+		t.Fatalf("read CODEMAP.json: %v", err)
+	}
+	if strings.Contains(string(data), tmpDir) {
+		t.Fatal("expected JSON output to omit the absolute project root")
+	}
+	var decoded struct {
+		ContentHash string
+		Concerns    []Concern
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("decode CODEMAP.json: %v", err)
+	}
+	if decoded.ContentHash != cm.ContentHash {
+		t.Fatalf("expected hash %s, got %s", cm.ContentHash, decoded.ContentHash)
+	}
+	if len(decoded.Concerns) != 1 {
+		t.Fatalf("unexpected concerns: %+v", decoded.Concerns)
+	}
+	if want := map[string]int{"go": 1, "typescript": 2}; !reflect.DeepEqual(decoded.Concerns[0].Languages, want) {
+		t.Fatalf("unexpected concern languages: %v", decoded.Concerns[0].Languages)
+	}
+
+	if stale, err := IsStale(context.Background(), opts); err != nil || stale {
+		t.Fatalf("expected fresh outputs, got %v (err %v)", stale, err)
+	}
+	if err := os.Remove(filepath.Join(tmpDir, "CODEMAP.json")); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+		t.Fatalf("expected missing JSON output to be stale, got %v (err %v)", stale, err)
+	}
+}
+
+func TestIncludeUnexportedOnlyAffectsJSONOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module example.com/test\n\ngo 1.22\n",
+		"main.go":      "package main\n\n// Server serves.\ntype Server struct{}\n\n// config holds settings.\ntype config struct{}\n\nfunc main() {}\n\n// loadConfig reads settings.\nfunc loadConfig() config { return config{} }\n\nfunc (s *Server) start() {}\n",
+		"app/tools.py": "class Tool:\n    pass\n\nclass _Cache:\n    pass\n\ndef _helper():\n    pass\n\ndef __getattr__(name):\n    pass\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.JSONOutputPath = "CODEMAP.json"
+	opts.IncludeUnexported = true
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+
+	private := make(map[string][]TypeInfo)
+	for _, pkg := range cm.Packages {
+		private[pkg.Language+":"+pkg.RelativePath] = pkg.PrivateSymbols
+		for _, typ := range pkg.ExportedTypes {
+			if typ.IsPrivate {
+				t.Fatalf("private symbol %s listed as exported", typ.Name)
+			}
+		}
+	}
+	wantGo := []TypeInfo{
+		{Name: "config", Kind: "struct", Comment: "config holds settings.", IsPrivate: true},
+		{Name: "main", Kind: "func", IsPrivate: true},
+		{Name: "loadConfig", Kind: "func", Comment: "loadConfig reads settings.", IsPrivate: true},
+	}
+	if !reflect.DeepEqual(private["go:."], wantGo) {
+		t.Fatalf("unexpected Go private symbols: %+v", private["go:."])
+	}
+	wantPython := []TypeInfo{
+		{Name: "_Cache", Kind: "class", IsPrivate: true},
+		{Name: "_helper", Kind: "function", IsPrivate: true},
+	}
+	if !reflect.DeepEqual(private["python:."], wantPython) {
+		t.Fatalf("unexpected Python private symbols: %+v", private["python:."])
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.json"))
+	if err != nil {
+		t.Fatalf("read CODEMAP.json: %v", err)
+	}
+	if !strings.Contains(string(data), `"loadConfig"`) {
+		t.Fatal("expected CODEMAP.json to list private symbols")
+	}
+	for _, name := range []string{"CODEMAP.md", "CODEMAP.paths"} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if strings.Contains(string(data), "loadConfig") || strings.Contains(string(data), "_Cache") {
+			t.Fatalf("expected %s to omit private symbols:\n%s", name, data)
+		}
+	}
+
+	opts.IncludeUnexported = false
+	cm, err = Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	for _, pkg := range cm.Packages {
+		if len(pkg.PrivateSymbols) > 0 {
+			t.Fatalf("expected no private symbols without IncludeUnexported, got %+v", pkg.PrivateSymbols)
+		}
+	}
+}
+
+func TestJSONMinFilesListsKeyFilesOfSmallPackages(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example.com/test\n\ngo 1.22\n",
+		"store/store.go": "// Package store persists records.\npackage store\n",
+		"store/big.go":   "package store\n\n// Big is large.\ntype Big struct {\n\tA int\n\tB int\n\tC int\n}\n",
+		"store/mid.go":   "package store\n\nfunc Mid() {\n}\n",
+		"store/tiny.go":  "package store\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.JSONOutputPath = "CODEMAP.json"
+	opts.JSONMinFiles = 2
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.json"))
+	if err != nil {
+		t.Fatalf("read CODEMAP.json: %v", err)
+	}
+	var decoded struct{ Packages []Package }
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("decode CODEMAP.json: %v", err)
+	}
+	var names []string
+	for _, pkg := range decoded.Packages {
+		if pkg.RelativePath != "store" {
+			continue
+		}
+		if len(pkg.KeyFiles) > 0 {
+			t.Fatalf("expected key files to be listed as Files, got %+v", pkg.KeyFiles)
+		}
+		for _, file := range pkg.Files {
+			names = append(names, file.Name)
+		}
+	}
+	if want := []string{"store.go", "big.go", "mid.go"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("unexpected JSON files %v, want %v", names, want)
+	}
+
+	markdown, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(markdown), "big.go") {
+		t.Fatalf("expected CODEMAP.md to stay summarized:\n%s", markdown)
+	}
+
+	// Only CODEMAP.json depends on JSONMinFiles, but changing it still
+	// leaves the outputs stale.
+	opts.JSONMinFiles = 1
+	if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+		t.Fatalf("IsStale after changing JSONMinFiles = %v, %v; want true", stale, err)
+	}
+}
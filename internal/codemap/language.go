@@ -8,10 +8,18 @@ import (
 )
 
 const (
+	languageConfig     = "config"
+	languageCpp        = "cpp"
+	languageCSS        = "css"
 	languageGo         = "go"
+	languageHTML       = "html"
+	languageJavaScript = "javascript"
+	languageProto      = "proto"
 	languagePython     = "python"
+	languageRuby       = "ruby"
 	languageRust       = "rust"
 	languageShell      = "shell"
+	languageSQL        = "sql"
 	languageTypeScript = "typescript"
 )
 
@@ -30,12 +38,12 @@ type languageMatch struct {
 var allBuiltinLanguageSpecList = buildAllBuiltinLanguageSpecs()
 
 func defaultLanguageSpecs() []LanguageSpec {
-	specs, err := resolveLanguageSpecs(DefaultAnalyzerRegistry().LanguageIDs())
+	specs, err := resolveLanguageSpecs(builtinAnalyzerRegistry().LanguageIDs())
 	if err != nil {
 		// Built-ins should always resolve.
 		panic(err)
 	}
-	return specs
+	return append(specs, registeredLanguageSpecs()...)
 }
 
 func resolveLanguageSpecs(ids []string) ([]LanguageSpec, error) {
@@ -64,6 +72,21 @@ func resolveLanguageSpecs(ids []string) ([]LanguageSpec, error) {
 	return specs, nil
 }
 
+// languageFilterIDs returns the canonical, sorted and deduplicated IDs of
+// Options.Languages, or nil when every language is indexed.
+func languageFilterIDs(opts Options) []string {
+	seen := make(map[string]struct{}, len(opts.Languages))
+	for _, raw := range opts.Languages {
+		if id := canonicalLanguageID(raw); id != "" {
+			seen[id] = struct{}{}
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+	return sortedImportSet(seen)
+}
+
 func canonicalLanguageID(id string) string {
 	normalized := strings.ToLower(strings.TrimSpace(id))
 	switch normalized {
@@ -73,6 +96,18 @@ func canonicalLanguageID(id string) string {
 		return languageShell
 	case "ts":
 		return languageTypeScript
+	case "js", "jsx":
+		return languageJavaScript
+	case "c", "c++", "cc", "cxx":
+		return languageCpp
+	case "rb":
+		return languageRuby
+	case "scss", "sass", "less", "styles":
+		return languageCSS
+	case "htm", "template", "templates":
+		return languageHTML
+	case "yaml", "yml", "json":
+		return languageConfig
 	default:
 		return normalized
 	}
@@ -137,7 +172,7 @@ func matchBuiltinLanguageForPath(path string) (languageMatch, bool) {
 			ID:     languageGo,
 			IsTest: strings.HasSuffix(name, "_test.go"),
 		}, true
-	case strings.HasSuffix(name, ".py"):
+	case strings.HasSuffix(name, ".py"), strings.HasSuffix(name, ".ipynb"):
 		return languageMatch{
 			ID:     languagePython,
 			IsTest: isPythonTestPathLike(name),
@@ -162,6 +197,34 @@ func matchBuiltinLanguageForPath(path string) (languageMatch, bool) {
 			ID:     languageTypeScript,
 			IsTest: hasAnySuffix(name, builtinLanguageSpecs[languageTypeScript].TestFileSuffixes),
 		}, true
+	case strings.HasSuffix(name, ".js"),
+		strings.HasSuffix(name, ".jsx"),
+		strings.HasSuffix(name, ".mjs"),
+		strings.HasSuffix(name, ".cjs"):
+		return languageMatch{
+			ID:     languageJavaScript,
+			IsTest: hasAnySuffix(name, builtinLanguageSpecs[languageJavaScript].TestFileSuffixes),
+		}, true
+	case hasAnySuffix(name, builtinLanguageSpecs[languageCpp].FileSuffixes):
+		return languageMatch{
+			ID:     languageCpp,
+			IsTest: hasAnySuffix(name, builtinLanguageSpecs[languageCpp].TestFileSuffixes),
+		}, true
+	case strings.HasSuffix(name, ".rb"):
+		return languageMatch{
+			ID:     languageRuby,
+			IsTest: hasAnySuffix(name, builtinLanguageSpecs[languageRuby].TestFileSuffixes),
+		}, true
+	case strings.HasSuffix(name, ".sql"):
+		return languageMatch{ID: languageSQL}, true
+	case strings.HasSuffix(name, ".proto"):
+		return languageMatch{ID: languageProto}, true
+	case hasAnySuffix(name, builtinLanguageSpecs[languageCSS].FileSuffixes):
+		return languageMatch{ID: languageCSS}, true
+	case hasAnySuffix(name, builtinLanguageSpecs[languageHTML].FileSuffixes):
+		return languageMatch{ID: languageHTML}, true
+	case hasAnySuffix(name, builtinLanguageSpecs[languageConfig].FileSuffixes):
+		return languageMatch{ID: languageConfig}, true
 	default:
 		return languageMatch{}, false
 	}
@@ -245,20 +308,104 @@ func dominantLanguage(idx *FileIndex, fallback string) string {
 }
 
 var builtinLanguageSpecs = map[string]LanguageSpec{
+	languageConfig: {
+		ID: languageConfig,
+		FileSuffixes: []string{
+			".yaml",
+			".yml",
+			".json",
+		},
+	},
+	languageCpp: {
+		ID: languageCpp,
+		FileSuffixes: []string{
+			".c",
+			".h",
+			".cc",
+			".cpp",
+			".cxx",
+			".hh",
+			".hpp",
+			".hxx",
+		},
+		TestFileSuffixes: []string{
+			"_test.c",
+			"_test.cc",
+			"_test.cpp",
+			"_unittest.cc",
+			"_unittest.cpp",
+		},
+	},
+	languageCSS: {
+		ID: languageCSS,
+		FileSuffixes: []string{
+			".css",
+			".scss",
+			".sass",
+			".less",
+		},
+	},
 	languageGo: {
 		ID:               languageGo,
 		FileSuffixes:     []string{".go"},
 		TestFileSuffixes: []string{"_test.go"},
 	},
+	languageHTML: {
+		ID: languageHTML,
+		FileSuffixes: []string{
+			".html",
+			".htm",
+			".gohtml",
+			".tmpl",
+			".hbs",
+			".handlebars",
+			".mustache",
+			".jinja",
+			".jinja2",
+			".njk",
+			".liquid",
+		},
+	},
+	languageJavaScript: {
+		ID: languageJavaScript,
+		FileSuffixes: []string{
+			".js",
+			".jsx",
+			".mjs",
+			".cjs",
+		},
+		TestFileSuffixes: []string{
+			".test.js",
+			".spec.js",
+			".test.jsx",
+			".spec.jsx",
+			".test.mjs",
+			".spec.mjs",
+			".test.cjs",
+			".spec.cjs",
+		},
+	},
+	languageProto: {
+		ID:           languageProto,
+		FileSuffixes: []string{".proto"},
+	},
 	languagePython: {
 		ID:           languagePython,
-		FileSuffixes: []string{".py"},
+		FileSuffixes: []string{".py", ".ipynb"},
 		TestFileSuffixes: []string{
 			"_test.py",
 			".test.py",
 			".spec.py",
 		},
 	},
+	languageRuby: {
+		ID:           languageRuby,
+		FileSuffixes: []string{".rb"},
+		TestFileSuffixes: []string{
+			"_spec.rb",
+			"_test.rb",
+		},
+	},
 	languageRust: {
 		ID:               languageRust,
 		FileSuffixes:     []string{".rs"},
@@ -281,6 +428,10 @@ var builtinLanguageSpecs = map[string]LanguageSpec{
 			".bats",
 		},
 	},
+	languageSQL: {
+		ID:           languageSQL,
+		FileSuffixes: []string{".sql"},
+	},
 	languageTypeScript: {
 		ID: languageTypeScript,
 		FileSuffixes: []string{
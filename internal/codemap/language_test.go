@@ -1,6 +1,10 @@
 package codemap
 
-import "testing"
+import (
+	"context"
+	"reflect"
+	"testing"
+)
 
 func TestResolveLanguageSpecsDefaultsToGo(t *testing.T) {
 	specs, err := resolveLanguageSpecs(nil)
@@ -103,3 +107,64 @@ func TestDominantLanguageTieBreaksDeterministically(t *testing.T) {
 		}
 	}
 }
+
+func TestLanguageFilterRestrictsIndexAndAnalysis(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":               "module example.com/app\n\ngo 1.22\n",
+		"main.go":              "package main\n\nfunc main() {}\n",
+		"web/package.json":     `{"name": "web", "bin": "cli.ts"}` + "\n",
+		"web/cli.ts":           "export function run(): void {}\n",
+		"tools/pyproject.toml": "[project]\nname = \"tools\"\n",
+		"tools/run.py":         "def run():\n    pass\n",
+	}
+	writeTestTree(t, tmpDir, files)
+	languagesOf := func(cm *Codemap) []string {
+		var languages []string
+		for _, pkg := range cm.Packages {
+			languages = append(languages, pkg.Language)
+		}
+		return languages
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if got := languagesOf(cm); !reflect.DeepEqual(got, []string{languageGo, languagePython, languageTypeScript}) {
+		t.Fatalf("unfiltered languages = %v", got)
+	}
+
+	// The state written above indexed every language, so it must not be
+	// reused for the filtered run.
+	opts.Languages = []string{"ts", "Go"}
+	cm, err = Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if got := languagesOf(cm); !reflect.DeepEqual(got, []string{languageGo, languageTypeScript}) {
+		t.Fatalf("filtered languages = %v", got)
+	}
+	if len(cm.Binaries) != 2 || cm.Binaries[1].Language != languageTypeScript {
+		t.Fatalf("unexpected binaries: %+v", cm.Binaries)
+	}
+	stale, err := IsStale(context.Background(), opts)
+	if err != nil || stale {
+		t.Fatalf("IsStale = %v, %v; want fresh outputs for the filtered run", stale, err)
+	}
+	opts.Languages = nil
+	if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+		t.Fatalf("IsStale = %v, %v; want stale outputs without the filter", stale, err)
+	}
+
+	opts.Languages = []string{"python"}
+	var ids []string
+	for _, lang := range Languages(opts) {
+		ids = append(ids, lang.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{languagePython}) {
+		t.Fatalf("Languages = %v, want only python", ids)
+	}
+}
@@ -0,0 +1,104 @@
+package codemap
+
+import "slices"
+
+// Sources of a language's analyzer in LanguageSupport.
+const (
+	LanguageSourceBuiltin    = "builtin"
+	LanguageSourceRegistered = "registered"
+	LanguageSourceExternal   = "external"
+	LanguageSourceCustom     = "custom"
+)
+
+// LanguageSupport describes how a run with given options handles one
+// language, as printed by "codemap languages".
+type LanguageSupport struct {
+	ID              string   `json:"id"`
+	Source          string   `json:"source"`
+	FileSuffixes    []string `json:"file_suffixes"`
+	TestConventions []string `json:"test_conventions,omitempty"`
+	PackageMarkers  []string `json:"package_markers,omitempty"` // Files marking a package root; none means one package per directory
+	Parser          string   `json:"parser,omitempty"`
+	TreeSitter      bool     `json:"tree_sitter"`
+}
+
+// builtinLanguageTraits records what the built-in analyzers do beyond their
+// LanguageSpec: test files recognized by name rather than suffix, the files
+// whose nearest directory becomes the package root, and how sources are
+// parsed.
+var builtinLanguageTraits = map[string]struct {
+	testPatterns   []string
+	packageMarkers []string
+	parser         string
+}{
+	languageConfig:     {parser: "none"},
+	languageCpp:        {packageMarkers: cppBuildManifests, parser: "regexp"},
+	languageCSS:        {parser: "none"},
+	languageGo:         {parser: "go/parser"},
+	languageHTML:       {parser: "none"},
+	languageJavaScript: {packageMarkers: []string{"package.json"}, parser: "tree-sitter"},
+	languageProto:      {parser: "regexp"},
+	languagePython:     {testPatterns: []string{"test_*.py"}, packageMarkers: []string{"pyproject.toml", "setup.cfg", "setup.py"}, parser: "regexp"},
+	languageRuby:       {packageMarkers: rubyPackageManifests, parser: "regexp"},
+	languageRust:       {packageMarkers: []string{"Cargo.toml"}, parser: "tree-sitter"},
+	languageShell:      {testPatterns: []string{"test_*.sh", "test_*.bash"}, parser: "line scanner"},
+	languageSQL:        {parser: "regexp"},
+	languageTypeScript: {packageMarkers: []string{"package.json"}, parser: "tree-sitter"},
+}
+
+// Languages lists the languages a run with opts analyzes, sorted by ID:
+// built-in analyzers, those added with RegisterAnalyzer, custom languages and
+// external ones, restricted to Options.Languages when set. Traits of analyzers codemap does
+// not ship are left empty.
+func Languages(opts Options) []LanguageSupport {
+	registry := analyzerRegistryFor(opts)
+	specs := make(map[string]LanguageSpec)
+	for _, spec := range languageSpecsFor(opts) {
+		specs[spec.ID] = spec
+	}
+	registeredMu.RLock()
+	registered := make(map[string]struct{}, len(registeredAnalyzers))
+	for id := range registeredAnalyzers {
+		registered[id] = struct{}{}
+	}
+	registeredMu.RUnlock()
+
+	ids := registry.LanguageIDs()
+	filter := languageFilterIDs(opts)
+	languages := make([]LanguageSupport, 0, len(ids))
+	for _, id := range ids {
+		if filter != nil && !slices.Contains(filter, id) {
+			continue
+		}
+		analyzer, _ := registry.AnalyzerFor(id)
+		spec, ok := specs[id]
+		if !ok {
+			if provider, isProvider := analyzer.(LanguageSpecProvider); isProvider {
+				spec = provider.LanguageSpec()
+			}
+		}
+		lang := LanguageSupport{
+			ID:              id,
+			Source:          LanguageSourceBuiltin,
+			FileSuffixes:    spec.FileSuffixes,
+			TestConventions: append([]string(nil), spec.TestFileSuffixes...),
+		}
+		if _, external := analyzer.(ExternalAnalyzer); external {
+			lang.Source = LanguageSourceExternal
+		} else if _, custom := analyzer.(SuffixAnalyzer); custom {
+			lang.Source = LanguageSourceCustom
+			lang.Parser = "leading comment"
+		} else if _, ok := registered[id]; ok {
+			lang.Source = LanguageSourceRegistered
+		}
+		if lang.Source == LanguageSourceBuiltin {
+			traits := builtinLanguageTraits[id]
+			lang.TestConventions = append(lang.TestConventions, traits.testPatterns...)
+			lang.PackageMarkers = traits.packageMarkers
+			lang.Parser = traits.parser
+			lang.TreeSitter = traits.parser == "tree-sitter"
+		}
+		languages = append(languages, lang)
+	}
+	return languages
+}
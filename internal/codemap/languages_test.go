@@ -0,0 +1,45 @@
+package codemap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLanguagesListsBuiltinAndExternalAnalyzers(t *testing.T) {
+	opts := DefaultOptions()
+	opts.ExternalAnalyzers = []ExternalAnalyzerDef{{Language: "zig", Command: "./zig-analyzer"}}
+
+	byID := make(map[string]LanguageSupport)
+	for _, lang := range Languages(opts) {
+		byID[lang.ID] = lang
+	}
+	if got, want := byID["rust"], (LanguageSupport{
+		ID:              "rust",
+		Source:          LanguageSourceBuiltin,
+		FileSuffixes:    []string{".rs"},
+		TestConventions: []string{"_test.rs"},
+		PackageMarkers:  []string{"Cargo.toml"},
+		Parser:          "tree-sitter",
+		TreeSitter:      true,
+	}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("rust = %+v, want %+v", got, want)
+	}
+	if got := byID["python"].TestConventions; !reflect.DeepEqual(got, []string{"_test.py", ".test.py", ".spec.py", "test_*.py"}) {
+		t.Fatalf("unexpected python test conventions %v", got)
+	}
+	if got, want := byID["zig"], (LanguageSupport{
+		ID:           "zig",
+		Source:       LanguageSourceExternal,
+		FileSuffixes: []string{".zig"},
+	}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("zig = %+v, want %+v", got, want)
+	}
+	for id := range builtinLanguageSpecs {
+		if _, ok := byID[id]; !ok {
+			t.Fatalf("expected built-in language %s to be listed", id)
+		}
+		if byID[id].Parser == "" {
+			t.Fatalf("expected a parser for built-in language %s", id)
+		}
+	}
+}
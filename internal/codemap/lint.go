@@ -0,0 +1,201 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Lint rules reported by Lint.
+const (
+	LintMissingPurpose = "missing-purpose"
+	LintMissingEntry   = "missing-entry"
+	LintLargePackage   = "large-package"
+	LintParseError     = "parse-error"
+)
+
+// LintSeverity controls how a lint rule is reported.
+type LintSeverity string
+
+const (
+	LintSeverityError   LintSeverity = "error"
+	LintSeverityWarning LintSeverity = "warning"
+	LintSeverityOff     LintSeverity = "off"
+)
+
+// LintOptions configures Lint.
+type LintOptions struct {
+	Severities map[string]LintSeverity // Per-rule severity; missing rules use defaults
+	MaxFiles   int                     // large-package file threshold (0 = unlimited)
+	MaxLines   int                     // large-package line threshold (0 = unlimited)
+}
+
+// LintIssue is a single documentation-quality finding.
+type LintIssue struct {
+	Rule     string       `json:"rule"`
+	Severity LintSeverity `json:"severity"`
+	Package  string       `json:"package"`
+	Language string       `json:"language,omitempty"`
+	Message  string       `json:"message"`
+}
+
+// LintReport is the machine-readable result of Lint.
+type LintReport struct {
+	Packages int         `json:"packages"`
+	Errors   int         `json:"errors"`
+	Warnings int         `json:"warnings"`
+	Issues   []LintIssue `json:"issues"`
+}
+
+// DefaultLintOptions returns the default rule severities and thresholds.
+func DefaultLintOptions() LintOptions {
+	return LintOptions{
+		Severities: map[string]LintSeverity{
+			LintMissingPurpose: LintSeverityWarning,
+			LintMissingEntry:   LintSeverityError,
+			LintLargePackage:   LintSeverityWarning,
+			LintParseError:     LintSeverityError,
+		},
+		MaxFiles: 50,
+		MaxLines: 5000,
+	}
+}
+
+// ParseLintSeverity validates a severity name.
+func ParseLintSeverity(value string) (LintSeverity, error) {
+	switch severity := LintSeverity(strings.ToLower(strings.TrimSpace(value))); severity {
+	case LintSeverityError, LintSeverityWarning, LintSeverityOff:
+		return severity, nil
+	}
+	return "", fmt.Errorf("unknown lint severity %q (want error, warning, or off)", value)
+}
+
+func (o LintOptions) severity(rule string) LintSeverity {
+	if severity, ok := o.Severities[rule]; ok {
+		return severity
+	}
+	return DefaultLintOptions().Severities[rule]
+}
+
+// Lint analyzes the project and reports packages missing purposes or entry
+// points, packages over the size thresholds, and packages whose files failed
+// to parse.
+func Lint(ctx context.Context, opts Options, lintOpts LintOptions) (*LintReport, error) {
+	failures := &parseFailureCollector{next: opts.Instrumentation}
+	opts.Instrumentation = failures
+
+	cm, err := Analyze(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &LintReport{Packages: len(cm.Packages), Issues: []LintIssue{}}
+	add := func(rule string, pkg PackageEvent, message string) {
+		severity := lintOpts.severity(rule)
+		if severity == LintSeverityOff || severity == "" {
+			return
+		}
+		report.Issues = append(report.Issues, LintIssue{
+			Rule:     rule,
+			Severity: severity,
+			Package:  pkg.RelativePath,
+			Language: pkg.Language,
+			Message:  message,
+		})
+		if severity == LintSeverityError {
+			report.Errors++
+		} else {
+			report.Warnings++
+		}
+	}
+
+	for _, pkg := range cm.Packages {
+		event := PackageEvent{Language: pkg.Language, RelativePath: pkg.RelativePath}
+		if strings.TrimSpace(pkg.Purpose) == "" {
+			add(LintMissingPurpose, event, "package has no doc comment describing its purpose")
+		}
+		if pkg.EntryPoint == "" {
+			add(LintMissingEntry, event, "no entry file could be determined")
+		}
+		if lintOpts.MaxFiles > 0 && pkg.FileCount > lintOpts.MaxFiles {
+			add(LintLargePackage, event, fmt.Sprintf("%d files exceeds the %d file threshold", pkg.FileCount, lintOpts.MaxFiles))
+		}
+		if lintOpts.MaxLines > 0 && pkg.LineCount > lintOpts.MaxLines {
+			add(LintLargePackage, event, fmt.Sprintf("%d lines exceeds the %d line threshold", pkg.LineCount, lintOpts.MaxLines))
+		}
+	}
+	// Parser errors embed absolute file paths; report them relative to root.
+	rootPrefix := cm.ProjectRoot + string(filepath.Separator)
+	for _, failure := range failures.sorted() {
+		add(LintParseError, failure.pkg, strings.ReplaceAll(failure.err.Error(), rootPrefix, ""))
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		if report.Issues[i].Package != report.Issues[j].Package {
+			return report.Issues[i].Package < report.Issues[j].Package
+		}
+		return report.Issues[i].Rule < report.Issues[j].Rule
+	})
+	return report, nil
+}
+
+type parseFailure struct {
+	pkg PackageEvent
+	err error
+}
+
+// parseFailureCollector records packages whose analysis failed. Analyzers skip
+// such packages, so instrumentation is the only place the failure surfaces.
+type parseFailureCollector struct {
+	next     Instrumentation
+	mu       sync.Mutex
+	failures []parseFailure
+}
+
+func (c *parseFailureCollector) OnPackageStart(ctx context.Context, pkg PackageEvent) context.Context {
+	if c.next != nil {
+		if next := c.next.OnPackageStart(ctx, pkg); next != nil {
+			return next
+		}
+	}
+	return ctx
+}
+
+func (c *parseFailureCollector) OnPackageEnd(ctx context.Context, pkg PackageEvent, err error) {
+	if err != nil && ctx.Err() == nil {
+		c.mu.Lock()
+		c.failures = append(c.failures, parseFailure{pkg: pkg, err: err})
+		c.mu.Unlock()
+	}
+	if c.next != nil {
+		c.next.OnPackageEnd(ctx, pkg, err)
+	}
+}
+
+func (c *parseFailureCollector) OnFileParsed(ctx context.Context, file FileEvent) {
+	if c.next != nil {
+		c.next.OnFileParsed(ctx, file)
+	}
+}
+
+func (c *parseFailureCollector) OnCacheHit(ctx context.Context, pkg PackageEvent) {
+	if c.next != nil {
+		c.next.OnCacheHit(ctx, pkg)
+	}
+}
+
+func (c *parseFailureCollector) sorted() []parseFailure {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := append([]parseFailure(nil), c.failures...)
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].pkg.RelativePath != out[j].pkg.RelativePath {
+			return out[i].pkg.RelativePath < out[j].pkg.RelativePath
+		}
+		return out[i].pkg.Language < out[j].pkg.Language
+	})
+	return out
+}
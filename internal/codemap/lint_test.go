@@ -0,0 +1,59 @@
+package codemap
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLintReportsDocumentationGaps(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":           "module example.com/test\n\ngo 1.22\n",
+		"good/good.go":     "// Package good is documented.\npackage good\n",
+		"bare/bare.go":     "package bare\n",
+		"broken/broken.go": "package broken\n\nfunc {\n",
+		"big/big.go":       "// Package big has many files.\npackage big\n",
+		"big/more.go":      "package big\n",
+		"big/even_more.go": "package big\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	lintOpts := DefaultLintOptions()
+	lintOpts.MaxFiles = 2
+	report, err := Lint(context.Background(), opts, lintOpts)
+	if err != nil {
+		t.Fatalf("Lint returned error: %v", err)
+	}
+
+	got := make(map[string]LintSeverity)
+	for _, issue := range report.Issues {
+		got[issue.Package+" "+issue.Rule] = issue.Severity
+	}
+	want := map[string]LintSeverity{
+		"bare " + LintMissingPurpose: LintSeverityWarning,
+		"big " + LintLargePackage:    LintSeverityWarning,
+		"broken " + LintParseError:   LintSeverityError,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected issues: %+v", report.Issues)
+	}
+	for key, severity := range want {
+		if got[key] != severity {
+			t.Fatalf("expected %s with severity %s, got issues %+v", key, severity, report.Issues)
+		}
+	}
+	if report.Errors != 1 || report.Warnings != 2 {
+		t.Fatalf("unexpected counts: errors=%d warnings=%d", report.Errors, report.Warnings)
+	}
+
+	lintOpts.Severities = map[string]LintSeverity{LintParseError: LintSeverityOff, LintMissingPurpose: LintSeverityError}
+	report, err = Lint(context.Background(), opts, lintOpts)
+	if err != nil {
+		t.Fatalf("Lint returned error: %v", err)
+	}
+	if report.Errors != 1 || report.Warnings != 1 {
+		t.Fatalf("expected severity overrides to apply, got %+v", report.Issues)
+	}
+}
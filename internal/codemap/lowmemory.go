@@ -0,0 +1,24 @@
+package codemap
+
+// compactPackage reduces pkg to the staging record that CODEMAP.md and
+// CODEMAP.paths render, releasing symbol lists and per-file details as soon
+// as the package is analyzed. Used with Options.LowMemory; file names, roles
+// and line counts stay for the Large Package Files section, Funcs stay when
+// Options.APISurface renders them, and Symbols stay when
+// Options.SymbolsOutputPath does.
+func compactPackage(pkg *Package, opts Options) {
+	pkg.ExportedTypes = nil
+	pkg.PrivateSymbols = nil
+	if opts.SymbolsOutputPath == "" {
+		pkg.Symbols = nil
+	}
+	if !opts.APISurface {
+		pkg.Funcs = nil
+	}
+	pkg.KeyFiles = nil
+	for i := range pkg.Files {
+		pkg.Files[i].Purpose = ""
+		pkg.Files[i].KeyTypes = nil
+		pkg.Files[i].KeyFuncs = nil
+	}
+}
@@ -0,0 +1,161 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLowMemoryKeepsRenderedOutputs(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":            "module example.com/test\n\ngo 1.22\n",
+		"main.go":           "package main\n\nfunc main() {}\n",
+		"store/store.go":    "// Package store persists records.\npackage store\n\n// Store saves records.\ntype Store struct{}\n\n// Open opens a store.\nfunc Open() *Store { return nil }\n",
+		"store/handler.go":  "package store\n\n// Handler serves records.\ntype Handler struct{}\n",
+		"store/model.go":    "package store\n\n// Record is a stored row.\ntype Record struct{}\n",
+		"store/config.go":   "package store\n\n// Config holds settings.\ntype Config struct{}\n",
+		"store/helpers.go":  "package store\n\nfunc helper() {}\n",
+		"store/extra_a.go":  "package store\n\nfunc a() {}\n",
+		"store/extra_b.go":  "package store\n\nfunc b() {}\n",
+		"store/extra_c.go":  "package store\n\nfunc c() {}\n",
+		"store/extra_d.go":  "package store\n\nfunc d() {}\n",
+		"store/extra_e.go":  "package store\n\nfunc e() {}\n",
+		"store/storage.go":  "package store\n\nfunc f() {}\n",
+		"store/records.go":  "package store\n\nfunc g() {}\n",
+		"scripts/build.sh":  "#!/bin/sh\n# Builds the project.\necho build\n",
+		"web/app.ts":        "/** Web app shell. */\nexport class App {}\n",
+		"web/app_routes.ts": "export function routes() {}\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	render := func(lowMemory bool) (*Codemap, map[string]string) {
+		t.Helper()
+		opts := DefaultOptions()
+		opts.ProjectRoot = tmpDir
+		opts.LowMemory = lowMemory
+		cm, err := Generate(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Generate(lowMemory=%v) returned error: %v", lowMemory, err)
+		}
+		outputs := make(map[string]string)
+		for _, name := range []string{"CODEMAP.md", "CODEMAP.paths"} {
+			data, err := os.ReadFile(filepath.Join(tmpDir, name))
+			if err != nil {
+				t.Fatalf("read %s: %v", name, err)
+			}
+			outputs[name] = string(data)
+		}
+		return cm, outputs
+	}
+
+	full, wantOutputs := render(false)
+	compact, gotOutputs := render(true)
+	if !reflect.DeepEqual(gotOutputs, wantOutputs) {
+		t.Fatalf("low-memory outputs differ:\n%s\nwant:\n%s", gotOutputs["CODEMAP.md"], wantOutputs["CODEMAP.md"])
+	}
+
+	var detailed bool
+	for _, pkg := range compact.Packages {
+		if len(pkg.ExportedTypes) > 0 {
+			t.Fatalf("expected %s to drop exported types, got %+v", pkg.ID, pkg.ExportedTypes)
+		}
+		for _, file := range pkg.Files {
+			detailed = true
+			if len(file.KeyTypes) > 0 || len(file.KeyFuncs) > 0 || file.Purpose != "" {
+				t.Fatalf("expected %s to drop file details, got %+v", pkg.ID, file)
+			}
+		}
+	}
+	if !detailed {
+		t.Fatal("expected the large package to keep its file listing")
+	}
+	if len(full.Packages) != len(compact.Packages) {
+		t.Fatalf("package counts differ: %d vs %d", len(full.Packages), len(compact.Packages))
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	inst := &recordingInstrumentation{}
+	opts.Instrumentation = inst
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if len(inst.hits) != 0 {
+		t.Fatalf("expected a full run to skip the compacted analysis cache, got hits %v", inst.hits)
+	}
+	var symbols int
+	for _, pkg := range cm.Packages {
+		symbols += len(pkg.ExportedTypes)
+	}
+	if symbols == 0 {
+		t.Fatal("expected a full run to restore exported types")
+	}
+}
+
+func TestLowMemoryKeepsAPISurface(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example.com/test\n\ngo 1.22\n",
+		"store/store.go": "// Package store persists records.\npackage store\n\n// Open opens a store.\nfunc Open(path string) error { return nil }\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	render := func(lowMemory bool) string {
+		t.Helper()
+		opts := DefaultOptions()
+		opts.ProjectRoot = tmpDir
+		opts.APISurface = true
+		opts.LowMemory = lowMemory
+		if _, err := Generate(context.Background(), opts); err != nil {
+			t.Fatalf("Generate(lowMemory=%v) returned error: %v", lowMemory, err)
+		}
+		data, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	want := render(false)
+	if !strings.Contains(want, "func Open(path string) error") {
+		t.Fatalf("expected an API surface entry in:\n%s", want)
+	}
+	if got := render(true); got != want {
+		t.Fatalf("low-memory API surface differs:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLowMemoryKeepsSymbolsIndex(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example.com/test\n\ngo 1.22\n",
+		"store/store.go": "// Package store persists records.\npackage store\n\n// Store saves records.\ntype Store struct{}\n\n// Open opens a store.\nfunc Open() *Store { return nil }\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	render := func(lowMemory bool) string {
+		t.Helper()
+		opts := DefaultOptions()
+		opts.ProjectRoot = tmpDir
+		opts.SymbolsOutputPath = "CODEMAP.symbols"
+		opts.LowMemory = lowMemory
+		if _, err := Generate(context.Background(), opts); err != nil {
+			t.Fatalf("Generate(lowMemory=%v) returned error: %v", lowMemory, err)
+		}
+		return readTestOutput(t, tmpDir, "CODEMAP.symbols")
+	}
+
+	want := render(false)
+	if !strings.Contains(want, "Open\tfunc") || !strings.Contains(want, "Store\tstruct") {
+		t.Fatalf("expected symbol rows in:\n%s", want)
+	}
+	if got := render(true); got != want {
+		t.Fatalf("low-memory symbols index differs:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,368 @@
+package codemap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sync"
+)
+
+// mcpProtocolVersions lists the Model Context Protocol revisions the server
+// speaks, newest first. Tools work the same way in all of them.
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes used by the MCP server.
+const (
+	mcpParseError     = -32700
+	mcpInvalidRequest = -32600
+	mcpMethodNotFound = -32601
+	mcpInvalidParams  = -32602
+)
+
+// mcpMessage is a JSON-RPC request or notification; notifications have no ID.
+type mcpMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type mcpResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *mcpError       `json:"error,omitempty"`
+}
+
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type mcpToolResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError,omitempty"`
+}
+
+// mcpTools are the tools listed by tools/list.
+var mcpTools = []mcpTool{
+	{
+		Name:        "get_codemap",
+		Description: "Render the codemap of the project from the current tree, re-analyzing only packages changed since the last run. format is markdown (default), paths, json, symbols, graph-dot, graph-mermaid or agents.",
+		InputSchema: mcpSchema(map[string]any{"format": mcpStringProperty("Output format, markdown by default")}),
+	},
+	{
+		Name:        "get_package",
+		Description: "Return the detailed analysis of the package at a project-relative path as JSON: files, purposes, imports, types and implementations.",
+		InputSchema: mcpSchema(map[string]any{"path": mcpStringProperty("Package path relative to the project root, e.g. internal/codemap")}, "path"),
+	},
+	{
+		Name:        "find_symbol",
+		Description: "Find the files defining an exported type or function by name, as JSON matches with file, kind and package.",
+		InputSchema: mcpSchema(map[string]any{"name": mcpStringProperty("Symbol name, e.g. Render")}, "name"),
+	},
+	{
+		Name:        "check_stale",
+		Description: "Report whether the generated codemap outputs are stale, as JSON {\"stale\", \"withinGrace\"}.",
+		InputSchema: mcpSchema(map[string]any{}),
+	},
+}
+
+func mcpSchema(properties map[string]any, required ...string) map[string]any {
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func mcpStringProperty(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+// MCPServer serves codemap data to coding agents over the Model Context
+// Protocol's stdio transport: newline-delimited JSON-RPC messages. Its tools
+// answer from the analysis cache, refreshing it first whenever the tree's
+// signature changed, so agents never read a codemap older than the tree.
+type MCPServer struct {
+	opts Options
+
+	mu    sync.Mutex
+	sig   uint64   // treeSignature the model was warmed for
+	model *Codemap // Last Warm result, nil until the first tool call
+}
+
+// NewMCPServer constructs an MCP server for opts.
+func NewMCPServer(opts Options) *MCPServer {
+	return &MCPServer{opts: opts}
+}
+
+// Serve answers the messages read from r on w until r is exhausted or ctx is
+// done.
+func (s *MCPServer) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					readErr <- err
+				}
+				return
+			}
+		}
+	}()
+
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case line, ok := <-lines:
+			if !ok {
+				select {
+				case err := <-readErr:
+					return fmt.Errorf("read message: %w", err)
+				default:
+					return nil
+				}
+			}
+			resp := s.handleLine(ctx, line)
+			if resp == nil {
+				continue
+			}
+			if err := enc.Encode(resp); err != nil {
+				return fmt.Errorf("write response: %w", err)
+			}
+		}
+	}
+}
+
+// handleLine answers one message, or returns nil for notifications and
+// blank lines.
+func (s *MCPServer) handleLine(ctx context.Context, line []byte) *mcpResponse {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+	var msg mcpMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return &mcpResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &mcpError{Code: mcpParseError, Message: err.Error()}}
+	}
+	if len(msg.ID) == 0 {
+		return nil
+	}
+	resp := &mcpResponse{JSONRPC: "2.0", ID: msg.ID}
+	if msg.JSONRPC != "2.0" || msg.Method == "" {
+		resp.Error = &mcpError{Code: mcpInvalidRequest, Message: "invalid JSON-RPC 2.0 request"}
+		return resp
+	}
+	result, rpcErr := s.handle(ctx, msg.Method, msg.Params)
+	if rpcErr != nil {
+		resp.Error = rpcErr
+	} else {
+		resp.Result = result
+	}
+	return resp
+}
+
+// handle executes one MCP method and returns its result.
+func (s *MCPServer) handle(ctx context.Context, method string, params json.RawMessage) (any, *mcpError) {
+	switch method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(params, &p)
+		version := mcpProtocolVersions[0]
+		if slices.Contains(mcpProtocolVersions, p.ProtocolVersion) {
+			version = p.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "codemap", "version": mcpServerVersion()},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": mcpTools}, nil
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &mcpError{Code: mcpInvalidParams, Message: err.Error()}
+		}
+		var args struct {
+			Format string `json:"format"`
+			Path   string `json:"path"`
+			Name   string `json:"name"`
+		}
+		if len(p.Arguments) > 0 {
+			if err := json.Unmarshal(p.Arguments, &args); err != nil {
+				return nil, &mcpError{Code: mcpInvalidParams, Message: fmt.Sprintf("arguments: %v", err)}
+			}
+		}
+
+		var text string
+		var err error
+		switch p.Name {
+		case "get_codemap":
+			text, err = s.getCodemap(ctx, args.Format)
+		case "get_package":
+			text, err = s.getPackage(ctx, args.Path)
+		case "find_symbol":
+			text, err = s.findSymbol(ctx, args.Name)
+		case "check_stale":
+			text, err = s.checkStale(ctx)
+		default:
+			return nil, &mcpError{Code: mcpInvalidParams, Message: fmt.Sprintf("unknown tool %q", p.Name)}
+		}
+		if err != nil {
+			return mcpToolResult{Content: []mcpContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+		}
+		return mcpToolResult{Content: []mcpContent{{Type: "text", Text: text}}}, nil
+	}
+	return nil, &mcpError{Code: mcpMethodNotFound, Message: fmt.Sprintf("unknown method %q", method)}
+}
+
+// warm returns a model of the current tree, running Warm only when the tree
+// signature changed since the last call.
+func (s *MCPServer) warm(ctx context.Context) (*Codemap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	root, err := filepath.Abs(s.opts.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	sig, sigErr := treeSignature(ctx, root, s.opts)
+	if sigErr == nil && s.model != nil && sig == s.sig {
+		return s.model, nil
+	}
+	cm, err := Warm(ctx, s.opts)
+	if err != nil {
+		return nil, err
+	}
+	if sigErr == nil {
+		s.model, s.sig = cm, sig
+	}
+	return cm, nil
+}
+
+func (s *MCPServer) getCodemap(ctx context.Context, format string) (string, error) {
+	if format == "" {
+		format = "markdown"
+	}
+	renderer, err := RendererFor(format, s.opts)
+	if err != nil {
+		return "", err
+	}
+	cm, err := s.warm(ctx)
+	if err != nil {
+		return "", err
+	}
+	switch renderer.(type) {
+	case MarkdownRenderer, PathsRenderer:
+		// Trimmed to Options.MaxOutputTokens like the written outputs.
+		if cm, err = outputView(cm, s.opts); err != nil {
+			return "", err
+		}
+	}
+	return renderer.Render(cm)
+}
+
+// getPackage serves the package from the warm model, re-analyzing only the
+// files of small packages, which the model lists none of. Models compacted
+// by Options.LowMemory carry no symbols, so those fall back to PackageDetail.
+func (s *MCPServer) getPackage(ctx context.Context, relPath string) (string, error) {
+	if relPath == "" {
+		return "", errors.New("path is required")
+	}
+	if s.opts.LowMemory {
+		packages, err := PackageDetail(ctx, s.opts, relPath)
+		if err != nil {
+			return "", err
+		}
+		return mcpJSON(packages)
+	}
+	cm, err := s.warm(ctx)
+	if err != nil {
+		return "", err
+	}
+	packages, err := packageDetail(ctx, s.opts, cm, func() (*FileIndex, error) { return stateFileIndex(s.opts) }, relPath)
+	if err != nil {
+		return "", err
+	}
+	return mcpJSON(packages)
+}
+
+func (s *MCPServer) findSymbol(ctx context.Context, name string) (string, error) {
+	if name == "" {
+		return "", errors.New("name is required")
+	}
+	if _, err := s.warm(ctx); err != nil {
+		return "", err
+	}
+	matches, err := Query(s.opts, QuerySymbol, name)
+	if err != nil {
+		return "", err
+	}
+	if matches == nil {
+		matches = []QueryMatch{}
+	}
+	return mcpJSON(matches)
+}
+
+func (s *MCPServer) checkStale(ctx context.Context) (string, error) {
+	stale, withinGrace, err := IsStaleWithGrace(ctx, s.opts)
+	if err != nil {
+		return "", err
+	}
+	return mcpJSON(StaleHealth{Stale: stale, WithinGrace: withinGrace})
+}
+
+func mcpJSON(v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// mcpServerVersion reports the module version the binary was built from.
+func mcpServerVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
@@ -0,0 +1,160 @@
+package codemap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMCPServerAnswersToolCalls(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":          "module example.com/test\n\ngo 1.22\n",
+		"main.go":         "package main\n\nfunc main() {}\n",
+		"store/store.go":  "// Package store keeps items.\npackage store\n\n// Store keeps items.\ntype Store struct{}\n",
+		"store/memory.go": "package store\n\n// Open returns a store.\nfunc Open() *Store { return &Store{} }\n",
+	}
+	writeTestTree(t, tmpDir, files)
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+
+	requests := []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"0"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"check_stale","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"get_codemap"}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"find_symbol","arguments":{"name":"Open"}}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"get_package","arguments":{"path":"store"}}}`,
+		`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"get_package","arguments":{"path":"missing"}}}`,
+		`{"jsonrpc":"2.0","id":"x","method":"resources/list"}`,
+		`not json`,
+	}
+	var out bytes.Buffer
+	if err := NewMCPServer(opts).Serve(context.Background(), strings.NewReader(strings.Join(requests, "\n")+"\n"), &out); err != nil {
+		t.Fatalf("Serve returned error: %v", err)
+	}
+
+	type response struct {
+		ID     json.RawMessage `json:"id"`
+		Result struct {
+			ProtocolVersion string          `json:"protocolVersion"`
+			Tools           []mcpTool       `json:"tools"`
+			Content         []mcpContent    `json:"content"`
+			IsError         bool            `json:"isError"`
+			Capabilities    json.RawMessage `json:"capabilities"`
+		} `json:"result"`
+		Error *mcpError `json:"error"`
+	}
+	var responses []response
+	scanner := bufio.NewScanner(&out)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var resp response
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response %q: %v", scanner.Text(), err)
+		}
+		responses = append(responses, resp)
+	}
+	if len(responses) != 9 {
+		t.Fatalf("expected 9 responses (none for the notification), got %d:\n%s", len(responses), out.String())
+	}
+	text := func(i int) string {
+		t.Helper()
+		if len(responses[i].Result.Content) != 1 {
+			t.Fatalf("response %s has no text content", responses[i].ID)
+		}
+		return responses[i].Result.Content[0].Text
+	}
+
+	if responses[0].Result.ProtocolVersion != "2024-11-05" || len(responses[0].Result.Capabilities) == 0 {
+		t.Fatalf("unexpected initialize result: %+v", responses[0].Result)
+	}
+	var names []string
+	for _, tool := range responses[1].Result.Tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "get_codemap,get_package,find_symbol,check_stale" {
+		t.Fatalf("unexpected tools: %v", names)
+	}
+	if got := text(2); !strings.Contains(got, `"stale": true`) {
+		t.Fatalf("expected stale outputs before generating, got %s", got)
+	}
+	if got := text(3); !strings.Contains(got, "# Codemap") || !strings.Contains(got, "store") {
+		t.Fatalf("expected the rendered codemap, got:\n%s", got)
+	}
+	var matches []QueryMatch
+	if err := json.Unmarshal([]byte(text(4)), &matches); err != nil {
+		t.Fatalf("decode find_symbol result: %v", err)
+	}
+	if len(matches) != 1 || matches[0].File != "store/memory.go" || matches[0].Package != "store" {
+		t.Fatalf("unexpected find_symbol matches: %+v", matches)
+	}
+	var packages []Package
+	if err := json.Unmarshal([]byte(text(5)), &packages); err != nil {
+		t.Fatalf("decode get_package result: %v", err)
+	}
+	if len(packages) != 1 || packages[0].RelativePath != "store" || packages[0].Purpose != "Package store keeps items." {
+		t.Fatalf("unexpected get_package result: %+v", packages)
+	}
+	if !responses[6].Result.IsError || !strings.Contains(text(6), "unknown package") {
+		t.Fatalf("expected a tool error for a missing package, got %+v", responses[6])
+	}
+	if string(responses[7].ID) != `"x"` || responses[7].Error == nil || responses[7].Error.Code != mcpMethodNotFound {
+		t.Fatalf("expected method not found, got %+v", responses[7])
+	}
+	if responses[8].Error == nil || responses[8].Error.Code != mcpParseError {
+		t.Fatalf("expected a parse error, got %+v", responses[8])
+	}
+}
+
+func TestMCPServerRendersWithinTokenBudget(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":          "module example.com/test\n\ngo 1.22\n",
+		"main.go":         "// Command test runs the store.\npackage main\n\nfunc main() {}\n",
+		"store/store.go":  "// Package store keeps items for the rest of the program.\npackage store\n\n// Store keeps items.\ntype Store struct{}\n",
+		"store/memory.go": "package store\n\n// Open returns a store.\nfunc Open() *Store { return &Store{} }\n",
+	}
+	writeTestTree(t, tmpDir, files)
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.MaxOutputTokens = 40
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if len(cm.Warnings) == 0 {
+		t.Fatal("expected the outputs to exceed the token budget")
+	}
+	want, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewMCPServer(opts)
+	got, err := server.getCodemap(context.Background(), "markdown")
+	if err != nil {
+		t.Fatalf("getCodemap returned error: %v", err)
+	}
+	if got != string(want) {
+		t.Fatalf("get_codemap differs from CODEMAP.md:\n%s\nwant:\n%s", got, want)
+	}
+	detail, err := server.getPackage(context.Background(), "store")
+	if err != nil {
+		t.Fatalf("getPackage returned error: %v", err)
+	}
+	var packages []Package
+	if err := json.Unmarshal([]byte(detail), &packages); err != nil {
+		t.Fatalf("decode get_package result: %v", err)
+	}
+	if len(packages) != 1 || len(packages[0].Files) != 2 || len(packages[0].ExportedTypes) != 1 {
+		t.Fatalf("expected the store package with its files and types, got %+v", packages)
+	}
+}
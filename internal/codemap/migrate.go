@@ -0,0 +1,52 @@
+package codemap
+
+// stateMigrations upgrade state written by older releases, keyed by the
+// version each function upgrades from. Every function moves the state exactly
+// one version forward, so chains such as v2 -> v3 -> v4 compose.
+//
+// Add an entry whenever codemapStateVersion is bumped. State without a path to
+// the current version is discarded and rebuilt from scratch.
+var stateMigrations = map[int]func(*CodemapState){
+	3: migrateStateV3,
+}
+
+// migrateState upgrades state in place to codemapStateVersion and reports
+// whether it succeeded. Per-file content hashes survive, which is what keeps
+// the first run after an upgrade from re-reading every file. The aggregate
+// hash is cleared because its inputs may have changed between versions; the
+// next run recomputes it from the migrated entries.
+func migrateState(state *CodemapState) bool {
+	if state.Version > codemapStateVersion {
+		return false
+	}
+	for state.Version != codemapStateVersion {
+		migrate, ok := stateMigrations[state.Version]
+		if !ok {
+			return false
+		}
+		migrate(state)
+		state.Version++
+	}
+	state.AggregateHash = ""
+	return true
+}
+
+// migrateStateV3 fills the per-entry language and test flags introduced in v4.
+// Entries for files no built-in language claims are dropped; the walk never
+// indexes them either.
+func migrateStateV3(state *CodemapState) {
+	specs := defaultLanguageSpecs()
+	entries := state.Entries[:0]
+	for _, entry := range state.Entries {
+		if entry.Language == "" {
+			match, ok := matchLanguageForPath(entry.RelPath, specs)
+			if !ok || match.ID == "" {
+				continue
+			}
+			entry.Language = match.ID
+			entry.IsTest = match.IsTest
+		}
+		entries = append(entries, entry)
+	}
+	state.Entries = entries
+}
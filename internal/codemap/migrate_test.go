@@ -0,0 +1,109 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testdata/state-v3.json is state as releases before per-entry languages
+// wrote it.
+func TestReadStateMigratesV3(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "state-v3.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	statePath := filepath.Join(t.TempDir(), ".codemap.state.json")
+	if err := os.WriteFile(statePath, data, 0644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	state, err := readState(statePath)
+	if err != nil {
+		t.Fatalf("readState returned error: %v", err)
+	}
+	if state == nil {
+		t.Fatal("expected v3 state to be migrated, got nil")
+	}
+	if state.Version != codemapStateVersion {
+		t.Fatalf("expected version %d, got %d", codemapStateVersion, state.Version)
+	}
+	if state.AggregateHash != "" {
+		t.Fatalf("expected aggregate hash to be cleared, got %q", state.AggregateHash)
+	}
+	if len(state.RootEntries) != 7 || len(state.Dirs) != 5 {
+		t.Fatalf("expected root entries and dirs to be kept, got %v and %+v", state.RootEntries, state.Dirs)
+	}
+	var got []string
+	for _, entry := range state.Entries {
+		got = append(got, fmt.Sprintf("%s %s %v %s", entry.RelPath, entry.Language, entry.IsTest, entry.ContentHash))
+	}
+	want := []string{
+		"cmd/tool/tool.go go false 9a0364b9e99bb480dd25e1f0284c8555",
+		"main.go go false e4d909c290d0fb1ca068ffaddf22cbd0",
+		"main_test.go go true d8e8fca2dc0f896fd7cb4cb0031ba249",
+		"scripts/run.sh shell false 37b51d194a7513e45b56f6524f2d51f2",
+		"web/app.ts typescript false 73feffa4b7f6bb68e44cf984c85f6e88",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("migrated entries =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestReadStateDiscardsUnmigratableVersions(t *testing.T) {
+	for _, version := range []int{2, codemapStateVersion + 1} {
+		statePath := filepath.Join(t.TempDir(), ".codemap.state.json")
+		data := fmt.Sprintf(`{"version":%d,"aggregateHash":"x","entries":[]}`, version)
+		if err := os.WriteFile(statePath, []byte(data), 0644); err != nil {
+			t.Fatalf("write state: %v", err)
+		}
+		state, err := readState(statePath)
+		if err != nil {
+			t.Fatalf("readState returned error: %v", err)
+		}
+		if state != nil {
+			t.Fatalf("expected version %d state to be discarded, got %+v", version, state)
+		}
+	}
+}
+
+func TestMigratedStateKeepsContentHashes(t *testing.T) {
+	tmpDir := t.TempDir()
+	mainPath := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(mainPath, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("write main.go: %v", err)
+	}
+	info, err := os.Stat(mainPath)
+	if err != nil {
+		t.Fatalf("stat main.go: %v", err)
+	}
+
+	// A cached content hash that differs from the real one proves the
+	// migrated entry was reused instead of re-reading the file.
+	statePath := filepath.Join(tmpDir, ".codemap.state.json")
+	v3 := fmt.Sprintf(`{"version":3,"aggregateHash":"old","entries":[{"relPath":"main.go","size":%d,"modTimeUnixNano":%d,"contentHash":"cached"}]}`,
+		info.Size(), info.ModTime().UnixNano())
+	if err := os.WriteFile(statePath, []byte(v3), 0644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	state, err := readState(statePath)
+	if err != nil || state == nil {
+		t.Fatalf("readState returned %v (err %v)", state, err)
+	}
+
+	idx, err := BuildFileIndex(context.Background(), tmpDir)
+	if err != nil {
+		t.Fatalf("BuildFileIndex returned error: %v", err)
+	}
+	_, nextState, err := computeAggregateHash(context.Background(), idx, state)
+	if err != nil {
+		t.Fatalf("computeAggregateHash returned error: %v", err)
+	}
+	if len(nextState.Entries) != 1 || nextState.Entries[0].ContentHash != "cached" {
+		t.Fatalf("expected cached content hash to be reused, got %+v", nextState.Entries)
+	}
+}
@@ -0,0 +1,147 @@
+package codemap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// languageNestedCodemap is the Package.Language of rows summarizing a nested
+// codemap boundary. No analyzer is registered for it.
+const languageNestedCodemap = "codemap"
+
+// nestedCodemapMarkers are the files that make a subdirectory a nested
+// codemap boundary with Options.NestedCodemaps. The first one present is the
+// boundary's entry file, so its own CODEMAP.md is preferred.
+var nestedCodemapMarkers = []string{"CODEMAP.md", ".codemap.yaml"}
+
+// isNestedCodemapMarker reports whether the file at absPath marks its
+// directory as a nested codemap. Outputs this run writes itself, such as a
+// CODEMAP.md placed in a subdirectory with -output, never do.
+func isNestedCodemapMarker(absPath string, filter *pathFilter) bool {
+	name := filepath.Base(absPath)
+	for _, marker := range nestedCodemapMarkers {
+		if name == marker {
+			return !filter.skipGenerated(absPath)
+		}
+	}
+	return false
+}
+
+// hasNestedCodemapMarker reports whether absDir holds a nested codemap marker.
+func hasNestedCodemapMarker(absDir string, filter *pathFilter) bool {
+	for _, marker := range nestedCodemapMarkers {
+		markerPath := filepath.Join(absDir, marker)
+		if info, err := os.Stat(markerPath); err == nil && !info.IsDir() && isNestedCodemapMarker(markerPath, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// nestedCodemapPackages summarizes each nested codemap root of idx as one
+// package whose entry file is the nested project's codemap. The markers are
+// read through aux, so regenerating a nested CODEMAP.md makes the outputs
+// stale.
+func nestedCodemapPackages(aux *auxInputs, idx *FileIndex) []Package {
+	if idx == nil || len(idx.NestedRoots) == 0 {
+		return nil
+	}
+	packages := make([]Package, 0, len(idx.NestedRoots))
+	for _, relPath := range idx.NestedRoots {
+		pkg := Package{
+			Language:     languageNestedCodemap,
+			ImportPath:   path.Base(relPath),
+			RelativePath: relPath,
+			Purpose:      "Nested project with its own codemap",
+		}
+		var content []byte
+		for _, marker := range nestedCodemapMarkers {
+			data, err := aux.readFile(path.Join(relPath, marker))
+			if err == nil {
+				pkg.EntryPoint, content = marker, data
+				break
+			}
+		}
+		if pkg.EntryPoint == "CODEMAP.md" {
+			if count, ok := countCodemapPackages(bytes.NewReader(content)); ok {
+				noun := "packages"
+				if count == 1 {
+					noun = "package"
+				}
+				pkg.Purpose = fmt.Sprintf("Nested project with its own codemap (%d %s)", count, noun)
+			}
+		}
+		packages = append(packages, pkg)
+	}
+	return packages
+}
+
+// isNestedCodemap reports whether pkg summarizes a nested codemap boundary.
+func isNestedCodemap(pkg Package) bool {
+	return pkg.Language == languageNestedCodemap
+}
+
+// countCodemapPackages counts the rows of the Package Entry Points table in a
+// rendered CODEMAP.md. ok is false when the file has no such table.
+func countCodemapPackages(r io.Reader) (int, bool) {
+	count := 0
+	inSection, inTable := false, false
+	scanner := newHeaderScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "## "):
+			if inSection {
+				return count, true
+			}
+			inSection = line == "## Package Entry Points"
+		case !inSection:
+		case strings.HasPrefix(line, "|"):
+			// The first two table lines are the header and its separator.
+			if inTable {
+				count++
+			}
+			if strings.HasPrefix(line, "|--") {
+				inTable = true
+			}
+		case inTable:
+			return count, true
+		}
+	}
+	return count, inSection
+}
+
+// nestedRootOf returns the nested root in roots containing relPath, or "".
+func nestedRootOf(relPath string, roots map[string]struct{}) string {
+	for dir := path.Dir(relPath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if _, ok := roots[dir]; ok {
+			return dir
+		}
+	}
+	return ""
+}
+
+// trackedNestedRoots returns the outermost directories below the root whose
+// tracked files include a nested codemap marker.
+func trackedNestedRoots(absRoot string, relPaths []string, filter *pathFilter) map[string]struct{} {
+	candidates := make(map[string]struct{})
+	for _, relPath := range relPaths {
+		dir := path.Dir(relPath)
+		if dir == "." || !isNestedCodemapMarker(filepath.Join(absRoot, filepath.FromSlash(relPath)), filter) {
+			continue
+		}
+		candidates[dir] = struct{}{}
+	}
+	roots := make(map[string]struct{}, len(candidates))
+	for dir := range candidates {
+		if nestedRootOf(dir, candidates) == "" {
+			roots[dir] = struct{}{}
+		}
+	}
+	return roots
+}
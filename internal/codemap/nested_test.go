@@ -0,0 +1,103 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNestedCodemapsAreSummarizedAsOneRow(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                     "module example.com/host\n\ngo 1.22\n",
+		"main.go":                    "package main\n\nfunc main() {}\n",
+		"embedded/engine/go.mod":     "module example.com/engine\n\ngo 1.22\n",
+		"embedded/engine/engine.go":  "// Package engine runs jobs.\npackage engine\n",
+		"embedded/engine/sub/sub.go": "package sub\n",
+		"tools/.codemap.yaml":        "output: CODEMAP.md\n",
+		"tools/gen.py":               "print('gen')\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	nestedOpts := DefaultOptions()
+	nestedOpts.ProjectRoot = filepath.Join(tmpDir, "embedded", "engine")
+	if _, err := Generate(context.Background(), nestedOpts); err != nil {
+		t.Fatalf("Generate for nested project returned error: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if len(cm.Packages) != 4 {
+		t.Fatalf("expected nested projects to be analyzed without -nested, got %+v", cm.Packages)
+	}
+
+	opts.NestedCodemaps = true
+	cm, _, err = EnsureUpToDate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("EnsureUpToDate returned error: %v", err)
+	}
+	var rows []string
+	for _, pkg := range cm.Packages {
+		rows = append(rows, pkg.RelativePath+" "+entryPath(pkg)+" "+pkg.Purpose)
+	}
+	want := []string{
+		". main.go ",
+		"embedded/engine embedded/engine/CODEMAP.md Nested project with its own codemap (2 packages)",
+		"tools tools/.codemap.yaml Nested project with its own codemap",
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("unexpected packages:\n got %q\nwant %q", rows, want)
+	}
+	if len(cm.Warnings) != 0 || len(cm.Packages[1].Companions) != 0 {
+		t.Fatalf("expected nested rows to skip entry checks and companions, got %v / %v", cm.Warnings, cm.Packages[1].Companions)
+	}
+
+	markdown, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(markdown), "| <a id=\"pkg-embedded-engine\"></a>embedded/engine | embedded/engine/CODEMAP.md |") {
+		t.Fatalf("expected a row linking to the nested codemap:\n%s", markdown)
+	}
+
+	state, err := readState(resolveStatePath(tmpDir, opts))
+	if err != nil || state == nil {
+		t.Fatalf("read state: %v (err %v)", state, err)
+	}
+	if !state.NestedCodemaps || !reflect.DeepEqual(state.NestedRoots, []string{"embedded/engine", "tools"}) {
+		t.Fatalf("unexpected nested state: %v %v", state.NestedCodemaps, state.NestedRoots)
+	}
+	for _, entry := range state.Entries {
+		if strings.HasPrefix(entry.RelPath, "embedded/") || strings.HasPrefix(entry.RelPath, "tools/") {
+			t.Fatalf("expected nested files to be left out of the index, got %s", entry.RelPath)
+		}
+	}
+
+	// Regenerating the nested codemap changes its package count.
+	if err := os.MkdirAll(filepath.Join(tmpDir, "embedded", "engine", "queue"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "embedded", "engine", "queue", "queue.go"), []byte("package queue\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(context.Background(), nestedOpts); err != nil {
+		t.Fatalf("Generate for nested project returned error: %v", err)
+	}
+	if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+		t.Fatalf("IsStale after the nested codemap changed = %v, %v; want true", stale, err)
+	}
+	cm, generated, err := EnsureUpToDate(context.Background(), opts)
+	if err != nil || !generated {
+		t.Fatalf("EnsureUpToDate = %v, %v; want a regeneration", generated, err)
+	}
+	if purpose := cm.Packages[1].Purpose; purpose != "Nested project with its own codemap (3 packages)" {
+		t.Fatalf("unexpected nested purpose after regenerating: %q", purpose)
+	}
+}
@@ -0,0 +1,311 @@
+package codemap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// currentOutputPaths lists the state-relative names of the outputs opts writes.
+func currentOutputPaths(root string, opts Options) []string {
+	if opts.OutputPath == "" {
+		opts.OutputPath = MarkdownRenderer{}.DefaultPath()
+	}
+	if opts.PathsOutputPath == "" {
+		opts.PathsOutputPath = PathsRenderer{}.DefaultPath()
+	}
+	paths := []string{outputStateName(root, opts.OutputPath)}
+	if !opts.DisablePaths {
+		paths = append(paths, outputStateName(root, opts.PathsOutputPath))
+	}
+	if opts.HashesOutputPath != "" {
+		paths = append(paths, outputStateName(root, opts.HashesOutputPath))
+	}
+	if opts.DOTOutputPath != "" {
+		paths = append(paths, outputStateName(root, opts.DOTOutputPath))
+	}
+	if opts.MermaidOutputPath != "" {
+		paths = append(paths, outputStateName(root, opts.MermaidOutputPath))
+	}
+	if opts.SymbolsOutputPath != "" {
+		paths = append(paths, outputStateName(root, opts.SymbolsOutputPath))
+	}
+	if opts.ConcernsOutputPath != "" {
+		paths = append(paths, outputStateName(root, opts.ConcernsOutputPath))
+	}
+	if opts.JSONOutputPath != "" {
+		paths = append(paths, outputStateName(root, opts.JSONOutputPath))
+	}
+	if opts.AgentsOutputPath != "" {
+		paths = append(paths, outputStateName(root, opts.AgentsOutputPath))
+	}
+	for _, renderer := range registeredRendererList() {
+		paths = append(paths, outputStateName(root, renderer.DefaultPath()))
+	}
+	return paths
+}
+
+// generatedPaths returns the absolute paths of every file codemap writes or
+// manages for opts: outputs, the markdown template, agent instruction
+// targets, state and analysis caches, and the daemon socket. They are kept
+// out of the index wherever they live in the tree, so writing them never
+// changes the content hash.
+func generatedPaths(root string, opts Options) []string {
+	if opts.OutputPath == "" {
+		opts.OutputPath = MarkdownRenderer{}.DefaultPath()
+	}
+	if opts.PathsOutputPath == "" {
+		opts.PathsOutputPath = PathsRenderer{}.DefaultPath()
+	}
+	var paths []string
+	add := func(path string) {
+		if path == "" {
+			return
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		paths = append(paths, filepath.Clean(path))
+	}
+
+	add(opts.OutputPath)
+	add(opts.MarkdownTemplate)
+	if !opts.DisablePaths {
+		add(opts.PathsOutputPath)
+	}
+	add(opts.HashesOutputPath)
+	add(opts.DOTOutputPath)
+	add(opts.MermaidOutputPath)
+	add(opts.SymbolsOutputPath)
+	add(opts.ConcernsOutputPath)
+	add(opts.JSONOutputPath)
+	add(opts.AgentsOutputPath)
+	add(opts.SplitOutputDir)
+	for _, path := range opts.AgentsInjectPaths {
+		add(path)
+	}
+	for _, renderer := range registeredRendererList() {
+		add(renderer.DefaultPath())
+	}
+	for _, path := range opts.variantOutputs {
+		add(path)
+	}
+	add(resolveStatePath(root, opts))
+	add(resolveAnalysisStatePath(root, opts))
+	add(resolveSocketPath(root, opts))
+	return paths
+}
+
+// secondaryOutputsStale reports whether any optional output is missing or
+// disagrees with existingHash, the hash of the markdown output.
+func secondaryOutputsStale(root string, opts Options, existingHash string) (bool, error) {
+	if stale, err := hashesOutputStale(root, opts, existingHash); err != nil || stale {
+		return stale, err
+	}
+	if stale, err := graphOutputsStale(root, opts, existingHash); err != nil || stale {
+		return stale, err
+	}
+	if stale, err := symbolsOutputStale(root, opts, existingHash); err != nil || stale {
+		return stale, err
+	}
+	if stale, err := concernsOutputStale(root, opts, existingHash); err != nil || stale {
+		return stale, err
+	}
+	if stale, err := jsonOutputStale(root, opts, existingHash); err != nil || stale {
+		return stale, err
+	}
+	if stale, err := agentsOutputsStale(root, opts, existingHash); err != nil || stale {
+		return stale, err
+	}
+	if stale, err := splitOutputsStale(root, opts, existingHash); err != nil || stale {
+		return stale, err
+	}
+	return registeredOutputsMissing(root)
+}
+
+// registeredOutputsMissing reports whether a RegisterRenderer output is
+// missing. Their formats are unknown, so they are not checked for a hash.
+func registeredOutputsMissing(root string) (bool, error) {
+	for _, renderer := range registeredRendererList() {
+		if _, err := os.Stat(outputAbsPath(root, renderer.DefaultPath())); err != nil {
+			if os.IsNotExist(err) {
+				return true, nil
+			}
+			return false, fmt.Errorf("stat %s output: %w", renderer.Name(), err)
+		}
+	}
+	return false, nil
+}
+
+// writeSecondaryOutputs writes every optional output enabled in opts.
+func writeSecondaryOutputs(root string, opts Options, nextState *CodemapState, cm *Codemap) error {
+	if err := writeHashesOutput(root, opts, nextState, cm); err != nil {
+		return err
+	}
+	if err := writeGraphOutputs(root, opts, cm); err != nil {
+		return err
+	}
+	if opts.SymbolsOutputPath != "" {
+		if err := writeRenderedOutput(outputAbsPath(root, opts.SymbolsOutputPath), SymbolsRenderer{}, cm); err != nil {
+			return err
+		}
+	}
+	if opts.ConcernsOutputPath != "" {
+		if err := writeRenderedOutput(outputAbsPath(root, opts.ConcernsOutputPath), ConcernsRenderer{}, cm); err != nil {
+			return err
+		}
+	}
+	if opts.JSONOutputPath != "" {
+		if err := writeRenderedOutput(outputAbsPath(root, opts.JSONOutputPath), JSONRenderer{MinFiles: opts.JSONMinFiles}, cm); err != nil {
+			return err
+		}
+	}
+	if err := writeAgentsOutputs(root, opts, cm); err != nil {
+		return err
+	}
+	if err := writeSplitOutputs(root, opts, nextState, cm); err != nil {
+		return err
+	}
+	for _, renderer := range registeredRendererList() {
+		if err := writeRenderedOutput(outputAbsPath(root, renderer.DefaultPath()), renderer, cm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RendererFor returns the renderer for an output format, configured from
+// opts: markdown, paths, json, symbols, concerns, graph-dot, graph-mermaid,
+// agents, or the Name of a RegisterRenderer renderer. The hashes output is
+// not available this way because it is built from the state written with it,
+// and the concerns output lists files only when Options.ConcernsOutputPath
+// is set.
+func RendererFor(format string, opts Options) (Renderer, error) {
+	switch format {
+	case "markdown":
+		return newMarkdownRenderer(opts), nil
+	case "paths":
+		return PathsRenderer{Mini: opts.PathsMini, PurposeLength: opts.PathsPurposeLength}, nil
+	case "json":
+		return JSONRenderer{MinFiles: opts.JSONMinFiles}, nil
+	case "symbols":
+		return SymbolsRenderer{}, nil
+	case "concerns":
+		return ConcernsRenderer{}, nil
+	case "graph-dot":
+		return GraphDOTRenderer{}, nil
+	case "graph-mermaid":
+		return GraphMermaidRenderer{}, nil
+	case "agents":
+		return AgentsRenderer{}, nil
+	}
+	for _, renderer := range registeredRendererList() {
+		if renderer.Name() == format {
+			return renderer, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown output format %q (want markdown, paths, json, symbols, concerns, graph-dot, graph-mermaid or agents)", format)
+}
+
+// outputStateName records path relative to root when it lies inside root, so
+// state stays valid if the project directory moves.
+func outputStateName(root, path string) string {
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(root, abs)
+	}
+	if rel, err := filepath.Rel(root, abs); err == nil && filepath.IsLocal(rel) {
+		return filepath.ToSlash(rel)
+	}
+	return abs
+}
+
+func outputAbsPath(root, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(root, filepath.FromSlash(name))
+}
+
+// trackedOutputs returns the outputs written by this run plus outputs recorded
+// by earlier runs that still exist, so orphans stay visible until cleaned.
+func trackedOutputs(root string, opts Options, prev *CodemapState) []string {
+	seen := make(map[string]struct{})
+	for _, name := range currentOutputPaths(root, opts) {
+		seen[name] = struct{}{}
+	}
+	if prev != nil {
+		for _, name := range prev.Outputs {
+			if _, err := os.Stat(outputAbsPath(root, name)); err == nil {
+				seen[name] = struct{}{}
+			}
+		}
+	}
+	return sortedImportSet(seen)
+}
+
+// OrphanedOutputs lists output files written by earlier runs under output
+// paths opts no longer uses (for example after changing -output or adding
+// -no-paths) that still exist on disk.
+func OrphanedOutputs(opts Options) ([]string, error) {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	state, err := readState(resolveStatePath(root, opts))
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	if state == nil {
+		return nil, nil
+	}
+
+	current := make(map[string]struct{})
+	for _, name := range currentOutputPaths(root, opts) {
+		current[name] = struct{}{}
+	}
+	var orphans []string
+	for _, name := range state.Outputs {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		if _, err := os.Stat(outputAbsPath(root, name)); err == nil {
+			orphans = append(orphans, name)
+		}
+	}
+	sort.Strings(orphans)
+	return orphans, nil
+}
+
+// CleanOutputs removes orphaned outputs and forgets them in state. It returns
+// the removed paths.
+func CleanOutputs(opts Options) ([]string, error) {
+	orphans, err := OrphanedOutputs(opts)
+	if err != nil || len(orphans) == 0 {
+		return nil, err
+	}
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	for _, name := range orphans {
+		if err := os.Remove(outputAbsPath(root, name)); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("remove %s: %w", name, err)
+		}
+	}
+
+	statePath := resolveStatePath(root, opts)
+	state, err := readState(statePath)
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	if state != nil {
+		next := cloneCodemapState(state)
+		next.Outputs = trackedOutputs(root, opts, nil)
+		if err := writeState(statePath, next); err != nil {
+			return nil, fmt.Errorf("write state: %w", err)
+		}
+	}
+	return orphans, nil
+}
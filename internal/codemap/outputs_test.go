@@ -0,0 +1,128 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOrphanedOutputsAfterOutputPathChange(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("write main.go: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if orphans, err := OrphanedOutputs(opts); err != nil || len(orphans) != 0 {
+		t.Fatalf("expected no orphans, got %v (err %v)", orphans, err)
+	}
+
+	opts.OutputPath = "docs/ARCHITECTURE.md"
+	opts.DisablePaths = true
+	if err := os.MkdirAll(filepath.Join(tmpDir, "docs"), 0755); err != nil {
+		t.Fatalf("mkdir docs: %v", err)
+	}
+	if _, _, err := EnsureUpToDate(context.Background(), opts); err != nil {
+		t.Fatalf("EnsureUpToDate returned error: %v", err)
+	}
+	orphans, err := OrphanedOutputs(opts)
+	if err != nil {
+		t.Fatalf("OrphanedOutputs returned error: %v", err)
+	}
+	want := []string{"CODEMAP.md", "CODEMAP.paths"}
+	if !reflect.DeepEqual(orphans, want) {
+		t.Fatalf("unexpected orphans: got %v want %v", orphans, want)
+	}
+
+	removed, err := CleanOutputs(opts)
+	if err != nil {
+		t.Fatalf("CleanOutputs returned error: %v", err)
+	}
+	if !reflect.DeepEqual(removed, want) {
+		t.Fatalf("unexpected removed outputs: got %v want %v", removed, want)
+	}
+	for _, name := range want {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed, stat err: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "docs", "ARCHITECTURE.md")); err != nil {
+		t.Fatalf("expected current output to remain: %v", err)
+	}
+	if orphans, err := OrphanedOutputs(opts); err != nil || len(orphans) != 0 {
+		t.Fatalf("expected no orphans after cleaning, got %v (err %v)", orphans, err)
+	}
+}
+
+func TestGeneratedFilesAreNeverIndexed(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":      "module example.com/test\n\ngo 1.22\n",
+		"main.go":     "package main\n\nfunc main() {}\n",
+		"web/app.ts":  "export const app = 1;\n",
+		"web/map.ts":  "export const stale = 1;\n",
+		"cache/.keep": "",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.StatePath = "cache/codemap-state.json"
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+
+	// Point the outputs at a source file and source-like names in subdirectories.
+	opts.OutputPath = "web/map.ts"
+	opts.PathsOutputPath = "web/CODEMAP.paths.ts"
+	opts.JSONOutputPath = "cache/CODEMAP.json"
+	if _, generated, err := EnsureUpToDate(context.Background(), opts); err != nil || !generated {
+		t.Fatalf("EnsureUpToDate returned %v (err %v)", generated, err)
+	}
+	idx, err := buildIndex(context.Background(), tmpDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indexed []string
+	for _, rec := range idx.Files {
+		indexed = append(indexed, rec.RelPath)
+	}
+	if want := []string{"main.go", "web/app.ts"}; !reflect.DeepEqual(indexed, want) {
+		t.Fatalf("unexpected indexed files: got %v want %v", indexed, want)
+	}
+
+	for i := 0; i < 2; i++ {
+		if stale, err := IsStale(context.Background(), opts); err != nil || stale {
+			t.Fatalf("expected outputs to stay fresh after writing them, got %v (err %v)", stale, err)
+		}
+		if _, generated, err := EnsureUpToDate(context.Background(), opts); err != nil || generated {
+			t.Fatalf("expected no regeneration, got %v (err %v)", generated, err)
+		}
+	}
+}
+
+func TestRendererForFormats(t *testing.T) {
+	opts := DefaultOptions()
+	opts.PathsMini = true
+	for _, format := range []string{"markdown", "paths", "json", "symbols", "concerns", "graph-dot", "graph-mermaid", "agents"} {
+		renderer, err := RendererFor(format, opts)
+		if err != nil {
+			t.Fatalf("RendererFor(%q) returned error: %v", format, err)
+		}
+		if renderer.Name() != format {
+			t.Fatalf("RendererFor(%q) returned the %s renderer", format, renderer.Name())
+		}
+	}
+	if renderer, _ := RendererFor("paths", opts); !renderer.(PathsRenderer).Mini {
+		t.Fatalf("expected the paths renderer to follow opts.PathsMini")
+	}
+	if _, err := RendererFor("hashes", opts); err == nil {
+		t.Fatalf("expected an error for the hashes format")
+	}
+}
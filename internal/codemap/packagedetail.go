@@ -0,0 +1,172 @@
+package codemap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+)
+
+// ErrUnknownPackage is returned by PackageDetail when no analyzed package
+// lives at the requested path.
+var ErrUnknownPackage = errors.New("unknown package")
+
+// analyzeCached analyzes the project without writing outputs or state,
+// serving every package whose fingerprint still matches from the analysis
+// cache so only changed packages are parsed. Symbols are always kept, so
+// Options.LowMemory is ignored.
+func analyzeCached(ctx context.Context, opts Options) (*Codemap, *FileIndex, error) {
+	opts.LowMemory = false
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolve root: %w", err)
+	}
+	state, err := readState(resolveStatePath(root, opts))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read state: %w", err)
+	}
+	fastState := state
+	if !indexStateMatches(state, opts) {
+		fastState = nil
+	}
+	idx, _, err := indexFromState(ctx, root, opts, fastState, ignoredRootEntryNames(root, opts))
+	if err != nil {
+		return nil, nil, fmt.Errorf("build file index from state: %w", err)
+	}
+	if idx == nil {
+		idx, err = buildIndex(ctx, root, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("build file index: %w", err)
+		}
+	}
+	analysisCache, err := readAnalysisCache(resolveAnalysisStatePath(root, opts))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read analysis cache: %w", err)
+	}
+	prevState := mergeStateWithAnalysis(state, analysisCache)
+	hash, nextState, err := computeAggregateHash(ctx, idx, prevState)
+	if err != nil {
+		return nil, nil, fmt.Errorf("compute hash: %w", err)
+	}
+
+	cm, err := AnalyzeWithRegistry(ctx, AnalysisInput{
+		Root:      root,
+		Index:     idx,
+		Options:   opts,
+		PrevState: prevState,
+		NextState: nextState,
+	}, analyzerRegistryFor(opts))
+	if err != nil {
+		return nil, nil, fmt.Errorf("analyze: %w", err)
+	}
+	cm.ContentHash = hash
+	return cm, idx, nil
+}
+
+// PackageDetail returns the analysis of the package at relPath, one entry per
+// language analyzing that directory. Unlike the rendered outputs, files are
+// always listed, even for packages below Options.LargePackageFiles.
+func PackageDetail(ctx context.Context, opts Options, relPath string) ([]Package, error) {
+	cm, idx, err := analyzeCached(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return packageDetail(ctx, opts, cm, func() (*FileIndex, error) { return idx, nil }, relPath)
+}
+
+// packageDetail picks the packages at relPath from cm, a model analyzed with
+// opts. Packages carrying no file listing are re-analyzed from the files
+// index lists for them.
+func packageDetail(ctx context.Context, opts Options, cm *Codemap, index func() (*FileIndex, error), relPath string) ([]Package, error) {
+	relPath = path.Clean(filepath.ToSlash(relPath))
+	var matches []Package
+	pkgPaths := make(map[string]struct{}, len(cm.Packages))
+	for _, pkg := range cm.Packages {
+		pkgPaths[pkg.RelativePath] = struct{}{}
+		if pkg.RelativePath == relPath {
+			matches = append(matches, pkg)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPackage, relPath)
+	}
+
+	needsFiles := false
+	for _, pkg := range matches {
+		needsFiles = needsFiles || len(pkg.Files) == 0
+	}
+	if !needsFiles {
+		return matches, nil
+	}
+
+	// Small packages carry no file listing in the cached model; re-analyze
+	// just this package's files with the listing threshold lowered.
+	idx, err := index()
+	if err != nil {
+		return nil, err
+	}
+	sub := &FileIndex{Root: idx.Root}
+	for _, rec := range idx.Files {
+		if owner, _ := owningPackagePath(rec.RelPath, pkgPaths); owner == relPath {
+			sub.Files = append(sub.Files, rec)
+		}
+	}
+	detailOpts := opts
+	detailOpts.LargePackageFiles = 1
+	detailOpts.CoChange = false
+	detail, err := AnalyzeWithRegistry(ctx, AnalysisInput{Root: idx.Root, Index: sub, Options: detailOpts}, analyzerRegistryFor(opts))
+	if err != nil {
+		return nil, fmt.Errorf("analyze %s: %w", relPath, err)
+	}
+	for i := range matches {
+		if len(matches[i].Files) > 0 {
+			continue
+		}
+		for _, pkg := range detail.Packages {
+			if pkg.ID == matches[i].ID {
+				matches[i].Files = pkg.Files
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+// stateFileIndex lists the files recorded in the state of opts as an index,
+// without walking or hashing the tree, for re-analyzing part of a model that
+// was just built from that state.
+func stateFileIndex(opts Options) (*FileIndex, error) {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	state, err := readState(resolveStatePath(root, opts))
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	if state == nil {
+		return nil, fmt.Errorf("no state at %s", resolveStatePath(root, opts))
+	}
+	idx := &FileIndex{Root: root}
+	for _, entry := range state.Entries {
+		absPath := filepath.Join(root, filepath.FromSlash(entry.RelPath))
+		match, err := resolveStateEntryLanguage(entry, absPath)
+		if err != nil {
+			return nil, err
+		}
+		if match.ID == "" {
+			continue
+		}
+		idx.Files = append(idx.Files, FileRecord{
+			AbsPath:         absPath,
+			RelPath:         entry.RelPath,
+			Size:            entry.Size,
+			ModTimeUnixNano: entry.ModTimeUnixNano,
+			Language:        match.ID,
+			IsGo:            match.ID == languageGo,
+			IsTest:          match.IsTest,
+		})
+	}
+	return idx, nil
+}
@@ -0,0 +1,51 @@
+package codemap
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPackageDetailListsFilesOfSmallPackages(t *testing.T) {
+	tmpDir := t.TempDir()
+	pkgDir := filepath.Join(tmpDir, "internal", "store")
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pkgDir, "store.go"), []byte("// Package store persists things.\npackage store\n\n// Store saves.\ntype Store struct{}\n\nfunc NewStore() *Store { return nil }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+
+	packages, err := PackageDetail(context.Background(), opts, "./internal/store/")
+	if err != nil {
+		t.Fatalf("PackageDetail returned error: %v", err)
+	}
+	if len(packages) != 1 {
+		t.Fatalf("expected 1 package, got %d", len(packages))
+	}
+	pkg := packages[0]
+	if pkg.ImportPath != "example.com/app/internal/store" || pkg.EntryPoint != "store.go" {
+		t.Fatalf("unexpected package: %+v", pkg)
+	}
+	if len(pkg.Files) != 1 || pkg.Files[0].Name != "store.go" || len(pkg.Files[0].KeyFuncs) != 1 {
+		t.Fatalf("expected detailed file listing, got %+v", pkg.Files)
+	}
+
+	if _, err := PackageDetail(context.Background(), opts, "missing"); !errors.Is(err, ErrUnknownPackage) {
+		t.Fatalf("expected ErrUnknownPackage, got %v", err)
+	}
+}
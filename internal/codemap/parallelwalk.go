@@ -0,0 +1,189 @@
+package codemap
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// walkDirParallel walks the tree rooted at root like filepath.WalkDir: fn is
+// called from one goroutine, in the same lexical order, with the same
+// SkipDir and SkipAll handling, so indexes built either way are identical.
+// Meanwhile up to workers goroutines read directories and stat their entries
+// ahead of fn, which is where a cold walk of a large tree spends its time.
+// Directories whose name matches lazy are not read ahead; they are read only
+// when fn enters them, so trees fn is known to skip cost nothing.
+func walkDirParallel(ctx context.Context, root string, workers int, lazy func(name string) bool, fn fs.WalkDirFunc) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		p := newDirPrefetcher(ctx, workers, lazy)
+		defer p.stop()
+		err = p.walk(root, fs.FileInfoToDirEntry(info), p.prefetch(root, nil), fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// dirListing is one directory read by a dirPrefetcher. entries and children
+// are set before done is closed; children[i] is the listing queued for
+// entries[i], or nil for files and lazy directories.
+type dirListing struct {
+	path     string
+	parent   *dirListing
+	entries  []fs.DirEntry
+	children []*dirListing
+	err      error
+	skipped  atomic.Bool
+	done     chan struct{}
+}
+
+// abandoned reports whether fn skipped this directory or one above it, so
+// reading it ahead would be wasted.
+func (l *dirListing) abandoned() bool {
+	for ; l != nil; l = l.parent {
+		if l.skipped.Load() {
+			return true
+		}
+	}
+	return false
+}
+
+type dirPrefetcher struct {
+	ctx  context.Context
+	lazy func(name string) bool
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	stack   []*dirListing // LIFO, so workers stay close to where fn is
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func newDirPrefetcher(ctx context.Context, workers int, lazy func(name string) bool) *dirPrefetcher {
+	p := &dirPrefetcher{ctx: ctx, lazy: lazy}
+	p.cond = sync.NewCond(&p.mu)
+	for range max(workers, 1) {
+		p.wg.Add(1)
+		go p.run()
+	}
+	return p
+}
+
+func (p *dirPrefetcher) run() {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		for len(p.stack) == 0 && !p.stopped {
+			p.cond.Wait()
+		}
+		if p.stopped {
+			p.mu.Unlock()
+			return
+		}
+		l := p.stack[len(p.stack)-1]
+		p.stack = p.stack[:len(p.stack)-1]
+		p.mu.Unlock()
+		p.read(l)
+	}
+}
+
+func (p *dirPrefetcher) stop() {
+	p.mu.Lock()
+	p.stopped = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// prefetch queues path for reading.
+func (p *dirPrefetcher) prefetch(path string, parent *dirListing) *dirListing {
+	l := &dirListing{path: path, parent: parent, done: make(chan struct{})}
+	p.mu.Lock()
+	p.stack = append(p.stack, l)
+	p.cond.Signal()
+	p.mu.Unlock()
+	return l
+}
+
+// read lists l and queues its subdirectories, the first on top.
+func (p *dirPrefetcher) read(l *dirListing) {
+	defer close(l.done)
+	if err := p.ctx.Err(); err != nil {
+		l.err = err
+		return
+	}
+	if l.abandoned() {
+		return
+	}
+	l.entries, l.err = os.ReadDir(l.path)
+	l.children = make([]*dirListing, len(l.entries))
+	var queued []*dirListing
+	for i, entry := range l.entries {
+		// Entries that vanish before the stat keep their lazy Info, which
+		// then reports the error to fn as it would during a plain walk.
+		if info, err := entry.Info(); err == nil {
+			l.entries[i] = fs.FileInfoToDirEntry(info)
+		}
+		if entry.IsDir() && (p.lazy == nil || !p.lazy(entry.Name())) {
+			l.children[i] = &dirListing{path: filepath.Join(l.path, entry.Name()), parent: l, done: make(chan struct{})}
+			queued = append(queued, l.children[i])
+		}
+	}
+	if len(queued) == 0 {
+		return
+	}
+	p.mu.Lock()
+	for i := len(queued) - 1; i >= 0; i-- {
+		p.stack = append(p.stack, queued[i])
+	}
+	p.cond.Broadcast()
+	p.mu.Unlock()
+}
+
+// walk mirrors the recursion of filepath.WalkDir for path, whose listing is
+// l or, for lazy directories, nil.
+func (p *dirPrefetcher) walk(path string, d fs.DirEntry, l *dirListing, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if l != nil {
+			l.skipped.Store(true)
+		}
+		if err == filepath.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	if l == nil {
+		l = &dirListing{path: path, done: make(chan struct{})}
+		p.read(l)
+	}
+	select {
+	case <-l.done:
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+	if l.err != nil {
+		if err := fn(path, d, l.err); err != nil {
+			if err == filepath.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+	for i, entry := range l.entries {
+		if err := p.walk(filepath.Join(path, entry.Name()), entry, l.children[i], fn); err != nil {
+			if err == filepath.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeParallelWalkFixture(t *testing.T, root string) {
+	t.Helper()
+	files := map[string]string{
+		"go.mod":                     "module example.com/app\n\ngo 1.22\n",
+		"a.go":                       "package app\n",
+		"a/b.go":                     "package a\n",
+		"a/b/c.go":                   "package b\n",
+		"a.b/d.go":                   "package ab\n",
+		"skipme/e.go":                "package skipme\n",
+		"skipme/deep/f.go":           "package deep\n",
+		"node_modules/pkg/index.js":  "module.exports = 1;\n",
+		"build/out.go":               "package build\n",
+		".gitignore":                 "build/\n",
+		"web/src/app.ts":             "export const app = 1;\n",
+		"web/src/components/x.tsx":   "export const X = 1;\n",
+		"docs/readme.txt":            "not indexed\n",
+		"internal/z/z.go":            "package z\n",
+		"internal/z/z_test.go":       "package z\n",
+		"internal/y/nested/deep.py":  "x = 1\n",
+		"internal/y/nested/other.rb": "x = 1\n",
+	}
+	for i := range 20 {
+		files[fmt.Sprintf("many/d%02d/f.go", i)] = "package f\n"
+	}
+	writeTestTree(t, root, files)
+}
+
+func TestWalkDirParallelMatchesWalkDir(t *testing.T) {
+	root := t.TempDir()
+	writeParallelWalkFixture(t, root)
+	if err := os.Symlink(filepath.Join(root, "a"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	record := func(visited *[]string) fs.WalkDirFunc {
+		return func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			*visited = append(*visited, fmt.Sprintf("%s %v %v", filepath.ToSlash(rel), d.Type(), info.IsDir()))
+			if d.IsDir() && (d.Name() == "skipme" || d.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			if d.Name() == "b.go" {
+				return filepath.SkipDir // Skips the rest of a/
+			}
+			return nil
+		}
+	}
+	var want []string
+	if err := filepath.WalkDir(root, record(&want)); err != nil {
+		t.Fatalf("WalkDir returned error: %v", err)
+	}
+	for _, workers := range []int{1, 4, 16} {
+		var got []string
+		if err := walkDirParallel(context.Background(), root, workers, isExcludedDir, record(&got)); err != nil {
+			t.Fatalf("walkDirParallel(%d) returned error: %v", workers, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("walkDirParallel(%d) visited\n%v\nwant\n%v", workers, got, want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := walkDirParallel(ctx, root, 4, nil, func(path string, d fs.DirEntry, err error) error { return err })
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled from a canceled walk, got %v", err)
+	}
+}
+
+func TestParallelWalkBuildsSameIndex(t *testing.T) {
+	root := t.TempDir()
+	writeParallelWalkFixture(t, root)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = root
+	opts.GitIgnore = true
+	opts.ExcludePatterns = []string{"internal/y"}
+	sequential, err := buildIndex(context.Background(), root, opts)
+	if err != nil {
+		t.Fatalf("sequential index: %v", err)
+	}
+	opts.ParallelWalk = 8
+	parallel, err := buildIndex(context.Background(), root, opts)
+	if err != nil {
+		t.Fatalf("parallel index: %v", err)
+	}
+	if !reflect.DeepEqual(parallel, sequential) {
+		t.Fatalf("parallel index differs:\n%+v\nwant\n%+v", parallel, sequential)
+	}
+	if len(sequential.Files) == 0 {
+		t.Fatalf("expected indexed files")
+	}
+}
@@ -0,0 +1,107 @@
+package codemap
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// pathFilter applies Options.ExcludePatterns and Options.IncludePatterns,
+// written in gitignore syntax, to slash paths relative to the project root,
+// and drops files codemap itself generates. A nil filter keeps everything.
+type pathFilter struct {
+	exclude   *ignoreMatcher
+	include   *ignoreMatcher
+	generated map[string]struct{} // Absolute paths from generatedPaths
+}
+
+// newPathFilter compiles the index patterns of opts and collects the paths it
+// generates under opts.ProjectRoot. Blank lines and comments are ignored as in
+// a .gitignore file.
+func newPathFilter(opts Options) *pathFilter {
+	f := &pathFilter{
+		exclude: compilePathPatterns(opts.ExcludePatterns),
+		include: compilePathPatterns(opts.IncludePatterns),
+	}
+	if root, err := filepath.Abs(opts.ProjectRoot); err == nil {
+		paths := generatedPaths(root, opts)
+		f.generated = make(map[string]struct{}, len(paths))
+		for _, path := range paths {
+			f.generated[path] = struct{}{}
+		}
+	}
+	return f
+}
+
+func compilePathPatterns(patterns []string) *ignoreMatcher {
+	if len(patterns) == 0 {
+		return nil
+	}
+	m := &ignoreMatcher{}
+	for _, pattern := range patterns {
+		if rule, ok := parseIgnoreRule(strings.TrimSpace(pattern), ""); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+	return m
+}
+
+// skipDir reports whether the walker should prune the directory at relPath.
+func (f *pathFilter) skipDir(relPath string) bool {
+	return f != nil && f.exclude != nil && f.exclude.ignored(relPath, true)
+}
+
+// skipDirTree is skipDir for indexes not built by a walk: it also reports
+// directories below an excluded one.
+func (f *pathFilter) skipDirTree(relPath string) bool {
+	if f == nil || f.exclude == nil {
+		return false
+	}
+	for dir := relPath; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if f.exclude.ignored(dir, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipFile reports whether the file at relPath is filtered out: it or a
+// parent directory matches an exclude pattern, or include patterns are set
+// and neither it nor a parent directory matches one.
+func (f *pathFilter) skipFile(relPath string) bool {
+	if f == nil {
+		return false
+	}
+	if f.exclude != nil && matchesPathOrParent(f.exclude, relPath) {
+		return true
+	}
+	return f.include != nil && !matchesPathOrParent(f.include, relPath)
+}
+
+// skipGenerated reports whether absPath is an output, cache or socket that
+// codemap writes. Matching absolute paths keeps this correct for scans rooted
+// below the project root.
+func (f *pathFilter) skipGenerated(absPath string) bool {
+	if f == nil || len(f.generated) == 0 {
+		return false
+	}
+	_, ok := f.generated[filepath.Clean(absPath)]
+	return ok
+}
+
+func matchesPathOrParent(m *ignoreMatcher, relPath string) bool {
+	if m.ignored(relPath, false) {
+		return true
+	}
+	for dir := path.Dir(relPath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if m.ignored(dir, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexPatternsEqual reports whether two pattern lists are identical.
+func indexPatternsEqual(a, b []string) bool {
+	return strings.Join(a, "\x00") == strings.Join(b, "\x00")
+}
@@ -0,0 +1,101 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuildIndexAppliesExcludeAndIncludePatterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{
+		"go.mod",
+		"main.go",
+		"api/server.go",
+		"api/server.pb.go",
+		"api/generated/types.go",
+		"generated/client.go",
+		"libs/util/util.go",
+		"third_party/lib/lib.go",
+	} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := func(opts Options) []string {
+		t.Helper()
+		idx, err := buildIndex(context.Background(), tmpDir, opts)
+		if err != nil {
+			t.Fatalf("buildIndex returned error: %v", err)
+		}
+		var got []string
+		for _, rec := range idx.Files {
+			if rec.Language == languageGo {
+				got = append(got, rec.RelPath)
+			}
+		}
+		return got
+	}
+
+	opts := DefaultOptions()
+	opts.ExcludePatterns = []string{"generated/", "/third_party", "*.pb.go"}
+	if got, want := files(opts), []string{"api/server.go", "libs/util/util.go", "main.go"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected files with excludes: %v", got)
+	}
+
+	opts = DefaultOptions()
+	opts.IncludePatterns = []string{"api/", "main.go"}
+	opts.ExcludePatterns = []string{"api/generated"}
+	if got, want := files(opts), []string{"api/server.go", "api/server.pb.go", "main.go"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected files with includes: %v", got)
+	}
+}
+
+func TestChangingIndexPatternsInvalidatesState(t *testing.T) {
+	tmpDir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":              "module example.com/test\n\ngo 1.22\n",
+		"main.go":             "package main\n\nfunc main() {}\n",
+		"generated/client.go": "package generated\n",
+	} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if len(cm.Packages) != 2 {
+		t.Fatalf("expected 2 packages, got %d", len(cm.Packages))
+	}
+
+	opts.ExcludePatterns = []string{"generated/"}
+	if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+		t.Fatalf("expected new exclude patterns to make outputs stale, got %v (err %v)", stale, err)
+	}
+	cm, generated, err := EnsureUpToDate(context.Background(), opts)
+	if err != nil || !generated {
+		t.Fatalf("EnsureUpToDate returned %v (err %v)", generated, err)
+	}
+	if len(cm.Packages) != 1 || cm.Packages[0].RelativePath != "." {
+		t.Fatalf("expected only the root package, got %+v", cm.Packages)
+	}
+	if stale, err := IsStale(context.Background(), opts); err != nil || stale {
+		t.Fatalf("expected fresh outputs, got %v (err %v)", stale, err)
+	}
+}
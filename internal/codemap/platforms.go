@@ -0,0 +1,126 @@
+package codemap
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// platformSuffixes are operating-system tokens recognized in file names, such
+// as foo_linux.go, foo_windows_amd64.go, net_posix.py, or picker.ios.tsx.
+var platformSuffixes = map[string]struct{}{
+	"aix": {}, "android": {}, "darwin": {}, "dragonfly": {}, "freebsd": {},
+	"hurd": {}, "illumos": {}, "ios": {}, "js": {}, "linux": {}, "macos": {},
+	"netbsd": {}, "openbsd": {}, "osx": {}, "plan9": {}, "posix": {},
+	"solaris": {}, "unix": {}, "wasip1": {}, "web": {}, "win32": {},
+	"windows": {}, "zos": {},
+}
+
+// architectureSuffixes may follow an OS token (foo_linux_amd64.go).
+var architectureSuffixes = map[string]struct{}{
+	"386": {}, "amd64": {}, "arm": {}, "arm64": {}, "loong64": {}, "mips": {},
+	"mips64": {}, "mips64le": {}, "mipsle": {}, "ppc64": {}, "ppc64le": {},
+	"riscv64": {}, "s390x": {}, "wasm": {},
+}
+
+// assignPlatformVariants counts, per package, the source files whose names
+// carry a platform suffix and records which platforms appear.
+func assignPlatformVariants(packages []Package, idx *FileIndex, includeTests bool) {
+	if idx == nil {
+		return
+	}
+	pkgPaths := make(map[string]map[string]struct{})
+	for _, pkg := range packages {
+		if pkgPaths[pkg.Language] == nil {
+			pkgPaths[pkg.Language] = make(map[string]struct{})
+		}
+		pkgPaths[pkg.Language][pkg.RelativePath] = struct{}{}
+	}
+
+	type variants struct {
+		files     int
+		platforms map[string]struct{}
+	}
+	byPackage := make(map[[2]string]*variants)
+	for _, rec := range idx.Files {
+		if rec.Language == "" || (rec.IsTest && !includeTests) {
+			continue
+		}
+		platform := platformSuffix(path.Base(rec.RelPath))
+		if platform == "" {
+			continue
+		}
+		pkgPath, ok := owningPackagePath(rec.RelPath, pkgPaths[rec.Language])
+		if !ok {
+			continue
+		}
+		key := [2]string{rec.Language, pkgPath}
+		v := byPackage[key]
+		if v == nil {
+			v = &variants{platforms: make(map[string]struct{})}
+			byPackage[key] = v
+		}
+		v.files++
+		v.platforms[platform] = struct{}{}
+	}
+
+	for i := range packages {
+		v := byPackage[[2]string{packages[i].Language, packages[i].RelativePath}]
+		if v == nil {
+			continue
+		}
+		packages[i].PlatformFiles = v.files
+		packages[i].Platforms = sortedImportSet(v.platforms)
+	}
+}
+
+// platformSuffix returns the platform a file name targets, or "" when the
+// name has no platform suffix. Test affixes are ignored, so foo_linux_test.go
+// still reports linux.
+func platformSuffix(name string) string {
+	stem := strings.ToLower(name)
+	if ext := path.Ext(stem); ext != "" {
+		stem = strings.TrimSuffix(stem, ext)
+	}
+	for _, suffix := range []string{"_test", ".test", ".spec", "_spec"} {
+		stem = strings.TrimSuffix(stem, suffix)
+	}
+	tokens := strings.FieldsFunc(stem, func(r rune) bool {
+		return r == '_' || r == '.' || r == '-'
+	})
+	// A bare platform name (linux.go, web.ts) is an ordinary file name.
+	if len(tokens) < 2 {
+		return ""
+	}
+	last := tokens[len(tokens)-1]
+	if _, ok := architectureSuffixes[last]; ok && len(tokens) > 2 {
+		last = tokens[len(tokens)-2]
+	}
+	if _, ok := platformSuffixes[last]; ok {
+		return last
+	}
+	return ""
+}
+
+// platformSummary renders a package's variants, e.g.
+// "3 platform-specific files: _darwin, _linux, _windows".
+func platformSummary(pkg Package) string {
+	suffixes := make([]string, len(pkg.Platforms))
+	for i, platform := range pkg.Platforms {
+		suffixes[i] = "_" + platform
+	}
+	noun := "files"
+	if pkg.PlatformFiles == 1 {
+		noun = "file"
+	}
+	return fmt.Sprintf("%d platform-specific %s: %s", pkg.PlatformFiles, noun, strings.Join(suffixes, ", "))
+}
+
+func hasPlatformVariants(packages []Package) bool {
+	for _, pkg := range packages {
+		if pkg.PlatformFiles > 0 {
+			return true
+		}
+	}
+	return false
+}
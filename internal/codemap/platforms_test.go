@@ -0,0 +1,70 @@
+package codemap
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlatformSuffix(t *testing.T) {
+	tests := map[string]string{
+		"poll_linux.go":          "linux",
+		"poll_windows_amd64.go":  "windows",
+		"poll_darwin_test.go":    "darwin",
+		"poll_amd64.go":          "",
+		"linux.go":               "",
+		"picker.ios.tsx":         "ios",
+		"picker.android.test.ts": "android",
+		"net_posix.py":           "posix",
+		"term_unix.rs":           "unix",
+		"server.go":              "",
+	}
+	for name, want := range tests {
+		if got := platformSuffix(name); got != want {
+			t.Fatalf("platformSuffix(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestAnalyzeCountsPlatformVariants(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                      "module example.com/test\n\ngo 1.22\n",
+		"poller/poller.go":            "// Package poller waits for events.\npackage poller\n",
+		"poller/poller_linux.go":      "package poller\n",
+		"poller/poller_darwin.go":     "package poller\n",
+		"poller/poller_windows.go":    "package poller\n",
+		"poller/poller_linux_test.go": "package poller\n",
+		"plain/plain.go":              "package plain\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+
+	var poller Package
+	for _, pkg := range cm.Packages {
+		if pkg.RelativePath == "plain" && pkg.PlatformFiles != 0 {
+			t.Fatalf("expected no variants for plain, got %+v", pkg)
+		}
+		if pkg.RelativePath == "poller" {
+			poller = pkg
+		}
+	}
+	if poller.PlatformFiles != 3 || !reflect.DeepEqual(poller.Platforms, []string{"darwin", "linux", "windows"}) {
+		t.Fatalf("unexpected poller variants: %d %v", poller.PlatformFiles, poller.Platforms)
+	}
+
+	content, err := Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if !strings.Contains(content, "| poller | 3 platform-specific files: _darwin, _linux, _windows |") {
+		t.Fatalf("expected platform variants in output:\n%s", content)
+	}
+}
@@ -0,0 +1,143 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// LanguageProfile reports analysis cost for one language.
+type LanguageProfile struct {
+	Language  string        `json:"language"`
+	Files     int           `json:"files"`
+	Lines     int64         `json:"lines"` // Lines parsed; cache hits are not re-read
+	Packages  int           `json:"packages"`
+	Analyzed  int64         `json:"analyzed"`
+	CacheHits int64         `json:"cacheHits"`
+	Duration  time.Duration `json:"durationNs"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// CacheHitRate returns the share of packages reused from the analysis cache.
+func (p LanguageProfile) CacheHitRate() float64 {
+	total := p.Analyzed + p.CacheHits
+	if total == 0 {
+		return 0
+	}
+	return float64(p.CacheHits) / float64(total)
+}
+
+// ProfileLanguages runs each detected language analyzer on its own against
+// the current analysis cache and reports time and cache efficiency per
+// language. It never writes outputs or state. With cold set, the cache is
+// ignored so every package is analyzed.
+func ProfileLanguages(ctx context.Context, opts Options, cold bool) ([]LanguageProfile, error) {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	idx, err := buildIndex(ctx, root, opts)
+	if err != nil {
+		return nil, fmt.Errorf("build file index: %w", err)
+	}
+
+	var prevState *CodemapState
+	if !cold {
+		state, err := readState(resolveStatePath(root, opts))
+		if err != nil {
+			return nil, fmt.Errorf("read state: %w", err)
+		}
+		analysisCache, err := readAnalysisCache(resolveAnalysisStatePath(root, opts))
+		if err != nil {
+			return nil, fmt.Errorf("read analysis cache: %w", err)
+		}
+		prevState = mergeStateWithAnalysis(state, analysisCache)
+	}
+	_, nextState, err := computeAggregateHash(ctx, idx, prevState)
+	if err != nil {
+		return nil, fmt.Errorf("compute hash: %w", err)
+	}
+
+	filesByLanguage := make(map[string]int)
+	for _, rec := range idx.Files {
+		if rec.Language != "" && (opts.IncludeTests || !rec.IsTest) {
+			filesByLanguage[rec.Language]++
+		}
+	}
+
+	registry := analyzerRegistryFor(opts)
+	var profiles []LanguageProfile
+	for _, languageID := range selectedAnalyzerLanguageIDs(idx, registry) {
+		analyzer, _ := registry.AnalyzerFor(languageID)
+		counter := &profileCounter{next: opts.Instrumentation}
+		langOpts := opts
+		langOpts.Instrumentation = counter
+
+		start := time.Now()
+		cm, err := analyzer.Analyze(ctx, AnalysisInput{
+			Root:      root,
+			Index:     idx,
+			Options:   langOpts,
+			PrevState: prevState,
+			NextState: nextState,
+		})
+		profile := LanguageProfile{
+			Language:  languageID,
+			Files:     filesByLanguage[languageID],
+			Lines:     counter.lines.Load(),
+			Analyzed:  counter.analyzed.Load(),
+			CacheHits: counter.cacheHits.Load(),
+			Duration:  time.Since(start),
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			profile.Error = err.Error()
+		} else if cm != nil {
+			profile.Packages = len(cm.Packages)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// profileCounter tallies instrumentation events for ProfileLanguages.
+type profileCounter struct {
+	next      Instrumentation
+	analyzed  atomic.Int64
+	cacheHits atomic.Int64
+	lines     atomic.Int64
+}
+
+func (c *profileCounter) OnPackageStart(ctx context.Context, pkg PackageEvent) context.Context {
+	c.analyzed.Add(1)
+	if c.next != nil {
+		if next := c.next.OnPackageStart(ctx, pkg); next != nil {
+			return next
+		}
+	}
+	return ctx
+}
+
+func (c *profileCounter) OnPackageEnd(ctx context.Context, pkg PackageEvent, err error) {
+	if c.next != nil {
+		c.next.OnPackageEnd(ctx, pkg, err)
+	}
+}
+
+func (c *profileCounter) OnFileParsed(ctx context.Context, file FileEvent) {
+	c.lines.Add(int64(file.Lines))
+	if c.next != nil {
+		c.next.OnFileParsed(ctx, file)
+	}
+}
+
+func (c *profileCounter) OnCacheHit(ctx context.Context, pkg PackageEvent) {
+	c.cacheHits.Add(1)
+	if c.next != nil {
+		c.next.OnCacheHit(ctx, pkg)
+	}
+}
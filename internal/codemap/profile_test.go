@@ -0,0 +1,47 @@
+package codemap
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProfileLanguagesReportsCacheHitsPerLanguage(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":          "module example.com/test\n\ngo 1.22\n",
+		"app/app.go":      "// Package app runs things.\npackage app\n",
+		"scripts/run.sh":  "#!/bin/sh\n# Runs the app.\necho run\n",
+		"tools/helper.py": "\"\"\"Helper tools.\"\"\"\n\ndef helper():\n    pass\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cold, err := ProfileLanguages(context.Background(), opts, true)
+	if err != nil {
+		t.Fatalf("ProfileLanguages returned error: %v", err)
+	}
+	if len(cold) != 3 {
+		t.Fatalf("expected 3 language profiles, got %+v", cold)
+	}
+	for _, p := range cold {
+		if p.CacheHits != 0 || p.Analyzed == 0 || p.Files != 1 {
+			t.Fatalf("unexpected cold profile: %+v", p)
+		}
+	}
+
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	warm, err := ProfileLanguages(context.Background(), opts, false)
+	if err != nil {
+		t.Fatalf("ProfileLanguages returned error: %v", err)
+	}
+	for _, p := range warm {
+		// Directories that yield no package are never cached, so only the
+		// parsed line count and hit count are stable here.
+		if p.CacheHits != int64(p.Packages) || p.CacheHits == 0 || p.Lines != 0 {
+			t.Fatalf("expected every %s package to come from the cache, got %+v", p.Language, p)
+		}
+	}
+}
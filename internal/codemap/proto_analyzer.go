@@ -0,0 +1,263 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	protoPackagePattern     = regexp.MustCompile(`\bpackage\s+([\w.]+)\s*;`)
+	protoImportPattern      = regexp.MustCompile(`\bimport\s+(?:(?:public|weak)\s+)?"([^"]+)"\s*;`)
+	protoDeclarationPattern = regexp.MustCompile(`\b(message|enum|service)\s+(\w+)\s*\{|\brpc\s+(\w+)\s*\(\s*(stream\s+)?([\w.]+)\s*\)\s*returns\s*\(\s*(stream\s+)?([\w.]+)\s*\)`)
+	protoLineComment        = regexp.MustCompile(`//[^\n]*`)
+	protoBlockComment       = regexp.MustCompile(`(?s)/\*.*?\*/`)
+)
+
+// ProtoAnalyzer is the analyzer implementation for Protocol Buffers IDL
+// files. Files are grouped by directory; each package lists its services,
+// with their RPCs as methods, and its top-level messages and enums.
+type ProtoAnalyzer struct{}
+
+func (ProtoAnalyzer) LanguageID() string { return languageProto }
+
+func (ProtoAnalyzer) Analyze(ctx context.Context, in AnalysisInput) (*Codemap, error) {
+	if in.Index == nil {
+		return nil, fmt.Errorf("missing file index")
+	}
+	return analyzeProtoWithIndex(ctx, in.Root, in.Index, in.Options)
+}
+
+func analyzeProtoWithIndex(ctx context.Context, root string, idx *FileIndex, opts Options) (*Codemap, error) {
+	// Imports name files relative to an include root, so they are resolved
+	// against every indexed proto file, tests included.
+	var protoFiles []string
+	for _, rec := range idx.Files {
+		if rec.Language == languageProto {
+			protoFiles = append(protoFiles, rec.RelPath)
+		}
+	}
+	plans := buildDirectoryPackagePlans(root, testFilteredIndex(idx, languageProto, opts.IncludeTests), languageProto)
+
+	packageResults := make([]*Package, len(plans))
+	jobs := make([]analysisJob, 0, len(plans))
+	for i := range plans {
+		jobs = append(jobs, analysisJob{
+			index:   i,
+			dir:     plans[i].DirAbsPath,
+			relPath: plans[i].RelativePath,
+		})
+	}
+
+	if err := analyzePackagePlansParallel(ctx, opts, languageProto, jobs, packageResults, func(ctx context.Context, job analysisJob) (*Package, error) {
+		plan := plans[job.index]
+		pkg, err := analyzeProtoPackage(ctx, root, plan, protoFiles, opts)
+		if err != nil {
+			return nil, fmt.Errorf("analyze proto package %s: %w", plan.RelativePath, err)
+		}
+		return pkg, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0, len(packageResults))
+	for i := range packageResults {
+		if packageResults[i] != nil {
+			packages = append(packages, *packageResults[i])
+		}
+	}
+
+	return &Codemap{
+		ProjectRoot: root,
+		Packages:    packages,
+	}, nil
+}
+
+func analyzeProtoPackage(ctx context.Context, root string, plan packagePlan, protoFiles []string, opts Options) (*Package, error) {
+	if len(plan.FileRelPaths) == 0 {
+		return nil, nil
+	}
+
+	files := make([]File, 0, len(plan.FileRelPaths))
+	var allTypes []TypeInfo
+	protoPackages := make(map[string]struct{})
+	internal := make(map[string]struct{})
+	external := make(map[string]struct{})
+	totalLines := 0
+	purpose := ""
+	entryPoint := ""
+	entryScore := -1
+	var tags []string
+
+	for _, relPath := range plan.FileRelPaths {
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(relPath)))
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", relPath, err)
+		}
+		lineCount := lineCountBytes(content)
+		recordFileParsed(ctx, relPath, lineCount)
+		totalLines += lineCount
+		name := filepath.Base(relPath)
+
+		filePurpose := extractFilePurpose(opts, languageProto, relPath, content, extractLeadingCommentPurpose)
+		if purpose == "" && filePurpose != "" {
+			purpose = filePurpose
+		}
+		tags = mergeCodemapTags(tags, extractCodemapTags(content))
+
+		parsed := parseProtoFile(content)
+		if parsed.pkg != "" {
+			protoPackages[parsed.pkg] = struct{}{}
+		}
+		for _, imp := range parsed.imports {
+			if resolved, ok := resolveProtoImport(imp, protoFiles); ok {
+				internal[resolved] = struct{}{}
+			} else {
+				external[imp] = struct{}{}
+			}
+		}
+		keyTypes := make([]string, 0, len(parsed.types))
+		services := 0
+		for _, info := range parsed.types {
+			keyTypes = append(keyTypes, info.Name)
+			if info.Kind == "service" {
+				services++
+			}
+		}
+		allTypes = append(allTypes, parsed.types...)
+
+		files = append(files, File{
+			Name:      name,
+			LineCount: lineCount,
+			Purpose:   filePurpose,
+			KeyTypes:  keyTypes,
+		})
+
+		score := scoreProtoEntryPoint(name, path.Base(plan.RelativePath), services, len(parsed.types))
+		if score > entryScore || (score == entryScore && name < entryPoint) {
+			entryScore = score
+			entryPoint = name
+		}
+	}
+
+	// A directory normally holds one proto package, which becomes the
+	// import path.
+	importPath := plan.RelativePath
+	packageNames := sortedImportSet(protoPackages)
+	if len(packageNames) > 0 {
+		importPath = packageNames[0]
+	}
+	if purpose == "" {
+		purpose = "Protobuf definitions in " + filepath.Base(plan.DirAbsPath)
+		if len(packageNames) > 0 {
+			purpose = "Protobuf package " + strings.Join(packageNames, ", ")
+		}
+	}
+	sort.SliceStable(allTypes, func(i, j int) bool { return allTypes[i].Name < allTypes[j].Name })
+
+	detailedFiles, keyFiles := packageFileDetails(files, entryPoint, opts)
+
+	return &Package{
+		ImportPath:      importPath,
+		RelativePath:    plan.RelativePath,
+		Purpose:         purpose,
+		FileCount:       len(plan.FileRelPaths),
+		LineCount:       totalLines,
+		Files:           detailedFiles,
+		KeyFiles:        keyFiles,
+		ExportedTypes:   allTypes,
+		Imports:         sortedImportSet(internal),
+		ExternalImports: sortedImportSet(external),
+		Symbols:         packageSymbols(files, allTypes),
+		EntryPoint:      entryPoint,
+		Tags:            tags,
+	}, nil
+}
+
+type protoFile struct {
+	pkg     string
+	imports []string
+	types   []TypeInfo
+}
+
+// parseProtoFile lists the top-level messages, enums and services of a proto
+// file. The RPCs of a service become its methods, e.g.
+// "GetOrder(GetOrderRequest) returns (Order)".
+func parseProtoFile(content []byte) protoFile {
+	src := string(protoLineComment.ReplaceAll(protoBlockComment.ReplaceAll(content, []byte(" ")), nil))
+	var parsed protoFile
+	if match := protoPackagePattern.FindStringSubmatch(src); match != nil {
+		parsed.pkg = match[1]
+	}
+	for _, match := range protoImportPattern.FindAllStringSubmatch(src, -1) {
+		parsed.imports = append(parsed.imports, match[1])
+	}
+
+	depth := 0
+	last := 0
+	service := -1
+	for _, loc := range protoDeclarationPattern.FindAllStringSubmatchIndex(src, -1) {
+		depth += strings.Count(src[last:loc[0]], "{") - strings.Count(src[last:loc[0]], "}")
+		last = loc[0]
+		if loc[2] >= 0 {
+			if depth == 0 {
+				kind := src[loc[2]:loc[3]]
+				parsed.types = append(parsed.types, TypeInfo{Name: src[loc[4]:loc[5]], Kind: kind})
+				service = -1
+				if kind == "service" {
+					service = len(parsed.types) - 1
+				}
+			}
+			continue
+		}
+		if depth != 1 || service < 0 {
+			continue
+		}
+		method := src[loc[6]:loc[7]] + "(" + protoStreamPrefix(src, loc[8], loc[9]) + src[loc[10]:loc[11]] + ") returns (" + protoStreamPrefix(src, loc[12], loc[13]) + src[loc[14]:loc[15]] + ")"
+		parsed.types[service].Methods = append(parsed.types[service].Methods, method)
+	}
+	return parsed
+}
+
+func protoStreamPrefix(src string, start, end int) string {
+	if start < 0 {
+		return ""
+	}
+	return strings.TrimSpace(src[start:end]) + " "
+}
+
+// resolveProtoImport finds the indexed proto file an import names. Imports
+// are relative to an include root such as the project root or a proto/
+// directory, so the shortest file path ending in the import wins.
+func resolveProtoImport(imp string, protoFiles []string) (string, bool) {
+	best := ""
+	for _, relPath := range protoFiles {
+		if relPath != imp && !strings.HasSuffix(relPath, "/"+imp) {
+			continue
+		}
+		if best == "" || len(relPath) < len(best) {
+			best = relPath
+		}
+	}
+	return best, best != ""
+}
+
+func scoreProtoEntryPoint(name, dirName string, services, typeCount int) int {
+	score := 0
+	stem := strings.TrimSuffix(name, ".proto")
+	if services > 0 {
+		score += 100
+	}
+	if stem == dirName || stem == "service" || stem == "api" {
+		score += 20
+	}
+	if typeCount > 0 {
+		score += 5
+	}
+	return score
+}
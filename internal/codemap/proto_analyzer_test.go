@@ -0,0 +1,125 @@
+package codemap
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProtoFile(t *testing.T) {
+	content := `// Orders API.
+syntax = "proto3";
+
+package orders.v1;
+
+import "google/protobuf/timestamp.proto";
+import public "orders/v1/types.proto";
+
+option go_package = "example.com/app/gen/orders/v1;ordersv1";
+
+/* service Commented {} */
+service OrderService {
+  // rpc Hidden(A) returns (B);
+  rpc GetOrder(GetOrderRequest) returns (Order);
+  rpc WatchOrders(stream WatchRequest) returns (stream Order) {
+    option idempotency_level = NO_SIDE_EFFECTS;
+  }
+}
+
+message GetOrderRequest {
+  string id = 1;
+  message Filter { string status = 1; }
+  enum Mode { MODE_UNSPECIFIED = 0; }
+}
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+}
+`
+	parsed := parseProtoFile([]byte(content))
+	if parsed.pkg != "orders.v1" {
+		t.Fatalf("pkg = %q", parsed.pkg)
+	}
+	if want := []string{"google/protobuf/timestamp.proto", "orders/v1/types.proto"}; !reflect.DeepEqual(parsed.imports, want) {
+		t.Fatalf("imports = %v, want %v", parsed.imports, want)
+	}
+	want := []TypeInfo{
+		{Name: "OrderService", Kind: "service", Methods: []string{
+			"GetOrder(GetOrderRequest) returns (Order)",
+			"WatchOrders(stream WatchRequest) returns (stream Order)",
+		}},
+		{Name: "GetOrderRequest", Kind: "message"},
+		{Name: "Status", Kind: "enum"},
+	}
+	if !reflect.DeepEqual(parsed.types, want) {
+		t.Fatalf("types =\n%+v\nwant\n%+v", parsed.types, want)
+	}
+}
+
+func TestProtoPackagesLinkGeneratedCode(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                       "module example.com/app\n\ngo 1.22\n",
+		"main.go":                      "package main\n\nfunc main() {}\n",
+		"proto/orders/v1/orders.proto": "// Orders API.\nsyntax = \"proto3\";\npackage orders.v1;\nimport \"orders/v1/types.proto\";\nimport \"google/protobuf/empty.proto\";\noption go_package = \"example.com/app/gen/ordersv1\";\nservice OrderService {\n  rpc GetOrder(GetOrderRequest) returns (Order);\n}\nmessage GetOrderRequest {}\n",
+		"proto/orders/v1/types.proto":  "syntax = \"proto3\";\npackage orders.v1;\nmessage Order {}\n",
+		"proto/billing/billing.proto":  "syntax = \"proto3\";\npackage billing;\nimport \"orders/v1/types.proto\";\nmessage Invoice {}\n",
+		"gen/ordersv1/doc.go":          "// Package ordersv1 holds generated order types.\npackage ordersv1\n",
+		"gen/billing/billing.pb.go":    "// Code generated by protoc-gen-go. DO NOT EDIT.\n// source: billing/billing.proto\n\npackage billing\n\n// Invoice is an invoice.\ntype Invoice struct{}\n",
+		"web/src/gen/orders_pb.ts":     "// @generated by protoc-gen-es v1.0.0\n// @generated from file orders/v1/orders.proto (package orders.v1, syntax proto3)\nexport class Order {}\n",
+		"web/src/gen/index.ts":         "export * from './orders_pb';\n",
+		"web/package.json":             "{\"name\": \"web\"}\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	byPath := make(map[string]Package)
+	for _, pkg := range cm.Packages {
+		byPath[pkg.Language+":"+pkg.RelativePath] = pkg
+	}
+
+	orders, ok := byPath["proto:proto/orders/v1"]
+	if !ok {
+		t.Fatalf("expected a proto package, got %v", cm.Packages)
+	}
+	if orders.ImportPath != "orders.v1" || orders.Purpose != "Orders API." || orders.EntryPoint != "orders.proto" || orders.FileCount != 2 {
+		t.Fatalf("unexpected proto package: %+v", orders)
+	}
+	if !reflect.DeepEqual(orders.ExternalImports, []string{"google/protobuf/empty.proto"}) {
+		t.Fatalf("ExternalImports = %v", orders.ExternalImports)
+	}
+	if billing := byPath["proto:proto/billing"]; billing.Purpose != "Protobuf package billing" || !reflect.DeepEqual(billing.DependsOn, []string{"proto/orders/v1"}) {
+		t.Fatalf("unexpected billing package: %+v", billing)
+	}
+
+	generated := map[string][]string{
+		"go:gen/ordersv1":       {"proto/orders/v1"},
+		"go:gen/billing":        {"proto/billing"},
+		"typescript:web":        {"proto/orders/v1"},
+		"go:.":                  nil,
+		"proto:proto/orders/v1": nil,
+	}
+	for key, want := range generated {
+		pkg, ok := byPath[key]
+		if !ok {
+			t.Fatalf("missing package %s in %v", key, cm.Packages)
+		}
+		if !reflect.DeepEqual(pkg.GeneratedFrom, want) {
+			t.Errorf("%s GeneratedFrom = %v, want %v", key, pkg.GeneratedFrom, want)
+		}
+	}
+
+	content, err := Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if want := "| Package ordersv1 holds generated order types. (Generated from proto/orders/v1) |"; !strings.Contains(content, want) {
+		t.Fatalf("expected generated-from annotation %q:\n%s", want, content)
+	}
+}
@@ -0,0 +1,59 @@
+package codemap
+
+import (
+	"sort"
+	"strings"
+)
+
+// PurposeExtractor derives a file's purpose line, letting embedders support
+// conventions the built-in extractors do not know about, such as a JSDoc
+// @module tag or an in-house header Summary field. Extractors run on worker
+// goroutines, so implementations must be safe for concurrent use.
+type PurposeExtractor interface {
+	// ExtractPurpose returns the purpose of the file at relPath (slash
+	// separated, relative to the project root). Returning ok=false or an
+	// empty purpose falls back to the built-in extractor.
+	ExtractPurpose(relPath string, content []byte) (purpose string, ok bool)
+}
+
+// PurposeExtractorFunc adapts a function to PurposeExtractor.
+type PurposeExtractorFunc func(relPath string, content []byte) (string, bool)
+
+// ExtractPurpose calls f(relPath, content).
+func (f PurposeExtractorFunc) ExtractPurpose(relPath string, content []byte) (string, bool) {
+	return f(relPath, content)
+}
+
+// customFilePurpose runs the extractor registered for language, reporting
+// false when there is none or it declines the file.
+func customFilePurpose(opts Options, language, relPath string, content []byte) (string, bool) {
+	extractor := opts.PurposeExtractors[language]
+	if extractor == nil {
+		return "", false
+	}
+	purpose, ok := extractor.ExtractPurpose(relPath, content)
+	purpose = strings.TrimSpace(purpose)
+	return purpose, ok && purpose != ""
+}
+
+// extractFilePurpose prefers a registered extractor over builtin.
+func extractFilePurpose(opts Options, language, relPath string, content []byte, builtin func([]byte) string) string {
+	if purpose, ok := customFilePurpose(opts, language, relPath, content); ok {
+		return purpose
+	}
+	return builtin(content)
+}
+
+// purposeExtractorLanguages returns the languages with a registered
+// extractor, recorded in the analysis cache so that adding or removing one
+// re-analyzes cached packages.
+func purposeExtractorLanguages(opts Options) []string {
+	var languages []string
+	for language, extractor := range opts.PurposeExtractors {
+		if extractor != nil {
+			languages = append(languages, language)
+		}
+	}
+	sort.Strings(languages)
+	return languages
+}
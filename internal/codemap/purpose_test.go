@@ -0,0 +1,79 @@
+package codemap
+
+import (
+	"context"
+	"regexp"
+	"testing"
+)
+
+func TestPurposeExtractorsOverrideBuiltinPurposes(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":           "module example.com/test\n\ngo 1.22\n",
+		"main.go":          "// Summary: Entry point for the test tool.\n// Package main does things.\npackage main\n\nfunc main() {}\n",
+		"web/package.json": "{\"name\": \"web\"}\n",
+		"web/src/app.ts":   "/**\n * Application shell.\n * @module Renders the web shell\n */\nexport const app = 1;\n",
+		"web/src/util.ts":  "// Utility helpers.\nexport const util = 2;\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	moduleTag := regexp.MustCompile(`@module\s+(.+)`)
+	summary := regexp.MustCompile(`(?m)^// Summary:\s*(.+)$`)
+	var seen []string
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.PurposeExtractors = map[string]PurposeExtractor{
+		"typescript": PurposeExtractorFunc(func(relPath string, content []byte) (string, bool) {
+			seen = append(seen, relPath)
+			match := moduleTag.FindSubmatch(content)
+			if match == nil {
+				return "", false
+			}
+			return string(match[1]), true
+		}),
+		"go": PurposeExtractorFunc(func(_ string, content []byte) (string, bool) {
+			match := summary.FindSubmatch(content)
+			if match == nil {
+				return "", false
+			}
+			return string(match[1]), true
+		}),
+	}
+
+	purposes := func(cm *Codemap) map[string]string {
+		out := make(map[string]string)
+		for _, pkg := range cm.Packages {
+			out[pkg.Language+":"+pkg.RelativePath] = pkg.Purpose
+		}
+		return out
+	}
+
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	got := purposes(cm)
+	if got["go:."] != "Entry point for the test tool." {
+		t.Fatalf("unexpected Go purpose: %q", got["go:."])
+	}
+	if got["typescript:web"] != "Renders the web shell" {
+		t.Fatalf("unexpected TypeScript purpose: %q (%v)", got["typescript:web"], got)
+	}
+	if len(seen) != 2 || seen[0] != "web/src/app.ts" {
+		t.Fatalf("expected the extractor to see project-relative paths, got %v", seen)
+	}
+
+	// Dropping the extractors must not reuse purposes from the analysis cache.
+	opts.PurposeExtractors = nil
+	cm, err = Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	got = purposes(cm)
+	if got["go:."] != "Summary: Entry point for the test tool." {
+		t.Fatalf("expected the built-in Go purpose, got %q", got["go:."])
+	}
+	if got["typescript:web"] == "Renders the web shell" {
+		t.Fatal("expected the built-in TypeScript purpose after removing the extractor")
+	}
+}
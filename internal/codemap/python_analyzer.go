@@ -45,15 +45,17 @@ func analyzePythonWithIndex(ctx context.Context, root string, idx *FileIndex, op
 		if cached, ok := cachedByRel[plan.RelativePath]; ok && plan.Fingerprint != "" && cached.Fingerprint == plan.Fingerprint {
 			pkg := cached.Package
 			packageResults[i] = &pkg
+			recordCacheHit(ctx, opts, languagePython, plan.RelativePath)
 			continue
 		}
 		jobs = append(jobs, analysisJob{
-			index: i,
-			dir:   plan.DirAbsPath,
+			index:   i,
+			dir:     plan.DirAbsPath,
+			relPath: plan.RelativePath,
 		})
 	}
 
-	if err := analyzePackagePlansParallel(ctx, opts, jobs, packageResults, func(job analysisJob) (*Package, error) {
+	if err := analyzePackagePlansParallel(ctx, opts, languagePython, jobs, packageResults, func(ctx context.Context, job analysisJob) (*Package, error) {
 		plan := plans[job.index]
 		packageName := ""
 		if cached, ok := cachedByRel[plan.RelativePath]; ok {
@@ -62,7 +64,7 @@ func analyzePythonWithIndex(ctx context.Context, root string, idx *FileIndex, op
 		if packageName == "" {
 			packageName = readPythonPackageName(plan.DirAbsPath, plan.RelativePath)
 		}
-		pkg, err := analyzePythonPackage(root, plan, packageName, opts)
+		pkg, err := analyzePythonPackage(ctx, root, plan, packageName, opts)
 		if err != nil {
 			return nil, fmt.Errorf("analyze python package %s: %w", plan.RelativePath, err)
 		}
@@ -78,17 +80,11 @@ func analyzePythonWithIndex(ctx context.Context, root string, idx *FileIndex, op
 		}
 	}
 
-	concerns, err := buildConcerns(idx, opts.Concerns, opts.ConcernExampleLimit)
-	if err != nil {
-		return nil, fmt.Errorf("build concerns: %w", err)
-	}
-
-	updateAnalysisCache(nextState, opts, modulePath, plans, packageResults)
+	updateAnalysisCache(nextState, opts, modulePath, languagePython, plans, packageResults)
 
 	return &Codemap{
 		ProjectRoot: root,
 		Packages:    packages,
-		Concerns:    concerns,
 	}, nil
 }
 
@@ -194,23 +190,22 @@ func buildPythonPackagePlans(root string, idx *FileIndex, includeTests bool, ent
 	return plans, nil
 }
 
-func analyzePythonPackage(root string, plan packagePlan, packageName string, opts Options) (*Package, error) {
+func analyzePythonPackage(ctx context.Context, root string, plan packagePlan, packageName string, opts Options) (*Package, error) {
 	if len(plan.FileRelPaths) == 0 {
 		return nil, nil
 	}
 
-	includeDetailedFiles := len(plan.FileRelPaths) >= opts.LargePackageFiles
-	var files []File
-	if includeDetailedFiles {
-		files = make([]File, 0, len(plan.FileRelPaths))
-	}
+	files := make([]File, 0, len(plan.FileRelPaths))
 	allTypes := make([]TypeInfo, 0, len(plan.FileRelPaths))
+	var privateSymbols []TypeInfo
 	importsSeen := make(map[string]struct{}, len(plan.FileRelPaths))
+	externalSeen := make(map[string]struct{})
 	totalLines := 0
 	purpose := ""
 	entryPoint := ""
 	entryScore := -1
 	firstFileName := ""
+	var tags []string
 	importPrefix := pythonImportPrefix(packageName, plan.RelativePath)
 
 	for _, relPath := range plan.FileRelPaths {
@@ -231,29 +226,42 @@ func analyzePythonPackage(root string, plan packagePlan, packageName string, opt
 			firstFileName = withinPackage
 		}
 
-		filePurpose := extractPythonFilePurpose(content)
+		filePurpose := ""
+		if isPythonNotebookPath(relPath) {
+			content, filePurpose = pythonNotebookSource(content)
+		}
+		if custom, ok := customFilePurpose(opts, languagePython, relPath, content); ok {
+			filePurpose = custom
+		} else if filePurpose == "" {
+			filePurpose = extractPythonFilePurpose(content)
+		}
 		if purpose == "" && filePurpose != "" {
 			purpose = filePurpose
 		}
+		tags = mergeCodemapTags(tags, extractCodemapTags(content))
 
 		typeInfos, keyTypes, keyFuncs, imports, lineCount := parsePythonFileSymbols(content, withinPackage)
+		recordFileParsed(ctx, relPath, lineCount)
 		totalLines += lineCount
 		allTypes = append(allTypes, typeInfos...)
+		if opts.IncludeUnexported {
+			privateSymbols = append(privateSymbols, parsePythonPrivateSymbols(content)...)
+		}
 		for _, imp := range imports {
 			if isPythonInternalImport(imp, importPrefix) {
 				importsSeen[imp] = struct{}{}
+			} else {
+				externalSeen[imp] = struct{}{}
 			}
 		}
 
-		if includeDetailedFiles {
-			files = append(files, File{
-				Name:      withinPackage,
-				LineCount: lineCount,
-				Purpose:   filePurpose,
-				KeyTypes:  keyTypes,
-				KeyFuncs:  keyFuncs,
-			})
-		}
+		files = append(files, File{
+			Name:      withinPackage,
+			LineCount: lineCount,
+			Purpose:   filePurpose,
+			KeyTypes:  keyTypes,
+			KeyFuncs:  keyFuncs,
+		})
 
 		score := scorePythonEntryPoint(withinPackage, keyTypes, keyFuncs)
 		if score > entryScore || (score == entryScore && (entryPoint == "" || withinPackage < entryPoint)) {
@@ -277,25 +285,45 @@ func analyzePythonPackage(root string, plan packagePlan, packageName string, opt
 	sort.Slice(allTypes, func(i, j int) bool {
 		return allTypes[i].Name < allTypes[j].Name
 	})
+	sort.SliceStable(privateSymbols, func(i, j int) bool {
+		return privateSymbols[i].Name < privateSymbols[j].Name
+	})
 
-	var detailedFiles []File
-	if includeDetailedFiles {
-		detailedFiles = files
-	}
+	detailedFiles, keyFiles := packageFileDetails(files, entryPoint, opts)
 
 	return &Package{
-		ImportPath:    packageName,
-		RelativePath:  plan.RelativePath,
-		Purpose:       purpose,
-		FileCount:     len(plan.FileRelPaths),
-		LineCount:     totalLines,
-		Files:         detailedFiles,
-		ExportedTypes: allTypes,
-		Imports:       internalImports,
-		EntryPoint:    entryPoint,
+		ImportPath:      packageName,
+		RelativePath:    plan.RelativePath,
+		Purpose:         purpose,
+		FileCount:       len(plan.FileRelPaths),
+		LineCount:       totalLines,
+		Files:           detailedFiles,
+		KeyFiles:        keyFiles,
+		ExportedTypes:   allTypes,
+		Symbols:         pythonSymbols(files, allTypes),
+		PrivateSymbols:  privateSymbols,
+		Imports:         internalImports,
+		ExternalImports: sortedImportSet(externalSeen),
+		EntryPoint:      entryPoint,
+		Tags:            tags,
 	}, nil
 }
 
+// pythonSymbols lists the package's public symbols, naming documented
+// functions "func" like undocumented ones and module constants "const".
+func pythonSymbols(files []File, types []TypeInfo) []Symbol {
+	symbols := packageSymbols(files, types)
+	for i, sym := range symbols {
+		switch {
+		case sym.Kind == "function":
+			symbols[i].Kind = "func"
+		case sym.Kind == "func" && parsePythonConstName(sym.Name+" =") == sym.Name:
+			symbols[i].Kind = "const"
+		}
+	}
+	return symbols
+}
+
 func findPythonPackageRoot(root, fileAbsPath string) (string, string, error) {
 	rootAbs, err := filepath.Abs(root)
 	if err != nil {
@@ -526,12 +554,9 @@ func parsePythonFileSymbols(content []byte, _ string) ([]TypeInfo, []string, []s
 	keyTypes := make([]string, 0)
 	keyFuncs := make([]string, 0)
 	imports := make([]string, 0)
-	lineCount := 0
 
-	scanner := bufio.NewScanner(bytes.NewReader(content))
-	for scanner.Scan() {
-		lineCount++
-		line := scanner.Text()
+	lines := scanPythonLines(content)
+	for i, line := range lines {
 		trimmed := strings.TrimSpace(line)
 		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
 			continue
@@ -545,24 +570,25 @@ func parsePythonFileSymbols(content []byte, _ string) ([]TypeInfo, []string, []s
 		if name := parsePythonClassName(trimmed); name != "" {
 			if isPublicPythonSymbol(name) {
 				if !stringSliceContains(keyTypes, name) {
-					typeInfos = append(typeInfos, TypeInfo{Name: name, Kind: "class"})
+					typeInfos = append(typeInfos, TypeInfo{Name: name, Kind: "class", Comment: pythonDocstring(lines, i)})
 					keyTypes = append(keyTypes, name)
 				}
 			}
 			continue
 		}
 
-		if name := parsePythonFuncName(trimmed, "async def "); name != "" {
-			if isPublicPythonSymbol(name) {
-				if !stringSliceContains(keyFuncs, name) {
-					keyFuncs = append(keyFuncs, name)
-				}
-			}
-			continue
+		name := parsePythonFuncName(trimmed, "async def ")
+		if name == "" {
+			name = parsePythonFuncName(trimmed, "def ")
 		}
-		if name := parsePythonFuncName(trimmed, "def "); name != "" {
+		if name != "" {
 			if isPublicPythonSymbol(name) {
 				if !stringSliceContains(keyFuncs, name) {
+					// Functions are listed by name in keyFuncs; only
+					// documented ones also carry a TypeInfo for their docstring.
+					if doc := pythonDocstring(lines, i); doc != "" {
+						typeInfos = append(typeInfos, TypeInfo{Name: name, Kind: "function", Comment: doc})
+					}
 					keyFuncs = append(keyFuncs, name)
 				}
 			}
@@ -592,6 +618,7 @@ func parsePythonFileSymbols(content []byte, _ string) ([]TypeInfo, []string, []s
 		}
 	}
 
+	lineCount := len(lines)
 	if len(content) > 0 && content[len(content)-1] == '\n' {
 		lineCount++
 	}
@@ -599,6 +626,126 @@ func parsePythonFileSymbols(content []byte, _ string) ([]TypeInfo, []string, []s
 	return typeInfos, keyTypes, keyFuncs, imports, lineCount
 }
 
+func scanPythonLines(content []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// pythonDocstring returns the first line of the docstring opening the body
+// of the class or def whose header starts at lines[start], or "". Headers
+// may span several lines; a body on the header line has no docstring.
+func pythonDocstring(lines []string, start int) string {
+	depth := 0
+	for i := start; i < len(lines); i++ {
+		line := lines[i]
+		for j := 0; j < len(line); j++ {
+			switch line[j] {
+			case '(', '[', '{':
+				depth++
+			case ')', ']', '}':
+				depth--
+			case '#':
+				j = len(line)
+			case ':':
+				if depth > 0 {
+					continue
+				}
+				rest := strings.TrimSpace(line[j+1:])
+				if rest != "" && !strings.HasPrefix(rest, "#") {
+					return ""
+				}
+				for k := i + 1; k < len(lines); k++ {
+					body := strings.TrimSpace(lines[k])
+					if body == "" || strings.HasPrefix(body, "#") {
+						continue
+					}
+					return pythonStringDoc(body, lines[k+1:])
+				}
+				return ""
+			}
+		}
+	}
+	return ""
+}
+
+// pythonStringDoc returns the first sentence of the string literal starting
+// trimmed, continuing into rest for triple-quoted strings, or "" when
+// trimmed does not start with a string literal.
+func pythonStringDoc(trimmed string, rest []string) string {
+	if len(trimmed) > 0 && strings.ContainsRune("rRuU", rune(trimmed[0])) {
+		trimmed = trimmed[1:]
+	}
+	delimiter := ""
+	for _, candidate := range []string{`"""`, `'''`, `"`, `'`} {
+		if strings.HasPrefix(trimmed, candidate) {
+			delimiter = candidate
+			break
+		}
+	}
+	if delimiter == "" {
+		return ""
+	}
+
+	first := strings.TrimSpace(strings.TrimPrefix(trimmed, delimiter))
+	if idx := strings.Index(first, delimiter); idx >= 0 || len(delimiter) == 1 {
+		if idx >= 0 {
+			first = first[:idx]
+		}
+		return extractFirstSentence(strings.TrimSpace(first))
+	}
+
+	var doc strings.Builder
+	if first != "" {
+		doc.WriteString(first)
+		doc.WriteByte('\n')
+	}
+	for _, next := range rest {
+		if idx := strings.Index(next, delimiter); idx >= 0 {
+			doc.WriteString(strings.TrimSpace(next[:idx]))
+			break
+		}
+		doc.WriteString(strings.TrimSpace(next))
+		doc.WriteByte('\n')
+	}
+	return extractFirstSentence(strings.TrimSpace(doc.String()))
+}
+
+// parsePythonPrivateSymbols returns the top-level classes and functions whose
+// names start with an underscore, skipping dunder names.
+func parsePythonPrivateSymbols(content []byte) []TypeInfo {
+	var symbols []TypeInfo
+	seen := make(map[string]struct{})
+	lines := scanPythonLines(content)
+	for i, line := range lines {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		kind := "class"
+		name := parsePythonClassName(trimmed)
+		if name == "" {
+			kind = "function"
+			name = parsePythonFuncName(trimmed, "async def ")
+		}
+		if name == "" {
+			name = parsePythonFuncName(trimmed, "def ")
+		}
+		if name == "" || isPublicPythonSymbol(name) || strings.HasSuffix(name, "__") {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		symbols = append(symbols, TypeInfo{Name: name, Kind: kind, Comment: pythonDocstring(lines, i), IsPrivate: true})
+	}
+	return symbols
+}
+
 func parsePythonClassName(line string) string {
 	if !strings.HasPrefix(line, "class ") {
 		return ""
@@ -745,9 +892,8 @@ func parsePythonImportStatement(spec string) []string {
 }
 
 func extractPythonFilePurpose(content []byte) string {
-	scanner := bufio.NewScanner(bytes.NewReader(content))
-	for scanner.Scan() {
-		line := scanner.Text()
+	lines := scanPythonLines(content)
+	for i, line := range lines {
 		trimmed := strings.TrimSpace(line)
 		if trimmed == "" {
 			continue
@@ -755,39 +901,10 @@ func extractPythonFilePurpose(content []byte) string {
 		if strings.HasPrefix(trimmed, "#") {
 			return extractFirstSentence(strings.TrimSpace(strings.TrimPrefix(trimmed, "#")))
 		}
-
-		delimiter := ""
-		switch {
-		case strings.HasPrefix(trimmed, `"""`):
-			delimiter = `"""`
-		case strings.HasPrefix(trimmed, `'''`):
-			delimiter = `'''`
-		}
-		if delimiter == "" {
+		if !strings.HasPrefix(trimmed, `"""`) && !strings.HasPrefix(trimmed, `'''`) {
 			return ""
 		}
-
-		first := strings.TrimSpace(strings.TrimPrefix(trimmed, delimiter))
-		if idx := strings.Index(first, delimiter); idx >= 0 {
-			first = first[:idx]
-			return extractFirstSentence(strings.TrimSpace(first))
-		}
-
-		var doc strings.Builder
-		if first != "" {
-			doc.WriteString(first)
-			doc.WriteByte('\n')
-		}
-		for scanner.Scan() {
-			next := scanner.Text()
-			if idx := strings.Index(next, delimiter); idx >= 0 {
-				doc.WriteString(strings.TrimSpace(next[:idx]))
-				break
-			}
-			doc.WriteString(strings.TrimSpace(next))
-			doc.WriteByte('\n')
-		}
-		return extractFirstSentence(strings.TrimSpace(doc.String()))
+		return pythonStringDoc(trimmed, lines[i+1:])
 	}
 	return ""
 }
@@ -822,6 +939,10 @@ func scorePythonEntryPoint(relPath string, keyTypes, keyFuncs []string) int {
 	if len(keyTypes) > 0 {
 		score += 5
 	}
+	// Notebooks are exploratory; prefer regular modules as the entry point.
+	if isPythonNotebookPath(lower) {
+		score -= 20
+	}
 	return score
 }
 
@@ -388,3 +388,117 @@ func TestAnalyzePythonWithIndexSkipsBrokenPackageAndKeepsHealthyOnes(t *testing.
 		t.Fatalf("expected healthy package to remain, got %q", cm.Packages[0].ImportPath)
 	}
 }
+
+func TestAnalyzePythonProjectIncludesNotebooks(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "pyproject.toml"), []byte("[project]\nname = \"science\"\n"), 0644); err != nil {
+		t.Fatalf("write pyproject.toml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.py"), []byte("def main():\n    return 1\n"), 0644); err != nil {
+		t.Fatalf("write main.py: %v", err)
+	}
+
+	notebook := `{
+ "cells": [
+  {"cell_type": "markdown", "source": ["# Churn exploration\n", "Notes."]},
+  {"cell_type": "code", "source": ["import science.features\n", "from .loaders import load\n", "\n", "class ChurnModel:\n", "    pass\n"]},
+  {"cell_type": "code", "source": "def score(df):\n    return df\n"}
+ ],
+ "nbformat": 4
+}`
+	if err := os.WriteFile(filepath.Join(tmpDir, "explore.ipynb"), []byte(notebook), 0644); err != nil {
+		t.Fatalf("write explore.ipynb: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.LargePackageFiles = 1
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	if len(cm.Packages) != 1 {
+		t.Fatalf("expected 1 package, got %+v", cm.Packages)
+	}
+
+	pkg := cm.Packages[0]
+	if pkg.FileCount != 2 {
+		t.Fatalf("expected notebook attributed to package, got %d files", pkg.FileCount)
+	}
+	if pkg.EntryPoint != "main.py" {
+		t.Fatalf("expected regular module entry point, got %q", pkg.EntryPoint)
+	}
+	if len(pkg.ExportedTypes) != 1 || pkg.ExportedTypes[0].Name != "ChurnModel" {
+		t.Fatalf("expected notebook class, got %+v", pkg.ExportedTypes)
+	}
+	if !reflect.DeepEqual(pkg.Imports, []string{".loaders", "science.features"}) {
+		t.Fatalf("unexpected notebook imports: %v", pkg.Imports)
+	}
+
+	var nbFile File
+	for _, f := range pkg.Files {
+		if f.Name == "explore.ipynb" {
+			nbFile = f
+		}
+	}
+	if nbFile.Purpose != "Churn exploration" {
+		t.Fatalf("expected markdown purpose, got %q", nbFile.Purpose)
+	}
+	if !reflect.DeepEqual(nbFile.KeyFuncs, []string{"score"}) {
+		t.Fatalf("expected notebook function, got %v", nbFile.KeyFuncs)
+	}
+}
+
+func TestParsePythonFileSymbolsExtractsDocstrings(t *testing.T) {
+	content := []byte(`"""Billing service."""
+
+class Invoice(Base):
+    """An issued invoice.
+
+    Totals are cached.
+    """
+
+    def total(self):
+        """Not top level."""
+
+
+def charge(
+    invoice: Invoice,
+    retries: dict = {"a": 1},
+) -> bool:
+    # Comments before the docstring are skipped.
+    r'''Charge the card on file'''
+    return True
+
+
+def refund(invoice): return False
+
+
+def void(invoice):
+    return None
+
+
+def _audit(invoice):
+    "Record an audit entry."
+`)
+
+	types, _, keyFuncs, _, _ := parsePythonFileSymbols(content, "billing.py")
+	want := []TypeInfo{
+		{Name: "Invoice", Kind: "class", Comment: "An issued invoice."},
+		{Name: "charge", Kind: "function", Comment: "Charge the card on file"},
+	}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("unexpected type infos:\n got %+v\nwant %+v", types, want)
+	}
+	if !reflect.DeepEqual(keyFuncs, []string{"charge", "refund", "void"}) {
+		t.Fatalf("unexpected key funcs: %v", keyFuncs)
+	}
+	private := parsePythonPrivateSymbols(content)
+	if len(private) != 1 || private[0].Comment != "Record an audit entry." {
+		t.Fatalf("unexpected private symbols: %+v", private)
+	}
+	if purpose := extractPythonFilePurpose(content); purpose != "Billing service." {
+		t.Fatalf("unexpected file purpose: %q", purpose)
+	}
+}
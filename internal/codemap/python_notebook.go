@@ -0,0 +1,76 @@
+package codemap
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+type pythonNotebook struct {
+	Cells []pythonNotebookCell `json:"cells"`
+}
+
+type pythonNotebookCell struct {
+	CellType string          `json:"cell_type"`
+	Source   json.RawMessage `json:"source"`
+}
+
+func isPythonNotebookPath(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".ipynb")
+}
+
+// pythonNotebookSource flattens the code cells of a Jupyter notebook into a
+// Python source buffer and returns the first markdown line as the notebook
+// purpose. Invalid notebooks yield empty source so they still count as files.
+func pythonNotebookSource(content []byte) ([]byte, string) {
+	var nb pythonNotebook
+	if err := json.Unmarshal(content, &nb); err != nil {
+		return nil, ""
+	}
+
+	var code bytes.Buffer
+	purpose := ""
+	for _, cell := range nb.Cells {
+		text := notebookCellText(cell.Source)
+		switch cell.CellType {
+		case "code":
+			if text == "" {
+				continue
+			}
+			code.WriteString(text)
+			if !strings.HasSuffix(text, "\n") {
+				code.WriteByte('\n')
+			}
+		case "markdown":
+			if purpose == "" {
+				purpose = notebookMarkdownPurpose(text)
+			}
+		}
+	}
+	return code.Bytes(), purpose
+}
+
+func notebookCellText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err == nil {
+		return strings.Join(lines, "")
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	return ""
+}
+
+func notebookMarkdownPurpose(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+		if line != "" {
+			return extractFirstSentence(line)
+		}
+	}
+	return ""
+}
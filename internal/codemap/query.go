@@ -0,0 +1,252 @@
+package codemap
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNoAnalysisCache is returned by queries when no run has persisted an
+// analysis cache for the project yet.
+var ErrNoAnalysisCache = errors.New("no analysis cache")
+
+// Query kinds accepted by Query.
+const (
+	QueryOwner   = "owner"   // Packages owning a file or directory
+	QuerySymbol  = "symbol"  // Files defining an exported type or function
+	QueryConcern = "concern" // Files matching a concern definition
+	QueryTag     = "tag"     // Packages carrying a codemap:tag marker
+)
+
+// QueryMatch is one answer of Query. Fields that do not apply to the query
+// kind are left empty.
+type QueryMatch struct {
+	Package  string   `json:"package,omitempty"`
+	Language string   `json:"language,omitempty"`
+	File     string   `json:"file,omitempty"` // Relative to the project root
+	Name     string   `json:"name,omitempty"` // Symbol name
+	Kind     string   `json:"kind,omitempty"` // Symbol kind
+	Purpose  string   `json:"purpose,omitempty"`
+	Tags     []string `json:"tags,omitempty"` // Package tags, for owner and tag queries
+}
+
+// Query answers an ad-hoc lookup from the state and analysis cache persisted
+// by the last run, without indexing, hashing or analyzing the tree, so the
+// answers reflect that run:
+//
+//   - QueryOwner: the packages owning arg, a file or directory path; the
+//     entry file of each is reported in File.
+//   - QuerySymbol: where the exported type or function named arg is defined.
+//     Caches written with Options.LowMemory carry symbols only when
+//     Options.SymbolsOutputPath was set.
+//   - QueryConcern: every file matching the concern named arg, compared
+//     case-insensitively against Options.Concerns.
+//   - QueryTag: the packages tagged arg, compared case-insensitively; the
+//     entry file of each is reported in File.
+//
+// Matches are sorted by file, then package.
+func Query(opts Options, kind, arg string) ([]QueryMatch, error) {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+
+	var cache *AnalysisCache
+	var state *CodemapState
+	switch kind {
+	case QueryOwner, QuerySymbol, QueryTag:
+		if cache, err = readAnalysisCache(resolveAnalysisStatePath(root, opts)); err != nil {
+			return nil, fmt.Errorf("read analysis cache: %w", err)
+		}
+	case QueryConcern:
+		if state, err = readState(resolveStatePath(root, opts)); err != nil {
+			return nil, fmt.Errorf("read state: %w", err)
+		}
+		// Owning packages are best effort for concern queries.
+		cache, _ = readAnalysisCache(resolveAnalysisStatePath(root, opts))
+	}
+	return queryLoaded(root, opts, kind, arg, cache, state)
+}
+
+// queryLoaded answers Query from an analysis cache and state already in
+// memory, as the daemon keeps them. Owner, symbol and tag queries need
+// cache; concern queries need state and use cache, when set, for owning
+// packages. A cache written by another version of codemap counts as missing.
+func queryLoaded(root string, opts Options, kind, arg string, cache *AnalysisCache, state *CodemapState) ([]QueryMatch, error) {
+	if cache != nil && cache.Version != analysisCacheVersion {
+		cache = nil
+	}
+	var matches []QueryMatch
+	switch kind {
+	case QueryOwner, QuerySymbol, QueryTag:
+		if cache == nil {
+			return nil, fmt.Errorf("%w at %s; run codemap first", ErrNoAnalysisCache, resolveAnalysisStatePath(root, opts))
+		}
+		switch kind {
+		case QueryOwner:
+			matches = queryOwner(cache, queryRelPath(root, arg))
+		case QuerySymbol:
+			matches = querySymbol(cache, arg)
+		default:
+			matches = queryTag(cache, arg)
+		}
+	case QueryConcern:
+		var err error
+		matches, err = queryConcern(root, opts, arg, state, cache)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown query %q (want owner, symbol, concern or tag)", kind)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].File != matches[j].File {
+			return matches[i].File < matches[j].File
+		}
+		return matches[i].Package < matches[j].Package
+	})
+	return matches, nil
+}
+
+// queryRelPath turns a query path into a slash-separated path relative to
+// root, accepting absolute paths inside root.
+func queryRelPath(root, p string) string {
+	if filepath.IsAbs(p) {
+		if rel, err := filepath.Rel(root, p); err == nil {
+			p = rel
+		}
+	}
+	return path.Clean(filepath.ToSlash(p))
+}
+
+// queryOwner returns the packages listing relPath as one of their files,
+// or else those at the closest package directory containing relPath.
+func queryOwner(cache *AnalysisCache, relPath string) []QueryMatch {
+	var owners []Package
+	pkgPaths := make(map[string]struct{}, len(cache.Packages))
+	for _, cached := range cache.Packages {
+		pkgPaths[cached.RelativePath] = struct{}{}
+		for _, file := range cached.FileRelPaths {
+			if file == relPath {
+				owners = append(owners, cached.Package)
+				break
+			}
+		}
+	}
+	if len(owners) == 0 {
+		owner, ok := relPath, false
+		if _, ok = pkgPaths[relPath]; !ok {
+			owner, ok = owningPackagePath(relPath, pkgPaths)
+		}
+		if !ok {
+			return nil
+		}
+		for _, cached := range cache.Packages {
+			if cached.RelativePath == owner {
+				owners = append(owners, cached.Package)
+			}
+		}
+	}
+
+	matches := make([]QueryMatch, 0, len(owners))
+	for _, pkg := range owners {
+		matches = append(matches, packageMatch(pkg))
+	}
+	return matches
+}
+
+// packageMatch describes pkg for owner and tag queries.
+func packageMatch(pkg Package) QueryMatch {
+	return QueryMatch{
+		Package:  pkg.RelativePath,
+		Language: pkg.Language,
+		File:     entryPath(pkg),
+		Purpose:  pkg.Purpose,
+		Tags:     pkg.Tags,
+	}
+}
+
+// queryTag returns the packages carrying tag.
+func queryTag(cache *AnalysisCache, tag string) []QueryMatch {
+	var matches []QueryMatch
+	for _, cached := range cache.Packages {
+		for _, t := range cached.Package.Tags {
+			if strings.EqualFold(t, tag) {
+				matches = append(matches, packageMatch(cached.Package))
+				break
+			}
+		}
+	}
+	return matches
+}
+
+func querySymbol(cache *AnalysisCache, name string) []QueryMatch {
+	var matches []QueryMatch
+	for _, cached := range cache.Packages {
+		pkg := cached.Package
+		for _, sym := range pkg.Symbols {
+			if sym.Name != name {
+				continue
+			}
+			matches = append(matches, QueryMatch{
+				Package:  pkg.RelativePath,
+				Language: pkg.Language,
+				File:     path.Join(packageFileDir(pkg.RelativePath), sym.File),
+				Name:     sym.Name,
+				Kind:     sym.Kind,
+			})
+		}
+	}
+	return matches
+}
+
+// queryConcern matches the concern's patterns against the files recorded in
+// state, so every matching file is listed rather than the examples kept in
+// the cached concerns. Owning packages come from cache when one exists.
+func queryConcern(root string, opts Options, name string, state *CodemapState, cache *AnalysisCache) ([]QueryMatch, error) {
+	var def *ConcernDef
+	var names []string
+	for i := range opts.Concerns {
+		names = append(names, opts.Concerns[i].Name)
+		if strings.EqualFold(opts.Concerns[i].Name, name) {
+			def = &opts.Concerns[i]
+			break
+		}
+	}
+	if def == nil {
+		return nil, fmt.Errorf("unknown concern %q (have %s)", name, strings.Join(names, ", "))
+	}
+
+	if state == nil {
+		return nil, fmt.Errorf("%w at %s; run codemap first", ErrNoAnalysisCache, resolveStatePath(root, opts))
+	}
+
+	var matchers []concernMatcher
+	for _, pattern := range def.Patterns {
+		if matcher, err := compileConcernPattern(pattern); err == nil {
+			matchers = append(matchers, matcher)
+		}
+	}
+	pkgPaths := make(map[string]struct{})
+	if cache != nil {
+		for _, cached := range cache.Packages {
+			pkgPaths[cached.RelativePath] = struct{}{}
+		}
+	}
+
+	var matches []QueryMatch
+	for _, entry := range state.Entries {
+		for _, matcher := range matchers {
+			if matcher.matches(entry.RelPath) {
+				owner, _ := owningPackagePath(entry.RelPath, pkgPaths)
+				matches = append(matches, QueryMatch{Package: owner, Language: entry.Language, File: entry.RelPath, Name: def.Name})
+				break
+			}
+		}
+	}
+	return matches, nil
+}
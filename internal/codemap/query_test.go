@@ -0,0 +1,77 @@
+package codemap
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestQueryReadsAnalysisCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                   "module example.com/app\n\ngo 1.22\n",
+		"cmd/app/main.go":          "// Command app runs the server.\npackage main\n\nfunc main() {}\n",
+		"internal/store/store.go":  "// Package store persists records.\npackage store\n\ntype Store struct{}\n\nfunc NewStore() *Store { return nil }\n",
+		"internal/store/errors.go": "package store\n\ntype NotFoundError struct{}\n",
+		"internal/store/db/db.go":  "// Package db opens connections.\n//\n// codemap:tag=storage,critical\npackage db\n\nfunc Open() {}\n",
+		"web/package.json":         "{\"name\": \"web\"}\n",
+		"web/app.ts":               "// codemap:tag=Storage\nexport function load() {}\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	if _, err := Query(opts, QueryOwner, "cmd/app/main.go"); !errors.Is(err, ErrNoAnalysisCache) {
+		t.Fatalf("expected ErrNoAnalysisCache before the first run, got %v", err)
+	}
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+
+	owner, err := Query(opts, QueryOwner, filepath.Join(tmpDir, "internal", "store", "errors.go"))
+	if err != nil {
+		t.Fatalf("owner query returned error: %v", err)
+	}
+	wantOwner := []QueryMatch{{Package: "internal/store", Language: "go", File: "internal/store/store.go", Purpose: "Package store persists records."}}
+	if !reflect.DeepEqual(owner, wantOwner) {
+		t.Fatalf("unexpected owner:\n got %+v\nwant %+v", owner, wantOwner)
+	}
+	if owner, err := Query(opts, QueryOwner, "internal/store/db"); err != nil || len(owner) != 1 || owner[0].Package != "internal/store/db" {
+		t.Fatalf("expected the db package to own its directory, got %+v (%v)", owner, err)
+	}
+
+	symbol, err := Query(opts, QuerySymbol, "NewStore")
+	if err != nil {
+		t.Fatalf("symbol query returned error: %v", err)
+	}
+	wantSymbol := []QueryMatch{{Package: "internal/store", Language: "go", File: "internal/store/store.go", Name: "NewStore", Kind: "func"}}
+	if !reflect.DeepEqual(symbol, wantSymbol) {
+		t.Fatalf("unexpected symbol:\n got %+v\nwant %+v", symbol, wantSymbol)
+	}
+
+	tagged, err := Query(opts, QueryTag, "storage")
+	if err != nil {
+		t.Fatalf("tag query returned error: %v", err)
+	}
+	wantTagged := []QueryMatch{
+		{Package: "internal/store/db", Language: "go", File: "internal/store/db/db.go", Purpose: "Package db opens connections.", Tags: []string{"critical", "storage"}},
+		{Package: "web", Language: "typescript", File: "web/app.ts", Purpose: "TypeScript package web", Tags: []string{"Storage"}},
+	}
+	if !reflect.DeepEqual(tagged, wantTagged) {
+		t.Fatalf("unexpected tagged packages:\n got %+v\nwant %+v", tagged, wantTagged)
+	}
+
+	concern, err := Query(opts, QueryConcern, "error handling")
+	if err != nil {
+		t.Fatalf("concern query returned error: %v", err)
+	}
+	wantConcern := []QueryMatch{{Package: "internal/store", Language: "go", File: "internal/store/errors.go", Name: "Error Handling"}}
+	if !reflect.DeepEqual(concern, wantConcern) {
+		t.Fatalf("unexpected concern:\n got %+v\nwant %+v", concern, wantConcern)
+	}
+	if _, err := Query(opts, QueryConcern, "nope"); err == nil {
+		t.Fatal("expected an error for an unknown concern")
+	}
+}
@@ -0,0 +1,102 @@
+package codemap
+
+import (
+	"sort"
+	"sync"
+)
+
+// Analyzers and renderers registered by embedding programs. They apply to
+// every run in the process.
+var (
+	registeredMu        sync.RWMutex
+	registeredAnalyzers = map[string]LanguageAnalyzer{}
+	registeredRenderers = map[string]Renderer{}
+)
+
+// LanguageSpecProvider is implemented by registered analyzers for languages
+// codemap has no built-in support for, to say which files belong to them.
+// Without it such an analyzer is never given any files.
+type LanguageSpecProvider interface {
+	LanguageSpec() LanguageSpec
+}
+
+// RegisterAnalyzer adds analyzer to DefaultAnalyzerRegistry and so to every
+// run in this process, replacing the built-in analyzer or an earlier
+// registration for the same language ID. Analyzers for new languages should
+// implement LanguageSpecProvider so their files are indexed.
+func RegisterAnalyzer(analyzer LanguageAnalyzer) {
+	if analyzer == nil {
+		return
+	}
+	id := analyzer.LanguageID()
+	if id == "" {
+		return
+	}
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	registeredAnalyzers[id] = analyzer
+}
+
+// RegisterRenderer adds renderer to every generation in this process. Its
+// output is written to renderer.DefaultPath(), relative to the project root
+// unless absolute, next to the built-in outputs and is regenerated whenever
+// they are. A later registration with the same Name replaces an earlier one.
+func RegisterRenderer(renderer Renderer) {
+	if renderer == nil || renderer.Name() == "" || renderer.DefaultPath() == "" {
+		return
+	}
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	registeredRenderers[renderer.Name()] = renderer
+}
+
+// registeredAnalyzerList returns registered analyzers sorted by language ID.
+func registeredAnalyzerList() []LanguageAnalyzer {
+	registeredMu.RLock()
+	defer registeredMu.RUnlock()
+	ids := make([]string, 0, len(registeredAnalyzers))
+	for id := range registeredAnalyzers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	analyzers := make([]LanguageAnalyzer, 0, len(ids))
+	for _, id := range ids {
+		analyzers = append(analyzers, registeredAnalyzers[id])
+	}
+	return analyzers
+}
+
+// registeredRendererList returns registered renderers sorted by name.
+func registeredRendererList() []Renderer {
+	registeredMu.RLock()
+	defer registeredMu.RUnlock()
+	names := make([]string, 0, len(registeredRenderers))
+	for name := range registeredRenderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	renderers := make([]Renderer, 0, len(names))
+	for _, name := range names {
+		renderers = append(renderers, registeredRenderers[name])
+	}
+	return renderers
+}
+
+// registeredLanguageSpecs returns the specs of registered analyzers for
+// languages without a built-in spec.
+func registeredLanguageSpecs() []LanguageSpec {
+	var specs []LanguageSpec
+	for _, analyzer := range registeredAnalyzerList() {
+		provider, ok := analyzer.(LanguageSpecProvider)
+		if !ok {
+			continue
+		}
+		spec := provider.LanguageSpec()
+		spec.ID = analyzer.LanguageID()
+		if _, builtin := builtinLanguageSpecs[spec.ID]; builtin || len(spec.FileSuffixes) == 0 {
+			continue
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
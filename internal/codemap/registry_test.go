@@ -0,0 +1,85 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type toyAnalyzer struct{}
+
+func (toyAnalyzer) LanguageID() string { return "toy" }
+
+func (toyAnalyzer) LanguageSpec() LanguageSpec {
+	return LanguageSpec{FileSuffixes: []string{".toy"}}
+}
+
+func (toyAnalyzer) Analyze(_ context.Context, in AnalysisInput) (*Codemap, error) {
+	cm := &Codemap{ProjectRoot: in.Root}
+	for _, rec := range in.Index.Files {
+		if rec.Language == "toy" {
+			cm.Packages = append(cm.Packages, Package{RelativePath: ".", EntryPoint: rec.RelPath, FileCount: 1})
+		}
+	}
+	return cm, nil
+}
+
+type packageCountRenderer struct{}
+
+func (packageCountRenderer) Name() string        { return "count" }
+func (packageCountRenderer) DefaultPath() string { return "CODEMAP.count" }
+func (packageCountRenderer) Render(cm *Codemap) (string, error) {
+	return fmt.Sprintf("packages: %d\n", len(cm.Packages)), nil
+}
+
+func TestRegisteredAnalyzersAndRenderersJoinEveryRun(t *testing.T) {
+	RegisterAnalyzer(toyAnalyzer{})
+	RegisterRenderer(packageCountRenderer{})
+	t.Cleanup(func() {
+		registeredMu.Lock()
+		delete(registeredAnalyzers, "toy")
+		delete(registeredRenderers, "count")
+		registeredMu.Unlock()
+	})
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "game.toy"), []byte("toy\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	var toy *Package
+	for i := range cm.Packages {
+		if cm.Packages[i].Language == "toy" {
+			toy = &cm.Packages[i]
+		}
+	}
+	if toy == nil || toy.EntryPoint != "game.toy" {
+		t.Fatalf("expected the registered analyzer's package, got %+v", cm.Packages)
+	}
+
+	countPath := filepath.Join(tmpDir, "CODEMAP.count")
+	content, err := os.ReadFile(countPath)
+	if err != nil || string(content) != "packages: 2\n" {
+		t.Fatalf("unexpected registered output %q (err %v)", content, err)
+	}
+	if stale, err := IsStale(context.Background(), opts); err != nil || stale {
+		t.Fatalf("expected fresh outputs, got stale=%v err=%v", stale, err)
+	}
+	if err := os.Remove(countPath); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+		t.Fatalf("expected a missing registered output to be stale, got stale=%v err=%v", stale, err)
+	}
+}
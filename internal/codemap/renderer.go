@@ -4,29 +4,198 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
+	"unicode/utf8"
 )
 
 const codemapTemplate = `<!-- codemap-hash: {{.ContentHash}} -->
 <!-- Generated: {{.GeneratedAt.Format "2006-01-02 15:04:05 UTC"}} -->
-<!-- Regenerate: codemap -->
+{{with .IndexMode}}<!-- codemap-index: files={{$.HashedFiles}} mode={{.}} -->
+{{end}}<!-- Regenerate: codemap -->
 
 # Codemap
-
+{{range .Warnings}}
+> Warning: {{.}}
+{{end}}
 Prefer ` + "`CODEMAP.paths`" + ` for the most token-efficient routing to the files agents should open/edit.
 
-## Package Entry Points
+{{with tableOfContents}}## Contents
+{{range .}}
+- [{{.Title}}](#{{.Anchor}})
+{{- end}}
+
+{{end}}{{if .Repos}}## Repositories
+
+| Repo | Packages | Depends On |
+|------|----------|------------|
+{{- range .Repos}}
+| {{.Name}} | {{.PackageCount}} | {{join .DependsOn ", "}} |
+{{- end}}
+
+{{end}}{{if .Services}}## Services
+
+| Service | Path | Entry File | Config | Owners |
+|---------|------|------------|--------|--------|
+{{- range .Services}}
+| {{.Name}} | {{.Path}} | {{.EntryPoint}} | {{.Manifest}}{{range .Config}}, {{.}}{{end}} | {{join .Owners ", "}} |
+{{- end}}
+
+{{end}}{{if .Binaries}}## Binaries / Entry Points
+
+| Binary | Language | Entry | Declared By |
+|--------|----------|-------|-------------|
+{{- range .Binaries}}
+| {{.Name}} | {{.Language}} | {{.Entry}} | {{.Source}} |
+{{- end}}
+
+{{end}}{{if .Toolchains}}## Toolchains
+
+| Language | Version | Source |
+|----------|---------|--------|
+{{- range .Toolchains}}
+| {{.Language}} | {{.Version}}{{with .Toolchain}} (toolchain {{.}}){{end}} | {{.Source}} |
+{{- end}}
+
+{{end}}{{if .Migrations}}## Data / Migrations
+
+| Directory | Tool | Migrations | Latest | Referenced By |
+|-----------|------|------------|--------|---------------|
+{{- range .Migrations}}
+| {{.Path}} | {{.Tool}} | {{.Count}} | {{.Latest}} | {{join .ReferencedBy ", "}} |
+{{- end}}
+
+{{end}}{{if .Endpoints}}## HTTP Endpoints
+
+| Method | Path | Handler | Handler File | Source |
+|--------|------|---------|--------------|--------|
+{{- range .Endpoints}}
+| {{.Method}} | {{.Path}} | {{.Handler}} | {{.HandlerFile}} | {{join .Sources ", "}} |
+{{- end}}
+
+{{end}}## Package Entry Points
+{{$tests := hasPackageTests .Packages}}{{$owners := hasPackageOwners .Packages}}
+| Package | Entry File | Purpose |{{if $tests}} Tests |{{end}}{{if $owners}} Owners |{{end}}
+|---------|------------|---------|{{if $tests}}-------|{{end}}{{if $owners}}--------|{{end}}
+{{- range $i, $pkg := .Packages}}
+| {{with packageAnchor $i}}<a id="{{.}}"></a>{{end}}{{with packagePage $i}}[{{$pkg.RelativePath}}]({{.}}){{else}}{{$pkg.RelativePath}}{{end}} | {{entryPath .}} | {{truncatePurpose .Purpose}}{{with .GeneratedFrom}} (Generated from {{join . ", "}}){{end}} |{{if $tests}} {{len .TestFiles}} |{{end}}{{if $owners}} {{join .Owners ", "}} |{{end}}
+{{- end}}
+
+{{with tagIndex .Packages}}## Tags
+
+| Tag | Packages |
+|-----|----------|
+{{- range .}}
+| {{.Name}} | {{join .Packages ", "}} |
+{{- end}}
+
+{{end}}{{with fileRoleRows .Packages}}## Large Package Files
+
+| Package | Role | Files |
+|---------|------|-------|
+{{- range .}}
+| {{.Package}} | {{.Role}} | {{join .Files ", "}} |
+{{- end}}
+
+{{end}}{{if hasCompanions .Packages}}## Entry Companions
+
+| Entry File | Usually Changes With |
+|------------|----------------------|
+{{- range .Packages}}{{if .Companions}}
+| {{entryPath .}} | {{join (companionPaths .) ", "}} |
+{{- end}}{{end}}
+
+{{end}}{{if hasPlatformVariants .Packages}}## Platform Variants
+
+| Package | Variants |
+|---------|----------|
+{{- range .Packages}}{{if .PlatformFiles}}
+| {{.RelativePath}} | {{platformSummary .}} |
+{{- end}}{{end}}
+
+{{end}}{{with dependencyRows .Packages}}## Dependency Graph
+
+| Package | Depends On |
+|---------|------------|
+{{- range .}}
+| {{.Name}} | {{join .Packages ", "}} |
+{{- end}}
+
+{{end}}{{with architectureHealth .Packages}}## Architecture Health
+
+Dependency layers: {{.Layers}} (layer 0 imports no project package)
+{{if .Cycles}}
+| Import Cycle |
+|--------------|
+{{- range .Cycles}}
+| {{join . ", "}} |
+{{- end}}
+{{end}}{{if .Hubs}}
+| Most Depended-On | Dependents | Layer |
+|------------------|------------|-------|
+{{- range .Hubs}}
+| {{.Name}} | {{.Dependents}} | {{.Layer}} |
+{{- end}}
+{{end}}
+{{end}}{{with coChangeRows .Packages}}## Frequently Changed Together
+
+| Package | Changes With |
+|---------|--------------|
+{{- range .}}
+| {{.Name}} | {{join .Packages ", "}} |
+{{- end}}
+
+{{end}}{{with securityRows .Packages}}## Security-Sensitive Packages
+
+| Package | Signals |
+|---------|---------|
+{{- range .}}
+| {{.Name}} ` + "`security-sensitive`" + ` | {{join .Signals ", "}} |
+{{- end}}
+
+{{end}}{{with externalModuleRows .Packages}}## External Go Modules
 
-| Package | Entry File | Purpose |
-|---------|------------|---------|
-{{- range .Packages}}
-| {{.RelativePath}} | {{entryPath .}} | {{truncate .Purpose 60}} |
+| Module | Packages | Import Paths |
+|--------|----------|--------------|
+{{- range .}}
+| {{.Module}} | {{.Packages}} | {{.ImportPaths}} |
 {{- end}}
 
-{{if .Concerns}}
+{{end}}{{with apiSurface}}## API Surface
+{{range .}}
+### {{.RelativePath}}
+
+` + "```" + `{{codeFence .Language}}
+{{- range .Funcs}}
+{{with .Comment}}// {{.}}
+{{end}}{{.Signature}}
+{{- end}}
+` + "```" + `
+{{end}}
+{{end}}{{with .Statistics}}## Statistics
+
+| Language | Packages | Files | Lines | Comments | Test Files | Test Lines | Test/Prod |
+|----------|----------|-------|-------|----------|------------|------------|-----------|
+{{- range .Languages}}
+| {{.Language}} | {{.Packages}} | {{.Files}} | {{.Lines}} | {{commentShare .LineStats}} | {{.TestFiles}} | {{.TestLines}} | {{testRatio .LineStats}} |
+{{- end}}
+{{- if gt (len .Languages) 1}}{{with .Total}}
+| {{.Language}} | {{.Packages}} | {{.Files}} | {{.Lines}} | {{commentShare .LineStats}} | {{.TestFiles}} | {{.TestLines}} | {{testRatio .LineStats}} |
+{{- end}}{{end}}
+{{with .LargestFiles}}
+Largest files: {{range $i, $f := .}}{{if $i}}, {{end}}{{$f.Path}} ({{$f.Lines}}){{end}}
+{{end}}{{with packageStatsRows $.Packages}}
+| Package | Language | Files | Lines | Comments | Test Lines | Largest File |
+|---------|----------|-------|-------|----------|------------|--------------|
+{{- range .}}
+| {{.RelativePath}} | {{.Language}} | {{.Stats.Files}} | {{.Stats.Lines}} | {{commentShare .Stats.LineStats}} | {{.Stats.TestLines}} | {{with .Stats.LargestFile}}{{if .Path}}{{.Path}} ({{.Lines}}){{end}}{{end}} |
+{{- end}}
+{{end}}
+{{end}}{{if .Concerns}}
 
 ## Concerns (Summary)
 
@@ -35,18 +204,99 @@ Prefer ` + "`CODEMAP.paths`" + ` for the most token-efficient routing to the fil
 {{- range .Concerns}}
 | {{.Name}} | {{.TotalFiles}} |
 {{- end}}
-
+{{if concernDetails .Concerns}}{{range .Concerns}}{{if or .Note .Files}}
+- {{.Name}}{{with .Note}}: {{.}}{{end}}{{with .Files}} (e.g. {{join . ", "}}){{end}}
+{{- end}}{{end}}
+{{end}}
 {{end}}
 `
 
+const (
+	defaultMarkdownPurposeLength = 60
+	defaultPathsPurposeLength    = 80
+)
+
 // Render generates the CODEMAP.md content.
 func Render(cm *Codemap) (string, error) {
+	return renderMarkdown(cm, MarkdownRenderer{}, codemapTemplate)
+}
+
+// newMarkdownRenderer returns the CODEMAP.md renderer configured by opts,
+// resolving a relative Options.MarkdownTemplate against the project root and
+// Options.SplitOutputDir against the markdown output's directory.
+func newMarkdownRenderer(opts Options) MarkdownRenderer {
+	r := MarkdownRenderer{PurposeLength: opts.MarkdownPurposeLength, TOC: opts.MarkdownTOC, APISurface: opts.APISurface, Template: opts.MarkdownTemplate}
+	if r.Template != "" && !filepath.IsAbs(r.Template) {
+		r.Template = filepath.Join(opts.ProjectRoot, r.Template)
+	}
+	if opts.SplitOutputDir != "" {
+		if opts.OutputPath == "" {
+			opts.OutputPath = r.DefaultPath()
+		}
+		r.PageDir = relativeLink(opts.ProjectRoot, opts.OutputPath, opts.SplitOutputDir)
+	}
+	return r
+}
+
+// renderMarkdown executes layout, codemapTemplate or a user template, on cm
+// with the helper funcs available to both.
+func renderMarkdown(cm *Codemap, r MarkdownRenderer, layout string) (string, error) {
+	purposeLength := r.PurposeLength
+	if purposeLength <= 0 {
+		purposeLength = defaultMarkdownPurposeLength
+	}
+	anchors := packageAnchorIDs(cm.Packages)
+	var pages []string
+	if r.PageDir != "" {
+		pages = packagePageNames(cm.Packages)
+	}
 	funcMap := template.FuncMap{
-		"truncate":  truncate,
-		"entryPath": entryPath,
+		"tableOfContents": func() []tocEntry {
+			if !r.TOC {
+				return nil
+			}
+			return markdownSections(cm, r)
+		},
+		"packageAnchor": func(i int) string { return anchors[i] },
+		"truncate":      truncate,
+		"truncatePurpose": func(s string) string {
+			return truncate(s, purposeLength)
+		},
+		"packagePage": func(i int) string {
+			if pages == nil {
+				return ""
+			}
+			return path.Join(r.PageDir, pages[i])
+		},
+		"entryPath":           entryPath,
+		"join":                strings.Join,
+		"tagIndex":            PackageTagIndex,
+		"companionPaths":      companionPaths,
+		"hasCompanions":       hasCompanions,
+		"coChangeRows":        coChangeRows,
+		"dependencyRows":      dependencyRows,
+		"architectureHealth":  architectureHealth,
+		"fileRoleRows":        fileRoleRows,
+		"concernDetails":      concernDetails,
+		"externalModuleRows":  externalModuleRows,
+		"securityRows":        securityRows,
+		"platformSummary":     platformSummary,
+		"hasPlatformVariants": hasPlatformVariants,
+		"hasPackageOwners":    hasPackageOwners,
+		"hasPackageTests":     hasPackageTests,
+		"commentShare":        commentShare,
+		"testRatio":           testRatio,
+		"packageStatsRows":    packageStatsRows,
+		"codeFence":           codeFence,
+		"apiSurface": func() []Package {
+			if !r.APISurface {
+				return nil
+			}
+			return apiSurfacePackages(cm.Packages)
+		},
 	}
 
-	tmpl, err := template.New("codemap").Funcs(funcMap).Parse(codemapTemplate)
+	tmpl, err := template.New("codemap").Funcs(funcMap).Parse(layout)
 	if err != nil {
 		return "", fmt.Errorf("parse template: %w", err)
 	}
@@ -60,6 +310,13 @@ func Render(cm *Codemap) (string, error) {
 }
 
 func RenderPaths(cm *Codemap) string {
+	return renderPaths(cm, defaultPathsPurposeLength)
+}
+
+func renderPaths(cm *Codemap, purposeLength int) string {
+	if purposeLength <= 0 {
+		purposeLength = defaultPathsPurposeLength
+	}
 	var sb strings.Builder
 	sb.WriteString("# codemap-hash: ")
 	sb.WriteString(cm.ContentHash)
@@ -67,16 +324,34 @@ func RenderPaths(cm *Codemap) string {
 	sb.WriteString("# Generated: ")
 	sb.WriteString(cm.GeneratedAt.Format("2006-01-02 15:04:05 UTC"))
 	sb.WriteString("\n")
+	if cm.IndexMode != "" {
+		fmt.Fprintf(&sb, "# codemap-index: files=%d mode=%s\n", cm.HashedFiles, cm.IndexMode)
+	}
 	sb.WriteString("# Regenerate: codemap\n")
-	sb.WriteString("# Format: <package>\\t<entry_file>\\t[purpose]\n")
+	withOwners := hasPackageOwners(cm.Packages)
+	if withOwners {
+		sb.WriteString("# Format: <package>\\t<entry_file>\\t<purpose>\\t<owners>\n")
+	} else {
+		sb.WriteString("# Format: <package>\\t<entry_file>\\t[purpose]\n")
+	}
+	for _, warning := range cm.Warnings {
+		sb.WriteString("# Warning: ")
+		sb.WriteString(warning)
+		sb.WriteString("\n")
+	}
 
 	for _, pkg := range cm.Packages {
 		sb.WriteString(pkg.RelativePath)
 		sb.WriteString("\t")
 		sb.WriteString(entryPath(pkg))
-		if purpose := strings.TrimSpace(pkg.Purpose); purpose != "" {
+		purpose := strings.TrimSpace(pkg.Purpose)
+		if purpose != "" || withOwners {
+			sb.WriteString("\t")
+			sb.WriteString(truncate(purpose, purposeLength))
+		}
+		if withOwners {
 			sb.WriteString("\t")
-			sb.WriteString(truncate(purpose, 80))
+			sb.WriteString(strings.Join(pkg.Owners, " "))
 		}
 		sb.WriteString("\n")
 	}
@@ -84,6 +359,22 @@ func RenderPaths(cm *Codemap) string {
 	return sb.String()
 }
 
+// RenderPathsMini generates a minimal CODEMAP.paths with a single hash header
+// and one "package\tentry" row per package.
+func RenderPathsMini(cm *Codemap) string {
+	var sb strings.Builder
+	sb.WriteString("# codemap-hash: ")
+	sb.WriteString(cm.ContentHash)
+	sb.WriteString("\n")
+	for _, pkg := range cm.Packages {
+		sb.WriteString(pkg.RelativePath)
+		sb.WriteString("\t")
+		sb.WriteString(entryPath(pkg))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
 // EnsureUpToDate generates outputs only if they're stale.
 func EnsureUpToDate(ctx context.Context, opts Options) (*Codemap, bool, error) {
 	root, err := filepath.Abs(opts.ProjectRoot)
@@ -91,14 +382,7 @@ func EnsureUpToDate(ctx context.Context, opts Options) (*Codemap, bool, error) {
 		return nil, false, fmt.Errorf("resolve root: %w", err)
 	}
 
-	markdownRenderer := MarkdownRenderer{}
-	pathsRenderer := PathsRenderer{}
-	if opts.OutputPath == "" {
-		opts.OutputPath = markdownRenderer.DefaultPath()
-	}
-	if opts.PathsOutputPath == "" {
-		opts.PathsOutputPath = pathsRenderer.DefaultPath()
-	}
+	opts = withDefaultOutputPaths(opts)
 
 	statePath := resolveStatePath(root, opts)
 	state, err := readState(statePath)
@@ -110,19 +394,29 @@ func EnsureUpToDate(ctx context.Context, opts Options) (*Codemap, bool, error) {
 	pathsPath := filepath.Join(root, opts.PathsOutputPath)
 	ignoredRootEntries := ignoredRootEntryNames(root, opts)
 
-	existingHash, err := ReadExistingHash(outputPath)
+	existingHash, err := readExistingHash(outputPath, opts.HashScanLines)
 	if err != nil {
 		return nil, false, fmt.Errorf("read existing hash: %w", err)
 	}
 	var existingPathsHash string
 	if !opts.DisablePaths {
-		existingPathsHash, err = ReadExistingHash(pathsPath)
+		existingPathsHash, err = readExistingHash(pathsPath, opts.HashScanLines)
 		if err != nil {
 			return nil, false, fmt.Errorf("read existing paths hash: %w", err)
 		}
 	}
+	if stale, err := secondaryOutputsStale(root, opts, existingHash); err != nil {
+		return nil, false, err
+	} else if stale || renderInputsChanged(root, opts, state, existingHash) {
+		// Treat the markdown hash as unknown so every up-to-date check below fails.
+		existingHash = ""
+	}
 
-	idx, unchangedFromState, err := buildFileIndexFromState(ctx, root, state, ignoredRootEntries)
+	fastState := state
+	if !indexStateMatches(state, opts) {
+		fastState = nil
+	}
+	idx, unchangedFromState, err := indexFromState(ctx, root, opts, fastState, ignoredRootEntries)
 	if err != nil {
 		return nil, false, fmt.Errorf("build file index from state: %w", err)
 	}
@@ -152,11 +446,11 @@ func EnsureUpToDate(ctx context.Context, opts Options) (*Codemap, bool, error) {
 				return nil, false, nil
 			}
 		}
-		return generateOutputs(ctx, root, opts, outputPath, pathsPath, statePath, state, nextState, currentHash, idx, markdownRenderer, pathsRenderer)
+		return generateOutputs(ctx, root, opts, statePath, state, nextState, currentHash, idx)
 	}
 
 	// Fallback warm fast-path: if filesystem metadata still matches cached state, avoid full index/hash work.
-	currentHash, matchedFromState, err := aggregateHashFromFilesystemState(ctx, root, state, ignoredRootEntries)
+	currentHash, matchedFromState, err := aggregateHashFromFilesystemState(ctx, root, fastState, ignoredRootEntries)
 	if err != nil {
 		return nil, false, fmt.Errorf("verify state: %w", err)
 	}
@@ -168,7 +462,7 @@ func EnsureUpToDate(ctx context.Context, opts Options) (*Codemap, bool, error) {
 		}
 	}
 
-	idx, err = BuildFileIndex(ctx, root)
+	idx, err = buildIndex(ctx, root, opts)
 	if err != nil {
 		return nil, false, fmt.Errorf("build file index: %w", err)
 	}
@@ -181,59 +475,31 @@ func EnsureUpToDate(ctx context.Context, opts Options) (*Codemap, bool, error) {
 			return nil, false, nil
 		}
 	}
-	return generateOutputs(ctx, root, opts, outputPath, pathsPath, statePath, state, nextState, currentHash, idx, markdownRenderer, pathsRenderer)
+	return generateOutputs(ctx, root, opts, statePath, state, nextState, currentHash, idx)
 }
 
-func generateOutputs(
-	ctx context.Context,
-	root string,
-	opts Options,
-	outputPath string,
-	pathsPath string,
-	statePath string,
-	state *CodemapState,
-	nextState *CodemapState,
-	currentHash string,
-	idx *FileIndex,
-	markdownRenderer MarkdownRenderer,
-	pathsRenderer PathsRenderer,
-) (*Codemap, bool, error) {
-	analysisPath := resolveAnalysisStatePath(root, opts)
-	analysisCache, err := readAnalysisCache(analysisPath)
+func generateOutputs(ctx context.Context, root string, opts Options, statePath string, state, nextState *CodemapState, currentHash string, idx *FileIndex) (*Codemap, bool, error) {
+	prevState, err := withAnalysisCache(root, opts, state)
 	if err != nil {
-		return nil, false, fmt.Errorf("read analysis cache: %w", err)
+		return nil, false, err
 	}
-	prevState := mergeStateWithAnalysis(state, analysisCache)
-
-	cm, err := AnalyzeWithRegistry(ctx, AnalysisInput{
+	cm, err := analyzeModel(ctx, AnalysisInput{
 		Root:      root,
 		Index:     idx,
 		Options:   opts,
 		PrevState: prevState,
 		NextState: nextState,
-	}, DefaultAnalyzerRegistry())
+	}, currentHash)
 	if err != nil {
 		return nil, false, fmt.Errorf("analyze: %w", err)
 	}
-
-	cm.ContentHash = currentHash
-	cm.GeneratedAt = time.Now().UTC()
-
-	if err := writeRenderedOutput(outputPath, markdownRenderer, cm); err != nil {
+	if err := renderOutputs(root, opts, nextState, cm); err != nil {
 		return nil, false, err
 	}
-	if !opts.DisablePaths {
-		if err := writeRenderedOutput(pathsPath, pathsRenderer, cm); err != nil {
-			return nil, false, err
-		}
-	}
-	if err := writeState(statePath, nextState); err != nil {
-		return nil, false, fmt.Errorf("write state: %w", err)
-	}
-	if err := writeAnalysisCache(analysisPath, nextState.Analysis); err != nil {
-		return nil, false, fmt.Errorf("write analysis cache: %w", err)
+	nextState.Outputs = trackedOutputs(root, opts, state)
+	if err := saveState(ctx, root, opts, statePath, nextState); err != nil {
+		return nil, false, err
 	}
-
 	return cm, true, nil
 }
 
@@ -243,71 +509,118 @@ func Generate(ctx context.Context, opts Options) (*Codemap, error) {
 	if err != nil {
 		return nil, fmt.Errorf("resolve root: %w", err)
 	}
+	opts = withDefaultOutputPaths(opts)
 
-	markdownRenderer := MarkdownRenderer{}
-	pathsRenderer := PathsRenderer{}
-	if opts.OutputPath == "" {
-		opts.OutputPath = markdownRenderer.DefaultPath()
-	}
-	if opts.PathsOutputPath == "" {
-		opts.PathsOutputPath = pathsRenderer.DefaultPath()
-	}
-
-	idx, err := BuildFileIndex(ctx, root)
+	idx, err := buildIndex(ctx, root, opts)
 	if err != nil {
 		return nil, fmt.Errorf("build file index: %w", err)
 	}
-
 	statePath := resolveStatePath(root, opts)
 	state, err := readState(statePath)
 	if err != nil {
 		return nil, fmt.Errorf("read state: %w", err)
 	}
-
-	analysisPath := resolveAnalysisStatePath(root, opts)
-	analysisCache, err := readAnalysisCache(analysisPath)
-	if err != nil {
-		return nil, fmt.Errorf("read analysis cache: %w", err)
-	}
-
 	hash, nextState, err := computeAggregateHash(ctx, idx, state)
 	if err != nil {
 		return nil, fmt.Errorf("compute hash: %w", err)
 	}
+	cm, _, err := generateOutputs(ctx, root, opts, statePath, state, nextState, hash, idx)
+	return cm, err
+}
 
-	prevState := mergeStateWithAnalysis(state, analysisCache)
-	cm, err := AnalyzeWithRegistry(ctx, AnalysisInput{
-		Root:      root,
-		Index:     idx,
-		Options:   opts,
-		PrevState: prevState,
-		NextState: nextState,
-	}, DefaultAnalyzerRegistry())
+// withDefaultOutputPaths fills in the markdown and paths output paths when
+// opts leaves them empty.
+func withDefaultOutputPaths(opts Options) Options {
+	if opts.OutputPath == "" {
+		opts.OutputPath = newMarkdownRenderer(opts).DefaultPath()
+	}
+	if opts.PathsOutputPath == "" {
+		opts.PathsOutputPath = PathsRenderer{}.DefaultPath()
+	}
+	return opts
+}
+
+// withAnalysisCache returns state with the analysis cache stored beside it
+// attached, as the previous state analyzers reuse packages from.
+func withAnalysisCache(root string, opts Options, state *CodemapState) (*CodemapState, error) {
+	analysisCache, err := readAnalysisCache(resolveAnalysisStatePath(root, opts))
 	if err != nil {
-		return nil, fmt.Errorf("analyze: %w", err)
+		return nil, fmt.Errorf("read analysis cache: %w", err)
 	}
+	return mergeStateWithAnalysis(state, analysisCache), nil
+}
 
+// analyzeModel analyzes in and stamps the model with hash and the index
+// details every output shares. A resource guard for in.Options is attached
+// unless ctx already carries one, so several analyses can share a budget.
+func analyzeModel(ctx context.Context, in AnalysisInput, hash string) (*Codemap, error) {
+	guard := resourceGuardFromContext(ctx)
+	if guard == nil {
+		ctx, guard = withResourceGuard(ctx, in.Options)
+	}
+	cm, err := AnalyzeWithRegistry(ctx, in, analyzerRegistryFor(in.Options))
+	if err != nil {
+		return nil, err
+	}
 	cm.ContentHash = hash
-	cm.GeneratedAt = time.Now().UTC()
+	cm.GeneratedAt = generationTime()
+	cm.IndexMode = indexModeName(in.Index)
+	cm.HashedFiles = len(in.Index.Files)
+	applyResourceWarning(cm, guard)
+	return cm, nil
+}
 
-	outputPath := filepath.Join(root, opts.OutputPath)
-	if err := writeRenderedOutput(outputPath, markdownRenderer, cm); err != nil {
-		return nil, err
+// outputView returns the model the markdown and paths outputs render for
+// opts: cm itself, or a trimmed copy when it exceeds opts.MaxOutputTokens.
+func outputView(cm *Codemap, opts Options) (*Codemap, error) {
+	markdownRenderer := newMarkdownRenderer(opts)
+	pathsRenderer := PathsRenderer{Mini: opts.PathsMini, PurposeLength: opts.PathsPurposeLength}
+	return applyTokenBudget(cm, opts, outputRenderers(opts, markdownRenderer, pathsRenderer)...)
+}
+
+// renderOutputs writes every output opts enables for cm: the markdown and
+// paths outputs within the token and diff budgets, then the secondary ones,
+// and records the options they were rendered with in nextState.
+func renderOutputs(root string, opts Options, nextState *CodemapState, cm *Codemap) error {
+	if err := checkSplitOutputDir(root, opts); err != nil {
+		return err
+	}
+	nextState.OptionsSignature = outputOptionsSignature(opts)
+	nextState.Packages = len(cm.Packages)
+	markdownRenderer := newMarkdownRenderer(opts)
+	pathsRenderer := PathsRenderer{Mini: opts.PathsMini, PurposeLength: opts.PathsPurposeLength}
+	outputPath := outputAbsPath(root, opts.OutputPath)
+	view, err := outputView(cm, opts)
+	if err != nil {
+		return err
+	}
+	if err := applyDiffBudget(outputPath, markdownRenderer, view, opts); err != nil {
+		return err
+	}
+	if err := writeRenderedOutput(outputPath, markdownRenderer, view); err != nil {
+		return err
 	}
 	if !opts.DisablePaths {
-		pathsPath := filepath.Join(root, opts.PathsOutputPath)
-		if err := writeRenderedOutput(pathsPath, pathsRenderer, cm); err != nil {
-			return nil, err
+		if err := writeRenderedOutput(outputAbsPath(root, opts.PathsOutputPath), pathsRenderer, view); err != nil {
+			return err
 		}
 	}
+	if err := writeSecondaryOutputs(root, opts, nextState, cm); err != nil {
+		return err
+	}
+	return verifyWrittenOutputs(opts)
+}
+
+// saveState writes nextState to statePath and its analysis cache beside it.
+func saveState(ctx context.Context, root string, opts Options, statePath string, nextState *CodemapState) error {
+	recordGitHead(ctx, root, opts, nextState)
 	if err := writeState(statePath, nextState); err != nil {
-		return nil, fmt.Errorf("write state: %w", err)
+		return fmt.Errorf("write state: %w", err)
 	}
-	if err := writeAnalysisCache(analysisPath, nextState.Analysis); err != nil {
-		return nil, fmt.Errorf("write analysis cache: %w", err)
+	if err := writeAnalysisCache(resolveAnalysisStatePath(root, opts), nextState.Analysis); err != nil {
+		return fmt.Errorf("write analysis cache: %w", err)
 	}
-
-	return cm, nil
+	return nil
 }
 
 func mergeStateWithAnalysis(state *CodemapState, analysis *AnalysisCache) *CodemapState {
@@ -319,6 +632,15 @@ func mergeStateWithAnalysis(state *CodemapState, analysis *AnalysisCache) *Codem
 	return &copy
 }
 
+// writeHashesOutput writes CODEMAP.hashes when opts.HashesOutputPath is set.
+func writeHashesOutput(root string, opts Options, nextState *CodemapState, cm *Codemap) error {
+	if opts.HashesOutputPath == "" {
+		return nil
+	}
+	cm.DirHashes = directoryHashes(nextState.Entries)
+	return writeRenderedOutput(outputAbsPath(root, opts.HashesOutputPath), HashesRenderer{}, cm)
+}
+
 func writeRenderedOutput(outputPath string, renderer Renderer, cm *Codemap) error {
 	content, err := renderer.Render(cm)
 	if err != nil {
@@ -331,19 +653,49 @@ func writeRenderedOutput(outputPath string, renderer Renderer, cm *Codemap) erro
 	return nil
 }
 
+// truncate shortens s to at most maxLen runes, ending in "..." when cut, so
+// multi-byte characters are never split.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
+	if utf8.RuneCountInString(s) <= maxLen {
 		return s
 	}
-	return s[:maxLen-3] + "..."
+	runes := []rune(s)
+	if maxLen <= 3 {
+		return string(runes[:maxLen])
+	}
+	return string(runes[:maxLen-3]) + "..."
 }
 
 func entryPath(pkg Package) string {
 	if pkg.EntryPoint == "" {
 		return ""
 	}
-	if pkg.RelativePath == "" || pkg.RelativePath == "." {
+	dir := packageFileDir(pkg.RelativePath)
+	if dir == "" || dir == "." {
 		return pkg.EntryPoint
 	}
-	return pkg.RelativePath + "/" + pkg.EntryPoint
+	return dir + "/" + pkg.EntryPoint
+}
+
+// packageFileDir returns the directory a package's EntryPoint and file names
+// are relative to. Packages grouped by a file name pattern, such as shell
+// scripts grouped with Options.ShellPrefixes, end their RelativePath in that
+// pattern (e.g. "scripts/deploy-*") and live in its parent directory.
+func packageFileDir(relPath string) string {
+	if strings.Contains(path.Base(relPath), "*") {
+		return path.Dir(relPath)
+	}
+	return relPath
+}
+
+// generationTime returns the timestamp recorded in outputs. SOURCE_DATE_EPOCH
+// (https://reproducible-builds.org/specs/source-date-epoch/) pins it for
+// hermetic builds; otherwise the current UTC time is used.
+func generationTime() time.Time {
+	if epoch := strings.TrimSpace(os.Getenv("SOURCE_DATE_EPOCH")); epoch != "" {
+		if secs, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(secs, 0).UTC()
+		}
+	}
+	return time.Now().UTC()
 }
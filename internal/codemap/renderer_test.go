@@ -0,0 +1,163 @@
+package codemap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// generationPathFiles is a tree whose "big" package lists its files in
+// CODEMAP.md unless the token budget drops them.
+var generationPathFiles = map[string]string{
+	"go.mod":         "module example.com/app\n\ngo 1.22\n",
+	"main.go":        "// Command app serves requests.\npackage main\n\nfunc main() {}\n",
+	"big/config.go":  "// Package big does many things.\npackage big\n\n// Config configures big.\ntype Config struct{}\n",
+	"big/handler.go": "package big\n\n// Handler serves big.\ntype Handler struct{}\n",
+	"big/store.go":   "package big\n\n// Store keeps big.\ntype Store struct{}\n",
+}
+
+// TestGenerationPathsShareTheOutputPipeline pins that every entry point that
+// writes outputs renders the same CODEMAP.md and CODEMAP.paths for the same
+// tree and options, applies the token and diff budgets, and records state
+// under which the outputs are up to date.
+func TestGenerationPathsShareTheOutputPipeline(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	ctx := context.Background()
+	newOpts := func(root string) Options {
+		opts := DefaultOptions()
+		opts.ProjectRoot = root
+		opts.LargePackageFiles = 2
+		return opts
+	}
+
+	// Without a budget the large package lists its files.
+	unbudgeted := t.TempDir()
+	writeTestTree(t, unbudgeted, generationPathFiles)
+	if _, err := Generate(ctx, newOpts(unbudgeted)); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if full := readTestOutput(t, unbudgeted, "CODEMAP.md"); !strings.Contains(full, "handler.go") {
+		t.Fatalf("expected the unbudgeted output to list big's files:\n%s", full)
+	}
+
+	budgeted := func(root string) Options {
+		opts := newOpts(root)
+		full, err := os.ReadFile(filepath.Join(unbudgeted, "CODEMAP.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.MaxOutputTokens = estimateTokens(len(full)) - 1
+		return opts
+	}
+	reference := t.TempDir()
+	writeTestTree(t, reference, generationPathFiles)
+	if _, err := Generate(ctx, budgeted(reference)); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	wantMarkdown := readTestOutput(t, reference, "CODEMAP.md")
+	wantPaths := readTestOutput(t, reference, "CODEMAP.paths")
+	if strings.Contains(wantMarkdown, "handler.go") {
+		t.Fatalf("expected the token budget to drop big's files:\n%s", wantMarkdown)
+	}
+	var snapshot bytes.Buffer
+	if _, err := ExportModel(ctx, budgeted(reference), &snapshot); err != nil {
+		t.Fatalf("ExportModel returned error: %v", err)
+	}
+
+	paths := []struct {
+		name     string
+		generate func(opts Options) error
+	}{
+		{"Generate", func(opts Options) error {
+			_, err := Generate(ctx, opts)
+			return err
+		}},
+		{"EnsureUpToDate", func(opts Options) error {
+			_, _, err := EnsureUpToDate(ctx, opts)
+			return err
+		}},
+		{"GenerateVariants", func(opts Options) error {
+			_, err := GenerateVariants(ctx, opts, []VariantSpec{{Name: "default"}})
+			return err
+		}},
+		{"ImportModel", func(opts Options) error {
+			_, _, err := ImportModel(ctx, opts, bytes.NewReader(snapshot.Bytes()))
+			return err
+		}},
+		{"Service.RefreshPackage", func(opts Options) error {
+			_, err := NewService(opts).RefreshPackage(ctx, "big")
+			return err
+		}},
+	}
+	for _, path := range paths {
+		t.Run(path.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTestTree(t, root, generationPathFiles)
+			opts := budgeted(root)
+
+			// An existing output that differs by more than the diff budget
+			// is left alone.
+			previous := "# Codemap\n\nold\n"
+			if err := os.WriteFile(filepath.Join(root, "CODEMAP.md"), []byte(previous), 0644); err != nil {
+				t.Fatal(err)
+			}
+			overBudget := opts
+			overBudget.DiffBudgetLines = 1
+			if err := path.generate(overBudget); !errors.Is(err, ErrDiffBudgetExceeded) {
+				t.Fatalf("expected ErrDiffBudgetExceeded, got %v", err)
+			}
+			if got := readTestOutput(t, root, "CODEMAP.md"); got != previous {
+				t.Fatalf("expected CODEMAP.md to be left alone over the diff budget, got:\n%s", got)
+			}
+
+			if err := path.generate(opts); err != nil {
+				t.Fatalf("generate returned error: %v", err)
+			}
+			if got := readTestOutput(t, root, "CODEMAP.md"); got != wantMarkdown {
+				t.Fatalf("CODEMAP.md differs from Generate:\n%s\nwant\n%s", got, wantMarkdown)
+			}
+			if got := readTestOutput(t, root, "CODEMAP.paths"); got != wantPaths {
+				t.Fatalf("CODEMAP.paths differs from Generate:\n%s\nwant\n%s", got, wantPaths)
+			}
+			if stale, err := IsStale(ctx, opts); err != nil || stale {
+				t.Fatalf("IsStale after generating = %v, %v; want false", stale, err)
+			}
+		})
+	}
+
+	// Warm builds the same caches without writing outputs, so the next
+	// EnsureUpToDate renders what Generate does.
+	t.Run("Warm", func(t *testing.T) {
+		root := t.TempDir()
+		writeTestTree(t, root, generationPathFiles)
+		opts := budgeted(root)
+		if _, err := Warm(ctx, opts); err != nil {
+			t.Fatalf("Warm returned error: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(root, "CODEMAP.md")); !os.IsNotExist(err) {
+			t.Fatalf("expected Warm to write no CODEMAP.md, got %v", err)
+		}
+		if _, err := os.Stat(resolveAnalysisStatePath(root, opts)); err != nil {
+			t.Fatalf("expected Warm to write the analysis cache: %v", err)
+		}
+		if _, generated, err := EnsureUpToDate(ctx, opts); err != nil || !generated {
+			t.Fatalf("EnsureUpToDate after Warm = %v, %v; want a generation", generated, err)
+		}
+		if got := readTestOutput(t, root, "CODEMAP.md"); got != wantMarkdown {
+			t.Fatalf("CODEMAP.md after Warm differs from Generate:\n%s\nwant\n%s", got, wantMarkdown)
+		}
+	})
+}
+
+func readTestOutput(t *testing.T, root, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
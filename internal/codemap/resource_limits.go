@@ -0,0 +1,106 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// resourceGuard enforces the soft CPU/RSS limits configured in Options.
+// Once a limit trips, remaining package jobs are skipped so the run can still
+// render the packages analyzed so far.
+type resourceGuard struct {
+	maxCPU     time.Duration
+	maxRSS     int64
+	startCPU   time.Duration
+	mu         sync.Mutex
+	reason     string
+	lastSample time.Time
+}
+
+type resourceGuardKey struct{}
+
+// resourceSampleInterval bounds how often usage is sampled from the OS.
+const resourceSampleInterval = 10 * time.Millisecond
+
+func newResourceGuard(opts Options) *resourceGuard {
+	if opts.MaxCPUSeconds <= 0 && opts.MaxRSS <= 0 {
+		return nil
+	}
+	cpu, _, _ := processResourceUsage()
+	return &resourceGuard{
+		maxCPU:   time.Duration(opts.MaxCPUSeconds * float64(time.Second)),
+		maxRSS:   opts.MaxRSS,
+		startCPU: cpu,
+	}
+}
+
+// withResourceGuard attaches a guard for opts to ctx. The returned guard is nil
+// when no limits are configured.
+func withResourceGuard(ctx context.Context, opts Options) (context.Context, *resourceGuard) {
+	guard := newResourceGuard(opts)
+	if guard == nil {
+		return ctx, nil
+	}
+	return context.WithValue(ctx, resourceGuardKey{}, guard), guard
+}
+
+func resourceGuardFromContext(ctx context.Context) *resourceGuard {
+	guard, _ := ctx.Value(resourceGuardKey{}).(*resourceGuard)
+	return guard
+}
+
+// exceeded samples current usage and reports whether a limit has been reached.
+func (g *resourceGuard) exceeded() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.reason != "" {
+		return true
+	}
+	now := time.Now()
+	if now.Sub(g.lastSample) < resourceSampleInterval {
+		return false
+	}
+	g.lastSample = now
+
+	cpu, rss, ok := processResourceUsage()
+	if !ok {
+		return false
+	}
+	if g.maxCPU > 0 && cpu-g.startCPU >= g.maxCPU {
+		g.reason = fmt.Sprintf("CPU time limit of %s reached", g.maxCPU)
+		return true
+	}
+	if g.maxRSS > 0 && rss >= g.maxRSS {
+		g.reason = fmt.Sprintf("memory limit of %d MiB reached", g.maxRSS/(1<<20))
+		return true
+	}
+	return false
+}
+
+// warning returns the message to surface when the guard tripped.
+func (g *resourceGuard) warning() string {
+	if g == nil {
+		return ""
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.reason == "" {
+		return ""
+	}
+	return g.reason + "; outputs are partial and will be completed on the next run"
+}
+
+// applyResourceWarning marks cm as partial when the guard tripped. Partial
+// outputs carry no content hash so the next run treats them as stale, while the
+// analysis cache keeps the packages that did complete.
+func applyResourceWarning(cm *Codemap, guard *resourceGuard) {
+	if warning := guard.warning(); warning != "" {
+		cm.Warnings = append(cm.Warnings, warning)
+		cm.ContentHash = ""
+	}
+}
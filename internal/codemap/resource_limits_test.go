@@ -0,0 +1,67 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateWithExceededMemoryLimitWritesPartialOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/test\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		dir := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".go"), []byte("package "+name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.MaxRSS = 1
+
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if len(cm.Warnings) != 1 || !strings.Contains(cm.Warnings[0], "memory limit") {
+		t.Fatalf("expected memory limit warning, got %v", cm.Warnings)
+	}
+	if cm.ContentHash != "" {
+		t.Fatalf("expected partial output to carry no content hash, got %q", cm.ContentHash)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, opts.OutputPath))
+	if err != nil {
+		t.Fatalf("expected partial markdown output: %v", err)
+	}
+	if !strings.Contains(string(content), "> Warning: memory limit") {
+		t.Fatalf("expected warning in markdown output, got:\n%s", content)
+	}
+
+	opts.MaxRSS = 0
+	stale, err := IsStale(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("IsStale returned error: %v", err)
+	}
+	if !stale {
+		t.Fatal("expected partial outputs to be reported stale")
+	}
+}
+
+func TestResourceGuardDisabledWithoutLimits(t *testing.T) {
+	ctx, guard := withResourceGuard(context.Background(), DefaultOptions())
+	if guard != nil {
+		t.Fatal("expected no guard without limits")
+	}
+	if resourceGuardFromContext(ctx).exceeded() {
+		t.Fatal("expected nil guard never to report exceeded")
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package codemap
+
+import (
+	"runtime"
+	"time"
+)
+
+// processResourceUsage reports Go heap usage only; CPU time is not available
+// without getrusage, so CPU limits are not enforced on these platforms.
+func processResourceUsage() (time.Duration, int64, bool) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return 0, int64(stats.Sys), true
+}
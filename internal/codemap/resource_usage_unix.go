@@ -0,0 +1,24 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package codemap
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// processResourceUsage returns consumed CPU time (user+system) and peak RSS in bytes.
+func processResourceUsage() (time.Duration, int64, bool) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, 0, false
+	}
+	cpu := time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+	rss := int64(ru.Maxrss)
+	if runtime.GOOS != "darwin" {
+		// Linux and the BSDs report ru_maxrss in kilobytes.
+		rss *= 1024
+	}
+	return cpu, rss, true
+}
@@ -0,0 +1,186 @@
+package codemap
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// roleHeaderBytes bounds how much of each file is read for content heuristics.
+const roleHeaderBytes = 4096
+
+// FileRoleDef classifies files by lowercase base-name globs (path.Match
+// syntax) or by regular expressions matched against the top of the file.
+type FileRoleDef struct {
+	Role            string
+	NamePatterns    []string
+	ContentPatterns []string
+}
+
+// defaultFileRoles is ordered: the first matching role wins.
+var defaultFileRoles = []FileRoleDef{
+	{
+		Role: "generated",
+		NamePatterns: []string{
+			"*.pb.go", "*_gen.go", "*.gen.go", "*_generated.*", "*.generated.*",
+			"zz_generated*", "*_pb2.py", "*_pb2_grpc.py", "*.pb.ts", "*_pb.ts",
+		},
+		ContentPatterns: []string{
+			`(?m)^\s*(?://|#|/?\*|--)\s*Code generated .*DO NOT EDIT`,
+			`(?m)^\s*(?://|#|/?\*|--)\s*@generated\b`,
+			`(?m)^\s*(?://|#|/?\*|--)\s*(?:This file (?:is|was) )?auto-?generated\b`,
+		},
+	},
+	{
+		Role: "test",
+		NamePatterns: []string{
+			"*_test.go", "test_*.py", "*_test.py", "conftest.py", "*.test.*", "*.spec.*",
+			"*_test.sh", "*_test.bash", "*.bats",
+		},
+	},
+	{
+		Role:         "config",
+		NamePatterns: []string{"config*", "*config.*", "*_config*", "settings*", "options*", "*_options.*", "flags*"},
+	},
+	{
+		Role: "storage",
+		NamePatterns: []string{
+			"*store*", "*storage*", "*repo.*", "*repository*", "db.*", "db_*", "*_db.*",
+			"*database*", "*cache*", "*persist*", "*dao*", "*migration*",
+		},
+	},
+	{
+		Role: "handler",
+		NamePatterns: []string{
+			"*handler*", "*controller*", "*route*", "*router*", "*server*", "*endpoint*",
+			"*middleware*", "views.*", "*_views.*",
+		},
+		ContentPatterns: []string{`http\.ResponseWriter`, `@app\.route\(`, `@router\.(?:get|post|put|patch|delete)\(`},
+	},
+	{
+		Role: "model",
+		NamePatterns: []string{
+			"*model*", "types.*", "*_types.*", "*entity*", "*entities*", "*schema*", "*dto*",
+		},
+	},
+}
+
+// assignFileRoles classifies the detailed file listings of large packages.
+func assignFileRoles(root string, packages []Package, defs []FileRoleDef) {
+	if len(defs) == 0 {
+		return
+	}
+	matchers := compileFileRoles(defs)
+	for i := range packages {
+		pkg := &packages[i]
+		if len(pkg.Files) == 0 {
+			continue
+		}
+		files := make([]File, len(pkg.Files))
+		copy(files, pkg.Files)
+		for j := range files {
+			relPath := entryPath(Package{RelativePath: pkg.RelativePath, EntryPoint: files[j].Name})
+			files[j].Role = classifyFileRole(filepath.Join(root, filepath.FromSlash(relPath)), matchers)
+		}
+		pkg.Files = files
+	}
+}
+
+type fileRoleMatcher struct {
+	role    string
+	names   []string
+	content []*regexp.Regexp
+}
+
+// compileFileRoles lowercases name globs and compiles content patterns,
+// skipping invalid expressions.
+func compileFileRoles(defs []FileRoleDef) []fileRoleMatcher {
+	matchers := make([]fileRoleMatcher, 0, len(defs))
+	for _, def := range defs {
+		matcher := fileRoleMatcher{role: def.Role}
+		for _, pattern := range def.NamePatterns {
+			matcher.names = append(matcher.names, strings.ToLower(pattern))
+		}
+		for _, pattern := range def.ContentPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				continue
+			}
+			matcher.content = append(matcher.content, re)
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers
+}
+
+// classifyFileRole returns the first role whose name or content heuristics
+// match absPath, or "" when none do.
+func classifyFileRole(absPath string, matchers []fileRoleMatcher) string {
+	name := strings.ToLower(filepath.Base(absPath))
+	var header []byte
+	headerRead := false
+	for _, matcher := range matchers {
+		for _, pattern := range matcher.names {
+			if ok, _ := path.Match(pattern, name); ok {
+				return matcher.role
+			}
+		}
+		if len(matcher.content) == 0 {
+			continue
+		}
+		if !headerRead {
+			header = readFileHeader(absPath, roleHeaderBytes)
+			headerRead = true
+		}
+		for _, re := range matcher.content {
+			if re.Match(header) {
+				return matcher.role
+			}
+		}
+	}
+	return ""
+}
+
+func readFileHeader(absPath string, limit int64) []byte {
+	f, err := os.Open(absPath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	header, _ := io.ReadAll(io.LimitReader(f, limit))
+	return header
+}
+
+type fileRoleRow struct {
+	Package string
+	Role    string
+	Files   []string
+}
+
+// fileRoleRows groups classified files of large packages by role, one row per
+// package and role.
+func fileRoleRows(packages []Package) []fileRoleRow {
+	var rows []fileRoleRow
+	for _, pkg := range packages {
+		byRole := make(map[string][]string)
+		var roles []string
+		for _, file := range pkg.Files {
+			if file.Role == "" {
+				continue
+			}
+			if _, ok := byRole[file.Role]; !ok {
+				roles = append(roles, file.Role)
+			}
+			byRole[file.Role] = append(byRole[file.Role], file.Name)
+		}
+		sort.Strings(roles)
+		for _, role := range roles {
+			rows = append(rows, fileRoleRow{Package: pkg.RelativePath, Role: role, Files: byRole[role]})
+		}
+	}
+	return rows
+}
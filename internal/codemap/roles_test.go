@@ -0,0 +1,62 @@
+package codemap
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeClassifiesFileRoles(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":               "module example.com/test\n\ngo 1.22\n",
+		"shop/shop.go":         "// Package shop sells things.\npackage shop\n",
+		"shop/order_model.go":  "package shop\n",
+		"shop/order_store.go":  "package shop\n",
+		"shop/config.go":       "package shop\n",
+		"shop/api.go":          "package shop\n\nimport \"net/http\"\n\nfunc Serve(w http.ResponseWriter, r *http.Request) {}\n",
+		"shop/enum.go":         "// Code generated by stringer; DO NOT EDIT.\n\npackage shop\n",
+		"shop/mentions_gen.go": "package shop\n",
+		"shop/util.go":         "package shop\n\n// Mentions \"Code generated ... DO NOT EDIT\" in a string only.\nvar marker = \"// Code generated x DO NOT EDIT\"\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.LargePackageFiles = 2
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	if len(cm.Packages) != 1 {
+		t.Fatalf("expected one package, got %+v", cm.Packages)
+	}
+
+	roles := make(map[string]string)
+	for _, file := range cm.Packages[0].Files {
+		roles[file.Name] = file.Role
+	}
+	want := map[string]string{
+		"shop.go":         "",
+		"order_model.go":  "model",
+		"order_store.go":  "storage",
+		"config.go":       "config",
+		"api.go":          "handler",
+		"enum.go":         "generated",
+		"mentions_gen.go": "generated",
+		"util.go":         "",
+	}
+	for name, role := range want {
+		if roles[name] != role {
+			t.Fatalf("role of %s = %q, want %q (all: %v)", name, roles[name], role, roles)
+		}
+	}
+
+	content, err := Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if !strings.Contains(content, "| shop | generated | enum.go, mentions_gen.go |") {
+		t.Fatalf("expected role rows in output:\n%s", content)
+	}
+}
@@ -0,0 +1,463 @@
+package codemap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// rubyPackageManifests mark the root of a Ruby package. Files are grouped by
+// the nearest directory holding one of them; the directories looked at are
+// recorded as auxiliary inputs.
+var rubyPackageManifests = []string{"Gemfile", "*.gemspec"}
+
+var rubyGemNamePattern = regexp.MustCompile(`(?m)^\s*\w+\.name\s*=\s*["']([^"']+)["']`)
+
+// RubyAnalyzer is the analyzer implementation for Ruby projects.
+type RubyAnalyzer struct{}
+
+func (RubyAnalyzer) LanguageID() string { return languageRuby }
+
+func (RubyAnalyzer) Analyze(ctx context.Context, in AnalysisInput) (*Codemap, error) {
+	if in.Index == nil {
+		return nil, fmt.Errorf("missing file index")
+	}
+	return analyzeRubyWithIndex(ctx, in.Root, in.Index, in.Options, in.PrevState, in.NextState, in.auxInputs())
+}
+
+func analyzeRubyWithIndex(ctx context.Context, root string, idx *FileIndex, opts Options, prevState, nextState *CodemapState, aux *auxInputs) (*Codemap, error) {
+	entryByRel := stateEntryByRelPath(nextState)
+	plans, err := buildRubyPackagePlans(aux, idx, opts.IncludeTests, entryByRel)
+	if err != nil {
+		return nil, err
+	}
+
+	const modulePath = languageRuby
+	cachedByRel := cachedPackagesByPath(prevState, opts, modulePath)
+
+	packageResults := make([]*Package, len(plans))
+	jobs := make([]analysisJob, 0, len(plans))
+	for i := range plans {
+		plan := plans[i]
+		if cached, ok := cachedByRel[plan.RelativePath]; ok && plan.Fingerprint != "" && cached.Fingerprint == plan.Fingerprint {
+			pkg := cached.Package
+			packageResults[i] = &pkg
+			recordCacheHit(ctx, opts, languageRuby, plan.RelativePath)
+			continue
+		}
+		jobs = append(jobs, analysisJob{
+			index:   i,
+			dir:     plan.DirAbsPath,
+			relPath: plan.RelativePath,
+		})
+	}
+
+	if err := analyzePackagePlansParallel(ctx, opts, languageRuby, jobs, packageResults, func(ctx context.Context, job analysisJob) (*Package, error) {
+		plan := plans[job.index]
+		pkg, err := analyzeRubyPackage(ctx, root, plan, plan.Name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("analyze ruby package %s: %w", plan.RelativePath, err)
+		}
+		return pkg, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0, len(packageResults))
+	for i := range packageResults {
+		if packageResults[i] != nil {
+			packages = append(packages, *packageResults[i])
+		}
+	}
+
+	updateAnalysisCache(nextState, opts, modulePath, languageRuby, plans, packageResults)
+
+	return &Codemap{
+		ProjectRoot: root,
+		Packages:    packages,
+	}, nil
+}
+
+func buildRubyPackagePlans(aux *auxInputs, idx *FileIndex, includeTests bool, entriesByRel map[string]StateEntry) ([]packagePlan, error) {
+	plansByRel := make(map[string]*packagePlan)
+	rootAbs, err := filepath.Abs(aux.root)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	rootByDir := map[string]string{}
+
+	for _, rec := range idx.Files {
+		if rec.Language != languageRuby {
+			continue
+		}
+		if !includeTests && isRubyTestPath(rec.RelPath, rec.IsTest) {
+			continue
+		}
+
+		pkgRel := resolveManifestRoot(aux, path.Dir(rec.RelPath), rubyPackageManifests, rootByDir)
+		plan, ok := plansByRel[pkgRel]
+		if !ok {
+			plan = &packagePlan{
+				RelativePath: pkgRel,
+				DirAbsPath:   filepath.Join(rootAbs, filepath.FromSlash(pkgRel)),
+				FileRelPaths: make([]string, 0, 4),
+			}
+			plansByRel[pkgRel] = plan
+		}
+		plan.FileRelPaths = append(plan.FileRelPaths, rec.RelPath)
+	}
+
+	relPaths := make([]string, 0, len(plansByRel))
+	for rel := range plansByRel {
+		relPaths = append(relPaths, rel)
+	}
+	sort.Strings(relPaths)
+
+	plans := make([]packagePlan, 0, len(relPaths))
+	for _, rel := range relPaths {
+		plan := plansByRel[rel]
+		sort.Strings(plan.FileRelPaths)
+		plan.Name = readRubyGemName(aux, rootAbs, *plan)
+		plan.Fingerprint = namedPackageFingerprint(packageFingerprint(plan.FileRelPaths, entriesByRel), plan.Name)
+		plans = append(plans, *plan)
+	}
+
+	return plans, nil
+}
+
+func analyzeRubyPackage(ctx context.Context, root string, plan packagePlan, gemName string, opts Options) (*Package, error) {
+	if len(plan.FileRelPaths) == 0 {
+		return nil, nil
+	}
+
+	files := make([]File, 0, len(plan.FileRelPaths))
+	allTypes := make([]TypeInfo, 0, len(plan.FileRelPaths))
+	importsSeen := make(map[string]struct{}, len(plan.FileRelPaths))
+	externalSeen := make(map[string]struct{})
+	totalLines := 0
+	purpose := ""
+	entryPoint := ""
+	entryScore := -1
+	var tags []string
+
+	for _, relPath := range plan.FileRelPaths {
+		absPath := filepath.Join(root, filepath.FromSlash(relPath))
+		content, err := os.ReadFile(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", relPath, err)
+		}
+
+		withinPackage := relPath
+		if plan.RelativePath != "." {
+			prefix := plan.RelativePath + "/"
+			if strings.HasPrefix(relPath, prefix) {
+				withinPackage = strings.TrimPrefix(relPath, prefix)
+			}
+		}
+
+		filePurpose := extractFilePurpose(opts, languageRuby, relPath, content, extractRubyFilePurpose)
+		if purpose == "" && filePurpose != "" {
+			purpose = filePurpose
+		}
+		tags = mergeCodemapTags(tags, extractCodemapTags(content))
+
+		symbols := parseRubyFileSymbols(content)
+		recordFileParsed(ctx, relPath, symbols.lineCount)
+		totalLines += symbols.lineCount
+		allTypes = append(allTypes, symbols.types...)
+		for _, imp := range symbols.relativeRequires {
+			importsSeen[packageRelativeImport(withinPackage, imp)] = struct{}{}
+		}
+		for _, imp := range symbols.requires {
+			externalSeen[imp] = struct{}{}
+		}
+
+		files = append(files, File{
+			Name:      withinPackage,
+			LineCount: symbols.lineCount,
+			Purpose:   filePurpose,
+			KeyTypes:  symbols.keyTypes,
+			KeyFuncs:  symbols.keyFuncs,
+		})
+
+		score := scoreRubyEntryPoint(withinPackage, gemName, symbols.keyTypes, symbols.keyFuncs)
+		if score > entryScore || (score == entryScore && (entryPoint == "" || withinPackage < entryPoint)) {
+			entryScore = score
+			entryPoint = withinPackage
+		}
+	}
+
+	if entryPoint == "" && len(files) > 0 {
+		entryPoint = files[0].Name
+	}
+	if purpose == "" && gemName != "" {
+		purpose = "Ruby package " + gemName
+	}
+
+	sort.SliceStable(allTypes, func(i, j int) bool {
+		return allTypes[i].Name < allTypes[j].Name
+	})
+
+	detailedFiles, keyFiles := packageFileDetails(files, entryPoint, opts)
+
+	return &Package{
+		ImportPath:      gemName,
+		RelativePath:    plan.RelativePath,
+		Purpose:         purpose,
+		FileCount:       len(files),
+		LineCount:       totalLines,
+		Files:           detailedFiles,
+		KeyFiles:        keyFiles,
+		ExportedTypes:   allTypes,
+		Symbols:         packageSymbols(files, allTypes),
+		Imports:         sortedImportSet(importsSeen),
+		ExternalImports: sortedImportSet(externalSeen),
+		EntryPoint:      entryPoint,
+		Tags:            tags,
+	}, nil
+}
+
+// readRubyGemName returns the name declared by the package's gemspec,
+// falling back to the directory name. The gemspec is read through aux.
+func readRubyGemName(aux *auxInputs, root string, plan packagePlan) string {
+	entries, _ := aux.readDir(plan.RelativePath, manifestDirFilter(rubyPackageManifests))
+	var specs []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".gemspec") {
+			specs = append(specs, entry.Name())
+		}
+	}
+	sort.Strings(specs)
+	for _, spec := range specs {
+		if content, err := aux.readFile(path.Join(plan.RelativePath, spec)); err == nil {
+			if match := rubyGemNamePattern.FindSubmatch(content); match != nil {
+				return string(match[1])
+			}
+		}
+		return strings.TrimSuffix(spec, ".gemspec")
+	}
+	if plan.RelativePath == "." || plan.RelativePath == "" {
+		return filepath.Base(root)
+	}
+	return path.Base(plan.RelativePath)
+}
+
+func isRubyTestPath(relPath string, fileMatchTest bool) bool {
+	if fileMatchTest {
+		return true
+	}
+	lower := strings.ToLower(relPath)
+	for _, dir := range []string{"spec", "test", "tests"} {
+		if strings.HasPrefix(lower, dir+"/") || strings.Contains(lower, "/"+dir+"/") {
+			return true
+		}
+	}
+	base := path.Base(lower)
+	return strings.HasPrefix(base, "test_") && strings.HasSuffix(base, ".rb")
+}
+
+type rubyFileSymbols struct {
+	types            []TypeInfo
+	keyTypes         []string
+	keyFuncs         []string
+	relativeRequires []string // require_relative targets
+	requires         []string // require targets, usually gems
+	lineCount        int
+}
+
+// rubyScope is an open class or module body, tracked by indentation.
+type rubyScope struct {
+	indent int
+	kind   string
+}
+
+// parseRubyFileSymbols extracts classes and modules that are not nested in a
+// class, methods defined outside any class or module, and require targets.
+// Nesting follows indentation, which holds for conventionally formatted code.
+func parseRubyFileSymbols(content []byte) rubyFileSymbols {
+	symbols := rubyFileSymbols{lineCount: lineCountBytes(content)}
+	var scopes []rubyScope
+	inBlockComment := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case inBlockComment:
+			inBlockComment = !strings.HasPrefix(line, "=end")
+			continue
+		case strings.HasPrefix(line, "=begin"):
+			inBlockComment = true
+			continue
+		case strings.HasPrefix(line, "__END__"):
+			return symbols
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		for len(scopes) > 0 && scopes[len(scopes)-1].indent >= indent {
+			scopes = scopes[:len(scopes)-1]
+		}
+		insideClass := false
+		for _, scope := range scopes {
+			insideClass = insideClass || scope.kind == "class"
+		}
+
+		if kind, name := parseRubyTypeDecl(trimmed); kind != "" {
+			if name != "" && !insideClass && !stringSliceContains(symbols.keyTypes, name) {
+				symbols.types = append(symbols.types, TypeInfo{Name: name, Kind: kind})
+				symbols.keyTypes = append(symbols.keyTypes, name)
+			}
+			scopes = append(scopes, rubyScope{indent: indent, kind: kind})
+			continue
+		}
+		if name := parseRubyMethodName(trimmed); name != "" {
+			if len(scopes) == 0 && !stringSliceContains(symbols.keyFuncs, name) {
+				symbols.keyFuncs = append(symbols.keyFuncs, name)
+			}
+			continue
+		}
+		if target, ok := parseRubyRequire(trimmed, "require_relative"); ok {
+			if !stringSliceContains(symbols.relativeRequires, target) {
+				symbols.relativeRequires = append(symbols.relativeRequires, target)
+			}
+			continue
+		}
+		if target, ok := parseRubyRequire(trimmed, "require"); ok {
+			if !stringSliceContains(symbols.requires, target) {
+				symbols.requires = append(symbols.requires, target)
+			}
+		}
+	}
+
+	return symbols
+}
+
+// parseRubyTypeDecl parses "class Name < Base" and "module Name" lines. The
+// kind is reported with an empty name for singleton classes (class << self).
+func parseRubyTypeDecl(line string) (kind, name string) {
+	for _, keyword := range []string{"class", "module"} {
+		rest, ok := strings.CutPrefix(line, keyword)
+		if !ok || rest == "" || (rest[0] != ' ' && rest[0] != '\t') {
+			continue
+		}
+		rest = strings.TrimSpace(rest)
+		if strings.HasPrefix(rest, "<<") {
+			return keyword, ""
+		}
+		end := 0
+		for end < len(rest) && (isRubyConstantChar(rest[end]) || rest[end] == ':') {
+			end++
+		}
+		name = strings.Trim(rest[:end], ":")
+		if name == "" || rest[0] < 'A' || rest[0] > 'Z' {
+			return "", ""
+		}
+		return keyword, name
+	}
+	return "", ""
+}
+
+// parseRubyMethodName parses "def name", "def name(args)" and "def self.name".
+func parseRubyMethodName(line string) string {
+	rest, ok := strings.CutPrefix(line, "def ")
+	if !ok {
+		return ""
+	}
+	rest = strings.TrimPrefix(strings.TrimSpace(rest), "self.")
+	end := 0
+	for end < len(rest) && isRubyConstantChar(rest[end]) {
+		end++
+	}
+	if end < len(rest) && strings.ContainsRune("?!=", rune(rest[end])) {
+		end++
+	}
+	return rest[:end]
+}
+
+// parseRubyRequire parses `keyword "target"` and `keyword("target")`.
+func parseRubyRequire(line, keyword string) (string, bool) {
+	rest, ok := strings.CutPrefix(line, keyword)
+	if !ok || rest == "" || (rest[0] != ' ' && rest[0] != '(' && rest[0] != '\t') {
+		return "", false
+	}
+	rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), "("))
+	if rest == "" || (rest[0] != '"' && rest[0] != '\'') {
+		return "", false
+	}
+	end := strings.IndexByte(rest[1:], rest[0])
+	if end <= 0 {
+		return "", false
+	}
+	return rest[1 : end+1], true
+}
+
+func isRubyConstantChar(c byte) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
+
+// extractRubyFilePurpose returns the first sentence of the leading comment,
+// skipping the shebang and magic comments such as frozen_string_literal.
+func extractRubyFilePurpose(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#!") {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			return ""
+		}
+		text := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		if isRubyMagicComment(text) || text == "" {
+			continue
+		}
+		return extractFirstSentence(text)
+	}
+	return ""
+}
+
+func isRubyMagicComment(text string) bool {
+	lower := strings.ToLower(text)
+	for _, prefix := range []string{"frozen_string_literal:", "encoding:", "coding:", "-*-", "typed:", "warn_indent:", "shareable_constant_value:", "rubocop:"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func scoreRubyEntryPoint(relPath, gemName string, keyTypes, keyFuncs []string) int {
+	score := 0
+	lower := strings.ToLower(relPath)
+
+	switch {
+	case gemName != "" && lower == "lib/"+strings.ToLower(gemName)+".rb":
+		score += 140
+	case lower == "config/application.rb":
+		score += 110
+	case strings.HasPrefix(lower, "exe/") || strings.HasPrefix(lower, "bin/"):
+		score += 100
+	case lower == "main.rb" || lower == "app.rb" || lower == "application.rb":
+		score += 90
+	case strings.HasPrefix(lower, "lib/") && !strings.Contains(strings.TrimPrefix(lower, "lib/"), "/"):
+		score += 60
+	}
+
+	if len(keyTypes) > 0 {
+		score += 10
+	}
+	if len(keyFuncs) > 0 {
+		score += 5
+	}
+	return score
+}
@@ -0,0 +1,173 @@
+package codemap
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRubyFileSymbols(t *testing.T) {
+	content := []byte(`# frozen_string_literal: true
+
+require "json"
+require_relative "store/version"
+require_relative('store/backend')
+
+module Store
+  class Error < StandardError; end
+
+  class Client
+    class << self
+      def connect; end
+    end
+
+    def get(key)
+    end
+  end
+
+  def self.configure
+  end
+end
+
+class Store::Cache
+  class Entry
+  end
+end
+
+def helper?(x)
+end
+=begin
+class Hidden
+end
+=end
+__END__
+class AfterEnd
+end
+`)
+
+	got := parseRubyFileSymbols(content)
+	if !reflect.DeepEqual(got.keyTypes, []string{"Store", "Error", "Client", "Store::Cache"}) {
+		t.Fatalf("unexpected types: %v", got.keyTypes)
+	}
+	if got.types[0].Kind != "module" || got.types[1].Kind != "class" {
+		t.Fatalf("unexpected kinds: %+v", got.types)
+	}
+	if !reflect.DeepEqual(got.keyFuncs, []string{"helper?"}) {
+		t.Fatalf("unexpected funcs: %v", got.keyFuncs)
+	}
+	if !reflect.DeepEqual(got.relativeRequires, []string{"store/version", "store/backend"}) || !reflect.DeepEqual(got.requires, []string{"json"}) {
+		t.Fatalf("unexpected requires: %v / %v", got.relativeRequires, got.requires)
+	}
+	if got.lineCount != lineCountBytes(content) {
+		t.Fatalf("unexpected line count: %d", got.lineCount)
+	}
+	if purpose := extractRubyFilePurpose(content); purpose != "" {
+		t.Fatalf("expected magic comment to be skipped, got %q", purpose)
+	}
+}
+
+func TestRubyAnalyzerGroupsByGemRoot(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"Gemfile":                          "source \"https://rubygems.org\"\n",
+		"app.rb":                           "# Web front end.\nrequire \"sinatra\"\nrequire_relative \"lib/routes\"\n",
+		"lib/routes.rb":                    "module Routes\nend\n",
+		"gems/toolkit/toolkit.gemspec":     "Gem::Specification.new do |spec|\n  spec.name = \"acme-toolkit\"\nend\n",
+		"gems/toolkit/lib/acme-toolkit.rb": "# frozen_string_literal: true\n\n# Shared helpers for Acme services.\nmodule Toolkit\nend\n",
+		"gems/toolkit/lib/toolkit/fmt.rb":  "module Toolkit\n  class Formatter\n  end\nend\n",
+		"gems/toolkit/spec/fmt_spec.rb":    "describe Toolkit do\nend\n",
+		"gems/toolkit/test/test_fmt.rb":    "class TestFmt\nend\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+
+	byPath := make(map[string]Package)
+	for _, pkg := range cm.Packages {
+		if pkg.Language == languageRuby {
+			byPath[pkg.RelativePath] = pkg
+		}
+	}
+	if len(byPath) != 2 {
+		t.Fatalf("expected 2 Ruby packages, got %+v", byPath)
+	}
+
+	app := byPath["."]
+	if app.FileCount != 2 || app.EntryPoint != "app.rb" || app.Purpose != "Web front end." {
+		t.Fatalf("unexpected app package: %+v", app)
+	}
+	if !reflect.DeepEqual(app.Imports, []string{"./lib/routes"}) || !reflect.DeepEqual(app.ExternalImports, []string{"sinatra"}) {
+		t.Fatalf("unexpected app imports: %v / %v", app.Imports, app.ExternalImports)
+	}
+
+	toolkit := byPath["gems/toolkit"]
+	if toolkit.ImportPath != "acme-toolkit" || toolkit.FileCount != 2 || toolkit.EntryPoint != "lib/acme-toolkit.rb" {
+		t.Fatalf("unexpected toolkit package: %+v", toolkit)
+	}
+	if toolkit.Purpose != "Shared helpers for Acme services." {
+		t.Fatalf("unexpected toolkit purpose: %q", toolkit.Purpose)
+	}
+
+	opts.IncludeTests = true
+	cm, err = Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	for _, pkg := range cm.Packages {
+		if pkg.Language == languageRuby && pkg.RelativePath == "gems/toolkit" && pkg.FileCount != 4 {
+			t.Fatalf("expected spec and test files with -tests, got %+v", pkg)
+		}
+	}
+}
+
+func TestRubyManifestChangesMakeOutputsStale(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"Gemfile":           "source \"https://rubygems.org\"\n",
+		"app/main.rb":       "puts 'hi'\n",
+		"gems/acme/acme.rb": "module Acme\nend\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	packages := func() map[string]string {
+		t.Helper()
+		if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+			t.Fatalf("IsStale = %v, %v; want true", stale, err)
+		}
+		cm, _, err := EnsureUpToDate(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("EnsureUpToDate returned error: %v", err)
+		}
+		byPath := make(map[string]string)
+		for _, pkg := range cm.Packages {
+			if pkg.Language == languageRuby {
+				byPath[pkg.RelativePath] = pkg.ImportPath
+			}
+		}
+		return byPath
+	}
+	root := filepath.Base(tmpDir)
+	if got, want := packages(), map[string]string{".": root}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("packages = %v, want %v", got, want)
+	}
+
+	spec := "Gem::Specification.new do |s|\n  s.name = \"acme-core\"\nend\n"
+	writeTestTree(t, tmpDir, map[string]string{"gems/acme/acme.gemspec": spec})
+	if got, want := packages(), map[string]string{".": root, "gems/acme": "acme-core"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("packages after adding a gemspec = %v, want %v", got, want)
+	}
+
+	writeTestTree(t, tmpDir, map[string]string{"gems/acme/acme.gemspec": strings.Replace(spec, "acme-core", "acme", 1)})
+	if got, want := packages(), map[string]string{".": root, "gems/acme": "acme"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("packages after renaming the gem = %v, want %v", got, want)
+	}
+}
@@ -7,12 +7,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 
 	sitter "github.com/tree-sitter/go-tree-sitter"
 )
 
+var rustExternalCratePattern = regexp.MustCompile(`(?m)^\s*(?:pub(?:\([^)]*\))?\s+)?(?:use\s+|extern\s+crate\s+)(?:::)?([A-Za-z_][A-Za-z0-9_]*)`)
+
 // RustAnalyzer is the analyzer implementation for Rust projects.
 type RustAnalyzer struct{}
 
@@ -42,18 +45,20 @@ func analyzeRustWithIndex(ctx context.Context, root string, idx *FileIndex, opts
 		if cached, ok := cachedByRel[plan.RelativePath]; ok && plan.Fingerprint != "" && cached.Fingerprint == plan.Fingerprint {
 			pkg := cached.Package
 			packageResults[i] = &pkg
+			recordCacheHit(ctx, opts, languageRust, plan.RelativePath)
 			continue
 		}
 		jobs = append(jobs, analysisJob{
-			index: i,
-			dir:   plan.DirAbsPath,
+			index:   i,
+			dir:     plan.DirAbsPath,
+			relPath: plan.RelativePath,
 		})
 	}
 
-	if err := analyzePackagePlansParallel(ctx, opts, jobs, packageResults, func(job analysisJob) (*Package, error) {
+	if err := analyzePackagePlansParallel(ctx, opts, languageRust, jobs, packageResults, func(ctx context.Context, job analysisJob) (*Package, error) {
 		plan := plans[job.index]
 		crateName := readRustCrateName(plan.DirAbsPath, plan.RelativePath)
-		pkg, err := analyzeRustPackage(root, plan, crateName, opts)
+		pkg, err := analyzeRustPackage(ctx, root, plan, crateName, opts)
 		if err != nil {
 			return nil, fmt.Errorf("analyze rust package %s: %w", plan.RelativePath, err)
 		}
@@ -69,17 +74,11 @@ func analyzeRustWithIndex(ctx context.Context, root string, idx *FileIndex, opts
 		}
 	}
 
-	concerns, err := buildConcerns(idx, opts.Concerns, opts.ConcernExampleLimit)
-	if err != nil {
-		return nil, fmt.Errorf("build concerns: %w", err)
-	}
-
-	updateAnalysisCache(nextState, opts, modulePath, plans, packageResults)
+	updateAnalysisCache(nextState, opts, modulePath, languageRust, plans, packageResults)
 
 	return &Codemap{
 		ProjectRoot: root,
 		Packages:    packages,
-		Concerns:    concerns,
 	}, nil
 }
 
@@ -185,7 +184,7 @@ func buildRustPackagePlans(root string, idx *FileIndex, includeTests bool, entri
 	return plans, nil
 }
 
-func analyzeRustPackage(root string, plan packagePlan, crateName string, opts Options) (*Package, error) {
+func analyzeRustPackage(ctx context.Context, root string, plan packagePlan, crateName string, opts Options) (*Package, error) {
 	if len(plan.FileRelPaths) == 0 {
 		return nil, nil
 	}
@@ -195,15 +194,16 @@ func analyzeRustPackage(root string, plan packagePlan, crateName string, opts Op
 
 	files := make([]File, 0, len(fileRelPaths))
 	allTypes := make([]TypeInfo, 0, len(fileRelPaths))
+	var funcs []FuncInfo
 	importsSeen := make(map[string]struct{}, len(fileRelPaths))
+	externalSeen := make(map[string]struct{})
 	totalLines := 0
 	purpose := ""
 	entryPoint := ""
 	entryScore := -1
-	parser, _ := newRustParser()
-	if parser != nil {
-		defer parser.Close()
-	}
+	var tags []string
+	parser := rustParsers.get()
+	defer rustParsers.put(parser)
 
 	for _, relPath := range fileRelPaths {
 		absPath := filepath.Join(root, filepath.FromSlash(relPath))
@@ -213,6 +213,7 @@ func analyzeRustPackage(root string, plan packagePlan, crateName string, opts Op
 		}
 
 		lineCount := lineCountBytes(content)
+		recordFileParsed(ctx, relPath, lineCount)
 		totalLines += lineCount
 
 		withinPackage := relPath
@@ -223,16 +224,24 @@ func analyzeRustPackage(root string, plan packagePlan, crateName string, opts Op
 			}
 		}
 
-		filePurpose := extractRustFilePurpose(content)
+		filePurpose := extractFilePurpose(opts, languageRust, relPath, content, extractRustFilePurpose)
 		if purpose == "" && filePurpose != "" {
 			purpose = filePurpose
 		}
+		tags = mergeCodemapTags(tags, extractCodemapTags(content))
 
-		typeInfos, keyTypes, keyFuncs, imports := parseRustFileSymbolsWithParser(content, parser)
+		typeInfos, keyTypes, keyFuncs, imports, fileFuncs := parseRustFileSymbolsWithParser(content, parser)
 		allTypes = append(allTypes, typeInfos...)
+		for _, fn := range fileFuncs {
+			fn.File = withinPackage
+			funcs = append(funcs, fn)
+		}
 		for _, imp := range imports {
 			importsSeen[imp] = struct{}{}
 		}
+		for _, crate := range scanRustExternalCrates(content) {
+			externalSeen[crate] = struct{}{}
+		}
 
 		files = append(files, File{
 			Name:      withinPackage,
@@ -265,21 +274,23 @@ func analyzeRustPackage(root string, plan packagePlan, crateName string, opts Op
 		return allTypes[i].Name < allTypes[j].Name
 	})
 
-	var detailedFiles []File
-	if len(files) >= opts.LargePackageFiles {
-		detailedFiles = files
-	}
+	detailedFiles, keyFiles := packageFileDetails(files, entryPoint, opts)
 
 	return &Package{
-		ImportPath:    crateName,
-		RelativePath:  plan.RelativePath,
-		Purpose:       purpose,
-		FileCount:     len(files),
-		LineCount:     totalLines,
-		Files:         detailedFiles,
-		ExportedTypes: allTypes,
-		Imports:       internalImports,
-		EntryPoint:    entryPoint,
+		ImportPath:      crateName,
+		RelativePath:    plan.RelativePath,
+		Purpose:         purpose,
+		FileCount:       len(files),
+		LineCount:       totalLines,
+		Files:           detailedFiles,
+		KeyFiles:        keyFiles,
+		ExportedTypes:   allTypes,
+		Symbols:         packageSymbols(files, allTypes),
+		Funcs:           sortedFuncs(funcs),
+		Imports:         internalImports,
+		ExternalImports: sortedImportSet(externalSeen),
+		EntryPoint:      entryPoint,
+		Tags:            tags,
 	}, nil
 }
 
@@ -373,33 +384,37 @@ func isRustTestPath(relPath string) bool {
 }
 
 func parseRustFileSymbols(content []byte) ([]TypeInfo, []string, []string, []string) {
-	parser, err := newRustParser()
-	if err != nil {
+	parser := rustParsers.get()
+	if parser == nil {
 		return nil, nil, nil, nil
 	}
-	defer parser.Close()
+	defer rustParsers.put(parser)
 
-	return parseRustFileSymbolsWithParser(content, parser)
+	typeInfos, keyTypes, keyFuncs, imports, _ := parseRustFileSymbolsWithParser(content, parser)
+	return typeInfos, keyTypes, keyFuncs, imports
 }
 
-func parseRustFileSymbolsWithParser(content []byte, parser *sitter.Parser) ([]TypeInfo, []string, []string, []string) {
+// parseRustFileSymbolsWithParser also returns the signatures of the exported
+// functions.
+func parseRustFileSymbolsWithParser(content []byte, parser *sitter.Parser) ([]TypeInfo, []string, []string, []string, []FuncInfo) {
 	typeInfos := make([]TypeInfo, 0)
 	keyTypes := make([]string, 0)
 	keyFuncs := make([]string, 0)
 	imports := make([]string, 0)
+	var funcs []FuncInfo
 	if parser == nil {
-		return typeInfos, keyTypes, keyFuncs, imports
+		return typeInfos, keyTypes, keyFuncs, imports, funcs
 	}
 
 	tree := parser.Parse(content, nil)
 	if tree == nil {
-		return typeInfos, keyTypes, keyFuncs, imports
+		return typeInfos, keyTypes, keyFuncs, imports, funcs
 	}
 	defer tree.Close()
 
 	root := tree.RootNode()
 	if root == nil {
-		return typeInfos, keyTypes, keyFuncs, imports
+		return typeInfos, keyTypes, keyFuncs, imports, funcs
 	}
 
 	walkTreePreOrder(root, func(node *sitter.Node) {
@@ -419,6 +434,7 @@ func parseRustFileSymbolsWithParser(content []byte, parser *sitter.Parser) ([]Ty
 			name := rustNodeName(node, content)
 			if name != "" {
 				keyFuncs = append(keyFuncs, name)
+				funcs = append(funcs, FuncInfo{Name: name, Signature: declarationSignature(node, content), Comment: rustDocComment(node, content)})
 			}
 		case "use_declaration":
 			argument := node.ChildByFieldName("argument")
@@ -435,7 +451,22 @@ func parseRustFileSymbolsWithParser(content []byte, parser *sitter.Parser) ([]Ty
 		}
 	})
 
-	return typeInfos, keyTypes, keyFuncs, imports
+	return typeInfos, keyTypes, keyFuncs, imports, funcs
+}
+
+// scanRustExternalCrates returns the root crate names referenced by use and
+// extern crate items, skipping the standard library and local paths.
+func scanRustExternalCrates(content []byte) []string {
+	var crates []string
+	for _, match := range rustExternalCratePattern.FindAllSubmatch(content, -1) {
+		name := string(match[1])
+		switch name {
+		case "std", "core", "alloc", "self", "crate", "super", "Self":
+			continue
+		}
+		crates = append(crates, name)
+	}
+	return crates
 }
 
 func rustAppendTypeInfo(node *sitter.Node, content []byte, kind string, typeInfos *[]TypeInfo, keyTypes *[]string) {
@@ -446,10 +477,56 @@ func rustAppendTypeInfo(node *sitter.Node, content []byte, kind string, typeInfo
 	if name == "" {
 		return
 	}
-	*typeInfos = append(*typeInfos, TypeInfo{Name: name, Kind: kind})
+	*typeInfos = append(*typeInfos, TypeInfo{Name: name, Kind: kind, Comment: rustDocComment(node, content)})
 	*keyTypes = append(*keyTypes, name)
 }
 
+// rustDocComment returns the first sentence of the outer doc comments (/// or
+// /** */) preceding node, looking past attributes such as #[derive(...)].
+func rustDocComment(node *sitter.Node, content []byte) string {
+	var lines []string
+	for sibling := node.PrevNamedSibling(); sibling != nil; sibling = sibling.PrevNamedSibling() {
+		if sibling.Kind() == "attribute_item" {
+			continue
+		}
+		if sibling.Kind() != "line_comment" && sibling.Kind() != "block_comment" {
+			break
+		}
+		text := strings.TrimSpace(nodeText(sibling, content))
+		switch {
+		case strings.HasPrefix(text, "///") && !strings.HasPrefix(text, "////"):
+			lines = append(lines, strings.TrimSpace(strings.TrimPrefix(text, "///")))
+		case strings.HasPrefix(text, "/**") && !strings.HasPrefix(text, "/***"):
+			lines = append(lines, cleanRustBlockDoc(text))
+		default:
+			// A regular comment ends the doc block.
+			return rustJoinDocLines(lines)
+		}
+	}
+	return rustJoinDocLines(lines)
+}
+
+// rustJoinDocLines joins doc lines collected bottom-up into their first sentence.
+func rustJoinDocLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	ordered := make([]string, 0, len(lines))
+	for i := len(lines) - 1; i >= 0; i-- {
+		ordered = append(ordered, lines[i])
+	}
+	return extractFirstSentence(strings.Join(ordered, "\n"))
+}
+
+func cleanRustBlockDoc(text string) string {
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "/**"), "*/")
+	fields := strings.Split(text, "\n")
+	for i, line := range fields {
+		fields[i] = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "*"))
+	}
+	return strings.TrimSpace(strings.Join(fields, "\n"))
+}
+
 func rustNodeIsExported(node *sitter.Node) bool {
 	if node == nil {
 		return false
@@ -256,6 +256,43 @@ use crate::feature::{Runner, Mode as PublicMode};
 	}
 }
 
+func TestParseRustFileSymbolsExtractsDocComments(t *testing.T) {
+	content := []byte(`//! Crate docs are not item docs.
+
+/// Handles incoming requests.
+/// Second line is ignored.
+#[derive(Debug, Clone)]
+pub struct Service {}
+
+/**
+ * Selects the execution mode.
+ */
+pub enum Mode { Fast }
+
+// A plain comment is not documentation.
+pub trait Runner {}
+
+/// Docs for a private item stay private.
+struct Hidden {}
+
+/// Result alias
+///
+/// with more detail.
+pub type AppResult = Result<(), String>;
+`)
+
+	types, _, _, _ := parseRustFileSymbols(content)
+	want := []TypeInfo{
+		{Name: "Service", Kind: "struct", Comment: "Handles incoming requests."},
+		{Name: "Mode", Kind: "enum", Comment: "Selects the execution mode."},
+		{Name: "Runner", Kind: "trait"},
+		{Name: "AppResult", Kind: "type", Comment: "Result alias"},
+	}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("unexpected type infos:\n got %+v\nwant %+v", types, want)
+	}
+}
+
 func TestScoreRustEntryPointHeuristics(t *testing.T) {
 	mainScore := scoreRustEntryPoint("src/main.rs", nil, []string{"main"})
 	libScore := scoreRustEntryPoint("src/lib.rs", []string{"Service"}, nil)
@@ -0,0 +1,161 @@
+package codemap
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Search match kinds, in ranking order.
+const (
+	SearchKindPackage = "package"
+	SearchKindType    = "type"
+	SearchKindFunc    = "func"
+	SearchKindFile    = "file"
+	SearchKindPurpose = "purpose"
+)
+
+var searchKindWeight = map[string]int{
+	SearchKindPackage: 50,
+	SearchKindType:    40,
+	SearchKindFunc:    40,
+	SearchKindFile:    30,
+	SearchKindPurpose: 10,
+}
+
+// SearchMatch is one hit of Search.
+type SearchMatch struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Location string `json:"location"` // File path when known, otherwise the package path
+	Package  string `json:"package"`
+	Language string `json:"language,omitempty"`
+	Detail   string `json:"detail,omitempty"` // Purpose or doc comment
+	Score    int    `json:"score"`
+}
+
+// Search matches re against package paths, purposes, exported symbols and
+// file names of the cached model. Results are ranked by kind (packages, then
+// symbols, files and purposes) and by how closely the name matches; exact
+// names beat prefixes, which beat other matches. limit <= 0 returns all.
+//
+// Symbols are located by their defining file. Packages compacted by
+// Options.LowMemory keep no symbols, so only their functions kept for
+// Options.APISurface match.
+func Search(ctx context.Context, opts Options, re *regexp.Regexp, limit int) ([]SearchMatch, error) {
+	cm, idx, err := analyzeCached(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []SearchMatch
+	add := func(m SearchMatch, text string) {
+		loc := re.FindStringIndex(text)
+		if loc == nil {
+			return
+		}
+		m.Score = searchKindWeight[m.Kind] + searchMatchQuality(text, loc)
+		matches = append(matches, m)
+	}
+
+	pkgPaths := make(map[string]struct{}, len(cm.Packages))
+	for _, pkg := range cm.Packages {
+		pkgPaths[pkg.RelativePath] = struct{}{}
+		base := SearchMatch{Package: pkg.RelativePath, Language: pkg.Language, Location: pkg.RelativePath}
+
+		m := base
+		m.Kind, m.Name, m.Detail = SearchKindPackage, pkg.RelativePath, pkg.Purpose
+		if name := path.Base(pkg.RelativePath); re.MatchString(name) {
+			add(m, name)
+		} else {
+			add(m, pkg.RelativePath)
+		}
+		m.Kind, m.Name = SearchKindPurpose, pkg.RelativePath
+		add(m, pkg.Purpose)
+
+		dir := packageFileDir(pkg.RelativePath)
+		for _, file := range pkg.Files {
+			if file.Purpose != "" {
+				m := base
+				m.Kind, m.Name, m.Location, m.Detail = SearchKindPurpose, file.Name, path.Join(dir, file.Name), file.Purpose
+				add(m, file.Purpose)
+			}
+		}
+		comments := make(map[string]string, len(pkg.ExportedTypes)+len(pkg.Funcs))
+		for _, typ := range pkg.ExportedTypes {
+			comments[typ.Name] = typ.Comment
+		}
+		for _, fn := range pkg.Funcs {
+			if comments[fn.Name] == "" {
+				comments[fn.Name] = fn.Comment
+			}
+		}
+		symbols := pkg.Symbols
+		if len(symbols) == 0 {
+			for _, fn := range pkg.Funcs {
+				symbols = append(symbols, Symbol{Name: fn.Name, Kind: "func", File: fn.File})
+			}
+		}
+		for _, sym := range symbols {
+			m := base
+			m.Kind, m.Name, m.Detail = SearchKindType, sym.Name, comments[sym.Name]
+			if sym.Kind == "func" || sym.Kind == "function" {
+				m.Kind = SearchKindFunc
+			}
+			if sym.File != "" {
+				m.Location = path.Join(dir, sym.File)
+			}
+			add(m, sym.Name)
+		}
+	}
+
+	for _, rec := range idx.Files {
+		if rec.IsTest && !opts.IncludeTests {
+			continue
+		}
+		owner, ok := owningPackagePath(rec.RelPath, pkgPaths)
+		if !ok {
+			continue
+		}
+		add(SearchMatch{
+			Kind:     SearchKindFile,
+			Name:     path.Base(rec.RelPath),
+			Location: rec.RelPath,
+			Package:  owner,
+			Language: rec.Language,
+		}, path.Base(rec.RelPath))
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].Location != matches[j].Location {
+			return matches[i].Location < matches[j].Location
+		}
+		return matches[i].Name < matches[j].Name
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// searchMatchQuality rates a match at loc within text: 3 for the whole text,
+// 2 for a prefix, 1 otherwise. File extensions are ignored for whole matches.
+func searchMatchQuality(text string, loc []int) int {
+	whole := text
+	if ext := path.Ext(text); ext != "" && loc[1] <= len(text)-len(ext) {
+		whole = strings.TrimSuffix(text, ext)
+	}
+	switch {
+	case loc[0] == 0 && loc[1] == len(whole):
+		return 3
+	case loc[0] == 0:
+		return 2
+	default:
+		return 1
+	}
+}
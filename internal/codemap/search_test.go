@@ -0,0 +1,69 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestSearchRanksMatches(t *testing.T) {
+	tmpDir := t.TempDir()
+	pkgDir := filepath.Join(tmpDir, "store")
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"go.mod":         "module example.com/app\n",
+		"main.go":        "// Command app reads from the store.\npackage main\n",
+		"store/store.go": "// Package store persists records.\npackage store\n\n// Store saves records.\ntype Store struct{}\n\n// StoreOptions configures a Store.\ntype StoreOptions struct{}\n\n// DoFrobnicate frobnicates records.\nfunc DoFrobnicate() {}\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	matches, err := Search(context.Background(), opts, regexp.MustCompile("(?i)store"), 0)
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+
+	var got []string
+	for _, m := range matches {
+		got = append(got, m.Kind+":"+m.Name+"@"+m.Location)
+	}
+	want := []string{
+		"package:store@store",
+		"type:Store@store/store.go",
+		"type:StoreOptions@store/store.go",
+		"file:store.go@store/store.go",
+		"purpose:.@.",
+		"purpose:store@store",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected matches: %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("unexpected match %d: got %v want %v", i, got, want)
+		}
+	}
+
+	limited, err := Search(context.Background(), opts, regexp.MustCompile("Store"), 1)
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(limited) != 1 || limited[0].Name != "Store" {
+		t.Fatalf("expected exact type match first, got %+v", limited)
+	}
+
+	// Functions of packages without a detailed file listing match too.
+	funcs, err := Search(context.Background(), opts, regexp.MustCompile("Frobnicate"), 0)
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(funcs) != 1 || funcs[0].Kind != SearchKindFunc || funcs[0].Name != "DoFrobnicate" ||
+		funcs[0].Location != "store/store.go" || funcs[0].Detail != "DoFrobnicate frobnicates records." {
+		t.Fatalf("expected the DoFrobnicate func, got %+v", funcs)
+	}
+}
@@ -0,0 +1,112 @@
+package codemap
+
+import (
+	"sort"
+	"strings"
+)
+
+// defaultSecurityImportPatterns lists modules whose use marks a package as
+// touching crypto, auth, secret management, or unsafe deserialization.
+var defaultSecurityImportPatterns = []string{
+	// Go
+	"crypto/*",
+	"golang.org/x/crypto",
+	"golang.org/x/oauth2",
+	"github.com/golang-jwt/jwt*",
+	"github.com/hashicorp/vault",
+	"encoding/gob",
+	// Python
+	"pickle",
+	"cPickle",
+	"marshal",
+	"shelve",
+	"cryptography",
+	"Crypto",
+	"hashlib",
+	"hmac",
+	"secrets",
+	"ssl",
+	"jwt",
+	"jose",
+	"passlib",
+	"bcrypt",
+	"OpenSSL",
+	"hvac",
+	// TypeScript and JavaScript
+	"crypto",
+	"node:crypto",
+	"jsonwebtoken",
+	"bcryptjs",
+	"node-forge",
+	"passport*",
+	// Rust
+	"openssl",
+	"ring",
+	"rustls",
+	"argon2",
+	"sha2",
+}
+
+// assignSecurityImports records, per package, the external imports matching
+// any of patterns.
+func assignSecurityImports(packages []Package, patterns []string) {
+	for i := range packages {
+		var matched []string
+		for _, imp := range packages[i].ExternalImports {
+			if matchesSecurityImport(imp, patterns) {
+				matched = append(matched, imp)
+			}
+		}
+		packages[i].SecurityImports = matched
+	}
+}
+
+// matchesSecurityImport reports whether imp matches one of patterns. A
+// pattern ending in "*" is a prefix match; otherwise the import must equal the
+// pattern or be a submodule of it ("/", "." or "::" separated).
+func matchesSecurityImport(imp string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(imp, prefix) {
+				return true
+			}
+			continue
+		}
+		if imp == pattern {
+			return true
+		}
+		rest, ok := strings.CutPrefix(imp, pattern)
+		if ok && (strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "::")) {
+			return true
+		}
+	}
+	return false
+}
+
+type securityRow struct {
+	Name    string
+	Signals []string
+}
+
+// securityRows lists security-sensitive packages once per path, merging the
+// signals of packages from different languages sharing a directory.
+func securityRows(packages []Package) []securityRow {
+	var rows []securityRow
+	index := make(map[string]int)
+	for _, pkg := range packages {
+		if len(pkg.SecurityImports) == 0 {
+			continue
+		}
+		i, ok := index[pkg.RelativePath]
+		if !ok {
+			i = len(rows)
+			index[pkg.RelativePath] = i
+			rows = append(rows, securityRow{Name: pkg.RelativePath})
+		}
+		rows[i].Signals = append(rows[i].Signals, pkg.SecurityImports...)
+	}
+	for i := range rows {
+		sort.Strings(rows[i].Signals)
+	}
+	return rows
+}
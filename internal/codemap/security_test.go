@@ -0,0 +1,105 @@
+package codemap
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchesSecurityImport(t *testing.T) {
+	patterns := []string{"crypto/*", "golang.org/x/crypto", "pickle", "passport*", "openssl"}
+	tests := map[string]bool{
+		"crypto/sha256":                true,
+		"crypto":                       false,
+		"golang.org/x/crypto/bcrypt":   true,
+		"golang.org/x/cryptography":    false,
+		"pickle":                       true,
+		"pickle.loads":                 true,
+		"pickletools":                  false,
+		"passport-jwt":                 true,
+		"openssl::ssl":                 true,
+		"github.com/example/something": false,
+	}
+	for imp, want := range tests {
+		if got := matchesSecurityImport(imp, patterns); got != want {
+			t.Fatalf("matchesSecurityImport(%q) = %v, want %v", imp, got, want)
+		}
+	}
+}
+
+func TestScanExternalImports(t *testing.T) {
+	ts := []byte(`import jwt from "jsonwebtoken";
+import { helper } from './helper';
+export * from "@scope/pkg/sub";
+const forge = require('node-forge');
+const lazy = await import("bcryptjs");
+`)
+	if got, want := scanTypeScriptExternalImports(ts), []string{"jsonwebtoken", "@scope/pkg/sub", "node-forge", "bcryptjs"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected TypeScript imports: got %v want %v", got, want)
+	}
+
+	rs := []byte(`use std::collections::HashMap;
+use crate::config::Config;
+pub use ring::digest;
+extern crate openssl;
+use ::serde::Deserialize;
+`)
+	if got, want := scanRustExternalCrates(rs), []string{"ring", "openssl", "serde"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected Rust crates: got %v want %v", got, want)
+	}
+}
+
+func TestAnalyzeFlagsSecuritySensitivePackages(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":            "module example.com/test\n\ngo 1.22\n",
+		"auth/token.go":     "package auth\n\nimport (\n\t\"crypto/hmac\"\n\t\"fmt\"\n)\n\nvar _ = hmac.New\nvar _ = fmt.Sprint\n",
+		"plain/plain.go":    "package plain\n\nimport \"fmt\"\n\nvar _ = fmt.Sprint\n",
+		"cache/loader.py":   "import pickle\nfrom os import path\n",
+		"cache/__init__.py": "",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+
+	signals := make(map[string][]string)
+	for _, pkg := range cm.Packages {
+		if len(pkg.SecurityImports) > 0 {
+			signals[pkg.Language] = pkg.SecurityImports
+		}
+	}
+	want := map[string][]string{
+		languageGo:     {"crypto/hmac"},
+		languagePython: {"pickle"},
+	}
+	if !reflect.DeepEqual(signals, want) {
+		t.Fatalf("unexpected security signals: got %v want %v", signals, want)
+	}
+
+	content, err := Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if !strings.Contains(content, "## Security-Sensitive Packages") ||
+		!strings.Contains(content, "| auth `security-sensitive` | crypto/hmac |") {
+		t.Fatalf("expected security section in output:\n%s", content)
+	}
+
+	opts.SecurityImportPatterns = []string{"fmt"}
+	cm, err = Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	for _, pkg := range cm.Packages {
+		flagged := len(pkg.SecurityImports) > 0
+		if wantFlagged := pkg.Language == languageGo; flagged != wantFlagged {
+			t.Fatalf("custom patterns: package %s (%s) flagged=%v", pkg.RelativePath, pkg.Language, flagged)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package codemap
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sentenceAbbreviations end in a period without ending the sentence. "etc."
+// is handled separately since it often closes one.
+var sentenceAbbreviations = map[string]struct{}{
+	"e.g":    {},
+	"i.e":    {},
+	"cf":     {},
+	"vs":     {},
+	"viz":    {},
+	"approx": {},
+	"incl":   {},
+	"esp":    {},
+	"al":     {},
+	"resp":   {},
+	"fig":    {},
+}
+
+// extractFirstSentence returns the first sentence of a doc comment, used by
+// every analyzer for purposes. A sentence ends at a newline or at ".", "!" or
+// "?" followed by whitespace or the end of the text, except inside `code
+// spans` and after abbreviations such as "e.g.", so version numbers, file
+// names and URLs ("v1.2", "config.yaml", "https://example.com") stay whole.
+// Text without an ending is cut at 100 runes.
+func extractFirstSentence(text string) string {
+	text = strings.TrimSpace(stripCodemapTagLines(text))
+	if text == "" {
+		return ""
+	}
+
+	inCode := false
+	for i, r := range text {
+		switch r {
+		case '`':
+			inCode = !inCode
+		case '\n':
+			return strings.TrimSuffix(strings.TrimSpace(text[:i]), ".")
+		case '.', '!', '?':
+			if !inCode && endsSentence(text, i, r) {
+				return strings.TrimSpace(text[:i+1])
+			}
+		}
+	}
+
+	if runes := []rune(text); len(runes) > 100 {
+		return string(runes[:100]) + "..."
+	}
+	return text
+}
+
+// endsSentence reports whether the punctuation r at text[i] ends a sentence.
+func endsSentence(text string, i int, r rune) bool {
+	rest := text[i+1:]
+	if rest == "" {
+		return true
+	}
+	next, _ := utf8.DecodeRuneInString(rest)
+	if !unicode.IsSpace(next) {
+		return false
+	}
+	if r != '.' {
+		return true
+	}
+
+	word := text[:i]
+	if j := strings.LastIndexFunc(word, unicode.IsSpace); j >= 0 {
+		word = word[j+1:]
+	}
+	word = strings.ToLower(strings.TrimLeft(word, "(\"'"))
+	if _, ok := sentenceAbbreviations[word]; ok {
+		return false
+	}
+	if word == "etc" {
+		// "foo, bar, etc. and more" continues; "etc. More" starts anew.
+		following, _ := utf8.DecodeRuneInString(strings.TrimLeftFunc(rest, unicode.IsSpace))
+		return !unicode.IsLower(following)
+	}
+	return true
+}
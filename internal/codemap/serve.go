@@ -0,0 +1,46 @@
+package codemap
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// StaleHealth is the JSON body of the /healthz/stale endpoint.
+type StaleHealth struct {
+	Stale       bool   `json:"stale"`
+	WithinGrace bool   `json:"withinGrace,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// NewHTTPHandler serves health endpoints for the project in opts:
+//
+//	/healthz        200 while the server is up
+//	/healthz/stale  200 when outputs are fresh (or stale within
+//	                opts.StalenessGrace), 409 when stale, 500 on errors
+func NewHTTPHandler(opts Options) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("GET /healthz/stale", func(w http.ResponseWriter, r *http.Request) {
+		var body StaleHealth
+		status := http.StatusOK
+		stale, withinGrace, err := IsStaleWithGrace(r.Context(), opts)
+		switch {
+		case err != nil:
+			body.Error = err.Error()
+			status = http.StatusInternalServerError
+		case stale && !withinGrace:
+			body.Stale = true
+			status = http.StatusConflict
+		default:
+			body.Stale = stale
+			body.WithinGrace = withinGrace
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	})
+	return mux
+}
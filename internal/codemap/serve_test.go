@@ -0,0 +1,56 @@
+package codemap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHTTPHandlerReportsStaleness(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/test\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatalf("write go.mod: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("write main.go: %v", err)
+	}
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	server := httptest.NewServer(NewHTTPHandler(opts))
+	defer server.Close()
+
+	check := func(wantStatus int, wantStale bool) {
+		t.Helper()
+		resp, err := http.Get(server.URL + "/healthz/stale")
+		if err != nil {
+			t.Fatalf("GET /healthz/stale: %v", err)
+		}
+		defer resp.Body.Close()
+		var body StaleHealth
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		if resp.StatusCode != wantStatus || body.Stale != wantStale {
+			t.Fatalf("got status %d body %+v, want %d stale=%v", resp.StatusCode, body, wantStatus, wantStale)
+		}
+	}
+
+	check(http.StatusConflict, true)
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	check(http.StatusOK, false)
+
+	resp, err := http.Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("GET /healthz: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected liveness 200, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,204 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Service keeps the codemap model of one project in memory for long-running
+// hosts such as the daemon and editor integrations.
+type Service struct {
+	opts Options
+
+	mu    sync.Mutex
+	model *Codemap
+}
+
+// NewService constructs a service for opts.
+func NewService(opts Options) *Service {
+	return &Service{opts: opts}
+}
+
+// Model returns the most recently generated or loaded model, or nil before
+// the first refresh.
+func (s *Service) Model() *Codemap {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.model
+}
+
+// Refresh regenerates outputs if they are stale (always with force) and
+// reports whether anything was written.
+func (s *Service) Refresh(ctx context.Context, force bool) (*Codemap, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshLocked(ctx, force)
+}
+
+func (s *Service) refreshLocked(ctx context.Context, force bool) (*Codemap, bool, error) {
+	var (
+		cm        *Codemap
+		generated bool
+		err       error
+	)
+	if force {
+		cm, err = Generate(ctx, s.opts)
+		generated = err == nil
+	} else {
+		cm, generated, err = EnsureUpToDate(ctx, s.opts)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if generated {
+		s.model = cm
+	} else if s.model == nil {
+		// Outputs were already fresh; analyze once so the model is available in memory.
+		cm, err = Analyze(ctx, s.opts)
+		if err != nil {
+			return nil, false, err
+		}
+		root, _ := filepath.Abs(s.opts.ProjectRoot)
+		outputPath := s.opts.OutputPath
+		if outputPath == "" {
+			outputPath = MarkdownRenderer{}.DefaultPath()
+		}
+		cm.ContentHash, _ = readExistingHash(filepath.Join(root, outputPath), s.opts.HashScanLines)
+		s.model = cm
+	}
+	return s.model, generated, nil
+}
+
+// RefreshPackage re-indexes and re-hashes only the files of the package at
+// relPath, then regenerates outputs with every other package served from the
+// analysis cache. It is meant for editors that know which file was saved;
+// changes elsewhere are picked up by the next full refresh. Unknown packages
+// and the root package fall back to a full refresh.
+func (s *Service) RefreshPackage(ctx context.Context, relPath string) (*Codemap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	relPath = path.Clean(filepath.ToSlash(relPath))
+	if s.model == nil {
+		if _, _, err := s.refreshLocked(ctx, false); err != nil {
+			return nil, err
+		}
+	}
+
+	root, err := filepath.Abs(s.opts.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	opts := withDefaultOutputPaths(s.opts)
+
+	statePath := resolveStatePath(root, opts)
+	state, err := readState(statePath)
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	pkgPaths := make(map[string]struct{}, len(s.model.Packages))
+	for _, pkg := range s.model.Packages {
+		pkgPaths[pkg.RelativePath] = struct{}{}
+	}
+	if _, known := pkgPaths[relPath]; !known || relPath == "." || state == nil || !indexStateMatches(state, opts) {
+		cm, _, err := s.refreshLocked(ctx, false)
+		return cm, err
+	}
+
+	idx, err := packageRefreshIndex(ctx, root, opts, state, relPath, pkgPaths)
+	if err != nil {
+		return nil, fmt.Errorf("build file index: %w", err)
+	}
+	currentHash, nextState, err := computeAggregateHash(ctx, idx, state)
+	if err != nil {
+		return nil, fmt.Errorf("compute hash: %w", err)
+	}
+	cm, _, err := generateOutputs(ctx, root, opts, statePath, state, nextState, currentHash, idx)
+	if err != nil {
+		return nil, err
+	}
+	s.model = cm
+	return cm, nil
+}
+
+// packageRefreshIndex combines a fresh scan of the package directory at
+// relPath with the recorded state of every file owned by other packages.
+// Directory metadata outside the package is copied from state, so later fast
+// staleness checks still notice changes made elsewhere.
+func packageRefreshIndex(ctx context.Context, root string, opts Options, state *CodemapState, relPath string, pkgPaths map[string]struct{}) (*FileIndex, error) {
+	// Index patterns are relative to the project root, so they are applied
+	// below once scanned paths are re-rooted.
+	filter := newPathFilter(opts)
+	scanOpts := opts
+	scanOpts.ExcludePatterns, scanOpts.IncludePatterns = nil, nil
+	pkgDir := filepath.Join(root, filepath.FromSlash(relPath))
+	scanned, err := buildFileIndex(ctx, pkgDir, languageSpecsFor(opts), scanOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &FileIndex{
+		Root:              root,
+		RootEntries:       append([]string(nil), state.RootEntries...),
+		GitIgnore:         state.GitIgnore,
+		GitTracked:        state.GitTracked,
+		IgnoreFiles:       append([]IgnoreFileState(nil), state.IgnoreFiles...),
+		ExcludePatterns:   state.ExcludePatterns,
+		IncludePatterns:   state.IncludePatterns,
+		NestedCodemaps:    state.NestedCodemaps,
+		NestedRoots:       append([]string(nil), state.NestedRoots...),
+		ExternalLanguages: state.ExternalLanguages,
+		Languages:         state.Languages,
+	}
+	for _, entry := range state.Entries {
+		if owner, _ := owningPackagePath(entry.RelPath, pkgPaths); owner == relPath {
+			continue
+		}
+		idx.Files = append(idx.Files, FileRecord{
+			AbsPath:         filepath.Join(root, filepath.FromSlash(entry.RelPath)),
+			RelPath:         entry.RelPath,
+			Size:            entry.Size,
+			ModTimeUnixNano: entry.ModTimeUnixNano,
+			Language:        entry.Language,
+			IsGo:            entry.Language == languageGo,
+			IsTest:          entry.IsTest,
+		})
+	}
+	for _, rec := range scanned.Files {
+		rec.RelPath = relPath + "/" + rec.RelPath
+		if filter.skipFile(rec.RelPath) {
+			continue
+		}
+		if owner, _ := owningPackagePath(rec.RelPath, pkgPaths); owner != relPath {
+			continue
+		}
+		idx.Files = append(idx.Files, rec)
+	}
+
+	idx.Dirs = append(idx.Dirs, DirRecord{RelPath: "."})
+	for _, dir := range state.Dirs {
+		if dir.RelPath != relPath && !strings.HasPrefix(dir.RelPath, relPath+"/") {
+			idx.Dirs = append(idx.Dirs, DirRecord(dir))
+		}
+	}
+	for _, dir := range scanned.Dirs {
+		if dir.RelPath == "." {
+			dir.RelPath = relPath
+		} else {
+			dir.RelPath = relPath + "/" + dir.RelPath
+		}
+		if filter.skipDirTree(dir.RelPath) {
+			continue
+		}
+		idx.Dirs = append(idx.Dirs, dir)
+	}
+
+	sort.Slice(idx.Files, func(i, j int) bool { return walkOrderLess(idx.Files[i].RelPath, idx.Files[j].RelPath) })
+	sort.Slice(idx.Dirs, func(i, j int) bool { return walkOrderLess(idx.Dirs[i].RelPath, idx.Dirs[j].RelPath) })
+	return idx, nil
+}
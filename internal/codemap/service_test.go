@@ -0,0 +1,78 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServiceRefreshPackageReanalyzesOnlyThatPackage(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/test\n\ngo 1.22\n",
+		"a/a.go": "package a\n",
+		"b/b.go": "package b\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	counter := &profileCounter{}
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.Instrumentation = counter
+	svc := NewService(opts)
+	ctx := context.Background()
+	if _, generated, err := svc.Refresh(ctx, false); err != nil || !generated {
+		t.Fatalf("Refresh = generated %v, err %v", generated, err)
+	}
+
+	writeFile := func(rel, content string) {
+		if err := os.WriteFile(filepath.Join(tmpDir, filepath.FromSlash(rel)), []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+	writeFile("a/a.go", "// Package a does alpha things.\npackage a\n")
+	writeFile("a/extra.go", "package a\n\nfunc Extra() {}\n")
+	writeFile("b/b.go", "// Package b does beta things.\npackage b\n")
+
+	counter.analyzed.Store(0)
+	cm, err := svc.RefreshPackage(ctx, "a/")
+	if err != nil {
+		t.Fatalf("RefreshPackage returned error: %v", err)
+	}
+	if got := counter.analyzed.Load(); got != 1 {
+		t.Fatalf("expected one package analyzed, got %d", got)
+	}
+	purposes := make(map[string]string)
+	fileCounts := make(map[string]int)
+	for _, pkg := range cm.Packages {
+		purposes[pkg.RelativePath] = pkg.Purpose
+		fileCounts[pkg.RelativePath] = pkg.FileCount
+	}
+	if purposes["a"] != "Package a does alpha things." || fileCounts["a"] != 2 {
+		t.Fatalf("expected refreshed package a, got purpose %q with %d files", purposes["a"], fileCounts["a"])
+	}
+	if purposes["b"] != "" {
+		t.Fatalf("expected package b to be served from cache, got purpose %q", purposes["b"])
+	}
+	if svc.Model() != cm {
+		t.Fatal("expected the service model to be updated")
+	}
+
+	stale, err := IsStale(ctx, opts)
+	if err != nil {
+		t.Fatalf("IsStale returned error: %v", err)
+	}
+	if !stale {
+		t.Fatal("expected changes outside the refreshed package to leave outputs stale")
+	}
+	cm, _, err = svc.Refresh(ctx, false)
+	if err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	for _, pkg := range cm.Packages {
+		if pkg.RelativePath == "b" && pkg.Purpose != "Package b does beta things." {
+			t.Fatalf("expected full refresh to pick up b, got %q", pkg.Purpose)
+		}
+	}
+}
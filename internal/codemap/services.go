@@ -0,0 +1,280 @@
+package codemap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// serviceManifests mark a directory that builds on its own, in the order
+// one is reported when several are present.
+var serviceManifests = []string{
+	"go.mod",
+	"package.json",
+	"Cargo.toml",
+	"pyproject.toml",
+	"setup.py",
+	"requirements.txt",
+	"Gemfile",
+}
+
+// serviceDeployFiles are container and deployment configs that mark a
+// directory as deployable. Dockerfile variants are matched separately.
+var serviceDeployFiles = map[string]struct{}{
+	"Containerfile":       {},
+	"docker-compose.yml":  {},
+	"docker-compose.yaml": {},
+	"compose.yml":         {},
+	"compose.yaml":        {},
+	"Procfile":            {},
+	"app.yaml":            {},
+	"fly.toml":            {},
+	"render.yaml":         {},
+	"serverless.yml":      {},
+	"serverless.yaml":     {},
+	"skaffold.yaml":       {},
+	"Chart.yaml":          {},
+	"kustomization.yaml":  {},
+}
+
+// serviceDeployDirs hold Kubernetes manifests, Helm charts or other
+// deployment configs for the directory they sit in.
+var serviceDeployDirs = map[string]struct{}{
+	"k8s":         {},
+	"kubernetes":  {},
+	"helm":        {},
+	"charts":      {},
+	"deploy":      {},
+	"deployment":  {},
+	"deployments": {},
+}
+
+// serviceEntryStems are entry file names, without extension, that start a
+// program rather than export a library.
+var serviceEntryStems = map[string]struct{}{
+	"main":     {},
+	"__main__": {},
+	"server":   {},
+	"app":      {},
+	"index":    {},
+	"manage":   {},
+	"wsgi":     {},
+	"asgi":     {},
+}
+
+// detectServices finds deployable services: directories with their own
+// manifest, a container or deployment config, and a package whose entry file
+// starts a program. Packages belong to the deepest manifest directory
+// containing them, so a monorepo root with its own go.mod does not claim the
+// entry points of the services below it. owners are the CODEOWNERS rules of
+// root.
+func detectServices(aux *auxInputs, packages []Package, owners []codeOwnersRule) []ServiceInfo {
+	candidates := make(map[string]struct{})
+	for _, pkg := range packages {
+		if isNestedCodemap(pkg) {
+			continue
+		}
+		for dir := packageFileDir(pkg.RelativePath); ; dir = path.Dir(dir) {
+			if _, seen := candidates[dir]; seen {
+				break
+			}
+			candidates[dir] = struct{}{}
+			if dir == "." {
+				break
+			}
+		}
+	}
+
+	manifests := make(map[string]string)
+	configs := make(map[string][]string)
+	for dir := range candidates {
+		entries, err := aux.readDir(dir, "services")
+		if err != nil {
+			continue
+		}
+		names := make(map[string]struct{}, len(entries))
+		for _, entry := range entries {
+			name := entry.Name()
+			names[name] = struct{}{}
+			if entry.IsDir() {
+				if _, ok := serviceDeployDirs[name]; ok {
+					configs[dir] = append(configs[dir], name+"/")
+				}
+			} else if isServiceDeployFile(name) {
+				configs[dir] = append(configs[dir], name)
+			}
+		}
+		for _, manifest := range serviceManifests {
+			if _, ok := names[manifest]; ok {
+				manifests[dir] = manifest
+				break
+			}
+		}
+	}
+
+	entries := make(map[string]string)
+	for _, pkg := range packages {
+		if isNestedCodemap(pkg) {
+			continue
+		}
+		entry := entryPath(pkg)
+		if !isServiceEntry(entry) {
+			continue
+		}
+		dir := packageFileDir(pkg.RelativePath)
+		for ; ; dir = path.Dir(dir) {
+			if _, ok := manifests[dir]; ok || dir == "." {
+				break
+			}
+		}
+		if current, ok := entries[dir]; !ok || serviceEntryLess(entry, current) {
+			entries[dir] = entry
+		}
+	}
+
+	var services []ServiceInfo
+	for dir, manifest := range manifests {
+		if len(configs[dir]) == 0 || entries[dir] == "" {
+			continue
+		}
+		name := path.Base(dir)
+		if dir == "." {
+			name = rootManifestName(aux, manifest)
+		}
+		sort.Strings(configs[dir])
+		services = append(services, ServiceInfo{
+			Name:       name,
+			Path:       dir,
+			Manifest:   manifest,
+			EntryPoint: entries[dir],
+			Config:     configs[dir],
+			Owners:     dirOwners(owners, dir),
+		})
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Path < services[j].Path })
+	return services
+}
+
+// isServiceDirEntry reports whether detectServices looks at a directory
+// entry: a manifest, a deployment config or a deployment config directory.
+func isServiceDirEntry(name string, isDir bool) bool {
+	if isDir {
+		_, ok := serviceDeployDirs[name]
+		return ok
+	}
+	return slices.Contains(serviceManifests, name) || isServiceDeployFile(name)
+}
+
+// rootManifestName names a service or binary at the project root after the
+// name its manifest declares, so it does not depend on the checkout
+// directory's name. Manifests without a name, such as requirements.txt, fall
+// back to that directory's name.
+func rootManifestName(aux *auxInputs, manifest string) string {
+	if name := manifestName(aux, manifest); name != "" {
+		return name
+	}
+	return filepath.Base(aux.root)
+}
+
+// manifestName returns the last path element of the module or package name
+// declared by the manifest at relPath: the go.mod module path, the
+// package.json name, the [package] name of Cargo.toml or the [project] or
+// [tool.poetry] name of pyproject.toml. It is empty for other manifests.
+func manifestName(aux *auxInputs, relPath string) string {
+	var name string
+	switch path.Base(relPath) {
+	case "go.mod", "package.json", "Cargo.toml", "pyproject.toml":
+	default:
+		return ""
+	}
+	content, err := aux.readFile(relPath)
+	if err != nil {
+		return ""
+	}
+	switch path.Base(relPath) {
+	case "go.mod":
+		name = goModModulePath(content)
+	case "package.json":
+		var manifest struct {
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(content, &manifest) == nil {
+			name = strings.TrimSpace(manifest.Name)
+		}
+	default:
+		name = tomlSectionName(content, "[package]", "[project]", "[tool.poetry]")
+	}
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// goModModulePath returns the module path of a go.mod.
+func goModModulePath(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if rest, ok := strings.CutPrefix(line, "module"); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			rest, _, _ = strings.Cut(rest, "//")
+			return strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return ""
+}
+
+// tomlSectionName returns the name key of the first of sections found in a
+// TOML manifest.
+func tomlSectionName(content []byte, sections ...string) string {
+	names := make(map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+		if strings.HasPrefix(line, "[") {
+			section = line
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "name" {
+			if _, seen := names[section]; !seen {
+				names[section] = strings.Trim(strings.TrimSpace(value), `"'`)
+			}
+		}
+	}
+	for _, section := range sections {
+		if name := names[section]; name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+func isServiceDeployFile(name string) bool {
+	if _, ok := serviceDeployFiles[name]; ok {
+		return true
+	}
+	return name == "Dockerfile" || strings.HasPrefix(name, "Dockerfile.") || strings.HasSuffix(name, ".Dockerfile") || strings.HasSuffix(name, ".dockerfile")
+}
+
+func isServiceEntry(entry string) bool {
+	base := path.Base(entry)
+	if base == "config.ru" {
+		return true
+	}
+	_, ok := serviceEntryStems[strings.TrimSuffix(base, path.Ext(base))]
+	return ok
+}
+
+// serviceEntryLess prefers shallower entry files, then the lexically first.
+func serviceEntryLess(a, b string) bool {
+	if da, db := strings.Count(a, "/"), strings.Count(b, "/"); da != db {
+		return da < db
+	}
+	return a < b
+}
@@ -0,0 +1,200 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectServices(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                             "module example.com/platform\n\ngo 1.22\n",
+		"docker-compose.yml":                 "services: {}\n",
+		"pkg/log/log.go":                     "package log\n\nfunc Info() {}\n",
+		"services/api/go.mod":                "module example.com/api\n\ngo 1.22\n",
+		"services/api/Dockerfile":            "FROM scratch\n",
+		"services/api/k8s/deployment.yaml":   "kind: Deployment\n",
+		"services/api/cmd/api/main.go":       "package main\n\nfunc main() {}\n",
+		"services/api/internal/store/db.go":  "package store\n\nfunc Open() {}\n",
+		"services/web/package.json":          "{\"name\": \"web\"}\n",
+		"services/web/Dockerfile.prod":       "FROM node\n",
+		"services/web/src/server.ts":         "export const port = 8080;\n",
+		"services/worker/pyproject.toml":     "[project]\nname = \"worker\"\n",
+		"services/worker/worker/__init__.py": "",
+		"services/worker/worker/jobs.py":     "def run():\n    pass\n",
+		"libs/ui/package.json":               "{\"name\": \"ui\"}\n",
+		"libs/ui/Dockerfile":                 "FROM node\n",
+		"libs/ui/button.ts":                  "export const button = 1;\n",
+		".github/CODEOWNERS":                 "* @acme/platform\n/services/api/ @acme/api-team # API owners\n/services/web/ @acme/web @alice\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	want := []ServiceInfo{
+		{
+			Name:       "api",
+			Path:       "services/api",
+			Manifest:   "go.mod",
+			EntryPoint: "services/api/cmd/api/main.go",
+			Config:     []string{"Dockerfile", "k8s/"},
+			Owners:     []string{"@acme/api-team"},
+		},
+		{
+			Name:       "web",
+			Path:       "services/web",
+			Manifest:   "package.json",
+			EntryPoint: "services/web/src/server.ts",
+			Config:     []string{"Dockerfile.prod"},
+			Owners:     []string{"@acme/web", "@alice"},
+		},
+	}
+	if !reflect.DeepEqual(cm.Services, want) {
+		t.Fatalf("unexpected services:\n got %+v\nwant %+v", cm.Services, want)
+	}
+
+	markdown, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(markdown), "## Services") || !strings.Contains(string(markdown), "| api | services/api | services/api/cmd/api/main.go | go.mod, Dockerfile, k8s/ | @acme/api-team |") {
+		t.Fatalf("expected a services section:\n%s", markdown)
+	}
+}
+
+func TestServiceConfigChangeMakesOutputsStale(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                  "module example.com/gateway\n\ngo 1.22\n",
+		"cmd/gateway/main.go":     "package main\n\nfunc main() {}\n",
+		"internal/route/route.go": "package route\n\nfunc Match() {}\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if len(cm.Services) != 0 {
+		t.Fatalf("unexpected services without a deployment config: %+v", cm.Services)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "Dockerfile"), []byte("FROM scratch\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+		t.Fatalf("IsStale after adding a Dockerfile = %v, %v; want true", stale, err)
+	}
+	cm, generated, err := EnsureUpToDate(context.Background(), opts)
+	if err != nil || !generated {
+		t.Fatalf("EnsureUpToDate = %v, %v; want a regeneration", generated, err)
+	}
+	// The root service is named after the module, not the checkout directory.
+	want := []ServiceInfo{{
+		Name:       "gateway",
+		Path:       ".",
+		Manifest:   "go.mod",
+		EntryPoint: "cmd/gateway/main.go",
+		Config:     []string{"Dockerfile"},
+	}}
+	if !reflect.DeepEqual(cm.Services, want) {
+		t.Fatalf("unexpected services:\n got %+v\nwant %+v", cm.Services, want)
+	}
+	if stale, err := IsStale(context.Background(), opts); err != nil || stale {
+		t.Fatalf("IsStale after regenerating = %v, %v; want false", stale, err)
+	}
+}
+
+func TestDirOwnersUsesLastMatchingRule(t *testing.T) {
+	rules := parseCodeOwners("# Owners\n* @all\n[Docs]\ndocs/ @docs\n/docs/internal/\n*.go @gophers\n")
+	cases := map[string][]string{
+		".":             {"@all"},
+		"src":           {"@all"},
+		"docs":          {"@docs"},
+		"docs/guide":    {"@docs"},
+		"docs/internal": {},
+	}
+	for dir, want := range cases {
+		if got := dirOwners(rules, dir); len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Fatalf("dirOwners(%q) = %v, want %v", dir, got, want)
+		}
+	}
+}
+
+func TestPackageOwnersFromCodeOwners(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":           "module example.com/app\n\ngo 1.22\n",
+		"CODEOWNERS":       "* @acme/core\n/billing/ @acme/billing @bob\n/scratch/\n",
+		"api/api.go":       "// Package api serves requests.\npackage api\n",
+		"billing/bill.go":  "package billing\n",
+		"scratch/draft.go": "package scratch\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	owners := make(map[string][]string)
+	for _, pkg := range cm.Packages {
+		owners[pkg.RelativePath] = pkg.Owners
+	}
+	want := map[string][]string{
+		"api":     {"@acme/core"},
+		"billing": {"@acme/billing", "@bob"},
+		"scratch": {},
+	}
+	if !reflect.DeepEqual(owners, want) {
+		t.Fatalf("unexpected owners: %v", owners)
+	}
+
+	markdown, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(markdown), "| Package | Entry File | Purpose | Owners |") ||
+		!strings.Contains(string(markdown), "billing | billing/bill.go |  | @acme/billing, @bob |") {
+		t.Fatalf("expected an Owners column:\n%s", markdown)
+	}
+	paths, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.paths"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(paths), "api\tapi/api.go\tPackage api serves requests.\t@acme/core\n") ||
+		!strings.Contains(string(paths), "billing\tbilling/bill.go\t\t@acme/billing @bob\n") {
+		t.Fatalf("expected owners in CODEMAP.paths:\n%s", paths)
+	}
+
+	// .github/CODEOWNERS takes precedence over the root file once it exists.
+	if err := os.MkdirAll(filepath.Join(tmpDir, ".github"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, ".github", "CODEOWNERS"), []byte("* @acme/platform\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+		t.Fatalf("IsStale after adding .github/CODEOWNERS = %v, %v; want true", stale, err)
+	}
+	cm, generated, err := EnsureUpToDate(context.Background(), opts)
+	if err != nil || !generated {
+		t.Fatalf("EnsureUpToDate = %v, %v; want a regeneration", generated, err)
+	}
+	for _, pkg := range cm.Packages {
+		if !reflect.DeepEqual(pkg.Owners, []string{"@acme/platform"}) {
+			t.Fatalf("owners of %s = %v after CODEOWNERS changed", pkg.RelativePath, pkg.Owners)
+		}
+	}
+}
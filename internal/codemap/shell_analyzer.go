@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -25,7 +26,7 @@ func (ShellAnalyzer) Analyze(ctx context.Context, in AnalysisInput) (*Codemap, e
 
 func analyzeShellWithIndex(ctx context.Context, root string, idx *FileIndex, opts Options, prevState, nextState *CodemapState) (*Codemap, error) {
 	entryByRel := stateEntryByRelPath(nextState)
-	plans, err := buildShellPackagePlans(root, idx, opts.IncludeTests, entryByRel)
+	plans, err := buildShellPackagePlans(root, idx, opts, entryByRel)
 	if err != nil {
 		return nil, err
 	}
@@ -40,18 +41,20 @@ func analyzeShellWithIndex(ctx context.Context, root string, idx *FileIndex, opt
 		if cached, ok := cachedByRel[plan.RelativePath]; ok && plan.Fingerprint != "" && cached.Fingerprint == plan.Fingerprint {
 			pkg := cached.Package
 			packageResults[i] = &pkg
+			recordCacheHit(ctx, opts, languageShell, plan.RelativePath)
 			continue
 		}
 		jobs = append(jobs, analysisJob{
-			index: i,
-			dir:   plan.DirAbsPath,
+			index:   i,
+			dir:     plan.DirAbsPath,
+			relPath: plan.RelativePath,
 		})
 	}
 
-	if err := analyzePackagePlansParallel(ctx, opts, jobs, packageResults, func(job analysisJob) (*Package, error) {
+	if err := analyzePackagePlansParallel(ctx, opts, languageShell, jobs, packageResults, func(ctx context.Context, job analysisJob) (*Package, error) {
 		plan := plans[job.index]
 		packageName := shellPackageName(root, plan.RelativePath)
-		pkg, err := analyzeShellPackage(root, plan, packageName, opts)
+		pkg, err := analyzeShellPackage(ctx, root, plan, packageName, opts)
 		if err != nil {
 			return nil, fmt.Errorf("analyze shell package %s: %w", plan.RelativePath, err)
 		}
@@ -67,21 +70,15 @@ func analyzeShellWithIndex(ctx context.Context, root string, idx *FileIndex, opt
 		}
 	}
 
-	concerns, err := buildConcerns(idx, opts.Concerns, opts.ConcernExampleLimit)
-	if err != nil {
-		return nil, fmt.Errorf("build concerns: %w", err)
-	}
-
-	updateAnalysisCache(nextState, opts, modulePath, plans, packageResults)
+	updateAnalysisCache(nextState, opts, modulePath, languageShell, plans, packageResults)
 
 	return &Codemap{
 		ProjectRoot: root,
 		Packages:    packages,
-		Concerns:    concerns,
 	}, nil
 }
 
-func buildShellPackagePlans(root string, idx *FileIndex, includeTests bool, entriesByRel map[string]StateEntry) ([]packagePlan, error) {
+func buildShellPackagePlans(root string, idx *FileIndex, opts Options, entriesByRel map[string]StateEntry) ([]packagePlan, error) {
 	plansByRel := make(map[string]*packagePlan)
 	rootAbs, err := filepath.Abs(root)
 	if err != nil {
@@ -92,14 +89,14 @@ func buildShellPackagePlans(root string, idx *FileIndex, includeTests bool, entr
 		if rec.Language != languageShell {
 			continue
 		}
-		if !includeTests && isShellTestPath(rec.RelPath, rec.IsTest) {
+		if !opts.IncludeTests && isShellTestPath(rec.RelPath, rec.IsTest) {
 			continue
 		}
 
-		pkgRel := shellPackageRootRel(rec.RelPath)
+		pkgRel := shellPackageRel(rec.RelPath, opts)
 		pkgAbs := rootAbs
-		if pkgRel != "." {
-			pkgAbs = filepath.Join(rootAbs, filepath.FromSlash(pkgRel))
+		if dir := packageFileDir(pkgRel); dir != "." {
+			pkgAbs = filepath.Join(rootAbs, filepath.FromSlash(dir))
 		}
 
 		plan, ok := plansByRel[pkgRel]
@@ -131,22 +128,19 @@ func buildShellPackagePlans(root string, idx *FileIndex, includeTests bool, entr
 	return plans, nil
 }
 
-func analyzeShellPackage(root string, plan packagePlan, packageName string, opts Options) (*Package, error) {
+func analyzeShellPackage(ctx context.Context, root string, plan packagePlan, packageName string, opts Options) (*Package, error) {
 	if len(plan.FileRelPaths) == 0 {
 		return nil, nil
 	}
 
-	includeDetailedFiles := len(plan.FileRelPaths) >= opts.LargePackageFiles
-	var files []File
-	if includeDetailedFiles {
-		files = make([]File, 0, len(plan.FileRelPaths))
-	}
+	files := make([]File, 0, len(plan.FileRelPaths))
 	importsSeen := make(map[string]struct{}, len(plan.FileRelPaths))
 	totalLines := 0
 	purpose := ""
 	entryPoint := ""
 	entryScore := -1
 	firstFileName := ""
+	var tags []string
 
 	for _, relPath := range plan.FileRelPaths {
 		absPath := filepath.Join(root, filepath.FromSlash(relPath))
@@ -156,8 +150,8 @@ func analyzeShellPackage(root string, plan packagePlan, packageName string, opts
 		}
 
 		withinPackage := relPath
-		if plan.RelativePath != "." {
-			prefix := plan.RelativePath + "/"
+		if dir := packageFileDir(plan.RelativePath); dir != "." {
+			prefix := dir + "/"
 			if strings.HasPrefix(relPath, prefix) {
 				withinPackage = strings.TrimPrefix(relPath, prefix)
 			}
@@ -166,25 +160,25 @@ func analyzeShellPackage(root string, plan packagePlan, packageName string, opts
 			firstFileName = withinPackage
 		}
 
-		filePurpose := extractShellFilePurpose(content)
+		filePurpose := extractFilePurpose(opts, languageShell, relPath, content, extractShellFilePurpose)
 		if purpose == "" && filePurpose != "" {
 			purpose = filePurpose
 		}
+		tags = mergeCodemapTags(tags, extractCodemapTags(content))
 
 		keyFuncs, imports, lineCount := parseShellFileSymbols(content)
+		recordFileParsed(ctx, relPath, lineCount)
 		totalLines += lineCount
 		for _, imp := range imports {
 			importsSeen[imp] = struct{}{}
 		}
 
-		if includeDetailedFiles {
-			files = append(files, File{
-				Name:      withinPackage,
-				LineCount: lineCount,
-				Purpose:   filePurpose,
-				KeyFuncs:  keyFuncs,
-			})
-		}
+		files = append(files, File{
+			Name:      withinPackage,
+			LineCount: lineCount,
+			Purpose:   filePurpose,
+			KeyFuncs:  keyFuncs,
+		})
 
 		score := scoreShellEntryPoint(withinPackage, keyFuncs)
 		if score > entryScore || (score == entryScore && (entryPoint == "" || withinPackage < entryPoint)) {
@@ -209,10 +203,7 @@ func analyzeShellPackage(root string, plan packagePlan, packageName string, opts
 	}
 	sort.Strings(internalImports)
 
-	var detailedFiles []File
-	if includeDetailedFiles {
-		detailedFiles = files
-	}
+	detailedFiles, keyFiles := packageFileDetails(files, entryPoint, opts)
 
 	return &Package{
 		ImportPath:    packageName,
@@ -221,12 +212,54 @@ func analyzeShellPackage(root string, plan packagePlan, packageName string, opts
 		FileCount:     len(plan.FileRelPaths),
 		LineCount:     totalLines,
 		Files:         detailedFiles,
+		KeyFiles:      keyFiles,
 		ExportedTypes: nil,
+		Symbols:       packageSymbols(files, nil),
 		Imports:       internalImports,
 		EntryPoint:    entryPoint,
+		Tags:          tags,
 	}, nil
 }
 
+// Shell grouping modes for Options.ShellGrouping.
+const (
+	ShellGroupDir = "dir" // Each directory, folding scripts/ and bin/ trees into their parent
+	ShellGroupTop = "top" // Each top-level directory; root scripts form one package
+)
+
+// shellPackageRel returns the package path of a shell script under the
+// grouping configured in opts. Scripts whose file names match one of
+// opts.ShellPrefixes form one package per pattern within that package, with
+// the pattern as the last path element (e.g. "scripts/deploy-*").
+func shellPackageRel(relPath string, opts Options) string {
+	var pkgRel string
+	if opts.ShellGrouping == ShellGroupTop {
+		top, _, nested := strings.Cut(relPath, "/")
+		pkgRel = "."
+		if nested {
+			pkgRel = top
+		}
+	} else {
+		pkgRel = shellPackageRootRel(relPath)
+	}
+	base := path.Base(relPath)
+	for _, prefix := range opts.ShellPrefixes {
+		pattern := shellPrefixPattern(prefix)
+		if matched, _ := path.Match(pattern, base); matched {
+			return path.Join(pkgRel, pattern)
+		}
+	}
+	return pkgRel
+}
+
+// shellPrefixPattern turns a bare prefix such as "deploy-" into "deploy-*".
+func shellPrefixPattern(prefix string) string {
+	if strings.ContainsAny(prefix, "*?[") {
+		return prefix
+	}
+	return prefix + "*"
+}
+
 func shellPackageRootRel(relPath string) string {
 	if guessedRel, guessed := likelyPackageRootRelBySegments(relPath, []string{"scripts", "script", "bin", "hack", "tools", "tests", "test"}); guessed {
 		if pathContainsSegment(relPath, "scripts") || pathContainsSegment(relPath, "bin") {
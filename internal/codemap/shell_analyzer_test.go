@@ -267,3 +267,41 @@ func TestAnalyzeShellWithIndexSkipsBrokenPackageAndKeepsHealthyOnes(t *testing.T
 		t.Fatalf("expected healthy package to remain, got %+v", cm.Packages[0])
 	}
 }
+
+func TestShellGroupingByTopLevelDirAndPrefix(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"build.sh":                  "#!/bin/sh\necho build\n",
+		"ops/deploy-web.sh":         "#!/bin/sh\n# Deploy the web tier.\necho web\n",
+		"ops/aws/deploy-db.sh":      "#!/bin/sh\necho db\n",
+		"ops/aws/rotate-keys.sh":    "#!/bin/sh\necho rotate\n",
+		"ops/gcp/sync.sh":           "#!/bin/sh\necho sync\n",
+		"tools/lint/check-style.sh": "#!/bin/sh\necho lint\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.ShellGrouping = ShellGroupTop
+	opts.ShellPrefixes = []string{"deploy-"}
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	var rows []string
+	for _, pkg := range cm.Packages {
+		rows = append(rows, pkg.RelativePath+" "+entryPath(pkg)+" "+pkg.Purpose)
+	}
+	want := []string{
+		". build.sh Shell scripts in " + filepath.Base(tmpDir),
+		"ops ops/aws/rotate-keys.sh Shell scripts in ops",
+		"ops/deploy-* ops/aws/deploy-db.sh Deploy the web tier.",
+		"tools tools/lint/check-style.sh Shell scripts in tools",
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("unexpected packages:\n got %q\nwant %q", rows, want)
+	}
+	if len(cm.Warnings) != 0 {
+		t.Fatalf("expected grouped entry points to resolve, got %v", cm.Warnings)
+	}
+}
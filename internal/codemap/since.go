@@ -0,0 +1,249 @@
+package codemap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// indexFromState builds the file index from prev like
+// buildFileIndexFromState. With Options.Since set to the commit prev was
+// written at, it trusts prev for every file git reports unchanged since
+// then, so only changed files are statted, hashed and analyzed; unchanged is
+// then always false. Since naming another commit builds the index without
+// trusting prev that way, as a state older or newer than the ref could
+// describe files git does not report.
+func indexFromState(ctx context.Context, absRoot string, opts Options, prev *CodemapState, ignoredRootEntries map[string]struct{}) (*FileIndex, bool, error) {
+	if opts.Since != "" {
+		commit, err := gitCommit(ctx, absRoot, opts.Since)
+		if err != nil {
+			return nil, false, err
+		}
+		if prev != nil && prev.Version == codemapStateVersion && len(prev.Entries) > 0 && prev.AggregateHash != "" && commit == prev.GitHead {
+			idx, err := buildFileIndexSince(ctx, absRoot, prev, opts)
+			if err != nil {
+				return nil, false, err
+			}
+			return idx, false, nil
+		}
+	}
+	return buildFileIndexFromState(ctx, absRoot, prev, ignoredRootEntries)
+}
+
+// buildFileIndexSince copies the files recorded in prev into an index and
+// re-reads only the paths git reports changed since prev.GitHead, the commit
+// prev was written at: modified, added, deleted and untracked files, plus
+// those prev.GitDirty lists as differing from that commit back then. Edits
+// git cannot see, such as files it ignores, are missed until a run without
+// Since.
+func buildFileIndexSince(ctx context.Context, absRoot string, prev *CodemapState, opts Options) (*FileIndex, error) {
+	changed, err := gitChangedFiles(ctx, absRoot, prev.GitHead)
+	if err != nil {
+		return nil, err
+	}
+	changedSet := make(map[string]struct{}, len(changed))
+	for _, relPath := range changed {
+		changedSet[relPath] = struct{}{}
+	}
+	for _, relPath := range prev.GitDirty {
+		if _, ok := changedSet[relPath]; !ok {
+			changedSet[relPath] = struct{}{}
+			changed = append(changed, relPath)
+		}
+	}
+
+	idx := &FileIndex{
+		Root:              absRoot,
+		GitIgnore:         opts.GitIgnore,
+		GitTracked:        opts.GitTracked,
+		IgnoreFiles:       prev.IgnoreFiles,
+		ExcludePatterns:   opts.ExcludePatterns,
+		IncludePatterns:   opts.IncludePatterns,
+		NestedCodemaps:    opts.NestedCodemaps,
+		NestedRoots:       prev.NestedRoots,
+		ExternalLanguages: externalLanguageSignatures(opts),
+		Languages:         languageFilterIDs(opts),
+	}
+	rootEntries, err := os.ReadDir(absRoot)
+	if err != nil {
+		return nil, fmt.Errorf("read root: %w", err)
+	}
+	for _, entry := range rootEntries {
+		idx.RootEntries = append(idx.RootEntries, entry.Name())
+	}
+
+	for _, entry := range prev.Entries {
+		if _, ok := changedSet[entry.RelPath]; ok {
+			continue
+		}
+		absPath := filepath.Join(absRoot, filepath.FromSlash(entry.RelPath))
+		match, err := resolveStateEntryLanguage(entry, absPath)
+		if err != nil {
+			return nil, err
+		}
+		if match.ID == "" {
+			continue
+		}
+		idx.Files = append(idx.Files, FileRecord{
+			AbsPath:         absPath,
+			RelPath:         entry.RelPath,
+			Size:            entry.Size,
+			ModTimeUnixNano: entry.ModTimeUnixNano,
+			Language:        match.ID,
+			IsGo:            match.ID == languageGo,
+			IsTest:          match.IsTest,
+		})
+	}
+
+	filter := newPathFilter(opts)
+	languageSpecs := languageSpecsFor(opts)
+	nestedRoots := make(map[string]struct{}, len(prev.NestedRoots))
+	for _, dir := range prev.NestedRoots {
+		nestedRoots[dir] = struct{}{}
+	}
+	dirs := make(map[string]int64, len(prev.Dirs))
+	for _, dir := range prev.Dirs {
+		dirs[dir.RelPath] = dir.ModTimeUnixNano
+	}
+	for _, relPath := range changed {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		if trackedPathExcluded(relPath) || filter.skipDirTree(path.Dir(relPath)) || filter.skipFile(relPath) || nestedRootOf(relPath, nestedRoots) != "" {
+			continue
+		}
+		for dir := path.Dir(relPath); ; dir = path.Dir(dir) {
+			if info, err := os.Stat(filepath.Join(absRoot, filepath.FromSlash(dir))); err == nil {
+				dirs[dir] = info.ModTime().UnixNano()
+			} else {
+				delete(dirs, dir)
+			}
+			if dir == "." {
+				break
+			}
+		}
+		absPath := filepath.Join(absRoot, filepath.FromSlash(relPath))
+		if filter.skipGenerated(absPath) {
+			continue
+		}
+		info, err := os.Lstat(absPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // Deleted since the ref
+			}
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		langMatch, ok, err := detectLanguageForFile(absPath, path.Base(relPath), languageSpecs)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || shouldSkipIndexedFile(langMatch.ID, relPath, info.Size()) {
+			continue
+		}
+		idx.Files = append(idx.Files, FileRecord{
+			AbsPath:         absPath,
+			RelPath:         relPath,
+			Size:            info.Size(),
+			ModTimeUnixNano: info.ModTime().UnixNano(),
+			Language:        langMatch.ID,
+			IsGo:            langMatch.ID == languageGo,
+			IsTest:          langMatch.IsTest,
+		})
+	}
+	for dir, modTime := range dirs {
+		idx.Dirs = append(idx.Dirs, DirRecord{RelPath: dir, ModTimeUnixNano: modTime})
+	}
+
+	sort.Strings(idx.RootEntries)
+	sort.Slice(idx.Files, func(i, j int) bool { return walkOrderLess(idx.Files[i].RelPath, idx.Files[j].RelPath) })
+	sort.Slice(idx.Dirs, func(i, j int) bool { return walkOrderLess(idx.Dirs[i].RelPath, idx.Dirs[j].RelPath) })
+	return idx, nil
+}
+
+// gitChangedFiles lists the files under root that differ between ref and the
+// work tree, with renames reported as a deletion and an addition, plus
+// untracked files git does not ignore. Paths are relative to root.
+func gitChangedFiles(ctx context.Context, root, ref string) ([]string, error) {
+	diff, err := gitLines(ctx, root, "diff", "--name-only", "--no-renames", "--relative", "-z", ref, "--")
+	if err != nil {
+		return nil, fmt.Errorf("list files changed since %s: %w", ref, err)
+	}
+	untracked, err := gitLines(ctx, root, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, fmt.Errorf("list untracked files: %w", err)
+	}
+
+	seen := make(map[string]struct{}, len(diff)+len(untracked))
+	var files []string
+	for _, file := range append(diff, untracked...) {
+		if _, dup := seen[file]; dup {
+			continue
+		}
+		seen[file] = struct{}{}
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// gitCommit resolves ref to a commit hash in root.
+func gitCommit(ctx context.Context, root, ref string) (string, error) {
+	lines, err := gitLines(ctx, root, "rev-parse", "--verify", "--quiet", "--end-of-options", ref+"^{commit}")
+	if err != nil || len(lines) == 0 {
+		return "", fmt.Errorf("resolve %s: not a commit", ref)
+	}
+	return strings.TrimSpace(lines[0]), nil
+}
+
+// recordGitHead stores in next the commit HEAD points at and the files that
+// differ from it, so a later run with Options.Since at that commit can trust
+// next for the rest. It records nothing without Since or outside a work
+// tree.
+func recordGitHead(ctx context.Context, root string, opts Options, next *CodemapState) {
+	next.GitHead, next.GitDirty = "", nil
+	if opts.Since == "" {
+		return
+	}
+	head, err := gitCommit(ctx, root, "HEAD")
+	if err != nil {
+		return
+	}
+	dirty, err := gitChangedFiles(ctx, root, head)
+	if err != nil {
+		return
+	}
+	next.GitHead, next.GitDirty = head, dirty
+}
+
+// gitLines runs git in dir and splits its NUL-separated output.
+func gitLines(ctx context.Context, dir string, args ...string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\x00") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,114 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestSinceRereadsOnlyFilesChangedSinceRef(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	tmpDir := t.TempDir()
+	write := func(files map[string]string) {
+		writeTestTree(t, tmpDir, files)
+	}
+	write(map[string]string{
+		"go.mod":          "module example.com/test\n\ngo 1.22\n",
+		".gitignore":      "scratch/\n",
+		"a/a.go":          "package a\n",
+		"old/old.go":      "package old\n",
+		"scratch/note.go": "package scratch\n",
+	})
+	runGit := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tmpDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	runGit("init", "-q")
+	runGit("add", ".")
+	runGit("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial")
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.Since = "HEAD"
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+
+	if err := os.RemoveAll(filepath.Join(tmpDir, "old")); err != nil {
+		t.Fatal(err)
+	}
+	write(map[string]string{
+		"a/a.go":          "package a\n\n// Widget is new.\ntype Widget struct{}\n",
+		"c/c.go":          "package c\n",
+		"scratch/note.go": "package scratch\n\n// Hidden is invisible to git.\ntype Hidden struct{}\n",
+	})
+
+	cm, generated, err := EnsureUpToDate(context.Background(), opts)
+	if err != nil || !generated {
+		t.Fatalf("EnsureUpToDate returned %v (err %v)", generated, err)
+	}
+	lines := make(map[string]int)
+	for _, pkg := range cm.Packages {
+		lines[pkg.RelativePath] = pkg.LineCount
+	}
+	if _, ok := lines["old"]; ok {
+		t.Fatalf("expected the deleted package to be dropped: %v", lines)
+	}
+	if lines["a"] != 4 || lines["c"] != 1 {
+		t.Fatalf("expected changed and added files to be re-read: %v", lines)
+	}
+	if lines["scratch"] != 1 {
+		t.Fatalf("expected files git ignores to come from the state: %v", lines)
+	}
+
+	// The state now records a.go as differing from HEAD, so reverting it
+	// is picked up although git no longer lists it.
+	runGit("checkout", "-q", "--", "a/a.go")
+	cm, generated, err = EnsureUpToDate(context.Background(), opts)
+	if err != nil || !generated {
+		t.Fatalf("EnsureUpToDate after reverting returned %v (err %v)", generated, err)
+	}
+	if got := packageLines(cm, "a"); got != 1 {
+		t.Fatalf("expected the reverted file to be re-read, got %d lines", got)
+	}
+
+	// Once HEAD moves past the commit the state was written at, git's diff
+	// from HEAD would miss the committed change, so the tree is walked.
+	write(map[string]string{"a/a.go": "package a\n\n// Gadget is committed.\ntype Gadget struct{}\n"})
+	runGit("add", ".")
+	runGit("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "gadget")
+	cm, generated, err = EnsureUpToDate(context.Background(), opts)
+	if err != nil || !generated {
+		t.Fatalf("EnsureUpToDate after committing returned %v (err %v)", generated, err)
+	}
+	if got := packageLines(cm, "a"); got != 4 {
+		t.Fatalf("expected the committed change to be read, got %d lines", got)
+	}
+	if got := packageLines(cm, "scratch"); got != 4 {
+		t.Fatalf("expected the walk to re-read files git ignores, got %d lines", got)
+	}
+
+	opts.Since = "no-such-ref"
+	if _, _, err := EnsureUpToDate(context.Background(), opts); err == nil {
+		t.Fatal("expected an error for an unknown ref")
+	}
+}
+
+func packageLines(cm *Codemap, relPath string) int {
+	for _, pkg := range cm.Packages {
+		if pkg.RelativePath == relPath {
+			return pkg.LineCount
+		}
+	}
+	return 0
+}
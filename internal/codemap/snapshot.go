@@ -0,0 +1,172 @@
+package codemap
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// modelSnapshotVersion is bumped when the ModelSnapshot layout changes in a
+// way older releases cannot import.
+const modelSnapshotVersion = 1
+
+// ErrSnapshotMismatch is returned by ImportModel when the project tree
+// differs from the one the snapshot was exported from. The caches are still
+// imported; only the outputs are left alone.
+var ErrSnapshotMismatch = errors.New("snapshot does not match the project tree")
+
+// ModelSnapshot is the portable archive written by ExportModel: the analyzed
+// model plus the state and analysis caches it was built with, as gzipped
+// JSON. File paths in it are relative to the project root, so it imports on
+// any checkout of the same tree.
+type ModelSnapshot struct {
+	Version int
+	Model   *Codemap
+	State   *CodemapState // Includes the analysis cache as State.Analysis
+}
+
+// ExportModel analyzes opts.ProjectRoot, reusing and refreshing its caches
+// like Warm, and writes the model and caches to w as a ModelSnapshot.
+func ExportModel(ctx context.Context, opts Options, w io.Writer) (*Codemap, error) {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	cm, err := Warm(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	state, err := readState(resolveStatePath(root, opts))
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	analysisCache, err := readAnalysisCache(resolveAnalysisStatePath(root, opts))
+	if err != nil {
+		return nil, fmt.Errorf("read analysis cache: %w", err)
+	}
+
+	// Record the options the model was analyzed with, so importing it under
+	// other options leaves stale outputs.
+	state = cloneCodemapState(state)
+	if state != nil {
+		state.OptionsSignature = outputOptionsSignature(opts)
+	}
+
+	model := *cm
+	model.ProjectRoot = ""
+	snapshot := ModelSnapshot{
+		Version: modelSnapshotVersion,
+		Model:   &model,
+		State:   mergeStateWithAnalysis(state, analysisCache),
+	}
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(snapshot); err != nil {
+		return nil, fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("write snapshot: %w", err)
+	}
+	return cm, nil
+}
+
+// ImportModel reads a ModelSnapshot from r into opts.ProjectRoot. The state
+// and analysis caches are always imported, so the next run only re-analyzes
+// what differs. When the tree hashes to the snapshot's ContentHash, the
+// outputs opts enables are rendered from the imported model without
+// analyzing anything and the returned bool is true; otherwise the error wraps
+// ErrSnapshotMismatch.
+func ImportModel(ctx context.Context, opts Options, r io.Reader) (*Codemap, bool, error) {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return nil, false, fmt.Errorf("resolve root: %w", err)
+	}
+	snapshot, err := readModelSnapshot(r)
+	if err != nil {
+		return nil, false, err
+	}
+
+	opts = withDefaultOutputPaths(opts)
+
+	idx, err := buildIndex(ctx, root, opts)
+	if err != nil {
+		return nil, false, fmt.Errorf("build file index: %w", err)
+	}
+	statePath := resolveStatePath(root, opts)
+	state, err := readState(statePath)
+	if err != nil {
+		return nil, false, fmt.Errorf("read state: %w", err)
+	}
+	// Entries of the exported state carry the exporting checkout's mtimes,
+	// so every file is re-hashed here rather than trusted.
+	hash, nextState, err := computeAggregateHash(ctx, idx, snapshot.State)
+	if err != nil {
+		return nil, false, fmt.Errorf("compute hash: %w", err)
+	}
+	if snapshot.State != nil && snapshot.State.Analysis != nil && snapshot.State.Analysis.Version == analysisCacheVersion {
+		if err := writeAnalysisCache(resolveAnalysisStatePath(root, opts), snapshot.State.Analysis); err != nil {
+			return nil, false, fmt.Errorf("write analysis cache: %w", err)
+		}
+	}
+
+	if hash != snapshot.Model.ContentHash {
+		// Keep the local outputs, and the state recording them, as they are.
+		if state != nil {
+			nextState.Outputs = state.Outputs
+			nextState.AuxFiles = state.AuxFiles
+			nextState.OptionsSignature = state.OptionsSignature
+			nextState.Packages = state.Packages
+			nextState.SplitPages = state.SplitPages
+		}
+		if err := writeState(statePath, nextState); err != nil {
+			return nil, false, fmt.Errorf("write state: %w", err)
+		}
+		return nil, false, fmt.Errorf("%w: exported %s, local %s", ErrSnapshotMismatch, shortHash(snapshot.Model.ContentHash), shortHash(hash))
+	}
+
+	cm := snapshot.Model
+	cm.ProjectRoot = root
+	if err := renderOutputs(root, opts, nextState, cm); err != nil {
+		return nil, false, err
+	}
+	// The model was analyzed with the exporter's options and auxiliary
+	// files; if local ones differ, the next staleness check notices.
+	if snapshot.State != nil {
+		nextState.AuxFiles = snapshot.State.AuxFiles
+		nextState.OptionsSignature = snapshot.State.OptionsSignature
+	}
+	nextState.Outputs = trackedOutputs(root, opts, state)
+	if err := writeState(statePath, nextState); err != nil {
+		return nil, false, fmt.Errorf("write state: %w", err)
+	}
+	return cm, true, nil
+}
+
+func readModelSnapshot(r io.Reader) (*ModelSnapshot, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+	defer zr.Close()
+	var snapshot ModelSnapshot
+	if err := json.NewDecoder(zr).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+	if snapshot.Version != modelSnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d (want %d)", snapshot.Version, modelSnapshotVersion)
+	}
+	if snapshot.Model == nil {
+		return nil, errors.New("snapshot has no model")
+	}
+	return &snapshot, nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
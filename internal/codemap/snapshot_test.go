@@ -0,0 +1,74 @@
+package codemap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportImportModel(t *testing.T) {
+	files := map[string]string{
+		"go.mod":              "module example.com/app\n\ngo 1.22\n",
+		"internal/foo/foo.go": "// Package foo does foo.\npackage foo\n\n// Foo is exported.\ntype Foo struct{}\n",
+		"cmd/app/main.go":     "package main\n\nimport _ \"example.com/app/internal/foo\"\n\nfunc main() {}\n",
+	}
+	writeTree := func(root string) {
+		writeTestTree(t, root, files)
+	}
+	farm, local := t.TempDir(), t.TempDir()
+	writeTree(farm)
+	writeTree(local)
+	ctx := context.Background()
+
+	farmOpts := DefaultOptions()
+	farmOpts.ProjectRoot = farm
+	var archive bytes.Buffer
+	exported, err := ExportModel(ctx, farmOpts, &archive)
+	if err != nil {
+		t.Fatalf("ExportModel returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(farm, "CODEMAP.md")); !os.IsNotExist(err) {
+		t.Fatalf("expected export to write no outputs, stat err = %v", err)
+	}
+
+	localOpts := DefaultOptions()
+	localOpts.ProjectRoot = local
+	localOpts.JSONOutputPath = "CODEMAP.json"
+	imported, written, err := ImportModel(ctx, localOpts, bytes.NewReader(archive.Bytes()))
+	if err != nil || !written {
+		t.Fatalf("ImportModel = written %v, err %v", written, err)
+	}
+	if imported.ContentHash != exported.ContentHash || len(imported.Packages) != len(exported.Packages) {
+		t.Fatalf("imported model differs: %+v vs %+v", imported, exported)
+	}
+	want, err := Render(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(local, "CODEMAP.md"))
+	if err != nil {
+		t.Fatalf("read imported markdown: %v", err)
+	}
+	if string(got) != want {
+		t.Fatalf("imported CODEMAP.md differs from export:\n%s\nwant:\n%s", got, want)
+	}
+	if stale, err := IsStale(ctx, localOpts); err != nil || stale {
+		t.Fatalf("expected imported outputs to be fresh, got stale=%v err=%v", stale, err)
+	}
+	if cache, err := readAnalysisCache(resolveAnalysisStatePath(local, localOpts)); err != nil || cache == nil || len(cache.Packages) == 0 {
+		t.Fatalf("expected imported analysis cache, got %+v err=%v", cache, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(local, "internal/foo/bar.go"), []byte("package foo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, written, err := ImportModel(ctx, localOpts, bytes.NewReader(archive.Bytes())); !errors.Is(err, ErrSnapshotMismatch) || written {
+		t.Fatalf("expected ErrSnapshotMismatch without writing outputs, got written=%v err=%v", written, err)
+	}
+	if _, _, err := ImportModel(ctx, localOpts, bytes.NewReader([]byte("not an archive"))); err == nil {
+		t.Fatalf("expected error for a malformed archive")
+	}
+}
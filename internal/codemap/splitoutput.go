@@ -0,0 +1,299 @@
+package codemap
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// splitIndexName is the page listing every package page. Page names are
+// anchor slugs, which never contain "_", so it cannot clash with one.
+const splitIndexName = "_index.md"
+
+const packagePageTemplate = `<!-- codemap-hash: {{.Hash}} -->
+<!-- Regenerate: codemap -->
+
+# {{.Path}}
+
+Back to [{{.IndexName}}]({{.Index}}#{{.Anchor}})
+{{range .Packages}}{{$lang := ""}}{{if gt (len $.Packages) 1}}{{$lang = printf " (%s)" .Language}}{{end}}
+## Overview{{$lang}}
+
+{{with .Purpose}}{{.}}
+
+{{end}}- Language: {{.Language}}
+{{- if and .ImportPath (ne .ImportPath .RelativePath)}}
+- Import path: ` + "`{{.ImportPath}}`" + `
+{{- end}}
+- Entry file: ` + "`{{entryPath .}}`" + `
+- Size: {{.FileCount}} files, {{.LineCount}} lines
+{{- with .Owners}}
+- Owners: {{join . ", "}}
+{{- end}}
+{{- with .Tags}}
+- Tags: {{join . ", "}}
+{{- end}}
+{{with pageFiles .}}
+## Files{{$lang}}
+
+| File | Lines | Purpose | Types | Funcs |
+|------|-------|---------|-------|-------|
+{{- range .}}
+| {{.Name}} | {{.LineCount}} | {{.Purpose}} | {{join .KeyTypes ", "}} | {{join .KeyFuncs ", "}} |
+{{- end}}
+{{end}}{{with .ExportedTypes}}
+## Exported API{{$lang}}
+
+| Name | Kind | Comment |
+|------|------|---------|
+{{- range .}}
+| {{.Name}} | {{.Kind}} | {{.Comment}} |
+{{- end}}
+{{end}}{{with .Implementations}}
+## Implementations{{$lang}}
+
+| Type | Implements |
+|------|------------|
+{{- range .}}
+| {{.Type}} | {{join .Interfaces ", "}} |
+{{- end}}
+{{end}}{{if or .DependsOn .ExternalImports}}
+## Imports{{$lang}}
+{{with .DependsOn}}
+Project packages: {{range $i, $dep := .}}{{if $i}}, {{end}}{{with pageLink $dep}}[{{$dep}}]({{.}}){{else}}{{$dep}}{{end}}{{end}}
+{{end}}{{with .ExternalImports}}
+External: {{join . ", "}}
+{{end}}{{end}}{{end}}`
+
+const splitIndexTemplate = `<!-- codemap-hash: {{.Hash}} -->
+<!-- Regenerate: codemap -->
+
+# Package Pages
+
+One page per package of [{{.IndexName}}]({{.Index}}).
+{{range .Pages}}
+- [{{.Path}}]({{.Name}}){{with .Purpose}}: {{.}}{{end}}
+{{- end}}
+`
+
+// packagePage is one file written to Options.SplitOutputDir.
+type packagePage struct {
+	Name      string // File name within the directory
+	Path      string // Package path shared by Packages
+	Purpose   string
+	Anchor    string // Row anchor in the markdown output
+	Packages  []Package
+	Hash      string
+	Index     string // Markdown output, relative to the directory
+	IndexName string
+}
+
+// packagePageNames returns the page file of each package: its row anchor
+// without the "pkg-" prefix, so pages share the anchors' collision handling.
+// Packages of several languages in one directory share the first one's page.
+func packagePageNames(packages []Package) []string {
+	ids := packageAnchorIDs(packages)
+	byPath := make(map[string]string, len(packages))
+	names := make([]string, len(packages))
+	for i, pkg := range packages {
+		if ids[i] != "" {
+			byPath[pkg.RelativePath] = strings.TrimPrefix(ids[i], "pkg-") + ".md"
+		}
+		names[i] = byPath[pkg.RelativePath]
+	}
+	return names
+}
+
+// packagePages groups packages into the pages written for them, in the order
+// of the markdown rows.
+func packagePages(packages []Package) []*packagePage {
+	ids := packageAnchorIDs(packages)
+	names := packagePageNames(packages)
+	var pages []*packagePage
+	byName := make(map[string]*packagePage, len(packages))
+	for i, pkg := range packages {
+		page := byName[names[i]]
+		if page == nil {
+			page = &packagePage{Name: names[i], Path: pkg.RelativePath, Purpose: pkg.Purpose, Anchor: ids[i]}
+			byName[names[i]] = page
+			pages = append(pages, page)
+		}
+		page.Packages = append(page.Packages, pkg)
+	}
+	return pages
+}
+
+// pageFiles returns the files listed on a package page: the full listing
+// when the package has one, otherwise its key files, by name.
+func pageFiles(pkg Package) []File {
+	if len(pkg.Files) > 0 {
+		return pkg.Files
+	}
+	files := append([]File(nil), pkg.KeyFiles...)
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files
+}
+
+// writeSplitOutputs writes one page per package directory to
+// Options.SplitOutputDir plus an index of them, written last so its hash
+// stands for the whole set, and records the pages in nextState. Pages the
+// last run recorded for packages that no longer exist are removed; other
+// files in the directory are left alone.
+func writeSplitOutputs(root string, opts Options, nextState *CodemapState, cm *Codemap) error {
+	if opts.SplitOutputDir == "" {
+		return nil
+	}
+	dir := outputAbsPath(root, opts.SplitOutputDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create split output directory: %w", err)
+	}
+	if opts.OutputPath == "" {
+		opts.OutputPath = MarkdownRenderer{}.DefaultPath()
+	}
+	index := relativeLink(root, filepath.Join(opts.SplitOutputDir, splitIndexName), opts.OutputPath)
+	indexName := path.Base(index)
+
+	pages := packagePages(cm.Packages)
+	pageByPath := make(map[string]string, len(pages))
+	for _, page := range pages {
+		page.Hash, page.Index, page.IndexName = cm.ContentHash, index, indexName
+		pageByPath[page.Path] = page.Name
+	}
+	funcs := template.FuncMap{
+		"entryPath": entryPath,
+		"join":      strings.Join,
+		"pageFiles": pageFiles,
+		"pageLink":  func(pkgPath string) string { return pageByPath[pkgPath] },
+	}
+	pageTmpl, err := template.New("page").Funcs(funcs).Parse(packagePageTemplate)
+	if err != nil {
+		return fmt.Errorf("parse package page template: %w", err)
+	}
+	indexTmpl, err := template.New("index").Parse(splitIndexTemplate)
+	if err != nil {
+		return fmt.Errorf("parse page index template: %w", err)
+	}
+
+	current := make(map[string]struct{}, len(pages)+1)
+	current[outputStateName(root, filepath.Join(dir, splitIndexName))] = struct{}{}
+	for _, page := range pages {
+		current[outputStateName(root, filepath.Join(dir, page.Name))] = struct{}{}
+	}
+	if err := removeStalePages(root, opts, dir, current); err != nil {
+		return err
+	}
+	nextState.SplitPages = sortedImportSet(current)
+
+	write := func(name string, tmpl *template.Template, data any) error {
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, data); err != nil {
+			return fmt.Errorf("render %s: %w", name, err)
+		}
+		pagePath := filepath.Join(dir, name)
+		if err := os.WriteFile(pagePath, []byte(sb.String()), 0644); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+		return nil
+	}
+	for _, page := range pages {
+		if err := write(page.Name, pageTmpl, page); err != nil {
+			return err
+		}
+	}
+	return write(splitIndexName, indexTmpl, struct {
+		Hash, Index, IndexName string
+		Pages                  []*packagePage
+	}{cm.ContentHash, index, indexName, pages})
+}
+
+// removeStalePages deletes the pages in dir that the last run recorded but
+// current does not list, provided they still carry a codemap-hash header.
+// Pages the last run wrote to another directory are left alone.
+func removeStalePages(root string, opts Options, dir string, current map[string]struct{}) error {
+	prev, err := readState(resolveStatePath(root, opts))
+	if err != nil {
+		return fmt.Errorf("read state: %w", err)
+	}
+	if prev == nil {
+		return nil
+	}
+	for _, name := range prev.SplitPages {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		pagePath := outputAbsPath(root, name)
+		if filepath.Dir(pagePath) != dir {
+			continue
+		}
+		if hash, err := readPageHash(pagePath, opts.HashScanLines); err != nil || hash == "" {
+			continue
+		}
+		if err := os.Remove(pagePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove stale page %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// checkSplitOutputDir rejects an Options.SplitOutputDir that is the project
+// root or holds another output, whose files page cleanup could remove.
+func checkSplitOutputDir(root string, opts Options) error {
+	if opts.SplitOutputDir == "" {
+		return nil
+	}
+	dir := outputAbsPath(root, opts.SplitOutputDir)
+	if dir == root {
+		return fmt.Errorf("split output directory %s is the project root", opts.SplitOutputDir)
+	}
+	outputs := append(currentOutputPaths(root, opts), opts.AgentsInjectPaths...)
+	outputs = append(outputs, resolveStatePath(root, opts), resolveAnalysisStatePath(root, opts))
+	for _, output := range outputs {
+		if filepath.Dir(outputAbsPath(root, output)) == dir {
+			return fmt.Errorf("split output directory %s holds the output %s", opts.SplitOutputDir, output)
+		}
+	}
+	return nil
+}
+
+// splitOutputsStale reports whether the page index is missing or was written
+// for another hash than existingHash.
+func splitOutputsStale(root string, opts Options, existingHash string) (bool, error) {
+	if opts.SplitOutputDir == "" {
+		return false, nil
+	}
+	hash, err := readPageHash(filepath.Join(outputAbsPath(root, opts.SplitOutputDir), splitIndexName), opts.HashScanLines)
+	if err != nil {
+		return false, fmt.Errorf("read existing page index hash: %w", err)
+	}
+	return hash == "" || hash != existingHash, nil
+}
+
+// readPageHash returns the codemap-hash header of a page, or "" when it is
+// missing. Pages bypass readExistingHash's cache: there can be thousands of
+// them, and a deleted index must read as missing within the same process.
+func readPageHash(pagePath string, scanLines int) (string, error) {
+	f, err := os.Open(pagePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer f.Close()
+	return scanHashHeader(f, scanLines)
+}
+
+// relativeLink returns the slash path from the directory of the file from to
+// the path to, both relative to root unless absolute.
+func relativeLink(root, from, to string) string {
+	target := outputAbsPath(root, to)
+	rel, err := filepath.Rel(filepath.Dir(outputAbsPath(root, from)), target)
+	if err != nil {
+		return filepath.ToSlash(target)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,145 @@
+package codemap
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPackagePageNames(t *testing.T) {
+	packages := []Package{
+		{RelativePath: "."},
+		{RelativePath: "web", Language: languageJavaScript},
+		{RelativePath: "web", Language: languageTypeScript},
+		{RelativePath: "web_"},
+	}
+	got := packagePageNames(packages)
+	want := []string{"root.md", "web.md", "web.md", "web-2.md"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("packagePageNames = %v, want %v", got, want)
+	}
+	if pages := packagePages(packages); len(pages) != 3 || len(pages[1].Packages) != 2 {
+		t.Fatalf("expected the web packages to share a page, got %+v", pages)
+	}
+}
+
+func TestSplitOutputWritesPackagePages(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                "module example.com/app\n\ngo 1.22\n",
+		"internal/foo/foo.go":   "// Package foo does foo.\npackage foo\n\n// Foo holds foo state.\ntype Foo struct{}\n",
+		"internal/foo/bar.go":   "package foo\n\n// Bar makes a Foo.\nfunc Bar() Foo { return Foo{} }\n",
+		"cmd/app/main.go":       "package main\n\nimport _ \"example.com/app/internal/foo\"\n\nfunc main() {}\n",
+		"docs/codemap/notes.md": "# Notes kept by hand\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.SplitOutputDir = "docs/codemap"
+	opts.JSONOutputPath = "CODEMAP.json"
+	opts.VerifyOutputs = true
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	markdown, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "| <a id=\"pkg-internal-foo\"></a>[internal/foo](docs/codemap/internal-foo.md) | internal/foo/foo.go |"; !strings.Contains(string(markdown), want) {
+		t.Fatalf("expected linked row %q in CODEMAP.md:\n%s", want, markdown)
+	}
+	page, err := os.ReadFile(filepath.Join(tmpDir, "docs/codemap/internal-foo.md"))
+	if err != nil {
+		t.Fatalf("read package page: %v", err)
+	}
+	for _, want := range []string{
+		"Back to [CODEMAP.md](../../CODEMAP.md#pkg-internal-foo)",
+		"| bar.go | 4 |  |  | Bar |",
+		"| foo.go | 5 | Package foo does foo. | Foo |  |",
+		"| Foo | struct | Foo holds foo state. |",
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Fatalf("expected %q in package page:\n%s", want, page)
+		}
+	}
+	appPage, err := os.ReadFile(filepath.Join(tmpDir, "docs/codemap/cmd-app.md"))
+	if err != nil {
+		t.Fatalf("read cmd/app page: %v", err)
+	}
+	if want := "Project packages: [internal/foo](internal-foo.md)"; !strings.Contains(string(appPage), want) {
+		t.Fatalf("expected %q in cmd/app page:\n%s", want, appPage)
+	}
+	if stale, err := IsStale(context.Background(), opts); err != nil || stale {
+		t.Fatalf("expected fresh outputs, got stale=%v err=%v", stale, err)
+	}
+	// The pages list every file, but the JSON output stays as without them.
+	data, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct{ Packages []Package }
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("decode CODEMAP.json: %v", err)
+	}
+	for _, pkg := range decoded.Packages {
+		if len(pkg.Files) > 0 || len(pkg.KeyFiles) > 0 {
+			t.Fatalf("expected no file details in CODEMAP.json for %s, got %+v", pkg.RelativePath, pkg.Files)
+		}
+	}
+
+	if err := os.Remove(filepath.Join(tmpDir, "docs/codemap/_index.md")); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := IsStale(context.Background(), opts); err != nil || !stale {
+		t.Fatalf("expected a missing page index to be stale, got stale=%v err=%v", stale, err)
+	}
+
+	// A file with a hash header that codemap did not write as a page, such
+	// as a copied codemap, is kept.
+	if err := os.WriteFile(filepath.Join(tmpDir, "docs/codemap/copy.md"), markdown, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(tmpDir, "cmd")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("second Generate returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "docs/codemap/cmd-app.md")); !os.IsNotExist(err) {
+		t.Fatalf("expected the page of a removed package to be deleted, stat err = %v", err)
+	}
+	for _, name := range []string{"notes.md", "copy.md"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, "docs/codemap", name)); err != nil {
+			t.Fatalf("expected %s to be kept: %v", name, err)
+		}
+	}
+}
+
+func TestSplitOutputDirMustNotHoldOtherOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":     "module example.com/app\n\ngo 1.22\n",
+		"foo/foo.go": "package foo\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	for _, tt := range []struct {
+		dir, output, want string
+	}{
+		{".", "CODEMAP.md", "is the project root"},
+		{"docs", "docs/CODEMAP.md", "holds the output docs/CODEMAP.md"},
+	} {
+		opts := DefaultOptions()
+		opts.ProjectRoot = tmpDir
+		opts.OutputPath = tt.output
+		opts.SplitOutputDir = tt.dir
+		if _, err := Generate(context.Background(), opts); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Generate with split output %q = %v, want an error containing %q", tt.dir, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package codemap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	sqlCreatePattern = regexp.MustCompile(`(?i)\bcreate\s+(?:or\s+replace\s+)?(?:(?:global|local)\s+)?(?:(?:temp|temporary|unlogged|materialized|recursive|virtual)\s+)*(table|view|function|procedure|trigger|type|sequence)\s+(?:if\s+not\s+exists\s+)?([^\s(;]+)`)
+	sqlBlockComment  = regexp.MustCompile(`(?s)/\*.*?\*/`)
+)
+
+// SQLAnalyzer is the analyzer implementation for SQL schema and stored-procedure files.
+type SQLAnalyzer struct{}
+
+func (SQLAnalyzer) LanguageID() string { return languageSQL }
+
+func (SQLAnalyzer) Analyze(ctx context.Context, in AnalysisInput) (*Codemap, error) {
+	if in.Index == nil {
+		return nil, fmt.Errorf("missing file index")
+	}
+	return analyzeSQLWithIndex(ctx, in.Root, in.Index, in.Options)
+}
+
+func analyzeSQLWithIndex(ctx context.Context, root string, idx *FileIndex, opts Options) (*Codemap, error) {
+	plans := buildDirectoryPackagePlans(root, idx, languageSQL)
+
+	packageResults := make([]*Package, len(plans))
+	jobs := make([]analysisJob, 0, len(plans))
+	for i := range plans {
+		jobs = append(jobs, analysisJob{
+			index:   i,
+			dir:     plans[i].DirAbsPath,
+			relPath: plans[i].RelativePath,
+		})
+	}
+
+	if err := analyzePackagePlansParallel(ctx, opts, languageSQL, jobs, packageResults, func(ctx context.Context, job analysisJob) (*Package, error) {
+		plan := plans[job.index]
+		pkg, err := analyzeSQLPackage(ctx, root, plan, opts)
+		if err != nil {
+			return nil, fmt.Errorf("analyze sql package %s: %w", plan.RelativePath, err)
+		}
+		return pkg, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0, len(packageResults))
+	for i := range packageResults {
+		if packageResults[i] != nil {
+			packages = append(packages, *packageResults[i])
+		}
+	}
+
+	return &Codemap{
+		ProjectRoot: root,
+		Packages:    packages,
+	}, nil
+}
+
+func analyzeSQLPackage(ctx context.Context, root string, plan packagePlan, opts Options) (*Package, error) {
+	if len(plan.FileRelPaths) == 0 {
+		return nil, nil
+	}
+
+	files := make([]File, 0, len(plan.FileRelPaths))
+	allTypes := make([]TypeInfo, 0, len(plan.FileRelPaths))
+	seen := make(map[string]struct{})
+	totalLines := 0
+	purpose := ""
+	entryPoint := ""
+	entryScore := -1
+	var tags []string
+
+	for _, relPath := range plan.FileRelPaths {
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(relPath)))
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", relPath, err)
+		}
+		lineCount := lineCountBytes(content)
+		recordFileParsed(ctx, relPath, lineCount)
+		totalLines += lineCount
+		name := filepath.Base(relPath)
+
+		filePurpose := extractFilePurpose(opts, languageSQL, relPath, content, extractSQLFilePurpose)
+		if purpose == "" && filePurpose != "" {
+			purpose = filePurpose
+		}
+		tags = mergeCodemapTags(tags, extractCodemapTags(content))
+
+		typeInfos, keyTypes, keyFuncs := parseSQLFileObjects(content)
+		for _, info := range typeInfos {
+			key := info.Kind + "\x00" + strings.ToLower(info.Name)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			allTypes = append(allTypes, info)
+		}
+
+		files = append(files, File{
+			Name:      name,
+			LineCount: lineCount,
+			Purpose:   filePurpose,
+			KeyTypes:  keyTypes,
+			KeyFuncs:  keyFuncs,
+		})
+
+		score := scoreSQLEntryPoint(name, len(typeInfos))
+		if score > entryScore || (score == entryScore && name < entryPoint) {
+			entryScore = score
+			entryPoint = name
+		}
+	}
+
+	if purpose == "" {
+		purpose = "SQL schema in " + filepath.Base(plan.DirAbsPath)
+	}
+	sort.Slice(allTypes, func(i, j int) bool {
+		if allTypes[i].Name != allTypes[j].Name {
+			return allTypes[i].Name < allTypes[j].Name
+		}
+		return allTypes[i].Kind < allTypes[j].Kind
+	})
+
+	detailedFiles, keyFiles := packageFileDetails(files, entryPoint, opts)
+
+	return &Package{
+		ImportPath:    plan.RelativePath,
+		RelativePath:  plan.RelativePath,
+		Purpose:       purpose,
+		FileCount:     len(plan.FileRelPaths),
+		LineCount:     totalLines,
+		Files:         detailedFiles,
+		KeyFiles:      keyFiles,
+		ExportedTypes: allTypes,
+		Symbols:       packageSymbols(files, allTypes),
+		EntryPoint:    entryPoint,
+		Tags:          tags,
+	}, nil
+}
+
+// parseSQLFileObjects lists objects created by CREATE statements. Tables, views,
+// types, and sequences are reported as key types; functions, procedures, and
+// triggers as key funcs.
+func parseSQLFileObjects(content []byte) ([]TypeInfo, []string, []string) {
+	typeInfos := make([]TypeInfo, 0)
+	keyTypes := make([]string, 0)
+	keyFuncs := make([]string, 0)
+
+	stripped := stripSQLComments(content)
+	for _, match := range sqlCreatePattern.FindAllSubmatch(stripped, -1) {
+		kind := strings.ToLower(string(match[1]))
+		name := normalizeSQLIdentifier(string(match[2]))
+		if name == "" {
+			continue
+		}
+		typeInfos = append(typeInfos, TypeInfo{Name: name, Kind: kind})
+		switch kind {
+		case "function", "procedure", "trigger":
+			if !containsString(keyFuncs, name) {
+				keyFuncs = append(keyFuncs, name)
+			}
+		default:
+			if !containsString(keyTypes, name) {
+				keyTypes = append(keyTypes, name)
+			}
+		}
+	}
+	return typeInfos, keyTypes, keyFuncs
+}
+
+func stripSQLComments(content []byte) []byte {
+	content = sqlBlockComment.ReplaceAll(content, []byte(" "))
+	var out bytes.Buffer
+	out.Grow(len(content))
+	for _, line := range bytes.Split(content, []byte{'\n'}) {
+		if idx := bytes.Index(line, []byte("--")); idx >= 0 {
+			line = line[:idx]
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+func normalizeSQLIdentifier(raw string) string {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimRight(raw, ",")
+	parts := strings.Split(raw, ".")
+	for i, part := range parts {
+		parts[i] = strings.Trim(part, "`\"[]")
+	}
+	return strings.Join(parts, ".")
+}
+
+func extractSQLFilePurpose(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "--") {
+			text := strings.TrimSpace(strings.TrimLeft(line, "-"))
+			if text == "" {
+				continue
+			}
+			return extractFirstSentence(text)
+		}
+		return ""
+	}
+	return ""
+}
+
+func scoreSQLEntryPoint(name string, objectCount int) int {
+	score := 0
+	switch strings.ToLower(name) {
+	case "schema.sql", "structure.sql":
+		score += 120
+	case "init.sql", "main.sql", "setup.sql":
+		score += 100
+	case "tables.sql":
+		score += 80
+	}
+	if objectCount > 0 {
+		score += 5
+	}
+	return score
+}
@@ -0,0 +1,75 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseSQLFileObjects(t *testing.T) {
+	content := []byte(`-- Core billing schema.
+CREATE TABLE IF NOT EXISTS "public"."invoices" (
+  id bigint primary key
+);
+create or replace view active_invoices as select * from invoices;
+CREATE MATERIALIZED VIEW monthly_totals AS SELECT 1;
+/* CREATE TABLE commented_out (id int); */
+-- CREATE TABLE also_commented (id int);
+CREATE OR REPLACE FUNCTION invoice_total(id bigint) RETURNS numeric AS $$ SELECT 1 $$ LANGUAGE sql;
+CREATE PROCEDURE ` + "`close_period`" + `() BEGIN END;
+`)
+
+	typeInfos, keyTypes, keyFuncs := parseSQLFileObjects(content)
+
+	wantTypes := []string{"public.invoices", "active_invoices", "monthly_totals"}
+	if !reflect.DeepEqual(keyTypes, wantTypes) {
+		t.Fatalf("unexpected key types: got %v want %v", keyTypes, wantTypes)
+	}
+	wantFuncs := []string{"invoice_total", "close_period"}
+	if !reflect.DeepEqual(keyFuncs, wantFuncs) {
+		t.Fatalf("unexpected key funcs: got %v want %v", keyFuncs, wantFuncs)
+	}
+	if len(typeInfos) != 5 || typeInfos[2].Kind != "view" || typeInfos[4].Kind != "procedure" {
+		t.Fatalf("unexpected type infos: %+v", typeInfos)
+	}
+	if got := extractSQLFilePurpose(content); got != "Core billing schema." {
+		t.Fatalf("unexpected purpose: %q", got)
+	}
+}
+
+func TestAnalyzeSQLProjectGroupsByDirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbDir := filepath.Join(tmpDir, "db")
+	if err := os.MkdirAll(dbDir, 0755); err != nil {
+		t.Fatalf("mkdir db: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dbDir, "schema.sql"), []byte("CREATE TABLE users (id int);\n"), 0644); err != nil {
+		t.Fatalf("write schema.sql: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dbDir, "functions.sql"), []byte("CREATE FUNCTION user_count() RETURNS int AS $$ SELECT 1 $$;\n"), 0644); err != nil {
+		t.Fatalf("write functions.sql: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	if len(cm.Packages) != 1 {
+		t.Fatalf("expected 1 package, got %+v", cm.Packages)
+	}
+
+	pkg := cm.Packages[0]
+	if pkg.RelativePath != "db" || pkg.EntryPoint != "schema.sql" {
+		t.Fatalf("unexpected sql package: %+v", pkg)
+	}
+	if pkg.FileCount != 2 || len(pkg.ExportedTypes) != 2 {
+		t.Fatalf("expected 2 files and 2 objects, got %d files and %+v", pkg.FileCount, pkg.ExportedTypes)
+	}
+	if pkg.ExportedTypes[0].Name != "user_count" || pkg.ExportedTypes[1].Name != "users" {
+		t.Fatalf("expected objects sorted by name, got %+v", pkg.ExportedTypes)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 )
@@ -238,7 +240,7 @@ func TestBuildConcernsFromIndex(t *testing.T) {
 	defs := []ConcernDef{
 		{Name: "CLI", Patterns: []string{"cmd/**/*.go", "**/cli_*.go"}},
 	}
-	concerns, err := buildConcerns(idx, defs, 10)
+	concerns, err := buildConcerns(idx, defs, 10, 0)
 	if err != nil {
 		t.Fatalf("buildConcerns failed: %v", err)
 	}
@@ -250,6 +252,56 @@ func TestBuildConcernsFromIndex(t *testing.T) {
 	}
 }
 
+func TestCachedConcernsReusesResultsForUnchangedFileSet(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "cli_main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := BuildFileIndex(context.Background(), tmpDir)
+	if err != nil {
+		t.Fatalf("BuildFileIndex failed: %v", err)
+	}
+
+	opts := Options{Concerns: []ConcernDef{{Name: "CLI", Patterns: []string{"**/cli_*.go"}}}}
+	first := &CodemapState{}
+	concerns, err := cachedConcerns(AnalysisInput{Index: idx, Options: opts, NextState: first})
+	if err != nil {
+		t.Fatalf("cachedConcerns failed: %v", err)
+	}
+	if len(concerns) != 1 || first.Analysis == nil || first.Analysis.Concerns == nil {
+		t.Fatalf("expected concerns to be computed and cached, got %+v", concerns)
+	}
+
+	// A sentinel in the cache proves the second run skips matching.
+	first.Analysis.Concerns.Concerns = []Concern{{Name: "Cached", TotalFiles: 7}}
+	second := &CodemapState{}
+	concerns, err = cachedConcerns(AnalysisInput{Index: idx, Options: opts, PrevState: first, NextState: second})
+	if err != nil {
+		t.Fatalf("cachedConcerns failed: %v", err)
+	}
+	if len(concerns) != 1 || concerns[0].Name != "Cached" {
+		t.Fatalf("expected cached concerns, got %+v", concerns)
+	}
+	if second.Analysis == nil || second.Analysis.Concerns == nil {
+		t.Fatal("expected cache hit to carry concerns into next state")
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "cli_extra.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err = BuildFileIndex(context.Background(), tmpDir)
+	if err != nil {
+		t.Fatalf("BuildFileIndex failed: %v", err)
+	}
+	concerns, err = cachedConcerns(AnalysisInput{Index: idx, Options: opts, PrevState: first, NextState: &CodemapState{}})
+	if err != nil {
+		t.Fatalf("cachedConcerns failed: %v", err)
+	}
+	if len(concerns) != 1 || concerns[0].Name != "CLI" || concerns[0].TotalFiles != 2 {
+		t.Fatalf("expected recomputed concerns after file set change, got %+v", concerns)
+	}
+}
+
 func TestSimpleGlobMatchesPathMatch(t *testing.T) {
 	patterns := []string{
 		"",
@@ -334,8 +386,8 @@ func TestEnsureUpToDateWritesAnalysisCache(t *testing.T) {
 	if cache == nil {
 		t.Fatal("expected analysis cache")
 	}
-	if cache.Version != analysisCacheVersionV2 {
-		t.Fatalf("expected analysis cache version %d, got %d", analysisCacheVersionV2, cache.Version)
+	if cache.Version != analysisCacheVersion {
+		t.Fatalf("expected analysis cache version %d, got %d", analysisCacheVersion, cache.Version)
 	}
 	if len(cache.Packages) != 2 {
 		t.Fatalf("expected 2 cached packages, got %d", len(cache.Packages))
@@ -447,8 +499,50 @@ func TestEnsureUpToDateInvalidatesIncompatibleAnalysisCache(t *testing.T) {
 	if err != nil {
 		t.Fatalf("readAnalysisCache failed: %v", err)
 	}
-	if after == nil || after.Version != analysisCacheVersionV2 {
-		t.Fatalf("expected analysis cache version %d after rebuild", analysisCacheVersionV2)
+	if after == nil || after.Version != analysisCacheVersion {
+		t.Fatalf("expected analysis cache version %d after rebuild", analysisCacheVersion)
+	}
+}
+
+func TestOptionsChangeMakesOutputsStale(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/test\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "store.go"), []byte("package test\n\ntype store struct{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	if _, err := Generate(ctx, opts); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if stale, err := IsStale(ctx, opts); err != nil || stale {
+		t.Fatalf("IsStale with the same options = %v, %v; want false", stale, err)
+	}
+
+	opts.IncludeUnexported = true
+	if stale, err := IsStale(ctx, opts); err != nil || !stale {
+		t.Fatalf("IsStale after enabling IncludeUnexported = %v, %v; want true", stale, err)
+	}
+	report, err := StaleReport(ctx, opts)
+	if err != nil {
+		t.Fatalf("StaleReport failed: %v", err)
+	}
+	if !reflect.DeepEqual(report.Reasons, []string{"options that change the outputs differ from the last run"}) {
+		t.Fatalf("unexpected reasons: %q", report.Reasons)
+	}
+	cm, generated, err := EnsureUpToDate(ctx, opts)
+	if err != nil || !generated {
+		t.Fatalf("EnsureUpToDate = %v, %v; want a regeneration", generated, err)
+	}
+	if len(cm.Packages) != 1 || len(cm.Packages[0].PrivateSymbols) == 0 {
+		t.Fatalf("expected private symbols after regenerating: %+v", cm.Packages)
+	}
+	if stale, err := IsStale(ctx, opts); err != nil || stale {
+		t.Fatalf("IsStale after regenerating = %v, %v; want false", stale, err)
 	}
 }
 
@@ -472,6 +566,57 @@ func TestParseHashLine(t *testing.T) {
 	}
 }
 
+func TestReadExistingHashHandlesEncodingsAndScanDepth(t *testing.T) {
+	tmpDir := t.TempDir()
+	utf16LE := func(text string) []byte {
+		out := []byte{0xFF, 0xFE}
+		for _, r := range text {
+			out = append(out, byte(r), byte(r>>8))
+		}
+		return out
+	}
+	utf16BE := func(text string) []byte {
+		out := []byte{0xFE, 0xFF}
+		for _, r := range text {
+			out = append(out, byte(r>>8), byte(r))
+		}
+		return out
+	}
+	header := "<!-- codemap-hash: 0123abcd -->\r\n<!-- Generated: 2026-01-01 00:00:00 UTC -->\r\n"
+	files := map[string][]byte{
+		"crlf.md":      []byte(header),
+		"utf8bom.md":   append([]byte{0xEF, 0xBB, 0xBF}, header...),
+		"utf16le.md":   utf16LE(header),
+		"utf16be.md":   utf16BE(header),
+		"binary.md":    append(make([]byte, 2*maxHeaderLineBytes), []byte("\n# codemap-hash: 0123abcd\n")...),
+		"banner.md":    []byte(strings.Repeat("# banner\n", 25) + "# codemap-hash: 0123abcd\n"),
+		"truncated.md": utf16LE("# codemap-hash: 0123abcd\n")[:9],
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"crlf.md", "utf8bom.md", "utf16le.md", "utf16be.md"} {
+		if got, err := ReadExistingHash(filepath.Join(tmpDir, name)); err != nil || got != "0123abcd" {
+			t.Fatalf("ReadExistingHash(%s) = %q (err %v), want 0123abcd", name, got, err)
+		}
+	}
+	for _, name := range []string{"binary.md", "banner.md", "truncated.md"} {
+		if got, err := ReadExistingHash(filepath.Join(tmpDir, name)); err != nil || got != "" {
+			t.Fatalf("ReadExistingHash(%s) = %q (err %v), want no hash", name, got, err)
+		}
+	}
+	if got, err := readExistingHash(filepath.Join(tmpDir, "banner.md"), 30); err != nil || got != "0123abcd" {
+		t.Fatalf("expected a deeper scan to find the banner-shifted header, got %q (err %v)", got, err)
+	}
+	parsed, err := readOutputHeader(filepath.Join(tmpDir, "utf16le.md"), 0)
+	if err != nil || parsed.Hash != "0123abcd" {
+		t.Fatalf("readOutputHeader = %+v (err %v)", parsed, err)
+	}
+}
+
 func TestAggregateHashFromFilesystemState(t *testing.T) {
 	tmpDir := t.TempDir()
 	if err := os.WriteFile(filepath.Join(tmpDir, "a.go"), []byte("package main\n"), 0644); err != nil {
@@ -548,3 +693,51 @@ func TestAggregateHashFromFilesystemStateDetectsGoFileInPreviouslyNonGoDir(t *te
 		t.Fatal("expected mismatch after adding go file in previously non-go directory")
 	}
 }
+
+func TestIsStaleWithGraceUsesGeneratedAt(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/test\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatalf("write go.mod: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatalf("write main.go: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.StalenessGrace = time.Hour
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "util.go"), []byte("package main\n\nfunc Helper() {}\n"), 0644); err != nil {
+		t.Fatalf("write util.go: %v", err)
+	}
+
+	stale, withinGrace, err := IsStaleWithGrace(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("IsStaleWithGrace returned error: %v", err)
+	}
+	if !stale || !withinGrace {
+		t.Fatalf("expected stale outputs within grace, got stale=%v withinGrace=%v", stale, withinGrace)
+	}
+
+	outputPath := filepath.Join(tmpDir, opts.OutputPath)
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	old := time.Now().UTC().Add(-2 * time.Hour).Format("2006-01-02 15:04:05 UTC")
+	lines := strings.SplitN(string(content), "\n", 3)
+	lines[1] = "<!-- Generated: " + old + " -->"
+	if err := os.WriteFile(outputPath, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatalf("rewrite output: %v", err)
+	}
+
+	stale, withinGrace, err = IsStaleWithGrace(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("IsStaleWithGrace returned error: %v", err)
+	}
+	if !stale || withinGrace {
+		t.Fatalf("expected stale outputs beyond grace, got stale=%v withinGrace=%v", stale, withinGrace)
+	}
+}
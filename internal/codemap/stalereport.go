@@ -0,0 +1,263 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// StalenessReport explains why outputs are stale: the files changed, added
+// or removed since the last run and the packages they belong to.
+type StalenessReport struct {
+	Stale    bool
+	Reasons  []string    // Causes other than file changes, e.g. a missing output
+	Changed  []StaleFile // Files whose contents changed
+	Added    []StaleFile // Files indexed now but not by the last run
+	Removed  []StaleFile // Files indexed by the last run but gone now
+	Packages []string    // Relative paths of the packages of all listed files, sorted
+}
+
+// StaleFile is a file listed by a StalenessReport.
+type StaleFile struct {
+	Path    string // Relative to the project root
+	Package string // Relative path of the owning package; "" when no package owns it
+}
+
+// Empty reports whether the report lists no reason and no file.
+func (r *StalenessReport) Empty() bool {
+	return len(r.Reasons) == 0 && len(r.Changed) == 0 && len(r.Added) == 0 && len(r.Removed) == 0
+}
+
+// StaleReport checks staleness like IsStale and, when outputs are stale,
+// compares the tree with the state file of the last run. Files map to
+// packages through the cached analysis, so added files belong to the package
+// of their directory as of that run. Without a state describing the tree the
+// outputs were generated from, as in a fresh clone, it diffs the commit that
+// last changed Options.OutputPath against the work tree instead, mapping
+// files to the packages of the current tree.
+func StaleReport(ctx context.Context, opts Options) (*StalenessReport, error) {
+	stale, err := IsStale(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	report := &StalenessReport{Stale: stale}
+	if !stale {
+		return report, nil
+	}
+
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	if opts.OutputPath == "" {
+		opts.OutputPath = "CODEMAP.md"
+	}
+	if opts.PathsOutputPath == "" {
+		opts.PathsOutputPath = "CODEMAP.paths"
+	}
+	existingHash, err := readExistingHash(filepath.Join(root, opts.OutputPath), opts.HashScanLines)
+	if err != nil {
+		return nil, fmt.Errorf("read existing hash: %w", err)
+	}
+	if existingHash == "" {
+		report.Reasons = append(report.Reasons, opts.OutputPath+" is missing or has no hash header")
+	}
+	if !opts.DisablePaths {
+		pathsHash, err := readExistingHash(filepath.Join(root, opts.PathsOutputPath), opts.HashScanLines)
+		if err != nil {
+			return nil, fmt.Errorf("read existing paths hash: %w", err)
+		}
+		if pathsHash == "" {
+			report.Reasons = append(report.Reasons, opts.PathsOutputPath+" is missing or has no hash header")
+		} else if existingHash != "" && pathsHash != existingHash {
+			report.Reasons = append(report.Reasons, opts.PathsOutputPath+" and "+opts.OutputPath+" were generated from different trees")
+		}
+	}
+	if existingHash != "" {
+		if secondary, err := secondaryOutputsStale(root, opts, existingHash); err != nil {
+			return nil, err
+		} else if secondary {
+			report.Reasons = append(report.Reasons, "an enabled output is missing or out of date")
+		}
+	}
+
+	state, err := readState(resolveStatePath(root, opts))
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	entries := sortedStateEntries(state)
+	if entries == nil || (existingHash != "" && state.AggregateHash != existingHash) {
+		if ok, err := gitStaleReport(ctx, root, opts, report); err != nil || ok {
+			return report, err
+		}
+	}
+	if state != nil && state.OptionsSignature != outputOptionsSignature(opts) {
+		report.Reasons = append(report.Reasons, "options that change the outputs differ from the last run")
+	}
+	if state != nil {
+		report.Reasons = append(report.Reasons, auxFileReasons(root, state.AuxFiles)...)
+	}
+	if entries == nil {
+		report.Reasons = append(report.Reasons, "no state from a previous run to compare the tree with")
+		return report, nil
+	}
+	if !indexStateMatches(state, opts) {
+		report.Reasons = append(report.Reasons, "indexing options or ignore files changed since the last run")
+	}
+	if existingHash != "" && state.AggregateHash != existingHash {
+		report.Reasons = append(report.Reasons, "the state file describes a different tree than "+opts.OutputPath)
+	}
+
+	idx, err := buildIndex(ctx, root, opts)
+	if err != nil {
+		return nil, fmt.Errorf("build file index: %w", err)
+	}
+	analysisCache, err := readAnalysisCache(resolveAnalysisStatePath(root, opts))
+	if err != nil {
+		return nil, fmt.Errorf("read analysis cache: %w", err)
+	}
+	var packages []Package
+	if state = mergeStateWithAnalysis(state, analysisCache); state.Analysis != nil {
+		for _, cached := range state.Analysis.Packages {
+			packages = append(packages, cached.Package)
+		}
+	}
+	owners := newStatsOwners(packages)
+	seen := make(map[string]struct{})
+	file := func(relPath, language string) StaleFile {
+		f := StaleFile{Path: relPath}
+		if i, ok := owners.find(language, relPath); ok {
+			f.Package = packages[i].RelativePath
+			seen[f.Package] = struct{}{}
+		}
+		return f
+	}
+
+	current := make(map[string]struct{}, len(idx.Files))
+	pos := 0
+	for _, rec := range idx.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		current[rec.RelPath] = struct{}{}
+		prev, ok := findCachedEntry(entries, rec.RelPath, &pos)
+		if !ok {
+			report.Added = append(report.Added, file(rec.RelPath, rec.Language))
+			continue
+		}
+		if prev.Size == rec.Size && prev.ModTimeUnixNano == rec.ModTimeUnixNano && prev.ContentHash != "" {
+			continue
+		}
+		contentHash, err := hashFileContents(rec.AbsPath)
+		if err != nil {
+			return nil, fmt.Errorf("hash %s: %w", rec.RelPath, err)
+		}
+		if contentHash != prev.ContentHash {
+			report.Changed = append(report.Changed, file(rec.RelPath, rec.Language))
+		}
+	}
+	for _, prev := range entries {
+		if _, ok := current[prev.RelPath]; !ok {
+			report.Removed = append(report.Removed, file(prev.RelPath, prev.Language))
+		}
+	}
+	report.Packages = sortedImportSet(seen)
+	if report.Empty() {
+		report.Reasons = append(report.Reasons, "outputs were generated from a different tree than the last run recorded")
+	}
+	return report, nil
+}
+
+// auxFileReasons names the files and directories read outside the index,
+// such as manifests and CODEOWNERS, that changed since they were recorded
+// in states.
+func auxFileReasons(root string, states []AuxFileState) []string {
+	var reasons []string
+	statter := newAuxStatter(root)
+	for _, recorded := range states {
+		if !statter.changed(recorded) {
+			continue
+		}
+		if recorded.DirFilter != "" {
+			reasons = append(reasons, "entries of "+recorded.RelPath+"/ read by the last run changed")
+		} else {
+			reasons = append(reasons, recorded.RelPath+" read by the last run changed")
+		}
+	}
+	return reasons
+}
+
+// gitStaleReport fills report with the files that differ between the commit
+// that last changed opts.OutputPath and the work tree. It reports false,
+// leaving report untouched, when root is not a git work tree, no commit
+// holds the output or the output was edited since.
+func gitStaleReport(ctx context.Context, root string, opts Options, report *StalenessReport) (bool, error) {
+	commits, err := gitLines(ctx, root, "log", "-1", "--format=%H", "--", opts.OutputPath)
+	if err != nil || len(commits) == 0 {
+		return false, nil
+	}
+	commit := strings.TrimSpace(commits[0])
+	status, err := gitLines(ctx, root, "diff", "--name-status", "--no-renames", "--relative", "-z", commit, "--")
+	if err != nil {
+		return false, nil
+	}
+	untracked, err := gitLines(ctx, root, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return false, nil
+	}
+	changed := make(map[string]byte, len(status)/2+len(untracked))
+	for i := 0; i+1 < len(status); i += 2 {
+		changed[status[i+1]] = status[i][0]
+	}
+	for _, relPath := range untracked {
+		changed[relPath] = 'A'
+	}
+	if _, ok := changed[opts.OutputPath]; ok {
+		return false, nil
+	}
+
+	cm, idx, err := analyzeCached(ctx, opts)
+	if err != nil {
+		return false, err
+	}
+	owners := newStatsOwners(cm.Packages)
+	seen := make(map[string]struct{})
+	file := func(relPath, language string) StaleFile {
+		f := StaleFile{Path: relPath}
+		if i, ok := owners.find(language, relPath); ok {
+			f.Package = cm.Packages[i].RelativePath
+			seen[f.Package] = struct{}{}
+		}
+		return f
+	}
+	for _, rec := range idx.Files {
+		switch changed[rec.RelPath] {
+		case 'A':
+			report.Added = append(report.Added, file(rec.RelPath, rec.Language))
+		case 0:
+		default:
+			report.Changed = append(report.Changed, file(rec.RelPath, rec.Language))
+		}
+	}
+	filter := newPathFilter(opts)
+	languageSpecs := languageSpecsFor(opts)
+	var removed []string
+	for relPath, kind := range changed {
+		if kind != 'D' || trackedPathExcluded(relPath) || filter.skipDirTree(path.Dir(relPath)) || filter.skipFile(relPath) {
+			continue
+		}
+		removed = append(removed, relPath)
+	}
+	sort.Strings(removed)
+	for _, relPath := range removed {
+		if match, ok := matchLanguageForPath(relPath, languageSpecs); ok {
+			report.Removed = append(report.Removed, file(relPath, match.ID))
+		}
+	}
+	report.Packages = sortedImportSet(seen)
+	report.Reasons = append(report.Reasons, "no state describes "+opts.OutputPath+"; compared the tree with commit "+commit[:min(len(commit), 12)]+", which last changed it")
+	return true, nil
+}
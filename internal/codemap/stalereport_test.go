@@ -0,0 +1,186 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStaleReportListsFilesAndPackages(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example.com/app\n\ngo 1.22\n",
+		"main.go":        "package main\n\nfunc main() {}\n",
+		"store/store.go": "// Package store keeps items.\npackage store\n",
+		"store/old.go":   "package store\n\ntype Old struct{}\n",
+		"api/api.go":     "// Package api serves items.\npackage api\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	if _, _, err := EnsureUpToDate(context.Background(), opts); err != nil {
+		t.Fatalf("EnsureUpToDate failed: %v", err)
+	}
+	report, err := StaleReport(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("StaleReport failed: %v", err)
+	}
+	if report.Stale || !report.Empty() {
+		t.Fatalf("expected a clean report after generating, got %+v", report)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "api", "api.go"), []byte("// Package api serves items.\npackage api\n\nfunc Serve() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "store", "new.go"), []byte("package store\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(tmpDir, "store", "old.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = StaleReport(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("StaleReport failed: %v", err)
+	}
+	if !report.Stale {
+		t.Fatal("expected stale outputs")
+	}
+	if len(report.Reasons) != 0 {
+		t.Fatalf("unexpected reasons: %v", report.Reasons)
+	}
+	if want := []StaleFile{{Path: "api/api.go", Package: "api"}}; !reflect.DeepEqual(report.Changed, want) {
+		t.Fatalf("Changed = %+v, want %+v", report.Changed, want)
+	}
+	if want := []StaleFile{{Path: "store/new.go", Package: "store"}}; !reflect.DeepEqual(report.Added, want) {
+		t.Fatalf("Added = %+v, want %+v", report.Added, want)
+	}
+	if want := []StaleFile{{Path: "store/old.go", Package: "store"}}; !reflect.DeepEqual(report.Removed, want) {
+		t.Fatalf("Removed = %+v, want %+v", report.Removed, want)
+	}
+	if want := []string{"api", "store"}; !reflect.DeepEqual(report.Packages, want) {
+		t.Fatalf("Packages = %v, want %v", report.Packages, want)
+	}
+
+}
+
+func TestStaleReportWithoutOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	report, err := StaleReport(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("StaleReport failed: %v", err)
+	}
+	want := []string{
+		"CODEMAP.md is missing or has no hash header",
+		"CODEMAP.paths is missing or has no hash header",
+		"no state from a previous run to compare the tree with",
+	}
+	if !report.Stale || !reflect.DeepEqual(report.Reasons, want) {
+		t.Fatalf("report = %+v, want stale with reasons %v", report, want)
+	}
+}
+
+func TestStaleReportNamesChangedAuxFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":     "module example.com/app\n\ngo 1.22\n",
+		"main.go":    "package main\n\nfunc main() {}\n",
+		"CODEOWNERS": "* @core\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	if _, _, err := EnsureUpToDate(context.Background(), opts); err != nil {
+		t.Fatalf("EnsureUpToDate failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "CODEOWNERS"), []byte("* @platform\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report, err := StaleReport(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("StaleReport failed: %v", err)
+	}
+	if want := []string{"CODEOWNERS read by the last run changed"}; !report.Stale || !reflect.DeepEqual(report.Reasons, want) {
+		t.Fatalf("report = %+v, want stale with reasons %v", report, want)
+	}
+}
+
+func TestStaleReportFallsBackToGitWithoutState(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example.com/app\n\ngo 1.22\n",
+		".gitignore":     ".codemap.state.json\n.codemap.analysis.json\n",
+		"main.go":        "package main\n\nfunc main() {}\n",
+		"store/store.go": "// Package store keeps items.\npackage store\n",
+		"store/old.go":   "package store\n\ntype Old struct{}\n",
+		"api/api.go":     "// Package api serves items.\npackage api\n",
+	}
+	writeTestTree(t, tmpDir, files)
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	if _, _, err := EnsureUpToDate(context.Background(), opts); err != nil {
+		t.Fatalf("EnsureUpToDate failed: %v", err)
+	}
+	runGit := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tmpDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	runGit("init", "-q")
+	runGit("add", ".")
+	runGit("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial")
+
+	// A fresh clone has the outputs but no state.
+	cloneDir := filepath.Join(t.TempDir(), "clone")
+	runGit("clone", "-q", tmpDir, cloneDir)
+	opts.ProjectRoot = cloneDir
+	if err := os.WriteFile(filepath.Join(cloneDir, "api", "api.go"), []byte("// Package api serves items.\npackage api\n\nfunc Serve() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cloneDir, "store", "new.go"), []byte("package store\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(cloneDir, "store", "old.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := StaleReport(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("StaleReport failed: %v", err)
+	}
+	if !report.Stale || len(report.Reasons) != 1 || !strings.HasPrefix(report.Reasons[0], "no state describes CODEMAP.md; compared the tree with commit ") {
+		t.Fatalf("report = %+v, want stale with a git comparison reason", report)
+	}
+	if want := []StaleFile{{Path: "api/api.go", Package: "api"}}; !reflect.DeepEqual(report.Changed, want) {
+		t.Fatalf("Changed = %+v, want %+v", report.Changed, want)
+	}
+	if want := []StaleFile{{Path: "store/new.go", Package: "store"}}; !reflect.DeepEqual(report.Added, want) {
+		t.Fatalf("Added = %+v, want %+v", report.Added, want)
+	}
+	if want := []StaleFile{{Path: "store/old.go", Package: "store"}}; !reflect.DeepEqual(report.Removed, want) {
+		t.Fatalf("Removed = %+v, want %+v", report.Removed, want)
+	}
+	if want := []string{"api", "store"}; !reflect.DeepEqual(report.Packages, want) {
+		t.Fatalf("Packages = %v, want %v", report.Packages, want)
+	}
+}
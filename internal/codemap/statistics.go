@@ -0,0 +1,245 @@
+package codemap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// largestFilesLimit caps Statistics.LargestFiles.
+const largestFilesLimit = 5
+
+// commentSyntax is how a language marks comment lines. Only lines starting
+// with a marker count, so trailing comments after code do not.
+type commentSyntax struct {
+	line       string
+	blockStart string
+	blockEnd   string
+}
+
+var (
+	cStyleComments = commentSyntax{line: "//", blockStart: "/*", blockEnd: "*/"}
+	hashComments   = commentSyntax{line: "#"}
+)
+
+// commentSyntaxes covers the built-in languages. Python docstrings count as
+// comments; files of other languages count no comment lines.
+var commentSyntaxes = map[string]commentSyntax{
+	languageGo:         cStyleComments,
+	languageRust:       cStyleComments,
+	languageTypeScript: cStyleComments,
+	languageJavaScript: cStyleComments,
+	languageCpp:        cStyleComments,
+	languageProto:      cStyleComments,
+	languageCSS:        {blockStart: "/*", blockEnd: "*/"},
+	languageHTML:       {blockStart: "<!--", blockEnd: "-->"},
+	languagePython:     {line: "#", blockStart: `"""`, blockEnd: `"""`},
+	languageRuby:       {line: "#", blockStart: "=begin", blockEnd: "=end"},
+	languageShell:      hashComments,
+	languageConfig:     hashComments,
+	languageSQL:        {line: "--", blockStart: "/*", blockEnd: "*/"},
+}
+
+// computeStatistics counts the lines of the indexed files of each package,
+// test files included, by reading them once. Each file counts toward the
+// deepest package of its language containing it; files outside every package
+// are left out. With perPackage, packages also get their Stats.
+func computeStatistics(ctx context.Context, idx *FileIndex, packages []Package, perPackage bool) (*Statistics, error) {
+	owners := newStatsOwners(packages)
+	stats := make([]PackageStats, len(packages))
+	var largest []FileLines
+	for _, rec := range idx.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		i, ok := owners.find(rec.Language, rec.RelPath)
+		if !ok {
+			continue
+		}
+		content, err := os.ReadFile(rec.AbsPath)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", rec.RelPath, err)
+		}
+		lines, comments := countSourceLines(content, commentSyntaxes[rec.Language])
+		if rec.IsTest {
+			stats[i].TestFiles++
+			stats[i].TestLines += lines
+			continue
+		}
+		stats[i].Files++
+		stats[i].Lines += lines
+		stats[i].CommentLines += comments
+		file := FileLines{Path: rec.RelPath, Lines: lines}
+		if lines > stats[i].LargestFile.Lines {
+			stats[i].LargestFile = file
+		}
+		largest = append(largest, file)
+	}
+
+	byLanguage := make(map[string]*LanguageStats)
+	result := &Statistics{Total: LanguageStats{Language: "total"}}
+	for i, pkg := range packages {
+		if isNestedCodemap(pkg) {
+			continue
+		}
+		lang := byLanguage[pkg.Language]
+		if lang == nil {
+			lang = &LanguageStats{Language: pkg.Language}
+			byLanguage[pkg.Language] = lang
+		}
+		lang.Packages++
+		lang.add(stats[i].LineStats)
+		result.Total.Packages++
+		result.Total.add(stats[i].LineStats)
+		if perPackage {
+			pkgStats := stats[i]
+			packages[i].Stats = &pkgStats
+		}
+	}
+	for _, lang := range byLanguage {
+		result.Languages = append(result.Languages, *lang)
+	}
+	sort.Slice(result.Languages, func(i, j int) bool {
+		a, b := result.Languages[i], result.Languages[j]
+		if a.Lines != b.Lines {
+			return a.Lines > b.Lines
+		}
+		return a.Language < b.Language
+	})
+	sort.Slice(largest, func(i, j int) bool {
+		if largest[i].Lines != largest[j].Lines {
+			return largest[i].Lines > largest[j].Lines
+		}
+		return largest[i].Path < largest[j].Path
+	})
+	if len(largest) > largestFilesLimit {
+		largest = largest[:largestFilesLimit]
+	}
+	result.LargestFiles = largest
+	return result, nil
+}
+
+// commentShare formats the comment lines of s as a percentage of its lines.
+func commentShare(s LineStats) string {
+	if s.Lines == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d%%", (s.CommentLines*100+s.Lines/2)/s.Lines)
+}
+
+// testRatio formats the test lines of s per non-test line.
+func testRatio(s LineStats) string {
+	if s.Lines == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", float64(s.TestLines)/float64(s.Lines))
+}
+
+// packageStatsRows returns the packages with Stats, for the per-package
+// breakdown of Options.StatisticsPackages.
+func packageStatsRows(packages []Package) []Package {
+	var rows []Package
+	for _, pkg := range packages {
+		if pkg.Stats != nil {
+			rows = append(rows, pkg)
+		}
+	}
+	return rows
+}
+
+func (s *LineStats) add(o LineStats) {
+	s.Files += o.Files
+	s.Lines += o.Lines
+	s.CommentLines += o.CommentLines
+	s.TestFiles += o.TestFiles
+	s.TestLines += o.TestLines
+}
+
+// statsOwners maps files to the packages they are counted toward.
+type statsOwners struct {
+	dirs     map[string]map[string][]int // Language, then directory, to package indexes
+	patterns []string                    // Base name pattern of each package, "" for directories
+}
+
+func newStatsOwners(packages []Package) statsOwners {
+	o := statsOwners{dirs: make(map[string]map[string][]int), patterns: make([]string, len(packages))}
+	for i, pkg := range packages {
+		if isNestedCodemap(pkg) {
+			continue
+		}
+		dirs := o.dirs[pkg.Language]
+		if dirs == nil {
+			dirs = make(map[string][]int)
+			o.dirs[pkg.Language] = dirs
+		}
+		dir := packageFileDir(pkg.RelativePath)
+		if dir != pkg.RelativePath {
+			o.patterns[i] = path.Base(pkg.RelativePath)
+		}
+		dirs[dir] = append(dirs[dir], i)
+	}
+	return o
+}
+
+// find returns the package of language owning relPath. Packages grouping
+// files by a name pattern, such as shell prefix groups, take the files they
+// match over the package they sit beside.
+func (o statsOwners) find(language, relPath string) (int, bool) {
+	dirs := o.dirs[language]
+	if dirs == nil {
+		return 0, false
+	}
+	name := path.Base(relPath)
+	for dir := path.Dir(relPath); ; dir = path.Dir(dir) {
+		if candidates, ok := dirs[dir]; ok {
+			plain := -1
+			for _, i := range candidates {
+				if o.patterns[i] == "" {
+					if plain < 0 {
+						plain = i
+					}
+				} else if matched, _ := path.Match(o.patterns[i], name); matched {
+					return i, true
+				}
+			}
+			if plain >= 0 {
+				return plain, true
+			}
+		}
+		if dir == "." {
+			return 0, false
+		}
+	}
+}
+
+// countSourceLines returns the lines of content and how many of them are
+// comments in syntax.
+func countSourceLines(content []byte, syntax commentSyntax) (lines, comments int) {
+	inBlock := false
+	for len(content) > 0 {
+		line := content
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			line, content = content[:i], content[i+1:]
+		} else {
+			content = nil
+		}
+		lines++
+		text := strings.TrimSpace(string(line))
+		switch {
+		case inBlock:
+			comments++
+			inBlock = !strings.Contains(text, syntax.blockEnd)
+		case text == "":
+		case syntax.line != "" && strings.HasPrefix(text, syntax.line):
+			comments++
+		case syntax.blockStart != "" && strings.HasPrefix(text, syntax.blockStart):
+			comments++
+			inBlock = !strings.Contains(text[len(syntax.blockStart):], syntax.blockEnd)
+		}
+	}
+	return lines, comments
+}
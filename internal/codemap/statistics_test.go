@@ -0,0 +1,124 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCountSourceLines(t *testing.T) {
+	tests := []struct {
+		language string
+		content  string
+		lines    int
+		comments int
+	}{
+		{languageGo, "// Package a.\npackage a\n\n/*\nblock\n*/\nvar x = 1 // trailing\n", 7, 4},
+		{languagePython, "#!/usr/bin/env python\n\"\"\"Doc.\n\nMore.\n\"\"\"\nx = 1\n", 6, 5},
+		{languagePython, "\"\"\"One line.\"\"\"\nx = 1", 2, 1},
+		{languageSQL, "-- schema\nCREATE TABLE t (id int);\n", 2, 1},
+		{"zig", "// unknown\nconst x = 1;\n", 2, 0},
+		{languageGo, "", 0, 0},
+	}
+	for _, tt := range tests {
+		lines, comments := countSourceLines([]byte(tt.content), commentSyntaxes[tt.language])
+		if lines != tt.lines || comments != tt.comments {
+			t.Errorf("countSourceLines(%s, %q) = %d, %d, want %d, %d", tt.language, tt.content, lines, comments, tt.lines, tt.comments)
+		}
+	}
+}
+
+func TestStatisticsSection(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":              "module example.com/app\n\ngo 1.22\n",
+		"main.go":             "// Command app runs.\npackage main\n\nfunc main() {}\n",
+		"store/store.go":      "// Package store saves things.\npackage store\n\n// Save saves.\nfunc Save() {}\n\nfunc load() {}\n",
+		"store/store_test.go": "package store\n\nimport \"testing\"\n\nfunc TestSave(t *testing.T) {}\n",
+		"scripts/deploy-a.sh": "#!/bin/sh\n# Deploys a.\necho a\n",
+		"scripts/deploy-b.sh": "#!/bin/sh\necho b\n",
+		"scripts/build.sh":    "#!/bin/sh\necho build\n",
+		"scripts/lib/util.sh": "#!/bin/sh\necho util\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.ShellPrefixes = []string{"deploy-"}
+	opts.StatisticsPackages = true
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	if cm.Statistics == nil {
+		t.Fatal("expected statistics")
+	}
+
+	stats := make(map[string]LineStats)
+	for _, pkg := range cm.Packages {
+		if pkg.Stats == nil {
+			t.Fatalf("expected stats for package %s", pkg.RelativePath)
+		}
+		stats[pkg.Language+":"+pkg.RelativePath] = pkg.Stats.LineStats
+	}
+	wantStats := map[string]LineStats{
+		"go:.":           {Files: 1, Lines: 4, CommentLines: 1},
+		"go:store":       {Files: 1, Lines: 7, CommentLines: 2, TestFiles: 1, TestLines: 5},
+		"shell:.":        {Files: 2, Lines: 4, CommentLines: 2},
+		"shell:deploy-*": {Files: 2, Lines: 5, CommentLines: 3},
+	}
+	if !reflect.DeepEqual(stats, wantStats) {
+		t.Fatalf("package stats =\n%+v\nwant\n%+v", stats, wantStats)
+	}
+
+	content, err := Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	for _, want := range []string{
+		"## Statistics",
+		"| store | go | 1 | 7 | 29% | 5 | store/store.go (7) |",
+		"Largest files: store/store.go (7),",
+	} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("expected %q in:\n%s", want, content)
+		}
+	}
+}
+
+func TestStatisticsOptionsMakeOutputsStale(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.22\n",
+		"main.go": "// Command app runs.\npackage main\n\nfunc main() {}\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	ctx := context.Background()
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	if _, err := Generate(ctx, opts); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	opts.Statistics = true
+	if stale, err := IsStale(ctx, opts); err != nil || !stale {
+		t.Fatalf("IsStale after enabling Statistics = %v, %v; want true", stale, err)
+	}
+	if _, generated, err := EnsureUpToDate(ctx, opts); err != nil || !generated {
+		t.Fatalf("EnsureUpToDate = %v, %v; want a regeneration", generated, err)
+	}
+	markdown, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(markdown), "## Statistics") {
+		t.Fatalf("expected a Statistics section:\n%s", markdown)
+	}
+	opts.StatisticsPackages = true
+	if stale, err := IsStale(ctx, opts); err != nil || !stale {
+		t.Fatalf("IsStale after enabling StatisticsPackages = %v, %v; want true", stale, err)
+	}
+}
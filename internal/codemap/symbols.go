@@ -0,0 +1,105 @@
+package codemap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Symbol locates one exported type or function within its package.
+type Symbol struct {
+	Name string
+	Kind string // TypeInfo kind for types (struct, class, trait, ...), "func" for functions
+	File string // Relative to the package, like File.Name
+}
+
+// packageSymbols lists the exported types and functions of files in file
+// order, taking kinds from the package's TypeInfos where a name matches.
+func packageSymbols(files []File, types []TypeInfo) []Symbol {
+	kinds := make(map[string]string, len(types))
+	for _, typ := range types {
+		if _, ok := kinds[typ.Name]; !ok {
+			kinds[typ.Name] = typ.Kind
+		}
+	}
+	kindOf := func(name, fallback string) string {
+		if kind := kinds[name]; kind != "" {
+			return kind
+		}
+		return fallback
+	}
+
+	var symbols []Symbol
+	for _, file := range files {
+		for _, name := range file.KeyTypes {
+			symbols = append(symbols, Symbol{Name: name, Kind: kindOf(name, "type"), File: file.Name})
+		}
+		for _, name := range file.KeyFuncs {
+			symbols = append(symbols, Symbol{Name: name, Kind: kindOf(name, "func"), File: file.Name})
+		}
+	}
+	return symbols
+}
+
+// SymbolsRenderer renders CODEMAP.symbols output.
+type SymbolsRenderer struct{}
+
+func (SymbolsRenderer) Name() string        { return "symbols" }
+func (SymbolsRenderer) DefaultPath() string { return "CODEMAP.symbols" }
+func (SymbolsRenderer) Render(cm *Codemap) (string, error) {
+	return renderSymbols(cm), nil
+}
+
+type symbolRow struct {
+	name, kind, pkg, file string
+}
+
+// renderSymbols writes one tab-separated row per exported symbol, sorted by
+// name and then location, so the file can be grepped or binary searched.
+func renderSymbols(cm *Codemap) string {
+	var rows []symbolRow
+	for _, pkg := range cm.Packages {
+		for _, sym := range pkg.Symbols {
+			file := entryPath(Package{RelativePath: pkg.RelativePath, EntryPoint: sym.File})
+			rows = append(rows, symbolRow{name: sym.Name, kind: sym.Kind, pkg: pkg.RelativePath, file: file})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].name != rows[j].name {
+			return rows[i].name < rows[j].name
+		}
+		if rows[i].file != rows[j].file {
+			return rows[i].file < rows[j].file
+		}
+		return rows[i].kind < rows[j].kind
+	})
+
+	var sb strings.Builder
+	sb.WriteString("# codemap-hash: ")
+	sb.WriteString(cm.ContentHash)
+	sb.WriteString("\n# Format: symbol\\tkind\\tpackage\\tfile\n")
+	for _, row := range rows {
+		sb.WriteString(row.name)
+		sb.WriteByte('\t')
+		sb.WriteString(row.kind)
+		sb.WriteByte('\t')
+		sb.WriteString(row.pkg)
+		sb.WriteByte('\t')
+		sb.WriteString(row.file)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// symbolsOutputStale reports whether an enabled symbols output is missing or
+// was written for a different content hash than the markdown output.
+func symbolsOutputStale(root string, opts Options, existingHash string) (bool, error) {
+	if opts.SymbolsOutputPath == "" {
+		return false, nil
+	}
+	symbolsHash, err := readExistingHash(outputAbsPath(root, opts.SymbolsOutputPath), opts.HashScanLines)
+	if err != nil {
+		return false, fmt.Errorf("read existing symbols hash: %w", err)
+	}
+	return symbolsHash == "" || symbolsHash != existingHash, nil
+}
@@ -0,0 +1,55 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSymbolsOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                    "module example.com/app\n\ngo 1.22\n",
+		"internal/store/store.go":   "package store\n\n// Store keeps records.\ntype Store struct{}\n\ntype Reader interface{ Read() }\n\nfunc Open() *Store { return nil }\n\nfunc helper() {}\n",
+		"internal/store/options.go": "package store\n\ntype Option func(*Store)\n",
+		"tools/lib/__init__.py":     "",
+		"tools/lib/config.py":       "MAX_SIZE = 10\n\nclass Config:\n    \"\"\"Parsed settings.\"\"\"\n\ndef load(path):\n    \"\"\"Load a config.\"\"\"\n    return Config()\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.SymbolsOutputPath = "CODEMAP.symbols"
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.symbols"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if lines[0] != "# codemap-hash: "+cm.ContentHash || !strings.HasPrefix(lines[1], "# Format: ") {
+		t.Fatalf("unexpected header:\n%s", data)
+	}
+	want := []string{
+		"Config\tclass\t.\ttools/lib/config.py",
+		"MAX_SIZE\tconst\t.\ttools/lib/config.py",
+		"Open\tfunc\tinternal/store\tinternal/store/store.go",
+		"Option\ttype\tinternal/store\tinternal/store/options.go",
+		"Reader\tinterface\tinternal/store\tinternal/store/store.go",
+		"Store\tstruct\tinternal/store\tinternal/store/store.go",
+		"load\tfunc\t.\ttools/lib/config.py",
+	}
+	if !reflect.DeepEqual(lines[2:], want) {
+		t.Fatalf("unexpected symbols:\n got %q\nwant %q", lines[2:], want)
+	}
+
+	if stale, err := IsStale(context.Background(), opts); err != nil || stale {
+		t.Fatalf("expected fresh outputs, got stale=%v err=%v", stale, err)
+	}
+}
@@ -0,0 +1,163 @@
+package codemap
+
+import (
+	"bufio"
+	"bytes"
+	"go/ast"
+	"sort"
+	"strings"
+)
+
+// codemapTagMarker introduces a comma-separated tag list in a file's leading
+// doc comment, e.g. "// codemap:tag=payments,critical".
+const codemapTagMarker = "codemap:tag="
+
+// TagGroup lists the packages carrying a tag.
+type TagGroup struct {
+	Name     string
+	Packages []string
+}
+
+// extractCodemapTags returns tags declared in the leading comment block of a
+// file. It understands line comments (//, #, --, ;), block comments (/* */,
+// <!-- -->) and Python docstrings, and stops at the first line of code.
+func extractCodemapTags(content []byte) []string {
+	var tags []string
+	closing := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if closing != "" {
+			tags = append(tags, parseCodemapTagLine(line)...)
+			if strings.Contains(line, closing) {
+				closing = ""
+			}
+			continue
+		}
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "//"), strings.HasPrefix(line, "#"),
+			strings.HasPrefix(line, "--"), strings.HasPrefix(line, ";"):
+			tags = append(tags, parseCodemapTagLine(line)...)
+		case strings.HasPrefix(line, "/*"):
+			tags = append(tags, parseCodemapTagLine(line)...)
+			if !strings.Contains(line[2:], "*/") {
+				closing = "*/"
+			}
+		case strings.HasPrefix(line, "<!--"):
+			tags = append(tags, parseCodemapTagLine(line)...)
+			if !strings.Contains(line[4:], "-->") {
+				closing = "-->"
+			}
+		case strings.HasPrefix(line, `"""`), strings.HasPrefix(line, "'''"):
+			delim := line[:3]
+			tags = append(tags, parseCodemapTagLine(line)...)
+			if !strings.Contains(line[3:], delim) {
+				closing = delim
+			}
+		default:
+			return mergeCodemapTags(nil, tags)
+		}
+	}
+	return mergeCodemapTags(nil, tags)
+}
+
+// extractGoDocTags returns tags declared in a Go file's package doc comment.
+// Raw comment text is used because ast.CommentGroup.Text drops directive-style
+// lines such as "//codemap:tag=...".
+func extractGoDocTags(doc *ast.CommentGroup) []string {
+	if doc == nil {
+		return nil
+	}
+	var tags []string
+	for _, c := range doc.List {
+		for _, line := range strings.Split(c.Text, "\n") {
+			tags = append(tags, parseCodemapTagLine(line)...)
+		}
+	}
+	return mergeCodemapTags(nil, tags)
+}
+
+func parseCodemapTagLine(line string) []string {
+	idx := strings.Index(line, codemapTagMarker)
+	if idx < 0 {
+		return nil
+	}
+	value := line[idx+len(codemapTagMarker):]
+	for _, closing := range []string{"*/", "-->", `"""`, "'''"} {
+		if end := strings.Index(value, closing); end >= 0 {
+			value = value[:end]
+		}
+	}
+
+	// The list ends at the first tag followed by other text, so
+	// "codemap:tag=a, b trailing words" yields [a b].
+	var tags []string
+	for _, part := range strings.Split(value, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		tags = append(tags, fields[0])
+		if len(fields) > 1 {
+			break
+		}
+	}
+	return tags
+}
+
+// mergeCodemapTags returns the sorted, de-duplicated union of dst and src.
+func mergeCodemapTags(dst, src []string) []string {
+	if len(src) == 0 {
+		return dst
+	}
+	seen := make(map[string]struct{}, len(dst)+len(src))
+	merged := make([]string, 0, len(dst)+len(src))
+	for _, tag := range append(append([]string(nil), dst...), src...) {
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		merged = append(merged, tag)
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// PackageTagIndex groups package paths by tag, sorted by tag name.
+func PackageTagIndex(packages []Package) []TagGroup {
+	byTag := make(map[string][]string)
+	for _, pkg := range packages {
+		for _, tag := range pkg.Tags {
+			byTag[tag] = append(byTag[tag], pkg.RelativePath)
+		}
+	}
+
+	groups := make([]TagGroup, 0, len(byTag))
+	for tag, paths := range byTag {
+		sort.Strings(paths)
+		groups = append(groups, TagGroup{Name: tag, Packages: paths})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// stripCodemapTagLines removes tag marker lines so they never become purposes.
+func stripCodemapTagLines(text string) string {
+	if !strings.Contains(text, codemapTagMarker) {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.Contains(line, codemapTagMarker) {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,74 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractCodemapTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"line comment", "// Package billing.\n// codemap:tag=payments, critical\nexport const x = 1;\n", []string{"critical", "payments"}},
+		{"hash comment", "#!/usr/bin/env bash\n# codemap:tag=ops\necho hi\n", []string{"ops"}},
+		{"block comment", "/**\n * Billing API.\n * codemap:tag=payments\n */\nexport {}\n", []string{"payments"}},
+		{"docstring", "\"\"\"Billing helpers.\n\ncodemap:tag=payments,critical\n\"\"\"\nimport os\n", []string{"critical", "payments"}},
+		{"single line docstring", "\"\"\"Billing. codemap:tag=payments\"\"\"\n", []string{"payments"}},
+		{"sql comment", "-- codemap:tag=data\nCREATE TABLE t (id int);\n", []string{"data"}},
+		{"html comment", "<!-- codemap:tag=web -->\n<div></div>\n", []string{"web"}},
+		{"after code ignored", "package main\n// codemap:tag=late\n", nil},
+	}
+	for _, tt := range tests {
+		if got := extractCodemapTags([]byte(tt.content)); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("%s: got %v want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzeSurfacesPackageTags(t *testing.T) {
+	tmpDir := t.TempDir()
+	billingDir := filepath.Join(tmpDir, "billing")
+	if err := os.MkdirAll(billingDir, 0755); err != nil {
+		t.Fatalf("mkdir billing: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/test\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatalf("write go.mod: %v", err)
+	}
+	src := "// Package billing charges customers.\n//\n//codemap:tag=payments,critical\npackage billing\n\nfunc Charge() {}\n"
+	if err := os.WriteFile(filepath.Join(billingDir, "billing.go"), []byte(src), 0644); err != nil {
+		t.Fatalf("write billing.go: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	if len(cm.Packages) != 1 || !reflect.DeepEqual(cm.Packages[0].Tags, []string{"critical", "payments"}) {
+		t.Fatalf("expected billing tags, got %+v", cm.Packages)
+	}
+	if cm.Packages[0].Purpose != "Package billing charges customers." {
+		t.Fatalf("tag marker leaked into purpose: %q", cm.Packages[0].Purpose)
+	}
+
+	content, err := Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if !strings.Contains(content, "## Tags") || !strings.Contains(content, "| payments | billing |") {
+		t.Fatalf("expected tags section in output:\n%s", content)
+	}
+}
+
+func TestExtractFirstSentenceSkipsTagLines(t *testing.T) {
+	if got := extractFirstSentence("codemap:tag=payments\nBilling helpers. More text."); got != "Billing helpers." {
+		t.Fatalf("unexpected purpose: %q", got)
+	}
+}
@@ -0,0 +1,147 @@
+package codemap
+
+import (
+	"context"
+	"path"
+	"strings"
+)
+
+// testTreeDirs hold tests apart from the code they exercise, as Python and
+// Rust tests/, JavaScript __tests__/ and RSpec spec/ directories do.
+var testTreeDirs = map[string]struct{}{
+	"test":      {},
+	"tests":     {},
+	"__tests__": {},
+	"spec":      {},
+	"specs":     {},
+}
+
+// assignTestFiles links each package to the indexed test files counted
+// toward it, whether or not tests are analyzed, so packages without tests
+// stand out. Each test file counts toward the deepest package of its
+// language containing it, except that Python, JavaScript, TypeScript, Rust
+// and Ruby tests kept in a separate test tree count toward the package
+// holding the file they are named after, when one exists. Line counts are
+// read through scans.
+func assignTestFiles(ctx context.Context, scans *fileScans, idx *FileIndex, packages []Package) error {
+	for i := range packages {
+		packages[i].TestFiles = nil
+		packages[i].TestLineCount = 0
+	}
+	if idx == nil {
+		return nil
+	}
+	owners := newStatsOwners(packages)
+	var subjects map[string][]int
+	for _, rec := range idx.Files {
+		if !rec.IsTest {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		pkgIndex, ok := 0, false
+		if stem := testSubjectStem(rec.Language, rec.RelPath); stem != "" {
+			if subjects == nil {
+				subjects = testSubjects(idx, owners)
+			}
+			pkgIndex, ok = closestPackage(packages, subjects[dependencyFamily(rec.Language)+"\x00"+stem], rec.RelPath)
+		}
+		if !ok {
+			pkgIndex, ok = owners.find(rec.Language, rec.RelPath)
+		}
+		if !ok {
+			continue
+		}
+		pkg := &packages[pkgIndex]
+		pkg.TestFiles = append(pkg.TestFiles, rec.RelPath)
+		if scan, ok := scans.scan(rec.RelPath); ok {
+			pkg.TestLineCount += scan.Lines
+		}
+	}
+	return nil
+}
+
+// testSubjectStem returns the name of the file a test in a separate test
+// tree exercises, without extension: foo for tests/test_foo.py,
+// __tests__/foo.test.ts, spec/foo_spec.rb or tests/foo.rs. It is empty for
+// other test files.
+func testSubjectStem(language, relPath string) string {
+	switch language {
+	case languagePython, languageJavaScript, languageTypeScript, languageRust, languageRuby:
+	default:
+		return ""
+	}
+	inTree := false
+	for _, part := range strings.Split(path.Dir(relPath), "/") {
+		if _, ok := testTreeDirs[part]; ok {
+			inTree = true
+			break
+		}
+	}
+	if !inTree {
+		return ""
+	}
+	base := path.Base(relPath)
+	stem := strings.TrimSuffix(base, path.Ext(base))
+	switch language {
+	case languagePython:
+		stem = strings.TrimSuffix(strings.TrimPrefix(stem, "test_"), "_test")
+	case languageJavaScript, languageTypeScript:
+		stem = strings.TrimSuffix(strings.TrimSuffix(stem, ".test"), ".spec")
+	case languageRuby:
+		stem = strings.TrimPrefix(strings.TrimSuffix(strings.TrimSuffix(stem, "_spec"), "_test"), "test_")
+	}
+	return stem
+}
+
+// testSubjects maps each language family and file name without extension
+// to the packages holding a non-test file of that name.
+func testSubjects(idx *FileIndex, owners statsOwners) map[string][]int {
+	subjects := make(map[string][]int)
+	for _, rec := range idx.Files {
+		if rec.IsTest {
+			continue
+		}
+		i, ok := owners.find(rec.Language, rec.RelPath)
+		if !ok {
+			continue
+		}
+		base := path.Base(rec.RelPath)
+		key := dependencyFamily(rec.Language) + "\x00" + strings.TrimSuffix(base, path.Ext(base))
+		if n := len(subjects[key]); n == 0 || subjects[key][n-1] != i {
+			subjects[key] = append(subjects[key], i)
+		}
+	}
+	return subjects
+}
+
+// closestPackage returns the candidate whose directory shares the longest
+// prefix with relPath's, preferring the first on ties.
+func closestPackage(packages []Package, candidates []int, relPath string) (int, bool) {
+	best, bestLen := 0, -1
+	dir := path.Dir(relPath)
+	for _, i := range candidates {
+		pkgDir := packageFileDir(packages[i].RelativePath)
+		a, b := strings.Split(dir, "/"), strings.Split(pkgDir, "/")
+		shared := 0
+		for shared < len(a) && shared < len(b) && a[shared] == b[shared] {
+			shared++
+		}
+		if shared > bestLen {
+			best, bestLen = i, shared
+		}
+	}
+	return best, bestLen >= 0
+}
+
+// hasPackageTests reports whether any package has test files, so the Tests
+// column is only rendered for projects with tests.
+func hasPackageTests(packages []Package) bool {
+	for _, pkg := range packages {
+		if len(pkg.TestFiles) > 0 {
+			return true
+		}
+	}
+	return false
+}
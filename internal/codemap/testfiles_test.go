@@ -0,0 +1,136 @@
+package codemap
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPackagesListTestFilesWithoutIncludeTests(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                  "module example.com/app\n\ngo 1.22\n",
+		"main.go":                 "package main\n\nfunc main() {}\n",
+		"store/store.go":          "// Package store keeps items.\npackage store\n",
+		"store/store_test.go":     "package store\n\nimport \"testing\"\n\nfunc TestStore(t *testing.T) {}\n",
+		"store/export_test.go":    "package store_test\n",
+		"api/api.go":              "// Package api serves items.\npackage api\n",
+		"tools/pyproject.toml":    "[project]\nname = \"tools\"\n",
+		"tools/tools/__init__.py": "\"\"\"Release tooling.\"\"\"\n",
+		"tools/tests/test_cli.py": "def test_cli():\n    pass\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	for _, includeTests := range []bool{false, true} {
+		opts := DefaultOptions()
+		opts.ProjectRoot = tmpDir
+		opts.IncludeTests = includeTests
+		cm, err := Analyze(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Analyze returned error: %v", err)
+		}
+		byPath := make(map[string]Package)
+		for _, pkg := range cm.Packages {
+			byPath[pkg.Language+":"+pkg.RelativePath] = pkg
+		}
+		store := byPath["go:store"]
+		if !reflect.DeepEqual(store.TestFiles, []string{"store/export_test.go", "store/store_test.go"}) || store.TestLineCount != 8 {
+			t.Fatalf("includeTests=%v: unexpected store tests: %v, %d lines", includeTests, store.TestFiles, store.TestLineCount)
+		}
+		if tools := byPath["python:tools"]; !reflect.DeepEqual(tools.TestFiles, []string{"tools/tests/test_cli.py"}) {
+			t.Fatalf("includeTests=%v: unexpected tools tests: %+v", includeTests, tools)
+		}
+		if api := byPath["go:api"]; api.TestFiles != nil || api.TestLineCount != 0 {
+			t.Fatalf("includeTests=%v: expected no api tests, got %v", includeTests, api.TestFiles)
+		}
+
+		content, err := Render(cm)
+		if err != nil {
+			t.Fatalf("Render returned error: %v", err)
+		}
+		for _, row := range []string{
+			"| Package | Entry File | Purpose | Tests |",
+			"| Package api serves items. | 0 |",
+			"| Package store keeps items. | 2 |",
+		} {
+			if !strings.Contains(content, row) {
+				t.Fatalf("includeTests=%v: expected %q in:\n%s", includeTests, row, content)
+			}
+		}
+	}
+}
+
+func TestTestTreesCountTowardTheirSubject(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"package.json":                "{\"name\": \"root\"}\n",
+		"index.js":                    "module.exports = {};\n",
+		"__tests__/button.test.js":    "test('button', () => {});\n",
+		"packages/ui/package.json":    "{\"name\": \"@acme/ui\"}\n",
+		"packages/ui/src/button.js":   "export function button() {}\n",
+		"svc/pyproject.toml":          "[project]\nname = \"svc\"\n",
+		"svc/svc/__init__.py":         "\"\"\"Service.\"\"\"\n",
+		"lib/pyproject.toml":          "[project]\nname = \"lib\"\n",
+		"lib/lib/util.py":             "def helper():\n    pass\n",
+		"svc/tests/test_util.py":      "def test_helper():\n    pass\n",
+		"svc/tests/test_unmatched.py": "def test_other():\n    pass\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	got := make(map[string][]string)
+	for _, pkg := range cm.Packages {
+		if len(pkg.TestFiles) > 0 {
+			got[pkg.Language+":"+pkg.RelativePath] = pkg.TestFiles
+		}
+	}
+	want := map[string][]string{
+		"javascript:packages/ui": {"__tests__/button.test.js"},
+		"python:lib":             {"svc/tests/test_util.py"},
+		"python:svc":             {"svc/tests/test_unmatched.py"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("test files = %v, want %v", got, want)
+	}
+}
+
+func TestTestLineCountsFollowEditsThroughCachedScans(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestTree(t, tmpDir, map[string]string{
+		"go.mod":              "module example.com/app\n\ngo 1.22\n",
+		"store/store.go":      "// Package store keeps items.\npackage store\n",
+		"store/store_test.go": "package store\n",
+	})
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	lines := func() int {
+		t.Helper()
+		cm, err := Generate(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Generate returned error: %v", err)
+		}
+		for _, pkg := range cm.Packages {
+			if pkg.RelativePath == "store" {
+				return pkg.TestLineCount
+			}
+		}
+		t.Fatal("expected a store package")
+		return 0
+	}
+	if got := lines(); got != 2 {
+		t.Fatalf("TestLineCount = %d, want 2", got)
+	}
+	if got := lines(); got != 2 {
+		t.Fatalf("TestLineCount from cached scans = %d, want 2", got)
+	}
+	writeTestTree(t, tmpDir, map[string]string{"store/store_test.go": "package store\n\nimport \"testing\"\n\nfunc TestStore(t *testing.T) {}\n"})
+	if got := lines(); got != 6 {
+		t.Fatalf("TestLineCount after editing the test = %d, want 6", got)
+	}
+}
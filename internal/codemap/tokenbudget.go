@@ -0,0 +1,101 @@
+package codemap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tokenBudgetSteps are the details dropped, in order, to fit the markdown and
+// paths outputs into Options.MaxOutputTokens. Each step works on a copy whose
+// Packages slice may be modified in place.
+var tokenBudgetSteps = []struct {
+	name  string
+	apply func(cm *Codemap)
+}{
+	{"file listings", func(cm *Codemap) {
+		for i := range cm.Packages {
+			cm.Packages[i].Files = nil
+		}
+	}},
+	{"purposes", func(cm *Codemap) {
+		for i := range cm.Packages {
+			cm.Packages[i].Purpose = ""
+		}
+	}},
+	{"concerns", func(cm *Codemap) {
+		cm.Concerns = nil
+	}},
+}
+
+// applyTokenBudget returns the model the markdown and paths outputs are
+// rendered from under Options.MaxOutputTokens: cm itself when every renderer
+// fits, otherwise a copy with tokenBudgetSteps applied until they do. The
+// details omitted are added to cm.Warnings, so the command and the JSON output
+// report them while keeping the full model.
+func applyTokenBudget(cm *Codemap, opts Options, renderers ...Renderer) (*Codemap, error) {
+	if opts.MaxOutputTokens <= 0 {
+		return cm, nil
+	}
+	tokens, err := renderedTokens(cm, renderers)
+	if err != nil || tokens <= opts.MaxOutputTokens {
+		return cm, err
+	}
+
+	view := *cm
+	view.Packages = append([]Package(nil), cm.Packages...)
+	var omitted []string
+	for _, step := range tokenBudgetSteps {
+		step.apply(&view)
+		reduced, err := renderedTokens(&view, renderers)
+		if err != nil {
+			return nil, err
+		}
+		if reduced < tokens {
+			omitted = append(omitted, step.name)
+		}
+		tokens = reduced
+		if tokens <= opts.MaxOutputTokens {
+			break
+		}
+	}
+
+	warning := fmt.Sprintf("outputs exceed the %d-token budget", opts.MaxOutputTokens)
+	if len(omitted) > 0 {
+		warning += "; omitted " + strings.Join(omitted, ", ")
+	}
+	if tokens > opts.MaxOutputTokens {
+		warning += fmt.Sprintf("; still about %d tokens", tokens)
+	}
+	cm.Warnings = append(cm.Warnings, warning)
+	view.Warnings = cm.Warnings
+	return &view, nil
+}
+
+// outputRenderers returns the renderers of the outputs Options.MaxOutputTokens
+// limits.
+func outputRenderers(opts Options, markdown MarkdownRenderer, paths PathsRenderer) []Renderer {
+	if opts.DisablePaths {
+		return []Renderer{markdown}
+	}
+	return []Renderer{markdown, paths}
+}
+
+// renderedTokens returns the estimated tokens of the largest output of
+// renderers for cm.
+func renderedTokens(cm *Codemap, renderers []Renderer) (int, error) {
+	largest := 0
+	for _, renderer := range renderers {
+		content, err := renderer.Render(cm)
+		if err != nil {
+			return 0, fmt.Errorf("render %s: %w", renderer.Name(), err)
+		}
+		largest = max(largest, estimateTokens(len(content)))
+	}
+	return largest, nil
+}
+
+// estimateTokens approximates the tokens of size bytes of output at roughly
+// four bytes per token.
+func estimateTokens(size int) int {
+	return (size + 3) / 4
+}
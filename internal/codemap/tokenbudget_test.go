@@ -0,0 +1,88 @@
+package codemap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func tokenBudgetModel() *Codemap {
+	cm := &Codemap{ContentHash: "abc123"}
+	for i := range 4 {
+		pkg := Package{
+			RelativePath: fmt.Sprintf("internal/pkg%d", i),
+			Language:     languageGo,
+			Purpose:      strings.Repeat("Handles a rather long description of things. ", 2),
+			EntryPoint:   "pkg.go",
+		}
+		for j := range 10 {
+			pkg.Files = append(pkg.Files, File{
+				Name:     fmt.Sprintf("file%d.go", j),
+				Purpose:  "Implements one part of the package in detail.",
+				KeyTypes: []string{"Alpha", "Beta"},
+				Role:     "handlers",
+			})
+		}
+		cm.Packages = append(cm.Packages, pkg)
+	}
+	cm.Concerns = []Concern{{Name: "testing", TotalFiles: 3, Files: []string{"a_test.go", "b_test.go", "c_test.go"}}}
+	return cm
+}
+
+func TestApplyTokenBudgetDropsDetailInOrder(t *testing.T) {
+	renderers := []Renderer{MarkdownRenderer{}, PathsRenderer{}}
+	full, err := renderedTokens(tokenBudgetModel(), renderers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("within budget", func(t *testing.T) {
+		cm := tokenBudgetModel()
+		opts := Options{MaxOutputTokens: full}
+		view, err := applyTokenBudget(cm, opts, renderers...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if view != cm || len(cm.Warnings) != 0 {
+			t.Fatalf("expected the model unchanged within budget, got warnings %v", cm.Warnings)
+		}
+	})
+
+	t.Run("file listings", func(t *testing.T) {
+		cm := tokenBudgetModel()
+		opts := Options{MaxOutputTokens: full - 1}
+		view, err := applyTokenBudget(cm, opts, renderers...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(view.Packages[0].Files) != 0 || view.Packages[0].Purpose == "" || len(view.Concerns) != 1 {
+			t.Fatalf("expected only file listings dropped, got %+v", view.Packages[0])
+		}
+		if len(cm.Packages[0].Files) != 10 {
+			t.Fatalf("expected the full model to keep its files, got %d", len(cm.Packages[0].Files))
+		}
+		want := fmt.Sprintf("outputs exceed the %d-token budget; omitted file listings", full-1)
+		if len(cm.Warnings) != 1 || cm.Warnings[0] != want || view.Warnings[0] != want {
+			t.Fatalf("warnings = %v, want %q", cm.Warnings, want)
+		}
+	})
+
+	t.Run("over budget", func(t *testing.T) {
+		cm := tokenBudgetModel()
+		opts := Options{MaxOutputTokens: 10}
+		view, err := applyTokenBudget(cm, opts, renderers...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if view.Packages[0].Purpose != "" || view.Concerns != nil {
+			t.Fatalf("expected purposes and concerns dropped, got %+v", view)
+		}
+		if cm.Packages[0].Purpose == "" {
+			t.Fatal("expected the full model to keep its purposes")
+		}
+		warning := cm.Warnings[0]
+		if !strings.Contains(warning, "omitted file listings, purposes, concerns; still about ") {
+			t.Fatalf("unexpected warning %q", warning)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package codemap
+
+import (
+	"bufio"
+	"path"
+	"sort"
+	"strings"
+)
+
+// toolchainFiles pin a language version for the directory they sit in, in
+// the order they are reported within one directory.
+var toolchainFiles = []struct {
+	name     string
+	language string
+	parse    func(content string) (version, toolchain string)
+}{
+	{"go.mod", languageGo, parseGoModToolchain},
+	{"rust-toolchain.toml", languageRust, parseRustToolchain},
+	{"rust-toolchain", languageRust, parseRustToolchain},
+	{".nvmrc", "node", parseVersionFile},
+	{".python-version", languagePython, parseVersionFile},
+}
+
+// detectToolchains reads the language versions pinned in the project root and
+// in the directories of packages and their parents, so a monorepo reports
+// each go.mod or .nvmrc it contains. Files without a version are skipped.
+func detectToolchains(aux *auxInputs, packages []Package) []ToolchainInfo {
+	dirs := map[string]struct{}{".": {}}
+	for _, pkg := range packages {
+		if isNestedCodemap(pkg) {
+			continue
+		}
+		for dir := packageFileDir(pkg.RelativePath); dir != "."; dir = path.Dir(dir) {
+			if _, seen := dirs[dir]; seen {
+				break
+			}
+			dirs[dir] = struct{}{}
+		}
+	}
+
+	var toolchains []ToolchainInfo
+	for dir := range dirs {
+		for _, file := range toolchainFiles {
+			source := path.Join(dir, file.name)
+			content, err := aux.readFile(source)
+			if err != nil {
+				continue
+			}
+			version, toolchain := file.parse(string(content))
+			if version == "" && toolchain == "" {
+				continue
+			}
+			toolchains = append(toolchains, ToolchainInfo{
+				Language:  file.language,
+				Version:   version,
+				Toolchain: toolchain,
+				Source:    source,
+			})
+		}
+	}
+	sort.Slice(toolchains, func(i, j int) bool {
+		di, dj := path.Dir(toolchains[i].Source), path.Dir(toolchains[j].Source)
+		if di != dj {
+			return di < dj
+		}
+		return toolchainFileRank(toolchains[i].Source) < toolchainFileRank(toolchains[j].Source)
+	})
+	return toolchains
+}
+
+func toolchainFileRank(source string) int {
+	base := path.Base(source)
+	for i, file := range toolchainFiles {
+		if file.name == base {
+			return i
+		}
+	}
+	return len(toolchainFiles)
+}
+
+// parseGoModToolchain returns the go and toolchain directives of a go.mod.
+func parseGoModToolchain(content string) (string, string) {
+	var version, toolchain string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if idx := strings.Index(line, "//"); idx >= 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "go":
+			version = fields[1]
+		case "toolchain":
+			toolchain = fields[1]
+		}
+	}
+	return version, toolchain
+}
+
+// parseRustToolchain returns the channel of a rust-toolchain.toml, or the
+// first line of a legacy rust-toolchain file that only names the channel.
+func parseRustToolchain(content string) (string, string) {
+	if !strings.Contains(content, "[toolchain]") {
+		return parseVersionFile(content)
+	}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok && strings.TrimSpace(key) == "channel" {
+			value, _, _ = strings.Cut(value, "#")
+			return strings.Trim(strings.TrimSpace(value), `"'`), ""
+		}
+	}
+	return "", ""
+}
+
+// parseVersionFile returns the first line of a version file such as .nvmrc
+// or .python-version that is neither blank nor a comment.
+func parseVersionFile(content string) (string, string) {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line, ""
+		}
+	}
+	return "", ""
+}
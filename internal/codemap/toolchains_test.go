@@ -0,0 +1,98 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectToolchains(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                          "module example.com/app\n\ngo 1.22 // minimum\n\ntoolchain go1.22.3\n",
+		".nvmrc":                          "# pinned for CI\nv20.11.0\n",
+		"services/api/go.mod":             "module example.com/app/services/api\n\ngo 1.21\n",
+		"services/api/main.go":            "package main\n",
+		"crates/core/rust-toolchain.toml": "[toolchain]\nchannel = \"1.75.0\" # stable\ncomponents = [\"clippy\"]\n",
+		"crates/core/src/lib.rs":          "pub fn run() {}\n",
+		"tools/.python-version":           "\n3.12.1\n",
+		"tools/gen.py":                    "print('gen')\n",
+		"web/.nvmrc":                      "\n",
+		"web/index.js":                    "export const x = 1;\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	packages := []Package{
+		{RelativePath: "services/api"},
+		{RelativePath: "crates/core/src"},
+		{RelativePath: "tools"},
+		{RelativePath: "web"},
+	}
+	got := detectToolchains(newAuxInputs(tmpDir), packages)
+	want := []ToolchainInfo{
+		{Language: languageGo, Version: "1.22", Toolchain: "go1.22.3", Source: "go.mod"},
+		{Language: "node", Version: "v20.11.0", Source: ".nvmrc"},
+		{Language: languageRust, Version: "1.75.0", Source: "crates/core/rust-toolchain.toml"},
+		{Language: languageGo, Version: "1.21", Source: "services/api/go.mod"},
+		{Language: languagePython, Version: "3.12.1", Source: "tools/.python-version"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("detectToolchains =\n%+v\nwant\n%+v", got, want)
+	}
+
+	cm := &Codemap{Toolchains: want[:1]}
+	content, err := Render(cm)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if row := "| go | 1.22 (toolchain go1.22.3) | go.mod |"; !strings.Contains(content, "## Toolchains") || !strings.Contains(content, row) {
+		t.Fatalf("expected Toolchains section with %q:\n%s", row, content)
+	}
+}
+
+func TestToolchainChangeMakesOutputsStale(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.21\n",
+		".nvmrc":  "18\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	ctx := context.Background()
+	if _, _, err := EnsureUpToDate(ctx, opts); err != nil {
+		t.Fatalf("EnsureUpToDate failed: %v", err)
+	}
+	if stale, err := IsStale(ctx, opts); err != nil || stale {
+		t.Fatalf("IsStale after generating = %v, %v; want false", stale, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, ".nvmrc"), []byte("20\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := IsStale(ctx, opts); err != nil || !stale {
+		t.Fatalf("IsStale after changing .nvmrc = %v, %v; want true", stale, err)
+	}
+	cm, generated, err := EnsureUpToDate(ctx, opts)
+	if err != nil {
+		t.Fatalf("EnsureUpToDate failed: %v", err)
+	}
+	if !generated {
+		t.Fatal("expected EnsureUpToDate to regenerate after .nvmrc changed")
+	}
+	want := []ToolchainInfo{
+		{Language: languageGo, Version: "1.21", Source: "go.mod"},
+		{Language: "node", Version: "20", Source: ".nvmrc"},
+	}
+	if !reflect.DeepEqual(cm.Toolchains, want) {
+		t.Fatalf("Toolchains = %+v, want %+v", cm.Toolchains, want)
+	}
+	if stale, err := IsStale(ctx, opts); err != nil || stale {
+		t.Fatalf("IsStale after regenerating = %v, %v; want false", stale, err)
+	}
+}
@@ -7,37 +7,202 @@ type Codemap struct {
 	ProjectRoot string
 	GeneratedAt time.Time
 	ContentHash string
+	IndexMode   string    // How files were enumerated for ContentHash: "walk", "gitignore", "git-tracked"
+	HashedFiles int       // Number of files covered by ContentHash
+	DirHashes   []DirHash // Per-directory fingerprints; populated only when hashes output is enabled
 	Packages    []Package
 	Concerns    []Concern
+	Warnings    []string        // Notices about incomplete or degraded output.
+	Repos       []RepoInfo      // Populated only for multi-repo aggregates.
+	Services    []ServiceInfo   // Deployable services detected in the tree
+	Binaries    []BinaryInfo    // Executables the project builds or installs
+	Toolchains  []ToolchainInfo // Language versions pinned by go.mod and version files
+	Migrations  []MigrationSet  // Database migration directories
+	Endpoints   []HTTPEndpoint  // HTTP routes registered in Go code or declared by OpenAPI specs
+	Statistics  *Statistics     // Line statistics; populated only with Options.Statistics
+}
+
+// ServiceInfo describes a directory that builds and deploys on its own: it
+// has a manifest, a container or deployment config, and a program entry
+// point.
+type ServiceInfo struct {
+	Name       string   // Directory name (the project directory's for the root)
+	Path       string   // Directory relative to the project root
+	Manifest   string   // e.g. "go.mod" or "package.json"
+	EntryPoint string   // Entry file relative to the project root
+	Config     []string // Dockerfiles and deployment configs in Path; directories end in "/"
+	Owners     []string // From CODEOWNERS
+}
+
+// BinaryInfo is an executable entry point of the project.
+type BinaryInfo struct {
+	Name     string // Command name, e.g. a Go main package's directory or a bin key
+	Language string
+	Entry    string // File relative to the project root, or "module:function" for Python console scripts
+	Source   string // Manifest declaring it, or "package main", "__main__.py" or "shebang"
+}
+
+// ToolchainInfo is a language version a directory of the project expects.
+type ToolchainInfo struct {
+	Language  string // Analyzer language ID, or "node" for .nvmrc
+	Version   string // e.g. "1.22" from a go directive or "v20" from .nvmrc
+	Toolchain string // Go toolchain directive, e.g. "go1.22.3"
+	Source    string // File relative to the project root, e.g. "go.mod"
+}
+
+// MigrationSet is a directory of database schema migrations.
+type MigrationSet struct {
+	Path         string   // Directory relative to the project root, e.g. "db/migrate"
+	Tool         string   // e.g. "sql", "rails", "django", "alembic", "prisma", "diesel" or "goose"
+	Count        int      // Migrations; the up and down files of one migration count once
+	Latest       string   // Newest migration by name, without extension
+	ReferencedBy []string // Packages querying a table the migrations create or naming the directory
+}
+
+// HTTPEndpoint is an HTTP route the project serves.
+type HTTPEndpoint struct {
+	Method      string   // e.g. "GET"; "ANY" when the route accepts every method
+	Path        string   // As registered or declared, e.g. "/users/{id}"
+	Handler     string   // Handler expression, e.g. "h.ListUsers", or the OpenAPI operationId
+	HandlerFile string   // Go file declaring the handler, relative to the project root; "" when not found
+	Sources     []string // Registration as "file:line" and specs declaring the route
+}
+
+// Statistics summarizes the source lines of the packages per language.
+type Statistics struct {
+	Languages    []LanguageStats
+	Total        LanguageStats // Sum over Languages, with Language "total"
+	LargestFiles []FileLines   // Largest non-test files of the project
+}
+
+// LanguageStats are the line statistics of one analyzer language.
+type LanguageStats struct {
+	Language string
+	Packages int
+	LineStats
+}
+
+// LineStats counts the indexed files of packages. Test files are counted
+// even without Options.IncludeTests; comment lines are counted for the
+// built-in languages only.
+type LineStats struct {
+	Files        int // Non-test source files
+	Lines        int // Lines of non-test source files
+	CommentLines int // Comment lines of non-test source files
+	TestFiles    int
+	TestLines    int
+}
+
+// FileLines is the line count of one file.
+type FileLines struct {
+	Path  string // Relative to the project root
+	Lines int
+}
+
+// RepoInfo summarizes one repository in a multi-repo aggregate.
+type RepoInfo struct {
+	Name         string
+	ModulePaths  []string // Go module paths and package.json names published by the repo
+	ContentHash  string
+	PackageCount int
+	DependsOn    []string // Other aggregated repos referenced from go.mod/package.json
 }
 
 // Package represents a logical code package/module with metadata.
 type Package struct {
-	ImportPath    string
-	RelativePath  string // e.g., "internal/supervisor"
-	Purpose       string // Derived from package/file-level comments when available.
-	FileCount     int
-	LineCount     int
-	Files         []File // Only populated for large packages
-	ExportedTypes []TypeInfo
-	Imports       []string // Package-local or internal import references.
-	EntryPoint    string   // Suggested first file to read
+	ID              string // Stable identifier: PackageID(Language, RelativePath)
+	Language        string // Analyzer language ID, e.g. "go"
+	ImportPath      string
+	RelativePath    string // e.g., "internal/supervisor"
+	Purpose         string // Derived from package/file-level comments when available.
+	FileCount       int
+	LineCount       int
+	Files           []File // Only populated for large packages
+	KeyFiles        []File `json:",omitempty"` // Entry and largest files of smaller packages, see Options.JSONMinFiles
+	ExportedTypes   []TypeInfo
+	Imports         []string // Package-local or internal import references; relative ones are relative to the package directory
+	DependsOn       []string // Relative paths of project packages this package imports
+	ExternalImports []string // Third-party and standard library imports
+	SecurityImports []string // ExternalImports matching Options.SecurityImportPatterns
+	ExternalModules []string // Go modules owning ExternalImports, from go.mod requires
+	EntryPoint      string   // Suggested first file to read
+	Companions      []string // Entry point siblings sharing its naming stem
+	CoChanged       []string // Packages that often change in the same git commits
+	Tags            []string // From codemap:tag= markers in doc comments
+	PlatformFiles   int      // Files with a platform suffix such as _linux or .ios
+	Platforms       []string // Distinct platform suffixes, e.g. "darwin", "linux"
+	Owners          []string `json:",omitempty"` // From CODEOWNERS
+	GeneratedFrom   []string `json:",omitempty"` // Proto packages the package's generated code comes from
+	TestFiles       []string `json:",omitempty"` // Test files counted toward the package, relative to the project root, even without Options.IncludeTests
+	TestLineCount   int      `json:",omitempty"` // Lines of TestFiles
+	// Stats are the package's line statistics and largest file. They are
+	// only populated with Options.StatisticsPackages.
+	Stats *PackageStats `json:",omitempty"`
+	// PrivateSymbols lists unexported types and funcs. It is only populated
+	// with Options.IncludeUnexported and only rendered in JSON output.
+	PrivateSymbols []TypeInfo `json:",omitempty"`
+	// Symbols locates each exported type and function by file, for the
+	// CODEMAP.symbols index. Dropped with Options.LowMemory unless
+	// Options.SymbolsOutputPath is set.
+	Symbols []Symbol `json:",omitempty"`
+	// Funcs are the exported functions with their signatures, recorded by
+	// the Go, TypeScript and Rust analyzers. Dropped with Options.LowMemory
+	// unless Options.APISurface is set.
+	Funcs []FuncInfo `json:",omitempty"`
+	// Implementations maps the Go types of the package to the exported
+	// interfaces of the project they satisfy. Not computed with
+	// Options.LowMemory.
+	Implementations []Implementation `json:",omitempty"`
+}
+
+// Implementation lists the interfaces a Go type satisfies. Interfaces of
+// other packages are qualified by their relative path, e.g.
+// "internal/store.Store".
+type Implementation struct {
+	Type       string
+	Interfaces []string
+}
+
+// FuncInfo is an exported function and its signature as declared, without
+// the body, e.g. "func Render(cm *Codemap) (string, error)".
+type FuncInfo struct {
+	Name      string
+	Signature string
+	Comment   string
+	File      string // File name within the package
+}
+
+// PackageStats are the line statistics of one package.
+type PackageStats struct {
+	LineStats
+	LargestFile FileLines // Largest non-test file
 }
 
 // File represents a source file.
 type File struct {
-	Name      string
-	LineCount int
-	Purpose   string   // From file-level comment
-	KeyTypes  []string // Exported types defined in this file
-	KeyFuncs  []string // Exported functions defined in this file
+	Name       string
+	LineCount  int
+	Purpose    string   // From file-level comment
+	KeyTypes   []string // Exported types defined in this file
+	KeyFuncs   []string // Exported functions defined in this file
+	Role       string   // model, handler, storage, test, config, generated, or ""
+	IsTestOnly bool     // From an external test package (package foo_test)
 }
 
 // TypeInfo represents an exported type.
 type TypeInfo struct {
-	Name    string
-	Kind    string // struct, interface, alias, func
-	Comment string
+	Name       string
+	Kind       string // struct, interface, alias, func
+	Comment    string
+	IsTestOnly bool // Declared in an external test package (package foo_test)
+	IsPrivate  bool `json:",omitempty"` // Unexported or underscore-private; see Package.PrivateSymbols
+	// Methods are the Go method signatures of the type without parameter
+	// names, e.g. "Save(context.Context, string) error": an interface's
+	// methods, or the methods declared with the type as receiver.
+	Methods []string `json:",omitempty"`
+	// Embeds are the Go types embedded in an interface or struct, e.g.
+	// "Reader" or "io.Closer".
+	Embeds []string `json:",omitempty"`
 }
 
 // Concern represents a cross-cutting concern grouping files.
@@ -46,42 +211,121 @@ type Concern struct {
 	Patterns   []string
 	Files      []string
 	TotalFiles int
+	Languages  map[string]int // Matched files per language ID
 	Note       string
+	// Paths lists matched files for CODEMAP.concerns, up to the concerns
+	// output limit. Empty unless that output is enabled.
+	Paths []string `json:",omitempty"`
 }
 
 // ConcernDef defines a concern pattern to match.
 type ConcernDef struct {
-	Name     string
-	Patterns []string
+	Name         string
+	Patterns     []string
+	ExampleLimit int    // Example files listed for this concern (0 = Options.ConcernExampleLimit)
+	Note         string // Shown next to the concern in CODEMAP.md
 }
 
 // Options configures codemap generation.
 type Options struct {
-	ProjectRoot         string
-	OutputPath          string // Default: "CODEMAP.md"
-	PathsOutputPath     string // Default: "CODEMAP.paths"
-	StatePath           string // Default: ".codemap.state.json"
-	LargePackageFiles   int    // Threshold for detailed file listing
-	IncludeTests        bool
-	Concerns            []ConcernDef
-	ConcernExampleLimit int // Max files stored per concern (0 = none)
-	DisablePaths        bool
-	Verbose             bool
+	ProjectRoot           string
+	OutputPath            string   // Default: "CODEMAP.md"
+	MarkdownTemplate      string   // text/template file replacing the CODEMAP.md layout, relative to ProjectRoot ("" = built-in)
+	PathsOutputPath       string   // Default: "CODEMAP.paths"
+	HashesOutputPath      string   // Per-directory content hashes, e.g. "CODEMAP.hashes" (empty = disabled)
+	DOTOutputPath         string   // Package dependency graph as Graphviz DOT, e.g. "CODEMAP.dot" (empty = disabled)
+	MermaidOutputPath     string   // Package dependency graph as a Mermaid flowchart, e.g. "CODEMAP.mmd" (empty = disabled)
+	SymbolsOutputPath     string   // Flat exported symbol index, e.g. "CODEMAP.symbols" (empty = disabled)
+	ConcernsOutputPath    string   // Concern to matched file TSV, e.g. "CODEMAP.concerns" (empty = disabled)
+	JSONOutputPath        string   // Machine-readable model, e.g. "CODEMAP.json" (empty = disabled)
+	SplitOutputDir        string   // One Markdown page per package, e.g. "docs/codemap", linked from CODEMAP.md (empty = disabled)
+	AgentsOutputPath      string   // Agent instructions fragment, e.g. "CODEMAP.agents.md" (empty = disabled)
+	AgentsInjectPaths     []string // Files such as AGENTS.md that get the fragment between managed markers
+	StatePath             string   // Default: ".codemap.state.json"
+	BranchState           bool     // Keep separate state and analysis caches per git branch
+	SocketPath            string   // Daemon control socket. Default: ".codemap.sock"
+	LargePackageFiles     int      // Threshold for detailed file listing
+	JSONMinFiles          int      // Files beyond the entry file kept for JSON output of smaller packages (0 = none)
+	DetailBudget          int      // Approximate tokens of file listings, spent on the most imported and changed packages (0 = list large packages)
+	MaxOutputTokens       int      // Approximate tokens each of CODEMAP.md and CODEMAP.paths may use before detail is dropped (0 = unlimited)
+	IncludeTests          bool
+	IncludeUnexported     bool     // Record unexported/underscore-private symbols in Package.PrivateSymbols (JSON output only)
+	Statistics            bool     // Count source, comment and test lines per language into Codemap.Statistics
+	StatisticsPackages    bool     // Also break the statistics down per package into Package.Stats (implies Statistics)
+	LowMemory             bool     // Analyze one package at a time and keep only what CODEMAP.md and CODEMAP.paths render
+	GitIgnore             bool     // Skip files ignored by .gitignore, .git/info/exclude and core.excludesFile
+	GitTracked            bool     // Enumerate files with git ls-files; falls back to walking without git
+	ParallelWalk          int      // Goroutines reading directories ahead of the index walk (0 = sequential walk)
+	Since                 string   // Git ref; when the state was written at its commit, only files git reports changed are re-read
+	ExcludePatterns       []string // Extra files and directories to skip, in .gitignore syntax
+	IncludePatterns       []string // When set, index only matching files or files in matching directories
+	Languages             []string // When set, index and analyze only these languages (IDs or aliases such as ts)
+	NestedCodemaps        bool     // Summarize subdirectories with their own .codemap.yaml or CODEMAP.md as one package
+	Concerns              []ConcernDef
+	ConcernExampleLimit   int // Max files stored per concern (0 = none)
+	ConcernPathLimit      int // Max files per concern in CODEMAP.concerns (0 = 50)
+	DisablePaths          bool
+	PathsMini             bool // Strip purposes and comments from CODEMAP.paths
+	MarkdownPurposeLength int  // Max purpose runes in CODEMAP.md (0 = 60)
+	MarkdownTOC           bool // Start CODEMAP.md with a table of contents
+	APISurface            bool // Add an API Surface section listing each package's function signatures
+	PathsPurposeLength    int  // Max purpose runes in CODEMAP.paths (0 = 80)
+	DiffBudgetLines       int  // Fail when CODEMAP.md would change by more than this many lines (0 = unlimited)
+	DiffBudgetCoarsen     bool // Over the diff budget, drop file listings before failing
+	VerifyOutputs         bool // Fail when the written outputs disagree on hash or packages
+	Verbose               bool
+	StrictAnalyzers       bool            // Fail the run when any language analyzer errors
+	CoChange              bool            // Mine git history for packages that change together
+	CoChangeCommits       int             // Commits scanned for co-change hints (0 = 500)
+	MaxCPUSeconds         float64         // Soft per-run CPU time limit (0 = unlimited)
+	MaxRSS                int64           // Soft peak RSS limit in bytes (0 = unlimited)
+	StalenessGrace        time.Duration   // Stale outputs younger than this pass checks (0 = none)
+	HashScanLines         int             // Leading output lines searched for the codemap-hash header (0 = 20)
+	AutoRefreshInterval   time.Duration   // Daemon polls for changes this often and regenerates on its own (0 = on request only)
+	RefreshQuiescence     time.Duration   // Automatic regeneration waits until no change was seen for this long (0 = 2s)
+	RefreshMinInterval    time.Duration   // Minimum time between automatic regenerations
+	Instrumentation       Instrumentation // Optional analysis lifecycle hooks
+	// PurposeExtractors overrides file purpose extraction per language ID
+	// (e.g. "typescript"). Package purposes follow from file purposes.
+	PurposeExtractors map[string]PurposeExtractor
+	// SecurityImportPatterns marks packages importing matching modules as
+	// security-sensitive. A trailing "*" matches any suffix.
+	SecurityImportPatterns []string
+	// FileRoles classifies files in detailed listings; the first match wins.
+	FileRoles []FileRoleDef
+	// ExternalAnalyzers adds languages analyzed by external commands.
+	ExternalAnalyzers []ExternalAnalyzerDef
+	// CustomLanguages adds languages whose files are only listed, grouped by
+	// directory with purposes from leading comments.
+	CustomLanguages []CustomLanguageDef
+	// ShellGrouping groups shell scripts into packages per directory
+	// (ShellGroupDir, the default) or per top-level directory (ShellGroupTop).
+	ShellGrouping string
+	// ShellPrefixes groups shell scripts whose file names match a prefix such
+	// as "deploy-" or a pattern such as "db-*" into one package per pattern.
+	ShellPrefixes []string
+
+	// variantOutputs lists the generated paths of every variant in a
+	// GenerateVariants run, kept out of the shared index.
+	variantOutputs []string
 }
 
 // DefaultOptions returns sensible defaults.
 func DefaultOptions() Options {
 	return Options{
-		ProjectRoot:         ".",
-		OutputPath:          "CODEMAP.md",
-		PathsOutputPath:     "CODEMAP.paths",
-		StatePath:           ".codemap.state.json",
-		LargePackageFiles:   10,
-		IncludeTests:        false,
-		Concerns:            defaultConcerns,
-		ConcernExampleLimit: 0,
-		DisablePaths:        false,
-		Verbose:             false,
+		ProjectRoot:            ".",
+		OutputPath:             "CODEMAP.md",
+		PathsOutputPath:        "CODEMAP.paths",
+		StatePath:              ".codemap.state.json",
+		SocketPath:             ".codemap.sock",
+		LargePackageFiles:      10,
+		IncludeTests:           false,
+		Concerns:               defaultConcerns,
+		SecurityImportPatterns: defaultSecurityImportPatterns,
+		FileRoles:              defaultFileRoles,
+		ConcernExampleLimit:    0,
+		DisablePaths:           false,
+		Verbose:                false,
 	}
 }
 
@@ -103,6 +347,16 @@ var defaultConcerns = []ConcernDef{
 			"**/*error*.tsx",
 			"**/*error*.mts",
 			"**/*error*.cts",
+			"**/*error*.js",
+			"**/*error*.jsx",
+			"**/*error*.mjs",
+			"**/*error*.cjs",
+			"**/*error*.c",
+			"**/*error*.cc",
+			"**/*error*.cpp",
+			"**/*error*.h",
+			"**/*error*.hpp",
+			"**/*error*.rb",
 		},
 	},
 	{
@@ -143,6 +397,30 @@ var defaultConcerns = []ConcernDef{
 			"__tests__/**/*.tsx",
 			"__tests__/**/*.mts",
 			"__tests__/**/*.cts",
+			"**/*.test.js",
+			"**/*.spec.js",
+			"**/*.test.jsx",
+			"**/*.spec.jsx",
+			"**/*.test.mjs",
+			"**/*.spec.mjs",
+			"**/*.test.cjs",
+			"**/*.spec.cjs",
+			"__tests__/**/*.js",
+			"__tests__/**/*.jsx",
+			"__tests__/**/*.mjs",
+			"__tests__/**/*.cjs",
+			"**/*_test.c",
+			"**/*_test.cc",
+			"**/*_test.cpp",
+			"**/*_unittest.cc",
+			"**/*_unittest.cpp",
+			"tests/**/*.c",
+			"tests/**/*.cc",
+			"tests/**/*.cpp",
+			"spec/**/*.rb",
+			"test/**/*.rb",
+			"**/*_spec.rb",
+			"**/*_test.rb",
 		},
 	},
 	{
@@ -164,6 +442,11 @@ var defaultConcerns = []ConcernDef{
 			"**/cli*.tsx",
 			"**/cli*.mts",
 			"**/cli*.cts",
+			"**/cli*.js",
+			"**/cli*.mjs",
+			"**/cli*.cjs",
+			"bin/**/*.rb",
+			"**/cli*.rb",
 		},
 	},
 	{
@@ -183,6 +466,9 @@ var defaultConcerns = []ConcernDef{
 			"**/*config*.tsx",
 			"**/*config*.mts",
 			"**/*config*.cts",
+			"**/*config*.js",
+			"**/*config*.mjs",
+			"**/*config*.cjs",
 		},
 	},
 }
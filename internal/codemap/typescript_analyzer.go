@@ -8,12 +8,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 
 	sitter "github.com/tree-sitter/go-tree-sitter"
 )
 
+var typeScriptModuleSpecifierPattern = regexp.MustCompile(`(?:\bfrom|\bimport|\brequire\s*\(|\bimport\s*\()\s*['"]([^'"\n]+)['"]`)
+
 // TypeScriptAnalyzer is the analyzer implementation for TypeScript projects.
 type TypeScriptAnalyzer struct{}
 
@@ -27,13 +30,19 @@ func (TypeScriptAnalyzer) Analyze(ctx context.Context, in AnalysisInput) (*Codem
 }
 
 func analyzeTypeScriptWithIndex(ctx context.Context, root string, idx *FileIndex, opts Options, prevState, nextState *CodemapState) (*Codemap, error) {
+	return analyzeScriptLanguageWithIndex(ctx, languageTypeScript, root, idx, opts, prevState, nextState)
+}
+
+// analyzeScriptLanguageWithIndex analyzes the TypeScript or JavaScript files
+// of idx, grouped into packages by their nearest package.json.
+func analyzeScriptLanguageWithIndex(ctx context.Context, language, root string, idx *FileIndex, opts Options, prevState, nextState *CodemapState) (*Codemap, error) {
 	entryByRel := stateEntryByRelPath(nextState)
-	plans, err := buildTypeScriptPackagePlans(root, idx, opts.IncludeTests, entryByRel)
+	plans, err := buildTypeScriptPackagePlans(root, idx, language, opts.IncludeTests, entryByRel)
 	if err != nil {
 		return nil, err
 	}
 
-	const modulePath = "typescript"
+	modulePath := language
 	cachedByRel := cachedPackagesByPath(prevState, opts, modulePath)
 
 	packageResults := make([]*Package, len(plans))
@@ -43,20 +52,22 @@ func analyzeTypeScriptWithIndex(ctx context.Context, root string, idx *FileIndex
 		if cached, ok := cachedByRel[plan.RelativePath]; ok && plan.Fingerprint != "" && cached.Fingerprint == plan.Fingerprint {
 			pkg := cached.Package
 			packageResults[i] = &pkg
+			recordCacheHit(ctx, opts, language, plan.RelativePath)
 			continue
 		}
 		jobs = append(jobs, analysisJob{
-			index: i,
-			dir:   plan.DirAbsPath,
+			index:   i,
+			dir:     plan.DirAbsPath,
+			relPath: plan.RelativePath,
 		})
 	}
 
-	if err := analyzePackagePlansParallel(ctx, opts, jobs, packageResults, func(job analysisJob) (*Package, error) {
+	if err := analyzePackagePlansParallel(ctx, opts, language, jobs, packageResults, func(ctx context.Context, job analysisJob) (*Package, error) {
 		plan := plans[job.index]
 		pkgName := readTypeScriptPackageName(plan.DirAbsPath, plan.RelativePath)
-		pkg, err := analyzeTypeScriptPackage(root, plan, pkgName, opts)
+		pkg, err := analyzeTypeScriptPackage(ctx, root, plan, pkgName, language, opts)
 		if err != nil {
-			return nil, fmt.Errorf("analyze typescript package %s: %w", plan.RelativePath, err)
+			return nil, fmt.Errorf("analyze %s package %s: %w", language, plan.RelativePath, err)
 		}
 		return pkg, nil
 	}); err != nil {
@@ -70,21 +81,15 @@ func analyzeTypeScriptWithIndex(ctx context.Context, root string, idx *FileIndex
 		}
 	}
 
-	concerns, err := buildConcerns(idx, opts.Concerns, opts.ConcernExampleLimit)
-	if err != nil {
-		return nil, fmt.Errorf("build concerns: %w", err)
-	}
-
-	updateAnalysisCache(nextState, opts, modulePath, plans, packageResults)
+	updateAnalysisCache(nextState, opts, modulePath, language, plans, packageResults)
 
 	return &Codemap{
 		ProjectRoot: root,
 		Packages:    packages,
-		Concerns:    concerns,
 	}, nil
 }
 
-func buildTypeScriptPackagePlans(root string, idx *FileIndex, includeTests bool, entriesByRel map[string]StateEntry) ([]packagePlan, error) {
+func buildTypeScriptPackagePlans(root string, idx *FileIndex, language string, includeTests bool, entriesByRel map[string]StateEntry) ([]packagePlan, error) {
 	plansByRel := make(map[string]*packagePlan)
 	rootAbs, err := filepath.Abs(root)
 	if err != nil {
@@ -100,7 +105,7 @@ func buildTypeScriptPackagePlans(root string, idx *FileIndex, includeTests bool,
 	})
 
 	for _, rec := range idx.Files {
-		if rec.Language != languageTypeScript {
+		if rec.Language != language {
 			continue
 		}
 		if !includeTests && isTypeScriptTestPath(rec.RelPath, rec.IsTest) {
@@ -186,7 +191,7 @@ func buildTypeScriptPackagePlans(root string, idx *FileIndex, includeTests bool,
 	return plans, nil
 }
 
-func analyzeTypeScriptPackage(root string, plan packagePlan, packageName string, opts Options) (*Package, error) {
+func analyzeTypeScriptPackage(ctx context.Context, root string, plan packagePlan, packageName, language string, opts Options) (*Package, error) {
 	if len(plan.FileRelPaths) == 0 {
 		return nil, nil
 	}
@@ -196,20 +201,19 @@ func analyzeTypeScriptPackage(root string, plan packagePlan, packageName string,
 
 	files := make([]File, 0, len(fileRelPaths))
 	allTypes := make([]TypeInfo, 0, len(fileRelPaths))
+	var funcs []FuncInfo
 	importsSeen := make(map[string]struct{}, len(fileRelPaths))
+	externalSeen := make(map[string]struct{})
 	totalLines := 0
 	purpose := ""
 	entryPoint := ""
 	entryScore := -1
+	var tags []string
 	var tsParser *sitter.Parser
 	var tsxParser *sitter.Parser
 	defer func() {
-		if tsParser != nil {
-			tsParser.Close()
-		}
-		if tsxParser != nil {
-			tsxParser.Close()
-		}
+		typeScriptParsers.put(tsParser)
+		typeScriptTSXParsers.put(tsxParser)
 	}()
 
 	for _, relPath := range fileRelPaths {
@@ -220,6 +224,7 @@ func analyzeTypeScriptPackage(root string, plan packagePlan, packageName string,
 		}
 
 		lineCount := lineCountBytesTS(content)
+		recordFileParsed(ctx, relPath, lineCount)
 		totalLines += lineCount
 
 		withinPackage := relPath
@@ -230,28 +235,46 @@ func analyzeTypeScriptPackage(root string, plan packagePlan, packageName string,
 			}
 		}
 
-		filePurpose := extractTypeScriptFilePurpose(content)
+		filePurpose := extractFilePurpose(opts, language, relPath, content, extractTypeScriptFilePurpose)
 		if purpose == "" && filePurpose != "" {
 			purpose = filePurpose
 		}
+		tags = mergeCodemapTags(tags, extractCodemapTags(content))
 
+		// The TSX grammar also accepts plain JavaScript and JSX.
 		parser := tsParser
-		if isTypeScriptTSXPath(withinPackage) {
+		if language == languageJavaScript || isTypeScriptTSXPath(withinPackage) {
 			if tsxParser == nil {
-				tsxParser, _ = newTypeScriptParser(true)
+				tsxParser = typeScriptTSXParsers.get()
 			}
 			parser = tsxParser
 		} else {
 			if tsParser == nil {
-				tsParser, _ = newTypeScriptParser(false)
+				tsParser = typeScriptParsers.get()
 			}
 			parser = tsParser
 		}
 
-		typeInfos, keyTypes, keyFuncs, imports := parseTypeScriptFileSymbolsWithParser(content, parser)
+		typeInfos, keyTypes, keyFuncs, imports, exportDoc, fileFuncs := parseTypeScriptFileSymbolsWithParser(content, parser)
+		if filePurpose == "" && exportDoc != "" {
+			filePurpose = exportDoc
+			if purpose == "" {
+				purpose = filePurpose
+			}
+		}
 		allTypes = append(allTypes, typeInfos...)
+		for _, fn := range fileFuncs {
+			fn.File = withinPackage
+			funcs = append(funcs, fn)
+		}
+		if language == languageJavaScript {
+			imports = append(imports, scanJavaScriptRelativeRequires(content)...)
+		}
 		for _, imp := range imports {
-			importsSeen[imp] = struct{}{}
+			importsSeen[packageRelativeImport(withinPackage, imp)] = struct{}{}
+		}
+		for _, spec := range scanTypeScriptExternalImports(content) {
+			externalSeen[spec] = struct{}{}
 		}
 
 		files = append(files, File{
@@ -273,7 +296,11 @@ func analyzeTypeScriptPackage(root string, plan packagePlan, packageName string,
 		entryPoint = files[0].Name
 	}
 	if purpose == "" && packageName != "" {
-		purpose = "TypeScript package " + packageName
+		if language == languageJavaScript {
+			purpose = "JavaScript package " + packageName
+		} else {
+			purpose = "TypeScript package " + packageName
+		}
 	}
 
 	internalImports := make([]string, 0, len(importsSeen))
@@ -285,21 +312,23 @@ func analyzeTypeScriptPackage(root string, plan packagePlan, packageName string,
 		return allTypes[i].Name < allTypes[j].Name
 	})
 
-	var detailedFiles []File
-	if len(files) >= opts.LargePackageFiles {
-		detailedFiles = files
-	}
+	detailedFiles, keyFiles := packageFileDetails(files, entryPoint, opts)
 
 	return &Package{
-		ImportPath:    packageName,
-		RelativePath:  plan.RelativePath,
-		Purpose:       purpose,
-		FileCount:     len(files),
-		LineCount:     totalLines,
-		Files:         detailedFiles,
-		ExportedTypes: allTypes,
-		Imports:       internalImports,
-		EntryPoint:    entryPoint,
+		ImportPath:      packageName,
+		RelativePath:    plan.RelativePath,
+		Purpose:         purpose,
+		FileCount:       len(files),
+		LineCount:       totalLines,
+		Files:           detailedFiles,
+		KeyFiles:        keyFiles,
+		ExportedTypes:   allTypes,
+		Symbols:         packageSymbols(files, allTypes),
+		Funcs:           sortedFuncs(funcs),
+		Imports:         internalImports,
+		ExternalImports: sortedImportSet(externalSeen),
+		EntryPoint:      entryPoint,
+		Tags:            tags,
 	}, nil
 }
 
@@ -368,33 +397,38 @@ func isTypeScriptTestPath(relPath string, fileMatchTest bool) bool {
 }
 
 func parseTypeScriptFileSymbols(content []byte, filePath string) ([]TypeInfo, []string, []string, []string) {
-	parser, err := newTypeScriptParser(isTypeScriptTSXPath(filePath))
-	if err != nil {
+	pool := typeScriptParserPool(isTypeScriptTSXPath(filePath))
+	parser := pool.get()
+	if parser == nil {
 		return nil, nil, nil, nil
 	}
-	defer parser.Close()
+	defer pool.put(parser)
 
-	return parseTypeScriptFileSymbolsWithParser(content, parser)
+	typeInfos, keyTypes, keyFuncs, imports, _, _ := parseTypeScriptFileSymbolsWithParser(content, parser)
+	return typeInfos, keyTypes, keyFuncs, imports
 }
 
-func parseTypeScriptFileSymbolsWithParser(content []byte, parser *sitter.Parser) ([]TypeInfo, []string, []string, []string) {
-	typeInfos := make([]TypeInfo, 0)
-	keyTypes := make([]string, 0)
-	keyFuncs := make([]string, 0)
-	imports := make([]string, 0)
+// parseTypeScriptFileSymbolsWithParser also returns exportDoc, the first
+// sentence of the first JSDoc block attached to an export, which stands in
+// for a missing file purpose, and the signatures of named exported functions.
+func parseTypeScriptFileSymbolsWithParser(content []byte, parser *sitter.Parser) (typeInfos []TypeInfo, keyTypes, keyFuncs, imports []string, exportDoc string, funcs []FuncInfo) {
+	typeInfos = make([]TypeInfo, 0)
+	keyTypes = make([]string, 0)
+	keyFuncs = make([]string, 0)
+	imports = make([]string, 0)
 	if parser == nil {
-		return typeInfos, keyTypes, keyFuncs, imports
+		return typeInfos, keyTypes, keyFuncs, imports, "", nil
 	}
 
 	tree := parser.Parse(content, nil)
 	if tree == nil {
-		return typeInfos, keyTypes, keyFuncs, imports
+		return typeInfos, keyTypes, keyFuncs, imports, "", nil
 	}
 	defer tree.Close()
 
 	root := tree.RootNode()
 	if root == nil {
-		return typeInfos, keyTypes, keyFuncs, imports
+		return typeInfos, keyTypes, keyFuncs, imports, "", nil
 	}
 
 	for i := uint(0); i < root.NamedChildCount(); i++ {
@@ -409,42 +443,57 @@ func parseTypeScriptFileSymbolsWithParser(content []byte, parser *sitter.Parser)
 				imports = append(imports, target)
 			}
 		case "export_statement":
-			exportTypes, exportKeyTypes, exportKeyFuncs := parseTypeScriptExportStatement(stmt, content)
+			doc := typeScriptJSDoc(stmt, content)
+			exportTypes, exportKeyTypes, exportKeyFuncs, exportFuncs := parseTypeScriptExportStatement(stmt, content, doc)
+			if exportDoc == "" && len(exportTypes)+len(exportKeyFuncs) > 0 {
+				exportDoc = doc
+			}
 			typeInfos = append(typeInfos, exportTypes...)
 			keyTypes = append(keyTypes, exportKeyTypes...)
 			keyFuncs = append(keyFuncs, exportKeyFuncs...)
+			funcs = append(funcs, exportFuncs...)
 			if target := typeScriptRelativeSource(stmt, content); target != "" {
 				imports = append(imports, target)
 			}
+		case "expression_statement":
+			exportTypes, exportKeyTypes, exportKeyFuncs := parseCommonJSExports(stmt, content)
+			typeInfos = append(typeInfos, exportTypes...)
+			keyTypes = append(keyTypes, exportKeyTypes...)
+			keyFuncs = append(keyFuncs, exportKeyFuncs...)
 		}
 	}
 
-	return typeInfos, keyTypes, keyFuncs, imports
+	return typeInfos, keyTypes, keyFuncs, imports, exportDoc, funcs
 }
 
-func parseTypeScriptExportStatement(stmt *sitter.Node, content []byte) ([]TypeInfo, []string, []string) {
+// parseTypeScriptExportStatement records doc as the comment of exported types
+// and functions.
+func parseTypeScriptExportStatement(stmt *sitter.Node, content []byte, doc string) ([]TypeInfo, []string, []string, []FuncInfo) {
 	typeInfos := make([]TypeInfo, 0)
 	keyTypes := make([]string, 0)
 	keyFuncs := make([]string, 0)
+	var funcs []FuncInfo
 
 	declaration := stmt.ChildByFieldName("declaration")
 	if declaration != nil {
 		switch declaration.Kind() {
 		case "class_declaration":
-			typeScriptAppendTypeInfo(declaration, content, "class", &typeInfos, &keyTypes)
+			typeScriptAppendTypeInfo(declaration, content, "class", doc, &typeInfos, &keyTypes)
 		case "interface_declaration":
-			typeScriptAppendTypeInfo(declaration, content, "interface", &typeInfos, &keyTypes)
+			typeScriptAppendTypeInfo(declaration, content, "interface", doc, &typeInfos, &keyTypes)
 		case "type_alias_declaration":
-			typeScriptAppendTypeInfo(declaration, content, "type", &typeInfos, &keyTypes)
+			typeScriptAppendTypeInfo(declaration, content, "type", doc, &typeInfos, &keyTypes)
 		case "enum_declaration":
-			typeScriptAppendTypeInfo(declaration, content, "enum", &typeInfos, &keyTypes)
+			typeScriptAppendTypeInfo(declaration, content, "enum", doc, &typeInfos, &keyTypes)
 		case "function_declaration":
 			name := typeScriptDeclarationName(declaration, content)
 			if name != "" {
 				keyFuncs = append(keyFuncs, name)
+				funcs = append(funcs, FuncInfo{Name: name, Signature: declarationSignature(declaration, content), Comment: doc})
 			}
 		case "lexical_declaration", "variable_declaration":
 			keyFuncs = append(keyFuncs, typeScriptVariableDeclaratorNames(declaration, content)...)
+			funcs = append(funcs, typeScriptFunctionVariables(declaration, content, doc)...)
 		}
 	}
 
@@ -454,7 +503,7 @@ func parseTypeScriptExportStatement(stmt *sitter.Node, content []byte) ([]TypeIn
 		case "function_expression", "arrow_function":
 			keyFuncs = append(keyFuncs, "default")
 		case "class":
-			typeInfos = append(typeInfos, TypeInfo{Name: "default", Kind: "class"})
+			typeInfos = append(typeInfos, TypeInfo{Name: "default", Kind: "class", Comment: doc})
 			keyTypes = append(keyTypes, "default")
 		}
 	}
@@ -475,18 +524,64 @@ func parseTypeScriptExportStatement(stmt *sitter.Node, content []byte) ([]TypeIn
 		}
 	}
 
-	return typeInfos, keyTypes, keyFuncs
+	return typeInfos, keyTypes, keyFuncs, funcs
 }
 
-func typeScriptAppendTypeInfo(node *sitter.Node, content []byte, kind string, typeInfos *[]TypeInfo, keyTypes *[]string) {
+func typeScriptAppendTypeInfo(node *sitter.Node, content []byte, kind, comment string, typeInfos *[]TypeInfo, keyTypes *[]string) {
 	name := typeScriptDeclarationName(node, content)
 	if name == "" {
 		return
 	}
-	*typeInfos = append(*typeInfos, TypeInfo{Name: name, Kind: kind})
+	*typeInfos = append(*typeInfos, TypeInfo{Name: name, Kind: kind, Comment: comment})
 	*keyTypes = append(*keyTypes, name)
 }
 
+// typeScriptJSDoc returns the first sentence of the /** ... */ block ending
+// on the line before stmt (or on its first line). Block tags such as @param
+// are skipped; @file, @fileoverview and @description text is kept.
+func typeScriptJSDoc(stmt *sitter.Node, content []byte) string {
+	prev := stmt.PrevNamedSibling()
+	if prev == nil || prev.Kind() != "comment" || prev.EndPosition().Row+1 < stmt.StartPosition().Row {
+		return ""
+	}
+	text := nodeText(prev, content)
+	if !strings.HasPrefix(text, "/**") || strings.HasPrefix(text, "/**/") {
+		return ""
+	}
+	return extractFirstSentence(cleanJSDoc(text))
+}
+
+// jsDocDescription returns the description text of a JSDoc line: the text of
+// an @file, @fileoverview or @description tag, "" for other tags, or line.
+func jsDocDescription(line string) string {
+	if !strings.HasPrefix(line, "@") {
+		return line
+	}
+	tag, rest, _ := strings.Cut(line, " ")
+	switch tag {
+	case "@file", "@fileoverview", "@description":
+		return strings.TrimSpace(rest)
+	}
+	return ""
+}
+
+// cleanJSDoc strips the comment markers and block tags from a JSDoc block.
+func cleanJSDoc(text string) string {
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "/**"), "*/")
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "*"))
+		if strings.HasPrefix(line, "@") {
+			if line = jsDocDescription(line); line == "" {
+				// Other block tags end the description.
+				return strings.Join(lines, "\n")
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
 func typeScriptDeclarationName(node *sitter.Node, content []byte) string {
 	if node == nil {
 		return ""
@@ -498,6 +593,32 @@ func typeScriptDeclarationName(node *sitter.Node, content []byte) string {
 	return strings.TrimSpace(nodeText(nameNode, content))
 }
 
+// typeScriptFunctionVariables returns the functions and arrow functions
+// assigned to the variables of declaration.
+func typeScriptFunctionVariables(declaration *sitter.Node, content []byte, doc string) []FuncInfo {
+	keyword := ""
+	if first := declaration.Child(0); first != nil && !first.IsNamed() {
+		keyword = nodeText(first, content) + " "
+	}
+	var funcs []FuncInfo
+	for i := uint(0); i < declaration.NamedChildCount(); i++ {
+		child := declaration.NamedChild(i)
+		if child == nil || child.Kind() != "variable_declarator" {
+			continue
+		}
+		name, value := child.ChildByFieldName("name"), child.ChildByFieldName("value")
+		if name == nil || name.Kind() != "identifier" || value == nil {
+			continue
+		}
+		if value.Kind() != "arrow_function" && value.Kind() != "function_expression" {
+			continue
+		}
+		signature := strings.TrimSpace(nodeText(name, content)) + " = " + declarationSignature(value, content)
+		funcs = append(funcs, FuncInfo{Name: nodeText(name, content), Signature: keyword + signature, Comment: doc})
+	}
+	return funcs
+}
+
 func typeScriptVariableDeclaratorNames(declaration *sitter.Node, content []byte) []string {
 	if declaration == nil {
 		return nil
@@ -587,6 +708,20 @@ func typeScriptRelativeSource(node *sitter.Node, content []byte) string {
 	return ""
 }
 
+// scanTypeScriptExternalImports returns bare module specifiers from import,
+// export-from, require, and dynamic import statements.
+func scanTypeScriptExternalImports(content []byte) []string {
+	var specs []string
+	for _, match := range typeScriptModuleSpecifierPattern.FindAllSubmatch(content, -1) {
+		spec := string(match[1])
+		if strings.HasPrefix(spec, ".") || strings.HasPrefix(spec, "/") {
+			continue
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
 func extractTypeScriptFilePurpose(content []byte) string {
 	scanner := bufio.NewScanner(bytes.NewReader(content))
 	inBlockComment := false
@@ -604,7 +739,7 @@ func extractTypeScriptFilePurpose(content []byte) string {
 			line = strings.TrimSpace(strings.TrimPrefix(line, "/*"))
 			line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
 			line = strings.TrimSuffix(line, "*/")
-			line = strings.TrimSpace(line)
+			line = jsDocDescription(strings.TrimSpace(line))
 			if line != "" {
 				return extractFirstSentence(line)
 			}
@@ -614,7 +749,7 @@ func extractTypeScriptFilePurpose(content []byte) string {
 			}
 			line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
 			line = strings.TrimSuffix(line, "*/")
-			line = strings.TrimSpace(line)
+			line = jsDocDescription(strings.TrimSpace(line))
 			if line != "" {
 				return extractFirstSentence(line)
 			}
@@ -632,11 +767,13 @@ func scoreTypeScriptEntryPoint(relPath string, keyTypes, keyFuncs []string) int
 	lower := strings.ToLower(relPath)
 
 	switch lower {
-	case "src/index.ts", "src/index.tsx", "src/index.mts", "src/index.cts":
+	case "src/index.ts", "src/index.tsx", "src/index.mts", "src/index.cts",
+		"src/index.js", "src/index.jsx", "src/index.mjs", "src/index.cjs":
 		score += 120
-	case "index.ts", "index.tsx", "index.mts", "index.cts":
+	case "index.ts", "index.tsx", "index.mts", "index.cts",
+		"index.js", "index.jsx", "index.mjs", "index.cjs":
 		score += 110
-	case "src/main.ts", "src/main.tsx":
+	case "src/main.ts", "src/main.tsx", "src/main.js", "src/main.jsx":
 		score += 100
 	}
 
@@ -84,8 +84,8 @@ export function start() {}
 	if !strings.Contains(pkg.Purpose, "TypeScript app entry") {
 		t.Fatalf("expected purpose from comment, got %q", pkg.Purpose)
 	}
-	if len(pkg.Imports) == 0 || pkg.Imports[0] != "./foo" {
-		t.Fatalf("expected relative import ./foo, got %v", pkg.Imports)
+	if len(pkg.Imports) == 0 || pkg.Imports[0] != "./src/foo" {
+		t.Fatalf("expected relative import ./src/foo, got %v", pkg.Imports)
 	}
 
 	paths := RenderPaths(&Codemap{
@@ -257,6 +257,55 @@ export * as ns from "./namespace";
 	}
 }
 
+func TestParseTypeScriptFileSymbolsExtractsJSDoc(t *testing.T) {
+	content := []byte(`import { Base } from "./base";
+
+/**
+ * Creates a session store.
+ * @param ttl seconds to keep sessions
+ */
+export function createStore(ttl: number) {}
+
+/** Caches sessions in memory. Evicts on expiry. */
+@Injectable()
+export class SessionCache extends Base {}
+
+/** Detached comment. */
+
+export interface Options {}
+
+// Line comments are not JSDoc.
+export type ID = string;
+
+/**
+ * @description Retry policies.
+ */
+export enum Retry { Never }
+`)
+
+	parser, err := newTypeScriptParser(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	types, _, _, _, exportDoc, _ := parseTypeScriptFileSymbolsWithParser(content, parser)
+	want := []TypeInfo{
+		{Name: "SessionCache", Kind: "class", Comment: "Caches sessions in memory."},
+		{Name: "Options", Kind: "interface"},
+		{Name: "ID", Kind: "type"},
+		{Name: "Retry", Kind: "enum", Comment: "Retry policies."},
+	}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("unexpected type infos:\n got %+v\nwant %+v", types, want)
+	}
+	if exportDoc != "Creates a session store." {
+		t.Fatalf("unexpected export doc: %q", exportDoc)
+	}
+	if purpose := extractTypeScriptFilePurpose([]byte("/**\n * @file Session helpers.\n */\nexport {};\n")); purpose != "Session helpers." {
+		t.Fatalf("unexpected @file purpose: %q", purpose)
+	}
+}
+
 func TestParseTypeScriptFileSymbolsUsesTSXGrammarForTSXFiles(t *testing.T) {
 	content := []byte(`
 export default () => <div />;
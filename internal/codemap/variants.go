@@ -0,0 +1,160 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// VariantSpec describes one output configuration produced by
+// GenerateVariants.
+type VariantSpec struct {
+	Name string // Identifies the variant in errors
+	// Apply adjusts a copy of the base options, for example setting
+	// IncludeTests and output paths. A nil Apply generates the base options.
+	Apply func(opts *Options)
+}
+
+// GenerateVariants writes the outputs of several option variants from one
+// file index and one content hash, so each extra artifact costs only its
+// analysis and rendering. Variants analyzed with compatible options reuse the
+// packages analyzed for an earlier variant.
+//
+// Variants must index files like the base options (same root, git modes,
+// exclude and include patterns, nested codemaps and external analyzers) and
+// must write distinct outputs. State and the analysis cache are stored at the
+// base options' paths; the analysis cache kept is the first variant's. Every
+// variant's outputs are kept out of the shared index, so staleness checks for
+// one variant alone see the other variants' outputs as project files.
+func GenerateVariants(ctx context.Context, base Options, variants []VariantSpec) ([]*Codemap, error) {
+	if len(variants) == 0 {
+		return nil, nil
+	}
+	root, err := filepath.Abs(base.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+
+	optsList, err := variantOptions(root, base, variants)
+	if err != nil {
+		return nil, err
+	}
+	base.variantOutputs = optsList[0].variantOutputs
+
+	idx, err := buildIndex(ctx, root, base)
+	if err != nil {
+		return nil, fmt.Errorf("build file index: %w", err)
+	}
+	statePath := resolveStatePath(root, base)
+	state, err := readState(statePath)
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	analysisPath := resolveAnalysisStatePath(root, base)
+	analysisCache, err := readAnalysisCache(analysisPath)
+	if err != nil {
+		return nil, fmt.Errorf("read analysis cache: %w", err)
+	}
+	hash, nextState, err := computeAggregateHash(ctx, idx, state)
+	if err != nil {
+		return nil, fmt.Errorf("compute hash: %w", err)
+	}
+
+	// Variants share one resource budget.
+	ctx, _ = withResourceGuard(ctx, base)
+	analyses := []*AnalysisCache{analysisCache}
+	var firstState *CodemapState
+	outputs := make(map[string]struct{})
+	results := make([]*Codemap, len(optsList))
+	for i, opts := range optsList {
+		variantState := cloneCodemapState(nextState)
+		variantState.Analysis = nil
+		cm, err := analyzeModel(ctx, AnalysisInput{
+			Root:      root,
+			Index:     idx,
+			Options:   opts,
+			PrevState: mergeStateWithAnalysis(nextState, compatibleAnalysisCache(analyses, opts)),
+			NextState: variantState,
+		}, hash)
+		if err != nil {
+			return nil, fmt.Errorf("analyze variant %s: %w", variants[i].Name, err)
+		}
+		analyses = append(analyses, variantState.Analysis)
+		if firstState == nil {
+			firstState = variantState
+		}
+
+		if err := renderOutputs(root, opts, variantState, cm); err != nil {
+			return nil, fmt.Errorf("write variant %s: %w", variants[i].Name, err)
+		}
+		for _, name := range trackedOutputs(root, opts, state) {
+			outputs[name] = struct{}{}
+		}
+		results[i] = cm
+	}
+
+	firstState.Outputs = sortedImportSet(outputs)
+	if err := saveState(ctx, root, base, statePath, firstState); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// variantOptions applies each variant to base, checks that the variants
+// share the file index and write distinct outputs, and records every
+// variant's outputs in each result so none of them is indexed.
+func variantOptions(root string, base Options, variants []VariantSpec) ([]Options, error) {
+	optsList := make([]Options, len(variants))
+	owners := make(map[string]string)
+	var generated []string
+	for i, variant := range variants {
+		opts := base
+		if variant.Apply != nil {
+			variant.Apply(&opts)
+		}
+		if opts.OutputPath == "" {
+			opts.OutputPath = MarkdownRenderer{}.DefaultPath()
+		}
+		if opts.PathsOutputPath == "" {
+			opts.PathsOutputPath = PathsRenderer{}.DefaultPath()
+		}
+		if !sameIndexOptions(base, opts) {
+			return nil, fmt.Errorf("variant %s changes how files are indexed; only analysis and output options may differ", variant.Name)
+		}
+		for _, name := range currentOutputPaths(root, opts) {
+			if owner, ok := owners[name]; ok {
+				return nil, fmt.Errorf("variants %s and %s both write %s", owner, variant.Name, name)
+			}
+			owners[name] = variant.Name
+		}
+		generated = append(generated, generatedPaths(root, opts)...)
+		optsList[i] = opts
+	}
+	for i := range optsList {
+		optsList[i].variantOutputs = generated
+	}
+	return optsList, nil
+}
+
+// sameIndexOptions reports whether a and b build the same file index.
+func sameIndexOptions(a, b Options) bool {
+	return a.ProjectRoot == b.ProjectRoot &&
+		a.GitIgnore == b.GitIgnore &&
+		a.GitTracked == b.GitTracked &&
+		a.NestedCodemaps == b.NestedCodemaps &&
+		indexPatternsEqual(a.ExcludePatterns, b.ExcludePatterns) &&
+		indexPatternsEqual(a.IncludePatterns, b.IncludePatterns) &&
+		indexPatternsEqual(externalLanguageSignatures(a), externalLanguageSignatures(b)) &&
+		indexPatternsEqual(languageFilterIDs(a), languageFilterIDs(b))
+}
+
+// compatibleAnalysisCache returns the most recent cache in analyses that opts
+// can reuse, or nil.
+func compatibleAnalysisCache(analyses []*AnalysisCache, opts Options) *AnalysisCache {
+	for i := len(analyses) - 1; i >= 0; i-- {
+		if analysisCacheCompatible(analyses[i], opts) {
+			return analyses[i]
+		}
+	}
+	return nil
+}
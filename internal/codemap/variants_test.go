@@ -0,0 +1,74 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateVariantsSharesIndexAcrossOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":          "module example.com/app\n\ngo 1.22\n",
+		"main.go":         "package main\n\nfunc main() {}\n",
+		"e2e/e2e_test.go": "package e2e\n\nimport \"testing\"\n\nfunc TestRun(t *testing.T) {}\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	base := DefaultOptions()
+	base.ProjectRoot = tmpDir
+	variants := []VariantSpec{
+		{Name: "default"},
+		{Name: "tests", Apply: func(opts *Options) {
+			opts.IncludeTests = true
+			opts.OutputPath = "CODEMAP.tests.md"
+			opts.PathsOutputPath = "CODEMAP.tests.paths"
+		}},
+	}
+	results, err := GenerateVariants(context.Background(), base, variants)
+	if err != nil {
+		t.Fatalf("GenerateVariants returned error: %v", err)
+	}
+	if len(results) != 2 || results[0].ContentHash != results[1].ContentHash {
+		t.Fatalf("expected two results sharing one content hash, got %+v", results)
+	}
+	if len(results[1].Packages) <= len(results[0].Packages) {
+		t.Fatalf("expected the tests variant to add packages, got %d and %d", len(results[0].Packages), len(results[1].Packages))
+	}
+	for _, name := range []string{"CODEMAP.md", "CODEMAP.paths", "CODEMAP.tests.md", "CODEMAP.tests.paths"} {
+		hash, err := readExistingHash(filepath.Join(tmpDir, name), 0)
+		if err != nil || hash != results[0].ContentHash {
+			t.Fatalf("expected %s with hash %s, got %q (err %v)", name, results[0].ContentHash, hash, err)
+		}
+	}
+	tests, err := os.ReadFile(filepath.Join(tmpDir, "CODEMAP.tests.md"))
+	if err != nil || !strings.Contains(string(tests), "| <a id=\"pkg-e2e\"></a>e2e |") {
+		t.Fatalf("expected the tests variant to list e2e:\n%s", tests)
+	}
+
+	again, err := GenerateVariants(context.Background(), base, variants)
+	if err != nil {
+		t.Fatalf("second GenerateVariants returned error: %v", err)
+	}
+	if again[0].ContentHash != results[0].ContentHash {
+		t.Fatalf("expected variant outputs to stay out of the index, hash changed from %s to %s", results[0].ContentHash, again[0].ContentHash)
+	}
+	state, err := readState(resolveStatePath(tmpDir, base))
+	if err != nil || state == nil || len(state.Outputs) != 4 {
+		t.Fatalf("expected state to track every variant's outputs, got %+v (err %v)", state, err)
+	}
+
+	if _, err := GenerateVariants(context.Background(), base, []VariantSpec{{Name: "a"}, {Name: "b"}}); err == nil {
+		t.Fatal("expected variants writing the same outputs to be rejected")
+	}
+	gitignore := VariantSpec{Name: "gitignore", Apply: func(opts *Options) {
+		opts.GitIgnore = true
+		opts.OutputPath = "CODEMAP.ignored.md"
+		opts.DisablePaths = true
+	}}
+	if _, err := GenerateVariants(context.Background(), base, []VariantSpec{{Name: "default"}, gitignore}); err == nil {
+		t.Fatal("expected a variant changing the index to be rejected")
+	}
+}
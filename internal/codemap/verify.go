@@ -0,0 +1,186 @@
+package codemap
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Index modes recorded in output headers.
+const (
+	IndexModeWalk       = "walk"
+	IndexModeGitIgnore  = "gitignore"
+	IndexModeGitTracked = "git-tracked"
+)
+
+func indexModeName(idx *FileIndex) string {
+	switch {
+	case idx.GitTracked && idx.GitIgnore:
+		return IndexModeGitTracked + "+" + IndexModeGitIgnore
+	case idx.GitTracked:
+		return IndexModeGitTracked
+	case idx.GitIgnore:
+		return IndexModeGitIgnore
+	default:
+		return IndexModeWalk
+	}
+}
+
+// applyIndexMode sets the index options named by mode on opts.
+func applyIndexMode(opts *Options, mode string) error {
+	opts.GitIgnore, opts.GitTracked = false, false
+	for _, part := range strings.Split(mode, "+") {
+		switch part {
+		case IndexModeWalk:
+		case IndexModeGitIgnore:
+			opts.GitIgnore = true
+		case IndexModeGitTracked:
+			opts.GitTracked = true
+		default:
+			return fmt.Errorf("unknown index mode: %q", mode)
+		}
+	}
+	return nil
+}
+
+// OutputHeader is the verification metadata at the top of a codemap output.
+type OutputHeader struct {
+	Hash      string
+	IndexMode string // Empty for outputs written before index metadata existed
+	Files     int
+}
+
+// OutputPackages returns the number of packages the outputs of opts were
+// last rendered from, as recorded in the state.
+func OutputPackages(opts Options) (int, error) {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return 0, fmt.Errorf("resolve root: %w", err)
+	}
+	state, err := readState(resolveStatePath(root, withDefaultOutputPaths(opts)))
+	if err != nil {
+		return 0, fmt.Errorf("read state: %w", err)
+	}
+	if state == nil {
+		return 0, errors.New("no state records the outputs")
+	}
+	return state.Packages, nil
+}
+
+// ReadOutputHeader parses the hash and index metadata of an output file.
+func ReadOutputHeader(path string) (OutputHeader, error) {
+	return readOutputHeader(path, defaultHashScanLines)
+}
+
+// readOutputHeader parses the header from the first scanLines lines of path
+// (0 = default).
+func readOutputHeader(path string, scanLines int) (OutputHeader, error) {
+	var header OutputHeader
+	if scanLines <= 0 {
+		scanLines = defaultHashScanLines
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return header, err
+	}
+	defer f.Close()
+
+	scanner := newHeaderScanner(f)
+	for linesChecked := 0; linesChecked < scanLines && scanner.Scan(); linesChecked++ {
+		line := scanner.Text()
+		if hash := parseHashLine(line); hash != "" {
+			header.Hash = hash
+			continue
+		}
+		if fields, ok := parseIndexLine(line); ok {
+			header.IndexMode = fields["mode"]
+			header.Files, _ = strconv.Atoi(fields["files"])
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		return header, err
+	}
+	return header, nil
+}
+
+// parseIndexLine parses a "codemap-index: files=N mode=M" header line in
+// either markdown comment or paths comment form.
+func parseIndexLine(line string) (map[string]string, bool) {
+	s := strings.TrimSpace(line)
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(s, "<!--"), "-->"))
+	s = strings.TrimSpace(strings.TrimPrefix(s, "#"))
+	rest, ok := strings.CutPrefix(s, "codemap-index:")
+	if !ok {
+		return nil, false
+	}
+	fields := make(map[string]string)
+	for _, field := range strings.Fields(rest) {
+		if key, value, ok := strings.Cut(field, "="); ok {
+			fields[key] = value
+		}
+	}
+	return fields, true
+}
+
+// VerifyResult reports whether the current tree matches an expected hash.
+type VerifyResult struct {
+	ExpectedHash  string `json:"expectedHash"`
+	ActualHash    string `json:"actualHash"`
+	IndexMode     string `json:"indexMode"`
+	ExpectedFiles int    `json:"expectedFiles,omitempty"` // 0 when the header predates file counts
+	Files         int    `json:"files"`
+	Match         bool   `json:"match"`
+}
+
+// Verify recomputes the content hash of the tree from scratch, ignoring local
+// state files, and compares it with expectedHash, which defaults to the hash
+// in the markdown output header. Index metadata in that header overrides the
+// index options in opts, so the same files are hashed as on the machine that
+// generated the output.
+func Verify(ctx context.Context, opts Options, expectedHash string) (*VerifyResult, error) {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	if opts.OutputPath == "" {
+		opts.OutputPath = MarkdownRenderer{}.DefaultPath()
+	}
+
+	header, err := readOutputHeader(filepath.Join(root, opts.OutputPath), opts.HashScanLines)
+	if err != nil && !(os.IsNotExist(err) && expectedHash != "") {
+		return nil, fmt.Errorf("read output header: %w", err)
+	}
+	if header.IndexMode != "" {
+		if err := applyIndexMode(&opts, header.IndexMode); err != nil {
+			return nil, err
+		}
+	}
+	result := &VerifyResult{ExpectedHash: expectedHash}
+	if result.ExpectedHash == "" {
+		if header.Hash == "" {
+			return nil, fmt.Errorf("no codemap-hash header in %s", opts.OutputPath)
+		}
+		result.ExpectedHash = header.Hash
+		result.ExpectedFiles = header.Files
+	} else if header.Hash == expectedHash {
+		result.ExpectedFiles = header.Files
+	}
+
+	idx, err := buildIndex(ctx, root, opts)
+	if err != nil {
+		return nil, fmt.Errorf("build file index: %w", err)
+	}
+	result.ActualHash, err = computeAggregateHashOnly(ctx, idx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("compute hash: %w", err)
+	}
+	result.IndexMode = indexModeName(idx)
+	result.Files = len(idx.Files)
+	result.Match = result.ActualHash == result.ExpectedHash
+	return result, nil
+}
@@ -0,0 +1,90 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyIgnoresLocalStateAndHonorsHeaderIndexMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module example.com/test\n\ngo 1.22\n",
+		"main.go":      "package main\n",
+		".gitignore":   "scratch/\n",
+		"scratch/x.go": "package scratch\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.GitIgnore = true
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	header, err := ReadOutputHeader(filepath.Join(tmpDir, "CODEMAP.md"))
+	if err != nil {
+		t.Fatalf("ReadOutputHeader returned error: %v", err)
+	}
+	want := OutputHeader{Hash: cm.ContentHash, IndexMode: IndexModeGitIgnore, Files: 1}
+	if header != want {
+		t.Fatalf("unexpected header: got %+v want %+v", header, want)
+	}
+
+	// Simulate a fresh CI checkout: no state files, default flags.
+	for _, name := range []string{".codemap.state.json", ".codemap.state.analysis.json"} {
+		if err := os.Remove(filepath.Join(tmpDir, name)); err != nil && !os.IsNotExist(err) {
+			t.Fatalf("remove %s: %v", name, err)
+		}
+	}
+	verifyOpts := DefaultOptions()
+	verifyOpts.ProjectRoot = tmpDir
+	result, err := Verify(context.Background(), verifyOpts, "")
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if !result.Match || result.Files != 1 || result.IndexMode != IndexModeGitIgnore {
+		t.Fatalf("expected verified tree, got %+v", result)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatalf("write main.go: %v", err)
+	}
+	result, err = Verify(context.Background(), verifyOpts, cm.ContentHash)
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if result.Match {
+		t.Fatalf("expected mismatch after edit, got %+v", result)
+	}
+}
+
+func TestOutputPackagesSurvivesWarm(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":     "module example.com/test\n\ngo 1.22\n",
+		"main.go":    "package main\n",
+		"lib/lib.go": "package lib\n",
+	}
+	writeTestTree(t, tmpDir, files)
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	// Warm writes no outputs, so the count of the last render must stay.
+	if _, err := Warm(context.Background(), opts); err != nil {
+		t.Fatalf("Warm returned error: %v", err)
+	}
+	packages, err := OutputPackages(opts)
+	if err != nil {
+		t.Fatalf("OutputPackages returned error: %v", err)
+	}
+	if packages != len(cm.Packages) || packages == 0 {
+		t.Fatalf("OutputPackages = %d, want %d", packages, len(cm.Packages))
+	}
+}
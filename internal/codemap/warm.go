@@ -0,0 +1,62 @@
+package codemap
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// Warm builds the state and analysis caches for opts.ProjectRoot without
+// writing any outputs, so CI images and devcontainers can ship them and the
+// first real run only re-checks what changed. The returned model is not
+// rendered anywhere.
+func Warm(ctx context.Context, opts Options) (*Codemap, error) {
+	root, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+
+	idx, err := buildIndex(ctx, root, opts)
+	if err != nil {
+		return nil, fmt.Errorf("build file index: %w", err)
+	}
+
+	statePath := resolveStatePath(root, opts)
+	state, err := readState(statePath)
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+
+	hash, nextState, err := computeAggregateHash(ctx, idx, state)
+	if err != nil {
+		return nil, fmt.Errorf("compute hash: %w", err)
+	}
+	prevState, err := withAnalysisCache(root, opts, state)
+	if err != nil {
+		return nil, err
+	}
+	cm, err := analyzeModel(ctx, AnalysisInput{
+		Root:      root,
+		Index:     idx,
+		Options:   opts,
+		PrevState: prevState,
+		NextState: nextState,
+	}, hash)
+	if err != nil {
+		return nil, fmt.Errorf("analyze: %w", err)
+	}
+
+	// Nothing was written, so keep the outputs recorded by earlier runs and
+	// the inputs and options they were rendered from.
+	if state != nil {
+		nextState.Outputs = state.Outputs
+		nextState.AuxFiles = state.AuxFiles
+		nextState.OptionsSignature = state.OptionsSignature
+		nextState.Packages = state.Packages
+		nextState.SplitPages = state.SplitPages
+	}
+	if err := saveState(ctx, root, opts, statePath, nextState); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
@@ -0,0 +1,52 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWarmBuildsCachesWithoutWritingOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/test\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	cm, err := Warm(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Warm returned error: %v", err)
+	}
+	if len(cm.Packages) != 1 {
+		t.Fatalf("expected 1 package, got %d", len(cm.Packages))
+	}
+	for _, name := range []string{"CODEMAP.md", "CODEMAP.paths"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected warm not to write %s (stat err %v)", name, err)
+		}
+	}
+
+	state, err := readState(resolveStatePath(tmpDir, opts))
+	if err != nil || state == nil {
+		t.Fatalf("expected warm to write state, got %v (err %v)", state, err)
+	}
+	cache, err := readAnalysisCache(resolveAnalysisStatePath(tmpDir, opts))
+	if err != nil || cache == nil || len(cache.Packages) != 1 {
+		t.Fatalf("expected warm to write the analysis cache, got %+v (err %v)", cache, err)
+	}
+
+	inst := &recordingInstrumentation{}
+	opts.Instrumentation = inst
+	if _, generated, err := EnsureUpToDate(context.Background(), opts); err != nil || !generated {
+		t.Fatalf("EnsureUpToDate returned %v (err %v)", generated, err)
+	}
+	if want := []string{"go:."}; !reflect.DeepEqual(inst.hits, want) || len(inst.starts) != 0 {
+		t.Fatalf("expected the first real run to reuse the warmed analysis, got hits %v and starts %v", inst.hits, inst.starts)
+	}
+}
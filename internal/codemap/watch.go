@@ -0,0 +1,35 @@
+package codemap
+
+import (
+	"context"
+	"time"
+)
+
+// Watch brings outputs up to date, then keeps them current until ctx is done
+// by polling the tree every interval and regenerating once a burst of changes
+// has been quiet for Options.RefreshQuiescence. Regeneration goes through
+// EnsureUpToDate, so unchanged packages come from the analysis cache.
+//
+// onRefresh, when set, is called after every regeneration attempt with the
+// same results EnsureUpToDate returns. Only the initial run's error is
+// returned; later errors are reported through onRefresh and watching goes on.
+func Watch(ctx context.Context, opts Options, interval time.Duration, onRefresh func(cm *Codemap, generated bool, err error)) error {
+	cm, generated, err := EnsureUpToDate(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if onRefresh != nil {
+		onRefresh(cm, generated, nil)
+	}
+
+	pollChanges(ctx, opts, interval, func(ctx context.Context) {
+		cm, generated, err := EnsureUpToDate(ctx, opts)
+		if ctx.Err() != nil {
+			return
+		}
+		if onRefresh != nil {
+			onRefresh(cm, generated, err)
+		}
+	})
+	return nil
+}
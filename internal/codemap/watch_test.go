@@ -0,0 +1,66 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchRegeneratesAfterChanges(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/test\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatalf("write go.mod: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatalf("write main.go: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	opts.RefreshQuiescence = 30 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	refreshed := make(chan bool, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, opts, 10*time.Millisecond, func(cm *Codemap, generated bool, err error) {
+			if err != nil {
+				t.Errorf("refresh error: %v", err)
+			}
+			refreshed <- generated
+		})
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("Watch returned error: %v", err)
+		}
+	}()
+
+	select {
+	case generated := <-refreshed:
+		if !generated {
+			t.Fatal("expected the initial run to generate outputs")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("initial generation did not happen")
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "util.go"), []byte("package main\n\n// Helper does nothing.\nfunc Helper() {}\n"), 0644); err != nil {
+		t.Fatalf("write util.go: %v", err)
+	}
+	select {
+	case generated := <-refreshed:
+		if !generated {
+			t.Fatal("expected a regeneration after the change")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected watch to regenerate outputs after the change")
+	}
+
+	if stale, err := IsStale(context.Background(), opts); err != nil || stale {
+		t.Fatalf("IsStale = %v, %v; want fresh outputs", stale, err)
+	}
+}
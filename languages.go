@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runLanguagesCommand handles "codemap languages" and returns the process
+// exit code.
+func runLanguagesCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap languages", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: codemap languages [flags]")
+		fmt.Fprintln(fs.Output(), "List the analyzed languages with their file suffixes, test conventions, package roots and parser.")
+		fs.PrintDefaults()
+	}
+	applyLimits := bindOptionFlags(fs, &opts)
+	asJSON := fs.Bool("json", false, "Print the languages as JSON")
+	_ = fs.Parse(args)
+	applyLimits()
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	languages := codemap.Languages(opts)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(languages); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+		return 0
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LANGUAGE\tSOURCE\tSUFFIXES\tTESTS\tPACKAGE ROOTS\tPARSER")
+	for _, lang := range languages {
+		roots := strings.Join(lang.PackageMarkers, ",")
+		if roots == "" && lang.Source == codemap.LanguageSourceBuiltin {
+			roots = "directory"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			lang.ID, lang.Source, orDash(strings.Join(lang.FileSuffixes, ",")), orDash(strings.Join(lang.TestConventions, ",")), orDash(roots), orDash(lang.Parser))
+	}
+	_ = tw.Flush()
+	return 0
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runLintCommand handles "codemap lint" and returns the process exit code:
+// 1 when any error-severity issue is found.
+func runLintCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	lintOpts := codemap.DefaultLintOptions()
+	fs := flag.NewFlagSet("codemap lint", flag.ExitOnError)
+	applyLimits := bindOptionFlags(fs, &opts)
+	format := fs.String("format", "text", "Report format: text or json")
+	severities := fs.String("severity", "", "Comma-separated rule=level overrides, e.g. missing-purpose=error,large-package=off")
+	fs.IntVar(&lintOpts.MaxFiles, "max-files", lintOpts.MaxFiles, "Files per package above which large-package is reported (0 = unlimited)")
+	fs.IntVar(&lintOpts.MaxLines, "max-lines", lintOpts.MaxLines, "Lines per package above which large-package is reported (0 = unlimited)")
+	_ = fs.Parse(args)
+	applyLimits()
+
+	for _, item := range splitCommaList(*severities) {
+		rule, level, ok := strings.Cut(item, "=")
+		if !ok {
+			fmt.Fprintf(os.Stderr, "error: invalid -severity entry %q (want rule=level)\n", item)
+			return 2
+		}
+		severity, err := codemap.ParseLintSeverity(level)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+		lintOpts.Severities[strings.TrimSpace(rule)] = severity
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	report, err := codemap.Lint(ctx, opts, lintOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+	case "text":
+		for _, issue := range report.Issues {
+			fmt.Printf("%s: %s [%s] %s\n", issue.Severity, issue.Package, issue.Rule, issue.Message)
+		}
+		fmt.Printf("%d packages checked: %d errors, %d warnings\n", report.Packages, report.Errors, report.Warnings)
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown -format %q (want text or json)\n", *format)
+		return 2
+	}
+
+	if report.Errors > 0 {
+		return 1
+	}
+	return 0
+}
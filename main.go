@@ -2,43 +2,318 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
 
 	codemap "github.com/Someblueman/codemap/internal/codemap"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "generate":
+			os.Exit(runGenerateCommand(os.Args[2:]))
+		case "check":
+			os.Exit(runCheckCommand(os.Args[2:]))
+		case "watch":
+			os.Exit(runWatchCommand(os.Args[2:]))
+		case "daemon", "status", "refresh":
+			os.Exit(runDaemonCommand(os.Args[1], os.Args[2:]))
+		case "aggregate":
+			os.Exit(runAggregateCommand(os.Args[2:]))
+		case "lint":
+			os.Exit(runLintCommand(os.Args[2:]))
+		case "languages":
+			os.Exit(runLanguagesCommand(os.Args[2:]))
+		case "profile-languages":
+			os.Exit(runProfileLanguagesCommand(os.Args[2:]))
+		case "verify":
+			os.Exit(runVerifyCommand(os.Args[2:]))
+		case "verify-outputs":
+			os.Exit(runVerifyOutputsCommand(os.Args[2:]))
+		case "serve":
+			os.Exit(runServeCommand(os.Args[2:]))
+		case "mcp":
+			os.Exit(runMCPCommand(os.Args[2:]))
+		case "clean-outputs":
+			os.Exit(runCleanOutputsCommand(os.Args[2:]))
+		case "package":
+			os.Exit(runPackageCommand(os.Args[2:]))
+		case "search":
+			os.Exit(runSearchCommand(os.Args[2:]))
+		case "query":
+			os.Exit(runQueryCommand(os.Args[2:]))
+		case "diff":
+			os.Exit(runDiffCommand(os.Args[2:]))
+		case "warm":
+			os.Exit(runWarmCommand(os.Args[2:]))
+		case "export-model":
+			os.Exit(runExportModelCommand(os.Args[2:]))
+		case "import-model":
+			os.Exit(runImportModelCommand(os.Args[2:]))
+		}
+	}
+
+	os.Exit(runDefaultCommand(os.Args[1:]))
+}
+
+// runDefaultCommand handles a bare "codemap" invocation. It accepts the flags
+// of generate, check and watch together, with -check and -watch selecting
+// those commands, so scripts written before the subcommands keep working.
+func runDefaultCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap", flag.ExitOnError)
+	fs.Usage = func() { printUsage(fs) }
+	applyLimits := bindOptionFlags(fs, &opts)
+	gen := bindGenerateFlags(fs)
+	check := fs.Bool("check", false, "Check staleness only (exit 1 if stale)")
+	bindCheckFlags(fs, &opts)
+	viaDaemon := bindDaemonClientFlag(fs)
+	watch := fs.Bool("watch", false, "Keep running and regenerate outputs whenever tracked files change")
+	watchInterval := bindWatchFlags(fs, &opts)
+	_ = fs.Parse(args)
+	applyLimits()
+	if fs.NArg() != 0 {
+		// A mistyped command must not fall through to a regeneration.
+		fmt.Fprintf(os.Stderr, "error: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	switch {
+	case *check:
+		return runCheck(ctx, opts, gen.quiet, *viaDaemon)
+	case *watch:
+		return runWatch(ctx, opts, *watchInterval)
+	}
+	return runGenerate(ctx, opts, gen, *viaDaemon)
+}
+
+// printUsage lists the subcommands before the flags of a bare invocation.
+func printUsage(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintln(out, "Usage: codemap [command] [flags]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Commands:")
+	fmt.Fprintln(out, "  generate        Write outputs when stale (the default without a command)")
+	fmt.Fprintln(out, "  check           Exit 1 when outputs are stale")
+	fmt.Fprintln(out, "  watch           Regenerate outputs whenever tracked files change")
+	fmt.Fprintln(out, "  search          Search package, file and symbol names")
+	fmt.Fprintln(out, "  package         Print the detailed analysis of one package")
+	fmt.Fprintln(out, "  query           Look up package owners, symbols or concern files in the cache")
+	fmt.Fprintln(out, "  diff            Report package changes since the last run or a saved state")
+	fmt.Fprintln(out, "  lint            Report packages without purposes, oversized packages and similar issues")
+	fmt.Fprintln(out, "  verify          Check that the tree matches an output's hash")
+	fmt.Fprintln(out, "  verify-outputs  Check that the outputs agree on hash and package list")
+	fmt.Fprintln(out, "  aggregate       Combine several repositories into one codemap")
+	fmt.Fprintln(out, "  mcp             Serve codemap tools to coding agents over MCP on stdio")
+	fmt.Fprintln(out, "  languages       List analyzed languages, their file suffixes and package roots")
+	fmt.Fprintln(out, "  export-model    Bundle the model and caches into one portable archive")
+	fmt.Fprintln(out, "  import-model    Import an export-model archive instead of analyzing")
+	fmt.Fprintln(out, "  daemon, status, refresh, serve, warm, clean-outputs, profile-languages")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Run \"codemap <command> -h\" for the flags of a command. Flags of a bare")
+	fmt.Fprintln(out, "invocation (generate, check with -check, watch with -watch):")
+	fs.PrintDefaults()
+}
+
+// generateFlags holds the flags of "codemap generate".
+type generateFlags struct {
+	force        bool
+	toStdout     bool
+	format       string
+	quiet        bool
+	jsonStatus   bool
+	failOnCycles bool
+}
+
+func bindGenerateFlags(fs *flag.FlagSet) *generateFlags {
+	gen := &generateFlags{}
+	fs.BoolVar(&gen.force, "force", false, "Force regeneration even if outputs are up to date")
+	fs.BoolVar(&gen.toStdout, "stdout", false, "Print the rendered codemap to stdout instead of writing output files (caches are still updated)")
+	fs.StringVar(&gen.format, "format", "markdown", "With -stdout, the output to print: markdown, paths, json, symbols, concerns, graph-dot, graph-mermaid or agents")
+	fs.BoolVar(&gen.quiet, "quiet", false, "Print nothing on success; errors and warnings still go to stderr")
+	fs.BoolVar(&gen.jsonStatus, "json-status", false, "Print the result as one JSON object: {\"generated\", \"packages\", \"hash\"}")
+	fs.BoolVar(&gen.failOnCycles, "fail-on-cycles", false, "Exit 1 when project packages import each other in a cycle")
+	return gen
+}
+
+func bindCheckFlags(fs *flag.FlagSet, opts *codemap.Options) {
+	fs.DurationVar(&opts.StalenessGrace, "staleness-grace", 0, "When checking, only fail when outputs were generated longer ago than this (e.g. 24h)")
+}
+
+// bindWatchFlags registers the watch flags and returns the poll interval.
+func bindWatchFlags(fs *flag.FlagSet, opts *codemap.Options) *time.Duration {
+	interval := fs.Duration("watch-interval", 500*time.Millisecond, "When watching, how often to poll the tree for changes")
+	fs.DurationVar(&opts.RefreshQuiescence, "watch-debounce", 300*time.Millisecond, "When watching, wait until no changes were seen for this long before regenerating")
+	return interval
+}
+
+// runGenerateCommand handles "codemap generate" and returns the process exit
+// code.
+func runGenerateCommand(args []string) int {
 	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap generate", flag.ExitOnError)
+	applyLimits := bindOptionFlags(fs, &opts)
+	gen := bindGenerateFlags(fs)
+	viaDaemon := bindDaemonClientFlag(fs)
+	_ = fs.Parse(args)
+	applyLimits()
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	return runGenerate(ctx, opts, gen, *viaDaemon)
+}
 
-	flag.StringVar(&opts.ProjectRoot, "root", ".", "Project root directory")
-	flag.StringVar(&opts.OutputPath, "output", "CODEMAP.md", "Output file")
-	flag.StringVar(&opts.PathsOutputPath, "paths-output", "CODEMAP.paths", "Paths output file")
-	flag.IntVar(&opts.LargePackageFiles, "large", 10, "File threshold for detailed listing")
-	flag.BoolVar(&opts.IncludeTests, "tests", false, "Include test files")
-	flag.BoolVar(&opts.DisablePaths, "no-paths", false, "Disable CODEMAP.paths output")
-	flag.BoolVar(&opts.Verbose, "v", false, "Verbose output")
-	check := flag.Bool("check", false, "Check staleness only (exit 1 if stale)")
-	force := flag.Bool("force", false, "Force regeneration even if outputs are up to date")
-	flag.Parse()
+// runCheckCommand handles "codemap check" and returns the process exit code:
+// 1 when outputs are stale, 2 on errors.
+func runCheckCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap check", flag.ExitOnError)
+	applyLimits := bindOptionFlags(fs, &opts)
+	bindCheckFlags(fs, &opts)
+	quiet := fs.Bool("quiet", false, "Print nothing; report staleness through the exit code only")
+	viaDaemon := bindDaemonClientFlag(fs)
+	_ = fs.Parse(args)
+	applyLimits()
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	return runCheck(ctx, opts, *quiet, *viaDaemon)
+}
+
+// runWatchCommand handles "codemap watch" and returns the process exit code.
+func runWatchCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap watch", flag.ExitOnError)
+	applyLimits := bindOptionFlags(fs, &opts)
+	interval := bindWatchFlags(fs, &opts)
+	_ = fs.Parse(args)
+	applyLimits()
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
+	return runWatch(ctx, opts, *interval)
+}
+
+// runCheck reports whether outputs are stale and returns the exit code.
+// With viaDaemon a running daemon answers instead.
+func runCheck(ctx context.Context, opts codemap.Options, quiet, viaDaemon bool) int {
+	var resp *codemap.DaemonResponse
+	var err error
+	if viaDaemon {
+		resp, err = callRunningDaemon(ctx, opts, codemap.DaemonRequest{Command: codemap.DaemonCommandStatus})
+	}
+	var stale, withinGrace bool
+	switch {
+	case err != nil:
+	case resp != nil:
+		stale = resp.Stale
+		if stale {
+			withinGrace, err = codemap.WithinStalenessGrace(opts)
+		}
+	default:
+		warnOrphanedOutputs(opts)
+		stale, withinGrace, err = codemap.IsStaleWithGrace(ctx, opts)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+	switch {
+	case quiet:
+	case stale && withinGrace:
+		fmt.Printf("Codemap outputs are stale (within %s grace period)\n", opts.StalenessGrace)
+	case stale:
+		fmt.Println("Codemap outputs are stale")
+	default:
+		fmt.Println("Codemap outputs are up to date")
+	}
+	if stale && !quiet && opts.Verbose {
+		if err := printStaleReport(ctx, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+	}
+	if stale && !withinGrace {
+		return 1
+	}
+	return 0
+}
+
+// printStaleReport prints why outputs are stale: the reasons other than file
+// changes, then each changed, added or removed file with its package.
+func printStaleReport(ctx context.Context, opts codemap.Options) error {
+	report, err := codemap.StaleReport(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for _, reason := range report.Reasons {
+		fmt.Printf("  %s\n", reason)
+	}
+	for _, group := range []struct {
+		label string
+		files []codemap.StaleFile
+	}{
+		{"changed", report.Changed},
+		{"added", report.Added},
+		{"removed", report.Removed},
+	} {
+		for _, f := range group.files {
+			if f.Package == "" {
+				fmt.Printf("  %-8s %s\n", group.label, f.Path)
+			} else {
+				fmt.Printf("  %-8s %s (package %s)\n", group.label, f.Path, f.Package)
+			}
+		}
+	}
+	if len(report.Packages) > 0 {
+		fmt.Printf("Stale packages: %s\n", strings.Join(report.Packages, ", "))
+	}
+	return nil
+}
 
-	if *check {
-		stale, err := codemap.IsStale(ctx, opts)
+// runGenerate writes stale outputs, or prints one with -stdout, and returns
+// the exit code. With viaDaemon a running daemon writes them instead, unless
+// -stdout or -fail-on-cycles need the model here.
+func runGenerate(ctx context.Context, opts codemap.Options, gen *generateFlags, viaDaemon bool) int {
+	if viaDaemon && !gen.toStdout && !gen.failOnCycles {
+		resp, err := callRunningDaemon(ctx, opts, codemap.DaemonRequest{Command: codemap.DaemonCommandRefresh, Force: gen.force})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(2)
+			return 1
 		}
-		if stale {
-			fmt.Println("Codemap outputs are stale")
-			os.Exit(1)
+		if resp != nil {
+			return printDaemonRefresh(opts, gen, resp)
 		}
-		fmt.Println("Codemap outputs are up to date")
-		os.Exit(0)
+	}
+	if gen.toStdout {
+		cm, code := printRendered(ctx, opts, gen.format)
+		if code == 0 && gen.failOnCycles {
+			code = checkImportCycles(ctx, opts, cm)
+		}
+		return code
 	}
 
 	var (
@@ -46,7 +321,7 @@ func main() {
 		generated bool
 		err       error
 	)
-	if *force {
+	if gen.force {
 		cm, err = codemap.Generate(ctx, opts)
 		generated = true
 	} else {
@@ -54,28 +329,164 @@ func main() {
 	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
+	warnOrphanedOutputs(opts)
 
-	if !generated {
-		if opts.Verbose {
-			fmt.Printf("Codemap outputs are up to date (%s", opts.OutputPath)
-			if !opts.DisablePaths {
-				fmt.Printf(", %s", opts.PathsOutputPath)
-			}
-			fmt.Println(")")
-		} else {
+	switch {
+	case gen.jsonStatus:
+		if generated {
+			printWarnings(cm)
+		}
+		if err := printJSONStatus(opts, cm, generated); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+	case gen.quiet:
+		if generated {
+			printWarnings(cm)
+		}
+	case !generated:
+		printUpToDate(opts)
+	default:
+		printGenerated(opts, cm)
+	}
+
+	if gen.failOnCycles {
+		return checkImportCycles(ctx, opts, cm)
+	}
+	return 0
+}
+
+// checkImportCycles reports import cycles between project packages and
+// returns the exit code for -fail-on-cycles. cm is nil when outputs were
+// already up to date, in which case the packages come from the analysis
+// cache and only changed ones are parsed again.
+func checkImportCycles(ctx context.Context, opts codemap.Options, cm *codemap.Codemap) int {
+	var cycles [][]string
+	if cm != nil {
+		cycles = codemap.ImportCycles(cm.Packages)
+	} else {
+		var err error
+		if cycles, err = codemap.ProjectImportCycles(ctx, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+	}
+	for _, cycle := range cycles {
+		fmt.Fprintf(os.Stderr, "import cycle: %s\n", strings.Join(cycle, ", "))
+	}
+	if len(cycles) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runWatch regenerates outputs on every settled change until interrupted.
+// Only a failing initial run ends the watch; later errors are reported and
+// the next change retries.
+func runWatch(ctx context.Context, opts codemap.Options, interval time.Duration) int {
+	if interval <= 0 {
+		fmt.Fprintln(os.Stderr, "error: -watch-interval must be positive")
+		return 2
+	}
+	err := codemap.Watch(ctx, opts, interval, func(cm *codemap.Codemap, generated bool, err error) {
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		case generated:
+			printGenerated(opts, cm)
+		case opts.Verbose:
 			fmt.Println("Codemap outputs are up to date")
 		}
-		return
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// printRendered prints the output named by format for the current tree
+// without writing any output file, and returns the model and exit code.
+func printRendered(ctx context.Context, opts codemap.Options, format string) (*codemap.Codemap, int) {
+	renderer, err := codemap.RendererFor(format, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return nil, 2
+	}
+	if format == "concerns" && opts.ConcernsOutputPath == "" {
+		// Concern file lists are only collected for an enabled output.
+		opts.ConcernsOutputPath = renderer.DefaultPath()
+	}
+	cm, err := codemap.Warm(ctx, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return nil, 1
+	}
+	printWarnings(cm)
+	content, err := renderer.Render(cm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: render %s: %v\n", format, err)
+		return nil, 1
+	}
+	fmt.Print(content)
+	return cm, 0
+}
+
+// generationStatus is the -json-status report.
+type generationStatus struct {
+	Generated bool   `json:"generated"`
+	Packages  int    `json:"packages"`
+	Hash      string `json:"hash"`
+}
+
+// printJSONStatus prints the -json-status report. When nothing was
+// generated the hash is read from the existing markdown output and the
+// package count from the state that recorded it.
+func printJSONStatus(opts codemap.Options, cm *codemap.Codemap, generated bool) error {
+	status := generationStatus{Generated: generated}
+	if cm != nil {
+		status.Packages = len(cm.Packages)
+		status.Hash = cm.ContentHash
+	} else {
+		outputPath := opts.OutputPath
+		if !filepath.IsAbs(outputPath) {
+			outputPath = filepath.Join(opts.ProjectRoot, outputPath)
+		}
+		header, err := codemap.ReadOutputHeader(outputPath)
+		if err != nil {
+			return err
+		}
+		status.Hash = header.Hash
+		if status.Packages, err = codemap.OutputPackages(opts); err != nil {
+			return err
+		}
+	}
+	return json.NewEncoder(os.Stdout).Encode(status)
+}
+
+// printWarnings reports notices about incomplete or degraded output.
+func printWarnings(cm *codemap.Codemap) {
+	for _, warning := range cm.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
 	}
+}
+
+// printGenerated reports a regeneration and its warnings.
+func printGenerated(opts codemap.Options, cm *codemap.Codemap) {
+	printWarnings(cm)
+	printGeneratedSummary(opts, len(cm.Packages), len(cm.Concerns))
+}
 
+// printGeneratedSummary reports the outputs a regeneration wrote.
+func printGeneratedSummary(opts codemap.Options, packages, concerns int) {
 	if opts.Verbose {
 		fmt.Printf("Generated %s", opts.OutputPath)
 		if !opts.DisablePaths {
 			fmt.Printf(", %s", opts.PathsOutputPath)
 		}
-		fmt.Printf(": %d packages, %d concerns\n", len(cm.Packages), len(cm.Concerns))
+		fmt.Printf(": %d packages, %d concerns\n", packages, concerns)
 	} else {
 		if opts.DisablePaths {
 			fmt.Printf("Generated %s\n", opts.OutputPath)
@@ -84,3 +495,177 @@ func main() {
 		}
 	}
 }
+
+// printUpToDate reports that no output needed regenerating.
+func printUpToDate(opts codemap.Options) {
+	if opts.Verbose {
+		fmt.Printf("Codemap outputs are up to date (%s", opts.OutputPath)
+		if !opts.DisablePaths {
+			fmt.Printf(", %s", opts.PathsOutputPath)
+		}
+		fmt.Println(")")
+	} else {
+		fmt.Println("Codemap outputs are up to date")
+	}
+}
+
+// bindOptionFlags registers the flags shared by every command. The returned
+// function must be called after parsing to apply derived options.
+func bindOptionFlags(fs *flag.FlagSet, opts *codemap.Options) func() {
+	fs.StringVar(&opts.ProjectRoot, "root", ".", "Project root directory")
+	fs.StringVar(&opts.OutputPath, "output", "CODEMAP.md", "Output file")
+	fs.StringVar(&opts.PathsOutputPath, "paths-output", "CODEMAP.paths", "Paths output file")
+	fs.IntVar(&opts.LargePackageFiles, "large", 10, "File threshold for detailed listing")
+	fs.BoolVar(&opts.IncludeTests, "tests", false, "Include test files")
+	fs.BoolVar(&opts.IncludeUnexported, "unexported", false, "Record unexported and underscore-private symbols (marked private) in the JSON output")
+	fs.BoolVar(&opts.LowMemory, "low-memory", false, "Analyze one package at a time and keep only what CODEMAP.md and CODEMAP.paths render (the JSON output omits symbols)")
+	fs.BoolVar(&opts.GitIgnore, "gitignore", false, "Skip files ignored by git (.gitignore, .git/info/exclude and core.excludesFile)")
+	fs.BoolVar(&opts.BranchState, "branch-state", false, "Keep separate state caches per git branch so switching branches stays on the warm path")
+	fs.BoolVar(&opts.GitTracked, "git-tracked", false, "Index only files tracked by git (git ls-files), falling back to a directory walk without git")
+	fs.IntVar(&opts.ParallelWalk, "parallel-walk", 0, "Read directories with this many goroutines during the index walk (0 = sequential); helps cold runs on large trees")
+	fs.StringVar(&opts.Since, "since", "", "Re-read only files git reports changed since this ref when the state was written at its commit")
+	fs.BoolVar(&opts.NestedCodemaps, "nested", false, "Treat subdirectories with their own .codemap.yaml or CODEMAP.md as nested projects: list each as one row linking to its codemap instead of analyzing it")
+	fs.Func("exclude", "Skip files and directories matching a .gitignore-style pattern (repeatable or comma-separated, e.g. generated/,third_party/)", func(value string) error {
+		opts.ExcludePatterns = append(opts.ExcludePatterns, splitCommaList(value)...)
+		return nil
+	})
+	fs.Func("include", "Index only files matching a .gitignore-style pattern or inside a matching directory (repeatable or comma-separated)", func(value string) error {
+		opts.IncludePatterns = append(opts.IncludePatterns, splitCommaList(value)...)
+		return nil
+	})
+	fs.Func("lang", "Index and analyze only these languages (repeatable or comma-separated IDs or aliases, e.g. go,ts); see codemap languages", func(value string) error {
+		opts.Languages = append(opts.Languages, splitCommaList(value)...)
+		return nil
+	})
+	fs.Func("analyzer", "Analyze another language with an external command, as LANG=COMMAND or LANG:SUFFIX[:SUFFIX...]=COMMAND (repeatable, e.g. zig=./tools/zig-analyzer)", func(value string) error {
+		def, err := codemap.ParseExternalAnalyzerDef(value)
+		if err != nil {
+			return err
+		}
+		opts.ExternalAnalyzers = append(opts.ExternalAnalyzers, def)
+		return nil
+	})
+	fs.Func("custom-language", "List files of another language without parsing them, as LANG or LANG:SUFFIX[:SUFFIX...] (repeatable, e.g. thrift or terraform:.tf:.tfvars)", func(value string) error {
+		def, err := codemap.ParseCustomLanguageDef(value)
+		if err != nil {
+			return err
+		}
+		opts.CustomLanguages = append(opts.CustomLanguages, def)
+		return nil
+	})
+	fs.Func("language-config", "Add custom languages from a JSON file: {\"languages\": {\"thrift\": {\"suffixes\": [\".thrift\"], \"testSuffixes\": [...]}}}", func(path string) error {
+		defs, err := codemap.LoadLanguageFile(path)
+		if err != nil {
+			return err
+		}
+		opts.CustomLanguages = append(opts.CustomLanguages, defs...)
+		return nil
+	})
+	fs.BoolVar(&opts.DisablePaths, "no-paths", false, "Disable CODEMAP.paths output")
+	fs.StringVar(&opts.JSONOutputPath, "json-output", "", "Also write the full model as JSON to this file (e.g. CODEMAP.json)")
+	fs.StringVar(&opts.SplitOutputDir, "split-output", "", "Also write one Markdown page per package to this directory (e.g. docs/codemap) and link them from CODEMAP.md")
+	fs.IntVar(&opts.JSONMinFiles, "json-min-files", 0, "List the entry file plus the N largest other files of packages below -large in the JSON output")
+	fs.IntVar(&opts.DetailBudget, "detail-budget", 0, "Approximate tokens of file listings: the most imported and most changed packages get full detail, the rest one row (0 = list packages at -large)")
+	fs.IntVar(&opts.MaxOutputTokens, "max-tokens", 0, "Approximate tokens CODEMAP.md and CODEMAP.paths may each use; over it, drop file listings, then purposes, then concerns (0 = unlimited)")
+	fs.BoolVar(&opts.Statistics, "stats", false, "Add a Statistics section with source, comment and test lines per language and the largest files")
+	fs.BoolVar(&opts.StatisticsPackages, "stats-packages", false, "Break the Statistics section down per package (implies -stats)")
+	fs.BoolVar(&opts.APISurface, "api", false, "Add an API Surface section with the exported function signatures of each Go, TypeScript and Rust package")
+	hashes := fs.Bool("hashes", false, "Also write per-directory content hashes for build systems and caches")
+	hashesOutput := fs.String("hashes-output", "CODEMAP.hashes", "Hashes output file (with -hashes)")
+	graph := fs.Bool("graph", false, "Also write the package dependency graph as Graphviz DOT and Mermaid")
+	dotOutput := fs.String("graph-dot-output", "CODEMAP.dot", "DOT graph output file (with -graph)")
+	mermaidOutput := fs.String("graph-mermaid-output", "CODEMAP.mmd", "Mermaid graph output file (with -graph)")
+	symbols := fs.Bool("symbols", false, "Also write a tab-separated index of exported symbols with their package and file")
+	symbolsOutput := fs.String("symbols-output", "CODEMAP.symbols", "Symbol index output file (with -symbols)")
+	concerns := fs.Bool("concerns", false, "Also write a tab-separated list of the files matched by each concern")
+	concernsOutput := fs.String("concerns-output", "CODEMAP.concerns", "Concerns output file (with -concerns)")
+	fs.IntVar(&opts.ConcernPathLimit, "concerns-limit", 50, "Max files listed per concern in the concerns output")
+	fs.StringVar(&opts.AgentsOutputPath, "agents-output", "", "Also write a short agent instructions fragment to this file (e.g. CODEMAP.agents.md)")
+	agentsInject := fs.String("agents-inject", "", "Comma-separated files (e.g. AGENTS.md,CLAUDE.md) that get the agent instructions fragment between codemap:agents markers")
+	fs.BoolVar(&opts.PathsMini, "paths-mini", false, "Write CODEMAP.paths as bare package/entry rows with a single hash header")
+	fs.IntVar(&opts.MarkdownPurposeLength, "purpose-length", 60, "Max purpose length (characters) in CODEMAP.md")
+	fs.BoolVar(&opts.MarkdownTOC, "toc", false, "Start CODEMAP.md with a table of contents linking to each section")
+	fs.StringVar(&opts.MarkdownTemplate, "template", "", "Render CODEMAP.md with this Go text/template instead of the built-in layout")
+	fs.IntVar(&opts.PathsPurposeLength, "paths-purpose-length", 80, "Max purpose length (characters) in CODEMAP.paths")
+	fs.IntVar(&opts.DiffBudgetLines, "max-diff-lines", 0, "Fail instead of writing CODEMAP.md when it would change by more than this many lines (0 = unlimited)")
+	fs.BoolVar(&opts.DiffBudgetCoarsen, "diff-coarsen", false, "With -max-diff-lines, drop file listings to stay within the budget before failing")
+	fs.BoolVar(&opts.VerifyOutputs, "verify-outputs", false, "After writing, check that CODEMAP.md, CODEMAP.paths and the JSON output agree on hash and packages")
+	fs.BoolVar(&opts.Verbose, "v", false, "Verbose output; with -check, list the files and packages that made outputs stale")
+	fs.BoolVar(&opts.Verbose, "verbose", false, "Same as -v")
+	fs.BoolVar(&opts.CoChange, "cochange", false, "Mine git history for packages that frequently change together")
+	fs.IntVar(&opts.CoChangeCommits, "cochange-commits", 500, "Number of recent commits scanned by -cochange")
+	fs.IntVar(&opts.HashScanLines, "hash-scan-lines", 20, "Leading lines of each output searched for its codemap-hash header")
+	fs.BoolVar(&opts.StrictAnalyzers, "strict", false, "Fail when any language analyzer errors instead of skipping that language")
+	fs.StringVar(&opts.SocketPath, "socket", ".codemap.sock", "Daemon control socket (relative to root)")
+	fs.Float64Var(&opts.MaxCPUSeconds, "max-cpu", 0, "Soft CPU time limit in seconds; writes partial outputs when reached (0 = unlimited)")
+	maxRSSMB := fs.Int64("max-rss-mb", 0, "Soft peak memory limit in MiB; writes partial outputs when reached (0 = unlimited)")
+	var extraConcerns []codemap.ConcernDef
+	fs.Func("concern", "Add a concern as name=pattern1,pattern2 (repeatable; replaces a default concern of the same name)", func(value string) error {
+		def, err := codemap.ParseConcernFlag(value)
+		if err != nil {
+			return err
+		}
+		extraConcerns = append(extraConcerns, def)
+		return nil
+	})
+	fs.Func("concerns-file", "Add concerns from a JSON file: [{\"name\", \"patterns\", \"exampleLimit\", \"note\"}]", func(path string) error {
+		defs, err := codemap.LoadConcernFile(path)
+		if err != nil {
+			return err
+		}
+		extraConcerns = append(extraConcerns, defs...)
+		return nil
+	})
+	replaceConcerns := fs.Bool("concerns-replace", false, "Use only concerns from -concern and -concerns-file, dropping the defaults")
+	fs.Func("shell-group", "Group shell scripts per directory (dir, the default) or per top-level directory (top)", func(value string) error {
+		switch value {
+		case codemap.ShellGroupDir, codemap.ShellGroupTop:
+			opts.ShellGrouping = value
+			return nil
+		}
+		return fmt.Errorf("unknown shell grouping %q (want dir or top)", value)
+	})
+	shellPrefixes := fs.String("shell-prefix", "", "Comma-separated script name prefixes or patterns (e.g. deploy-,db-*) that each group matching shell scripts into one package")
+	securityImports := fs.String("security-imports", "", "Comma-separated import patterns that mark packages security-sensitive, replacing the defaults (trailing * = prefix)")
+	return func() {
+		opts.MaxRSS = *maxRSSMB << 20
+		if *replaceConcerns {
+			opts.Concerns = extraConcerns
+		} else if len(extraConcerns) > 0 {
+			opts.Concerns = codemap.MergeConcernDefs(opts.Concerns, extraConcerns)
+		}
+		if *agentsInject != "" {
+			opts.AgentsInjectPaths = splitCommaList(*agentsInject)
+		}
+		if *hashes {
+			opts.HashesOutputPath = *hashesOutput
+		}
+		if *graph {
+			opts.DOTOutputPath = *dotOutput
+			opts.MermaidOutputPath = *mermaidOutput
+		}
+		if *symbols {
+			opts.SymbolsOutputPath = *symbolsOutput
+		}
+		if *concerns {
+			opts.ConcernsOutputPath = *concernsOutput
+		}
+		if *shellPrefixes != "" {
+			opts.ShellPrefixes = splitCommaList(*shellPrefixes)
+		}
+		if *securityImports != "" {
+			opts.SecurityImportPatterns = splitCommaList(*securityImports)
+		}
+	}
+}
+
+// splitCommaList splits a comma-separated flag value, dropping empty items.
+func splitCommaList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
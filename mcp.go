@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runMCPCommand handles "codemap mcp", serving MCP on stdin and stdout, and
+// returns the process exit code.
+func runMCPCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap mcp", flag.ExitOnError)
+	applyLimits := bindOptionFlags(fs, &opts)
+	fs.DurationVar(&opts.StalenessGrace, "staleness-grace", 0, "Report stale outputs as within grace from check_stale until they are older than this (e.g. 24h)")
+	_ = fs.Parse(args)
+	applyLimits()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if opts.Verbose {
+		fmt.Fprintln(os.Stderr, "Serving codemap over MCP on stdio")
+	}
+	if err := codemap.NewMCPServer(opts).Serve(ctx, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
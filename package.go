@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runPackageCommand handles "codemap package <relpath>" and returns the
+// process exit code: 1 when no package lives at relpath, 2 on errors.
+func runPackageCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap package", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: codemap package [flags] <relpath>")
+		fs.PrintDefaults()
+	}
+	applyLimits := bindOptionFlags(fs, &opts)
+	asJSON := fs.Bool("json", false, "Print the analysis as JSON")
+	_ = fs.Parse(args)
+	applyLimits()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	packages, err := codemap.PackageDetail(ctx, opts, fs.Arg(0))
+	if errors.Is(err, codemap.ErrUnknownPackage) {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(packages); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+		return 0
+	}
+	for i, pkg := range packages {
+		if i > 0 {
+			fmt.Println()
+		}
+		printPackageDetail(pkg)
+	}
+	return 0
+}
+
+func printPackageDetail(pkg codemap.Package) {
+	fmt.Printf("Package: %s (%s)\n", pkg.RelativePath, pkg.Language)
+	if pkg.ImportPath != "" && pkg.ImportPath != pkg.RelativePath {
+		fmt.Printf("Import: %s\n", pkg.ImportPath)
+	}
+	if pkg.Purpose != "" {
+		fmt.Printf("Purpose: %s\n", pkg.Purpose)
+	}
+	if pkg.EntryPoint != "" {
+		fmt.Printf("Entry: %s\n", pkg.EntryPoint)
+	}
+	fmt.Printf("Size: %d files, %d lines\n", pkg.FileCount, pkg.LineCount)
+	if len(pkg.Imports) > 0 {
+		fmt.Printf("Imports: %s\n", strings.Join(pkg.Imports, ", "))
+	}
+	if len(pkg.ExternalImports) > 0 {
+		fmt.Printf("External imports: %s\n", strings.Join(pkg.ExternalImports, ", "))
+	}
+
+	if len(pkg.Files) > 0 {
+		fmt.Println("Files:")
+		for _, file := range pkg.Files {
+			line := fmt.Sprintf("  %s (%d lines)", file.Name, file.LineCount)
+			if file.Role != "" {
+				line += " [" + file.Role + "]"
+			}
+			if file.Purpose != "" {
+				line += " - " + file.Purpose
+			}
+			fmt.Println(line)
+			if len(file.KeyTypes) > 0 {
+				fmt.Printf("    types: %s\n", strings.Join(file.KeyTypes, ", "))
+			}
+			if len(file.KeyFuncs) > 0 {
+				fmt.Printf("    funcs: %s\n", strings.Join(file.KeyFuncs, ", "))
+			}
+		}
+	}
+
+	if len(pkg.ExportedTypes) > 0 {
+		fmt.Println("Types:")
+		for _, typ := range pkg.ExportedTypes {
+			line := fmt.Sprintf("  %s %s", typ.Kind, typ.Name)
+			if typ.IsTestOnly {
+				line += " [test]"
+			}
+			if typ.Comment != "" {
+				line += " - " + typ.Comment
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if len(pkg.Implementations) > 0 {
+		fmt.Println("Implementations:")
+		for _, impl := range pkg.Implementations {
+			fmt.Printf("  %s: %s\n", impl.Type, strings.Join(impl.Interfaces, ", "))
+		}
+	}
+}
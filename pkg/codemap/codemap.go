@@ -0,0 +1,193 @@
+// Package codemap is the public API for embedding codemap in other Go
+// programs such as CI bots and language servers. It re-exports the options,
+// model types and entry points of the codemap command; everything else is an
+// implementation detail that may change between releases.
+//
+// A typical embedder regenerates outputs only when the tree changed:
+//
+//	opts := codemap.DefaultOptions()
+//	opts.ProjectRoot = root
+//	cm, generated, err := codemap.EnsureUpToDate(ctx, opts)
+package codemap
+
+import (
+	"context"
+
+	internal "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// Configuration.
+type (
+	// Options configures generation, staleness checks and analysis.
+	Options = internal.Options
+	// ConcernDef defines a cross-cutting concern matched by file patterns.
+	ConcernDef = internal.ConcernDef
+	// FileRoleDef classifies files of large packages by name and content.
+	FileRoleDef = internal.FileRoleDef
+	// ExternalAnalyzerDef configures a language analyzed by an external command.
+	ExternalAnalyzerDef = internal.ExternalAnalyzerDef
+	// CustomLanguageDef configures a language whose files are listed without parsing.
+	CustomLanguageDef = internal.CustomLanguageDef
+	// Instrumentation receives analysis lifecycle events.
+	Instrumentation = internal.Instrumentation
+	// PurposeExtractor derives a file's purpose line for one language.
+	PurposeExtractor = internal.PurposeExtractor
+	// PurposeExtractorFunc adapts a function to PurposeExtractor.
+	PurposeExtractorFunc = internal.PurposeExtractorFunc
+)
+
+// Model.
+type (
+	// Codemap is the full analysis of a project.
+	Codemap = internal.Codemap
+	// Package is one logical code package with its metadata.
+	Package = internal.Package
+	// File is a source file of a package.
+	File = internal.File
+	// TypeInfo is an exported type or function.
+	TypeInfo = internal.TypeInfo
+	// FuncInfo is an exported function with its signature.
+	FuncInfo = internal.FuncInfo
+	// Implementation lists the interfaces a Go type satisfies.
+	Implementation = internal.Implementation
+	// Symbol locates an exported symbol by file.
+	Symbol = internal.Symbol
+	// Concern groups the files matching a ConcernDef.
+	Concern = internal.Concern
+	// ServiceInfo describes a deployable service.
+	ServiceInfo = internal.ServiceInfo
+	// BinaryInfo is an executable entry point of the project.
+	BinaryInfo = internal.BinaryInfo
+	// ToolchainInfo is a language version pinned by the project.
+	ToolchainInfo = internal.ToolchainInfo
+	// MigrationSet is a directory of database schema migrations.
+	MigrationSet = internal.MigrationSet
+	// HTTPEndpoint is an HTTP route the project serves.
+	HTTPEndpoint = internal.HTTPEndpoint
+	// StalenessReport explains why outputs are stale.
+	StalenessReport = internal.StalenessReport
+	// StaleFile is a file listed by a StalenessReport.
+	StaleFile = internal.StaleFile
+	// Statistics summarizes source, comment and test lines per language.
+	Statistics = internal.Statistics
+	// LanguageStats are the line statistics of one language.
+	LanguageStats = internal.LanguageStats
+	// LineStats counts the source and test files of packages.
+	LineStats = internal.LineStats
+	// PackageStats are the line statistics of one package.
+	PackageStats = internal.PackageStats
+	// FileLines is the line count of one file.
+	FileLines = internal.FileLines
+	// RepoInfo summarizes one repository of a multi-repo aggregate.
+	RepoInfo = internal.RepoInfo
+	// DirHash is the content fingerprint of one directory.
+	DirHash = internal.DirHash
+)
+
+// Extension points.
+type (
+	// Renderer turns a Codemap into one output format.
+	Renderer = internal.Renderer
+	// Analyzer builds a Codemap from a project snapshot.
+	Analyzer = internal.Analyzer
+	// LanguageAnalyzer is an Analyzer bound to one language ID.
+	LanguageAnalyzer = internal.LanguageAnalyzer
+	// LanguageSpec says which files belong to a language.
+	LanguageSpec = internal.LanguageSpec
+	// LanguageSpecProvider is implemented by analyzers for new languages.
+	LanguageSpecProvider = internal.LanguageSpecProvider
+	// AnalysisInput is the project snapshot passed to analyzers.
+	AnalysisInput = internal.AnalysisInput
+	// FileIndex lists the files of a project snapshot.
+	FileIndex = internal.FileIndex
+	// FileRecord is one indexed file.
+	FileRecord = internal.FileRecord
+	// CodemapState is the incremental state carried between runs.
+	CodemapState = internal.CodemapState
+	// AnalyzerRegistry maps language IDs to analyzers.
+	AnalyzerRegistry = internal.AnalyzerRegistry
+)
+
+// DefaultOptions returns the options the codemap command starts from.
+func DefaultOptions() Options {
+	return internal.DefaultOptions()
+}
+
+// Generate analyzes the project and writes every output, even when they are
+// up to date.
+func Generate(ctx context.Context, opts Options) (*Codemap, error) {
+	return internal.Generate(ctx, opts)
+}
+
+// EnsureUpToDate regenerates the outputs only when the tree changed since
+// they were written. It returns the new model and true after regenerating,
+// and nil and false when the outputs were current.
+func EnsureUpToDate(ctx context.Context, opts Options) (*Codemap, bool, error) {
+	return internal.EnsureUpToDate(ctx, opts)
+}
+
+// IsStale reports whether the outputs no longer match the tree.
+func IsStale(ctx context.Context, opts Options) (bool, error) {
+	return internal.IsStale(ctx, opts)
+}
+
+// StaleReport explains stale outputs: the files changed, added or removed
+// since the last run and the packages they belong to.
+func StaleReport(ctx context.Context, opts Options) (*StalenessReport, error) {
+	return internal.StaleReport(ctx, opts)
+}
+
+// Analyze builds the model without reading or writing outputs or caches.
+func Analyze(ctx context.Context, opts Options) (*Codemap, error) {
+	return internal.Analyze(ctx, opts)
+}
+
+// AnalyzeWithRegistry runs the analyzers of registry over a prepared
+// snapshot, for embedders that index files themselves.
+func AnalyzeWithRegistry(ctx context.Context, in AnalysisInput, registry *AnalyzerRegistry) (*Codemap, error) {
+	return internal.AnalyzeWithRegistry(ctx, in, registry)
+}
+
+// Render returns the CODEMAP.md content for cm.
+func Render(cm *Codemap) (string, error) {
+	return internal.Render(cm)
+}
+
+// RenderPaths returns the CODEMAP.paths content for cm.
+func RenderPaths(cm *Codemap) string {
+	return internal.RenderPaths(cm)
+}
+
+// RendererFor returns the renderer of an output format configured from opts,
+// such as "markdown", "paths" or "json".
+func RendererFor(format string, opts Options) (Renderer, error) {
+	return internal.RendererFor(format, opts)
+}
+
+// RegisterAnalyzer adds analyzer to every run in this process, replacing the
+// built-in analyzer for the same language ID.
+func RegisterAnalyzer(analyzer LanguageAnalyzer) {
+	internal.RegisterAnalyzer(analyzer)
+}
+
+// RegisterRenderer adds renderer's output to every generation in this
+// process.
+func RegisterRenderer(renderer Renderer) {
+	internal.RegisterRenderer(renderer)
+}
+
+// NewAnalyzerRegistry returns an empty analyzer registry.
+func NewAnalyzerRegistry() *AnalyzerRegistry {
+	return internal.NewAnalyzerRegistry()
+}
+
+// DefaultAnalyzerRegistry returns the built-in analyzers plus those added
+// with RegisterAnalyzer.
+func DefaultAnalyzerRegistry() *AnalyzerRegistry {
+	return internal.DefaultAnalyzerRegistry()
+}
+
+// PackageID returns the stable identifier of a package.
+func PackageID(language, relPath string) string {
+	return internal.PackageID(language, relPath)
+}
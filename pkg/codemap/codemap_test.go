@@ -0,0 +1,72 @@
+package codemap_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Someblueman/codemap/pkg/codemap"
+)
+
+func TestPublicAPIGeneratesAndChecksStaleness(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":           "module example.com/test\n\ngo 1.22\n",
+		"api/api.go":       "// Package api serves requests.\npackage api\n",
+		"cmd/tool/main.go": "package main\n\nfunc main() {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	opts := codemap.DefaultOptions()
+	opts.ProjectRoot = tmpDir
+	ctx := context.Background()
+
+	stale, err := codemap.IsStale(ctx, opts)
+	if err != nil {
+		t.Fatalf("IsStale returned error: %v", err)
+	}
+	if !stale {
+		t.Fatalf("expected missing outputs to be stale")
+	}
+
+	cm, generated, err := codemap.EnsureUpToDate(ctx, opts)
+	if err != nil {
+		t.Fatalf("EnsureUpToDate returned error: %v", err)
+	}
+	if !generated || cm == nil {
+		t.Fatalf("expected EnsureUpToDate to generate, got generated=%v", generated)
+	}
+	var api *codemap.Package
+	for i := range cm.Packages {
+		if cm.Packages[i].RelativePath == "api" {
+			api = &cm.Packages[i]
+		}
+	}
+	if api == nil || api.Purpose != "Package api serves requests." {
+		t.Fatalf("expected api package with its purpose, got %+v", cm.Packages)
+	}
+
+	if stale, err := codemap.IsStale(ctx, opts); err != nil || stale {
+		t.Fatalf("expected fresh outputs after generation, got stale=%v err=%v", stale, err)
+	}
+	if _, generated, err := codemap.EnsureUpToDate(ctx, opts); err != nil || generated {
+		t.Fatalf("expected no regeneration, got generated=%v err=%v", generated, err)
+	}
+
+	analyzed, err := codemap.Analyze(ctx, opts)
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	if len(analyzed.Packages) != len(cm.Packages) {
+		t.Fatalf("expected Analyze to find %d packages, got %d", len(cm.Packages), len(analyzed.Packages))
+	}
+}
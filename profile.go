@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runProfileLanguagesCommand handles "codemap profile-languages" and returns
+// the process exit code.
+func runProfileLanguagesCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap profile-languages", flag.ExitOnError)
+	applyLimits := bindOptionFlags(fs, &opts)
+	cold := fs.Bool("cold", false, "Ignore the analysis cache and analyze every package")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	_ = fs.Parse(args)
+	applyLimits()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	profiles, err := codemap.ProfileLanguages(ctx, opts, *cold)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(profiles); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LANGUAGE\tFILES\tPACKAGES\tANALYZED\tCACHE HITS\tHIT RATE\tLINES PARSED\tTIME")
+	var total time.Duration
+	for _, p := range profiles {
+		total += p.Duration
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%.0f%%\t%d\t%s\n",
+			p.Language, p.Files, p.Packages, p.Analyzed, p.CacheHits, p.CacheHitRate()*100, p.Lines, p.Duration.Round(time.Microsecond))
+		if p.Error != "" {
+			fmt.Fprintf(tw, "  error: %s\t\t\t\t\t\t\t\n", p.Error)
+		}
+	}
+	_ = tw.Flush()
+	fmt.Printf("Total analysis time: %s\n", total.Round(time.Microsecond))
+	return 0
+}
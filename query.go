@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runQueryCommand handles "codemap query <owner|symbol|concern|tag> <arg>" and
+// returns the process exit code: 1 when nothing matched, 2 on errors.
+func runQueryCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap query", flag.ExitOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintln(out, "Usage: codemap query [flags] <owner|symbol|concern|tag> <arg>")
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "  owner <path>      Packages owning a file or directory")
+		fmt.Fprintln(out, "  symbol <name>     Files defining an exported type or function")
+		fmt.Fprintln(out, "  concern <name>    Files matching a concern")
+		fmt.Fprintln(out, "  tag <name>        Packages carrying a codemap:tag marker")
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Answers come from the cache of the last run; nothing is re-analyzed.")
+		fs.PrintDefaults()
+	}
+	applyLimits := bindOptionFlags(fs, &opts)
+	asJSON := fs.Bool("json", false, "Print matches as JSON")
+	viaDaemon := bindDaemonClientFlag(fs)
+	_ = fs.Parse(args)
+	applyLimits()
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	kind := fs.Arg(0)
+	var resp *codemap.DaemonResponse
+	var err error
+	if *viaDaemon {
+		resp, err = callRunningDaemon(context.Background(), opts, codemap.DaemonRequest{Command: codemap.DaemonCommandQuery, Kind: kind, Arg: fs.Arg(1)})
+	}
+	var matches []codemap.QueryMatch
+	switch {
+	case err != nil:
+	case resp != nil:
+		matches = resp.Matches
+	default:
+		matches, err = codemap.Query(opts, kind, fs.Arg(1))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if matches == nil {
+			matches = []codemap.QueryMatch{}
+		}
+		if err := enc.Encode(matches); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, m := range matches {
+			switch kind {
+			case codemap.QueryOwner, codemap.QueryTag:
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m.Package, m.Language, m.File, m.Purpose)
+			case codemap.QuerySymbol:
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m.File, m.Kind, m.Name, m.Package)
+			default:
+				fmt.Fprintf(tw, "%s\t%s\n", m.File, m.Package)
+			}
+		}
+		tw.Flush()
+	}
+	if len(matches) == 0 {
+		return 1
+	}
+	return 0
+}
@@ -129,6 +129,7 @@ fi
 
 ensure_gitignore_entry "${target_root}/.gitignore" ".codemap.state.json"
 ensure_gitignore_entry "${target_root}/.gitignore" ".codemap.state.analysis.json"
+ensure_gitignore_entry "${target_root}/.gitignore" ".codemap.state.*.json"
 
 (cd "${target_root}" && git add .gitignore 2>/dev/null || true)
 
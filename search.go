@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"text/tabwriter"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runSearchCommand handles "codemap search <regex>" and returns the process
+// exit code: 1 when nothing matched, 2 on errors.
+func runSearchCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap search", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: codemap search [flags] <regex>")
+		fs.PrintDefaults()
+	}
+	applyLimits := bindOptionFlags(fs, &opts)
+	ignoreCase := fs.Bool("i", false, "Match case-insensitively")
+	limit := fs.Int("limit", 50, "Maximum number of matches (0 = unlimited)")
+	asJSON := fs.Bool("json", false, "Print matches as JSON")
+	_ = fs.Parse(args)
+	applyLimits()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	pattern := fs.Arg(0)
+	if *ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: invalid pattern: %v\n", err)
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	matches, err := codemap.Search(ctx, opts, re, *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if matches == nil {
+			matches = []codemap.SearchMatch{}
+		}
+		if err := enc.Encode(matches); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, m := range matches {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m.Location, m.Kind, m.Name, m.Detail)
+		}
+		tw.Flush()
+	}
+	if len(matches) == 0 {
+		return 1
+	}
+	return 0
+}
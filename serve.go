@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runServeCommand handles "codemap serve" and returns the process exit code.
+func runServeCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap serve", flag.ExitOnError)
+	applyLimits := bindOptionFlags(fs, &opts)
+	addr := fs.String("addr", "127.0.0.1:8080", "HTTP listen address")
+	fs.DurationVar(&opts.StalenessGrace, "staleness-grace", 0, "Report stale outputs as healthy until they are older than this (e.g. 24h)")
+	_ = fs.Parse(args)
+	applyLimits()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           codemap.NewHTTPHandler(opts),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, done := context.WithTimeout(context.Background(), 5*time.Second)
+		defer done()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	if opts.Verbose {
+		fmt.Printf("Serving codemap health on http://%s/healthz/stale\n", *addr)
+	}
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
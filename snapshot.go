@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runExportModelCommand handles "codemap export-model" and returns the
+// process exit code.
+func runExportModelCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap export-model", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: codemap export-model [flags]")
+		fmt.Fprintln(fs.Output(), "Analyze the project and write the model plus its caches to one portable archive.")
+		fs.PrintDefaults()
+	}
+	applyLimits := bindOptionFlags(fs, &opts)
+	output := fs.String("o", "codemap-model.json.gz", "Archive to write (- for stdout)")
+	_ = fs.Parse(args)
+	applyLimits()
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	cm, err := codemap.ExportModel(ctx, opts, w)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	for _, warning := range cm.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	if *output != "-" {
+		fmt.Printf("Exported %d packages to %s\n", len(cm.Packages), *output)
+	}
+	return 0
+}
+
+// runImportModelCommand handles "codemap import-model" and returns the
+// process exit code: 0 when the outputs were written from the archive, 1 when
+// the tree differs from it and only the caches were imported, 2 on errors.
+func runImportModelCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap import-model", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: codemap import-model [flags] <archive>")
+		fmt.Fprintln(fs.Output(), "Import the caches of an export-model archive (- for stdin) and write the outputs from its model when the tree matches.")
+		fs.PrintDefaults()
+	}
+	applyLimits := bindOptionFlags(fs, &opts)
+	_ = fs.Parse(args)
+	applyLimits()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var r io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+		defer f.Close()
+		r = f
+	}
+	cm, _, err := codemap.ImportModel(ctx, opts, r)
+	if errors.Is(err, codemap.ErrSnapshotMismatch) {
+		fmt.Fprintf(os.Stderr, "Imported caches only: %v; run codemap to regenerate\n", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+	fmt.Printf("Imported %d packages\n", len(cm.Packages))
+	return 0
+}
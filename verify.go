@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runVerifyCommand handles "codemap verify" and returns the process exit code:
+// 0 when the tree matches, 1 on mismatch, 2 on errors.
+func runVerifyCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap verify", flag.ExitOnError)
+	applyLimits := bindOptionFlags(fs, &opts)
+	hash := fs.String("hash", "", "Expected content hash (default: the codemap-hash header of -output)")
+	asJSON := fs.Bool("json", false, "Print the result as JSON")
+	_ = fs.Parse(args)
+	applyLimits()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	result, err := codemap.Verify(ctx, opts, *hash)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+	} else if result.Match {
+		fmt.Printf("Verified: tree matches %s (%d files, %s)\n", result.ExpectedHash, result.Files, result.IndexMode)
+	} else {
+		fmt.Printf("Mismatch: expected %s, tree hashes to %s (%s)\n", result.ExpectedHash, result.ActualHash, result.IndexMode)
+		if result.ExpectedFiles > 0 && result.ExpectedFiles != result.Files {
+			fmt.Printf("File count differs: expected %d, found %d\n", result.ExpectedFiles, result.Files)
+		}
+	}
+	if !result.Match {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runVerifyOutputsCommand handles "codemap verify-outputs" and returns the
+// process exit code: 0 when the outputs agree, 1 when they do not, 2 on
+// errors.
+func runVerifyOutputsCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap verify-outputs", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: codemap verify-outputs [flags]")
+		fmt.Fprintln(fs.Output(), "Check that CODEMAP.md, CODEMAP.paths and -json-output embed the same hash and list the same packages.")
+		fs.PrintDefaults()
+	}
+	applyLimits := bindOptionFlags(fs, &opts)
+	asJSON := fs.Bool("json", false, "Print the mismatches as JSON")
+	_ = fs.Parse(args)
+	applyLimits()
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	mismatches, err := codemap.VerifyOutputs(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		if mismatches == nil {
+			mismatches = []codemap.OutputMismatch{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(mismatches); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+	} else if len(mismatches) == 0 {
+		fmt.Println("Outputs are consistent.")
+	} else {
+		for _, mismatch := range mismatches {
+			fmt.Println(mismatch)
+		}
+	}
+	if len(mismatches) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	codemap "github.com/Someblueman/codemap/internal/codemap"
+)
+
+// runWarmCommand handles "codemap warm" and returns the process exit code.
+func runWarmCommand(args []string) int {
+	opts := codemap.DefaultOptions()
+	fs := flag.NewFlagSet("codemap warm", flag.ExitOnError)
+	applyLimits := bindOptionFlags(fs, &opts)
+	_ = fs.Parse(args)
+	applyLimits()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	cm, err := codemap.Warm(ctx, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	for _, warning := range cm.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	fmt.Printf("Warmed caches: %d files, %d packages\n", cm.HashedFiles, len(cm.Packages))
+	return 0
+}